@@ -4,19 +4,126 @@ import "time"
 
 // Article describes an Article posted to a Feed in the tldrfeed service
 type Article struct {
-	ID            string    `json:"id"`
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	// Body is left out of listings in the TL;DR view
+	Body string `json:"body,omitempty"`
+	// Summary is a TL;DR summary of the Body, provided by the publisher or extracted from the Body
+	Summary string `json:"summary,omitempty"`
+	Author  string `json:"author,omitempty"`
+	// URL links to the original of the Article
+	URL      string   `json:"url,omitempty"`
+	ImageURL string   `json:"image_url,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	// PublishedTime is when the Article was published, which may predate its addition to the Feed for backfills.
+	// It is zero for drafts and the time they are due for scheduled Articles.
+	PublishedTime time.Time `json:"published_at"`
+	// UpdatedTime is when the Article was last changed, equal to PublishedTime for Articles never changed
+	UpdatedTime time.Time `json:"updated_at"`
+	// FeedID identifies the Feed the Article was published in
+	FeedID string `json:"feed_id,omitempty"`
+	// ClusterID groups near-duplicates of the Article published in other Feeds, it is the ID of the first
+	// Article of the cluster
+	ClusterID string `json:"cluster_id,omitempty"`
+	// Duplicates lists the other Articles of the cluster in timelines with duplicates collapsed
+	Duplicates []ArticleSource `json:"duplicates,omitempty"`
+	// BodyFormat is the format of the Body, plain text when empty
+	BodyFormat string `json:"body_format,omitempty"`
+	// Status is one of StatusPublished, StatusDraft or StatusScheduled, only published Articles are listed
+	Status string `json:"status"`
+	// PublishAt is when a scheduled Article is due to be published
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+}
+
+// ArticleSource identifies a duplicate of an Article published in another Feed
+type ArticleSource struct {
+	ArticleID     string    `json:"article_id"`
+	FeedID        string    `json:"feed_id"`
 	Title         string    `json:"title"`
-	Body          string    `json:"body"`
+	URL           string    `json:"url,omitempty"`
 	PublishedTime time.Time `json:"published_at"`
 }
 
+// Article list views
+const (
+	// ViewFull lists Articles with their bodies
+	ViewFull = "full"
+	// ViewTLDR lists Articles with their summaries instead of their bodies
+	ViewTLDR = "tldr"
+)
+
+// Article body formats
+const (
+	// BodyText is plain text
+	BodyText = "text"
+	// BodyMarkdown is Markdown, rendered to HTML on request
+	BodyMarkdown = "markdown"
+	// BodyHTML is HTML, sanitized when the Article is added
+	BodyHTML = "html"
+	// BodyOriginal requests Article bodies in the format they were added in
+	BodyOriginal = "original"
+)
+
+// Article statuses
+const (
+	// StatusPublished Articles are visible in Feeds, timelines and search
+	StatusPublished = "published"
+	// StatusDraft Articles are only visible among the drafts of their Feed until published
+	StatusDraft = "draft"
+	// StatusScheduled Articles are drafts published automatically at their PublishAt time
+	StatusScheduled = "scheduled"
+)
+
 // CreateArticleRequest defines a request to add an Article to a Feed
 type CreateArticleRequest struct {
 	Title string `json:"title" valid:"required~Article title cannot be blank"`
-	Body  string `json:"body" valid:"required~Article title cannot be blank"`
+	// Body can be up to a million characters long
+	Body string `json:"body" valid:"required~Article title cannot be blank,runelength(1|1000000)~Article body can be at most 1000000 characters"`
+	// BodyFormat is one of BodyText, BodyMarkdown or BodyHTML, defaulting to BodyText
+	BodyFormat string `json:"body_format,omitempty"`
+	// Summary is extracted from the Body when not provided
+	Summary  string   `json:"summary,omitempty"`
+	Author   string   `json:"author,omitempty"`
+	URL      string   `json:"url,omitempty"`
+	ImageURL string   `json:"image_url,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	// PublishedTime defaults to the time the Article is added, set it to backfill older Articles
+	PublishedTime *time.Time `json:"published_at,omitempty"`
+	// UpdatedTime defaults to PublishedTime
+	UpdatedTime *time.Time `json:"updated_at,omitempty"`
+	// Status defaults to StatusScheduled when PublishAt is set and StatusPublished otherwise
+	Status string `json:"status,omitempty"`
+	// PublishAt schedules the Article to be published at a future time
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+}
+
+// TagCount describes how many Articles carry a tag
+type TagCount struct {
+	Tag      string `json:"tag"`
+	Articles int    `json:"articles"`
 }
 
 // CreateArticleResponse defines a response to send for adding an Article to a Feed
 type CreateArticleResponse struct {
 	ID string `json:"id"`
 }
+
+// BatchArticleResult reports the outcome of adding one Article of a batch
+type BatchArticleResult struct {
+	// Index is the position of the Article in the batch, starting at 0
+	Index int `json:"index"`
+	// ID is set for Articles added
+	ID string `json:"id,omitempty"`
+	// Status is the HTTP status adding the Article on its own would have responded with
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// CreateArticlesResponse defines a response to send for adding a batch of Articles to a Feed. Articles are added
+// independently, a batch may be partially added.
+type CreateArticlesResponse struct {
+	Created int `json:"created"`
+	Failed  int `json:"failed"`
+	// Results lists the outcome for every Article in the order of the batch
+	Results []BatchArticleResult `json:"results"`
+}
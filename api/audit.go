@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// RequestIDHeader is the request header identifying a request in the audit log, the service assigns an ID to
+// requests without one and returns it in the response
+const RequestIDHeader = "X-Request-ID"
+
+// Audited actions
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+	// AuditPublish is recorded as drafts and scheduled Articles are published
+	AuditPublish = "publish"
+	// AuditSubscribe and AuditUnsubscribe are recorded against the User
+	AuditSubscribe   = "subscribe"
+	AuditUnsubscribe = "unsubscribe"
+	// AuditStar and AuditUnstar are recorded against the User
+	AuditStar   = "star"
+	AuditUnstar = "unstar"
+	// AuditExpire is recorded against a Feed whose expired Articles were removed and against each Article removed
+	AuditExpire = "expire"
+	// AuditRestore is recorded for records restored from an archive
+	AuditRestore = "restore"
+)
+
+// Audited entity types
+const (
+	AuditUser       = "user"
+	AuditFeed       = "feed"
+	AuditArticle    = "article"
+	AuditFilterRule = "filter_rule"
+)
+
+// AuditRecord describes a change made to a User, Feed, Article or filter rule
+type AuditRecord struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	// Principal made the change: an authenticated client, "anonymous", or the service itself (e.g. "scheduler")
+	Principal string `json:"principal"`
+	// RequestID identifies the request the change was made by
+	RequestID  string `json:"request_id,omitempty"`
+	Action     string `json:"action"`
+	EntityType string `json:"entity_type"`
+	EntityID   string `json:"entity_id"`
+	// Before and After are the entity, or the part of it which changed, before and after the change
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// AuditQuery selects audit records, zero values match all records
+type AuditQuery struct {
+	EntityType string
+	EntityID   string
+	Principal  string
+	Action     string
+	// Since and Until bound the time of the records, Since inclusively and Until exclusively
+	Since time.Time
+	Until time.Time
+	// Limit is the number of latest matching records returned, a zero limit uses the service default
+	Limit int
+}
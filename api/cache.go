@@ -0,0 +1,100 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"net/http/httputil"
+	"sync"
+)
+
+// WithCache makes the API Client keep the latest response of every listing that carries validators (ETag or
+// Last-Modified) and revalidate it with a conditional request, so unchanged listings are not transferred again
+func WithCache() ClientOption {
+	return func(c *Client) {
+		c.cache = true
+	}
+}
+
+// cachingTransport is an http.RoundTripper replaying cached responses when the service answers 304 Not Modified
+type cachingTransport struct {
+	next http.RoundTripper
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	etag         string
+	lastModified string
+	// response is the complete cached response as dumped by httputil.DumpResponse
+	response []byte
+}
+
+func newCachingTransport(next http.RoundTripper) *cachingTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &cachingTransport{
+		next:    next,
+		entries: make(map[string]cacheEntry),
+	}
+}
+
+func (t *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "GET" {
+		return t.next.RoundTrip(req)
+	}
+
+	key := req.URL.String()
+	t.mu.Lock()
+	entry, cached := t.entries[key]
+	t.mu.Unlock()
+
+	if cached {
+		// RoundTrippers must not modify the request they were given
+		req = req.WithContext(req.Context())
+		req.Header = cloneHeader(req.Header)
+		if entry.etag != "" {
+			req.Header.Set("If-None-Match", entry.etag)
+		}
+		if entry.lastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.lastModified)
+		}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case cached && resp.StatusCode == http.StatusNotModified:
+		resp.Body.Close()
+		return http.ReadResponse(bufio.NewReader(bytes.NewReader(entry.response)), req)
+	case resp.StatusCode == http.StatusOK && (resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""):
+		dump, err := httputil.DumpResponse(resp, true)
+		if err != nil {
+			return nil, err
+		}
+		t.mu.Lock()
+		t.entries[key] = cacheEntry{
+			etag:         resp.Header.Get("ETag"),
+			lastModified: resp.Header.Get("Last-Modified"),
+			response:     dump,
+		}
+		t.mu.Unlock()
+		// DumpResponse restores the body for reading
+		return resp, nil
+	default:
+		return resp, nil
+	}
+}
+
+func cloneHeader(h http.Header) http.Header {
+	clone := make(http.Header, len(h))
+	for k, v := range h {
+		clone[k] = append([]string(nil), v...)
+	}
+	return clone
+}
@@ -2,27 +2,143 @@
 package api
 
 import (
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/dghubble/sling"
+	"github.com/google/uuid"
 )
 
+// IdempotencyKeyHeader is the request header carrying keys that make POST requests safe to retry
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// retryBackoff is the delay before the first retry, doubled on every following one
+var retryBackoff = 100 * time.Millisecond
+
 // Client implements a REST Client for programmatic interaction with tldrfeed service
 type Client struct {
-	sling *sling.Sling
+	sling      *sling.Sling
+	httpClient *http.Client
+	// transport sends requests once their trace context is injected
+	transport http.RoundTripper
+	baseURL   string
+	retries   int
+	cache     bool
+}
+
+// Error is returned by the Client when the tldrfeed service responds with an error status
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// ClientOption customizes the API Client
+type ClientOption func(*Client)
+
+// WithTLSConfig makes the API Client use the provided TLS configuration, e.g. one built using NewTLSConfig
+func WithTLSConfig(config *tls.Config) ClientOption {
+	return func(c *Client) {
+		c.httpClient = &http.Client{
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: config,
+			},
+		}
+	}
+}
+
+// WithRetries makes the API Client retry requests failing with network errors or transient statuses
+// (429, 502, 503, 504) up to the given number of times. Create requests carry an idempotency key
+// so that the service does not apply them more than once.
+func WithRetries(retries int) ClientOption {
+	return func(c *Client) {
+		c.retries = retries
+	}
 }
 
 // NewClient returns a new API client for tldrfeed
-func NewClient(url string) *Client {
+func NewClient(url string, opts ...ClientOption) *Client {
+
+	c := &Client{
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.transport = c.httpClient.Transport
+	if c.cache {
+		c.transport = newCachingTransport(c.transport)
+	}
+	c.baseURL = fmt.Sprintf("%s%s/", url, APIVersion)
+	// Transports are set on a copy of the HTTP client so that the shared http.DefaultClient is left alone
+	c.setTransport(nil)
+	return c
+}
+
+// post sends a POST request decoding a successful response into success,
+// retrying transient failures with the same idempotency key
+func (c *Client) post(path string, body interface{}, success interface{}) error {
+	key := ""
+	if c.retries > 0 {
+		key = uuid.New().String()
+	}
 
-	httpClient := http.DefaultClient
-	baseURL := fmt.Sprintf("%s%s/", url, APIVersion)
-	return &Client{
-		sling: sling.New().Client(httpClient).Base(baseURL),
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		s := c.sling.New().Post(path).BodyJSON(body)
+		if key != "" {
+			s = s.Set(IdempotencyKeyHeader, key)
+		}
+		retry, err := c.do(s, success)
+		if err == nil || !retry || attempt >= c.retries {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
 	}
 }
 
+// do sends a request, returning whether it is worth retrying when it fails. The response body of a successful
+// request is decoded into success unless nil.
+func (c *Client) do(s *sling.Sling, success interface{}) (bool, error) {
+	req, err := s.Request()
+	if err != nil {
+		return false, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		err := &Error{
+			StatusCode: resp.StatusCode,
+			Message:    strings.TrimSpace(string(msg)),
+		}
+		switch resp.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true, err
+		default:
+			return false, err
+		}
+	}
+	if success == nil {
+		return false, nil
+	}
+	return false, json.NewDecoder(resp.Body).Decode(success)
+}
+
 // CreateUser creates a new User
 func (c *Client) CreateUser(name string) (*User, error) {
 
@@ -31,8 +147,7 @@ func (c *Client) CreateUser(name string) (*User, error) {
 	}
 
 	var u User
-	_, err := c.sling.Post("users").BodyJSON(createUser).ReceiveSuccess(&u)
-	if err != nil {
+	if err := c.post("users", createUser, &u); err != nil {
 		return nil, err
 	}
 	return &u, nil
@@ -40,18 +155,51 @@ func (c *Client) CreateUser(name string) (*User, error) {
 
 // CreateFeed creates a new Feed
 func (c *Client) CreateFeed(name string) (*Feed, error) {
+	return c.CreateFeedInCategory(name, "")
+}
+
+// CreateFeedInCategory creates a new Feed in a category
+func (c *Client) CreateFeedInCategory(name string, category string) (*Feed, error) {
 
 	createFeed := &CreateFeedRequest{
-		Name: name,
+		Name:     name,
+		Category: category,
 	}
+	return c.CreateFeedWithOptions(createFeed)
+}
+
+// CreateFeedWithOptions creates a new Feed with optional settings (category, retention policy)
+func (c *Client) CreateFeedWithOptions(feed *CreateFeedRequest) (*Feed, error) {
 	var f Feed
-	_, err := c.sling.Post("feeds").BodyJSON(createFeed).ReceiveSuccess(&f)
-	if err != nil {
+	if err := c.post("feeds", feed, &f); err != nil {
 		return nil, err
 	}
 	return &f, nil
 }
 
+// SetFeedRetention replaces the retention policy of a Feed, an empty policy keeps the Feed's Articles forever
+func (c *Client) SetFeedRetention(feedID string, policy *RetentionPolicy) (*Feed, error) {
+	var f Feed
+	if _, err := c.do(c.sling.New().Put(fmt.Sprintf("feeds/%s/retention", feedID)).BodyJSON(policy), &f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// RetentionReport lists the Articles of a Feed its retention policy would remove now, without removing them.
+// Non-zero fields of policy override the Feed's policy to try out a different one, policy can be nil.
+func (c *Client) RetentionReport(feedID string, policy *RetentionPolicy) (*RetentionReport, error) {
+	var r RetentionReport
+	s := c.sling.New().Get(fmt.Sprintf("feeds/%s/retention/report", feedID))
+	if policy != nil {
+		s = s.QueryStruct(policy)
+	}
+	if _, err := c.do(s, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
 // CreateArticle creates a new Article
 func (c *Client) CreateArticle(feedID string, title string, body string) (*Article, error) {
 
@@ -59,18 +207,66 @@ func (c *Client) CreateArticle(feedID string, title string, body string) (*Artic
 		Title: title,
 		Body:  body,
 	}
+	return c.PublishArticle(feedID, createArticle)
+}
+
+// PublishArticle creates a new Article with optional fields (author, URL, tags, backfilled publication time etc.)
+func (c *Client) PublishArticle(feedID string, article *CreateArticleRequest) (*Article, error) {
 	var f Article
-	_, err := c.sling.Post(fmt.Sprintf("feeds/%s", feedID)).BodyJSON(createArticle).ReceiveSuccess(&f)
-	if err != nil {
+	if err := c.post(fmt.Sprintf("feeds/%s/articles", feedID), article, &f); err != nil {
 		return nil, err
 	}
 	return &f, nil
 }
 
+// CreateArticles adds a batch of Articles to a Feed at once. Articles are validated on their own, the response
+// reports which were added and why the others were not.
+func (c *Client) CreateArticles(feedID string, articles []CreateArticleRequest) (*CreateArticlesResponse, error) {
+	var r CreateArticlesResponse
+	if err := c.post(fmt.Sprintf("feeds/%s/articles:batch", feedID), articles, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ListDrafts lists the draft and scheduled Articles of a Feed, most recently updated first
+func (c *Client) ListDrafts(feedID string) ([]Article, error) {
+	drafts := []Article{}
+	if _, err := c.do(c.sling.New().Get(fmt.Sprintf("feeds/%s/drafts", feedID)), &drafts); err != nil {
+		return nil, err
+	}
+	return drafts, nil
+}
+
+// GetDraft returns a draft or scheduled Article of a Feed
+func (c *Client) GetDraft(feedID string, articleID string) (*Article, error) {
+	var a Article
+	if _, err := c.do(c.sling.New().Get(fmt.Sprintf("feeds/%s/drafts/%s", feedID, articleID)), &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// UpdateDraft replaces a draft or scheduled Article, publishing or scheduling it according to the requested status
+func (c *Client) UpdateDraft(feedID string, articleID string, article *CreateArticleRequest) (*Article, error) {
+	var a Article
+	s := c.sling.New().Put(fmt.Sprintf("feeds/%s/drafts/%s", feedID, articleID)).BodyJSON(article)
+	if _, err := c.do(s, &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// DeleteDraft discards a draft or scheduled Article
+func (c *Client) DeleteDraft(feedID string, articleID string) error {
+	_, err := c.do(c.sling.New().Delete(fmt.Sprintf("feeds/%s/drafts/%s", feedID, articleID)), nil)
+	return err
+}
+
 // ListUsers lists all Users
 func (c *Client) ListUsers() ([]User, error) {
 	users := []User{}
-	_, err := c.sling.Get("users").ReceiveSuccess(&users)
+	_, err := c.sling.New().Get("users").ReceiveSuccess(&users)
 	if err != nil {
 		return nil, err
 	}
@@ -79,8 +275,18 @@ func (c *Client) ListUsers() ([]User, error) {
 
 // ListFeeds lists all Feeds
 func (c *Client) ListFeeds() ([]Feed, error) {
+	return c.ListFeedsInCategory("")
+}
+
+// feedListParams defines the query parameters of Feed list requests
+type feedListParams struct {
+	Category string `url:"category,omitempty"`
+}
+
+// ListFeedsInCategory lists the Feeds of a category, or all Feeds given no category
+func (c *Client) ListFeedsInCategory(category string) ([]Feed, error) {
 	feeds := []Feed{}
-	_, err := c.sling.Get("feeds").ReceiveSuccess(&feeds)
+	_, err := c.sling.New().Get("feeds").QueryStruct(&feedListParams{Category: category}).ReceiveSuccess(&feeds)
 	if err != nil {
 		return nil, err
 	}
@@ -89,27 +295,236 @@ func (c *Client) ListFeeds() ([]Feed, error) {
 
 // ListArticles lists all Articles
 func (c *Client) ListArticles(feedID string) ([]Article, error) {
+	return c.ListArticlesWithOptions(feedID, ArticleListOptions{})
+}
+
+// ListArticleSummaries lists all Articles with their summaries instead of their bodies
+func (c *Client) ListArticleSummaries(feedID string) ([]Article, error) {
+	return c.ListArticlesWithOptions(feedID, ArticleListOptions{View: ViewTLDR})
+}
+
+// ListArticlesWithOptions lists the Articles of a Feed in a view, optionally only those with a tag
+func (c *Client) ListArticlesWithOptions(feedID string, opts ArticleListOptions) ([]Article, error) {
+	return c.listArticles(fmt.Sprintf("feeds/%s/articles", feedID), opts)
+}
+
+// ListUserArticles lists Articles from all or one channel for a User
+func (c *Client) ListUserArticles(userID string, feedID string) ([]Article, error) {
+	return c.ListUserArticlesWithOptions(userID, feedID, ArticleListOptions{})
+}
+
+// ListUserArticleSummaries lists Articles from all or one channel for a User with their summaries instead of their bodies
+func (c *Client) ListUserArticleSummaries(userID string, feedID string) ([]Article, error) {
+	return c.ListUserArticlesWithOptions(userID, feedID, ArticleListOptions{View: ViewTLDR})
+}
+
+// ListUserArticlesWithOptions lists Articles from all or one channel for a User in a view, optionally only those
+// with a tag
+func (c *Client) ListUserArticlesWithOptions(userID string, feedID string, opts ArticleListOptions) ([]Article, error) {
+	return c.listArticles(userArticlesPath(userID, feedID), opts)
+}
+
+func userArticlesPath(userID string, feedID string) string {
+	if feedID == "" {
+		return fmt.Sprintf("users/%s/articles", userID)
+	}
+	return fmt.Sprintf("users/%s/feeds/%s/articles", userID, feedID)
+}
+
+// ArticleListOptions defines the query parameters of Article list requests, zero values list all Articles in
+// the full view
+type ArticleListOptions struct {
+	View string `url:"view,omitempty"`
+	Tag  string `url:"tag,omitempty"`
+	// CollapseDuplicates lists one Article per story in User timelines, with its duplicates from other Feeds attached
+	CollapseDuplicates bool `url:"collapse_duplicates,omitempty"`
+	// BodyFormat is one of BodyOriginal, BodyText or BodyHTML, defaulting to BodyOriginal
+	BodyFormat string `url:"body_format,omitempty"`
+}
+
+func (c *Client) listArticles(path string, opts ArticleListOptions) ([]Article, error) {
 	articles := []Article{}
-	_, err := c.sling.Get(fmt.Sprintf("feeds/%s/articles", feedID)).ReceiveSuccess(&articles)
+	if opts.View == ViewFull {
+		opts.View = ""
+	}
+	_, err := c.sling.New().Get(path).QueryStruct(&opts).ReceiveSuccess(&articles)
 	if err != nil {
 		return nil, err
 	}
 	return articles, nil
 }
 
-// ListUserArticles lists Articles from all or one channel for a User
-func (c *Client) ListUserArticles(userID string, feedID string) ([]Article, error) {
-	articles := []Article{}
+// Subscribe subscribes a User to a Feed
+func (c *Client) Subscribe(userID string, feedID string) error {
+	return c.post(fmt.Sprintf("users/%s/feeds", userID), &AddUserFeedRequest{FeedID: feedID}, nil)
+}
 
-	var url string
-	if feedID == "" {
-		url = fmt.Sprintf("users/%s/articles", userID)
-	} else {
-		url = fmt.Sprintf("users/%s/feeds/%s/articles", userID, feedID)
+// Unsubscribe unsubscribes a User from a Feed
+func (c *Client) Unsubscribe(userID string, feedID string) error {
+	_, err := c.do(c.sling.New().Delete(fmt.Sprintf("users/%s/feeds/%s", userID, feedID)), nil)
+	return err
+}
+
+// StarArticle stars an Article for a User, starred Articles are kept regardless of retention policies
+func (c *Client) StarArticle(userID string, articleID string) error {
+	_, err := c.do(c.sling.New().Put(fmt.Sprintf("users/%s/starred/%s", userID, articleID)), nil)
+	return err
+}
+
+// UnstarArticle removes a User's star from an Article
+func (c *Client) UnstarArticle(userID string, articleID string) error {
+	_, err := c.do(c.sling.New().Delete(fmt.Sprintf("users/%s/starred/%s", userID, articleID)), nil)
+	return err
+}
+
+// ListStarredArticles lists the Articles a User starred, most recently published first
+func (c *Client) ListStarredArticles(userID string) ([]Article, error) {
+	articles := []Article{}
+	if _, err := c.do(c.sling.New().Get(fmt.Sprintf("users/%s/starred", userID)), &articles); err != nil {
+		return nil, err
 	}
-	_, err := c.sling.Get(url).ReceiveSuccess(&articles)
+	return articles, nil
+}
+
+// ListTags lists Article tags with the number of Articles carrying them, most used tags first
+func (c *Client) ListTags() ([]TagCount, error) {
+	tags := []TagCount{}
+	_, err := c.sling.New().Get("tags").ReceiveSuccess(&tags)
 	if err != nil {
 		return nil, err
 	}
-	return articles, nil
+	return tags, nil
+}
+
+// discoverParams defines the query parameters of Feed discovery requests
+type discoverParams struct {
+	Category string `url:"category,omitempty"`
+	Limit    int    `url:"limit,omitempty"`
+}
+
+// DiscoverFeeds suggests Feeds a User is not following, optionally in a category.
+// A zero limit uses the service default.
+func (c *Client) DiscoverFeeds(userID string, category string, limit int) ([]FeedSuggestion, error) {
+	suggestions := []FeedSuggestion{}
+	params := &discoverParams{Category: category, Limit: limit}
+	if _, err := c.do(c.sling.New().Get(fmt.Sprintf("users/%s/discover", userID)).QueryStruct(params), &suggestions); err != nil {
+		return nil, err
+	}
+	return suggestions, nil
+}
+
+// Search finds Articles matching a query in all Feeds or, given a User, in the Feeds the User follows.
+// A zero limit uses the service default.
+func (c *Client) Search(userID string, query string, offset int, limit int) (*SearchResults, error) {
+	path := "search"
+	if userID != "" {
+		path = fmt.Sprintf("users/%s/search", userID)
+	}
+	params := &SearchParams{
+		Query:  query,
+		Offset: offset,
+		Limit:  limit,
+	}
+
+	var results SearchResults
+	if _, err := c.do(c.sling.New().Get(path).QueryStruct(params), &results); err != nil {
+		return nil, err
+	}
+	return &results, nil
+}
+
+// CreateFilterRule adds a rule hiding (or keeping) Articles in a User's timeline
+func (c *Client) CreateFilterRule(userID string, rule *FilterRuleRequest) (*FilterRule, error) {
+	var r FilterRule
+	if err := c.post(fmt.Sprintf("users/%s/filters", userID), rule, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ListFilterRules lists a User's filter rules
+func (c *Client) ListFilterRules(userID string) ([]FilterRule, error) {
+	filterRules := []FilterRule{}
+	if _, err := c.do(c.sling.New().Get(fmt.Sprintf("users/%s/filters", userID)), &filterRules); err != nil {
+		return nil, err
+	}
+	return filterRules, nil
+}
+
+// UpdateFilterRule replaces a User's filter rule
+func (c *Client) UpdateFilterRule(userID string, ruleID string, rule *FilterRuleRequest) (*FilterRule, error) {
+	var r FilterRule
+	if _, err := c.do(c.sling.New().Put(fmt.Sprintf("users/%s/filters/%s", userID, ruleID)).BodyJSON(rule), &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// DeleteFilterRule removes a User's filter rule
+func (c *Client) DeleteFilterRule(userID string, ruleID string) error {
+	_, err := c.do(c.sling.New().Delete(fmt.Sprintf("users/%s/filters/%s", userID, ruleID)), nil)
+	return err
+}
+
+// previewParams defines the query parameters of filter rule previews
+type previewParams struct {
+	Limit int `url:"limit,omitempty"`
+}
+
+// PreviewFilterRule shows which of up to limit recent Articles of a User a rule would hide, without saving the rule.
+// A zero limit uses the service default.
+func (c *Client) PreviewFilterRule(userID string, rule *FilterRuleRequest, limit int) (*FilterPreview, error) {
+	var p FilterPreview
+	s := c.sling.New().Post(fmt.Sprintf("users/%s/filters/preview", userID)).QueryStruct(&previewParams{Limit: limit}).BodyJSON(rule)
+	if _, err := c.do(s, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// CacheStats returns the numbers of hits and misses of the service's cached repository queries
+func (c *Client) CacheStats() (*CacheStats, error) {
+	stats := CacheStats{}
+	if _, err := c.do(c.sling.New().Get("cache/stats"), &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// auditParams defines the query parameters of audit log listings, times are formatted here as go-querystring drops
+// their fractions of a second
+type auditParams struct {
+	EntityType string `url:"entity,omitempty"`
+	EntityID   string `url:"entity_id,omitempty"`
+	Principal  string `url:"actor,omitempty"`
+	Action     string `url:"action,omitempty"`
+	Since      string `url:"since,omitempty"`
+	Until      string `url:"until,omitempty"`
+	Limit      int    `url:"limit,omitempty"`
+}
+
+// formatQueryTime formats an optional time query parameter, zero times are left out
+func formatQueryTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// ListAuditRecords lists the latest audit records matching a query, oldest first
+func (c *Client) ListAuditRecords(q AuditQuery) ([]AuditRecord, error) {
+	params := &auditParams{
+		EntityType: q.EntityType,
+		EntityID:   q.EntityID,
+		Principal:  q.Principal,
+		Action:     q.Action,
+		Since:      formatQueryTime(q.Since),
+		Until:      formatQueryTime(q.Until),
+		Limit:      q.Limit,
+	}
+	records := []AuditRecord{}
+	if _, err := c.do(c.sling.New().Get("admin/audit").QueryStruct(params), &records); err != nil {
+		return nil, err
+	}
+	return records, nil
 }
@@ -1,14 +1,31 @@
 package api
 
+import "time"
+
 // Feed defines a Feed in the tldrfeed service
 type Feed struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Category string `json:"category,omitempty"`
+	// Retention is nil for Feeds keeping their Articles forever
+	Retention *RetentionPolicy `json:"retention,omitempty"`
 }
 
 // CreateFeedRequest represents a request to create a new User
 type CreateFeedRequest struct {
 	Name string `json:"name" valid:"required~Feed name cannot be blank"`
+	// Category is optional, categories are lower-cased
+	Category string `json:"category,omitempty"`
+	// Retention is optional, Articles are kept forever by default
+	Retention *RetentionPolicy `json:"retention,omitempty"`
+}
+
+// FeedSuggestion describes a Feed recommended to a User, ranked by popularity and recent activity
+type FeedSuggestion struct {
+	Feed        Feed      `json:"feed"`
+	Subscribers int       `json:"subscribers"`
+	UpdatedTime time.Time `json:"updated_at"`
+	Score       float64   `json:"score"`
 }
 
 // AddUserFeedRequest represents a request to subscribe a User to an existing Feed
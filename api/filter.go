@@ -0,0 +1,53 @@
+package api
+
+import "time"
+
+// Filter rule types
+const (
+	// FilterKeyword matches Articles mentioning a word or phrase in their title, summary or body, ignoring case
+	FilterKeyword = "keyword"
+	// FilterRegex matches Articles whose title, summary or body match a regular expression
+	FilterRegex = "regex"
+	// FilterTag matches Articles carrying a tag
+	FilterTag = "tag"
+	// FilterFeed matches Articles of a Feed, the value being the Feed ID
+	FilterFeed = "feed"
+	// FilterAuthor matches Articles by an author, ignoring case
+	FilterAuthor = "author"
+)
+
+// Filter rule actions
+const (
+	// FilterExclude hides matching Articles
+	FilterExclude = "exclude"
+	// FilterInclude keeps matching Articles even when an exclude rule matches them
+	FilterInclude = "include"
+)
+
+// FilterRule defines a rule hiding (or keeping) Articles in a User's timeline
+type FilterRule struct {
+	ID          string    `json:"id"`
+	Type        string    `json:"type"`
+	Value       string    `json:"value"`
+	Action      string    `json:"action"`
+	CreatedTime time.Time `json:"created_at"`
+}
+
+// FilterRuleRequest represents a request to create, update or preview a filter rule
+type FilterRuleRequest struct {
+	Type  string `json:"type" valid:"required~Filter rule type cannot be blank"`
+	Value string `json:"value" valid:"required~Filter rule value cannot be blank"`
+	// Action is exclude unless set
+	Action string `json:"action,omitempty"`
+}
+
+// FilterPreview shows how a filter rule would change a User's recent Articles
+type FilterPreview struct {
+	Rule FilterRule `json:"rule"`
+	// Checked is the number of recent Articles the rule was tried on
+	Checked int `json:"checked"`
+	// Hidden are the Articles the rule would hide
+	Hidden []Article `json:"hidden"`
+	// Revealed are the Articles hidden by other rules the rule would keep
+	Revealed []Article `json:"revealed"`
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// GraphQLRequest is a GraphQL operation, sent as the JSON body of POST requests to the graphql route or as query
+// parameters of GET requests (variables being JSON encoded)
+type GraphQLRequest struct {
+	Query string `json:"query" valid:"required~GraphQL query cannot be blank"`
+	// OperationName selects the operation to run in documents defining several
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// GraphQLResponse is the result of a GraphQL operation. Operations may partly succeed, with both Data and Errors.
+type GraphQLResponse struct {
+	Data   json.RawMessage `json:"data,omitempty"`
+	Errors []GraphQLError  `json:"errors,omitempty"`
+	// Extensions carries the estimated cost of the operation
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// GraphQLError describes an error of a GraphQL operation
+type GraphQLError struct {
+	Message string `json:"message"`
+	// Path locates the field failing in the data of the response
+	Path []interface{} `json:"path,omitempty"`
+	// Extensions carries the HTTP status the REST API responds with to the same error, as "status"
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// GraphQLErrors is returned by the Client when a GraphQL operation fails
+type GraphQLErrors []GraphQLError
+
+func (e GraphQLErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// GraphQL runs a GraphQL query, decoding the data of its response into data unless nil. It fails with GraphQLErrors
+// when the response carries errors, the data of operations partly succeeding being decoded nevertheless.
+func (c *Client) GraphQL(req *GraphQLRequest, data interface{}) error {
+	var resp GraphQLResponse
+	if err := c.post("graphql", req, &resp); err != nil {
+		return err
+	}
+	if data != nil && len(resp.Data) > 0 {
+		if err := json.Unmarshal(resp.Data, data); err != nil {
+			return err
+		}
+	}
+	if len(resp.Errors) > 0 {
+		return GraphQLErrors(resp.Errors)
+	}
+	return nil
+}
@@ -0,0 +1,305 @@
+// Package grpcclient implements a gRPC Client API for the tldrfeed service, using the types of the REST Client API
+package grpcclient
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/api/tldrfeedpb"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+// propagator writes the trace context of calls to their W3C traceparent and baggage metadata
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Client implements a gRPC Client for programmatic interaction with tldrfeed service. Calls are made with the
+// context they are given and fail with gRPC status errors, see google.golang.org/grpc/status.
+type Client struct {
+	conn   *grpc.ClientConn
+	client tldrfeedpb.TLDRFeedClient
+}
+
+type options struct {
+	tlsConfig   *tls.Config
+	dialOptions []grpc.DialOption
+}
+
+// Option customizes the gRPC Client
+type Option func(*options)
+
+// WithTLSConfig makes the gRPC Client use the provided TLS configuration, e.g. one built using api.NewTLSConfig,
+// connections are not encrypted otherwise
+func WithTLSConfig(config *tls.Config) Option {
+	return func(o *options) {
+		o.tlsConfig = config
+	}
+}
+
+// WithDialOptions adds options to the gRPC connection of the Client
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(o *options) {
+		o.dialOptions = append(o.dialOptions, opts...)
+	}
+}
+
+// WithIdempotencyKey returns a context making the create calls made with it safe to retry: calls with the same key
+// and request are made once, retries get the response to the first call
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, api.IdempotencyKeyHeader, key)
+}
+
+// NewClient returns a new gRPC client for tldrfeed serving on target, e.g. "localhost:8081"
+func NewClient(target string, opts ...Option) (*Client, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	creds := insecure.NewCredentials()
+	if o.tlsConfig != nil {
+		creds = credentials.NewTLS(o.tlsConfig)
+	}
+	dialOptions := append([]grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithChainUnaryInterceptor(traceUnary),
+		grpc.WithChainStreamInterceptor(traceStream),
+	}, o.dialOptions...)
+
+	conn, err := grpc.Dial(target, dialOptions...)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, client: tldrfeedpb.NewTLDRFeedClient(conn)}, nil
+}
+
+// Close closes the connection of the Client
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// CreateUser creates a new User
+func (c *Client) CreateUser(ctx context.Context, name string) (*api.User, error) {
+	user, err := c.client.CreateUser(ctx, &tldrfeedpb.CreateUserRequest{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	u := user.ToAPI()
+	return &u, nil
+}
+
+// ListUsers lists all Users
+func (c *Client) ListUsers(ctx context.Context) ([]api.User, error) {
+	resp, err := c.client.ListUsers(ctx, &tldrfeedpb.ListUsersRequest{})
+	if err != nil {
+		return nil, err
+	}
+	users := []api.User{}
+	for _, u := range resp.GetUsers() {
+		users = append(users, u.ToAPI())
+	}
+	return users, nil
+}
+
+// GetUser returns a User
+func (c *Client) GetUser(ctx context.Context, userID string) (*api.User, error) {
+	user, err := c.client.GetUser(ctx, &tldrfeedpb.GetUserRequest{UserId: userID})
+	if err != nil {
+		return nil, err
+	}
+	u := user.ToAPI()
+	return &u, nil
+}
+
+// CreateFeed creates a new Feed with the given category and retention policy
+func (c *Client) CreateFeed(ctx context.Context, feed *api.CreateFeedRequest) (*api.Feed, error) {
+	created, err := c.client.CreateFeed(ctx, &tldrfeedpb.CreateFeedRequest{
+		Name:      feed.Name,
+		Category:  feed.Category,
+		Retention: tldrfeedpb.FromRetentionPolicy(feed.Retention),
+	})
+	if err != nil {
+		return nil, err
+	}
+	f := created.ToAPI()
+	return &f, nil
+}
+
+// ListFeeds lists the Feeds in a category, all of them when category is empty
+func (c *Client) ListFeeds(ctx context.Context, category string) ([]api.Feed, error) {
+	resp, err := c.client.ListFeeds(ctx, &tldrfeedpb.ListFeedsRequest{Category: category})
+	if err != nil {
+		return nil, err
+	}
+	return feeds(resp), nil
+}
+
+// GetFeed returns a Feed
+func (c *Client) GetFeed(ctx context.Context, feedID string) (*api.Feed, error) {
+	feed, err := c.client.GetFeed(ctx, &tldrfeedpb.GetFeedRequest{FeedId: feedID})
+	if err != nil {
+		return nil, err
+	}
+	f := feed.ToAPI()
+	return &f, nil
+}
+
+// PublishArticle adds an Article to a Feed, published, as a draft or scheduled, returning it with its ID
+func (c *Client) PublishArticle(ctx context.Context, feedID string, article *api.CreateArticleRequest) (*api.Article, error) {
+	resp, err := c.client.CreateArticle(ctx, tldrfeedpb.FromCreateArticleRequest(feedID, *article))
+	if err != nil {
+		return nil, err
+	}
+	return &api.Article{
+		ID:         resp.GetId(),
+		Title:      article.Title,
+		Body:       article.Body,
+		BodyFormat: article.BodyFormat,
+		Summary:    article.Summary,
+		Author:     article.Author,
+		URL:        article.URL,
+		ImageURL:   article.ImageURL,
+		Tags:       article.Tags,
+		FeedID:     feedID,
+		Status:     article.Status,
+		PublishAt:  article.PublishAt,
+	}, nil
+}
+
+// ListArticles lists the published Articles of a Feed
+func (c *Client) ListArticles(ctx context.Context, feedID string, opts api.ArticleListOptions) ([]api.Article, error) {
+	resp, err := c.client.ListFeedArticles(ctx, &tldrfeedpb.ListFeedArticlesRequest{
+		FeedId:  feedID,
+		Options: tldrfeedpb.FromArticleListOptions(opts),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return articles(resp), nil
+}
+
+// Subscribe subscribes a User to a Feed
+func (c *Client) Subscribe(ctx context.Context, userID string, feedID string) error {
+	_, err := c.client.Subscribe(ctx, &tldrfeedpb.SubscribeRequest{UserId: userID, FeedId: feedID})
+	return err
+}
+
+// Unsubscribe unsubscribes a User from a Feed
+func (c *Client) Unsubscribe(ctx context.Context, userID string, feedID string) error {
+	_, err := c.client.Unsubscribe(ctx, &tldrfeedpb.UnsubscribeRequest{UserId: userID, FeedId: feedID})
+	return err
+}
+
+// ListUserFeeds lists the Feeds a User is following
+func (c *Client) ListUserFeeds(ctx context.Context, userID string) ([]api.Feed, error) {
+	resp, err := c.client.ListUserFeeds(ctx, &tldrfeedpb.ListUserFeedsRequest{UserId: userID})
+	if err != nil {
+		return nil, err
+	}
+	return feeds(resp), nil
+}
+
+// ListUserArticles lists the timeline of a User, or the Articles of one of the Feeds they follow when feedID is set
+func (c *Client) ListUserArticles(ctx context.Context, userID string, feedID string, opts api.ArticleListOptions) ([]api.Article, error) {
+	resp, err := c.client.ListUserArticles(ctx, &tldrfeedpb.ListUserArticlesRequest{
+		UserId:  userID,
+		FeedId:  feedID,
+		Options: tldrfeedpb.FromArticleListOptions(opts),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return articles(resp), nil
+}
+
+// WatchUserArticles calls fn with the Articles published to the Feeds a User follows as they are published, until
+// ctx is done, fn fails or the service ends the stream. Articles are only streamed once the watch has started, list
+// the User's Articles once it returns ResourceExhausted to catch up with the Articles missed.
+func (c *Client) WatchUserArticles(ctx context.Context, userID string, opts api.ArticleListOptions, fn func(api.Article) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := c.client.WatchUserArticles(ctx, &tldrfeedpb.WatchUserArticlesRequest{
+		UserId:  userID,
+		Options: tldrfeedpb.FromArticleListOptions(opts),
+	})
+	if err != nil {
+		return err
+	}
+	for {
+		article, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(article.ToAPI()); err != nil {
+			return err
+		}
+	}
+}
+
+func feeds(resp *tldrfeedpb.ListFeedsResponse) []api.Feed {
+	feeds := []api.Feed{}
+	for _, f := range resp.GetFeeds() {
+		feeds = append(feeds, f.ToAPI())
+	}
+	return feeds
+}
+
+func articles(resp *tldrfeedpb.ListArticlesResponse) []api.Article {
+	articles := []api.Article{}
+	for _, a := range resp.GetArticles() {
+		articles = append(articles, a.ToAPI())
+	}
+	return articles
+}
+
+// traceUnary injects the trace context of unary calls into their metadata, so that the service continues the
+// caller's trace
+func traceUnary(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return invoker(injectTrace(ctx), method, req, reply, cc, opts...)
+}
+
+// traceStream injects the trace context of streaming calls into their metadata
+func traceStream(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string,
+	streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return streamer(injectTrace(ctx), desc, cc, method, opts...)
+}
+
+func injectTrace(ctx context.Context) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	propagator.Inject(ctx, metadataCarrier(md))
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// metadataCarrier adapts gRPC metadata to OpenTelemetry propagation
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (c metadataCarrier) Set(key string, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
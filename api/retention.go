@@ -0,0 +1,44 @@
+package api
+
+import "time"
+
+// RetentionPolicy limits how long the published Articles of a Feed are kept, zero values keep Articles forever.
+// Articles starred by any User are kept regardless of the policy.
+type RetentionPolicy struct {
+	// MaxAgeDays removes Articles published more than this many days ago
+	MaxAgeDays int `json:"max_age_days,omitempty" url:"max_age_days,omitempty"`
+	// MaxCount keeps only this many of the most recently published Articles
+	MaxCount int `json:"max_count,omitempty" url:"max_count,omitempty"`
+}
+
+// Empty returns true for policies keeping Articles forever
+func (p RetentionPolicy) Empty() bool {
+	return p.MaxAgeDays == 0 && p.MaxCount == 0
+}
+
+// Reasons Articles expire for
+const (
+	// ExpiryMaxAge marks Articles older than a policy's MaxAgeDays
+	ExpiryMaxAge = "max_age"
+	// ExpiryMaxCount marks Articles beyond a policy's MaxCount most recent Articles
+	ExpiryMaxCount = "max_count"
+)
+
+// ExpiredArticle describes an Article a retention policy removes
+type ExpiredArticle struct {
+	ID            string    `json:"id"`
+	Title         string    `json:"title"`
+	PublishedTime time.Time `json:"published_at"`
+	// Reason is ExpiryMaxAge or ExpiryMaxCount, ExpiryMaxAge taking precedence when both apply
+	Reason string `json:"reason"`
+}
+
+// RetentionReport lists the Articles of a Feed a retention policy would remove, without removing them
+type RetentionReport struct {
+	FeedID string          `json:"feed_id"`
+	Policy RetentionPolicy `json:"policy"`
+	// Expired lists the Articles removed, oldest first
+	Expired []ExpiredArticle `json:"expired"`
+	// Starred lists the expired Articles kept because they are starred, oldest first
+	Starred []ExpiredArticle `json:"starred"`
+}
@@ -0,0 +1,29 @@
+package api
+
+// SearchResults describes a page of Articles matching a search query, best matches first
+type SearchResults struct {
+	Query string `json:"query"`
+	// Total is the number of all matching Articles
+	Total  int         `json:"total"`
+	Offset int         `json:"offset"`
+	Limit  int         `json:"limit"`
+	Hits   []SearchHit `json:"hits"`
+}
+
+// SearchHit describes an Article matching a search query
+type SearchHit struct {
+	Article Article `json:"article"`
+	FeedID  string  `json:"feed_id"`
+	Score   float64 `json:"score"`
+	// Highlights maps matching fields ("title", "body") to HTML snippets with matches wrapped in <mark> tags
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+// SearchParams defines the query parameters of a search request
+type SearchParams struct {
+	Query  string `url:"q"`
+	Offset int    `url:"offset,omitempty"`
+	Limit  int    `url:"limit,omitempty"`
+	// BodyFormat is one of BodyOriginal, BodyText or BodyHTML, defaulting to BodyOriginal
+	BodyFormat string `url:"body_format,omitempty"`
+}
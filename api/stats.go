@@ -0,0 +1,16 @@
+package api
+
+// CacheStats counts hits and misses of the repository queries answered from the cache
+type CacheStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+	// Errors counts failures of the cache store, queries are answered by the DB instead
+	Errors  int64                      `json:"errors"`
+	Queries map[string]CacheQueryStats `json:"queries"`
+}
+
+// CacheQueryStats counts hits and misses of a single cached query
+type CacheQueryStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
@@ -0,0 +1,194 @@
+package tldrfeedpb
+
+import (
+	"time"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Conversions between the messages of the gRPC API and the types of the REST API, zero times are left unset
+
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func optionalTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func fromTimestamp(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
+
+func fromOptionalTimestamp(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
+
+// FromUser converts a User of the REST API
+func FromUser(u api.User) *User {
+	return &User{Id: u.ID, Name: u.Name}
+}
+
+// ToAPI converts a User to the REST API
+func (u *User) ToAPI() api.User {
+	return api.User{ID: u.GetId(), Name: u.GetName()}
+}
+
+// FromRetentionPolicy converts a retention policy of the REST API, nil policies keep Articles forever
+func FromRetentionPolicy(p *api.RetentionPolicy) *RetentionPolicy {
+	if p == nil {
+		return nil
+	}
+	return &RetentionPolicy{MaxAgeDays: int32(p.MaxAgeDays), MaxCount: int32(p.MaxCount)}
+}
+
+// ToAPI converts a retention policy to the REST API
+func (p *RetentionPolicy) ToAPI() *api.RetentionPolicy {
+	if p == nil {
+		return nil
+	}
+	return &api.RetentionPolicy{MaxAgeDays: int(p.MaxAgeDays), MaxCount: int(p.MaxCount)}
+}
+
+// FromFeed converts a Feed of the REST API
+func FromFeed(f api.Feed) *Feed {
+	return &Feed{Id: f.ID, Name: f.Name, Category: f.Category, Retention: FromRetentionPolicy(f.Retention)}
+}
+
+// ToAPI converts a Feed to the REST API
+func (f *Feed) ToAPI() api.Feed {
+	return api.Feed{ID: f.GetId(), Name: f.GetName(), Category: f.GetCategory(), Retention: f.GetRetention().ToAPI()}
+}
+
+// FromArticle converts an Article of the REST API
+func FromArticle(a api.Article) *Article {
+	article := &Article{
+		Id:          a.ID,
+		Title:       a.Title,
+		Body:        a.Body,
+		Summary:     a.Summary,
+		Author:      a.Author,
+		Url:         a.URL,
+		ImageUrl:    a.ImageURL,
+		Tags:        a.Tags,
+		PublishedAt: timestamp(a.PublishedTime),
+		UpdatedAt:   timestamp(a.UpdatedTime),
+		FeedId:      a.FeedID,
+		ClusterId:   a.ClusterID,
+		BodyFormat:  a.BodyFormat,
+		Status:      a.Status,
+		PublishAt:   optionalTimestamp(a.PublishAt),
+	}
+	for _, d := range a.Duplicates {
+		article.Duplicates = append(article.Duplicates, &ArticleSource{
+			ArticleId:   d.ArticleID,
+			FeedId:      d.FeedID,
+			Title:       d.Title,
+			Url:         d.URL,
+			PublishedAt: timestamp(d.PublishedTime),
+		})
+	}
+	return article
+}
+
+// ToAPI converts an Article to the REST API
+func (a *Article) ToAPI() api.Article {
+	article := api.Article{
+		ID:            a.GetId(),
+		Title:         a.GetTitle(),
+		Body:          a.GetBody(),
+		Summary:       a.GetSummary(),
+		Author:        a.GetAuthor(),
+		URL:           a.GetUrl(),
+		ImageURL:      a.GetImageUrl(),
+		Tags:          a.GetTags(),
+		PublishedTime: fromTimestamp(a.GetPublishedAt()),
+		UpdatedTime:   fromTimestamp(a.GetUpdatedAt()),
+		FeedID:        a.GetFeedId(),
+		ClusterID:     a.GetClusterId(),
+		BodyFormat:    a.GetBodyFormat(),
+		Status:        a.GetStatus(),
+		PublishAt:     fromOptionalTimestamp(a.GetPublishAt()),
+	}
+	for _, d := range a.GetDuplicates() {
+		article.Duplicates = append(article.Duplicates, api.ArticleSource{
+			ArticleID:     d.GetArticleId(),
+			FeedID:        d.GetFeedId(),
+			Title:         d.GetTitle(),
+			URL:           d.GetUrl(),
+			PublishedTime: fromTimestamp(d.GetPublishedAt()),
+		})
+	}
+	return article
+}
+
+// FromCreateArticleRequest converts a request of the REST API to add an Article to a Feed
+func FromCreateArticleRequest(feedID string, r api.CreateArticleRequest) *CreateArticleRequest {
+	return &CreateArticleRequest{
+		FeedId:      feedID,
+		Title:       r.Title,
+		Body:        r.Body,
+		BodyFormat:  r.BodyFormat,
+		Summary:     r.Summary,
+		Author:      r.Author,
+		Url:         r.URL,
+		ImageUrl:    r.ImageURL,
+		Tags:        r.Tags,
+		PublishedAt: optionalTimestamp(r.PublishedTime),
+		UpdatedAt:   optionalTimestamp(r.UpdatedTime),
+		Status:      r.Status,
+		PublishAt:   optionalTimestamp(r.PublishAt),
+	}
+}
+
+// ToAPI converts a request to add an Article to the REST API, leaving out the Feed ID
+func (r *CreateArticleRequest) ToAPI() api.CreateArticleRequest {
+	return api.CreateArticleRequest{
+		Title:         r.GetTitle(),
+		Body:          r.GetBody(),
+		BodyFormat:    r.GetBodyFormat(),
+		Summary:       r.GetSummary(),
+		Author:        r.GetAuthor(),
+		URL:           r.GetUrl(),
+		ImageURL:      r.GetImageUrl(),
+		Tags:          r.GetTags(),
+		PublishedTime: fromOptionalTimestamp(r.GetPublishedAt()),
+		UpdatedTime:   fromOptionalTimestamp(r.GetUpdatedAt()),
+		Status:        r.GetStatus(),
+		PublishAt:     fromOptionalTimestamp(r.GetPublishAt()),
+	}
+}
+
+// FromArticleListOptions converts Article list options of the REST API
+func FromArticleListOptions(o api.ArticleListOptions) *ArticleListOptions {
+	return &ArticleListOptions{
+		View:               o.View,
+		Tag:                o.Tag,
+		CollapseDuplicates: o.CollapseDuplicates,
+		BodyFormat:         o.BodyFormat,
+	}
+}
+
+// ToAPI converts Article list options to the REST API, unset options list all Articles in the full view
+func (o *ArticleListOptions) ToAPI() api.ArticleListOptions {
+	return api.ArticleListOptions{
+		View:               o.GetView(),
+		Tag:                o.GetTag(),
+		CollapseDuplicates: o.GetCollapseDuplicates(),
+		BodyFormat:         o.GetBodyFormat(),
+	}
+}
@@ -0,0 +1,617 @@
+// gRPC API of the tldrfeed service, mirroring the Users, Feeds, Articles and subscriptions of the REST API.
+//
+// Regenerate the Go code with `make proto` after changing this file.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: tldrfeed.proto
+
+package tldrfeedpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	TLDRFeed_CreateUser_FullMethodName        = "/tldrfeed.v1.TLDRFeed/CreateUser"
+	TLDRFeed_ListUsers_FullMethodName         = "/tldrfeed.v1.TLDRFeed/ListUsers"
+	TLDRFeed_GetUser_FullMethodName           = "/tldrfeed.v1.TLDRFeed/GetUser"
+	TLDRFeed_CreateFeed_FullMethodName        = "/tldrfeed.v1.TLDRFeed/CreateFeed"
+	TLDRFeed_ListFeeds_FullMethodName         = "/tldrfeed.v1.TLDRFeed/ListFeeds"
+	TLDRFeed_GetFeed_FullMethodName           = "/tldrfeed.v1.TLDRFeed/GetFeed"
+	TLDRFeed_CreateArticle_FullMethodName     = "/tldrfeed.v1.TLDRFeed/CreateArticle"
+	TLDRFeed_ListFeedArticles_FullMethodName  = "/tldrfeed.v1.TLDRFeed/ListFeedArticles"
+	TLDRFeed_Subscribe_FullMethodName         = "/tldrfeed.v1.TLDRFeed/Subscribe"
+	TLDRFeed_Unsubscribe_FullMethodName       = "/tldrfeed.v1.TLDRFeed/Unsubscribe"
+	TLDRFeed_ListUserFeeds_FullMethodName     = "/tldrfeed.v1.TLDRFeed/ListUserFeeds"
+	TLDRFeed_ListUserArticles_FullMethodName  = "/tldrfeed.v1.TLDRFeed/ListUserArticles"
+	TLDRFeed_WatchUserArticles_FullMethodName = "/tldrfeed.v1.TLDRFeed/WatchUserArticles"
+)
+
+// TLDRFeedClient is the client API for TLDRFeed service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type TLDRFeedClient interface {
+	// CreateUser creates a User, user names are alphanumeric and unique
+	CreateUser(ctx context.Context, in *CreateUserRequest, opts ...grpc.CallOption) (*User, error)
+	// ListUsers lists all Users
+	ListUsers(ctx context.Context, in *ListUsersRequest, opts ...grpc.CallOption) (*ListUsersResponse, error)
+	// GetUser returns a User
+	GetUser(ctx context.Context, in *GetUserRequest, opts ...grpc.CallOption) (*User, error)
+	// CreateFeed creates a Feed
+	CreateFeed(ctx context.Context, in *CreateFeedRequest, opts ...grpc.CallOption) (*Feed, error)
+	// ListFeeds lists all Feeds, optionally in a category
+	ListFeeds(ctx context.Context, in *ListFeedsRequest, opts ...grpc.CallOption) (*ListFeedsResponse, error)
+	// GetFeed returns a Feed
+	GetFeed(ctx context.Context, in *GetFeedRequest, opts ...grpc.CallOption) (*Feed, error)
+	// CreateArticle adds an Article to a Feed, published, as a draft or scheduled
+	CreateArticle(ctx context.Context, in *CreateArticleRequest, opts ...grpc.CallOption) (*CreateArticleResponse, error)
+	// ListFeedArticles lists the published Articles of a Feed
+	ListFeedArticles(ctx context.Context, in *ListFeedArticlesRequest, opts ...grpc.CallOption) (*ListArticlesResponse, error)
+	// Subscribe subscribes a User to a Feed
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (*SubscribeResponse, error)
+	// Unsubscribe unsubscribes a User from a Feed
+	Unsubscribe(ctx context.Context, in *UnsubscribeRequest, opts ...grpc.CallOption) (*UnsubscribeResponse, error)
+	// ListUserFeeds lists the Feeds a User is following
+	ListUserFeeds(ctx context.Context, in *ListUserFeedsRequest, opts ...grpc.CallOption) (*ListFeedsResponse, error)
+	// ListUserArticles lists the timeline of a User, or the Articles of one of the Feeds they follow, with the User's
+	// filter rules applied
+	ListUserArticles(ctx context.Context, in *ListUserArticlesRequest, opts ...grpc.CallOption) (*ListArticlesResponse, error)
+	// WatchUserArticles streams the Articles published to the Feeds a User follows as they are published, with the
+	// User's filter rules applied. Streams falling too far behind are ended with RESOURCE_EXHAUSTED, clients catch up
+	// by listing the User's Articles before watching again.
+	WatchUserArticles(ctx context.Context, in *WatchUserArticlesRequest, opts ...grpc.CallOption) (TLDRFeed_WatchUserArticlesClient, error)
+}
+
+type tLDRFeedClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTLDRFeedClient(cc grpc.ClientConnInterface) TLDRFeedClient {
+	return &tLDRFeedClient{cc}
+}
+
+func (c *tLDRFeedClient) CreateUser(ctx context.Context, in *CreateUserRequest, opts ...grpc.CallOption) (*User, error) {
+	out := new(User)
+	err := c.cc.Invoke(ctx, TLDRFeed_CreateUser_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tLDRFeedClient) ListUsers(ctx context.Context, in *ListUsersRequest, opts ...grpc.CallOption) (*ListUsersResponse, error) {
+	out := new(ListUsersResponse)
+	err := c.cc.Invoke(ctx, TLDRFeed_ListUsers_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tLDRFeedClient) GetUser(ctx context.Context, in *GetUserRequest, opts ...grpc.CallOption) (*User, error) {
+	out := new(User)
+	err := c.cc.Invoke(ctx, TLDRFeed_GetUser_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tLDRFeedClient) CreateFeed(ctx context.Context, in *CreateFeedRequest, opts ...grpc.CallOption) (*Feed, error) {
+	out := new(Feed)
+	err := c.cc.Invoke(ctx, TLDRFeed_CreateFeed_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tLDRFeedClient) ListFeeds(ctx context.Context, in *ListFeedsRequest, opts ...grpc.CallOption) (*ListFeedsResponse, error) {
+	out := new(ListFeedsResponse)
+	err := c.cc.Invoke(ctx, TLDRFeed_ListFeeds_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tLDRFeedClient) GetFeed(ctx context.Context, in *GetFeedRequest, opts ...grpc.CallOption) (*Feed, error) {
+	out := new(Feed)
+	err := c.cc.Invoke(ctx, TLDRFeed_GetFeed_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tLDRFeedClient) CreateArticle(ctx context.Context, in *CreateArticleRequest, opts ...grpc.CallOption) (*CreateArticleResponse, error) {
+	out := new(CreateArticleResponse)
+	err := c.cc.Invoke(ctx, TLDRFeed_CreateArticle_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tLDRFeedClient) ListFeedArticles(ctx context.Context, in *ListFeedArticlesRequest, opts ...grpc.CallOption) (*ListArticlesResponse, error) {
+	out := new(ListArticlesResponse)
+	err := c.cc.Invoke(ctx, TLDRFeed_ListFeedArticles_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tLDRFeedClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (*SubscribeResponse, error) {
+	out := new(SubscribeResponse)
+	err := c.cc.Invoke(ctx, TLDRFeed_Subscribe_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tLDRFeedClient) Unsubscribe(ctx context.Context, in *UnsubscribeRequest, opts ...grpc.CallOption) (*UnsubscribeResponse, error) {
+	out := new(UnsubscribeResponse)
+	err := c.cc.Invoke(ctx, TLDRFeed_Unsubscribe_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tLDRFeedClient) ListUserFeeds(ctx context.Context, in *ListUserFeedsRequest, opts ...grpc.CallOption) (*ListFeedsResponse, error) {
+	out := new(ListFeedsResponse)
+	err := c.cc.Invoke(ctx, TLDRFeed_ListUserFeeds_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tLDRFeedClient) ListUserArticles(ctx context.Context, in *ListUserArticlesRequest, opts ...grpc.CallOption) (*ListArticlesResponse, error) {
+	out := new(ListArticlesResponse)
+	err := c.cc.Invoke(ctx, TLDRFeed_ListUserArticles_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tLDRFeedClient) WatchUserArticles(ctx context.Context, in *WatchUserArticlesRequest, opts ...grpc.CallOption) (TLDRFeed_WatchUserArticlesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &TLDRFeed_ServiceDesc.Streams[0], TLDRFeed_WatchUserArticles_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &tLDRFeedWatchUserArticlesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TLDRFeed_WatchUserArticlesClient interface {
+	Recv() (*Article, error)
+	grpc.ClientStream
+}
+
+type tLDRFeedWatchUserArticlesClient struct {
+	grpc.ClientStream
+}
+
+func (x *tLDRFeedWatchUserArticlesClient) Recv() (*Article, error) {
+	m := new(Article)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// TLDRFeedServer is the server API for TLDRFeed service.
+// All implementations must embed UnimplementedTLDRFeedServer
+// for forward compatibility
+type TLDRFeedServer interface {
+	// CreateUser creates a User, user names are alphanumeric and unique
+	CreateUser(context.Context, *CreateUserRequest) (*User, error)
+	// ListUsers lists all Users
+	ListUsers(context.Context, *ListUsersRequest) (*ListUsersResponse, error)
+	// GetUser returns a User
+	GetUser(context.Context, *GetUserRequest) (*User, error)
+	// CreateFeed creates a Feed
+	CreateFeed(context.Context, *CreateFeedRequest) (*Feed, error)
+	// ListFeeds lists all Feeds, optionally in a category
+	ListFeeds(context.Context, *ListFeedsRequest) (*ListFeedsResponse, error)
+	// GetFeed returns a Feed
+	GetFeed(context.Context, *GetFeedRequest) (*Feed, error)
+	// CreateArticle adds an Article to a Feed, published, as a draft or scheduled
+	CreateArticle(context.Context, *CreateArticleRequest) (*CreateArticleResponse, error)
+	// ListFeedArticles lists the published Articles of a Feed
+	ListFeedArticles(context.Context, *ListFeedArticlesRequest) (*ListArticlesResponse, error)
+	// Subscribe subscribes a User to a Feed
+	Subscribe(context.Context, *SubscribeRequest) (*SubscribeResponse, error)
+	// Unsubscribe unsubscribes a User from a Feed
+	Unsubscribe(context.Context, *UnsubscribeRequest) (*UnsubscribeResponse, error)
+	// ListUserFeeds lists the Feeds a User is following
+	ListUserFeeds(context.Context, *ListUserFeedsRequest) (*ListFeedsResponse, error)
+	// ListUserArticles lists the timeline of a User, or the Articles of one of the Feeds they follow, with the User's
+	// filter rules applied
+	ListUserArticles(context.Context, *ListUserArticlesRequest) (*ListArticlesResponse, error)
+	// WatchUserArticles streams the Articles published to the Feeds a User follows as they are published, with the
+	// User's filter rules applied. Streams falling too far behind are ended with RESOURCE_EXHAUSTED, clients catch up
+	// by listing the User's Articles before watching again.
+	WatchUserArticles(*WatchUserArticlesRequest, TLDRFeed_WatchUserArticlesServer) error
+	mustEmbedUnimplementedTLDRFeedServer()
+}
+
+// UnimplementedTLDRFeedServer must be embedded to have forward compatible implementations.
+type UnimplementedTLDRFeedServer struct {
+}
+
+func (UnimplementedTLDRFeedServer) CreateUser(context.Context, *CreateUserRequest) (*User, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateUser not implemented")
+}
+func (UnimplementedTLDRFeedServer) ListUsers(context.Context, *ListUsersRequest) (*ListUsersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListUsers not implemented")
+}
+func (UnimplementedTLDRFeedServer) GetUser(context.Context, *GetUserRequest) (*User, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUser not implemented")
+}
+func (UnimplementedTLDRFeedServer) CreateFeed(context.Context, *CreateFeedRequest) (*Feed, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateFeed not implemented")
+}
+func (UnimplementedTLDRFeedServer) ListFeeds(context.Context, *ListFeedsRequest) (*ListFeedsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListFeeds not implemented")
+}
+func (UnimplementedTLDRFeedServer) GetFeed(context.Context, *GetFeedRequest) (*Feed, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFeed not implemented")
+}
+func (UnimplementedTLDRFeedServer) CreateArticle(context.Context, *CreateArticleRequest) (*CreateArticleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateArticle not implemented")
+}
+func (UnimplementedTLDRFeedServer) ListFeedArticles(context.Context, *ListFeedArticlesRequest) (*ListArticlesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListFeedArticles not implemented")
+}
+func (UnimplementedTLDRFeedServer) Subscribe(context.Context, *SubscribeRequest) (*SubscribeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedTLDRFeedServer) Unsubscribe(context.Context, *UnsubscribeRequest) (*UnsubscribeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Unsubscribe not implemented")
+}
+func (UnimplementedTLDRFeedServer) ListUserFeeds(context.Context, *ListUserFeedsRequest) (*ListFeedsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListUserFeeds not implemented")
+}
+func (UnimplementedTLDRFeedServer) ListUserArticles(context.Context, *ListUserArticlesRequest) (*ListArticlesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListUserArticles not implemented")
+}
+func (UnimplementedTLDRFeedServer) WatchUserArticles(*WatchUserArticlesRequest, TLDRFeed_WatchUserArticlesServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchUserArticles not implemented")
+}
+func (UnimplementedTLDRFeedServer) mustEmbedUnimplementedTLDRFeedServer() {}
+
+// UnsafeTLDRFeedServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TLDRFeedServer will
+// result in compilation errors.
+type UnsafeTLDRFeedServer interface {
+	mustEmbedUnimplementedTLDRFeedServer()
+}
+
+func RegisterTLDRFeedServer(s grpc.ServiceRegistrar, srv TLDRFeedServer) {
+	s.RegisterService(&TLDRFeed_ServiceDesc, srv)
+}
+
+func _TLDRFeed_CreateUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TLDRFeedServer).CreateUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TLDRFeed_CreateUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TLDRFeedServer).CreateUser(ctx, req.(*CreateUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TLDRFeed_ListUsers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListUsersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TLDRFeedServer).ListUsers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TLDRFeed_ListUsers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TLDRFeedServer).ListUsers(ctx, req.(*ListUsersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TLDRFeed_GetUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TLDRFeedServer).GetUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TLDRFeed_GetUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TLDRFeedServer).GetUser(ctx, req.(*GetUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TLDRFeed_CreateFeed_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateFeedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TLDRFeedServer).CreateFeed(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TLDRFeed_CreateFeed_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TLDRFeedServer).CreateFeed(ctx, req.(*CreateFeedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TLDRFeed_ListFeeds_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListFeedsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TLDRFeedServer).ListFeeds(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TLDRFeed_ListFeeds_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TLDRFeedServer).ListFeeds(ctx, req.(*ListFeedsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TLDRFeed_GetFeed_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetFeedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TLDRFeedServer).GetFeed(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TLDRFeed_GetFeed_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TLDRFeedServer).GetFeed(ctx, req.(*GetFeedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TLDRFeed_CreateArticle_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateArticleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TLDRFeedServer).CreateArticle(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TLDRFeed_CreateArticle_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TLDRFeedServer).CreateArticle(ctx, req.(*CreateArticleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TLDRFeed_ListFeedArticles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListFeedArticlesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TLDRFeedServer).ListFeedArticles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TLDRFeed_ListFeedArticles_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TLDRFeedServer).ListFeedArticles(ctx, req.(*ListFeedArticlesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TLDRFeed_Subscribe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubscribeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TLDRFeedServer).Subscribe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TLDRFeed_Subscribe_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TLDRFeedServer).Subscribe(ctx, req.(*SubscribeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TLDRFeed_Unsubscribe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnsubscribeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TLDRFeedServer).Unsubscribe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TLDRFeed_Unsubscribe_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TLDRFeedServer).Unsubscribe(ctx, req.(*UnsubscribeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TLDRFeed_ListUserFeeds_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListUserFeedsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TLDRFeedServer).ListUserFeeds(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TLDRFeed_ListUserFeeds_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TLDRFeedServer).ListUserFeeds(ctx, req.(*ListUserFeedsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TLDRFeed_ListUserArticles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListUserArticlesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TLDRFeedServer).ListUserArticles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TLDRFeed_ListUserArticles_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TLDRFeedServer).ListUserArticles(ctx, req.(*ListUserArticlesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TLDRFeed_WatchUserArticles_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchUserArticlesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TLDRFeedServer).WatchUserArticles(m, &tLDRFeedWatchUserArticlesServer{stream})
+}
+
+type TLDRFeed_WatchUserArticlesServer interface {
+	Send(*Article) error
+	grpc.ServerStream
+}
+
+type tLDRFeedWatchUserArticlesServer struct {
+	grpc.ServerStream
+}
+
+func (x *tLDRFeedWatchUserArticlesServer) Send(m *Article) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// TLDRFeed_ServiceDesc is the grpc.ServiceDesc for TLDRFeed service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var TLDRFeed_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "tldrfeed.v1.TLDRFeed",
+	HandlerType: (*TLDRFeedServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateUser",
+			Handler:    _TLDRFeed_CreateUser_Handler,
+		},
+		{
+			MethodName: "ListUsers",
+			Handler:    _TLDRFeed_ListUsers_Handler,
+		},
+		{
+			MethodName: "GetUser",
+			Handler:    _TLDRFeed_GetUser_Handler,
+		},
+		{
+			MethodName: "CreateFeed",
+			Handler:    _TLDRFeed_CreateFeed_Handler,
+		},
+		{
+			MethodName: "ListFeeds",
+			Handler:    _TLDRFeed_ListFeeds_Handler,
+		},
+		{
+			MethodName: "GetFeed",
+			Handler:    _TLDRFeed_GetFeed_Handler,
+		},
+		{
+			MethodName: "CreateArticle",
+			Handler:    _TLDRFeed_CreateArticle_Handler,
+		},
+		{
+			MethodName: "ListFeedArticles",
+			Handler:    _TLDRFeed_ListFeedArticles_Handler,
+		},
+		{
+			MethodName: "Subscribe",
+			Handler:    _TLDRFeed_Subscribe_Handler,
+		},
+		{
+			MethodName: "Unsubscribe",
+			Handler:    _TLDRFeed_Unsubscribe_Handler,
+		},
+		{
+			MethodName: "ListUserFeeds",
+			Handler:    _TLDRFeed_ListUserFeeds_Handler,
+		},
+		{
+			MethodName: "ListUserArticles",
+			Handler:    _TLDRFeed_ListUserArticles_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchUserArticles",
+			Handler:       _TLDRFeed_WatchUserArticles_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "tldrfeed.proto",
+}
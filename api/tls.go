@@ -0,0 +1,39 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+// NewTLSConfig builds client side TLS configuration for talking to a tldrfeed service over HTTPS.
+// caFile is an optional PEM bundle of CAs to trust instead of the system roots,
+// certFile and keyFile are an optional client certificate key pair for mutual TLS.
+func NewTLSConfig(caFile string, certFile string, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to read CA bundle '%s'", caFile)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("No PEM certificates found in CA bundle '%s'", caFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to load client key pair '%s', '%s'", certFile, keyFile)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dghubble/sling"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// propagator writes the trace context of requests to their W3C traceparent and baggage headers
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// WithContext returns a copy of the Client making its requests with ctx: they are canceled with it and carry the
+// trace context of its span, so that the service continues the caller's trace
+func (c *Client) WithContext(ctx context.Context) *Client {
+	clone := *c
+	clone.setTransport(ctx)
+	return &clone
+}
+
+// setTransport makes the Client send its requests through a tracingTransport making them with ctx, or with their own
+// context when nil
+func (c *Client) setTransport(ctx context.Context) {
+	httpClient := *c.httpClient
+	httpClient.Transport = &tracingTransport{next: c.transport, ctx: ctx}
+	c.httpClient = &httpClient
+	c.sling = sling.New().Client(c.httpClient).Base(c.baseURL)
+}
+
+// tracingTransport is an http.RoundTripper injecting the trace context of requests into their headers
+type tracingTransport struct {
+	next http.RoundTripper
+	ctx  context.Context
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := t.ctx
+	if ctx == nil {
+		ctx = req.Context()
+	}
+	// RoundTrippers must not modify the request they were given
+	req = req.WithContext(ctx)
+	req.Header = cloneHeader(req.Header)
+	propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return next.RoundTrip(req)
+}
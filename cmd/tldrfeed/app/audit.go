@@ -0,0 +1,124 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/spf13/cobra"
+)
+
+// followLimit is the number of records asked for by every poll of a followed audit log, the service's maximum
+const followLimit = 1000
+
+var auditQuery api.AuditQuery
+var auditSince string
+var auditFollow bool
+var auditInterval time.Duration
+var auditPayloads bool
+
+func init() {
+	addClientFlags(auditCmd.Flags())
+	auditCmd.Flags().StringVar(&auditQuery.EntityType, "entity", "", "Show changes of this entity type: user, feed, article or filter_rule")
+	auditCmd.Flags().StringVar(&auditQuery.EntityID, "entity-id", "", "Show changes of the entity with this ID")
+	auditCmd.Flags().StringVar(&auditQuery.Principal, "actor", "", "Show changes made by this principal")
+	auditCmd.Flags().StringVar(&auditQuery.Action, "action", "", "Show changes of this action (e.g. create, delete, subscribe)")
+	auditCmd.Flags().StringVar(&auditSince, "since", "", "Show changes made since this RFC 3339 time or this long ago (e.g. 1h)")
+	auditCmd.Flags().IntVarP(&auditQuery.Limit, "limit", "n", 0, "Number of latest changes to show (0 uses the service default)")
+	auditCmd.Flags().BoolVarP(&auditFollow, "follow", "f", false, "Keep showing changes as they are made")
+	auditCmd.Flags().DurationVar(&auditInterval, "interval", 2*time.Second, "How often to check for changes when following")
+	auditCmd.Flags().BoolVarP(&auditPayloads, "payloads", "p", false, "Show the before and after payloads of changes")
+	RootCmd.AddCommand(auditCmd)
+}
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Show the audit log of changes to users, feeds, articles and filter rules",
+	Long: `Show the latest changes recorded in the audit log, oldest first, one per line: time, principal, request
+ID, action and the entity changed. With --follow, changes are shown as they are made until interrupted.`,
+	Run: runAudit,
+}
+
+func runAudit(cmd *cobra.Command, args []string) {
+	q := auditQuery
+	if auditSince != "" {
+		since, err := parseSince(auditSince)
+		if err != nil {
+			log.Fatalf("Invalid --since: %s", err)
+		}
+		q.Since = since
+	}
+
+	c := newClient()
+	records, err := c.ListAuditRecords(q)
+	if err != nil {
+		log.Fatalf("Failed to list audit records: %s", err)
+	}
+	printAuditRecords(records)
+	if !auditFollow {
+		return
+	}
+
+	// Records are polled for since the time of the last one shown, skipping those at that time already shown
+	seen := map[string]bool{}
+	for {
+		if len(records) > 0 {
+			last := records[len(records)-1].Time
+			if !last.Equal(q.Since) {
+				seen = map[string]bool{}
+			}
+			q.Since = last
+			for _, r := range records {
+				if r.Time.Equal(last) {
+					seen[r.ID] = true
+				}
+			}
+		}
+
+		time.Sleep(auditInterval)
+		q.Limit = followLimit
+		polled, err := c.ListAuditRecords(q)
+		if err != nil {
+			log.Fatalf("Failed to list audit records: %s", err)
+		}
+		records = []api.AuditRecord{}
+		for _, r := range polled {
+			if !seen[r.ID] {
+				records = append(records, r)
+			}
+		}
+		printAuditRecords(records)
+	}
+}
+
+// parseSince parses a time given either in RFC 3339 or as a duration before now
+func parseSince(value string) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("'%s' is neither an RFC 3339 time nor a duration", value)
+	}
+	return t, nil
+}
+
+func printAuditRecords(records []api.AuditRecord) {
+	for _, r := range records {
+		requestID := r.RequestID
+		if requestID == "" {
+			requestID = "-"
+		}
+		fmt.Printf("%s %s %s %s %s %s\n", r.Time.Format(time.RFC3339Nano), r.Principal, requestID, r.Action, r.EntityType, r.EntityID)
+		if !auditPayloads {
+			continue
+		}
+		if len(r.Before) > 0 {
+			fmt.Printf("  before: %s\n", r.Before)
+		}
+		if len(r.After) > 0 {
+			fmt.Printf("  after:  %s\n", r.After)
+		}
+	}
+}
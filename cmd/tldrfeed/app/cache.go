@@ -0,0 +1,41 @@
+package app
+
+import (
+	"log"
+	"os"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	addClientFlags(cacheCmd.PersistentFlags())
+	cacheCmd.AddCommand(cacheStatsCmd)
+	RootCmd.AddCommand(cacheCmd)
+}
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect the server's cache of feed and subscription queries",
+	Run:   runCache,
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show cache hits and misses",
+	Run:   runCacheStats,
+}
+
+func runCache(cmd *cobra.Command, args []string) {
+	_ = cmd.Help()
+	os.Exit(0)
+}
+
+func runCacheStats(cmd *cobra.Command, args []string) {
+	c := newClient()
+	stats, err := c.CacheStats()
+	if err != nil {
+		log.Fatalf("Failed to get cache stats: %s", err)
+	}
+	spew.Printf("%+v\n", stats)
+}
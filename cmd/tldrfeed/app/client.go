@@ -0,0 +1,38 @@
+package app
+
+import (
+	"log"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/spf13/pflag"
+)
+
+var caCert string
+var clientCert string
+var clientKey string
+var retries int
+
+// addClientFlags registers flags controlling how commands connect to the tldrfeed service
+func addClientFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&url, "url", "u", "http://localhost:8080", "tldrfeed service URL")
+	flags.StringVar(&caCert, "ca-cert", "", "CA bundle to verify the tldrfeed service certificate with")
+	flags.StringVar(&clientCert, "client-cert", "", "Client certificate file for mutual TLS")
+	flags.StringVar(&clientKey, "client-key", "", "Client private key file for mutual TLS")
+	flags.IntVar(&retries, "retries", 3, "Number of times to retry requests failing with transient errors")
+}
+
+// newClient creates an API client configured from the client flags
+func newClient() *api.Client {
+	opts := []api.ClientOption{
+		api.WithRetries(retries),
+	}
+
+	if caCert != "" || clientCert != "" || clientKey != "" {
+		tlsConfig, err := api.NewTLSConfig(caCert, clientCert, clientKey)
+		if err != nil {
+			log.Fatalf("Failed to configure TLS: %s", err)
+		}
+		opts = append(opts, api.WithTLSConfig(tlsConfig))
+	}
+	return api.NewClient(url, opts...)
+}
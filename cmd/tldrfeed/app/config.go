@@ -0,0 +1,170 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/if-ivan-else/tldrfeed/internal/service"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// EnvPrefix is prepended to configuration keys to form the names of env variables binding them
+const EnvPrefix = "TLDRFEED"
+
+// legacyDBEnv is the env variable used to configure DB URL before TLDRFEED_ prefixed variables were introduced
+const legacyDBEnv = "DB_URL"
+
+// configKeys maps server flags to configuration keys, every key can also be set
+// through a TLDRFEED_ prefixed env variable (e.g. tls.cert via TLDRFEED_TLS_CERT)
+var configKeys = map[string]string{
+	"port":        "port",
+	"indent-json": "indent_json",
+	"db":          "db",
+	"db-timeout":  "db_timeout",
+
+	"tls-cert":          "tls.cert",
+	"tls-key":           "tls.key",
+	"tls-client-ca":     "tls.client_ca",
+	"tls-client-auth":   "tls.client_auth",
+	"tls-redirect-port": "tls.redirect_port",
+
+	"rate-limit-key":             "rate_limit.key",
+	"rate-limit":                 "rate_limit.rate",
+	"rate-limit-burst":           "rate_limit.burst",
+	"rate-limit-max-clients":     "rate_limit.max_clients",
+	"feed-articles-per-hour":     "quotas.feed_articles_per_hour",
+	"idempotency-ttl":            "idempotency.ttl",
+	"idempotency-lease":          "idempotency.lease",
+	"idempotency-max-body-bytes": "idempotency.max_body_bytes",
+
+	"summary-strategy":  "summary.strategy",
+	"summary-sentences": "summary.sentences",
+
+	"publish-interval": "scheduler.interval",
+
+	"retention-interval": "retention.interval",
+
+	"timeline-fan-out":         "timelines.fan_out",
+	"timeline-max-subscribers": "timelines.max_subscribers",
+
+	"cache":           "cache.backend",
+	"cache-size":      "cache.size",
+	"cache-ttl":       "cache.ttl",
+	"cache-redis-url": "cache.redis_url",
+
+	"audit": "audit.enabled",
+
+	"log-format": "log.format",
+
+	"tracing":              "tracing.exporter",
+	"tracing-endpoint":     "tracing.endpoint",
+	"tracing-insecure":     "tracing.insecure",
+	"tracing-sample-ratio": "tracing.sample_ratio",
+
+	"grpc-port": "grpc.port",
+
+	"graphql-max-complexity": "graphql.max_complexity",
+	"graphql-max-depth":      "graphql.max_depth",
+	"graphql-max-operations": "graphql.max_operations",
+}
+
+func init() {
+	addServerFlags(configPrintCmd.PersistentFlags())
+	configCmd.AddCommand(configPrintCmd)
+	RootCmd.AddCommand(configCmd)
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect tldrfeed server configuration",
+	Run:   runConfig,
+}
+
+var configPrintCmd = &cobra.Command{
+	Use:   "print",
+	Short: "Print effective server configuration merged from flags, env and config file, secrets redacted",
+	Run:   runConfigPrint,
+}
+
+func runConfig(cmd *cobra.Command, args []string) {
+	_ = cmd.Help()
+	os.Exit(0)
+}
+
+func runConfigPrint(cmd *cobra.Command, args []string) {
+	config, err := loadConfig(cmd.Flags())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := yaml.Marshal(config.Redacted())
+	if err != nil {
+		log.Fatalf("Failed to print configuration: %s", err)
+	}
+	fmt.Print(string(out))
+}
+
+// loadConfig merges server configuration from flags, env variables and an optional config file and validates it.
+// Precedence from highest to lowest is: flags explicitly set, env variables, config file, flag defaults.
+func loadConfig(flags *pflag.FlagSet) (service.Config, error) {
+	v, err := newConfigViper(flags)
+	if err != nil {
+		return service.Config{}, err
+	}
+	return decodeConfig(v)
+}
+
+func newConfigViper(flags *pflag.FlagSet) (*viper.Viper, error) {
+	v := viper.New()
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+
+	for flag, key := range configKeys {
+		if err := v.BindPFlag(key, flags.Lookup(flag)); err != nil {
+			return nil, err
+		}
+		if err := v.BindEnv(key); err != nil {
+			return nil, err
+		}
+	}
+
+	// Keep honoring DB_URL for existing deployments unless the prefixed variable is set
+	if os.Getenv(EnvPrefix+"_DB") == "" && os.Getenv(legacyDBEnv) != "" {
+		if err := v.BindEnv("db", legacyDBEnv); err != nil {
+			return nil, err
+		}
+	}
+
+	configFile, err := flags.GetString("config")
+	if err != nil {
+		return nil, err
+	}
+	if configFile == "" {
+		configFile = os.Getenv(EnvPrefix + "_CONFIG")
+	}
+	if configFile != "" {
+		v.SetConfigFile(configFile)
+		if err := v.ReadInConfig(); err != nil {
+			return nil, errors.Wrapf(err, "Failed to read config file '%s'", configFile)
+		}
+	}
+	return v, nil
+}
+
+func decodeConfig(v *viper.Viper) (service.Config, error) {
+	var config service.Config
+	// Reject unknown keys so that typos in the config file do not go unnoticed
+	if err := v.UnmarshalExact(&config); err != nil {
+		return service.Config{}, errors.Wrap(err, "Failed to parse configuration")
+	}
+	if err := config.Validate(); err != nil {
+		return service.Config{}, err
+	}
+	return config, nil
+}
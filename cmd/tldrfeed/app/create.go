@@ -3,32 +3,46 @@ package app
 import (
 	"log"
 	"os"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
-
 	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var url string
 var name string
+var category string
 var title string
 var body string
+var bodyFormat string
 var feedID string
+var summary string
+var author string
+var articleURL string
+var imageURL string
+var tags []string
+var publishedAt string
+var updatedAt string
+var status string
+var publishAt string
 
 func init() {
 
-	createCmd.PersistentFlags().StringVarP(&url, "url", "u", "http://localhost:8080", "tldrfeed service URL")
+	addClientFlags(createCmd.PersistentFlags())
 
 	createUserCmd.PersistentFlags().StringVarP(&name, "name", "n", "", "User name")
 	createCmd.AddCommand(createUserCmd)
 
-	createFeedCmd.PersistentFlags().StringVarP(&feedID, "feed", "f", "", "Feed ID")
 	createFeedCmd.PersistentFlags().StringVarP(&name, "name", "n", "", "Feed name")
+	createFeedCmd.PersistentFlags().StringVar(&category, "category", "", "Feed category")
+	addRetentionFlags(createFeedCmd.PersistentFlags())
 	createCmd.AddCommand(createFeedCmd)
 
-	createArticleCmd.PersistentFlags().StringVarP(&title, "title", "t", "", "Article title")
-	createArticleCmd.PersistentFlags().StringVarP(&body, "body", "b", "", "Article body")
+	createArticleCmd.PersistentFlags().StringVarP(&feedID, "feed", "f", "", "Feed ID")
+	addArticleFlags(createArticleCmd.PersistentFlags())
 	createCmd.AddCommand(createArticleCmd)
 
 	RootCmd.AddCommand(createCmd)
@@ -64,7 +78,7 @@ func runCreate(cmd *cobra.Command, args []string) {
 }
 
 func runCreateUser(cmd *cobra.Command, args []string) {
-	c := api.NewClient(url)
+	c := newClient()
 	u, err := c.CreateUser(name)
 	if err != nil {
 		log.Fatalf("Failed to create User: %s", err.Error())
@@ -73,19 +87,77 @@ func runCreateUser(cmd *cobra.Command, args []string) {
 }
 
 func runCreateFeed(cmd *cobra.Command, args []string) {
-	c := api.NewClient(url)
-	f, err := c.CreateFeed(name)
+	c := newClient()
+	f, err := c.CreateFeedWithOptions(&api.CreateFeedRequest{
+		Name:      name,
+		Category:  category,
+		Retention: retentionPolicy(),
+	})
 	if err != nil {
 		log.Fatalf("Failed to create Feed: %s", err.Error())
 	}
 	spew.Printf("Feed created: %v", f)
 }
 
+// addArticleFlags registers the flags describing an Article
+func addArticleFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&title, "title", "t", "", "Article title")
+	flags.StringVarP(&body, "body", "b", "", "Article body")
+	flags.StringVar(&bodyFormat, "body-format", "", "Article body format: text (default), markdown or html")
+	flags.StringVar(&summary, "summary", "", "Article summary (extracted from the body when not set)")
+	flags.StringVar(&author, "author", "", "Article author")
+	flags.StringVar(&articleURL, "link", "", "URL of the original article")
+	flags.StringVar(&imageURL, "image-url", "", "Article image URL")
+	flags.StringSliceVar(&tags, "tag", nil, "Article tag (repeatable)")
+	flags.StringVar(&publishedAt, "published-at", "", "RFC 3339 publication time for backfilling older articles")
+	flags.StringVar(&updatedAt, "updated-at", "", "RFC 3339 time the article was last updated")
+	flags.StringVar(&status, "status", "", "Article status: published, draft or scheduled (default published, or scheduled with --publish-at)")
+	flags.StringVar(&publishAt, "publish-at", "", "RFC 3339 time to publish a scheduled article at")
+}
+
+// articleRequest builds an Article request from the article flags
+func articleRequest() *api.CreateArticleRequest {
+	article := &api.CreateArticleRequest{
+		Title:      title,
+		Body:       body,
+		BodyFormat: bodyFormat,
+		Summary:    summary,
+		Author:     author,
+		URL:        articleURL,
+		ImageURL:   imageURL,
+		Tags:       tags,
+		Status:     status,
+	}
+	var err error
+	if article.PublishedTime, err = parseTimeFlag("published-at", publishedAt); err != nil {
+		log.Fatal(err)
+	}
+	if article.UpdatedTime, err = parseTimeFlag("updated-at", updatedAt); err != nil {
+		log.Fatal(err)
+	}
+	if article.PublishAt, err = parseTimeFlag("publish-at", publishAt); err != nil {
+		log.Fatal(err)
+	}
+	return article
+}
+
 func runCreateArticle(cmd *cobra.Command, args []string) {
-	c := api.NewClient(url)
-	f, err := c.CreateArticle(feedID, title, body)
+	c := newClient()
+	a, err := c.PublishArticle(feedID, articleRequest())
 	if err != nil {
-		log.Fatalf("Failed to create Feed: %s", err.Error())
+		log.Fatalf("Failed to create Article: %s", err.Error())
 	}
-	spew.Printf("Feed created: %v", f)
+	spew.Printf("Article created: %v", a)
+}
+
+// parseTimeFlag parses an optional RFC 3339 time flag
+func parseTimeFlag(name string, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid --%s", name)
+	}
+	return &t, nil
 }
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+	"github.com/if-ivan-else/tldrfeed/internal/service"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// dbConfigKeys maps the flags of commands accessing the DB directly to the server configuration keys they share, so
+// that such commands store data the way the server configured with the same flags, env variables or file would
+var dbConfigKeys = map[string]string{
+	"db":                       "db",
+	"timeline-fan-out":         "timelines.fan_out",
+	"timeline-max-subscribers": "timelines.max_subscribers",
+}
+
+// addDBFlags registers the flags of commands accessing the DB directly rather than through the service
+func addDBFlags(flags *pflag.FlagSet) {
+	flags.StringP("config", "c", "", "Server config file (YAML, TOML or JSON) to read the DB and timelines settings from")
+	flags.StringP("db", "d", "0.0.0.0:27017/db", "DB connection URL, postgres:// and sqlite:// URLs select a SQL DB")
+	flags.Bool("timeline-fan-out", false, "Copy articles to subscribers' timelines as they are added, as the server does")
+	flags.Int("timeline-max-subscribers", 1000, "Subscribers above which a feed's articles are read when listing timelines instead")
+}
+
+// openRepository connects to the DB configured with the DB flags
+func openRepository(flags *pflag.FlagSet) db.Repository {
+	config, err := loadDBConfig(flags)
+	if err != nil {
+		log.Fatal(err)
+	}
+	r, err := service.OpenRepository(config)
+	if err != nil {
+		log.Fatalf("Failed to open DB: %s", err)
+	}
+	return r
+}
+
+// loadDBConfig merges the DB settings from flags, env variables and an optional config file with the same precedence
+// as the server configuration. Other settings of the config file are ignored.
+func loadDBConfig(flags *pflag.FlagSet) (service.Config, error) {
+	v := viper.New()
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+
+	for flag, key := range dbConfigKeys {
+		if err := v.BindPFlag(key, flags.Lookup(flag)); err != nil {
+			return service.Config{}, err
+		}
+		if err := v.BindEnv(key); err != nil {
+			return service.Config{}, err
+		}
+	}
+	if os.Getenv(EnvPrefix+"_DB") == "" && os.Getenv(legacyDBEnv) != "" {
+		if err := v.BindEnv("db", legacyDBEnv); err != nil {
+			return service.Config{}, err
+		}
+	}
+
+	configFile, err := flags.GetString("config")
+	if err != nil {
+		return service.Config{}, err
+	}
+	if configFile == "" {
+		configFile = os.Getenv(EnvPrefix + "_CONFIG")
+	}
+	if configFile != "" {
+		v.SetConfigFile(configFile)
+		if err := v.ReadInConfig(); err != nil {
+			return service.Config{}, errors.Wrapf(err, "Failed to read config file '%s'", configFile)
+		}
+	}
+
+	config := service.Config{DB: v.GetString("db")}
+	config.Timelines.FanOut = v.GetBool("timelines.fan_out")
+	config.Timelines.MaxSubscribers = v.GetInt("timelines.max_subscribers")
+	return config, nil
+}
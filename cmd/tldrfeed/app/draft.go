@@ -0,0 +1,114 @@
+package app
+
+import (
+	"log"
+	"os"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/spf13/cobra"
+)
+
+var articleID string
+
+func init() {
+	addClientFlags(draftCmd.PersistentFlags())
+	draftCmd.PersistentFlags().StringVarP(&feedID, "feed", "f", "", "Feed ID")
+
+	for _, cmd := range []*cobra.Command{draftUpdateCmd, draftPublishCmd, draftDeleteCmd} {
+		cmd.Flags().StringVar(&articleID, "id", "", "Draft article ID")
+	}
+	addArticleFlags(draftUpdateCmd.Flags())
+
+	draftCmd.AddCommand(draftListCmd, draftUpdateCmd, draftPublishCmd, draftDeleteCmd)
+	RootCmd.AddCommand(draftCmd)
+}
+
+var draftCmd = &cobra.Command{
+	Use:   "draft",
+	Short: "Manage draft and scheduled articles of a feed",
+	Long: `Manage draft and scheduled articles of a feed. Drafts are added with "create article --status draft"
+and stay hidden until published, scheduled articles are published at their --publish-at time.`,
+	Run: runDraft,
+}
+
+var draftListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List draft and scheduled articles",
+	Run:   runDraftList,
+}
+
+var draftUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Replace a draft, publishing or scheduling it with --status or --publish-at",
+	Run:   runDraftUpdate,
+}
+
+var draftPublishCmd = &cobra.Command{
+	Use:   "publish",
+	Short: "Publish a draft or scheduled article now",
+	Run:   runDraftPublish,
+}
+
+var draftDeleteCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Discard a draft or scheduled article",
+	Run:   runDraftDelete,
+}
+
+func runDraft(cmd *cobra.Command, args []string) {
+	_ = cmd.Help()
+	os.Exit(0)
+}
+
+func runDraftList(cmd *cobra.Command, args []string) {
+	c := newClient()
+	drafts, err := c.ListDrafts(feedID)
+	if err != nil {
+		log.Fatalf("Failed to list drafts: %s", err)
+	}
+	log.Printf("Drafts in feed %s:", feedID)
+	for _, a := range drafts {
+		spew.Printf("%+v\n", a)
+	}
+}
+
+func runDraftUpdate(cmd *cobra.Command, args []string) {
+	c := newClient()
+	a, err := c.UpdateDraft(feedID, articleID, articleRequest())
+	if err != nil {
+		log.Fatalf("Failed to update draft: %s", err)
+	}
+	spew.Printf("Draft updated: %v", a)
+}
+
+func runDraftPublish(cmd *cobra.Command, args []string) {
+	c := newClient()
+	draft, err := c.GetDraft(feedID, articleID)
+	if err != nil {
+		log.Fatalf("Failed to get draft: %s", err)
+	}
+	a, err := c.UpdateDraft(feedID, articleID, &api.CreateArticleRequest{
+		Title:      draft.Title,
+		Body:       draft.Body,
+		BodyFormat: draft.BodyFormat,
+		Summary:    draft.Summary,
+		Author:     draft.Author,
+		URL:        draft.URL,
+		ImageURL:   draft.ImageURL,
+		Tags:       draft.Tags,
+		Status:     api.StatusPublished,
+	})
+	if err != nil {
+		log.Fatalf("Failed to publish draft: %s", err)
+	}
+	spew.Printf("Draft published: %v", a)
+}
+
+func runDraftDelete(cmd *cobra.Command, args []string) {
+	c := newClient()
+	if err := c.DeleteDraft(feedID, articleID); err != nil {
+		log.Fatalf("Failed to delete draft: %s", err)
+	}
+	log.Printf("Draft %s deleted", articleID)
+}
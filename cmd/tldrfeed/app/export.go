@@ -0,0 +1,68 @@
+package app
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/if-ivan-else/tldrfeed/internal/archive"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	addDBFlags(exportCmd.Flags())
+	exportCmd.Flags().StringVar(&file, "file", "", "Archive file to write, gzipped when ending with .gz (- writes stdout)")
+	RootCmd.AddCommand(exportCmd)
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export all users, feeds, subscriptions, articles and filter rules to an archive",
+	Long: `Export the full dataset from the DB to a tar archive of NDJSON files, which can be restored into any DB
+with 'tldrfeed import archive'. The server can keep running, changes made meanwhile may be left out.`,
+	Run: runExport,
+}
+
+func runExport(cmd *cobra.Command, args []string) {
+	if file == "" {
+		log.Fatal("An archive --file is required")
+	}
+	var out io.WriteCloser = os.Stdout
+	if file != "-" {
+		f, err := os.Create(file)
+		if err != nil {
+			log.Fatalf("Failed to create archive file: %s", err)
+		}
+		out = f
+	}
+	w := out
+	if strings.HasSuffix(file, ".gz") {
+		w = gzip.NewWriter(out)
+	}
+
+	r := openRepository(cmd.Flags())
+	defer r.Close()
+	manifest, err := archive.Export(context.Background(), r, w, logProgress("Exported"))
+	if err != nil {
+		log.Fatalf("Failed to export: %s", err)
+	}
+	if w != out {
+		if err := w.Close(); err != nil {
+			log.Fatalf("Failed to write archive: %s", err)
+		}
+	}
+	if err := out.Close(); err != nil {
+		log.Fatalf("Failed to write archive: %s", err)
+	}
+	log.Printf("Exported %v records (format version %d)", manifest.Records, manifest.FormatVersion)
+}
+
+// logProgress reports the progress of an export or import in the log
+func logProgress(verb string) archive.Progress {
+	return func(file string, records int) {
+		log.Printf("%s %d records of %s", verb, records, file)
+	}
+}
@@ -0,0 +1,137 @@
+package app
+
+import (
+	"log"
+	"os"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/spf13/cobra"
+)
+
+var ruleID string
+var ruleType string
+var ruleValue string
+var ruleAction string
+
+func init() {
+	addClientFlags(filterCmd.PersistentFlags())
+	filterCmd.PersistentFlags().StringVar(&userID, "user", "", "User ID")
+
+	for _, cmd := range []*cobra.Command{filterAddCmd, filterUpdateCmd, filterPreviewCmd} {
+		cmd.Flags().StringVar(&ruleType, "type", "", "Rule type: keyword, regex, tag, feed or author")
+		cmd.Flags().StringVar(&ruleValue, "value", "", "Keyword, regex, tag, feed ID or author to match")
+		cmd.Flags().StringVar(&ruleAction, "action", api.FilterExclude, "Rule action: exclude or include")
+	}
+	for _, cmd := range []*cobra.Command{filterUpdateCmd, filterDeleteCmd} {
+		cmd.Flags().StringVar(&ruleID, "id", "", "Filter rule ID")
+	}
+	filterPreviewCmd.Flags().IntVar(&limit, "limit", 0, "Number of recent articles to try the rule on (0 uses the service default)")
+
+	filterCmd.AddCommand(filterListCmd, filterAddCmd, filterUpdateCmd, filterDeleteCmd, filterPreviewCmd)
+	RootCmd.AddCommand(filterCmd)
+}
+
+var filterCmd = &cobra.Command{
+	Use:   "filter",
+	Short: "Manage rules hiding articles from a user's timeline",
+	Long: `Manage rules hiding articles from a user's timeline. Articles matching an exclude rule are hidden
+unless they match an include rule.`,
+	Run: runFilter,
+}
+
+var filterListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List filter rules",
+	Run:   runFilterList,
+}
+
+var filterAddCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Add a filter rule",
+	Run:   runFilterAdd,
+}
+
+var filterUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Replace a filter rule",
+	Run:   runFilterUpdate,
+}
+
+var filterDeleteCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Delete a filter rule",
+	Run:   runFilterDelete,
+}
+
+var filterPreviewCmd = &cobra.Command{
+	Use:   "preview",
+	Short: "Show which recent articles a filter rule would hide without adding it",
+	Run:   runFilterPreview,
+}
+
+func runFilter(cmd *cobra.Command, args []string) {
+	_ = cmd.Help()
+	os.Exit(0)
+}
+
+func filterRuleRequest() *api.FilterRuleRequest {
+	return &api.FilterRuleRequest{
+		Type:   ruleType,
+		Value:  ruleValue,
+		Action: ruleAction,
+	}
+}
+
+func runFilterList(cmd *cobra.Command, args []string) {
+	c := newClient()
+	filterRules, err := c.ListFilterRules(userID)
+	if err != nil {
+		log.Fatalf("Failed to list filter rules: %s", err)
+	}
+	log.Printf("Filter rules of user %s:", userID)
+	for _, r := range filterRules {
+		spew.Printf("%+v\n", r)
+	}
+}
+
+func runFilterAdd(cmd *cobra.Command, args []string) {
+	c := newClient()
+	r, err := c.CreateFilterRule(userID, filterRuleRequest())
+	if err != nil {
+		log.Fatalf("Failed to add filter rule: %s", err)
+	}
+	spew.Printf("Filter rule added: %v", r)
+}
+
+func runFilterUpdate(cmd *cobra.Command, args []string) {
+	c := newClient()
+	r, err := c.UpdateFilterRule(userID, ruleID, filterRuleRequest())
+	if err != nil {
+		log.Fatalf("Failed to update filter rule: %s", err)
+	}
+	spew.Printf("Filter rule updated: %v", r)
+}
+
+func runFilterDelete(cmd *cobra.Command, args []string) {
+	c := newClient()
+	if err := c.DeleteFilterRule(userID, ruleID); err != nil {
+		log.Fatalf("Failed to delete filter rule: %s", err)
+	}
+	log.Printf("Filter rule %s deleted", ruleID)
+}
+
+func runFilterPreview(cmd *cobra.Command, args []string) {
+	c := newClient()
+	p, err := c.PreviewFilterRule(userID, filterRuleRequest(), limit)
+	if err != nil {
+		log.Fatalf("Failed to preview filter rule: %s", err)
+	}
+	log.Printf("Of %d recent articles the rule would hide %d and reveal %d:", p.Checked, len(p.Hidden), len(p.Revealed))
+	for _, a := range p.Hidden {
+		spew.Printf("hidden: %+v\n", a)
+	}
+	for _, a := range p.Revealed {
+		spew.Printf("revealed: %+v\n", a)
+	}
+}
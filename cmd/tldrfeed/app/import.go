@@ -0,0 +1,168 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/archive"
+	"github.com/if-ivan-else/tldrfeed/internal/db/audit"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var file string
+var batchSize int
+var onConflict string
+var auditImport bool
+
+// importPrincipal is who restores made by an import are recorded in the audit log as made by
+const importPrincipal = "import"
+
+func init() {
+	addClientFlags(importCmd.PersistentFlags())
+
+	importArticlesCmd.Flags().StringVarP(&feedID, "feed", "f", "", "Feed ID")
+	importArticlesCmd.Flags().StringVar(&file, "file", "", "JSON array or NDJSON file of articles to import (- reads stdin)")
+	importArticlesCmd.Flags().IntVar(&batchSize, "batch-size", 500, "Number of articles sent per request (at most 1000)")
+	addDBFlags(importArchiveCmd.Flags())
+	importArchiveCmd.Flags().StringVar(&file, "file", "", "Archive file written by 'tldrfeed export', plain or gzipped (- reads stdin)")
+	importArchiveCmd.Flags().StringVar(&onConflict, "on-conflict", archive.ConflictFail,
+		"What to do with records whose ID exists: fail, skip or replace")
+	importArchiveCmd.Flags().BoolVar(&auditImport, "audit", true, "Record the restored records in the audit log")
+	importCmd.AddCommand(importArticlesCmd, importArchiveCmd)
+
+	RootCmd.AddCommand(importCmd)
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import data into tldrfeed in bulk",
+	Run:   runImport,
+}
+
+var importArticlesCmd = &cobra.Command{
+	Use:   "articles",
+	Short: "Import articles into a feed",
+	Long: `Import articles into a feed from a JSON array or NDJSON file of article creation requests, e.g.
+{"title": "...", "body": "...", "published_at": "2018-03-01T12:00:00Z"}. Articles failing validation are
+reported and skipped, the rest are imported.`,
+	Run: runImportArticles,
+}
+
+var importArchiveCmd = &cobra.Command{
+	Use:   "archive",
+	Short: "Restore an archive written by 'tldrfeed export' into the DB",
+	Long: `Restore users, feeds, subscriptions, articles and filter rules from an archive into the DB directly,
+keeping their IDs, e.g. to restore a backup or migrate to another DB.`,
+	Run: runImportArchive,
+}
+
+func runImport(cmd *cobra.Command, args []string) {
+	_ = cmd.Help()
+	os.Exit(0)
+}
+
+func runImportArticles(cmd *cobra.Command, args []string) {
+	in := os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			log.Fatalf("Failed to open articles file: %s", err)
+		}
+		defer f.Close()
+		in = f
+	}
+	articles, err := readArticles(in)
+	if err != nil {
+		log.Fatalf("Failed to read articles: %s", err)
+	}
+	if batchSize < 1 {
+		log.Fatalf("Invalid --batch-size %d, expected a positive number", batchSize)
+	}
+
+	c := newClient()
+	created, failed := 0, 0
+	for start := 0; start < len(articles); start += batchSize {
+		end := start + batchSize
+		if end > len(articles) {
+			end = len(articles)
+		}
+		r, err := c.CreateArticles(feedID, articles[start:end])
+		if err != nil {
+			log.Fatalf("Failed to import articles %d to %d: %s (%d imported so far)", start, end-1, err, created)
+		}
+		for _, result := range r.Results {
+			if result.Error != "" {
+				log.Printf("Article %d not imported: %d %s", start+result.Index, result.Status, result.Error)
+			}
+		}
+		created += r.Created
+		failed += r.Failed
+	}
+	log.Printf("Imported %d articles into feed %s, %d failed", created, feedID, failed)
+}
+
+func runImportArchive(cmd *cobra.Command, args []string) {
+	in := os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			log.Fatalf("Failed to open archive file: %s", err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	r := openRepository(cmd.Flags())
+	defer r.Close()
+	ctx := context.Background()
+	if auditImport {
+		r = audit.NewRepository(r)
+		ctx = audit.NewContext(ctx, audit.Actor{Principal: importPrincipal})
+	}
+	result, err := archive.Import(ctx, r, in, onConflict, logProgress("Imported"))
+	if err != nil {
+		if result != nil {
+			log.Printf("Imported %v records, skipped %v before failing", result.Imported, result.Skipped)
+		}
+		log.Fatalf("Failed to import archive: %s", err)
+	}
+	log.Printf("Imported %v records, skipped %v existing records (archive of %s, tldrfeed %s)",
+		result.Imported, result.Skipped, result.Manifest.CreatedTime.Format(time.RFC3339), result.Manifest.Version)
+}
+
+// readArticles reads article creation requests from a JSON array or NDJSON
+func readArticles(r io.Reader) ([]api.CreateArticleRequest, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	articles := []api.CreateArticleRequest{}
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("[")) {
+		if err := json.Unmarshal(data, &articles); err != nil {
+			return nil, err
+		}
+		return articles, nil
+	}
+
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var a api.CreateArticleRequest
+		if err := json.Unmarshal(line, &a); err != nil {
+			return nil, errors.Wrapf(err, "Line %d", i+1)
+		}
+		articles = append(articles, a)
+	}
+	return articles, nil
+}
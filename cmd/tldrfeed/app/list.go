@@ -10,22 +10,39 @@ import (
 )
 
 var userID string
+var tldr bool
+var tag string
+var collapseDuplicates bool
 
 func init() {
-	listCmd.PersistentFlags().StringVarP(&url, "url", "u", "http://localhost:8080", "tldrfeed service URL")
+	addClientFlags(listCmd.PersistentFlags())
 
 	listCmd.AddCommand(listUsersCmd)
+	listFeedsCmd.PersistentFlags().StringVar(&category, "category", "", "List only feeds in this category")
 	listCmd.AddCommand(listFeedsCmd)
 
 	listArticlesCmd.PersistentFlags().StringVarP(&feedID, "feed", "f", "", "Feed ID")
 	listArticlesCmd.PersistentFlags().StringVar(&userID, "user", "", "User ID")
+	listArticlesCmd.PersistentFlags().BoolVar(&tldr, "tldr", false, "Show article summaries instead of bodies")
+	listArticlesCmd.PersistentFlags().StringVar(&tag, "tag", "", "List only articles with this tag")
+	listArticlesCmd.PersistentFlags().BoolVar(&collapseDuplicates, "collapse-duplicates", false,
+		"Show one article per story in a user's timeline, listing duplicates from other feeds")
+	listArticlesCmd.PersistentFlags().StringVar(&bodyFormat, "body-format", "",
+		"Show article bodies as original (default), text or html")
 	listCmd.AddCommand(listArticlesCmd)
+
+	listCmd.AddCommand(listTagsCmd)
+
+	listDiscoverCmd.PersistentFlags().StringVar(&userID, "user", "", "User ID")
+	listDiscoverCmd.PersistentFlags().StringVar(&category, "category", "", "Suggest only feeds in this category")
+	listDiscoverCmd.PersistentFlags().IntVar(&limit, "limit", 0, "Number of feeds to suggest (0 uses the service default)")
+	listCmd.AddCommand(listDiscoverCmd)
 	RootCmd.AddCommand(listCmd)
 }
 
 var listCmd = &cobra.Command{
 	Use:   "list",
-	Short: "List users, feeds or articles in tldrfeed",
+	Short: "List users, feeds, articles or tags in tldrfeed",
 	Run:   runList,
 }
 
@@ -47,13 +64,25 @@ var listArticlesCmd = &cobra.Command{
 	Run:   runListArticles,
 }
 
+var listTagsCmd = &cobra.Command{
+	Use:   "tags",
+	Short: "List article tags with their article counts",
+	Run:   runListTags,
+}
+
+var listDiscoverCmd = &cobra.Command{
+	Use:   "discover",
+	Short: "Suggest feeds a user is not following yet",
+	Run:   runListDiscover,
+}
+
 func runList(cmd *cobra.Command, args []string) {
 	cmd.Help()
 	os.Exit(0)
 }
 
 func runListUsers(cmd *cobra.Command, args []string) {
-	c := api.NewClient(url)
+	c := newClient()
 	users, err := c.ListUsers()
 	if err != nil {
 		log.Fatalf("Failed to list Users: %s", err)
@@ -65,8 +94,8 @@ func runListUsers(cmd *cobra.Command, args []string) {
 }
 
 func runListFeeds(cmd *cobra.Command, args []string) {
-	c := api.NewClient(url)
-	feeds, err := c.ListFeeds()
+	c := newClient()
+	feeds, err := c.ListFeedsInCategory(category)
 	if err != nil {
 		log.Fatalf("Failed to list Feeds: %s", err)
 	}
@@ -77,19 +106,23 @@ func runListFeeds(cmd *cobra.Command, args []string) {
 }
 
 func runListArticles(cmd *cobra.Command, args []string) {
-	c := api.NewClient(url)
+	c := newClient()
+	opts := api.ArticleListOptions{Tag: tag, CollapseDuplicates: collapseDuplicates, BodyFormat: bodyFormat}
+	if tldr {
+		opts.View = api.ViewTLDR
+	}
 	articles := []api.Article{}
 	var err error
 	if userID == "" {
 		log.Printf("Articles in feed %s:", feedID)
-		articles, err = c.ListArticles(feedID)
+		articles, err = c.ListArticlesWithOptions(feedID, opts)
 	} else {
 		if feedID == "" {
 			log.Printf("Articles for user %s in all Feeds", userID)
 		} else {
 			log.Printf("Articles for user %s in Feed %s):", userID, feedID)
 		}
-		articles, err = c.ListUserArticles(userID, feedID)
+		articles, err = c.ListUserArticlesWithOptions(userID, feedID, opts)
 	}
 	if err != nil {
 		log.Fatalf("Failed to list Articles: %s", err)
@@ -98,3 +131,27 @@ func runListArticles(cmd *cobra.Command, args []string) {
 		spew.Printf("%+v\n", a)
 	}
 }
+
+func runListTags(cmd *cobra.Command, args []string) {
+	c := newClient()
+	tags, err := c.ListTags()
+	if err != nil {
+		log.Fatalf("Failed to list tags: %s", err)
+	}
+	log.Print("Tags:\n")
+	for _, t := range tags {
+		spew.Printf("%+v\n", t)
+	}
+}
+
+func runListDiscover(cmd *cobra.Command, args []string) {
+	c := newClient()
+	suggestions, err := c.DiscoverFeeds(userID, category, limit)
+	if err != nil {
+		log.Fatalf("Failed to discover Feeds: %s", err)
+	}
+	log.Printf("Suggested Feeds for user %s:", userID)
+	for _, f := range suggestions {
+		spew.Printf("%+v\n", f)
+	}
+}
@@ -0,0 +1,87 @@
+package app
+
+import (
+	"log"
+	"os"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var maxAgeDays int
+var maxCount int
+
+func init() {
+	addClientFlags(retentionCmd.PersistentFlags())
+	retentionCmd.PersistentFlags().StringVarP(&feedID, "feed", "f", "", "Feed ID")
+
+	addRetentionFlags(retentionSetCmd.Flags())
+	addRetentionFlags(retentionReportCmd.Flags())
+
+	retentionCmd.AddCommand(retentionSetCmd, retentionReportCmd)
+	RootCmd.AddCommand(retentionCmd)
+}
+
+var retentionCmd = &cobra.Command{
+	Use:   "retention",
+	Short: "Manage how long the articles of a feed are kept",
+	Long: `Manage how long the articles of a feed are kept. Published articles older than --max-age-days or
+beyond the --max-count most recent ones are removed, unless starred by a user.`,
+	Run: runRetention,
+}
+
+var retentionSetCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Replace the retention policy of a feed (no limits keeps articles forever)",
+	Run:   runRetentionSet,
+}
+
+var retentionReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Show which articles the retention policy, or one given with flags, would remove now",
+	Run:   runRetentionReport,
+}
+
+// addRetentionFlags registers the flags describing a retention policy
+func addRetentionFlags(flags *pflag.FlagSet) {
+	flags.IntVar(&maxAgeDays, "max-age-days", 0, "Remove articles published more than this many days ago (0 keeps them)")
+	flags.IntVar(&maxCount, "max-count", 0, "Keep only this many most recent articles (0 keeps all)")
+}
+
+// retentionPolicy builds a retention policy from the retention flags, nil when no limit is set
+func retentionPolicy() *api.RetentionPolicy {
+	policy := &api.RetentionPolicy{MaxAgeDays: maxAgeDays, MaxCount: maxCount}
+	if policy.Empty() {
+		return nil
+	}
+	return policy
+}
+
+func runRetention(cmd *cobra.Command, args []string) {
+	_ = cmd.Help()
+	os.Exit(0)
+}
+
+func runRetentionSet(cmd *cobra.Command, args []string) {
+	c := newClient()
+	f, err := c.SetFeedRetention(feedID, &api.RetentionPolicy{MaxAgeDays: maxAgeDays, MaxCount: maxCount})
+	if err != nil {
+		log.Fatalf("Failed to set retention policy: %s", err)
+	}
+	spew.Printf("Retention policy set: %v", f)
+}
+
+func runRetentionReport(cmd *cobra.Command, args []string) {
+	c := newClient()
+	report, err := c.RetentionReport(feedID, retentionPolicy())
+	if err != nil {
+		log.Fatalf("Failed to get retention report: %s", err)
+	}
+	log.Printf("Feed %s would have %d articles removed (%d kept as starred) under %+v:",
+		feedID, len(report.Expired), len(report.Starred), report.Policy)
+	for _, a := range report.Expired {
+		spew.Printf("%+v\n", a)
+	}
+}
@@ -0,0 +1,42 @@
+package app
+
+import (
+	"log"
+	"strings"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/spf13/cobra"
+)
+
+var offset int
+var limit int
+
+func init() {
+	addClientFlags(searchCmd.PersistentFlags())
+	searchCmd.PersistentFlags().StringVar(&userID, "user", "", "Search only Feeds followed by this User ID")
+	searchCmd.PersistentFlags().IntVar(&offset, "offset", 0, "Number of matches to skip")
+	searchCmd.PersistentFlags().IntVar(&limit, "limit", 0, "Number of matches to show (0 uses the service default)")
+	RootCmd.AddCommand(searchCmd)
+}
+
+var searchCmd = &cobra.Command{
+	Use:   "search QUERY",
+	Short: "Search articles",
+	Long: `Search articles by their content. Queries consist of terms (tolstoy), quoted phrases ("war and peace")
+and terms or phrases restricted to a field (title:tolstoy, body:"war and peace").`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  runSearch,
+}
+
+func runSearch(cmd *cobra.Command, args []string) {
+	c := newClient()
+	query := strings.Join(args, " ")
+	results, err := c.Search(userID, query, offset, limit)
+	if err != nil {
+		log.Fatalf("Failed to search Articles: %s", err)
+	}
+	log.Printf("Showing %d of %d Articles matching '%s':", len(results.Hits), results.Total, query)
+	for _, h := range results.Hits {
+		spew.Printf("%+v\n", h)
+	}
+}
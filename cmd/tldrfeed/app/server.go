@@ -2,26 +2,19 @@ package app
 
 import (
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/if-ivan-else/tldrfeed/internal/service"
+	"github.com/if-ivan-else/tldrfeed/internal/summarize"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
+	"github.com/spf13/pflag"
 )
 
-var config service.Config
-
 func init() {
-	serverCmd.PersistentFlags().IntVarP(&config.Port, "port", "p", 8080, "Port to bind to")
-	serverCmd.PersistentFlags().BoolVarP(&config.IndentJSON, "indent-json", "i", false, "Indent JSON nicely in rendered API responses")
-	serverCmd.PersistentFlags().StringVarP(&config.DB, "db", "d", "0.0.0.0:27017/db", "DB connection URL")
-	if err := viper.BindPFlag("db", serverCmd.PersistentFlags().Lookup("db")); err != nil {
-		log.Fatal(err)
-	}
-
-	if err := viper.BindEnv("db", "DB_URL"); err != nil {
-		log.Fatal(err)
-	}
-
+	addServerFlags(serverCmd.PersistentFlags())
 	RootCmd.AddCommand(serverCmd)
 }
 
@@ -31,8 +24,71 @@ var serverCmd = &cobra.Command{
 	Run:   runServer,
 }
 
+// addServerFlags registers all server configuration flags on a flag set
+func addServerFlags(flags *pflag.FlagSet) {
+	flags.StringP("config", "c", "", "Config file (YAML, TOML or JSON)")
+	flags.IntP("port", "p", 8080, "Port to bind to")
+	flags.BoolP("indent-json", "i", false, "Indent JSON nicely in rendered API responses")
+	flags.StringP("db", "d", "0.0.0.0:27017/db", "DB connection URL, postgres:// and sqlite:// URLs select a SQL DB")
+	flags.Duration("db-timeout", 30*time.Second, "Time a request can spend in the DB before failing with 504 (0 disables)")
+	flags.String("tls-cert", "", "TLS certificate file, enables HTTPS (reloaded on change)")
+	flags.String("tls-key", "", "TLS private key file")
+	flags.String("tls-client-ca", "", "CA bundle to verify client certificates with")
+	flags.String("tls-client-auth", service.ClientAuthNone, "Client certificate auth mode: none, request or require")
+	flags.Int("tls-redirect-port", 0, "Port to redirect plain HTTP requests to HTTPS from (0 disables)")
+	flags.String("rate-limit-key", service.RateLimitKeyIP, "What clients are rate limited by: ip, user or api_key")
+	flags.Float64("rate-limit", 0, "Requests per second a client can sustain on each route (0 disables)")
+	flags.Int("rate-limit-burst", 0, "Requests a client can make at once on each route")
+	flags.Int("rate-limit-max-clients", 100000, "Clients whose rate limits are tracked at once, least recently seen forgotten first (0 disables)")
+	flags.Int("feed-articles-per-hour", 0, "Articles that can be published to a feed per hour (0 disables)")
+	flags.Duration("idempotency-ttl", 24*time.Hour, "How long responses to requests with an Idempotency-Key are kept for retries")
+	flags.Duration("idempotency-lease", time.Minute, "How long an Idempotency-Key stays reserved for a request being processed")
+	flags.Int64("idempotency-max-body-bytes", 32<<20, "Largest body of a request with an Idempotency-Key")
+	flags.String("summary-strategy", summarize.StrategyTextRank, "How article summaries are computed: textrank or lead")
+	flags.Int("summary-sentences", 3, "Maximum number of sentences in article summaries")
+	flags.Duration("publish-interval", 30*time.Second, "How often scheduled articles falling due are published")
+	flags.Duration("retention-interval", time.Hour, "How often articles expired by their feed's retention policy are removed")
+	flags.Bool("timeline-fan-out", false, "Copy articles to subscribers' timelines as they are added (rebuilds timelines when first enabled)")
+	flags.Int("timeline-max-subscribers", 1000, "Subscribers above which a feed's articles are read when listing timelines instead")
+	flags.String("cache", service.CacheBackendNone, "Where feed and subscription queries are cached: none, lru or redis")
+	flags.Int("cache-size", 10000, "Query results held by the lru cache")
+	flags.Duration("cache-ttl", time.Minute, "How long query results are cached")
+	flags.String("cache-redis-url", "", "Redis server of the redis cache (e.g. redis://localhost:6379/0)")
+	flags.Bool("audit", true, "Record changes to users, feeds, articles and filter rules in the audit log")
+	flags.String("log-format", service.LogFormatJSON, "Format of the server's log: text or json")
+	flags.String("tracing", service.TracingExporterNone, "Where spans of requests and DB calls are exported: none, stdout or otlp")
+	flags.String("tracing-endpoint", "localhost:4318", "OpenTelemetry collector the otlp exporter sends spans to (host:port)")
+	flags.Bool("tracing-insecure", false, "Send spans to the collector over plain HTTP")
+	flags.Float64("tracing-sample-ratio", 1, "Fraction of traces sampled (requests continuing a trace follow the caller)")
+	flags.Int("grpc-port", 0, "Port the gRPC API is served on (0 disables)")
+	flags.Int("graphql-max-complexity", 5000, "Estimated number of fields a GraphQL query may resolve (0 disables)")
+	flags.Int("graphql-max-depth", 10, "How deeply the fields of a GraphQL query may be nested (0 disables)")
+	flags.Int("graphql-max-operations", 25, "GraphQL operations a websocket connection may run at once (0 disables)")
+}
+
 func runServer(cmd *cobra.Command, args []string) {
+	config, err := loadConfig(cmd.Flags())
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Start the tldr service
-	config.DB = viper.GetString("db")
-	service.Run(config)
+	s := service.NewServer(config)
+	go reloadOnHangup(cmd.Flags(), s)
+	s.Run()
+}
+
+// reloadOnHangup re-reads configuration on every SIGHUP and applies it to the running server
+func reloadOnHangup(flags *pflag.FlagSet, s *service.Server) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		log.Print("Received SIGHUP, reloading configuration")
+		config, err := loadConfig(flags)
+		if err != nil {
+			log.Printf("Failed to reload configuration, keeping current settings: %s", err)
+			continue
+		}
+		s.Reload(config)
+	}
 }
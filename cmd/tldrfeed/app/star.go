@@ -0,0 +1,78 @@
+package app
+
+import (
+	"log"
+	"os"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	addClientFlags(starCmd.PersistentFlags())
+	starCmd.PersistentFlags().StringVar(&userID, "user", "", "User ID")
+
+	for _, cmd := range []*cobra.Command{starAddCmd, starRemoveCmd} {
+		cmd.Flags().StringVar(&articleID, "id", "", "Article ID")
+	}
+
+	starCmd.AddCommand(starListCmd, starAddCmd, starRemoveCmd)
+	RootCmd.AddCommand(starCmd)
+}
+
+var starCmd = &cobra.Command{
+	Use:   "star",
+	Short: "Manage a user's starred articles, which are never removed by retention policies",
+	Run:   runStar,
+}
+
+var starListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List starred articles",
+	Run:   runStarList,
+}
+
+var starAddCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Star an article",
+	Run:   runStarAdd,
+}
+
+var starRemoveCmd = &cobra.Command{
+	Use:   "remove",
+	Short: "Unstar an article",
+	Run:   runStarRemove,
+}
+
+func runStar(cmd *cobra.Command, args []string) {
+	_ = cmd.Help()
+	os.Exit(0)
+}
+
+func runStarList(cmd *cobra.Command, args []string) {
+	c := newClient()
+	articles, err := c.ListStarredArticles(userID)
+	if err != nil {
+		log.Fatalf("Failed to list starred articles: %s", err)
+	}
+	log.Printf("Articles starred by user %s:", userID)
+	for _, a := range articles {
+		spew.Printf("%+v\n", a)
+	}
+}
+
+func runStarAdd(cmd *cobra.Command, args []string) {
+	c := newClient()
+	if err := c.StarArticle(userID, articleID); err != nil {
+		log.Fatalf("Failed to star article: %s", err)
+	}
+	log.Printf("Article %s starred", articleID)
+}
+
+func runStarRemove(cmd *cobra.Command, args []string) {
+	c := newClient()
+	if err := c.UnstarArticle(userID, articleID); err != nil {
+		log.Fatalf("Failed to unstar article: %s", err)
+	}
+	log.Printf("Article %s unstarred", articleID)
+}
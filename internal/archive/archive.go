@@ -0,0 +1,39 @@
+// Package archive exports the full dataset of a repository to a versioned tar archive of NDJSON files and imports it
+// into any repository, preserving IDs
+package archive
+
+import (
+	"time"
+)
+
+// FormatVersion is the version of the archive format written by Export, archives of newer versions are not imported
+const FormatVersion = 1
+
+// Names of the files in an archive, in the order they are written and must be imported
+const (
+	ManifestFile    = "manifest.json"
+	UsersFile       = "users.ndjson"
+	FeedsFile       = "feeds.ndjson"
+	ArticlesFile    = "articles.ndjson"
+	FilterRulesFile = "filter_rules.ndjson"
+)
+
+// sections lists the NDJSON files of an archive in dependency order: subscriptions and filter rules refer to Users,
+// Articles to Feeds
+var sections = []string{UsersFile, FeedsFile, ArticlesFile, FilterRulesFile}
+
+// Manifest describes an archive, it is the first file of the archive
+type Manifest struct {
+	FormatVersion int       `json:"format_version"`
+	CreatedTime   time.Time `json:"created_at"`
+	// Version is the version of tldrfeed that wrote the archive
+	Version string `json:"tldrfeed_version"`
+	// Records counts the records of every file
+	Records map[string]int `json:"records"`
+}
+
+// Progress is called as records of a file are exported or imported, with the number of records done so far
+type Progress func(file string, records int)
+
+// progressInterval is the number of records between calls to Progress, which is also called at the end of every file
+const progressInterval = 1000
@@ -0,0 +1,112 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+	"github.com/if-ivan-else/tldrfeed/internal/db/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	src := mock.NewRepository()
+	u, _ := src.CreateUser(ctx, "reader")
+	f, _ := src.CreateFeed(ctx, "News", "world", nil)
+	require.NoError(src.SetFeedRetention(ctx, f.ID, &api.RetentionPolicy{MaxCount: 10}))
+	require.NoError(src.AddUserFeed(ctx, u.ID, f.ID))
+	published, err := src.CreateFeedArticle(ctx, f.ID, api.Article{Title: "Published", Body: "body", Tags: []string{"news"}})
+	require.NoError(err)
+	publishAt := time.Now().Add(time.Hour).UTC()
+	_, err = src.CreateFeedArticle(ctx, f.ID, api.Article{Title: "Scheduled", Body: "body", Status: api.StatusScheduled, PublishAt: &publishAt})
+	require.NoError(err)
+	require.NoError(src.StarArticle(ctx, u.ID, published))
+	rule, err := src.CreateFilterRule(ctx, u.ID, api.FilterRule{Type: api.FilterKeyword, Value: "sports", Action: api.FilterExclude})
+	require.NoError(err)
+
+	var archive bytes.Buffer
+	progressed := map[string]int{}
+	manifest, err := Export(ctx, src, &archive, func(file string, records int) { progressed[file] = records })
+	require.NoError(err)
+	require.Equal(FormatVersion, manifest.FormatVersion)
+	expected := map[string]int{UsersFile: 1, FeedsFile: 1, ArticlesFile: 2, FilterRulesFile: 1}
+	require.Equal(expected, manifest.Records)
+	require.Equal(expected, progressed)
+
+	// Gzipped archives are imported too
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	_, err = gz.Write(archive.Bytes())
+	require.NoError(err)
+	require.NoError(gz.Close())
+
+	dst := mock.NewRepository()
+	result, err := Import(ctx, dst, bytes.NewReader(gzipped.Bytes()), ConflictFail, nil)
+	require.NoError(err)
+	require.Equal(expected, result.Imported)
+
+	feeds, err := dst.ListUserFeeds(ctx, u.ID)
+	require.NoError(err)
+	require.Len(feeds, 1)
+	require.Equal(f.ID, feeds[0].ID)
+	require.Equal(&api.RetentionPolicy{MaxCount: 10}, feeds[0].Retention)
+	dstArticles, err := dst.ListFeedArticles(ctx, f.ID, db.ArticleFilter{})
+	require.NoError(err)
+	require.Len(dstArticles, 1)
+	require.Equal(published, dstArticles[0].ID)
+	require.Equal([]string{"news"}, dstArticles[0].Tags)
+	drafts, err := dst.ListFeedDrafts(ctx, f.ID)
+	require.NoError(err)
+	require.Len(drafts, 1)
+	starred, err := dst.ListStarredArticles(ctx, u.ID)
+	require.NoError(err)
+	require.Len(starred, 1)
+	rules, err := dst.ListFilterRules(ctx, u.ID)
+	require.NoError(err)
+	require.Len(rules, 1)
+	require.Equal(rule.ID, rules[0].ID)
+	require.Equal(rule.Value, rules[0].Value)
+
+	// Importing again conflicts with every record
+	_, err = Import(ctx, dst, bytes.NewReader(archive.Bytes()), ConflictFail, nil)
+	require.Error(err)
+	result, err = Import(ctx, dst, bytes.NewReader(archive.Bytes()), ConflictSkip, nil)
+	require.NoError(err)
+	require.Equal(expected, result.Skipped)
+	require.Empty(result.Imported)
+	result, err = Import(ctx, dst, bytes.NewReader(archive.Bytes()), ConflictReplace, nil)
+	require.NoError(err)
+	require.Equal(expected, result.Imported)
+	dstArticles, err = dst.ListFeedArticles(ctx, f.ID, db.ArticleFilter{})
+	require.NoError(err)
+	require.Len(dstArticles, 1)
+
+	_, err = Import(ctx, dst, bytes.NewReader(archive.Bytes()), "merge", nil)
+	require.Error(err)
+}
+
+func TestImportTruncated(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	src := mock.NewRepository()
+	_, _ = src.CreateUser(ctx, "reader")
+	var archive bytes.Buffer
+	_, err := Export(ctx, src, &archive, nil)
+	require.NoError(err)
+
+	// Cut the archive in the middle of the users
+	_, err = Import(ctx, mock.NewRepository(), bytes.NewReader(archive.Bytes()[:1200]), ConflictFail, nil)
+	require.Error(err)
+	t.Logf("Error message (expected): %s", err)
+
+	_, err = Import(ctx, mock.NewRepository(), bytes.NewReader([]byte("not an archive")), ConflictFail, nil)
+	require.Error(err)
+}
@@ -0,0 +1,116 @@
+package archive
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/buildinfo"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+	"github.com/pkg/errors"
+)
+
+// Export writes all the records of a repository to w as a tar archive. Records are streamed to temporary files first,
+// as tar headers carry the size of the files, so that memory use does not grow with the dataset.
+func Export(ctx context.Context, store db.DatasetStore, w io.Writer, progress Progress) (*Manifest, error) {
+	manifest := &Manifest{
+		FormatVersion: FormatVersion,
+		CreatedTime:   time.Now().UTC(),
+		Version:       buildinfo.Version,
+		Records:       map[string]int{},
+	}
+
+	exports := map[string]func(visit func(record interface{}) error) error{
+		UsersFile: func(visit func(interface{}) error) error {
+			return store.ExportUsers(ctx, func(u api.User) error { return visit(u) })
+		},
+		FeedsFile: func(visit func(interface{}) error) error {
+			return store.ExportFeeds(ctx, func(f db.FeedRecord) error { return visit(f) })
+		},
+		ArticlesFile: func(visit func(interface{}) error) error {
+			return store.ExportArticles(ctx, func(a db.ArticleRecord) error { return visit(a) })
+		},
+		FilterRulesFile: func(visit func(interface{}) error) error {
+			return store.ExportFilterRules(ctx, func(r db.FilterRuleRecord) error { return visit(r) })
+		},
+	}
+
+	spooled := map[string]*os.File{}
+	defer func() {
+		for _, f := range spooled {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	for _, name := range sections {
+		f, err := ioutil.TempFile("", "tldrfeed-export")
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to create temporary file")
+		}
+		spooled[name] = f
+
+		enc := json.NewEncoder(f)
+		count := 0
+		err = exports[name](func(record interface{}) error {
+			if err := enc.Encode(record); err != nil {
+				return err
+			}
+			count++
+			if progress != nil && count%progressInterval == 0 {
+				progress(name, count)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to export %s", name)
+		}
+		manifest.Records[name] = count
+		if progress != nil {
+			progress(name, count)
+		}
+	}
+
+	tw := tar.NewWriter(w)
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := tw.WriteHeader(fileHeader(ManifestFile, int64(len(data)), manifest.CreatedTime)); err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return nil, err
+	}
+
+	for _, name := range sections {
+		f := spooled[name]
+		size, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if err := tw.WriteHeader(fileHeader(name, size, manifest.CreatedTime)); err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(tw, f); err != nil {
+			return nil, errors.Wrapf(err, "Failed to write %s", name)
+		}
+	}
+	return manifest, tw.Close()
+}
+
+func fileHeader(name string, size int64, modified time.Time) *tar.Header {
+	return &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    size,
+		ModTime: modified,
+	}
+}
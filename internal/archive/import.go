@@ -0,0 +1,155 @@
+package archive
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+	"github.com/pkg/errors"
+)
+
+// Strategies for records with the ID of an existing record
+const (
+	// ConflictFail stops the import at the first conflicting record
+	ConflictFail = "fail"
+	// ConflictSkip keeps existing records
+	ConflictSkip = "skip"
+	// ConflictReplace replaces existing records with the imported ones
+	ConflictReplace = "replace"
+)
+
+// ImportResult counts the records of every file imported and skipped
+type ImportResult struct {
+	Manifest *Manifest
+	Imported map[string]int
+	Skipped  map[string]int
+}
+
+// Import restores the records of an archive, plain or gzipped, into a repository with their IDs. Records conflicting
+// with existing ones are handled according to onConflict, one of ConflictFail, ConflictSkip or ConflictReplace.
+func Import(ctx context.Context, store db.DatasetStore, r io.Reader, onConflict string, progress Progress) (*ImportResult, error) {
+	switch onConflict {
+	case ConflictFail, ConflictSkip, ConflictReplace:
+	default:
+		return nil, errors.Errorf("Unknown conflict strategy '%s', expected one of fail, skip, replace", onConflict)
+	}
+
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = br
+	}
+
+	tr := tar.NewReader(r)
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read archive")
+	}
+	if hdr.Name != ManifestFile {
+		return nil, errors.Errorf("Archive does not start with %s", ManifestFile)
+	}
+	manifest := &Manifest{}
+	if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+		return nil, errors.Wrapf(err, "Failed to read %s", ManifestFile)
+	}
+	if manifest.FormatVersion < 1 || manifest.FormatVersion > FormatVersion {
+		return nil, errors.Errorf("Archive format version %d is not supported, expected at most %d",
+			manifest.FormatVersion, FormatVersion)
+	}
+
+	result := &ImportResult{
+		Manifest: manifest,
+		Imported: map[string]int{},
+		Skipped:  map[string]int{},
+	}
+	replace := onConflict == ConflictReplace
+	imports := map[string]func(dec *json.Decoder) error{
+		UsersFile: func(dec *json.Decoder) error {
+			var u api.User
+			if err := dec.Decode(&u); err != nil {
+				return err
+			}
+			return store.RestoreUser(ctx, u, replace)
+		},
+		FeedsFile: func(dec *json.Decoder) error {
+			var f db.FeedRecord
+			if err := dec.Decode(&f); err != nil {
+				return err
+			}
+			return store.RestoreFeed(ctx, f, replace)
+		},
+		ArticlesFile: func(dec *json.Decoder) error {
+			var a db.ArticleRecord
+			if err := dec.Decode(&a); err != nil {
+				return err
+			}
+			return store.RestoreArticle(ctx, a, replace)
+		},
+		FilterRulesFile: func(dec *json.Decoder) error {
+			var rule db.FilterRuleRecord
+			if err := dec.Decode(&rule); err != nil {
+				return err
+			}
+			return store.RestoreFilterRule(ctx, rule, replace)
+		},
+	}
+
+	seen := map[string]bool{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result, errors.Wrap(err, "Failed to read archive")
+		}
+		// Files added by newer versions of the same format can be left out
+		restore, ok := imports[hdr.Name]
+		if !ok {
+			continue
+		}
+
+		dec := json.NewDecoder(tr)
+		count := 0
+		for dec.More() {
+			err := restore(dec)
+			switch {
+			case err == db.ErrRecordExists && onConflict == ConflictSkip:
+				result.Skipped[hdr.Name]++
+			case err != nil:
+				return result, errors.Wrapf(err, "Failed to import record %d of %s", count+1, hdr.Name)
+			default:
+				result.Imported[hdr.Name]++
+			}
+			count++
+			if progress != nil && count%progressInterval == 0 {
+				progress(hdr.Name, count)
+			}
+		}
+		if progress != nil {
+			progress(hdr.Name, count)
+		}
+		if expected, ok := manifest.Records[hdr.Name]; ok && expected != count {
+			return result, errors.Errorf("Archive is truncated, %s has %d records out of %d", hdr.Name, count, expected)
+		}
+		seen[hdr.Name] = true
+	}
+
+	for name, expected := range manifest.Records {
+		if _, known := imports[name]; known && !seen[name] && expected > 0 {
+			return result, errors.Errorf("Archive is truncated, %s is missing", name)
+		}
+	}
+	return result, nil
+}
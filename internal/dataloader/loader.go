@@ -0,0 +1,113 @@
+// Package dataloader coalesces the loads of records by key made concurrently into batches, so that resolving a field
+// of every item of a list reads the records of all the items at once
+package dataloader
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BatchFunc loads the records of a batch of distinct keys, the records missing from the map it returns are not found
+type BatchFunc func(ctx context.Context, keys []string) (map[string]interface{}, error)
+
+// Loader batches the loads it is asked for until its wait elapses or the batch is full, and caches the records it
+// loaded. Loaders are short-lived, typically created for a request and dropped with it.
+type Loader struct {
+	ctx      context.Context
+	fn       BatchFunc
+	wait     time.Duration
+	maxBatch int
+
+	mu      sync.Mutex
+	results map[string]*result
+	pending *batch
+}
+
+type result struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+type batch struct {
+	keys    []string
+	results []*result
+	timer   *time.Timer
+}
+
+// New creates a Loader calling fn with ctx. A batch is loaded once wait elapses after its first key, or right away
+// once it has maxBatch keys.
+func New(ctx context.Context, fn BatchFunc, wait time.Duration, maxBatch int) *Loader {
+	return &Loader{
+		ctx:      ctx,
+		fn:       fn,
+		wait:     wait,
+		maxBatch: maxBatch,
+		results:  make(map[string]*result),
+	}
+}
+
+// Load returns the record of a key, or nil when it is not found. Concurrent loads of keys are loaded in a batch, loads
+// of a key already loaded return the same record and error.
+func (l *Loader) Load(ctx context.Context, key string) (interface{}, error) {
+	l.mu.Lock()
+	r, ok := l.results[key]
+	if !ok {
+		r = &result{done: make(chan struct{})}
+		l.results[key] = r
+		l.add(key, r)
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return r.value, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// add adds a key to the pending batch, starting one when there is none, and loads the batch once full. It is called
+// with the lock held.
+func (l *Loader) add(key string, r *result) {
+	if l.pending == nil {
+		b := &batch{}
+		b.timer = time.AfterFunc(l.wait, func() { l.dispatch(b) })
+		l.pending = b
+	}
+
+	b := l.pending
+	b.keys = append(b.keys, key)
+	b.results = append(b.results, r)
+	if len(b.keys) >= l.maxBatch {
+		b.timer.Stop()
+		l.pending = nil
+		go l.load(b)
+	}
+}
+
+// dispatch loads a batch once its wait elapsed, unless it was loaded as it got full
+func (l *Loader) dispatch(b *batch) {
+	l.mu.Lock()
+	if l.pending != b {
+		l.mu.Unlock()
+		return
+	}
+	l.pending = nil
+	l.mu.Unlock()
+
+	l.load(b)
+}
+
+func (l *Loader) load(b *batch) {
+	values, err := l.fn(l.ctx, b.keys)
+	for i, r := range b.results {
+		if err != nil {
+			r.err = err
+		} else {
+			r.value = values[b.keys[i]]
+		}
+		close(r.done)
+	}
+}
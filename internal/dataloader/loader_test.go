@@ -0,0 +1,94 @@
+package dataloader
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// recorder is a BatchFunc recording the batches it is called with, keys starting with "missing" are not found
+type recorder struct {
+	mu      sync.Mutex
+	batches [][]string
+	err     error
+}
+
+func (r *recorder) load(ctx context.Context, keys []string) (map[string]interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sorted := append([]string{}, keys...)
+	sort.Strings(sorted)
+	r.batches = append(r.batches, sorted)
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	values := map[string]interface{}{}
+	for _, k := range keys {
+		if k != "missing" {
+			values[k] = "value of " + k
+		}
+	}
+	return values, nil
+}
+
+func loadAll(l *Loader, keys ...string) ([]interface{}, []error) {
+	values := make([]interface{}, len(keys))
+	errs := make([]error, len(keys))
+	var wg sync.WaitGroup
+	for i, k := range keys {
+		wg.Add(1)
+		go func(i int, k string) {
+			defer wg.Done()
+			values[i], errs[i] = l.Load(context.Background(), k)
+		}(i, k)
+	}
+	wg.Wait()
+	return values, errs
+}
+
+func TestBatching(t *testing.T) {
+	require := require.New(t)
+	r := &recorder{}
+	l := New(context.Background(), r.load, 10*time.Millisecond, 100)
+
+	values, errs := loadAll(l, "a", "b", "a", "missing")
+	require.Equal([]interface{}{"value of a", "value of b", "value of a", nil}, values)
+	require.Equal([]error{nil, nil, nil, nil}, errs)
+	require.Equal([][]string{{"a", "b", "missing"}}, r.batches)
+
+	// Records loaded are cached
+	values, _ = loadAll(l, "b", "c")
+	require.Equal([]interface{}{"value of b", "value of c"}, values)
+	require.Equal([][]string{{"a", "b", "missing"}, {"c"}}, r.batches)
+}
+
+func TestFullBatch(t *testing.T) {
+	require := require.New(t)
+	r := &recorder{}
+	// Batches only get loaded once full
+	l := New(context.Background(), r.load, time.Hour, 2)
+
+	values, _ := loadAll(l, "a", "b")
+	require.Equal([]interface{}{"value of a", "value of b"}, values)
+	require.Equal([][]string{{"a", "b"}}, r.batches)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := l.Load(ctx, "c")
+	require.Equal(context.DeadlineExceeded, err)
+}
+
+func TestBatchError(t *testing.T) {
+	require := require.New(t)
+	r := &recorder{err: errors.New("DB is down")}
+	l := New(context.Background(), r.load, time.Millisecond, 100)
+
+	_, errs := loadAll(l, "a", "b")
+	require.Equal([]error{r.err, r.err}, errs)
+}
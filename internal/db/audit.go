@@ -0,0 +1,31 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+)
+
+// AuditFilter narrows down audit records, zero values match all records
+type AuditFilter struct {
+	EntityType string
+	EntityID   string
+	Principal  string
+	Action     string
+	// Since and Until bound the time of the records, Since inclusively and Until exclusively
+	Since time.Time
+	Until time.Time
+	// Limit is the number of latest matching records returned, all of them when zero
+	Limit int
+}
+
+// AuditStore defines persistence of the audit log, an append-only log of the changes made to the repository
+type AuditStore interface {
+	// AppendAuditRecord adds a record to the audit log, records are never changed or removed
+	AppendAuditRecord(ctx context.Context, record api.AuditRecord) error
+
+	// ListAuditRecords returns the latest records matching a filter, oldest first. Records of the same time are
+	// ordered by ID.
+	ListAuditRecords(ctx context.Context, filter AuditFilter) ([]api.AuditRecord, error)
+}
@@ -0,0 +1,29 @@
+package audit
+
+import "context"
+
+// Anonymous is the principal of changes made by unauthenticated clients
+const Anonymous = "anonymous"
+
+// Actor is who changes are recorded as made by
+type Actor struct {
+	Principal string
+	// RequestID identifies the request making the changes, if any
+	RequestID string
+}
+
+type actorKey struct{}
+
+// NewContext returns a context recording changes made with it as made by actor
+func NewContext(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// FromContext returns the actor of a context, changes made with a context without one are made by Anonymous
+func FromContext(ctx context.Context) Actor {
+	actor, _ := ctx.Value(actorKey{}).(Actor)
+	if actor.Principal == "" {
+		actor.Principal = Anonymous
+	}
+	return actor
+}
@@ -0,0 +1,369 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+)
+
+// Repository is a db.Repository appending a record of every successful change to the audit log, made by the actor
+// of the change's context. Records are appended after the change, a change whose record fails to append is still
+// made and the failure logged.
+type Repository struct {
+	db.Repository
+
+	mu sync.Mutex
+	// last is the time of the latest record, records get increasing times so that they list in the order made
+	last time.Time
+}
+
+// NewRepository decorates a repository with an audit log kept in the repository itself
+func NewRepository(repo db.Repository) *Repository {
+	return &Repository{Repository: repo}
+}
+
+// record appends a record of a change, before and after are marshaled to JSON unless nil
+func (r *Repository) record(ctx context.Context, action string, entityType string, entityID string, before interface{}, after interface{}) {
+	actor := FromContext(ctx)
+	record := api.AuditRecord{
+		ID:         uuid.New().String(),
+		Time:       r.now(),
+		Principal:  actor.Principal,
+		RequestID:  actor.RequestID,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+	}
+	var err error
+	if before != nil {
+		if record.Before, err = json.Marshal(before); err != nil {
+			log.Printf("Failed to record %s of %s %s: %s", action, entityType, entityID, err)
+			return
+		}
+	}
+	if after != nil {
+		if record.After, err = json.Marshal(after); err != nil {
+			log.Printf("Failed to record %s of %s %s: %s", action, entityType, entityID, err)
+			return
+		}
+	}
+
+	// The change is made, so the record is appended even when the change's context has since been canceled
+	if err := r.Repository.AppendAuditRecord(context.Background(), record); err != nil {
+		log.Printf("Failed to record %s of %s %s: %s", action, entityType, entityID, err)
+	}
+}
+
+// now returns the time of a new record, later than all records before it. Times have the millisecond precision of
+// MongoDB, records made within the same millisecond are a millisecond apart.
+func (r *Repository) now() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	if !now.After(r.last) {
+		now = r.last.Add(time.Millisecond)
+	}
+	r.last = now
+	return now
+}
+
+type subscription struct {
+	FeedID string `json:"feed_id"`
+}
+
+type star struct {
+	ArticleID string `json:"article_id"`
+}
+
+type expiry struct {
+	Removed int `json:"removed"`
+}
+
+func (r *Repository) CreateUser(ctx context.Context, name string) (*api.User, error) {
+	u, err := r.Repository.CreateUser(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	r.record(ctx, api.AuditCreate, api.AuditUser, u.ID, nil, u)
+	return u, nil
+}
+
+func (r *Repository) CreateFeed(ctx context.Context, name string, category string, retention *api.RetentionPolicy) (*api.Feed, error) {
+	f, err := r.Repository.CreateFeed(ctx, name, category, retention)
+	if err != nil {
+		return nil, err
+	}
+	r.record(ctx, api.AuditCreate, api.AuditFeed, f.ID, nil, f)
+	return f, nil
+}
+
+func (r *Repository) CreateFeedArticle(ctx context.Context, feedID string, article api.Article) (string, error) {
+	articleID, err := r.Repository.CreateFeedArticle(ctx, feedID, article)
+	if err != nil {
+		return "", err
+	}
+	r.recordArticles(ctx, feedID, []api.Article{article}, []string{articleID})
+	return articleID, nil
+}
+
+// CreateFeedArticles records the Articles added before failing, which stay added
+func (r *Repository) CreateFeedArticles(ctx context.Context, feedID string, articles []api.Article) ([]string, error) {
+	articleIDs, err := r.Repository.CreateFeedArticles(ctx, feedID, articles)
+	r.recordArticles(ctx, feedID, articles, articleIDs)
+	return articleIDs, err
+}
+
+// recordArticles records the creation of Articles as stored, with the IDs, times and contents assigned by the
+// repository. Articles that cannot be read back are recorded as given.
+func (r *Repository) recordArticles(ctx context.Context, feedID string, articles []api.Article, articleIDs []string) {
+	if len(articleIDs) > len(articles) {
+		articleIDs = articleIDs[:len(articles)]
+	}
+	stored := map[string]api.Article{}
+	found, err := r.Repository.GetFeedArticles(context.Background(), feedID, articleIDs)
+	if err != nil {
+		log.Printf("Failed to read Articles added to Feed %s: %s", feedID, err)
+	}
+	for _, a := range found {
+		stored[a.ID] = a
+	}
+
+	for i, articleID := range articleIDs {
+		article, ok := stored[articleID]
+		if !ok {
+			article = articles[i]
+			article.ID = articleID
+			article.FeedID = feedID
+		}
+		r.record(ctx, api.AuditCreate, api.AuditArticle, articleID, nil, article)
+	}
+}
+
+func (r *Repository) AddUserFeed(ctx context.Context, userID string, feedID string) error {
+	if err := r.Repository.AddUserFeed(ctx, userID, feedID); err != nil {
+		return err
+	}
+	r.record(ctx, api.AuditSubscribe, api.AuditUser, userID, nil, subscription{FeedID: feedID})
+	return nil
+}
+
+func (r *Repository) RemoveUserFeed(ctx context.Context, userID string, feedID string) error {
+	if err := r.Repository.RemoveUserFeed(ctx, userID, feedID); err != nil {
+		return err
+	}
+	r.record(ctx, api.AuditUnsubscribe, api.AuditUser, userID, subscription{FeedID: feedID}, nil)
+	return nil
+}
+
+func (r *Repository) UpdateFeedDraft(ctx context.Context, feedID string, article api.Article) (*api.Article, error) {
+	before, err := r.Repository.GetFeedDraft(ctx, feedID, article.ID)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := r.Repository.UpdateFeedDraft(ctx, feedID, article)
+	if err != nil {
+		return nil, err
+	}
+	action := api.AuditUpdate
+	if updated.Status == api.StatusPublished {
+		action = api.AuditPublish
+	}
+	r.record(ctx, action, api.AuditArticle, updated.ID, before, updated)
+	return updated, nil
+}
+
+func (r *Repository) DeleteFeedDraft(ctx context.Context, feedID string, articleID string) error {
+	before, err := r.Repository.GetFeedDraft(ctx, feedID, articleID)
+	if err != nil {
+		return err
+	}
+	if err := r.Repository.DeleteFeedDraft(ctx, feedID, articleID); err != nil {
+		return err
+	}
+	r.record(ctx, api.AuditDelete, api.AuditArticle, articleID, before, nil)
+	return nil
+}
+
+func (r *Repository) PublishDueArticles(ctx context.Context, now time.Time) ([]api.Article, error) {
+	published, err := r.Repository.PublishDueArticles(ctx, now)
+	for i := range published {
+		r.record(ctx, api.AuditPublish, api.AuditArticle, published[i].ID, nil, &published[i])
+	}
+	return published, err
+}
+
+func (r *Repository) SetFeedRetention(ctx context.Context, feedID string, policy *api.RetentionPolicy) error {
+	before, err := r.Repository.GetFeed(ctx, feedID)
+	if err != nil {
+		return err
+	}
+	if err := r.Repository.SetFeedRetention(ctx, feedID, policy); err != nil {
+		return err
+	}
+	after := *before
+	after.Retention = policy
+	r.record(ctx, api.AuditUpdate, api.AuditFeed, feedID, before, &after)
+	return nil
+}
+
+func (r *Repository) ExpireFeedArticles(ctx context.Context, feedID string, now time.Time) (int, error) {
+	expired, err := r.expiredArticles(ctx, feedID, now)
+	if err != nil {
+		return 0, err
+	}
+	n, err := r.Repository.ExpireFeedArticles(ctx, feedID, now)
+	if err != nil {
+		return n, err
+	}
+	if n == 0 {
+		return n, nil
+	}
+
+	// Articles starred since they were reported expired are kept
+	ids := make([]string, len(expired))
+	for i := range expired {
+		ids[i] = expired[i].ID
+	}
+	kept, err := r.Repository.GetFeedArticles(ctx, feedID, ids)
+	if err != nil {
+		log.Printf("Failed to record expiry of Articles of Feed %s: %s", feedID, err)
+		kept = expired
+	}
+	isKept := make(map[string]bool, len(kept))
+	for _, a := range kept {
+		isKept[a.ID] = true
+	}
+	for i := range expired {
+		if !isKept[expired[i].ID] {
+			r.record(ctx, api.AuditExpire, api.AuditArticle, expired[i].ID, &expired[i], nil)
+		}
+	}
+	r.record(ctx, api.AuditExpire, api.AuditFeed, feedID, nil, expiry{Removed: n})
+	return n, nil
+}
+
+// expiredArticles returns the Articles of a Feed expired by now under its retention policy
+func (r *Repository) expiredArticles(ctx context.Context, feedID string, now time.Time) ([]api.Article, error) {
+	f, err := r.Repository.GetFeed(ctx, feedID)
+	if err != nil || f.Retention == nil {
+		return nil, err
+	}
+	report, err := r.Repository.ReportExpiredArticles(ctx, feedID, *f.Retention, now)
+	if err != nil || len(report.Expired) == 0 {
+		return nil, err
+	}
+	ids := make([]string, len(report.Expired))
+	for i, e := range report.Expired {
+		ids[i] = e.ID
+	}
+	return r.Repository.GetFeedArticles(ctx, feedID, ids)
+}
+
+func (r *Repository) StarArticle(ctx context.Context, userID string, articleID string) error {
+	if err := r.Repository.StarArticle(ctx, userID, articleID); err != nil {
+		return err
+	}
+	r.record(ctx, api.AuditStar, api.AuditUser, userID, nil, star{ArticleID: articleID})
+	return nil
+}
+
+func (r *Repository) UnstarArticle(ctx context.Context, userID string, articleID string) error {
+	if err := r.Repository.UnstarArticle(ctx, userID, articleID); err != nil {
+		return err
+	}
+	r.record(ctx, api.AuditUnstar, api.AuditUser, userID, star{ArticleID: articleID}, nil)
+	return nil
+}
+
+func (r *Repository) SetArticleSummary(ctx context.Context, feedID string, articleID string, summary string) error {
+	before, err := r.Repository.GetFeedArticles(ctx, feedID, []string{articleID})
+	if err != nil {
+		return err
+	}
+	if err := r.Repository.SetArticleSummary(ctx, feedID, articleID, summary); err != nil {
+		return err
+	}
+	if len(before) == 1 {
+		after := before[0]
+		after.Summary = summary
+		r.record(ctx, api.AuditUpdate, api.AuditArticle, articleID, &before[0], &after)
+	}
+	return nil
+}
+
+func (r *Repository) CreateFilterRule(ctx context.Context, userID string, rule api.FilterRule) (*api.FilterRule, error) {
+	created, err := r.Repository.CreateFilterRule(ctx, userID, rule)
+	if err != nil {
+		return nil, err
+	}
+	r.record(ctx, api.AuditCreate, api.AuditFilterRule, created.ID, nil, db.FilterRuleRecord{UserID: userID, Rule: *created})
+	return created, nil
+}
+
+func (r *Repository) UpdateFilterRule(ctx context.Context, userID string, rule api.FilterRule) error {
+	before, err := r.Repository.GetFilterRule(ctx, userID, rule.ID)
+	if err != nil {
+		return err
+	}
+	if err := r.Repository.UpdateFilterRule(ctx, userID, rule); err != nil {
+		return err
+	}
+	after, err := r.Repository.GetFilterRule(ctx, userID, rule.ID)
+	if err != nil {
+		after = &rule
+	}
+	r.record(ctx, api.AuditUpdate, api.AuditFilterRule, rule.ID,
+		db.FilterRuleRecord{UserID: userID, Rule: *before}, db.FilterRuleRecord{UserID: userID, Rule: *after})
+	return nil
+}
+
+func (r *Repository) DeleteFilterRule(ctx context.Context, userID string, ruleID string) error {
+	before, err := r.Repository.GetFilterRule(ctx, userID, ruleID)
+	if err != nil {
+		return err
+	}
+	if err := r.Repository.DeleteFilterRule(ctx, userID, ruleID); err != nil {
+		return err
+	}
+	r.record(ctx, api.AuditDelete, api.AuditFilterRule, ruleID, db.FilterRuleRecord{UserID: userID, Rule: *before}, nil)
+	return nil
+}
+
+func (r *Repository) RestoreUser(ctx context.Context, user api.User, replace bool) error {
+	if err := r.Repository.RestoreUser(ctx, user, replace); err != nil {
+		return err
+	}
+	r.record(ctx, api.AuditRestore, api.AuditUser, user.ID, nil, user)
+	return nil
+}
+
+func (r *Repository) RestoreFeed(ctx context.Context, record db.FeedRecord, replace bool) error {
+	if err := r.Repository.RestoreFeed(ctx, record, replace); err != nil {
+		return err
+	}
+	r.record(ctx, api.AuditRestore, api.AuditFeed, record.Feed.ID, nil, record)
+	return nil
+}
+
+func (r *Repository) RestoreArticle(ctx context.Context, record db.ArticleRecord, replace bool) error {
+	if err := r.Repository.RestoreArticle(ctx, record, replace); err != nil {
+		return err
+	}
+	r.record(ctx, api.AuditRestore, api.AuditArticle, record.Article.ID, nil, record)
+	return nil
+}
+
+func (r *Repository) RestoreFilterRule(ctx context.Context, record db.FilterRuleRecord, replace bool) error {
+	if err := r.Repository.RestoreFilterRule(ctx, record, replace); err != nil {
+		return err
+	}
+	r.record(ctx, api.AuditRestore, api.AuditFilterRule, record.Rule.ID, nil, record)
+	return nil
+}
@@ -0,0 +1,196 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+	"github.com/if-ivan-else/tldrfeed/internal/db/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository(t *testing.T) {
+	require := require.New(t)
+	r := NewRepository(mock.NewRepository())
+	defer r.Close()
+
+	ctx := NewContext(context.Background(), Actor{Principal: "editor", RequestID: "req-1"})
+	u, err := r.CreateUser(ctx, "reader")
+	require.NoError(err)
+	f, err := r.CreateFeed(ctx, "Hot", "news", nil)
+	require.NoError(err)
+	require.NoError(r.AddUserFeed(ctx, u.ID, f.ID))
+	articleID, err := r.CreateFeedArticle(ctx, f.ID, api.Article{Title: "Title", Body: "Body", Status: api.StatusPublished})
+	require.NoError(err)
+	require.NoError(r.RemoveUserFeed(ctx, u.ID, f.ID))
+
+	records, err := r.ListAuditRecords(ctx, db.AuditFilter{})
+	require.NoError(err)
+	require.Len(records, 5)
+	actions := []string{}
+	for _, record := range records {
+		require.Equal("editor", record.Principal)
+		require.Equal("req-1", record.RequestID)
+		actions = append(actions, record.Action+" "+record.EntityType)
+	}
+	require.Equal([]string{"create user", "create feed", "subscribe user", "create article", "unsubscribe user"}, actions)
+
+	var created api.Article
+	require.NoError(json.Unmarshal(records[3].After, &created))
+	require.Equal(articleID, created.ID)
+	require.Equal(f.ID, created.FeedID)
+	require.Equal("Title", created.Title)
+	// Articles are recorded as stored, with the times assigned by the repository
+	require.False(created.PublishedTime.IsZero())
+	require.Nil(records[3].Before)
+	require.JSONEq(`{"feed_id": "`+f.ID+`"}`, string(records[4].Before))
+	require.Nil(records[4].After)
+
+	// Updates record the entity before and after the change
+	policy := &api.RetentionPolicy{MaxCount: 10}
+	require.NoError(r.SetFeedRetention(ctx, f.ID, policy))
+	records, err = r.ListAuditRecords(ctx, db.AuditFilter{EntityType: api.AuditFeed, EntityID: f.ID, Action: api.AuditUpdate})
+	require.NoError(err)
+	require.Len(records, 1)
+	var before, after api.Feed
+	require.NoError(json.Unmarshal(records[0].Before, &before))
+	require.NoError(json.Unmarshal(records[0].After, &after))
+	require.Nil(before.Retention)
+	require.Equal(policy, after.Retention)
+
+	// Failed changes are not recorded, changes without an actor are made anonymously
+	require.Equal(db.ErrNoSuchFeed, r.AddUserFeed(ctx, u.ID, "missing"))
+	_, err = r.CreateUser(context.Background(), "writer")
+	require.NoError(err)
+	records, err = r.ListAuditRecords(ctx, db.AuditFilter{EntityType: api.AuditUser, Action: api.AuditCreate})
+	require.NoError(err)
+	require.Len(records, 2)
+	require.Equal(Anonymous, records[1].Principal)
+	require.Empty(records[1].RequestID)
+
+	// Drafts published and filter rules deleted are recorded as such
+	draft := api.Article{Title: "Draft", Body: "Body", Status: api.StatusDraft}
+	draftID, err := r.CreateFeedArticle(ctx, f.ID, draft)
+	require.NoError(err)
+	draft.ID = draftID
+	draft.Status = api.StatusPublished
+	_, err = r.UpdateFeedDraft(ctx, f.ID, draft)
+	require.NoError(err)
+	rule, err := r.CreateFilterRule(ctx, u.ID, api.FilterRule{Type: api.FilterKeyword, Value: "sports", Action: api.FilterExclude})
+	require.NoError(err)
+	require.NoError(r.DeleteFilterRule(ctx, u.ID, rule.ID))
+
+	records, err = r.ListAuditRecords(ctx, db.AuditFilter{Since: records[1].Time})
+	require.NoError(err)
+	actions = []string{}
+	for _, record := range records {
+		actions = append(actions, record.Action+" "+record.EntityType)
+	}
+	require.Subset(actions, []string{"publish article", "create filter_rule", "delete filter_rule"})
+	require.Equal("delete filter_rule", actions[len(actions)-1])
+
+	// Limits keep the latest records
+	records, err = r.ListAuditRecords(ctx, db.AuditFilter{Limit: 2})
+	require.NoError(err)
+	require.Len(records, 2)
+	require.Equal(api.AuditDelete, records[1].Action)
+	require.True(records[0].Time.Before(records[1].Time) || records[0].Time.Equal(records[1].Time))
+
+	records, err = r.ListAuditRecords(ctx, db.AuditFilter{Principal: "nobody"})
+	require.NoError(err)
+	require.Empty(records)
+	records, err = r.ListAuditRecords(ctx, db.AuditFilter{Until: time.Now().Add(-time.Hour)})
+	require.NoError(err)
+	require.Empty(records)
+}
+
+func TestExpiryAndSummaries(t *testing.T) {
+	require := require.New(t)
+	r := NewRepository(mock.NewRepository())
+	defer r.Close()
+
+	ctx := NewContext(context.Background(), Actor{Principal: "janitor"})
+	f, err := r.CreateFeed(ctx, "Wire", "", &api.RetentionPolicy{MaxCount: 1})
+	require.NoError(err)
+	now := time.Now()
+	oldID, err := r.CreateFeedArticle(ctx, f.ID, api.Article{Title: "Old", Body: "Body", PublishedTime: now.Add(-time.Hour)})
+	require.NoError(err)
+	newID, err := r.CreateFeedArticle(ctx, f.ID, api.Article{Title: "New", Body: "Body", PublishedTime: now})
+	require.NoError(err)
+
+	require.NoError(r.SetArticleSummary(ctx, f.ID, newID, "tl;dr"))
+	records, err := r.ListAuditRecords(ctx, db.AuditFilter{EntityID: newID, Action: api.AuditUpdate})
+	require.NoError(err)
+	require.Len(records, 1)
+	var after api.Article
+	require.NoError(json.Unmarshal(records[0].After, &after))
+	require.Equal("tl;dr", after.Summary)
+
+	// Expiry is recorded against the Feed and every Article removed
+	removed, err := r.ExpireFeedArticles(ctx, f.ID, now)
+	require.NoError(err)
+	require.Equal(1, removed)
+	records, err = r.ListAuditRecords(ctx, db.AuditFilter{Action: api.AuditExpire})
+	require.NoError(err)
+	require.Len(records, 2)
+	require.Equal(api.AuditArticle, records[0].EntityType)
+	require.Equal(oldID, records[0].EntityID)
+	var before api.Article
+	require.NoError(json.Unmarshal(records[0].Before, &before))
+	require.Equal("Old", before.Title)
+	require.Equal(api.AuditFeed, records[1].EntityType)
+	require.JSONEq(`{"removed": 1}`, string(records[1].After))
+}
+
+// TestMutatingMethods lists the methods of db.Repository that change data, which Repository must all record
+func TestMutatingMethods(t *testing.T) {
+	require := require.New(t)
+
+	mutating := []string{
+		"CreateUser", "CreateFeed", "CreateFeedArticle", "CreateFeedArticles", "AddUserFeed", "RemoveUserFeed",
+		"UpdateFeedDraft", "DeleteFeedDraft", "PublishDueArticles", "SetFeedRetention", "ExpireFeedArticles",
+		"StarArticle", "UnstarArticle", "SetArticleSummary", "CreateFilterRule", "UpdateFilterRule",
+		"DeleteFilterRule", "RestoreUser", "RestoreFeed", "RestoreArticle", "RestoreFilterRule",
+	}
+	// Idempotency records and the audit log itself are bookkeeping rather than changes to entities
+	bookkeeping := []string{
+		"CreateIdempotencyRecord", "CompleteIdempotencyRecord", "DeleteIdempotencyRecord", "AppendAuditRecord",
+	}
+	reads := []string{
+		"ListUsers", "GetUser", "ListFeeds", "GetFeed", "GetFeeds", "GetFeedVersion", "ListFeedArticles",
+		"ListArticlesOfFeeds", "GetFeedArticles", "CountFeedArticles", "ListUserFeeds", "GetUserFeed",
+		"ListUserArticles", "ListUserFeedArticles", "ListTags", "DiscoverFeeds", "SearchArticles",
+		"GetTimelineVersion", "GetIdempotencyRecord", "ListFilterRules", "GetFilterRule", "ListFeedDrafts",
+		"GetFeedDraft", "ReportExpiredArticles", "ListStarredArticles", "ListUnsummarizedArticles", "ExportUsers",
+		"ExportFeeds", "ExportArticles", "ExportFilterRules", "ListAuditRecords", "Close",
+	}
+
+	// Every method of the interface is listed, so that methods added to it are listed as changing data or not
+	listed := append(append(append([]string{}, mutating...), bookkeeping...), reads...)
+	methods := []string{}
+	repository := reflect.TypeOf((*db.Repository)(nil)).Elem()
+	for i := 0; i < repository.NumMethod(); i++ {
+		methods = append(methods, repository.Method(i).Name)
+	}
+	require.ElementsMatch(methods, listed)
+
+	// Methods promoted from the decorated repository would change data without a record
+	file, err := parser.ParseFile(token.NewFileSet(), "repository.go", nil, 0)
+	require.NoError(err)
+	declared := map[string]bool{}
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv != nil {
+			declared[fn.Name.Name] = true
+		}
+	}
+	for _, m := range mutating {
+		require.True(declared[m], "Repository does not record %s", m)
+	}
+}
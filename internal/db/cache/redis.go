@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+)
+
+const (
+	// redisPrefix namespaces the keys of cached values in Redis
+	redisPrefix = "tldrfeed:"
+	// redisMaxIdle is the number of idle connections kept open to Redis
+	redisMaxIdle = 10
+	// redisIdleTimeout is how long idle connections to Redis are kept open
+	redisIdleTimeout = 4 * time.Minute
+)
+
+// redisStore is a Store shared by servers through Redis, which evicts values according to its own policy
+type redisStore struct {
+	pool *redis.Pool
+}
+
+// NewRedis creates a Store keeping values in the Redis server at url (e.g. redis://localhost:6379/0)
+func NewRedis(url string) (Store, error) {
+	if url == "" {
+		return nil, errors.New("Empty Redis URL")
+	}
+	pool := &redis.Pool{
+		MaxIdle:     redisMaxIdle,
+		IdleTimeout: redisIdleTimeout,
+		Dial: func() (redis.Conn, error) {
+			return redis.DialURL(url)
+		},
+	}
+
+	conn := pool.Get()
+	defer conn.Close()
+	if _, err := conn.Do("PING"); err != nil {
+		pool.Close()
+		return nil, errors.Wrapf(err, "Failed to connect to Redis @ %s", url)
+	}
+	return &redisStore{pool: pool}, nil
+}
+
+func (s *redisStore) Get(key string) ([]byte, bool, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	value, err := redis.Bytes(conn.Do("GET", redisPrefix+key))
+	if err == redis.ErrNil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (s *redisStore) Set(key string, value []byte, ttl time.Duration) error {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("SET", redisPrefix+key, value, "PX", int64(ttl/time.Millisecond))
+	return err
+}
+
+func (s *redisStore) Delete(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	args := []interface{}{}
+	for _, key := range keys {
+		args = append(args, redisPrefix+key)
+	}
+	_, err := conn.Do("DEL", args...)
+	return err
+}
+
+func (s *redisStore) Close() error {
+	return s.pool.Close()
+}
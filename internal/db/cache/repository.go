@@ -0,0 +1,327 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+)
+
+// Names of the cached queries in statistics
+const (
+	QueryGetFeed          = "getFeed"
+	QueryListFeedArticles = "listFeedArticles"
+	QueryListUserFeeds    = "listUserFeeds"
+)
+
+// Repository is a db.Repository answering Feed, Feed Article and User Feed queries from a Store, invalidating them
+// when Articles are added, published or removed and when subscriptions change. Values stored while racing with a
+// change show after the TTL.
+type Repository struct {
+	db.Repository
+	store Store
+	ttl   time.Duration
+
+	errors int64
+	// queries is never modified after creation
+	queries map[string]*counters
+}
+
+type counters struct {
+	hits   int64
+	misses int64
+}
+
+// NewRepository decorates a repository with a cache keeping query results in store for ttl
+func NewRepository(repo db.Repository, store Store, ttl time.Duration) *Repository {
+	return &Repository{
+		Repository: repo,
+		store:      store,
+		ttl:        ttl,
+		queries: map[string]*counters{
+			QueryGetFeed:          {},
+			QueryListFeedArticles: {},
+			QueryListUserFeeds:    {},
+		},
+	}
+}
+
+func feedKey(feedID string) string {
+	return "feed:" + feedID
+}
+
+// generationKey is the key of the token of the current generation of a Feed's cached Article lists
+func generationKey(feedID string) string {
+	return "feed:" + feedID + ":articles"
+}
+
+func articlesKey(feedID string, generation string, filter db.ArticleFilter) string {
+	return generationKey(feedID) + ":" + generation + ":" + filter.Tag
+}
+
+func userFeedsKey(userID string) string {
+	return "user:" + userID + ":feeds"
+}
+
+func (r *Repository) GetFeed(ctx context.Context, feedID string) (*api.Feed, error) {
+	key := feedKey(feedID)
+	cached := &api.Feed{}
+	if r.get(QueryGetFeed, key, cached) {
+		return cached, nil
+	}
+
+	f, err := r.Repository.GetFeed(ctx, feedID)
+	if err != nil {
+		return nil, err
+	}
+	r.set(key, f)
+	return f, nil
+}
+
+// GetFeeds answers from the store the Feeds it holds, reading the others at once
+func (r *Repository) GetFeeds(ctx context.Context, feedIDs []string) ([]api.Feed, error) {
+	feeds := []api.Feed{}
+	missing := []string{}
+	for _, id := range feedIDs {
+		cached := api.Feed{}
+		if r.get(QueryGetFeed, feedKey(id), &cached) {
+			feeds = append(feeds, cached)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return feeds, nil
+	}
+
+	read, err := r.Repository.GetFeeds(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	for i := range read {
+		r.set(feedKey(read[i].ID), &read[i])
+	}
+	return append(feeds, read...), nil
+}
+
+func (r *Repository) ListFeedArticles(ctx context.Context, feedID string, filter db.ArticleFilter) ([]api.Article, error) {
+	// Lists filtered by a User's rules are unlikely to be read again
+	if !filter.Rules.Empty() {
+		return r.Repository.ListFeedArticles(ctx, feedID, filter)
+	}
+	generation := r.generation(feedID)
+	if generation == "" {
+		return r.Repository.ListFeedArticles(ctx, feedID, filter)
+	}
+
+	key := articlesKey(feedID, generation, filter)
+	cached := []api.Article{}
+	if r.get(QueryListFeedArticles, key, &cached) {
+		return cached, nil
+	}
+
+	articles, err := r.Repository.ListFeedArticles(ctx, feedID, filter)
+	if err != nil {
+		return nil, err
+	}
+	r.set(key, articles)
+	return articles, nil
+}
+
+// generation returns the token of the current generation of a Feed's cached Article lists, starting a new one when
+// there is none. Invalidating the lists removes the token, so lists read before a change but stored after it are never
+// read. It is empty when the store fails.
+func (r *Repository) generation(feedID string) string {
+	key := generationKey(feedID)
+	token, ok, err := r.store.Get(key)
+	if err != nil {
+		r.storeFailed(err)
+		return ""
+	}
+	if ok {
+		return string(token)
+	}
+
+	generation := uuid.New().String()
+	if err := r.store.Set(key, []byte(generation), r.ttl); err != nil {
+		r.storeFailed(err)
+		return ""
+	}
+	return generation
+}
+
+// ListUserFeeds caches the IDs of the Feeds a User follows, the Feeds themselves are read through the Feed cache
+func (r *Repository) ListUserFeeds(ctx context.Context, userID string) ([]api.Feed, error) {
+	key := userFeedsKey(userID)
+	feedIDs := []string{}
+	if !r.get(QueryListUserFeeds, key, &feedIDs) {
+		feeds, err := r.Repository.ListUserFeeds(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range feeds {
+			feedIDs = append(feedIDs, f.ID)
+		}
+		r.set(key, feedIDs)
+		return feeds, nil
+	}
+
+	feeds := []api.Feed{}
+	for _, feedID := range feedIDs {
+		f, err := r.GetFeed(ctx, feedID)
+		if err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, *f)
+	}
+	return feeds, nil
+}
+
+// Writes invalidate cached queries whether they succeed or not, as failed writes may have been partly applied
+
+func (r *Repository) CreateFeedArticle(ctx context.Context, feedID string, article api.Article) (string, error) {
+	defer r.invalidate(generationKey(feedID))
+	return r.Repository.CreateFeedArticle(ctx, feedID, article)
+}
+
+func (r *Repository) CreateFeedArticles(ctx context.Context, feedID string, articles []api.Article) ([]string, error) {
+	defer r.invalidate(generationKey(feedID))
+	return r.Repository.CreateFeedArticles(ctx, feedID, articles)
+}
+
+func (r *Repository) UpdateFeedDraft(ctx context.Context, feedID string, article api.Article) (*api.Article, error) {
+	defer r.invalidate(generationKey(feedID))
+	return r.Repository.UpdateFeedDraft(ctx, feedID, article)
+}
+
+func (r *Repository) DeleteFeedDraft(ctx context.Context, feedID string, articleID string) error {
+	defer r.invalidate(generationKey(feedID))
+	return r.Repository.DeleteFeedDraft(ctx, feedID, articleID)
+}
+
+func (r *Repository) PublishDueArticles(ctx context.Context, now time.Time) ([]api.Article, error) {
+	published, err := r.Repository.PublishDueArticles(ctx, now)
+	keys := []string{}
+	for _, a := range published {
+		keys = append(keys, generationKey(a.FeedID))
+	}
+	r.invalidate(keys...)
+	return published, err
+}
+
+func (r *Repository) ExpireFeedArticles(ctx context.Context, feedID string, now time.Time) (int, error) {
+	defer r.invalidate(generationKey(feedID))
+	return r.Repository.ExpireFeedArticles(ctx, feedID, now)
+}
+
+func (r *Repository) SetArticleSummary(ctx context.Context, feedID string, articleID string, summary string) error {
+	defer r.invalidate(generationKey(feedID))
+	return r.Repository.SetArticleSummary(ctx, feedID, articleID, summary)
+}
+
+func (r *Repository) SetFeedRetention(ctx context.Context, feedID string, policy *api.RetentionPolicy) error {
+	defer r.invalidate(feedKey(feedID), generationKey(feedID))
+	return r.Repository.SetFeedRetention(ctx, feedID, policy)
+}
+
+func (r *Repository) AddUserFeed(ctx context.Context, userID string, feedID string) error {
+	defer r.invalidate(userFeedsKey(userID))
+	return r.Repository.AddUserFeed(ctx, userID, feedID)
+}
+
+func (r *Repository) RemoveUserFeed(ctx context.Context, userID string, feedID string) error {
+	defer r.invalidate(userFeedsKey(userID))
+	return r.Repository.RemoveUserFeed(ctx, userID, feedID)
+}
+
+func (r *Repository) RestoreFeed(ctx context.Context, record db.FeedRecord, replace bool) error {
+	keys := []string{feedKey(record.Feed.ID), generationKey(record.Feed.ID)}
+	for _, userID := range record.Subscribers {
+		keys = append(keys, userFeedsKey(userID))
+	}
+	defer r.invalidate(keys...)
+	return r.Repository.RestoreFeed(ctx, record, replace)
+}
+
+func (r *Repository) RestoreArticle(ctx context.Context, record db.ArticleRecord, replace bool) error {
+	defer r.invalidate(generationKey(record.Article.FeedID))
+	return r.Repository.RestoreArticle(ctx, record, replace)
+}
+
+func (r *Repository) Close() {
+	if err := r.store.Close(); err != nil {
+		log.Printf("Failed to close cache: %s", err)
+	}
+	r.Repository.Close()
+}
+
+// Stats returns the numbers of hits and misses of cached queries since the Repository was created
+func (r *Repository) Stats() api.CacheStats {
+	stats := api.CacheStats{
+		Errors:  atomic.LoadInt64(&r.errors),
+		Queries: map[string]api.CacheQueryStats{},
+	}
+	for name, c := range r.queries {
+		q := api.CacheQueryStats{
+			Hits:   atomic.LoadInt64(&c.hits),
+			Misses: atomic.LoadInt64(&c.misses),
+		}
+		stats.Hits += q.Hits
+		stats.Misses += q.Misses
+		stats.Queries[name] = q
+	}
+	return stats
+}
+
+// get decodes the value cached under key into value, returning false on a miss
+func (r *Repository) get(query string, key string, value interface{}) bool {
+	data, ok, err := r.store.Get(key)
+	if err != nil {
+		r.storeFailed(err)
+		ok = false
+	}
+	if ok {
+		if err := json.Unmarshal(data, value); err != nil {
+			r.storeFailed(err)
+			ok = false
+		}
+	}
+
+	if ok {
+		atomic.AddInt64(&r.queries[query].hits, 1)
+	} else {
+		atomic.AddInt64(&r.queries[query].misses, 1)
+	}
+	return ok
+}
+
+func (r *Repository) set(key string, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		r.storeFailed(err)
+		return
+	}
+	if err := r.store.Set(key, data, r.ttl); err != nil {
+		r.storeFailed(err)
+	}
+}
+
+func (r *Repository) invalidate(keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+	if err := r.store.Delete(keys...); err != nil {
+		r.storeFailed(err)
+	}
+}
+
+// storeFailed records a failure of the store, which is bypassed so that requests are still served
+func (r *Repository) storeFailed(err error) {
+	atomic.AddInt64(&r.errors, 1)
+	log.Printf("Cache failure: %s", err)
+}
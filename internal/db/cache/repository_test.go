@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+	"github.com/if-ivan-else/tldrfeed/internal/db/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository(t *testing.T) {
+	stores := map[string]func() Store{
+		"LRU": func() Store { return NewLRU(100) },
+		"Redis": func() Store {
+			s, err := NewRedis(fakeRedis(t))
+			require.NoError(t, err)
+			return s
+		},
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			testRepository(t, store())
+		})
+	}
+}
+
+func testRepository(t *testing.T, store Store) {
+	ctx := context.Background()
+	require := require.New(t)
+	r := NewRepository(mock.NewRepository(), store, time.Minute)
+	defer r.Close()
+
+	u, err := r.CreateUser(ctx, "reader")
+	require.NoError(err)
+	f, err := r.CreateFeed(ctx, "Hot", "", nil)
+	require.NoError(err)
+
+	// Feeds are cached once read
+	_, err = r.GetFeed(ctx, f.ID)
+	require.NoError(err)
+	cached, err := r.GetFeed(ctx, f.ID)
+	require.NoError(err)
+	require.Equal(f, cached)
+	require.Equal(api.CacheQueryStats{Hits: 1, Misses: 1}, r.Stats().Queries[QueryGetFeed])
+	_, err = r.GetFeed(ctx, "missing")
+	require.Equal(db.ErrNoSuchFeed, err)
+
+	// Batches read the Feeds missing from the cache
+	other, err := r.CreateFeed(ctx, "Cold", "", nil)
+	require.NoError(err)
+	feeds, err := r.GetFeeds(ctx, []string{f.ID, other.ID, "missing"})
+	require.NoError(err)
+	require.ElementsMatch([]api.Feed{*f, *other}, feeds)
+	feeds, err = r.GetFeeds(ctx, []string{other.ID})
+	require.NoError(err)
+	require.Equal([]api.Feed{*other}, feeds)
+	require.Equal(api.CacheQueryStats{Hits: 3, Misses: 4}, r.Stats().Queries[QueryGetFeed])
+
+	// Article lists are invalidated by new Articles, for every filter
+	_, err = r.CreateFeedArticle(ctx, f.ID, api.Article{Title: "First", Body: "body", Tags: []string{"news"}})
+	require.NoError(err)
+	for i := 0; i < 2; i++ {
+		articles, err := r.ListFeedArticles(ctx, f.ID, db.ArticleFilter{})
+		require.NoError(err)
+		require.Len(articles, 1)
+		tagged, err := r.ListFeedArticles(ctx, f.ID, db.ArticleFilter{Tag: "news"})
+		require.NoError(err)
+		require.Len(tagged, 1)
+	}
+	require.Equal(api.CacheQueryStats{Hits: 2, Misses: 2}, r.Stats().Queries[QueryListFeedArticles])
+
+	_, err = r.CreateFeedArticles(ctx, f.ID, []api.Article{{Title: "Second", Body: "body", Tags: []string{"news"}}})
+	require.NoError(err)
+	articles, err := r.ListFeedArticles(ctx, f.ID, db.ArticleFilter{})
+	require.NoError(err)
+	require.Len(articles, 2)
+	tagged, err := r.ListFeedArticles(ctx, f.ID, db.ArticleFilter{Tag: "news"})
+	require.NoError(err)
+	require.Len(tagged, 2)
+
+	// Subscriptions are invalidated when they change, the Feeds listed are kept up to date
+	feeds, err = r.ListUserFeeds(ctx, u.ID)
+	require.NoError(err)
+	require.Empty(feeds)
+	require.NoError(r.AddUserFeed(ctx, u.ID, f.ID))
+	feeds, err = r.ListUserFeeds(ctx, u.ID)
+	require.NoError(err)
+	require.Len(feeds, 1)
+	require.NoError(r.SetFeedRetention(ctx, f.ID, &api.RetentionPolicy{MaxCount: 10}))
+	feeds, err = r.ListUserFeeds(ctx, u.ID)
+	require.NoError(err)
+	require.Equal(&api.RetentionPolicy{MaxCount: 10}, feeds[0].Retention)
+	require.NoError(r.RemoveUserFeed(ctx, u.ID, f.ID))
+	feeds, err = r.ListUserFeeds(ctx, u.ID)
+	require.NoError(err)
+	require.Empty(feeds)
+
+	stats := r.Stats()
+	require.Zero(stats.Errors)
+	require.Equal(stats.Queries[QueryGetFeed].Hits+stats.Queries[QueryListFeedArticles].Hits+stats.Queries[QueryListUserFeeds].Hits, stats.Hits)
+}
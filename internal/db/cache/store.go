@@ -0,0 +1,104 @@
+// Package cache implements a read-through caching db.Repository decorator, storing query results in an in-process
+// LRU or in Redis
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Store keeps cached values for a limited time
+type Store interface {
+	// Get returns the value stored under a key, false when there is none or it expired
+	Get(key string) ([]byte, bool, error)
+	// Set stores a value under a key for ttl
+	Set(key string, value []byte, ttl time.Duration) error
+	// Delete removes the values stored under keys, missing keys are ignored
+	Delete(keys ...string) error
+	// Close releases the resources held by the Store
+	Close() error
+}
+
+// lru is an in-process Store evicting the least recently used values beyond its size
+type lru struct {
+	size int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// order lists entries, most recently used first
+	order *list.List
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewLRU creates an in-process Store holding up to size values
+func NewLRU(size int) Store {
+	return &lru{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func (c *lru) Get(key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := e.Value.(*lruEntry)
+	if time.Now().After(entry.expires) {
+		c.remove(e)
+		return nil, false, nil
+	}
+	c.order.MoveToFront(e)
+	return entry.value, true, nil
+}
+
+func (c *lru) Set(key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := time.Now().Add(ttl)
+	if e, ok := c.entries[key]; ok {
+		entry := e.Value.(*lruEntry)
+		entry.value = value
+		entry.expires = expires
+		c.order.MoveToFront(e)
+		return nil
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+	return nil
+}
+
+func (c *lru) Delete(keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		if e, ok := c.entries[key]; ok {
+			c.remove(e)
+		}
+	}
+	return nil
+}
+
+func (c *lru) Close() error {
+	return nil
+}
+
+func (c *lru) remove(e *list.Element) {
+	c.order.Remove(e)
+	delete(c.entries, e.Value.(*lruEntry).key)
+}
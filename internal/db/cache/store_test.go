@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRU(t *testing.T) {
+	require := require.New(t)
+	s := NewLRU(2)
+
+	require.NoError(s.Set("a", []byte("1"), time.Minute))
+	require.NoError(s.Set("b", []byte("2"), time.Minute))
+	// Reading a makes b the least recently used value
+	v, ok, err := s.Get("a")
+	require.NoError(err)
+	require.True(ok)
+	require.Equal("1", string(v))
+	require.NoError(s.Set("c", []byte("3"), time.Minute))
+
+	_, ok, _ = s.Get("b")
+	require.False(ok)
+	_, ok, _ = s.Get("a")
+	require.True(ok)
+
+	require.NoError(s.Delete("a", "missing"))
+	_, ok, _ = s.Get("a")
+	require.False(ok)
+
+	require.NoError(s.Set("d", []byte("4"), time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	_, ok, _ = s.Get("d")
+	require.False(ok)
+}
+
+func TestRedis(t *testing.T) {
+	require := require.New(t)
+	url := fakeRedis(t)
+
+	s, err := NewRedis(url)
+	require.NoError(err)
+	defer s.Close()
+
+	_, ok, err := s.Get("a")
+	require.NoError(err)
+	require.False(ok)
+	require.NoError(s.Set("a", []byte("1"), time.Minute))
+	require.NoError(s.Set("b", []byte("2"), time.Millisecond))
+	v, ok, err := s.Get("a")
+	require.NoError(err)
+	require.True(ok)
+	require.Equal("1", string(v))
+
+	time.Sleep(5 * time.Millisecond)
+	_, ok, _ = s.Get("b")
+	require.False(ok)
+
+	require.NoError(s.Delete("a", "missing"))
+	_, ok, _ = s.Get("a")
+	require.False(ok)
+
+	_, err = NewRedis("redis://127.0.0.1:1")
+	require.Error(err)
+}
+
+// fakeRedis serves the subset of the Redis protocol the redis Store uses, returning its URL
+func fakeRedis(t testing.TB) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	var mu sync.Mutex
+	values := map[string]string{}
+	expires := map[string]time.Time{}
+
+	serve := func(conn net.Conn) {
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			args, err := readCommand(r)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			reply := "-ERR unknown command\r\n"
+			switch strings.ToUpper(args[0]) {
+			case "PING":
+				reply = "+PONG\r\n"
+			case "GET":
+				v, ok := values[args[1]]
+				if ok && time.Now().After(expires[args[1]]) {
+					ok = false
+				}
+				reply = "$-1\r\n"
+				if ok {
+					reply = fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+				}
+			case "SET":
+				ms, _ := strconv.Atoi(args[4])
+				values[args[1]] = args[2]
+				expires[args[1]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+				reply = "+OK\r\n"
+			case "DEL":
+				n := 0
+				for _, key := range args[1:] {
+					if _, ok := values[key]; ok {
+						delete(values, key)
+						n++
+					}
+				}
+				reply = fmt.Sprintf(":%d\r\n", n)
+			}
+			mu.Unlock()
+			if _, err := io.WriteString(conn, reply); err != nil {
+				return
+			}
+		}
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+	return "redis://" + l.Addr().String()
+}
+
+// readCommand reads a command sent as an array of bulk strings
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line)[1:])
+	if err != nil {
+		return nil, err
+	}
+	args := []string{}
+	for i := 0; i < n; i++ {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line)[1:])
+		if err != nil {
+			return nil, err
+		}
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(r, arg); err != nil {
+			return nil, err
+		}
+		args = append(args, string(arg[:size]))
+	}
+	return args, nil
+}
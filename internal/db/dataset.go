@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+)
+
+// FeedRecord is a Feed with the Users subscribed to it
+type FeedRecord struct {
+	Feed        api.Feed `json:"feed"`
+	Subscribers []string `json:"subscribers,omitempty"`
+}
+
+// ArticleRecord is an Article, in any status, with the state stored along with it
+type ArticleRecord struct {
+	Article api.Article `json:"article"`
+	// CreatedTime is when the Article was added to its Feed
+	CreatedTime time.Time `json:"created_at"`
+	// StarredBy lists the Users who starred the Article
+	StarredBy []string `json:"starred_by,omitempty"`
+}
+
+// FilterRuleRecord is a filter rule with the User it belongs to
+type FilterRuleRecord struct {
+	UserID string         `json:"user_id"`
+	Rule   api.FilterRule `json:"rule"`
+}
+
+// DatasetStore defines export of all Users, Feeds, subscriptions, Articles and filter rules, and their restoration
+// with the same IDs, e.g. to back up a repository or move it to another backend
+type DatasetStore interface {
+	// ExportUsers calls visit with every User, stopping at the first error
+	ExportUsers(ctx context.Context, visit func(api.User) error) error
+
+	// ExportFeeds calls visit with every Feed and its subscribers, stopping at the first error
+	ExportFeeds(ctx context.Context, visit func(FeedRecord) error) error
+
+	// ExportArticles calls visit with every Article, stopping at the first error
+	ExportArticles(ctx context.Context, visit func(ArticleRecord) error) error
+
+	// ExportFilterRules calls visit with every filter rule, stopping at the first error
+	ExportFilterRules(ctx context.Context, visit func(FilterRuleRecord) error) error
+
+	// RestoreUser stores a User with its ID. When the ID is taken the User replaces the existing one if replace is
+	// true, otherwise ErrRecordExists is returned.
+	RestoreUser(ctx context.Context, user api.User, replace bool) error
+
+	// RestoreFeed stores a Feed with its ID and subscribers, which must have been restored before
+	RestoreFeed(ctx context.Context, record FeedRecord, replace bool) error
+
+	// RestoreArticle stores an Article with its ID in its Feed, which must have been restored before
+	RestoreArticle(ctx context.Context, record ArticleRecord, replace bool) error
+
+	// RestoreFilterRule stores a filter rule with its ID for its User, who must have been restored before
+	RestoreFilterRule(ctx context.Context, record FilterRuleRecord, replace bool) error
+}
@@ -0,0 +1,29 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+)
+
+// DraftStore defines persistence of the draft and scheduled Articles of Feeds. Articles are added as drafts with
+// Repository.CreateFeedArticle and stay out of all Article listings and searches until published.
+type DraftStore interface {
+	// ListFeedDrafts returns the draft and scheduled Articles of a Feed, most recently updated first
+	ListFeedDrafts(ctx context.Context, feedID string) ([]api.Article, error)
+
+	// GetFeedDraft returns a draft or scheduled Article of a Feed, or ErrNoSuchDraft
+	GetFeedDraft(ctx context.Context, feedID string, articleID string) (*api.Article, error)
+
+	// UpdateFeedDraft replaces the contents, times and status of a draft or scheduled Article, returning the
+	// Article as stored. Drafts updated with StatusPublished are published. Fails with ErrArticlePublished for
+	// Articles published in the meantime.
+	UpdateFeedDraft(ctx context.Context, feedID string, article api.Article) (*api.Article, error)
+
+	// DeleteFeedDraft removes a draft or scheduled Article, failing with ErrArticlePublished for published Articles
+	DeleteFeedDraft(ctx context.Context, feedID string, articleID string) error
+
+	// PublishDueArticles publishes all scheduled Articles due by now, returning the Articles published
+	PublishDueArticles(ctx context.Context, now time.Time) ([]api.Article, error)
+}
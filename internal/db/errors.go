@@ -13,4 +13,18 @@ var (
 	ErrNoSuchFeed = errors.New("No feed with provided ID")
 	// ErrNotSubscribed is the error returned when a user does not have a feed among the ones they are subscribed to
 	ErrNotSubscribed = errors.New("User has no feed with provided ID")
+	// ErrIdempotencyKeyExists is the error returned when an idempotency key is already in use
+	ErrIdempotencyKeyExists = errors.New("Idempotency key already exists")
+	// ErrNoSuchIdempotencyKey is the error returned when an idempotency key is unknown or expired
+	ErrNoSuchIdempotencyKey = errors.New("No idempotency record with provided key")
+	// ErrNoSuchFilterRule is the error returned when a user does not have a filter rule
+	ErrNoSuchFilterRule = errors.New("User has no filter rule with provided ID")
+	// ErrNoSuchDraft is the error returned when a feed does not have a draft or scheduled article
+	ErrNoSuchDraft = errors.New("Feed has no draft with provided ID")
+	// ErrNoSuchArticle is the error returned when a published article does not exist
+	ErrNoSuchArticle = errors.New("No article with provided ID")
+	// ErrArticlePublished is the error returned when editing a draft that has already been published
+	ErrArticlePublished = errors.New("Article is already published")
+	// ErrRecordExists is the error returned when restoring a record with the ID of an existing one
+	ErrRecordExists = errors.New("Record with provided ID already exists")
 )
@@ -0,0 +1,24 @@
+package db
+
+import (
+	"context"
+	"github.com/if-ivan-else/tldrfeed/api"
+)
+
+// FilterRuleStore defines persistence of Users' filter rules
+type FilterRuleStore interface {
+	// CreateFilterRule adds a rule to a User's filter rules, assigning the rule's ID and creation time
+	CreateFilterRule(ctx context.Context, userID string, rule api.FilterRule) (*api.FilterRule, error)
+
+	// ListFilterRules returns a User's filter rules, oldest first
+	ListFilterRules(ctx context.Context, userID string) ([]api.FilterRule, error)
+
+	// GetFilterRule returns a User's filter rule, or ErrNoSuchFilterRule
+	GetFilterRule(ctx context.Context, userID string, ruleID string) (*api.FilterRule, error)
+
+	// UpdateFilterRule replaces the type, value and action of a User's filter rule
+	UpdateFilterRule(ctx context.Context, userID string, rule api.FilterRule) error
+
+	// DeleteFilterRule removes a User's filter rule
+	DeleteFilterRule(ctx context.Context, userID string, ruleID string) error
+}
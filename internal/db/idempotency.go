@@ -0,0 +1,46 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// IdempotencyRecord captures the first response to a request made with an idempotency key so it can be
+// replayed to retries of the same request
+type IdempotencyRecord struct {
+	// Key identifies the request, scoped to the route it was made to
+	Key string
+	// RequestHash is a digest of the request body the key was first used with
+	RequestHash string
+	// Status is the HTTP status of the response, 0 while the request is still being processed
+	Status int
+	// ContentType of the response
+	ContentType string
+	// Body of the response
+	Body []byte
+	// ExpiresTime is the time after which the record is discarded: the end of the lease of the request being
+	// processed, then of the retention of its response once completed
+	ExpiresTime time.Time
+}
+
+// Completed returns true once the response to the request has been stored
+func (r *IdempotencyRecord) Completed() bool {
+	return r.Status != 0
+}
+
+// IdempotencyStore defines persistence of idempotency records
+type IdempotencyStore interface {
+	// CreateIdempotencyRecord reserves a key for a request being processed,
+	// returning ErrIdempotencyKeyExists if an unexpired record with the same key exists
+	CreateIdempotencyRecord(ctx context.Context, record IdempotencyRecord) error
+
+	// GetIdempotencyRecord returns an unexpired record, or ErrNoSuchIdempotencyKey
+	GetIdempotencyRecord(ctx context.Context, key string) (*IdempotencyRecord, error)
+
+	// CompleteIdempotencyRecord stores the response to a reserved request, to be kept until expiresTime
+	CompleteIdempotencyRecord(ctx context.Context, key string, status int, contentType string, body []byte,
+		expiresTime time.Time) error
+
+	// DeleteIdempotencyRecord releases a key so that the request can be retried
+	DeleteIdempotencyRecord(ctx context.Context, key string) error
+}
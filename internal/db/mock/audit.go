@@ -0,0 +1,40 @@
+package mock
+
+import (
+	"context"
+	"sort"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+)
+
+func (r *repository) AppendAuditRecord(ctx context.Context, record api.AuditRecord) error {
+	r.audit = append(r.audit, record)
+	return nil
+}
+
+func (r *repository) ListAuditRecords(ctx context.Context, filter db.AuditFilter) ([]api.AuditRecord, error) {
+	records := []api.AuditRecord{}
+	for _, a := range r.audit {
+		switch {
+		case filter.EntityType != "" && a.EntityType != filter.EntityType:
+		case filter.EntityID != "" && a.EntityID != filter.EntityID:
+		case filter.Principal != "" && a.Principal != filter.Principal:
+		case filter.Action != "" && a.Action != filter.Action:
+		case !filter.Since.IsZero() && a.Time.Before(filter.Since):
+		case !filter.Until.IsZero() && !a.Time.Before(filter.Until):
+		default:
+			records = append(records, a)
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].Time.Equal(records[j].Time) {
+			return records[i].ID < records[j].ID
+		}
+		return records[i].Time.Before(records[j].Time)
+	})
+	if filter.Limit > 0 && len(records) > filter.Limit {
+		records = records[len(records)-filter.Limit:]
+	}
+	return records, nil
+}
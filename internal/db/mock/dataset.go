@@ -0,0 +1,175 @@
+package mock
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+	"github.com/if-ivan-else/tldrfeed/internal/dedup"
+	"github.com/if-ivan-else/tldrfeed/internal/markup"
+)
+
+func (r *repository) ExportUsers(ctx context.Context, visit func(api.User) error) error {
+	for _, u := range r.users {
+		if err := visit(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *repository) ExportFeeds(ctx context.Context, visit func(db.FeedRecord) error) error {
+	for _, f := range r.feeds {
+		record := db.FeedRecord{Feed: f}
+		for _, u := range r.users {
+			if _, err := r.GetUserFeed(ctx, u.ID, f.ID); err == nil {
+				record.Subscribers = append(record.Subscribers, u.ID)
+			}
+		}
+		if err := visit(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *repository) ExportArticles(ctx context.Context, visit func(db.ArticleRecord) error) error {
+	for _, f := range r.feeds {
+		for _, a := range r.feedArticles[f.ID] {
+			record := db.ArticleRecord{
+				Article:     a,
+				CreatedTime: r.createdTimes[a.ID],
+			}
+			for userID := range r.stars[a.ID] {
+				record.StarredBy = append(record.StarredBy, userID)
+			}
+			sort.Strings(record.StarredBy)
+			if err := visit(record); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *repository) ExportFilterRules(ctx context.Context, visit func(db.FilterRuleRecord) error) error {
+	for _, u := range r.users {
+		for _, rule := range r.filterRules[u.ID] {
+			if err := visit(db.FilterRuleRecord{UserID: u.ID, Rule: rule}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *repository) RestoreUser(ctx context.Context, user api.User, replace bool) error {
+	for i := range r.users {
+		if r.users[i].ID == user.ID {
+			if !replace {
+				return db.ErrRecordExists
+			}
+			r.users[i] = user
+			return nil
+		}
+	}
+	r.users = append(r.users, user)
+	r.userFeeds[user.ID] = []api.Feed{}
+	return nil
+}
+
+func (r *repository) RestoreFeed(ctx context.Context, record db.FeedRecord, replace bool) error {
+	f := record.Feed
+	if _, err := r.GetFeed(ctx, f.ID); err == nil {
+		if !replace {
+			return db.ErrRecordExists
+		}
+		for i := range r.feeds {
+			if r.feeds[i].ID == f.ID {
+				r.feeds[i] = f
+			}
+		}
+		// Subscriptions are replaced too
+		for userID := range r.userFeeds {
+			if _, err := r.GetUserFeed(ctx, userID, f.ID); err == nil {
+				_ = r.RemoveUserFeed(ctx, userID, f.ID)
+			}
+		}
+	} else {
+		r.feeds = append(r.feeds, f)
+		r.feedArticles[f.ID] = []api.Article{}
+	}
+
+	for _, userID := range record.Subscribers {
+		if err := r.AddUserFeed(ctx, userID, f.ID); err != nil {
+			return err
+		}
+	}
+	r.bumpFeedVersion(f.ID, time.Now())
+	return nil
+}
+
+func (r *repository) RestoreArticle(ctx context.Context, record db.ArticleRecord, replace bool) error {
+	a := record.Article
+	// Archives may come from anywhere, so HTML bodies are sanitized as when Articles are created
+	if a.BodyFormat == api.BodyHTML {
+		a.Body = markup.Sanitize(a.Body)
+	}
+	if _, ok := r.feedArticles[a.FeedID]; !ok {
+		return db.ErrNoSuchFeed
+	}
+	for feedID, articles := range r.feedArticles {
+		for i := range articles {
+			if articles[i].ID != a.ID {
+				continue
+			}
+			if !replace {
+				return db.ErrRecordExists
+			}
+			r.feedArticles[feedID] = append(articles[:i:i], articles[i+1:]...)
+			r.index.Remove(a.ID)
+			delete(r.stars, a.ID)
+			break
+		}
+	}
+
+	if a.ClusterID == "" {
+		a.ClusterID = a.ID
+	}
+	created := record.CreatedTime
+	if created.IsZero() {
+		created = time.Now()
+	}
+	r.feedArticles[a.FeedID] = append(r.feedArticles[a.FeedID], a)
+	r.createdTimes[a.ID] = created
+	r.fingerprints[a.ID] = dedup.Compute(&a)
+	if a.Status == api.StatusPublished {
+		r.index.Add(a.FeedID, a)
+	}
+	for _, userID := range record.StarredBy {
+		if r.stars[a.ID] == nil {
+			r.stars[a.ID] = map[string]bool{}
+		}
+		r.stars[a.ID][userID] = true
+	}
+	r.bumpFeedVersion(a.FeedID, time.Now())
+	return nil
+}
+
+func (r *repository) RestoreFilterRule(ctx context.Context, record db.FilterRuleRecord, replace bool) error {
+	i, err := r.findFilterRule(ctx, record.UserID, record.Rule.ID)
+	switch {
+	case err == nil && !replace:
+		return db.ErrRecordExists
+	case err == nil:
+		r.filterRules[record.UserID][i] = record.Rule
+		return nil
+	case err == db.ErrNoSuchFilterRule:
+		r.filterRules[record.UserID] = append(r.filterRules[record.UserID], record.Rule)
+		return nil
+	default:
+		return err
+	}
+}
@@ -1,9 +1,15 @@
 package mock
 
 import (
+	"context"
+	"sort"
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/if-ivan-else/tldrfeed/api"
 	"github.com/if-ivan-else/tldrfeed/internal/db"
+	"github.com/if-ivan-else/tldrfeed/internal/dedup"
+	"github.com/if-ivan-else/tldrfeed/internal/search"
 )
 
 // Repository is a mock repository implementation used in tests
@@ -13,6 +19,24 @@ type repository struct {
 
 	userFeeds    map[string][]api.Feed
 	feedArticles map[string][]api.Article
+	// createdTimes maps Article IDs to the times the Articles were added
+	createdTimes map[string]time.Time
+	// fingerprints maps Article IDs to the Fingerprints duplicates are detected with
+	fingerprints map[string]dedup.Fingerprint
+
+	feedVersions       map[string]db.FeedVersion
+	subscriptionsTimes map[string]time.Time
+
+	idempotency map[string]db.IdempotencyRecord
+
+	filterRules map[string][]api.FilterRule
+
+	// stars maps Article IDs to the Users who starred them
+	stars map[string]map[string]bool
+
+	audit []api.AuditRecord
+
+	index *search.Index
 }
 
 // NewRepository creates an instance of a mock repository for tests
@@ -20,10 +44,18 @@ func NewRepository() db.Repository {
 	r := &repository{}
 	r.userFeeds = make(map[string][]api.Feed)
 	r.feedArticles = make(map[string][]api.Article)
+	r.createdTimes = make(map[string]time.Time)
+	r.fingerprints = make(map[string]dedup.Fingerprint)
+	r.feedVersions = make(map[string]db.FeedVersion)
+	r.subscriptionsTimes = make(map[string]time.Time)
+	r.idempotency = make(map[string]db.IdempotencyRecord)
+	r.filterRules = make(map[string][]api.FilterRule)
+	r.stars = make(map[string]map[string]bool)
+	r.index = search.NewIndex()
 	return r
 }
 
-func (r *repository) CreateUser(name string) (*api.User, error) {
+func (r *repository) CreateUser(ctx context.Context, name string) (*api.User, error) {
 	u := api.User{
 		ID:   uuid.New().String(),
 		Name: name,
@@ -34,11 +66,33 @@ func (r *repository) CreateUser(name string) (*api.User, error) {
 	return &u, nil
 }
 
-func (r *repository) ListUsers() ([]api.User, error) {
-	return r.users, nil
+// ListUsers lists the Users in the order they were created
+func (r *repository) ListUsers(ctx context.Context, page db.Page) ([]api.User, error) {
+	start := 0
+	if page.After != "" {
+		start = -1
+		for i, u := range r.users {
+			if u.ID == page.After {
+				start = i + 1
+				break
+			}
+		}
+		if start < 0 {
+			return nil, db.ErrNoSuchUser
+		}
+	}
+	users := r.users[start:]
+	if page.Limit > 0 && len(users) > page.Limit {
+		users = users[:page.Limit]
+	}
+	return users, nil
 }
 
-func (r *repository) GetUser(userID string) (*api.User, error) {
+func (r *repository) GetUser(ctx context.Context, userID string) (*api.User, error) {
+	// Lookups fail once ctx is done, like the queries of real repositories
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	for _, u := range r.users {
 		if u.ID == userID {
 			return &u, nil
@@ -47,21 +101,36 @@ func (r *repository) GetUser(userID string) (*api.User, error) {
 	return nil, db.ErrNoSuchUser
 }
 
-func (r *repository) CreateFeed(name string) (*api.Feed, error) {
+func (r *repository) CreateFeed(ctx context.Context, name string, category string, retention *api.RetentionPolicy) (*api.Feed, error) {
 	f := api.Feed{
-		ID:   uuid.New().String(),
-		Name: name,
+		ID:        uuid.New().String(),
+		Name:      name,
+		Category:  category,
+		Retention: retention,
 	}
 	r.feeds = append(r.feeds, f)
 	r.feedArticles[f.ID] = []api.Article{}
+	r.bumpFeedVersion(f.ID, time.Now())
 	return &f, nil
 }
 
-func (r *repository) ListFeeds() ([]api.Feed, error) {
-	return r.feeds, nil
+func (r *repository) ListFeeds(ctx context.Context, filter db.FeedFilter) ([]api.Feed, error) {
+	if filter.Category == "" {
+		return r.feeds, nil
+	}
+	feeds := []api.Feed{}
+	for _, f := range r.feeds {
+		if f.Category == filter.Category {
+			feeds = append(feeds, f)
+		}
+	}
+	return feeds, nil
 }
 
-func (r *repository) GetFeed(feedID string) (*api.Feed, error) {
+func (r *repository) GetFeed(ctx context.Context, feedID string) (*api.Feed, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	for _, f := range r.feeds {
 		if f.ID == feedID {
 			return &f, nil
@@ -70,33 +139,264 @@ func (r *repository) GetFeed(feedID string) (*api.Feed, error) {
 	return nil, db.ErrNoSuchFeed
 }
 
-func (r *repository) ListFeedArticles(feedID string) ([]api.Article, error) {
+func (r *repository) GetFeeds(ctx context.Context, feedIDs []string) ([]api.Feed, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	feeds := []api.Feed{}
+	for _, id := range feedIDs {
+		for _, f := range r.feeds {
+			if f.ID == id {
+				feeds = append(feeds, f)
+				break
+			}
+		}
+	}
+	return feeds, nil
+}
+
+func (r *repository) ListFeedArticles(ctx context.Context, feedID string, filter db.ArticleFilter) ([]api.Article, error) {
 	articles, ok := r.feedArticles[feedID]
 	if !ok {
 		return nil, db.ErrNoSuchFeed
 	}
+	return filterArticles(feedID, articles, filter), nil
+}
+
+func (r *repository) ListArticlesOfFeeds(ctx context.Context, feedIDs []string, filter db.ArticleFilter, page db.Page) ([]api.Article, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var after *api.Article
+	if page.After != "" {
+		now := time.Now()
+		for _, id := range feedIDs {
+			for i, a := range r.feedArticles[id] {
+				if a.ID == page.After && visible(a, now) {
+					after = &r.feedArticles[id][i]
+				}
+			}
+		}
+		if after == nil {
+			return nil, db.ErrNoSuchArticle
+		}
+	}
+
+	articles := []api.Article{}
+	for _, id := range feedIDs {
+		feedArticles := r.feedArticles[id]
+		if after != nil {
+			feedArticles = following(feedArticles, after)
+		}
+		feedArticles = filterArticles(id, feedArticles, filter)
+		if page.Limit > 0 && len(feedArticles) > page.Limit {
+			feedArticles = feedArticles[:page.Limit]
+		}
+		articles = append(articles, feedArticles...)
+	}
+	return articles, nil
+}
+
+// following returns the Articles of a Feed listed after an Article: the ones following it in its own Feed and the
+// ones published before it in other Feeds
+func following(articles []api.Article, after *api.Article) []api.Article {
+	res := []api.Article{}
+	for i, a := range articles {
+		if a.ID == after.ID {
+			return articles[i+1:]
+		}
+		if a.PublishedTime.Before(after.PublishedTime) {
+			res = append(res, a)
+		}
+	}
+	return res
+}
+
+func filterArticles(feedID string, articles []api.Article, filter db.ArticleFilter) []api.Article {
+	now := time.Now()
+	filtered := []api.Article{}
+	for _, a := range articles {
+		if !visible(a, now) {
+			continue
+		}
+		if filter.Tag != "" && !hasTag(a, filter.Tag) {
+			continue
+		}
+		if filter.Rules.Hides(feedID, &a) {
+			continue
+		}
+		filtered = append(filtered, a)
+	}
+	return filtered
+}
+
+// visible returns true for published Articles and scheduled Articles due but not yet published by the scheduler
+func visible(a api.Article, now time.Time) bool {
+	switch a.Status {
+	case api.StatusDraft:
+		return false
+	case api.StatusScheduled:
+		return !a.PublishAt.After(now)
+	}
+	return true
+}
+
+func hasTag(a api.Article, tag string) bool {
+	for _, t := range a.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *repository) GetFeedArticles(ctx context.Context, feedID string, articleIDs []string) ([]api.Article, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	articles := []api.Article{}
+	for _, id := range articleIDs {
+		for _, a := range r.feedArticles[feedID] {
+			if a.ID == id {
+				articles = append(articles, a)
+				break
+			}
+		}
+	}
 	return articles, nil
 }
 
-func (r *repository) CreateFeedArticle(feedID string, articleTitle string, articleBody string) (articleID string, e error) {
+func (r *repository) CreateFeedArticle(ctx context.Context, feedID string, a api.Article) (articleID string, e error) {
+	ids, err := r.CreateFeedArticles(ctx, feedID, []api.Article{a})
+	if err != nil {
+		return "", err
+	}
+	return ids[0], nil
+}
 
-	a := api.Article{
-		ID:    uuid.New().String(),
-		Title: articleTitle,
-		Body:  articleBody,
+func (r *repository) CreateFeedArticles(ctx context.Context, feedID string, articles []api.Article) (articleIDs []string, e error) {
+	if _, ok := r.feedArticles[feedID]; !ok {
+		return nil, db.ErrNoSuchFeed
+	}
+	if len(articles) == 0 {
+		return []string{}, nil
 	}
 
+	now := time.Now()
+	ids := []string{}
+	for _, a := range articles {
+		a.ID = uuid.New().String()
+		if a.Status == "" {
+			a.Status = api.StatusPublished
+		}
+		// Drafts are given a publication time when published
+		if a.PublishedTime.IsZero() && a.Status != api.StatusDraft {
+			a.PublishedTime = now
+		}
+		if a.UpdatedTime.IsZero() {
+			a.UpdatedTime = a.PublishedTime
+		}
+
+		a.FeedID = feedID
+		fingerprint := dedup.Compute(&a)
+		a.ClusterID = r.findCluster(feedID, fingerprint, now)
+		if a.ClusterID == "" {
+			a.ClusterID = a.ID
+		}
+
+		r.feedArticles[feedID] = append(r.feedArticles[feedID], a)
+		r.createdTimes[a.ID] = now
+		r.fingerprints[a.ID] = fingerprint
+		if a.Status == api.StatusPublished {
+			r.index.Add(feedID, a)
+		}
+		ids = append(ids, a.ID)
+	}
+	r.bumpFeedVersion(feedID, now)
+	return ids, nil
+}
+
+// findCluster returns the cluster of the earliest duplicate of an Article added to other Feeds, if any. Drafts and
+// Articles past their Feed's maximum age are not duplicates.
+func (r *repository) findCluster(feedID string, fingerprint dedup.Fingerprint, now time.Time) string {
+	var cluster string
+	var earliest time.Time
+	for otherFeedID, articles := range r.feedArticles {
+		if otherFeedID == feedID {
+			continue
+		}
+		var cutoff time.Time
+		if f, err := r.GetFeed(context.Background(), otherFeedID); err == nil && f.Retention != nil && f.Retention.MaxAgeDays > 0 {
+			cutoff = now.AddDate(0, 0, -f.Retention.MaxAgeDays)
+		}
+		for _, a := range articles {
+			created := r.createdTimes[a.ID]
+			if a.Status == api.StatusDraft || a.PublishedTime.Before(cutoff) {
+				continue
+			}
+			if created.Before(now.Add(-dedup.Window)) || !fingerprint.Matches(r.fingerprints[a.ID]) {
+				continue
+			}
+			if cluster == "" || created.Before(earliest) {
+				cluster, earliest = a.ClusterID, created
+			}
+		}
+	}
+	return cluster
+}
+
+func (r *repository) bumpFeedVersion(feedID string, updated time.Time) {
+	v := r.feedVersions[feedID]
+	v.FeedID = feedID
+	v.Version++
+	if updated.After(v.UpdatedTime) {
+		v.UpdatedTime = updated
+	}
+	r.feedVersions[feedID] = v
+}
+
+func (r *repository) GetFeedVersion(ctx context.Context, feedID string) (*db.FeedVersion, error) {
+	v, ok := r.feedVersions[feedID]
+	if !ok {
+		return nil, db.ErrNoSuchFeed
+	}
+	return &v, nil
+}
+
+func (r *repository) GetTimelineVersion(ctx context.Context, userID string) (*db.TimelineVersion, error) {
+	feeds, err := r.ListUserFeeds(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &db.TimelineVersion{
+		UserID:                   userID,
+		SubscriptionsUpdatedTime: r.subscriptionsTimes[userID],
+		Feeds:                    []db.FeedVersion{},
+	}
+	for _, f := range feeds {
+		v.Feeds = append(v.Feeds, r.feedVersions[f.ID])
+	}
+	return v, nil
+}
+
+func (r *repository) CountFeedArticles(ctx context.Context, feedID string, since time.Time) (int, error) {
 	articles, ok := r.feedArticles[feedID]
 	if !ok {
-		return "", db.ErrNoSuchFeed
+		return 0, db.ErrNoSuchFeed
 	}
 
-	r.feedArticles[feedID] = append(articles, a)
-	return a.ID, nil
+	count := 0
+	for _, a := range articles {
+		if !r.createdTimes[a.ID].Before(since) {
+			count++
+		}
+	}
+	return count, nil
 }
 
-func (r *repository) AddUserFeed(userID string, feedID string) error {
-	f, err := r.GetFeed(feedID)
+func (r *repository) AddUserFeed(ctx context.Context, userID string, feedID string) error {
+	f, err := r.GetFeed(ctx, feedID)
 	if err != nil {
 		return err
 	}
@@ -107,11 +407,29 @@ func (r *repository) AddUserFeed(userID string, feedID string) error {
 	}
 
 	r.userFeeds[userID] = append(feeds, *f)
+	r.subscriptionsTimes[userID] = time.Now()
+
+	return nil
+}
+
+func (r *repository) RemoveUserFeed(ctx context.Context, userID string, feedID string) error {
+	if _, err := r.GetUserFeed(ctx, userID, feedID); err != nil {
+		return err
+	}
+
+	feeds := []api.Feed{}
+	for _, f := range r.userFeeds[userID] {
+		if f.ID != feedID {
+			feeds = append(feeds, f)
+		}
+	}
+	r.userFeeds[userID] = feeds
+	r.subscriptionsTimes[userID] = time.Now()
 
 	return nil
 }
 
-func (r *repository) ListUserFeeds(userID string) ([]api.Feed, error) {
+func (r *repository) ListUserFeeds(ctx context.Context, userID string) ([]api.Feed, error) {
 	feeds, ok := r.userFeeds[userID]
 	if !ok {
 		return nil, db.ErrNoSuchUser
@@ -119,7 +437,7 @@ func (r *repository) ListUserFeeds(userID string) ([]api.Feed, error) {
 	return feeds, nil
 }
 
-func (r *repository) GetUserFeed(userID string, feedID string) (*api.Feed, error) {
+func (r *repository) GetUserFeed(ctx context.Context, userID string, feedID string) (*api.Feed, error) {
 	feeds, ok := r.userFeeds[userID]
 	if !ok {
 		return nil, db.ErrNoSuchUser
@@ -132,8 +450,8 @@ func (r *repository) GetUserFeed(userID string, feedID string) (*api.Feed, error
 	return nil, db.ErrNotSubscribed
 }
 
-func (r *repository) ListUserArticles(userID string) ([]api.Article, error) {
-	feeds, err := r.ListUserFeeds(userID)
+func (r *repository) ListUserArticles(ctx context.Context, userID string, filter db.ArticleFilter) ([]api.Article, error) {
+	feeds, err := r.ListUserFeeds(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -142,26 +460,455 @@ func (r *repository) ListUserArticles(userID string) ([]api.Article, error) {
 	for _, f := range feeds {
 		articles, ok := r.feedArticles[f.ID]
 		if ok {
-			userArticles = append(userArticles, articles...)
+			userArticles = append(userArticles, filterArticles(f.ID, articles, filter)...)
 		}
 	}
 
 	return userArticles, nil
 }
 
-func (r *repository) ListUserFeedArticles(userID string, feedID string) ([]api.Article, error) {
-	feeds, err := r.ListUserFeeds(userID)
+func (r *repository) ListUserFeedArticles(ctx context.Context, userID string, feedID string, filter db.ArticleFilter) ([]api.Article, error) {
+	feeds, err := r.ListUserFeeds(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
 
 	for _, f := range feeds {
 		if f.ID == feedID {
-			return r.ListFeedArticles(feedID)
+			return r.ListFeedArticles(ctx, feedID, filter)
 		}
 	}
 	return nil, db.ErrNotSubscribed
 }
 
+func (r *repository) ListTags(ctx context.Context) ([]api.TagCount, error) {
+	now := time.Now()
+	counts := map[string]int{}
+	for _, articles := range r.feedArticles {
+		for _, a := range articles {
+			if !visible(a, now) {
+				continue
+			}
+			for _, t := range a.Tags {
+				counts[t]++
+			}
+		}
+	}
+
+	tags := []api.TagCount{}
+	for t, n := range counts {
+		tags = append(tags, api.TagCount{Tag: t, Articles: n})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Articles != tags[j].Articles {
+			return tags[i].Articles > tags[j].Articles
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+	return tags, nil
+}
+
+func (r *repository) DiscoverFeeds(ctx context.Context, userID string, category string) ([]db.FeedStats, error) {
+	if _, err := r.GetUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	subscribers := map[string]int{}
+	for _, feeds := range r.userFeeds {
+		for _, f := range feeds {
+			subscribers[f.ID]++
+		}
+	}
+
+	stats := []db.FeedStats{}
+	for _, f := range r.feeds {
+		if _, err := r.GetUserFeed(ctx, userID, f.ID); err == nil {
+			continue
+		}
+		if category != "" && f.Category != category {
+			continue
+		}
+		stats = append(stats, db.FeedStats{
+			Feed:        f,
+			Subscribers: subscribers[f.ID],
+			UpdatedTime: r.feedVersions[f.ID].UpdatedTime,
+		})
+	}
+	return stats, nil
+}
+
+func (r *repository) SearchArticles(ctx context.Context, query *search.Query, feedIDs []string, offset int, limit int) (*search.Results, error) {
+	return r.index.Search(query, feedIDs, offset, limit), nil
+}
+
+func (r *repository) ListFeedDrafts(ctx context.Context, feedID string) ([]api.Article, error) {
+	articles, ok := r.feedArticles[feedID]
+	if !ok {
+		return nil, db.ErrNoSuchFeed
+	}
+	drafts := []api.Article{}
+	for _, a := range articles {
+		if a.Status != api.StatusPublished {
+			drafts = append(drafts, a)
+		}
+	}
+	sort.SliceStable(drafts, func(i, j int) bool { return drafts[i].UpdatedTime.After(drafts[j].UpdatedTime) })
+	return drafts, nil
+}
+
+func (r *repository) GetFeedDraft(ctx context.Context, feedID string, articleID string) (*api.Article, error) {
+	i, err := r.findDraft(feedID, articleID)
+	if err == db.ErrArticlePublished {
+		return nil, db.ErrNoSuchDraft
+	}
+	if err != nil {
+		return nil, err
+	}
+	a := r.feedArticles[feedID][i]
+	return &a, nil
+}
+
+func (r *repository) UpdateFeedDraft(ctx context.Context, feedID string, article api.Article) (*api.Article, error) {
+	i, err := r.findDraft(feedID, article.ID)
+	if err != nil {
+		return nil, err
+	}
+	stored := r.feedArticles[feedID][i]
+	article.FeedID, article.ClusterID = stored.FeedID, stored.ClusterID
+	if article.Status == "" {
+		article.Status = api.StatusPublished
+	}
+
+	r.feedArticles[feedID][i] = article
+	r.fingerprints[article.ID] = dedup.Compute(&article)
+	if article.Status == api.StatusPublished {
+		r.index.Add(feedID, article)
+	}
+	r.bumpFeedVersion(feedID, time.Now())
+	return &article, nil
+}
+
+func (r *repository) DeleteFeedDraft(ctx context.Context, feedID string, articleID string) error {
+	i, err := r.findDraft(feedID, articleID)
+	if err != nil {
+		return err
+	}
+	articles := r.feedArticles[feedID]
+	r.feedArticles[feedID] = append(articles[:i:i], articles[i+1:]...)
+	delete(r.createdTimes, articleID)
+	delete(r.fingerprints, articleID)
+	r.bumpFeedVersion(feedID, time.Now())
+	return nil
+}
+
+func (r *repository) PublishDueArticles(ctx context.Context, now time.Time) ([]api.Article, error) {
+	published := []api.Article{}
+	for feedID, articles := range r.feedArticles {
+		for i, a := range articles {
+			if a.Status != api.StatusScheduled || a.PublishAt.After(now) {
+				continue
+			}
+			a.Status = api.StatusPublished
+			articles[i] = a
+			r.index.Add(feedID, a)
+			r.bumpFeedVersion(feedID, now)
+			published = append(published, a)
+		}
+	}
+	return published, nil
+}
+
+// findDraft returns the position of an unpublished Article among the Articles of a Feed
+func (r *repository) findDraft(feedID string, articleID string) (int, error) {
+	articles, ok := r.feedArticles[feedID]
+	if !ok {
+		return 0, db.ErrNoSuchFeed
+	}
+	for i, a := range articles {
+		if a.ID != articleID {
+			continue
+		}
+		if a.Status == api.StatusPublished {
+			return 0, db.ErrArticlePublished
+		}
+		return i, nil
+	}
+	return 0, db.ErrNoSuchDraft
+}
+
+func (r *repository) SetFeedRetention(ctx context.Context, feedID string, policy *api.RetentionPolicy) error {
+	if _, err := r.GetFeed(ctx, feedID); err != nil {
+		return err
+	}
+	for i := range r.feeds {
+		if r.feeds[i].ID == feedID {
+			r.feeds[i].Retention = policy
+		}
+	}
+	for _, feeds := range r.userFeeds {
+		for i := range feeds {
+			if feeds[i].ID == feedID {
+				feeds[i].Retention = policy
+			}
+		}
+	}
+	r.bumpFeedVersion(feedID, time.Now())
+	return nil
+}
+
+func (r *repository) ReportExpiredArticles(ctx context.Context, feedID string, policy api.RetentionPolicy, now time.Time) (*api.RetentionReport, error) {
+	articles, ok := r.feedArticles[feedID]
+	if !ok {
+		return nil, db.ErrNoSuchFeed
+	}
+
+	report := &api.RetentionReport{
+		FeedID:  feedID,
+		Policy:  policy,
+		Expired: []api.ExpiredArticle{},
+		Starred: []api.ExpiredArticle{},
+	}
+	if policy.Empty() {
+		return report, nil
+	}
+
+	published := []api.Article{}
+	for _, a := range articles {
+		if a.Status == api.StatusPublished {
+			published = append(published, a)
+		}
+	}
+	sort.SliceStable(published, func(i, j int) bool { return published[i].PublishedTime.After(published[j].PublishedTime) })
+
+	cutoff := now.AddDate(0, 0, -policy.MaxAgeDays)
+	// Walk from the oldest Article so that reports list the oldest first
+	for i := len(published) - 1; i >= 0; i-- {
+		a := published[i]
+		expired := api.ExpiredArticle{ID: a.ID, Title: a.Title, PublishedTime: a.PublishedTime}
+		switch {
+		case policy.MaxAgeDays > 0 && a.PublishedTime.Before(cutoff):
+			expired.Reason = api.ExpiryMaxAge
+		case policy.MaxCount > 0 && i >= policy.MaxCount:
+			expired.Reason = api.ExpiryMaxCount
+		default:
+			continue
+		}
+		if len(r.stars[a.ID]) > 0 {
+			report.Starred = append(report.Starred, expired)
+		} else {
+			report.Expired = append(report.Expired, expired)
+		}
+	}
+	return report, nil
+}
+
+func (r *repository) ExpireFeedArticles(ctx context.Context, feedID string, now time.Time) (int, error) {
+	f, err := r.GetFeed(ctx, feedID)
+	if err != nil {
+		return 0, err
+	}
+	if f.Retention == nil {
+		return 0, nil
+	}
+	report, err := r.ReportExpiredArticles(ctx, feedID, *f.Retention, now)
+	if err != nil || len(report.Expired) == 0 {
+		return 0, err
+	}
+
+	expired := map[string]bool{}
+	for _, e := range report.Expired {
+		expired[e.ID] = true
+	}
+	kept := []api.Article{}
+	for _, a := range r.feedArticles[feedID] {
+		if !expired[a.ID] {
+			kept = append(kept, a)
+			continue
+		}
+		delete(r.createdTimes, a.ID)
+		delete(r.fingerprints, a.ID)
+		r.index.Remove(a.ID)
+	}
+	r.feedArticles[feedID] = kept
+	r.bumpFeedVersion(feedID, now)
+	return len(expired), nil
+}
+
+func (r *repository) StarArticle(ctx context.Context, userID string, articleID string) error {
+	if _, err := r.GetUser(ctx, userID); err != nil {
+		return err
+	}
+	if _, err := r.findArticle(articleID); err != nil {
+		return err
+	}
+	if r.stars[articleID] == nil {
+		r.stars[articleID] = map[string]bool{}
+	}
+	r.stars[articleID][userID] = true
+	return nil
+}
+
+func (r *repository) UnstarArticle(ctx context.Context, userID string, articleID string) error {
+	if _, err := r.GetUser(ctx, userID); err != nil {
+		return err
+	}
+	if _, err := r.findArticle(articleID); err != nil {
+		return err
+	}
+	delete(r.stars[articleID], userID)
+	return nil
+}
+
+func (r *repository) ListStarredArticles(ctx context.Context, userID string) ([]api.Article, error) {
+	if _, err := r.GetUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	starred := []api.Article{}
+	for _, articles := range r.feedArticles {
+		for _, a := range articles {
+			if r.stars[a.ID][userID] {
+				starred = append(starred, a)
+			}
+		}
+	}
+	sort.SliceStable(starred, func(i, j int) bool { return starred[i].PublishedTime.After(starred[j].PublishedTime) })
+	return starred, nil
+}
+
+func (r *repository) ListUnsummarizedArticles(ctx context.Context, afterID string, limit int) ([]api.Article, error) {
+	unsummarized := []api.Article{}
+	for _, articles := range r.feedArticles {
+		for _, a := range articles {
+			if a.Summary == "" && a.ID > afterID {
+				unsummarized = append(unsummarized, a)
+			}
+		}
+	}
+	sort.Slice(unsummarized, func(i, j int) bool { return unsummarized[i].ID < unsummarized[j].ID })
+	if len(unsummarized) > limit {
+		unsummarized = unsummarized[:limit]
+	}
+	return unsummarized, nil
+}
+
+func (r *repository) SetArticleSummary(ctx context.Context, feedID string, articleID string, summary string) error {
+	articles := r.feedArticles[feedID]
+	for i := range articles {
+		if articles[i].ID == articleID {
+			articles[i].Summary = summary
+			return nil
+		}
+	}
+	return db.ErrNoSuchArticle
+}
+
+// findArticle returns a published Article of any Feed
+func (r *repository) findArticle(articleID string) (*api.Article, error) {
+	for _, articles := range r.feedArticles {
+		for _, a := range articles {
+			if a.ID == articleID && a.Status == api.StatusPublished {
+				return &a, nil
+			}
+		}
+	}
+	return nil, db.ErrNoSuchArticle
+}
+
+func (r *repository) CreateIdempotencyRecord(ctx context.Context, record db.IdempotencyRecord) error {
+	if _, err := r.GetIdempotencyRecord(ctx, record.Key); err == nil {
+		return db.ErrIdempotencyKeyExists
+	}
+	r.idempotency[record.Key] = record
+	return nil
+}
+
+func (r *repository) GetIdempotencyRecord(ctx context.Context, key string) (*db.IdempotencyRecord, error) {
+	record, ok := r.idempotency[key]
+	if !ok || record.ExpiresTime.Before(time.Now()) {
+		return nil, db.ErrNoSuchIdempotencyKey
+	}
+	return &record, nil
+}
+
+func (r *repository) CompleteIdempotencyRecord(ctx context.Context, key string, status int, contentType string, body []byte,
+	expiresTime time.Time) error {
+	record, ok := r.idempotency[key]
+	if !ok {
+		return db.ErrNoSuchIdempotencyKey
+	}
+	record.Status = status
+	record.ContentType = contentType
+	record.Body = body
+	record.ExpiresTime = expiresTime
+	r.idempotency[key] = record
+	return nil
+}
+
+func (r *repository) DeleteIdempotencyRecord(ctx context.Context, key string) error {
+	delete(r.idempotency, key)
+	return nil
+}
+
+func (r *repository) CreateFilterRule(ctx context.Context, userID string, rule api.FilterRule) (*api.FilterRule, error) {
+	if _, err := r.GetUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	rule.ID = uuid.New().String()
+	rule.CreatedTime = time.Now()
+	r.filterRules[userID] = append(r.filterRules[userID], rule)
+	return &rule, nil
+}
+
+func (r *repository) ListFilterRules(ctx context.Context, userID string) ([]api.FilterRule, error) {
+	if _, err := r.GetUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	rules := []api.FilterRule{}
+	return append(rules, r.filterRules[userID]...), nil
+}
+
+func (r *repository) GetFilterRule(ctx context.Context, userID string, ruleID string) (*api.FilterRule, error) {
+	i, err := r.findFilterRule(ctx, userID, ruleID)
+	if err != nil {
+		return nil, err
+	}
+	rule := r.filterRules[userID][i]
+	return &rule, nil
+}
+
+func (r *repository) UpdateFilterRule(ctx context.Context, userID string, rule api.FilterRule) error {
+	i, err := r.findFilterRule(ctx, userID, rule.ID)
+	if err != nil {
+		return err
+	}
+	stored := &r.filterRules[userID][i]
+	stored.Type = rule.Type
+	stored.Value = rule.Value
+	stored.Action = rule.Action
+	return nil
+}
+
+func (r *repository) DeleteFilterRule(ctx context.Context, userID string, ruleID string) error {
+	i, err := r.findFilterRule(ctx, userID, ruleID)
+	if err != nil {
+		return err
+	}
+	rules := r.filterRules[userID]
+	r.filterRules[userID] = append(rules[:i:i], rules[i+1:]...)
+	return nil
+}
+
+func (r *repository) findFilterRule(ctx context.Context, userID string, ruleID string) (int, error) {
+	if _, err := r.GetUser(ctx, userID); err != nil {
+		return 0, err
+	}
+	for i, rule := range r.filterRules[userID] {
+		if rule.ID == ruleID {
+			return i, nil
+		}
+	}
+	return 0, db.ErrNoSuchFilterRule
+}
+
 func (r *repository) Close() {
 }
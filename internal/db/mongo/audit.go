@@ -0,0 +1,113 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+)
+
+// AuditCollection contains the audit log of changes
+const AuditCollection = "audit"
+
+// AuditRecord is a Mongo document recording a change, the payloads are stored as the JSON they are given in
+type AuditRecord struct {
+	ID         string    `bson:"_id"`
+	Time       time.Time `bson:"time"`
+	Principal  string    `bson:"principal"`
+	RequestID  string    `bson:"request_id,omitempty"`
+	Action     string    `bson:"action"`
+	EntityType string    `bson:"entity_type"`
+	EntityID   string    `bson:"entity_id"`
+	Before     string    `bson:"before,omitempty"`
+	After      string    `bson:"after,omitempty"`
+}
+
+func newAuditRecord(r *api.AuditRecord) *AuditRecord {
+	return &AuditRecord{
+		ID:         r.ID,
+		Time:       r.Time,
+		Principal:  r.Principal,
+		RequestID:  r.RequestID,
+		Action:     r.Action,
+		EntityType: r.EntityType,
+		EntityID:   r.EntityID,
+		Before:     string(r.Before),
+		After:      string(r.After),
+	}
+}
+
+func (r *AuditRecord) toAPI() *api.AuditRecord {
+	record := &api.AuditRecord{
+		ID:         r.ID,
+		Time:       r.Time,
+		Principal:  r.Principal,
+		RequestID:  r.RequestID,
+		Action:     r.Action,
+		EntityType: r.EntityType,
+		EntityID:   r.EntityID,
+	}
+	if r.Before != "" {
+		record.Before = []byte(r.Before)
+	}
+	if r.After != "" {
+		record.After = []byte(r.After)
+	}
+	return record
+}
+
+func (s *session) audit() *mgo.Collection {
+	return s.collection(AuditCollection)
+}
+
+func (r *repository) AppendAuditRecord(ctx context.Context, record api.AuditRecord) error {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	return s.audit().Insert(newAuditRecord(&record))
+}
+
+func (r *repository) ListAuditRecords(ctx context.Context, filter db.AuditFilter) ([]api.AuditRecord, error) {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	selector := bson.M{}
+	for field, value := range map[string]string{
+		"entity_type": filter.EntityType,
+		"entity_id":   filter.EntityID,
+		"principal":   filter.Principal,
+		"action":      filter.Action,
+	} {
+		if value != "" {
+			selector[field] = value
+		}
+	}
+	times := bson.M{}
+	if !filter.Since.IsZero() {
+		times["$gte"] = filter.Since
+	}
+	if !filter.Until.IsZero() {
+		times["$lt"] = filter.Until
+	}
+	if len(times) > 0 {
+		selector["time"] = times
+	}
+
+	// The latest records are found newest first and put back in order
+	found := []AuditRecord{}
+	q := s.audit().Find(selector).Sort("-time", "-_id")
+	if filter.Limit > 0 {
+		q = q.Limit(filter.Limit)
+	}
+	if err := s.bounded(q).All(&found); err != nil {
+		return nil, s.err(err)
+	}
+	records := []api.AuditRecord{}
+	for i := len(found) - 1; i >= 0; i-- {
+		records = append(records, *found[i].toAPI())
+	}
+	return records, nil
+}
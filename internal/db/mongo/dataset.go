@@ -0,0 +1,231 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+	"github.com/if-ivan-else/tldrfeed/internal/markup"
+)
+
+func (r *repository) ExportUsers(ctx context.Context, visit func(api.User) error) error {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	iter := s.users().Find(nil).Iter()
+	for u := (User{}); iter.Next(&u); u = (User{}) {
+		if err := s.ctx.Err(); err != nil {
+			iter.Close()
+			return err
+		}
+		if err := visit(*u.toAPI()); err != nil {
+			iter.Close()
+			return err
+		}
+	}
+	return iter.Close()
+}
+
+func (r *repository) ExportFeeds(ctx context.Context, visit func(db.FeedRecord) error) error {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	iter := s.feeds().Find(nil).Iter()
+	for f := (Feed{}); iter.Next(&f); f = (Feed{}) {
+		if err := s.ctx.Err(); err != nil {
+			iter.Close()
+			return err
+		}
+		if err := visit(db.FeedRecord{Feed: *f.toAPI(), Subscribers: f.Users}); err != nil {
+			iter.Close()
+			return err
+		}
+	}
+	return iter.Close()
+}
+
+func (r *repository) ExportArticles(ctx context.Context, visit func(db.ArticleRecord) error) error {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	iter := s.articles().Find(nil).Iter()
+	for a := (Article{}); iter.Next(&a); a = (Article{}) {
+		if err := s.ctx.Err(); err != nil {
+			iter.Close()
+			return err
+		}
+		record := db.ArticleRecord{
+			Article:     *a.toAPI(),
+			CreatedTime: a.CreatedTime,
+			StarredBy:   a.StarredBy,
+		}
+		if err := visit(record); err != nil {
+			iter.Close()
+			return err
+		}
+	}
+	return iter.Close()
+}
+
+func (r *repository) ExportFilterRules(ctx context.Context, visit func(db.FilterRuleRecord) error) error {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	iter := s.filterRules().Find(nil).Iter()
+	for rule := (FilterRule{}); iter.Next(&rule); rule = (FilterRule{}) {
+		if err := s.ctx.Err(); err != nil {
+			iter.Close()
+			return err
+		}
+		if err := visit(db.FilterRuleRecord{UserID: rule.UserID, Rule: *rule.toAPI()}); err != nil {
+			iter.Close()
+			return err
+		}
+	}
+	return iter.Close()
+}
+
+// restore inserts a document, or replaces the document with the same ID if replace is true
+func restore(c *mgo.Collection, id string, doc interface{}, replace bool) error {
+	if replace {
+		_, err := c.UpsertId(id, doc)
+		return err
+	}
+	if err := c.Insert(doc); err != nil {
+		if mgo.IsDup(err) {
+			return db.ErrRecordExists
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *repository) RestoreUser(ctx context.Context, user api.User, replace bool) error {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	u := &User{
+		ID:                       user.ID,
+		Name:                     user.Name,
+		SubscriptionsUpdatedTime: time.Now(),
+	}
+	return restore(s.users(), u.ID, u, replace)
+}
+
+func (r *repository) RestoreFeed(ctx context.Context, record db.FeedRecord, replace bool) error {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	now := time.Now()
+	f := &Feed{
+		ID:          record.Feed.ID,
+		Name:        record.Feed.Name,
+		Category:    record.Feed.Category,
+		Users:       record.Subscribers,
+		Version:     1,
+		UpdatedTime: now,
+		Retention:   newRetention(record.Feed.Retention),
+	}
+	if f.Users == nil {
+		f.Users = []string{}
+	}
+	// A replaced Feed gets a newer version so that clients do not mistake it for the Feed they cached
+	existing, err := r.getFeed(s, f.ID)
+	switch {
+	case err == db.ErrNoSuchFeed:
+	case err != nil:
+		return err
+	case !replace:
+		return db.ErrRecordExists
+	default:
+		f.Version = existing.Version + 1
+	}
+	if err := restore(s.feeds(), f.ID, f, true); err != nil {
+		return err
+	}
+
+	// Users unsubscribed by the replacement see their subscriptions change as much as the restored subscribers
+	changed := append([]string{}, f.Users...)
+	if existing != nil {
+		changed = append(changed, existing.Users...)
+	}
+	if len(changed) > 0 {
+		selector := bson.M{"_id": bson.M{"$in": changed}}
+		if _, err := s.users().UpdateAll(selector, bson.M{"$set": bson.M{"subscriptions_updated_at": now}}); err != nil {
+			return err
+		}
+	}
+	if !r.timelines {
+		return nil
+	}
+	if _, err := s.timelines().RemoveAll(bson.M{"feed_id": f.ID}); err != nil {
+		return err
+	}
+	if err := r.updateFanOut(s, f); err != nil {
+		return err
+	}
+	for _, userID := range f.Users {
+		if err := r.backfillTimeline(s, userID, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *repository) RestoreArticle(ctx context.Context, record db.ArticleRecord, replace bool) error {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	f, err := r.getFeed(s, record.Article.FeedID)
+	if err != nil {
+		return err
+	}
+
+	created := record.CreatedTime
+	if created.IsZero() {
+		created = time.Now()
+	}
+	// Archives may come from anywhere, so HTML bodies are sanitized as when Articles are created
+	if record.Article.BodyFormat == api.BodyHTML {
+		record.Article.Body = markup.Sanitize(record.Article.Body)
+	}
+	a := newArticle(f.ID, record.Article, created)
+	a.ID = record.Article.ID
+	// Articles in clusters of their own are stored without one
+	if record.Article.ClusterID != record.Article.ID {
+		a.ClusterID = record.Article.ClusterID
+	}
+	a.StarredBy = record.StarredBy
+
+	if err := restore(s.articles(), a.ID, a, replace); err != nil {
+		return err
+	}
+	if err := r.removeFromTimelines(s, a.ID); err != nil {
+		return err
+	}
+	if err := r.fanOut(s, f.ID, a); err != nil {
+		return err
+	}
+	return r.bumpFeedVersion(s, f.ID, time.Now())
+}
+
+func (r *repository) RestoreFilterRule(ctx context.Context, record db.FilterRuleRecord, replace bool) error {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	if _, err := r.getUser(s, record.UserID); err != nil {
+		return err
+	}
+	rule := &FilterRule{
+		ID:          record.Rule.ID,
+		UserID:      record.UserID,
+		Type:        record.Rule.Type,
+		Value:       record.Rule.Value,
+		Action:      record.Rule.Action,
+		CreatedTime: record.Rule.CreatedTime,
+	}
+	return restore(s.filterRules(), rule.ID, rule, replace)
+}
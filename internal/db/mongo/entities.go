@@ -3,13 +3,17 @@ package mongo
 import (
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+	"github.com/if-ivan-else/tldrfeed/internal/dedup"
 )
 
 // User is a Mongo document to store user records
 type User struct {
-	ID   string `bson:"_id"`
-	Name string `bson:"name"`
+	ID                       string    `bson:"_id"`
+	Name                     string    `bson:"name"`
+	SubscriptionsUpdatedTime time.Time `bson:"subscriptions_updated_at,omitempty"`
 }
 
 func (u *User) toAPI() *api.User {
@@ -32,15 +36,78 @@ func (l UserList) toAPI() []api.User {
 
 // Feed is a Mongo document to store feed records
 type Feed struct {
-	ID    string   `bson:"_id"`
-	Name  string   `bson:"title"`
-	Users []string `bson:"users"`
+	ID       string   `bson:"_id"`
+	Name     string   `bson:"title"`
+	Category string   `bson:"category,omitempty"`
+	Users    []string `bson:"users"`
+	// Version and UpdatedTime change with every change to the Feed or its Articles
+	Version     int64      `bson:"version"`
+	UpdatedTime time.Time  `bson:"updated_at"`
+	Retention   *Retention `bson:"retention,omitempty"`
+	// FanOutOnRead is set once a Feed has too many subscribers for its Articles to be copied to their timelines
+	FanOutOnRead bool `bson:"fan_out_on_read,omitempty"`
 }
 
 func (f *Feed) toAPI() *api.Feed {
-	return &api.Feed{
-		ID:   f.ID,
-		Name: f.Name,
+	feed := &api.Feed{
+		ID:       f.ID,
+		Name:     f.Name,
+		Category: f.Category,
+	}
+	if f.Retention != nil {
+		feed.Retention = f.Retention.toAPI()
+	}
+	return feed
+}
+
+// Retention is the retention policy of a Feed
+type Retention struct {
+	MaxAgeDays int `bson:"max_age_days,omitempty"`
+	MaxCount   int `bson:"max_count,omitempty"`
+}
+
+func newRetention(p *api.RetentionPolicy) *Retention {
+	if p == nil {
+		return nil
+	}
+	return &Retention{
+		MaxAgeDays: p.MaxAgeDays,
+		MaxCount:   p.MaxCount,
+	}
+}
+
+func (r *Retention) toAPI() *api.RetentionPolicy {
+	return &api.RetentionPolicy{
+		MaxAgeDays: r.MaxAgeDays,
+		MaxCount:   r.MaxCount,
+	}
+}
+
+// FeedStats is the result of aggregating Feed popularity
+type FeedStats struct {
+	Feed        `bson:",inline"`
+	Subscribers int `bson:"subscribers"`
+}
+
+func (f *FeedStats) toDB() db.FeedStats {
+	return db.FeedStats{
+		Feed:        *f.toAPI(),
+		Subscribers: f.Subscribers,
+		UpdatedTime: f.UpdatedTime,
+	}
+}
+
+// TagCount is the result of aggregating Article tags
+type TagCount struct {
+	Tag      string `bson:"_id"`
+	Articles int    `bson:"articles"`
+}
+
+func (f *Feed) toVersion() *db.FeedVersion {
+	return &db.FeedVersion{
+		FeedID:      f.ID,
+		Version:     f.Version,
+		UpdatedTime: f.UpdatedTime,
 	}
 }
 
@@ -61,15 +128,105 @@ type Article struct {
 	FeedID        string    `bson:"feed_id"`
 	Title         string    `bson:"title"`
 	Body          string    `bson:"body"`
+	BodyFormat    string    `bson:"body_format,omitempty"`
+	Summary       string    `bson:"summary,omitempty"`
+	Author        string    `bson:"author,omitempty"`
+	URL           string    `bson:"url,omitempty"`
+	ImageURL      string    `bson:"image_url,omitempty"`
+	Tags          []string  `bson:"tags,omitempty"`
 	PublishedTime time.Time `bson:"published_at"`
+	UpdatedTime   time.Time `bson:"updated_at,omitempty"`
+	// CreatedTime is when the Article was added to the Feed, it differs from PublishedTime for backfilled Articles
+	CreatedTime time.Time `bson:"created_at,omitempty"`
+	// ClusterID groups near-duplicates, Articles stored before duplicate detection are in clusters of their own
+	ClusterID string `bson:"cluster_id,omitempty"`
+	// URLKey, SimHash and SimHashBands make up the dedup.Fingerprint of the Article, bands are indexed to look up
+	// near-duplicates
+	URLKey       string   `bson:"url_key,omitempty"`
+	SimHash      int64    `bson:"simhash,omitempty"`
+	SimHashBands []string `bson:"simhash_bands,omitempty"`
+	// Status is empty for Articles stored before drafts were introduced, which are all published
+	Status    string     `bson:"status,omitempty"`
+	PublishAt *time.Time `bson:"publish_at,omitempty"`
+	// StarredBy lists the Users who starred the Article, starred Articles never expire
+	StarredBy []string `bson:"starred_by,omitempty"`
+}
+
+// newArticle creates an Article document with a new ID for an Article added to a Feed at the given time
+func newArticle(feedID string, a api.Article, now time.Time) *Article {
+	article := &Article{
+		ID:            uuid.New().String(),
+		FeedID:        feedID,
+		Title:         a.Title,
+		Body:          a.Body,
+		BodyFormat:    a.BodyFormat,
+		Summary:       a.Summary,
+		Author:        a.Author,
+		URL:           a.URL,
+		ImageURL:      a.ImageURL,
+		Tags:          a.Tags,
+		PublishedTime: a.PublishedTime,
+		UpdatedTime:   a.UpdatedTime,
+		CreatedTime:   now,
+		Status:        a.Status,
+		PublishAt:     a.PublishAt,
+	}
+	if article.Status == "" {
+		article.Status = api.StatusPublished
+	}
+	// Drafts are given a publication time when published
+	if article.PublishedTime.IsZero() && article.Status != api.StatusDraft {
+		article.PublishedTime = now
+	}
+	if article.UpdatedTime.IsZero() {
+		article.UpdatedTime = article.PublishedTime
+	}
+
+	fingerprint := dedup.Compute(&a)
+	article.URLKey = fingerprint.URL
+	// Mongo has no unsigned integers
+	article.SimHash = int64(fingerprint.SimHash)
+	article.SimHashBands = fingerprint.Bands()
+	return article
+}
+
+func (a *Article) fingerprint() dedup.Fingerprint {
+	return dedup.Fingerprint{
+		URL:     a.URLKey,
+		SimHash: uint64(a.SimHash),
+	}
 }
 
 func (a *Article) toAPI() *api.Article {
+	updated := a.UpdatedTime
+	if updated.IsZero() {
+		// Articles stored before update times were recorded
+		updated = a.PublishedTime
+	}
+	cluster := a.ClusterID
+	if cluster == "" {
+		cluster = a.ID
+	}
+	status := a.Status
+	if status == "" {
+		status = api.StatusPublished
+	}
 	return &api.Article{
 		ID:            a.ID,
 		Title:         a.Title,
 		Body:          a.Body,
+		BodyFormat:    a.BodyFormat,
+		Summary:       a.Summary,
+		Author:        a.Author,
+		URL:           a.URL,
+		ImageURL:      a.ImageURL,
+		Tags:          a.Tags,
 		PublishedTime: a.PublishedTime,
+		UpdatedTime:   updated,
+		FeedID:        a.FeedID,
+		ClusterID:     cluster,
+		Status:        status,
+		PublishAt:     a.PublishAt,
 	}
 }
 
@@ -83,3 +240,61 @@ func (l ArticleList) toAPI() []api.Article {
 	}
 	return res
 }
+
+// ScoredArticle is an Article document with the text search score of a query
+type ScoredArticle struct {
+	Article `bson:",inline"`
+	Score   float64 `bson:"score"`
+}
+
+// IdempotencyRecord is a Mongo document to store responses to requests made with idempotency keys
+type IdempotencyRecord struct {
+	Key         string    `bson:"_id"`
+	RequestHash string    `bson:"request_hash"`
+	Status      int       `bson:"status"`
+	ContentType string    `bson:"content_type"`
+	Body        []byte    `bson:"body"`
+	ExpiresTime time.Time `bson:"expires_at"`
+}
+
+func newIdempotencyRecord(r *db.IdempotencyRecord) *IdempotencyRecord {
+	return &IdempotencyRecord{
+		Key:         r.Key,
+		RequestHash: r.RequestHash,
+		Status:      r.Status,
+		ContentType: r.ContentType,
+		Body:        r.Body,
+		ExpiresTime: r.ExpiresTime,
+	}
+}
+
+func (r *IdempotencyRecord) toDB() *db.IdempotencyRecord {
+	return &db.IdempotencyRecord{
+		Key:         r.Key,
+		RequestHash: r.RequestHash,
+		Status:      r.Status,
+		ContentType: r.ContentType,
+		Body:        r.Body,
+		ExpiresTime: r.ExpiresTime,
+	}
+}
+
+// FilterRule is a Mongo document to store Users' filter rules
+type FilterRule struct {
+	ID          string    `bson:"_id"`
+	UserID      string    `bson:"user_id"`
+	Type        string    `bson:"type"`
+	Value       string    `bson:"value"`
+	Action      string    `bson:"action"`
+	CreatedTime time.Time `bson:"created_at"`
+}
+
+func (r *FilterRule) toAPI() *api.FilterRule {
+	return &api.FilterRule{
+		ID:          r.ID,
+		Type:        r.Type,
+		Value:       r.Value,
+		Action:      r.Action,
+		CreatedTime: r.CreatedTime,
+	}
+}
@@ -1,7 +1,13 @@
 package mongo
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/globalsign/mgo"
@@ -9,6 +15,8 @@ import (
 	"github.com/google/uuid"
 	"github.com/if-ivan-else/tldrfeed/api"
 	"github.com/if-ivan-else/tldrfeed/internal/db"
+	"github.com/if-ivan-else/tldrfeed/internal/dedup"
+	"github.com/if-ivan-else/tldrfeed/internal/search"
 	"github.com/pkg/errors"
 )
 
@@ -21,26 +29,98 @@ const (
 	FeedsCollection = "feeds"
 	// ArticlesCollection contains Article entities
 	ArticlesCollection = "articles"
+	// IdempotencyCollection contains responses to requests made with idempotency keys
+	IdempotencyCollection = "idempotency"
+	// FilterRulesCollection contains Users' filter rules
+	FilterRulesCollection = "filter_rules"
+
+	// maxDuplicateCandidates limits the number of Articles compared to a new Article when looking for duplicates
+	maxDuplicateCandidates = 100
+	// tagCountsTTL is how long tag counts are reused before the Articles are aggregated again
+	tagCountsTTL = time.Minute
 )
 
 // repository implements a MongoDB based repository for tldrfeed persistence of Users, Articles and Feeds
 type repository struct {
 	dbName     string
 	mgoSession *mgo.Session
+	// timelines enables materialized timelines, for Feeds with at most maxFanOut subscribers
+	timelines bool
+	maxFanOut int
+
+	// tagCounts are the last tag counts aggregated, reused until tagCountsExpiry. tagCountsRefresh is the aggregation
+	// in progress, shared by the callers listing tags meanwhile.
+	tagCountsMu      sync.Mutex
+	tagCounts        []api.TagCount
+	tagCountsExpiry  time.Time
+	tagCountsRefresh *tagCountsCall
+}
+
+// tagCountsCall is an aggregation of tag counts, done is closed once tags or err are set
+type tagCountsCall struct {
+	done chan struct{}
+	tags []api.TagCount
+	err  error
 }
 
-func (r *repository) newSession() *session {
-	return &session{
+// newSession copies the repository's session for an operation made on behalf of ctx. mgo cannot interrupt a query
+// once sent, so the deadline of ctx bounds the wait for the server's replies instead and reads are aborted by the
+// server past it. Cancellation is checked as operations go from one query to the next.
+func (r *repository) newSession(ctx context.Context) *session {
+	s := &session{
+		ctx:        ctx,
 		repo:       r,
 		mgoSession: r.mgoSession.Copy(),
 	}
+	if timeout, ok := s.timeout(); ok {
+		s.mgoSession.SetSocketTimeout(timeout)
+	}
+	return s
 }
 
 type session struct {
+	ctx        context.Context
 	repo       *repository
 	mgoSession *mgo.Session
 }
 
+// timeout returns the time left until the deadline of the session's context, if it has one
+func (s *session) timeout() (time.Duration, bool) {
+	deadline, ok := s.ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	// mgo takes zero for no timeout
+	if timeout := time.Until(deadline); timeout > time.Millisecond {
+		return timeout, true
+	}
+	return time.Millisecond, true
+}
+
+// bounded lets the server abort a read running past the deadline of the session's context
+func (s *session) bounded(q *mgo.Query) *mgo.Query {
+	if timeout, ok := s.timeout(); ok {
+		q.SetMaxTime(timeout)
+	}
+	return q
+}
+
+// boundedPipe lets the server abort an aggregation running past the deadline of the session's context
+func (s *session) boundedPipe(p *mgo.Pipe) *mgo.Pipe {
+	if timeout, ok := s.timeout(); ok {
+		p.SetMaxTime(timeout)
+	}
+	return p
+}
+
+// err reports the failure of a query cut short by the session's context as the context's error
+func (s *session) err(err error) error {
+	if err != nil && s.ctx.Err() != nil {
+		return s.ctx.Err()
+	}
+	return err
+}
+
 func (s *session) collection(name string) *mgo.Collection {
 	return s.mgoSession.DB(s.repo.dbName).C(name)
 }
@@ -57,16 +137,24 @@ func (s *session) articles() *mgo.Collection {
 	return s.collection(ArticlesCollection)
 }
 
+func (s *session) idempotency() *mgo.Collection {
+	return s.collection(IdempotencyCollection)
+}
+
+func (s *session) filterRules() *mgo.Collection {
+	return s.collection(FilterRulesCollection)
+}
+
 func (s *session) close() {
 	s.mgoSession.Close()
 }
 
 // NewRepository creates an instance of a MongoDB repository for tests
-func NewRepository(url string) (db.Repository, error) {
-	return newRepository(url, DB, false)
+func NewRepository(url string, opts ...Option) (db.Repository, error) {
+	return newRepository(url, DB, false, opts...)
 }
 
-func newRepository(url string, dbName string, drop bool) (db.Repository, error) {
+func newRepository(url string, dbName string, drop bool, opts ...Option) (db.Repository, error) {
 	if url == "" {
 		return nil, errors.New("Empty connection URL")
 	}
@@ -82,14 +170,95 @@ func newRepository(url string, dbName string, drop bool) (db.Repository, error)
 		log.Printf("Dropped DB %s", dbName)
 	}
 
-	return &repository{
+	r := &repository{
 		dbName:     dbName,
 		mgoSession: s,
-	}, nil
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if err := r.ensureIndexes(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	if err := r.initTimelines(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// ensureIndexes creates indexes needed by the repository queries
+func (r *repository) ensureIndexes() error {
+	s := r.newSession(context.Background())
+	defer s.close()
+
+	// Let mongo discard expired idempotency records, reads filter them out until they are reaped
+	expiry := mgo.Index{
+		Key:         []string{"expires_at"},
+		ExpireAfter: time.Second,
+	}
+	if err := s.idempotency().EnsureIndex(expiry); err != nil {
+		return errors.Wrap(err, "Failed to create idempotency expiry index")
+	}
+
+	// Stemming and stop words are disabled so that text search finds the same Articles as the
+	// exact term matching applied on top of it
+	text := mgo.Index{
+		Key:             []string{"$text:title", "$text:body"},
+		Weights:         map[string]int{"title": 2, "body": 1},
+		DefaultLanguage: "none",
+	}
+	if err := s.articles().EnsureIndex(text); err != nil {
+		return errors.Wrap(err, "Failed to create article text index")
+	}
+
+	for _, key := range []string{"url_key", "simhash_bands"} {
+		if err := s.articles().EnsureIndexKey(key); err != nil {
+			return errors.Wrapf(err, "Failed to create article %s index", key)
+		}
+	}
+
+	// Looks up scheduled Articles falling due
+	if err := s.articles().EnsureIndexKey("status", "publish_at"); err != nil {
+		return errors.Wrap(err, "Failed to create article status index")
+	}
+
+	// Articles used to expire with a TTL index, which removed them behind the back of Feed versions and timelines.
+	// The janitor expires them all now, the index and the expiry times it worked from are dropped.
+	if err := s.articles().DropIndex("expire_at"); err != nil && !strings.Contains(err.Error(), "not found") {
+		return errors.Wrap(err, "Failed to drop article expiry index")
+	}
+	if _, err := s.articles().UpdateAll(bson.M{"expire_at": bson.M{"$exists": true}}, bson.M{"$unset": bson.M{"expire_at": ""}}); err != nil {
+		return errors.Wrap(err, "Failed to clear article expiry times")
+	}
+	// Finds the oldest Articles of a Feed and the Articles a User starred
+	if err := s.articles().EnsureIndexKey("feed_id", "published_at"); err != nil {
+		return errors.Wrap(err, "Failed to create article publication index")
+	}
+	if err := s.articles().EnsureIndexKey("starred_by"); err != nil {
+		return errors.Wrap(err, "Failed to create article star index")
+	}
+	// Finds the Articles carrying a tag
+	if err := s.articles().EnsureIndexKey("tags"); err != nil {
+		return errors.Wrap(err, "Failed to create article tag index")
+	}
+
+	if err := s.filterRules().EnsureIndexKey("user_id", "created_at"); err != nil {
+		return errors.Wrap(err, "Failed to create filter rule index")
+	}
+
+	// Audit records are looked up by time, optionally for an entity or principal
+	for _, key := range [][]string{{"time"}, {"entity_type", "entity_id", "time"}, {"principal", "time"}} {
+		if err := s.audit().EnsureIndexKey(key...); err != nil {
+			return errors.Wrap(err, "Failed to create audit index")
+		}
+	}
+	return nil
 }
 
-func (r *repository) CreateUser(name string) (*api.User, error) {
-	s := r.newSession()
+func (r *repository) CreateUser(ctx context.Context, name string) (*api.User, error) {
+	s := r.newSession(ctx)
 	defer s.close()
 
 	u := User{
@@ -104,20 +273,31 @@ func (r *repository) CreateUser(name string) (*api.User, error) {
 	return u.toAPI(), nil
 }
 
-func (r *repository) ListUsers() ([]api.User, error) {
-	s := r.newSession()
+func (r *repository) ListUsers(ctx context.Context, page db.Page) ([]api.User, error) {
+	s := r.newSession(ctx)
 	defer s.close()
 
 	users := UserList{}
 
-	if err := s.users().Find(nil).All(&users); err != nil {
-		return nil, err
+	selector := bson.M{}
+	if page.After != "" {
+		if _, err := r.getUser(s, page.After); err != nil {
+			return nil, err
+		}
+		selector["_id"] = bson.M{"$gt": page.After}
+	}
+	q := s.users().Find(selector).Sort("_id")
+	if page.Limit > 0 {
+		q = q.Limit(page.Limit)
+	}
+	if err := s.bounded(q).All(&users); err != nil {
+		return nil, s.err(err)
 	}
 	return users.toAPI(), nil
 }
 
-func (r *repository) GetUser(userID string) (*api.User, error) {
-	s := r.newSession()
+func (r *repository) GetUser(ctx context.Context, userID string) (*api.User, error) {
+	s := r.newSession(ctx)
 	defer s.close()
 
 	u, err := r.getUser(s, userID)
@@ -128,6 +308,10 @@ func (r *repository) GetUser(userID string) (*api.User, error) {
 }
 
 func (r *repository) getUser(s *session, userID string) (*User, error) {
+	// Most operations start by looking up a User or Feed, a request given up on stops here
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
 	var u User
 	err := s.users().FindId(userID).One(&u)
 	if err != nil {
@@ -139,14 +323,18 @@ func (r *repository) getUser(s *session, userID string) (*User, error) {
 	return &u, nil
 }
 
-func (r *repository) CreateFeed(name string) (*api.Feed, error) {
-	s := r.newSession()
+func (r *repository) CreateFeed(ctx context.Context, name string, category string, retention *api.RetentionPolicy) (*api.Feed, error) {
+	s := r.newSession(ctx)
 	defer s.close()
 
 	f := Feed{
-		ID:    uuid.New().String(),
-		Name:  name,
-		Users: []string{},
+		ID:          uuid.New().String(),
+		Name:        name,
+		Category:    category,
+		Users:       []string{},
+		Version:     1,
+		UpdatedTime: time.Now(),
+		Retention:   newRetention(retention),
 	}
 
 	// TODO: make Feed's name uniqe so that we fail here if a Feed with such name already exists
@@ -156,19 +344,23 @@ func (r *repository) CreateFeed(name string) (*api.Feed, error) {
 	return f.toAPI(), nil
 }
 
-func (r *repository) ListFeeds() ([]api.Feed, error) {
-	s := r.newSession()
+func (r *repository) ListFeeds(ctx context.Context, filter db.FeedFilter) ([]api.Feed, error) {
+	s := r.newSession(ctx)
 	defer s.close()
 
+	selector := bson.M{}
+	if filter.Category != "" {
+		selector["category"] = filter.Category
+	}
 	feeds := FeedList{}
-	if err := s.feeds().Find(nil).All(&feeds); err != nil {
-		return nil, err
+	if err := s.bounded(s.feeds().Find(selector)).All(&feeds); err != nil {
+		return nil, s.err(err)
 	}
 	return feeds.toAPI(), nil
 }
 
-func (r *repository) GetFeed(feedID string) (*api.Feed, error) {
-	s := r.newSession()
+func (r *repository) GetFeed(ctx context.Context, feedID string) (*api.Feed, error) {
+	s := r.newSession(ctx)
 	defer s.close()
 
 	f, err := r.getFeed(s, feedID)
@@ -178,7 +370,21 @@ func (r *repository) GetFeed(feedID string) (*api.Feed, error) {
 	return f.toAPI(), nil
 }
 
+func (r *repository) GetFeeds(ctx context.Context, feedIDs []string) ([]api.Feed, error) {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	feeds := FeedList{}
+	if err := s.bounded(s.feeds().Find(bson.M{"_id": bson.M{"$in": feedIDs}})).All(&feeds); err != nil {
+		return nil, s.err(err)
+	}
+	return feeds.toAPI(), nil
+}
+
 func (r *repository) getFeed(s *session, feedID string) (*Feed, error) {
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
 	var f Feed
 	err := s.feeds().FindId(feedID).One(&f)
 	if err != nil {
@@ -190,38 +396,332 @@ func (r *repository) getFeed(s *session, feedID string) (*Feed, error) {
 	return &f, nil
 }
 
-func (r *repository) ListFeedArticles(feedID string) ([]api.Article, error) {
-	s := r.newSession()
+func (r *repository) ListFeedArticles(ctx context.Context, feedID string, filter db.ArticleFilter) ([]api.Article, error) {
+	s := r.newSession(ctx)
 	defer s.close()
 
 	if _, err := r.getFeed(s, feedID); err != nil {
 		return nil, err
 	}
 
-	return r.listArticlesFromFeeds(s, []string{feedID})
+	return r.listArticlesFromFeeds(s, []string{feedID}, filter)
+}
+
+func (r *repository) ListArticlesOfFeeds(ctx context.Context, feedIDs []string, filter db.ArticleFilter, page db.Page) ([]api.Article, error) {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	if !page.Paged() {
+		return r.listArticlesFromFeeds(s, feedIDs, filter)
+	}
+	return r.listArticlePages(s, feedIDs, filter, page)
+}
+
+// listArticlePages returns a page of the visible Articles of every Feed, newest first. The Articles of every Feed are
+// read by a query of their own, bounded to the page.
+func (r *repository) listArticlePages(s *session, feedIDs []string, filter db.ArticleFilter, page db.Page) ([]api.Article, error) {
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var after *Article
+	if page.After != "" {
+		var a Article
+		selector := bson.M{"_id": page.After, "feed_id": bson.M{"$in": feedIDs}, "$or": visible(now)}
+		if err := s.bounded(s.articles().Find(selector)).One(&a); err != nil {
+			if err == mgo.ErrNotFound {
+				return nil, db.ErrNoSuchArticle
+			}
+			return nil, s.err(err)
+		}
+		after = &a
+	}
+
+	res := []api.Article{}
+	listed := map[string]bool{}
+	for _, feedID := range feedIDs {
+		if listed[feedID] {
+			continue
+		}
+		listed[feedID] = true
+		articles, err := r.listFeedArticlePage(s, feedID, filter, after, page.Limit, now)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, articles...)
+	}
+	return res, nil
+}
+
+// listFeedArticlePage returns up to limit visible Articles of a Feed following an Article, reading on while the
+// filter's rules hide Articles
+func (r *repository) listFeedArticlePage(s *session, feedID string, filter db.ArticleFilter, last *Article, limit int, now time.Time) ([]api.Article, error) {
+	res := []api.Article{}
+	for {
+		selector := bson.M{"feed_id": feedID, "$or": visible(now)}
+		if filter.Tag != "" {
+			selector["tags"] = filter.Tag
+		}
+		if last != nil {
+			selector = bson.M{"$and": []bson.M{selector, {"$or": []bson.M{
+				{"published_at": bson.M{"$lt": last.PublishedTime}},
+				{"published_at": last.PublishedTime, "_id": bson.M{"$lt": last.ID}},
+			}}}}
+		}
+		q := s.articles().Find(selector).Sort("-published_at", "-_id")
+		if limit > 0 {
+			q = q.Limit(limit)
+		}
+		articles := ArticleList{}
+		if err := s.bounded(q).All(&articles); err != nil {
+			return nil, s.err(err)
+		}
+
+		for i := range articles {
+			article := articles[i].toAPI()
+			if (limit == 0 || len(res) < limit) && !filter.Rules.Hides(feedID, article) {
+				res = append(res, *article)
+			}
+		}
+		if limit == 0 || len(articles) < limit || len(res) == limit {
+			return res, nil
+		}
+		last = &articles[len(articles)-1]
+	}
 }
 
-func (r *repository) CreateFeedArticle(feedID string, articleTitle string, articleBody string) (articleID string, e error) {
-	s := r.newSession()
+func (r *repository) GetFeedArticles(ctx context.Context, feedID string, articleIDs []string) ([]api.Article, error) {
+	s := r.newSession(ctx)
 	defer s.close()
 
-	a := Article{
-		ID:            uuid.New().String(),
-		Title:         articleTitle,
-		Body:          articleBody,
-		FeedID:        feedID,
-		PublishedTime: time.Now(),
+	articles := ArticleList{}
+	if err := s.bounded(s.articles().Find(bson.M{"_id": bson.M{"$in": articleIDs}, "feed_id": feedID})).All(&articles); err != nil {
+		return nil, s.err(err)
 	}
+	return articles.toAPI(), nil
+}
 
-	if err := s.articles().Insert(a); err != nil {
+func (r *repository) CreateFeedArticle(ctx context.Context, feedID string, article api.Article) (articleID string, e error) {
+	ids, err := r.CreateFeedArticles(ctx, feedID, []api.Article{article})
+	if err != nil {
 		return "", err
 	}
+	return ids[0], nil
+}
+
+func (r *repository) CreateFeedArticles(ctx context.Context, feedID string, articles []api.Article) (articleIDs []string, e error) {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	if _, err := r.getFeed(s, feedID); err != nil {
+		return nil, err
+	}
+	if len(articles) == 0 {
+		return []string{}, nil
+	}
+
+	now := time.Now()
+	docs := []interface{}{}
+	added := []*Article{}
+	ids := []string{}
+	for _, article := range articles {
+		a := newArticle(feedID, article, now)
+		docs = append(docs, a)
+		added = append(added, a)
+		ids = append(ids, a.ID)
+	}
+	if err := r.findClusters(s, feedID, added); err != nil {
+		return nil, err
+	}
+
+	// MongoDB has no multi-document transactions here: an ordered insert stops at the first failure, and the Articles
+	// stored before it are removed again so that a failed batch leaves nothing behind and can be retried as a whole
+	if err := s.articles().Insert(docs...); err != nil {
+		return nil, r.removeCreatedArticles(s, ids, err)
+	}
+	if err := r.fanOut(s, feedID, added...); err != nil {
+		return nil, r.removeCreatedArticles(s, ids, err)
+	}
+
+	// Bump the version only after the Articles are visible so that a version is never newer than the data read with it
+	if err := r.bumpFeedVersion(s, feedID, now); err != nil {
+		return nil, r.removeCreatedArticles(s, ids, err)
+	}
+	return ids, nil
+}
+
+// removeCreatedArticles removes the Articles of a batch which failed to be created, and their timeline entries, then
+// returns the error which failed the batch
+func (r *repository) removeCreatedArticles(s *session, articleIDs []string, cause error) error {
+	if err := r.removeFromTimelines(s, articleIDs...); err != nil {
+		log.Printf("Failed to remove timeline entries of Articles of a failed batch: %v", err)
+	}
+	if _, err := s.articles().RemoveAll(bson.M{"_id": bson.M{"$in": articleIDs}}); err != nil {
+		log.Printf("Failed to remove Articles of a failed batch: %v", err)
+	}
+	return cause
+}
+
+// findClusters sets the cluster of new Articles added to a Feed to the cluster of a duplicate added to other Feeds, or
+// to the Article's own cluster. Duplicates join the cluster of the earliest one, so every duplicate found is in the
+// same cluster. Drafts and Articles past their Feed's maximum age, due to be removed by the janitor, are not
+// duplicates. Duplicates are only looked up in other Feeds, so Articles of the batch cannot be each other's, and the
+// candidates of the whole batch are read with a single query.
+func (r *repository) findClusters(s *session, feedID string, articles []*Article) error {
+	urlKeys := []string{}
+	bands := []string{}
+	created := time.Time{}
+	for _, a := range articles {
+		a.ClusterID = a.ID
+		if a.URLKey != "" {
+			urlKeys = append(urlKeys, a.URLKey)
+		}
+		bands = append(bands, a.SimHashBands...)
+		if created.IsZero() || a.CreatedTime.Before(created) {
+			created = a.CreatedTime
+		}
+	}
+	candidates := []bson.M{}
+	if len(urlKeys) > 0 {
+		candidates = append(candidates, bson.M{"url_key": bson.M{"$in": urlKeys}})
+	}
+	if len(bands) > 0 {
+		candidates = append(candidates, bson.M{"simhash_bands": bson.M{"$in": bands}})
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
 
-	return a.ID, nil
+	selector := bson.M{
+		"$or":        candidates,
+		"feed_id":    bson.M{"$ne": feedID},
+		"created_at": bson.M{"$gte": created.Add(-dedup.Window)},
+		"status":     bson.M{"$ne": api.StatusDraft},
+	}
+	// Bands are loose, so many Articles may share one: the most recent candidates are compared, near-duplicates
+	// being published close together. Sharing a band does not make near-duplicates, the fingerprints tell.
+	found := ArticleList{}
+	fields := bson.M{"feed_id": 1, "published_at": 1, "cluster_id": 1, "url_key": 1, "simhash": 1}
+	limit := maxDuplicateCandidates * len(articles)
+	if err := s.articles().Find(selector).Select(fields).Sort("-created_at").Limit(limit).All(&found); err != nil {
+		return err
+	}
+	cutoffs, err := r.retentionCutoffs(s, found, created)
+	if err != nil {
+		return err
+	}
+	for _, a := range articles {
+		for _, candidate := range found {
+			if cutoff, ok := cutoffs[candidate.FeedID]; ok && candidate.PublishedTime.Before(cutoff) {
+				continue
+			}
+			if a.fingerprint().Matches(candidate.fingerprint()) {
+				a.ClusterID = candidate.ClusterID
+				if candidate.ClusterID == "" {
+					a.ClusterID = candidate.ID
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// retentionCutoffs returns the publication times before which Articles of the Feeds of the given Articles are past
+// their maximum age at the given time, for the Feeds having one
+func (r *repository) retentionCutoffs(s *session, articles ArticleList, now time.Time) (map[string]time.Time, error) {
+	cutoffs := map[string]time.Time{}
+	if len(articles) == 0 {
+		return cutoffs, nil
+	}
+	feedIDs := []string{}
+	for _, a := range articles {
+		feedIDs = append(feedIDs, a.FeedID)
+	}
+	feeds := FeedList{}
+	selector := bson.M{"_id": bson.M{"$in": feedIDs}, "retention.max_age_days": bson.M{"$gt": 0}}
+	if err := s.feeds().Find(selector).Select(bson.M{"retention": 1}).All(&feeds); err != nil {
+		return nil, err
+	}
+	for _, f := range feeds {
+		cutoffs[f.ID] = now.AddDate(0, 0, -f.Retention.MaxAgeDays)
+	}
+	return cutoffs, nil
+}
+
+func (r *repository) bumpFeedVersion(s *session, feedID string, updated time.Time) error {
+	updator := bson.M{
+		"$inc": bson.M{"version": 1},
+		"$max": bson.M{"updated_at": updated},
+	}
+	if err := s.feeds().UpdateId(feedID, updator); err != nil {
+		if err == mgo.ErrNotFound {
+			return db.ErrNoSuchFeed
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *repository) GetFeedVersion(ctx context.Context, feedID string) (*db.FeedVersion, error) {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	f, err := r.getFeed(s, feedID)
+	if err != nil {
+		return nil, err
+	}
+	return f.toVersion(), nil
+}
+
+func (r *repository) GetTimelineVersion(ctx context.Context, userID string) (*db.TimelineVersion, error) {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	u, err := r.getUser(s, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	feeds := FeedList{}
+	selector := bson.M{"users": bson.M{"$in": []string{userID}}}
+	if err := s.bounded(s.feeds().Find(selector).Select(bson.M{"_id": 1, "version": 1, "updated_at": 1})).All(&feeds); err != nil {
+		return nil, s.err(err)
+	}
+
+	v := &db.TimelineVersion{
+		UserID:                   userID,
+		SubscriptionsUpdatedTime: u.SubscriptionsUpdatedTime,
+		Feeds:                    []db.FeedVersion{},
+	}
+	for _, f := range feeds {
+		v.Feeds = append(v.Feeds, *f.toVersion())
+	}
+	return v, nil
+}
+
+func (r *repository) CountFeedArticles(ctx context.Context, feedID string, since time.Time) (int, error) {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	if _, err := r.getFeed(s, feedID); err != nil {
+		return 0, err
+	}
+
+	// Articles stored before creation times were recorded were created when published
+	selector := bson.M{
+		"feed_id": feedID,
+		"$or": []bson.M{
+			{"created_at": bson.M{"$gte": since}},
+			{"created_at": bson.M{"$exists": false}, "published_at": bson.M{"$gte": since}},
+		},
+	}
+	n, err := s.bounded(s.articles().Find(selector)).Count()
+	return n, s.err(err)
 }
 
-func (r *repository) AddUserFeed(userID string, feedID string) error {
-	s := r.newSession()
+func (r *repository) AddUserFeed(ctx context.Context, userID string, feedID string) error {
+	s := r.newSession(ctx)
 	defer s.close()
 
 	if _, err := r.getUser(s, userID); err != nil {
@@ -234,24 +734,58 @@ func (r *repository) AddUserFeed(userID string, feedID string) error {
 
 	selector := bson.M{"_id": feedID}
 	updator := bson.M{"$addToSet": bson.M{"users": userID}}
-	return s.feeds().Update(selector, updator)
+	if err := s.feeds().Update(selector, updator); err != nil {
+		return err
+	}
+	if r.timelines {
+		f, err := r.getFeed(s, feedID)
+		if err != nil {
+			return err
+		}
+		if err := r.updateFanOut(s, f); err != nil {
+			return err
+		}
+		if err := r.backfillTimeline(s, userID, f); err != nil {
+			return err
+		}
+	}
+	return s.users().UpdateId(userID, bson.M{"$set": bson.M{"subscriptions_updated_at": time.Now()}})
+}
+
+func (r *repository) RemoveUserFeed(ctx context.Context, userID string, feedID string) error {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	if _, err := r.getUserFeed(s, userID, feedID); err != nil {
+		return err
+	}
+
+	if err := s.feeds().UpdateId(feedID, bson.M{"$pull": bson.M{"users": userID}}); err != nil {
+		return err
+	}
+	if r.timelines {
+		if _, err := s.timelines().RemoveAll(bson.M{"user_id": userID, "feed_id": feedID}); err != nil {
+			return err
+		}
+	}
+	return s.users().UpdateId(userID, bson.M{"$set": bson.M{"subscriptions_updated_at": time.Now()}})
 }
 
-func (r *repository) ListUserFeeds(userID string) ([]api.Feed, error) {
-	s := r.newSession()
+func (r *repository) ListUserFeeds(ctx context.Context, userID string) ([]api.Feed, error) {
+	s := r.newSession(ctx)
 	defer s.close()
 
 	feeds := FeedList{}
 	selector := bson.M{"users": bson.M{"$in": []string{userID}}}
-	if err := s.feeds().Find(selector).All(&feeds); err != nil {
-		return nil, err
+	if err := s.bounded(s.feeds().Find(selector)).All(&feeds); err != nil {
+		return nil, s.err(err)
 	}
 
 	return feeds.toAPI(), nil
 }
 
-func (r *repository) GetUserFeed(userID string, feedID string) (*api.Feed, error) {
-	s := r.newSession()
+func (r *repository) GetUserFeed(ctx context.Context, userID string, feedID string) (*api.Feed, error) {
+	s := r.newSession(ctx)
 	defer s.close()
 
 	f, err := r.getUserFeed(s, userID, feedID)
@@ -279,8 +813,8 @@ func (r *repository) getUserFeed(s *session, userID string, feedID string) (*Fee
 	return &f, nil
 }
 
-func (r *repository) ListUserArticles(userID string) ([]api.Article, error) {
-	s := r.newSession()
+func (r *repository) ListUserArticles(ctx context.Context, userID string, filter db.ArticleFilter) ([]api.Article, error) {
+	s := r.newSession(ctx)
 	defer s.close()
 
 	if _, err := r.getUser(s, userID); err != nil {
@@ -289,36 +823,745 @@ func (r *repository) ListUserArticles(userID string) ([]api.Article, error) {
 	// Get list of feeds for the user
 	feeds := FeedList{}
 	selector := bson.M{"users": bson.M{"$in": []string{userID}}}
-	if err := s.feeds().Find(selector).Select(bson.M{"_id": 1}).All(&feeds); err != nil {
-		return nil, err
+	if err := s.bounded(s.feeds().Find(selector).Select(bson.M{"_id": 1, "fan_out_on_read": 1})).All(&feeds); err != nil {
+		return nil, s.err(err)
+	}
+	if r.timelines {
+		return r.listTimelineArticles(s, userID, feeds, filter)
 	}
 	feedIDs := []string{}
 	for _, f := range feeds {
 		feedIDs = append(feedIDs, f.ID)
 	}
-	return r.listArticlesFromFeeds(s, feedIDs)
+	return r.listArticlesFromFeeds(s, feedIDs, filter)
 }
 
-func (r *repository) ListUserFeedArticles(userID string, feedID string) ([]api.Article, error) {
-	s := r.newSession()
+func (r *repository) ListUserFeedArticles(ctx context.Context, userID string, feedID string, filter db.ArticleFilter) ([]api.Article, error) {
+	s := r.newSession(ctx)
 	defer s.close()
 
 	if _, err := r.getUserFeed(s, userID, feedID); err != nil {
 		return nil, err
 	}
-	return r.listArticlesFromFeeds(s, []string{feedID})
+	return r.listArticlesFromFeeds(s, []string{feedID}, filter)
 }
 
-func (r *repository) listArticlesFromFeeds(s *session, feedIDs []string) ([]api.Article, error) {
+func (r *repository) listArticlesFromFeeds(s *session, feedIDs []string, filter db.ArticleFilter) ([]api.Article, error) {
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
 	articles := ArticleList{}
-	selector := bson.M{"feed_id": bson.M{"$in": feedIDs}}
+	selector := bson.M{"feed_id": bson.M{"$in": feedIDs}, "$or": visible(time.Now())}
+	if filter.Tag != "" {
+		selector["tags"] = filter.Tag
+	}
 	// Gather all the articles in the reverse order by published date
-	if err := s.articles().Find(selector).Sort("-published_at").All(&articles); err != nil {
+	if err := s.bounded(s.articles().Find(selector).Sort("-published_at", "-_id")).All(&articles); err != nil {
+		return nil, s.err(err)
+	}
+	return applyRules(articles, filter), nil
+}
+
+// applyRules returns the Articles not muted by the filter's rules
+func applyRules(articles ArticleList, filter db.ArticleFilter) []api.Article {
+	if filter.Rules.Empty() {
+		return articles.toAPI()
+	}
+
+	// Rules can match anything down to regular expressions, so muted Articles are dropped here rather than in the query
+	res := []api.Article{}
+	for _, a := range articles {
+		article := a.toAPI()
+		if !filter.Rules.Hides(a.FeedID, article) {
+			res = append(res, *article)
+		}
+	}
+	return res
+}
+
+// visible returns the alternatives matching published Articles, including scheduled Articles due but not yet
+// published by the scheduler. Articles stored before drafts were introduced have no status and are published.
+func visible(now time.Time) []bson.M {
+	return []bson.M{
+		{"status": bson.M{"$nin": unpublished}},
+		{"status": api.StatusScheduled, "publish_at": bson.M{"$lte": now}},
+	}
+}
+
+// unpublished lists the statuses of Articles not yet published
+var unpublished = []string{api.StatusDraft, api.StatusScheduled}
+
+// ListTags aggregates all visible Articles, its counts are reused for tagCountsTTL so that listing tags does not
+// scan the collection on every request. Callers listing tags while counts are aggregated wait for the aggregation
+// rather than making their own.
+func (r *repository) ListTags(ctx context.Context) ([]api.TagCount, error) {
+	r.tagCountsMu.Lock()
+	if r.tagCounts != nil && time.Now().Before(r.tagCountsExpiry) {
+		tags := r.tagCounts
+		r.tagCountsMu.Unlock()
+		return append([]api.TagCount{}, tags...), nil
+	}
+	call := r.tagCountsRefresh
+	if call == nil {
+		call = &tagCountsCall{done: make(chan struct{})}
+		r.tagCountsRefresh = call
+		r.tagCountsMu.Unlock()
+
+		call.tags, call.err = r.aggregateTags(ctx)
+		r.tagCountsMu.Lock()
+		if call.err == nil {
+			r.tagCounts = call.tags
+			r.tagCountsExpiry = time.Now().Add(tagCountsTTL)
+		}
+		r.tagCountsRefresh = nil
+		close(call.done)
+	}
+	r.tagCountsMu.Unlock()
+
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if call.err != nil {
+		return nil, call.err
+	}
+	return append([]api.TagCount{}, call.tags...), nil
+}
+
+// aggregateTags counts the visible Articles carrying every tag, most used tags first
+func (r *repository) aggregateTags(ctx context.Context) ([]api.TagCount, error) {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	pipeline := []bson.M{
+		{"$match": bson.M{"$or": visible(time.Now())}},
+		{"$unwind": "$tags"},
+		{"$group": bson.M{"_id": "$tags", "articles": bson.M{"$sum": 1}}},
+		// Sort keys are ordered, hence bson.D
+		{"$sort": bson.D{{Name: "articles", Value: -1}, {Name: "_id", Value: 1}}},
+	}
+	counts := []TagCount{}
+	if err := s.boundedPipe(s.articles().Pipe(pipeline)).All(&counts); err != nil {
+		return nil, s.err(err)
+	}
+
+	tags := []api.TagCount{}
+	for _, c := range counts {
+		tags = append(tags, api.TagCount{Tag: c.Tag, Articles: c.Articles})
+	}
+	return tags, nil
+}
+
+func (r *repository) DiscoverFeeds(ctx context.Context, userID string, category string) ([]db.FeedStats, error) {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	if _, err := r.getUser(s, userID); err != nil {
 		return nil, err
 	}
-	return articles.toAPI(), nil
+
+	match := bson.M{"users": bson.M{"$ne": userID}}
+	if category != "" {
+		match["category"] = category
+	}
+	pipeline := []bson.M{
+		{"$match": match},
+		{"$project": bson.M{
+			"title":       1,
+			"category":    1,
+			"updated_at":  1,
+			"subscribers": bson.M{"$size": "$users"},
+		}},
+	}
+	feeds := []FeedStats{}
+	if err := s.boundedPipe(s.feeds().Pipe(pipeline)).All(&feeds); err != nil {
+		return nil, s.err(err)
+	}
+
+	stats := []db.FeedStats{}
+	for _, f := range feeds {
+		stats = append(stats, f.toDB())
+	}
+	return stats, nil
 }
 
-func (r *repository) Close() {
-	r.mgoSession.Close()
+func (r *repository) ListFeedDrafts(ctx context.Context, feedID string) ([]api.Article, error) {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	if _, err := r.getFeed(s, feedID); err != nil {
+		return nil, err
+	}
+
+	drafts := ArticleList{}
+	selector := bson.M{"feed_id": feedID, "status": bson.M{"$in": unpublished}}
+	if err := s.bounded(s.articles().Find(selector).Sort("-updated_at")).All(&drafts); err != nil {
+		return nil, s.err(err)
+	}
+	return drafts.toAPI(), nil
+}
+
+func (r *repository) GetFeedDraft(ctx context.Context, feedID string, articleID string) (*api.Article, error) {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	if _, err := r.getFeed(s, feedID); err != nil {
+		return nil, err
+	}
+
+	var a Article
+	selector := bson.M{"_id": articleID, "feed_id": feedID, "status": bson.M{"$in": unpublished}}
+	if err := s.articles().Find(selector).One(&a); err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, db.ErrNoSuchDraft
+		}
+		return nil, err
+	}
+	return a.toAPI(), nil
+}
+
+func (r *repository) UpdateFeedDraft(ctx context.Context, feedID string, article api.Article) (*api.Article, error) {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	if _, err := r.getFeed(s, feedID); err != nil {
+		return nil, err
+	}
+
+	// The ID, cluster and creation time of the draft are kept, the rest is replaced
+	now := time.Now()
+	a := newArticle(feedID, article, now)
+	set := bson.M{
+		"title":         a.Title,
+		"body":          a.Body,
+		"body_format":   a.BodyFormat,
+		"summary":       a.Summary,
+		"author":        a.Author,
+		"url":           a.URL,
+		"image_url":     a.ImageURL,
+		"tags":          a.Tags,
+		"published_at":  a.PublishedTime,
+		"updated_at":    a.UpdatedTime,
+		"url_key":       a.URLKey,
+		"simhash":       a.SimHash,
+		"simhash_bands": a.SimHashBands,
+		"status":        a.Status,
+	}
+	unset := bson.M{}
+	if a.PublishAt != nil {
+		set["publish_at"] = a.PublishAt
+	} else {
+		unset["publish_at"] = ""
+	}
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	// Only unpublished Articles are updated, so that the scheduler and editors publish a draft at most once
+	selector := bson.M{"_id": article.ID, "feed_id": feedID, "status": bson.M{"$in": unpublished}}
+	if err := s.articles().Update(selector, update); err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, r.draftNotFound(s, feedID, article.ID)
+		}
+		return nil, err
+	}
+	if err := r.bumpFeedVersion(s, feedID, now); err != nil {
+		return nil, err
+	}
+
+	var updated Article
+	if err := s.articles().FindId(article.ID).One(&updated); err != nil {
+		return nil, err
+	}
+	// Scheduled Articles are in timelines already, possibly with another publication time
+	if err := r.updateTimelines(s, feedID, &updated); err != nil {
+		return nil, err
+	}
+	return updated.toAPI(), nil
+}
+
+func (r *repository) DeleteFeedDraft(ctx context.Context, feedID string, articleID string) error {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	if _, err := r.getFeed(s, feedID); err != nil {
+		return err
+	}
+
+	selector := bson.M{"_id": articleID, "feed_id": feedID, "status": bson.M{"$in": unpublished}}
+	if err := s.articles().Remove(selector); err != nil {
+		if err == mgo.ErrNotFound {
+			return r.draftNotFound(s, feedID, articleID)
+		}
+		return err
+	}
+	if err := r.removeFromTimelines(s, articleID); err != nil {
+		return err
+	}
+	return r.bumpFeedVersion(s, feedID, time.Now())
+}
+
+// draftNotFound tells apart Articles that do not exist from Articles already published
+func (r *repository) draftNotFound(s *session, feedID string, articleID string) error {
+	n, err := s.articles().Find(bson.M{"_id": articleID, "feed_id": feedID}).Count()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return db.ErrArticlePublished
+	}
+	return db.ErrNoSuchDraft
+}
+
+func (r *repository) PublishDueArticles(ctx context.Context, now time.Time) ([]api.Article, error) {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	due := ArticleList{}
+	selector := bson.M{"status": api.StatusScheduled, "publish_at": bson.M{"$lte": now}}
+	if err := s.articles().Find(selector).Sort("publish_at").All(&due); err != nil {
+		return nil, err
+	}
+
+	published := []api.Article{}
+	for _, a := range due {
+		if err := s.ctx.Err(); err != nil {
+			return published, err
+		}
+		// Another server or an editor may have published the Article since it was found
+		err := s.articles().Update(bson.M{"_id": a.ID, "status": api.StatusScheduled},
+			bson.M{"$set": bson.M{"status": api.StatusPublished}})
+		if err == mgo.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return published, err
+		}
+		if err := r.bumpFeedVersion(s, a.FeedID, now); err != nil {
+			return published, err
+		}
+		a.Status = api.StatusPublished
+		published = append(published, *a.toAPI())
+	}
+	return published, nil
+}
+
+func (r *repository) SetFeedRetention(ctx context.Context, feedID string, policy *api.RetentionPolicy) error {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	update := bson.M{"$set": bson.M{"retention": newRetention(policy)}}
+	if policy == nil {
+		update = bson.M{"$unset": bson.M{"retention": ""}}
+	}
+	if err := s.feeds().UpdateId(feedID, update); err != nil {
+		if err == mgo.ErrNotFound {
+			return db.ErrNoSuchFeed
+		}
+		return err
+	}
+
+	return r.bumpFeedVersion(s, feedID, time.Now())
+}
+
+func (r *repository) ReportExpiredArticles(ctx context.Context, feedID string, policy api.RetentionPolicy, now time.Time) (*api.RetentionReport, error) {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	if _, err := r.getFeed(s, feedID); err != nil {
+		return nil, err
+	}
+	return r.reportExpiredArticles(s, feedID, policy, now)
+}
+
+func (r *repository) reportExpiredArticles(s *session, feedID string, policy api.RetentionPolicy, now time.Time) (*api.RetentionReport, error) {
+	report := &api.RetentionReport{
+		FeedID:  feedID,
+		Policy:  policy,
+		Expired: []api.ExpiredArticle{},
+		Starred: []api.ExpiredArticle{},
+	}
+	if policy.Empty() {
+		return report, nil
+	}
+
+	published := bson.M{"feed_id": feedID, "status": bson.M{"$nin": unpublished}}
+	fields := bson.M{"title": 1, "published_at": 1, "starred_by": 1}
+	cutoff := now.AddDate(0, 0, -policy.MaxAgeDays)
+	found := ArticleList{}
+	if policy.MaxAgeDays > 0 {
+		selector := bson.M{"feed_id": feedID, "status": bson.M{"$nin": unpublished}, "published_at": bson.M{"$lt": cutoff}}
+		if err := s.bounded(s.articles().Find(selector).Select(fields)).All(&found); err != nil {
+			return nil, s.err(err)
+		}
+	}
+	if policy.MaxCount > 0 {
+		beyond := ArticleList{}
+		q := s.articles().Find(published).Select(fields).Sort("-published_at", "_id").Skip(policy.MaxCount)
+		if err := s.bounded(q).All(&beyond); err != nil {
+			return nil, s.err(err)
+		}
+		found = append(found, beyond...)
+	}
+
+	// Articles beyond the maximum count may also be too old, each is reported once
+	seen := map[string]bool{}
+	expired := ArticleList{}
+	for _, a := range found {
+		if !seen[a.ID] {
+			seen[a.ID] = true
+			expired = append(expired, a)
+		}
+	}
+	sort.SliceStable(expired, func(i, j int) bool { return expired[i].PublishedTime.Before(expired[j].PublishedTime) })
+
+	for _, a := range expired {
+		e := api.ExpiredArticle{ID: a.ID, Title: a.Title, PublishedTime: a.PublishedTime, Reason: api.ExpiryMaxCount}
+		if policy.MaxAgeDays > 0 && a.PublishedTime.Before(cutoff) {
+			e.Reason = api.ExpiryMaxAge
+		}
+		if len(a.StarredBy) > 0 {
+			report.Starred = append(report.Starred, e)
+		} else {
+			report.Expired = append(report.Expired, e)
+		}
+	}
+	return report, nil
+}
+
+func (r *repository) ExpireFeedArticles(ctx context.Context, feedID string, now time.Time) (int, error) {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	f, err := r.getFeed(s, feedID)
+	if err != nil {
+		return 0, err
+	}
+	if f.Retention == nil {
+		return 0, nil
+	}
+	report, err := r.reportExpiredArticles(s, feedID, *f.Retention.toAPI(), now)
+	if err != nil || len(report.Expired) == 0 {
+		return 0, err
+	}
+
+	ids := []string{}
+	for _, e := range report.Expired {
+		ids = append(ids, e.ID)
+	}
+	// Articles starred since the report was made are kept
+	selector := bson.M{"_id": bson.M{"$in": ids}, "starred_by.0": bson.M{"$exists": false}}
+	info, err := s.articles().RemoveAll(selector)
+	if err != nil {
+		return 0, err
+	}
+	if info.Removed == 0 {
+		return 0, nil
+	}
+	if r.timelines {
+		if err := r.removeExpiredFromTimelines(s, ids); err != nil {
+			return 0, err
+		}
+	}
+	return info.Removed, r.bumpFeedVersion(s, feedID, now)
+}
+
+func (r *repository) StarArticle(ctx context.Context, userID string, articleID string) error {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	if _, err := r.getUser(s, userID); err != nil {
+		return err
+	}
+
+	selector := bson.M{"_id": articleID, "status": bson.M{"$nin": unpublished}}
+	update := bson.M{"$addToSet": bson.M{"starred_by": userID}}
+	if err := s.articles().Update(selector, update); err != nil {
+		if err == mgo.ErrNotFound {
+			return db.ErrNoSuchArticle
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *repository) UnstarArticle(ctx context.Context, userID string, articleID string) error {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	if _, err := r.getUser(s, userID); err != nil {
+		return err
+	}
+
+	selector := bson.M{"_id": articleID, "status": bson.M{"$nin": unpublished}}
+	if err := s.articles().Update(selector, bson.M{"$pull": bson.M{"starred_by": userID}}); err != nil {
+		if err == mgo.ErrNotFound {
+			return db.ErrNoSuchArticle
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *repository) ListStarredArticles(ctx context.Context, userID string) ([]api.Article, error) {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	if _, err := r.getUser(s, userID); err != nil {
+		return nil, err
+	}
+
+	articles := ArticleList{}
+	if err := s.bounded(s.articles().Find(bson.M{"starred_by": userID}).Sort("-published_at")).All(&articles); err != nil {
+		return nil, s.err(err)
+	}
+	return articles.toAPI(), nil
+}
+
+func (r *repository) ListUnsummarizedArticles(ctx context.Context, afterID string, limit int) ([]api.Article, error) {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	// Empty summaries are not stored, missing ones are matched by null
+	selector := bson.M{"summary": bson.M{"$in": []interface{}{nil, ""}}, "_id": bson.M{"$gt": afterID}}
+	articles := ArticleList{}
+	if err := s.articles().Find(selector).Sort("_id").Limit(limit).All(&articles); err != nil {
+		return nil, s.err(err)
+	}
+	return articles.toAPI(), nil
+}
+
+func (r *repository) SetArticleSummary(ctx context.Context, feedID string, articleID string, summary string) error {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	selector := bson.M{"_id": articleID, "feed_id": feedID}
+	if err := s.articles().Update(selector, bson.M{"$set": bson.M{"summary": summary}}); err != nil {
+		if err == mgo.ErrNotFound {
+			return db.ErrNoSuchArticle
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *repository) CreateIdempotencyRecord(ctx context.Context, record db.IdempotencyRecord) error {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	// Replaces an expired record with the same key, fails on the unique _id if an unexpired one exists
+	selector := bson.M{"_id": record.Key, "expires_at": bson.M{"$lt": time.Now()}}
+	if _, err := s.idempotency().Upsert(selector, newIdempotencyRecord(&record)); err != nil {
+		if mgo.IsDup(err) {
+			return db.ErrIdempotencyKeyExists
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *repository) GetIdempotencyRecord(ctx context.Context, key string) (*db.IdempotencyRecord, error) {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	var record IdempotencyRecord
+	selector := bson.M{"_id": key, "expires_at": bson.M{"$gte": time.Now()}}
+	if err := s.idempotency().Find(selector).One(&record); err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, db.ErrNoSuchIdempotencyKey
+		}
+		return nil, err
+	}
+	return record.toDB(), nil
+}
+
+func (r *repository) CompleteIdempotencyRecord(ctx context.Context, key string, status int, contentType string, body []byte,
+	expiresTime time.Time) error {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	updator := bson.M{"$set": bson.M{"status": status, "content_type": contentType, "body": body, "expires_at": expiresTime}}
+	if err := s.idempotency().UpdateId(key, updator); err != nil {
+		if err == mgo.ErrNotFound {
+			return db.ErrNoSuchIdempotencyKey
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *repository) DeleteIdempotencyRecord(ctx context.Context, key string) error {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	if err := s.idempotency().RemoveId(key); err != nil && err != mgo.ErrNotFound {
+		return err
+	}
+	return nil
+}
+
+func (r *repository) Close() {
+	r.mgoSession.Close()
+}
+
+func (r *repository) SearchArticles(ctx context.Context, query *search.Query, feedIDs []string, offset int, limit int) (*search.Results, error) {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	// The text index finds Articles containing any of the terms, clauses narrow them down to exact matches
+	clauses := []bson.M{}
+	for _, c := range query.Clauses {
+		clauses = append(clauses, clauseSelector(c))
+	}
+	selector := bson.M{
+		"$text": bson.M{"$search": strings.Join(query.Terms(), " ")},
+		"$and":  clauses,
+		"$or":   visible(time.Now()),
+	}
+	if feedIDs != nil {
+		selector["feed_id"] = bson.M{"$in": feedIDs}
+	}
+
+	q := s.bounded(s.articles().Find(selector))
+	total, err := q.Count()
+	if err != nil {
+		return nil, s.err(err)
+	}
+
+	articles := []ScoredArticle{}
+	err = q.Select(bson.M{"score": bson.M{"$meta": "textScore"}}).
+		Sort("$textScore:score", "-published_at").
+		Skip(offset).
+		Limit(limit).
+		All(&articles)
+	if err != nil {
+		return nil, s.err(err)
+	}
+
+	results := &search.Results{Total: total, Hits: []search.Hit{}}
+	for _, a := range articles {
+		results.Hits = append(results.Hits, search.Hit{
+			Article: *a.toAPI(),
+			FeedID:  a.FeedID,
+			Score:   a.Score,
+		})
+	}
+	return results, nil
+}
+
+// clauseSelector matches the terms of a search clause in sequence, as whole words and ignoring case
+func clauseSelector(c search.Clause) bson.M {
+	const boundary = `[^\p{L}\p{N}]`
+	terms := make([]string, len(c.Terms))
+	for i, t := range c.Terms {
+		terms[i] = regexp.QuoteMeta(t)
+	}
+	pattern := fmt.Sprintf("(^|%s)%s(%s|$)", boundary, strings.Join(terms, boundary+"+"), boundary)
+	regex := bson.RegEx{Pattern: pattern, Options: "i"}
+
+	if c.Field != "" {
+		return bson.M{c.Field: regex}
+	}
+	fields := []bson.M{}
+	for _, f := range search.Fields {
+		fields = append(fields, bson.M{f: regex})
+	}
+	return bson.M{"$or": fields}
+}
+
+func (r *repository) CreateFilterRule(ctx context.Context, userID string, rule api.FilterRule) (*api.FilterRule, error) {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	if _, err := r.getUser(s, userID); err != nil {
+		return nil, err
+	}
+
+	// Mongo stores times with millisecond precision
+	f := FilterRule{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		Type:        rule.Type,
+		Value:       rule.Value,
+		Action:      rule.Action,
+		CreatedTime: time.Now().Truncate(time.Millisecond),
+	}
+	if err := s.filterRules().Insert(f); err != nil {
+		return nil, err
+	}
+	return f.toAPI(), nil
+}
+
+func (r *repository) ListFilterRules(ctx context.Context, userID string) ([]api.FilterRule, error) {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	if _, err := r.getUser(s, userID); err != nil {
+		return nil, err
+	}
+
+	stored := []FilterRule{}
+	if err := s.bounded(s.filterRules().Find(bson.M{"user_id": userID}).Sort("created_at")).All(&stored); err != nil {
+		return nil, s.err(err)
+	}
+	rules := []api.FilterRule{}
+	for _, f := range stored {
+		rules = append(rules, *f.toAPI())
+	}
+	return rules, nil
+}
+
+func (r *repository) GetFilterRule(ctx context.Context, userID string, ruleID string) (*api.FilterRule, error) {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	if _, err := r.getUser(s, userID); err != nil {
+		return nil, err
+	}
+
+	var f FilterRule
+	if err := s.filterRules().Find(bson.M{"_id": ruleID, "user_id": userID}).One(&f); err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, db.ErrNoSuchFilterRule
+		}
+		return nil, err
+	}
+	return f.toAPI(), nil
+}
+
+func (r *repository) UpdateFilterRule(ctx context.Context, userID string, rule api.FilterRule) error {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	if _, err := r.getUser(s, userID); err != nil {
+		return err
+	}
+
+	update := bson.M{"$set": bson.M{"type": rule.Type, "value": rule.Value, "action": rule.Action}}
+	if err := s.filterRules().Update(bson.M{"_id": rule.ID, "user_id": userID}, update); err != nil {
+		if err == mgo.ErrNotFound {
+			return db.ErrNoSuchFilterRule
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *repository) DeleteFilterRule(ctx context.Context, userID string, ruleID string) error {
+	s := r.newSession(ctx)
+	defer s.close()
+
+	if _, err := r.getUser(s, userID); err != nil {
+		return err
+	}
+
+	if err := s.filterRules().Remove(bson.M{"_id": ruleID, "user_id": userID}); err != nil {
+		if err == mgo.ErrNotFound {
+			return db.ErrNoSuchFilterRule
+		}
+		return err
+	}
+	return nil
 }
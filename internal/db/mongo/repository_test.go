@@ -1,6 +1,7 @@
 package mongo
 
 import (
+	"context"
 	"log"
 	"os"
 	"sort"
@@ -10,6 +11,8 @@ import (
 	"github.com/google/uuid"
 	"github.com/if-ivan-else/tldrfeed/api"
 	"github.com/if-ivan-else/tldrfeed/internal/db"
+	"github.com/if-ivan-else/tldrfeed/internal/rules"
+	"github.com/if-ivan-else/tldrfeed/internal/search"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 )
@@ -23,9 +26,9 @@ const (
 	TestDB = "test-tldrfeed"
 )
 
-func testRepository() db.Repository {
+func testRepository(opts ...Option) db.Repository {
 	url := os.Getenv(EnvTestDB)
-	r, err := newRepository(url, TestDB, true)
+	r, err := newRepository(url, TestDB, true, opts...)
 	if err != nil {
 		if url == "" {
 			log.Fatal(errors.Errorf("Failed to connect to test DB: %s env var not set?", EnvTestDB))
@@ -50,13 +53,14 @@ func TestMain(m *testing.M) {
 }
 
 func TestUserOperations(t *testing.T) {
+	ctx := context.Background()
 	require := require.New(t)
 	r := testRepository()
 	defer r.Close()
 
 	name := "alexandra"
 	// Test creating User
-	u, err := r.CreateUser(name)
+	u, err := r.CreateUser(ctx, name)
 	require.NotNil(u)
 	require.NoError(err)
 	require.NotEmpty(u.ID)
@@ -64,30 +68,31 @@ func TestUserOperations(t *testing.T) {
 
 	// Test retrieving User
 	var getUser *api.User
-	getUser, err = r.GetUser(u.ID)
+	getUser, err = r.GetUser(ctx, u.ID)
 	require.NoError(err)
 	require.Equal(name, getUser.Name)
 	require.Equal(u.ID, getUser.ID)
 
 	// Test listing Users
 	listUsers := []api.User{}
-	listUsers, err = r.ListUsers()
+	listUsers, err = r.ListUsers(ctx, db.Page{})
 	require.NoError(err)
 	require.Len(listUsers, 1)
 	require.Equal(*u, listUsers[0])
 
 	// Test rerieving unknown User
-	_, err = r.GetUser(uuid.New().String())
+	_, err = r.GetUser(ctx, uuid.New().String())
 	require.Equal(db.ErrNoSuchUser, err)
 }
 
 func TestFeedOperations(t *testing.T) {
+	ctx := context.Background()
 	require := require.New(t)
 	r := testRepository()
 	defer r.Close()
 
 	name := "Romanoff Royal Blog"
-	f, err := r.CreateFeed(name)
+	f, err := r.CreateFeed(ctx, name, "", nil)
 	require.NotNil(f)
 	require.NoError(err)
 	require.NotEmpty(f.ID)
@@ -95,53 +100,53 @@ func TestFeedOperations(t *testing.T) {
 
 	// Test retrieving the Feed
 	var getFeed *api.Feed
-	getFeed, err = r.GetFeed(f.ID)
+	getFeed, err = r.GetFeed(ctx, f.ID)
 	require.NoError(err)
 	require.Equal(name, getFeed.Name)
 	require.Equal(f.ID, getFeed.ID)
 
 	// Test listing Feeds
 	listFeeds := []api.Feed{}
-	listFeeds, err = r.ListFeeds()
+	listFeeds, err = r.ListFeeds(ctx, db.FeedFilter{})
 	require.Len(listFeeds, 1)
 	require.Equal(*f, listFeeds[0])
 
 	// Test rerieving unknown Feed
-	_, err = r.GetFeed(uuid.New().String())
+	_, err = r.GetFeed(ctx, uuid.New().String())
 	require.Equal(db.ErrNoSuchFeed, err)
 
 	// Test retrieving Feeds for non-existent user
-	_, err = r.GetUserFeed(uuid.New().String(), uuid.New().String())
+	_, err = r.GetUserFeed(ctx, uuid.New().String(), uuid.New().String())
 	require.Equal(db.ErrNoSuchUser, err)
 
 	// Create Test User
 	var u *api.User
-	u, _ = r.CreateUser("natasha")
+	u, _ = r.CreateUser(ctx, "natasha")
 	require.NotNil(u)
 
 	// Make sure the Feed is not subscribed
-	listFeeds, err = r.ListUserFeeds(u.ID)
+	listFeeds, err = r.ListUserFeeds(ctx, u.ID)
 	require.Len(listFeeds, 0)
 
-	getFeed, err = r.GetUserFeed(u.ID, f.ID)
+	getFeed, err = r.GetUserFeed(ctx, u.ID, f.ID)
 	require.Equal(db.ErrNotSubscribed, err)
 
 	// Test subscribing User to the Feed
-	err = r.AddUserFeed(u.ID, f.ID)
+	err = r.AddUserFeed(ctx, u.ID, f.ID)
 	require.NoError(err)
 
 	// Test enumerating Feeds for the User
-	listFeeds, err = r.ListUserFeeds(u.ID)
+	listFeeds, err = r.ListUserFeeds(ctx, u.ID)
 	require.Len(listFeeds, 1)
 	require.Equal(*f, listFeeds[0])
 
 	// Test retrieving Feeds for the User
-	getFeed, err = r.GetUserFeed(u.ID, f.ID)
+	getFeed, err = r.GetUserFeed(ctx, u.ID, f.ID)
 	require.NoError(err)
 	require.Equal(f, getFeed)
 
 	// Test retrieveing non-existent Feed subscription
-	getFeed, err = r.GetUserFeed(u.ID, uuid.New().String())
+	getFeed, err = r.GetUserFeed(ctx, u.ID, uuid.New().String())
 	require.Equal(db.ErrNotSubscribed, err)
 }
 
@@ -219,50 +224,115 @@ var feedData = map[string]feedArticleData{
 }
 
 func TestArticleOperations(t *testing.T) {
+	ctx := context.Background()
 	require := require.New(t)
 	r := testRepository()
 	defer r.Close()
 
 	for name, entries := range feedData {
-		f, err := r.CreateFeed(name)
+		f, err := r.CreateFeed(ctx, name, "", nil)
 		require.NoError(err)
 		require.NotNil(f)
+		var version *db.FeedVersion
+		version, err = r.GetFeedVersion(ctx, f.ID)
+		require.NoError(err)
+		require.Equal(int64(1), version.Version)
 		before := timeBefore()
 		// Test creating Articles
 		for _, e := range entries {
 			var articleID string
-			articleID, err = r.CreateFeedArticle(f.ID, e["title"], e["body"])
+			articleID, err = r.CreateFeedArticle(ctx, f.ID, api.Article{Title: e["title"], Body: e["body"]})
 			require.NoError(err)
 			require.NotEmpty(articleID)
 		}
 		// Test retrieving Articles
 		var articles []api.Article
-		articles, err = r.ListFeedArticles(f.ID)
+		articles, err = r.ListFeedArticles(ctx, f.ID, db.ArticleFilter{})
 		require.NoError(err)
 		require.Len(articles, len(entries))
 		require.True(articles[0].PublishedTime.After(before))
 
 		collected := collectArticles(articles)
 		require.ElementsMatch(entries, collected)
+
+		// Test counting recently published Articles
+		var count int
+		count, err = r.CountFeedArticles(ctx, f.ID, before)
+		require.NoError(err)
+		require.Equal(len(entries), count)
+		count, err = r.CountFeedArticles(ctx, f.ID, time.Now().Add(time.Minute))
+		require.NoError(err)
+		require.Zero(count)
+
+		// Test every Article bumping the Feed version
+		version, err = r.GetFeedVersion(ctx, f.ID)
+		require.NoError(err)
+		require.Equal(int64(1+len(entries)), version.Version)
+		require.True(version.UpdatedTime.After(before))
 	}
 
+	// Test versions and publishing for an unknown Feed
+	_, err := r.GetFeedVersion(ctx, uuid.New().String())
+	require.Equal(db.ErrNoSuchFeed, err)
+	_, err = r.CreateFeedArticle(ctx, uuid.New().String(), api.Article{Title: "title", Body: "body"})
+	require.Equal(db.ErrNoSuchFeed, err)
+
+	// Test storing optional Article fields, backfilled Articles count as added now rather than when published
+	backfilled, err := r.CreateFeed(ctx, "Backfills", "", nil)
+	require.NoError(err)
+	published := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	article := api.Article{
+		Title:         "title",
+		Body:          "First sentence. Second sentence.",
+		Summary:       "First sentence.",
+		Author:        "Lev Tolstoy",
+		URL:           "https://example.com/articles/1",
+		ImageURL:      "https://example.com/images/1.png",
+		Tags:          []string{"classics", "novels"},
+		PublishedTime: published,
+		UpdatedTime:   published.Add(time.Hour),
+	}
+	article.ID, err = r.CreateFeedArticle(ctx, backfilled.ID, article)
+	require.NoError(err)
+	stored, err := r.ListFeedArticles(ctx, backfilled.ID, db.ArticleFilter{})
+	require.NoError(err)
+	require.Len(stored, 1)
+	require.True(article.PublishedTime.Equal(stored[0].PublishedTime))
+
+	// Test reading Feeds and their Articles in batches, unknown Feeds are left out
+	batchFeeds, err := r.GetFeeds(ctx, []string{backfilled.ID, uuid.New().String()})
+	require.NoError(err)
+	require.Equal([]api.Feed{*backfilled}, batchFeeds)
+	batchArticles, err := r.ListArticlesOfFeeds(ctx, []string{backfilled.ID, uuid.New().String()}, db.ArticleFilter{}, db.Page{})
+	require.NoError(err)
+	require.Len(batchArticles, 1)
+	require.Equal(article.ID, batchArticles[0].ID)
+	require.True(article.UpdatedTime.Equal(stored[0].UpdatedTime))
+	stored[0].PublishedTime, stored[0].UpdatedTime = article.PublishedTime, article.UpdatedTime
+	article.FeedID, article.ClusterID, article.Status = backfilled.ID, article.ID, api.StatusPublished
+	require.Equal(article, stored[0])
+
+	count, err := r.CountFeedArticles(ctx, backfilled.ID, timeBefore())
+	require.NoError(err)
+	require.Equal(1, count)
+
 	// Test retrieving Articles for an unknown Feed
-	_, err := r.ListFeedArticles(uuid.New().String())
+	_, err = r.ListFeedArticles(ctx, uuid.New().String(), db.ArticleFilter{})
 	require.Equal(db.ErrNoSuchFeed, err)
 
 	var u *api.User
-	u, _ = r.CreateUser("alexandra")
+	u, _ = r.CreateUser(ctx, "alexandra")
 	require.NotNil(u)
 
 	var feeds []api.Feed
-	feeds, err = r.ListFeeds()
+	feeds, err = r.ListFeeds(ctx, db.FeedFilter{})
 
 	// Test subscribing User to the Feed
-	err = r.AddUserFeed(u.ID, feeds[0].ID)
+	err = r.AddUserFeed(ctx, u.ID, feeds[0].ID)
 	require.NoError(err)
 
 	var userArticles []api.Article
-	userArticles, err = r.ListUserArticles(u.ID)
+	userArticles, err = r.ListUserArticles(ctx, u.ID, db.ArticleFilter{})
 
 	require.NoError(err)
 	collected := collectArticles(userArticles)
@@ -275,14 +345,747 @@ func TestArticleOperations(t *testing.T) {
 	require.Equal(sorted, userArticles)
 
 	// Subscribe to a different feed - we should see articles from both feeds
-	err = r.AddUserFeed(u.ID, feeds[1].ID)
+	var timeline *db.TimelineVersion
+	timeline, err = r.GetTimelineVersion(ctx, u.ID)
 	require.NoError(err)
+	require.Len(timeline.Feeds, 1)
+	subscribed := timeline.SubscriptionsUpdatedTime
+	require.False(subscribed.IsZero())
+
+	err = r.AddUserFeed(ctx, u.ID, feeds[1].ID)
+	require.NoError(err)
+	timeline, err = r.GetTimelineVersion(ctx, u.ID)
+	require.NoError(err)
+	require.Len(timeline.Feeds, 2)
+	require.False(timeline.SubscriptionsUpdatedTime.Before(subscribed))
 	var moreArticles []api.Article
-	moreArticles, err = r.ListUserArticles(u.ID)
+	moreArticles, err = r.ListUserArticles(ctx, u.ID, db.ArticleFilter{})
 	require.Len(moreArticles, len(feedData[feeds[0].Name])+len(feedData[feeds[1].Name]))
 
 	var feedArticles []api.Article
-	feedArticles, err = r.ListUserFeedArticles(u.ID, feeds[1].ID)
+	feedArticles, err = r.ListUserFeedArticles(ctx, u.ID, feeds[1].ID, db.ArticleFilter{})
 	collected = collectArticles(feedArticles)
 	require.ElementsMatch(feedData[feeds[1].Name], collected)
 }
+
+func TestSearchArticles(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	r := testRepository()
+	defer r.Close()
+
+	russian, err := r.CreateFeed(ctx, "Russian Classics", "", nil)
+	require.NoError(err)
+	french, err := r.CreateFeed(ctx, "French Classics", "", nil)
+	require.NoError(err)
+	_, err = r.CreateFeedArticle(ctx, russian.ID, api.Article{Title: "War and Peace", Body: "Napoleon invades Russia"})
+	require.NoError(err)
+	_, err = r.CreateFeedArticle(ctx, russian.ID, api.Article{Title: "Anna Karenina", Body: "Levin thinks about peace and war on his farm"})
+	require.NoError(err)
+	_, err = r.CreateFeedArticle(ctx, french.ID, api.Article{Title: "Les Misérables", Body: "War and peace in Paris"})
+	require.NoError(err)
+
+	titles := func(raw string, feedIDs []string) []string {
+		q, err := search.ParseQuery(raw)
+		require.NoError(err)
+		results, err := r.SearchArticles(ctx, q, feedIDs, 0, 10)
+		require.NoError(err)
+		require.Equal(len(results.Hits), results.Total)
+		titles := []string{}
+		for _, h := range results.Hits {
+			titles = append(titles, h.Article.Title)
+		}
+		return titles
+	}
+
+	// Title matches rank first
+	require.Equal("War and Peace", titles("war", nil)[0])
+	require.ElementsMatch([]string{"War and Peace", "Les Misérables"}, titles(`"war and peace"`, nil))
+	require.ElementsMatch([]string{"Anna Karenina", "Les Misérables"}, titles("body:war", nil))
+	require.Equal([]string{"Anna Karenina"}, titles("war levin", nil))
+	require.Equal([]string{"Les Misérables"}, titles("war", []string{french.ID}))
+	require.Empty(titles("tolstoy", nil))
+
+	q, err := search.ParseQuery("war")
+	require.NoError(err)
+	page, err := r.SearchArticles(ctx, q, nil, 1, 1)
+	require.NoError(err)
+	require.Equal(3, page.Total)
+	require.Len(page.Hits, 1)
+}
+
+func TestTagsAndDiscovery(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	r := testRepository()
+	defer r.Close()
+
+	// The test DB is shared, so tags and categories are made unique to this run
+	run := uuid.New().String()[:8]
+	category := "poetry-" + run
+	poetry, err := r.CreateFeed(ctx, "Pushkin Poetry Hour", category, nil)
+	require.NoError(err)
+	require.Equal(category, poetry.Category)
+	prose, err := r.CreateFeed(ctx, "Tolstoy Unabridged", "", nil)
+	require.NoError(err)
+
+	feeds, err := r.ListFeeds(ctx, db.FeedFilter{Category: category})
+	require.NoError(err)
+	require.Equal([]api.Feed{*poetry}, feeds)
+
+	verse, classic := "verse-"+run, "classic-"+run
+	_, err = r.CreateFeedArticle(ctx, poetry.ID, api.Article{Title: "Winter Morning", Body: "Frost and sun", Tags: []string{verse, classic}})
+	require.NoError(err)
+	_, err = r.CreateFeedArticle(ctx, poetry.ID, api.Article{Title: "The Prophet", Body: "Parched with spiritual thirst", Tags: []string{verse}})
+	require.NoError(err)
+	_, err = r.CreateFeedArticle(ctx, prose.ID, api.Article{Title: "War and Peace", Body: "Well, Prince", Tags: []string{classic}})
+	require.NoError(err)
+
+	articles, err := r.ListFeedArticles(ctx, poetry.ID, db.ArticleFilter{Tag: classic})
+	require.NoError(err)
+	require.Len(articles, 1)
+	require.Equal("Winter Morning", articles[0].Title)
+
+	tags, err := r.ListTags(ctx)
+	require.NoError(err)
+	counts := map[string]int{}
+	for _, t := range tags {
+		counts[t.Tag] = t.Articles
+	}
+	require.Equal(2, counts[verse])
+	require.Equal(2, counts[classic])
+
+	// Counts are reused until they expire
+	_, err = r.CreateFeedArticle(ctx, prose.ID, api.Article{Title: "Anna Karenina", Body: "Happy families", Tags: []string{classic}})
+	require.NoError(err)
+	cached, err := r.ListTags(ctx)
+	require.NoError(err)
+	require.Equal(tags, cached)
+
+	// Expired counts are aggregated again once for callers listing tags at the same time
+	repo := r.(*repository)
+	repo.tagCountsMu.Lock()
+	repo.tagCountsExpiry = time.Time{}
+	repo.tagCountsMu.Unlock()
+	refreshed := make(chan []api.TagCount, 4)
+	errs := make(chan error, cap(refreshed))
+	for i := 0; i < cap(refreshed); i++ {
+		go func() {
+			tags, err := r.ListTags(ctx)
+			errs <- err
+			refreshed <- tags
+		}()
+	}
+	for i := 0; i < cap(refreshed); i++ {
+		require.NoError(<-errs)
+		counts := map[string]int{}
+		for _, t := range <-refreshed {
+			counts[t.Tag] = t.Articles
+		}
+		require.Equal(3, counts[classic])
+	}
+
+	u, err := r.CreateUser(ctx, "alexandra")
+	require.NoError(err)
+	require.NoError(r.AddUserFeed(ctx, u.ID, prose.ID))
+
+	userArticles, err := r.ListUserArticles(ctx, u.ID, db.ArticleFilter{Tag: verse})
+	require.NoError(err)
+	require.Empty(userArticles)
+
+	stats, err := r.DiscoverFeeds(ctx, u.ID, category)
+	require.NoError(err)
+	require.Len(stats, 1)
+	require.Equal(*poetry, stats[0].Feed)
+	require.Equal(0, stats[0].Subscribers)
+	require.False(stats[0].UpdatedTime.IsZero())
+
+	stats, err = r.DiscoverFeeds(ctx, u.ID, "")
+	require.NoError(err)
+	for _, s := range stats {
+		require.NotEqual(prose.ID, s.Feed.ID)
+	}
+
+	_, err = r.DiscoverFeeds(ctx, uuid.New().String(), "")
+	require.Equal(db.ErrNoSuchUser, err)
+}
+
+func TestFilterRules(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	r := testRepository()
+	defer r.Close()
+
+	u, err := r.CreateUser(ctx, "alexandra")
+	require.NoError(err)
+	news, err := r.CreateFeed(ctx, "Chaikovsky Breaking News", "", nil)
+	require.NoError(err)
+	require.NoError(r.AddUserFeed(ctx, u.ID, news.ID))
+	_, err = r.CreateFeedArticle(ctx, news.ID, api.Article{Title: "Election Night", Body: "Polls close at eight"})
+	require.NoError(err)
+	_, err = r.CreateFeedArticle(ctx, news.ID, api.Article{Title: "Cup final", Body: "Highlights of the match"})
+	require.NoError(err)
+
+	rule, err := r.CreateFilterRule(ctx, u.ID, api.FilterRule{Type: api.FilterKeyword, Value: "election", Action: api.FilterExclude})
+	require.NoError(err)
+	require.NotEmpty(rule.ID)
+
+	stored, err := r.ListFilterRules(ctx, u.ID)
+	require.NoError(err)
+	require.Equal([]api.FilterRule{*rule}, stored)
+
+	set, err := rules.Compile(stored)
+	require.NoError(err)
+	articles, err := r.ListUserArticles(ctx, u.ID, db.ArticleFilter{Rules: set})
+	require.NoError(err)
+	require.Len(articles, 1)
+	require.Equal("Cup final", articles[0].Title)
+	articles, err = r.ListUserFeedArticles(ctx, u.ID, news.ID, db.ArticleFilter{Rules: set})
+	require.NoError(err)
+	require.Len(articles, 1)
+
+	rule.Value = "final"
+	require.NoError(r.UpdateFilterRule(ctx, u.ID, *rule))
+	updated, err := r.GetFilterRule(ctx, u.ID, rule.ID)
+	require.NoError(err)
+	require.Equal("final", updated.Value)
+
+	other, err := r.CreateUser(ctx, "boris")
+	require.NoError(err)
+	_, err = r.GetFilterRule(ctx, other.ID, rule.ID)
+	require.Equal(db.ErrNoSuchFilterRule, err)
+	require.Equal(db.ErrNoSuchFilterRule, r.DeleteFilterRule(ctx, other.ID, rule.ID))
+
+	require.NoError(r.DeleteFilterRule(ctx, u.ID, rule.ID))
+	stored, err = r.ListFilterRules(ctx, u.ID)
+	require.NoError(err)
+	require.Empty(stored)
+	require.Equal(db.ErrNoSuchFilterRule, r.UpdateFilterRule(ctx, u.ID, *rule))
+}
+
+func TestDuplicateArticles(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	r := testRepository()
+	defer r.Close()
+
+	const story = `The city council approved the new budget on Monday after a six hour debate. The budget raises
+spending on public transport and road repairs, while critics say it ignores housing.`
+
+	link := "https://example.com/budget"
+	news, err := r.CreateFeed(ctx, "Chaikovsky Breaking News", "", nil)
+	require.NoError(err)
+	aggregator, err := r.CreateFeed(ctx, "Everything Aggregated", "", nil)
+	require.NoError(err)
+
+	originalID, err := r.CreateFeedArticle(ctx, news.ID, api.Article{Title: "Council approves budget", Body: story, URL: link})
+	require.NoError(err)
+	_, err = r.CreateFeedArticle(ctx, aggregator.ID, api.Article{Title: "Budget passes", Body: "See the link", URL: link + "/?utm_medium=rss"})
+	require.NoError(err)
+	_, err = r.CreateFeedArticle(ctx, aggregator.ID, api.Article{Title: "Council approves new budget", Body: story})
+	require.NoError(err)
+
+	articles, err := r.ListFeedArticles(ctx, aggregator.ID, db.ArticleFilter{})
+	require.NoError(err)
+	require.Len(articles, 2)
+	for _, a := range articles {
+		require.Equal(aggregator.ID, a.FeedID)
+		require.Equal(originalID, a.ClusterID)
+	}
+
+	articles, err = r.ListFeedArticles(ctx, news.ID, db.ArticleFilter{})
+	require.NoError(err)
+	require.Len(articles, 1)
+	require.Equal(originalID, articles[0].ClusterID)
+
+	// Candidates of a batch are looked up at once, each Article still joining the cluster of its own duplicate
+	digest, err := r.CreateFeed(ctx, "Daily Digest", "", nil)
+	require.NoError(err)
+	ids, err := r.CreateFeedArticles(ctx, digest.ID, []api.Article{
+		{Title: "Something else entirely", Body: "Weather is fine"},
+		{Title: "Budget", Body: "Read more", URL: link},
+	})
+	require.NoError(err)
+	require.Len(ids, 2)
+	articles, err = r.ListFeedArticles(ctx, digest.ID, db.ArticleFilter{})
+	require.NoError(err)
+	require.Len(articles, 2)
+	clusters := map[string]string{}
+	for _, a := range articles {
+		clusters[a.ID] = a.ClusterID
+	}
+	require.Equal(ids[0], clusters[ids[0]])
+	require.Equal(originalID, clusters[ids[1]])
+}
+
+func TestDrafts(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	r := testRepository()
+	defer r.Close()
+
+	f, err := r.CreateFeed(ctx, "Editorial", "", nil)
+	require.NoError(err)
+	u, err := r.CreateUser(ctx, "ivan")
+	require.NoError(err)
+	require.NoError(r.AddUserFeed(ctx, u.ID, f.ID))
+
+	_, err = r.CreateFeedArticle(ctx, f.ID, api.Article{Title: "Live", Body: "body", Tags: []string{"news"}})
+	require.NoError(err)
+	draftID, err := r.CreateFeedArticle(ctx, f.ID, api.Article{Title: "Draft", Body: "body", Tags: []string{"news"},
+		Status: api.StatusDraft, UpdatedTime: time.Now()})
+	require.NoError(err)
+	publishAt := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	scheduledID, err := r.CreateFeedArticle(ctx, f.ID, api.Article{Title: "Scheduled", Body: "body",
+		Status: api.StatusScheduled, PublishAt: &publishAt, PublishedTime: publishAt})
+	require.NoError(err)
+
+	// Unpublished Articles are left out of all listings
+	articles, err := r.ListFeedArticles(ctx, f.ID, db.ArticleFilter{})
+	require.NoError(err)
+	require.Len(articles, 1)
+	require.Equal(api.StatusPublished, articles[0].Status)
+	articles, err = r.ListUserArticles(ctx, u.ID, db.ArticleFilter{})
+	require.NoError(err)
+	require.Len(articles, 1)
+	tags, err := r.ListTags(ctx)
+	require.NoError(err)
+	require.Equal([]api.TagCount{{Tag: "news", Articles: 1}}, tags)
+
+	drafts, err := r.ListFeedDrafts(ctx, f.ID)
+	require.NoError(err)
+	require.Len(drafts, 2)
+	draft, err := r.GetFeedDraft(ctx, f.ID, draftID)
+	require.NoError(err)
+	require.Equal(api.StatusDraft, draft.Status)
+	_, err = r.GetFeedDraft(ctx, f.ID, uuid.New().String())
+	require.Equal(db.ErrNoSuchDraft, err)
+
+	draft.Body, draft.Status, draft.PublishedTime = "final body", api.StatusPublished, time.Now()
+	updated, err := r.UpdateFeedDraft(ctx, f.ID, *draft)
+	require.NoError(err)
+	require.Equal("final body", updated.Body)
+	require.Equal(api.StatusPublished, updated.Status)
+	_, err = r.UpdateFeedDraft(ctx, f.ID, *draft)
+	require.Equal(db.ErrArticlePublished, err)
+	require.Equal(db.ErrArticlePublished, r.DeleteFeedDraft(ctx, f.ID, draftID))
+
+	// Due Articles are published once
+	published, err := r.PublishDueArticles(ctx, time.Now())
+	require.NoError(err)
+	require.Empty(published)
+	published, err = r.PublishDueArticles(ctx, publishAt)
+	require.NoError(err)
+	require.Len(published, 1)
+	require.Equal(scheduledID, published[0].ID)
+	require.Equal(f.ID, published[0].FeedID)
+	published, err = r.PublishDueArticles(ctx, publishAt)
+	require.NoError(err)
+	require.Empty(published)
+
+	articles, err = r.ListFeedArticles(ctx, f.ID, db.ArticleFilter{})
+	require.NoError(err)
+	require.Len(articles, 3)
+	drafts, err = r.ListFeedDrafts(ctx, f.ID)
+	require.NoError(err)
+	require.Empty(drafts)
+}
+
+func TestRetention(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	r := testRepository()
+	defer r.Close()
+
+	f, err := r.CreateFeed(ctx, "Wire", "", nil)
+	require.NoError(err)
+	u, err := r.CreateUser(ctx, "ivan")
+	require.NoError(err)
+
+	now := time.Now()
+	ids := []string{}
+	for _, age := range []int{10, 5, 3, 1} {
+		id, err := r.CreateFeedArticle(ctx, f.ID, api.Article{Title: "title", Body: "body", PublishedTime: now.AddDate(0, 0, -age)})
+		require.NoError(err)
+		ids = append(ids, id)
+	}
+	_, err = r.CreateFeedArticle(ctx, f.ID, api.Article{Title: "Draft", Body: "body", Status: api.StatusDraft, UpdatedTime: now})
+	require.NoError(err)
+	require.NoError(r.StarArticle(ctx, u.ID, ids[0]))
+	require.NoError(r.StarArticle(ctx, u.ID, ids[0]))
+	require.Equal(db.ErrNoSuchArticle, r.StarArticle(ctx, u.ID, uuid.New().String()))
+
+	report, err := r.ReportExpiredArticles(ctx, f.ID, api.RetentionPolicy{MaxAgeDays: 4, MaxCount: 1}, now)
+	require.NoError(err)
+	require.Len(report.Expired, 2)
+	require.Equal(ids[1], report.Expired[0].ID)
+	require.Equal(api.ExpiryMaxAge, report.Expired[0].Reason)
+	require.Equal(ids[2], report.Expired[1].ID)
+	require.Equal(api.ExpiryMaxCount, report.Expired[1].Reason)
+	require.Len(report.Starred, 1)
+	require.Equal(ids[0], report.Starred[0].ID)
+
+	// Nothing expires without a policy
+	removed, err := r.ExpireFeedArticles(ctx, f.ID, now)
+	require.NoError(err)
+	require.Zero(removed)
+
+	require.NoError(r.SetFeedRetention(ctx, f.ID, &api.RetentionPolicy{MaxCount: 2}))
+	feed, err := r.GetFeed(ctx, f.ID)
+	require.NoError(err)
+	require.Equal(&api.RetentionPolicy{MaxCount: 2}, feed.Retention)
+	before, err := r.GetFeedVersion(ctx, f.ID)
+	require.NoError(err)
+	removed, err = r.ExpireFeedArticles(ctx, f.ID, now)
+	require.NoError(err)
+	require.Equal(1, removed)
+	// Expiry changes the Feed like any other removal, so that its validators change
+	after, err := r.GetFeedVersion(ctx, f.ID)
+	require.NoError(err)
+	require.Greater(after.Version, before.Version)
+
+	starred, err := r.ListStarredArticles(ctx, u.ID)
+	require.NoError(err)
+	require.Len(starred, 1)
+	require.NoError(r.UnstarArticle(ctx, u.ID, ids[0]))
+	removed, err = r.ExpireFeedArticles(ctx, f.ID, now)
+	require.NoError(err)
+	require.Equal(1, removed)
+
+	articles, err := r.ListFeedArticles(ctx, f.ID, db.ArticleFilter{})
+	require.NoError(err)
+	require.Len(articles, 2)
+	drafts, err := r.ListFeedDrafts(ctx, f.ID)
+	require.NoError(err)
+	require.Len(drafts, 1)
+
+	require.NoError(r.SetFeedRetention(ctx, f.ID, nil))
+	feed, err = r.GetFeed(ctx, f.ID)
+	require.NoError(err)
+	require.Nil(feed.Retention)
+}
+
+func TestCreateFeedArticles(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	r := testRepository()
+	defer r.Close()
+
+	f, err := r.CreateFeed(ctx, "Archive", "", nil)
+	require.NoError(err)
+	v, err := r.GetFeedVersion(ctx, f.ID)
+	require.NoError(err)
+
+	ids, err := r.CreateFeedArticles(ctx, f.ID, []api.Article{
+		{Title: "Older", Body: "body", PublishedTime: timeBefore()},
+		{Title: "Draft", Body: "body", Status: api.StatusDraft, UpdatedTime: time.Now()},
+		{Title: "Newer", Body: "body"},
+	})
+	require.NoError(err)
+	require.Len(ids, 3)
+
+	articles, err := r.ListFeedArticles(ctx, f.ID, db.ArticleFilter{})
+	require.NoError(err)
+	require.Len(articles, 2)
+	require.Equal(ids[2], articles[0].ID)
+	require.Equal(ids[0], articles[1].ID)
+	drafts, err := r.ListFeedDrafts(ctx, f.ID)
+	require.NoError(err)
+	require.Len(drafts, 1)
+	require.Equal(ids[1], drafts[0].ID)
+
+	// A batch is a single change to the Feed
+	updated, err := r.GetFeedVersion(ctx, f.ID)
+	require.NoError(err)
+	require.Equal(v.Version+1, updated.Version)
+
+	ids, err = r.CreateFeedArticles(ctx, f.ID, nil)
+	require.NoError(err)
+	require.Empty(ids)
+	_, err = r.CreateFeedArticles(ctx, uuid.New().String(), []api.Article{{Title: "title", Body: "body"}})
+	require.Equal(db.ErrNoSuchFeed, err)
+}
+
+func TestTimelines(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	r := testRepository(WithTimelines(1))
+	defer r.Close()
+
+	u, err := r.CreateUser(ctx, "reader")
+	require.NoError(err)
+	other, err := r.CreateUser(ctx, "other")
+	require.NoError(err)
+	quiet, err := r.CreateFeed(ctx, "Quiet", "", nil)
+	require.NoError(err)
+	popular, err := r.CreateFeed(ctx, "Popular", "", nil)
+	require.NoError(err)
+
+	// Articles added before subscribing are backfilled
+	old, err := r.CreateFeedArticle(ctx, quiet.ID, api.Article{Title: "Old", Body: "body", PublishedTime: timeBefore().Add(-time.Hour)})
+	require.NoError(err)
+	require.NoError(r.AddUserFeed(ctx, u.ID, quiet.ID))
+	require.NoError(r.AddUserFeed(ctx, u.ID, popular.ID))
+	// A second subscriber makes the Feed read on listing
+	require.NoError(r.AddUserFeed(ctx, other.ID, popular.ID))
+
+	hot, err := r.CreateFeedArticle(ctx, popular.ID, api.Article{Title: "Hot", Body: "body", PublishedTime: timeBefore()})
+	require.NoError(err)
+	draft, err := r.CreateFeedArticle(ctx, quiet.ID, api.Article{Title: "Draft", Body: "body", Status: api.StatusDraft})
+	require.NoError(err)
+	fresh, err := r.CreateFeedArticle(ctx, quiet.ID, api.Article{Title: "Fresh", Body: "body", Tags: []string{"news"}})
+	require.NoError(err)
+
+	ids := func(userID string, filter db.ArticleFilter) []string {
+		articles, err := r.ListUserArticles(ctx, userID, filter)
+		require.NoError(err)
+		res := []string{}
+		for _, a := range articles {
+			res = append(res, a.ID)
+		}
+		return res
+	}
+	require.Equal([]string{fresh, hot, old}, ids(u.ID, db.ArticleFilter{}))
+	require.Equal([]string{hot}, ids(other.ID, db.ArticleFilter{}))
+	require.Equal([]string{fresh}, ids(u.ID, db.ArticleFilter{Tag: "news"}))
+
+	// Publishing a draft adds it to the timeline
+	_, err = r.UpdateFeedDraft(ctx, quiet.ID, api.Article{ID: draft, Title: "Published", Body: "body", Status: api.StatusPublished})
+	require.NoError(err)
+	require.Equal([]string{draft, fresh, hot, old}, ids(u.ID, db.ArticleFilter{}))
+
+	// Rescheduling a scheduled Article moves it in the timeline
+	due := timeBefore().Add(-time.Minute)
+	scheduled, err := r.CreateFeedArticle(ctx, quiet.ID, api.Article{Title: "Scheduled", Body: "body",
+		Status: api.StatusScheduled, PublishAt: &due, PublishedTime: timeBefore().Add(-2 * time.Hour)})
+	require.NoError(err)
+	require.Equal([]string{draft, fresh, hot, old, scheduled}, ids(u.ID, db.ArticleFilter{}))
+	_, err = r.UpdateFeedDraft(ctx, quiet.ID, api.Article{ID: scheduled, Title: "Rescheduled", Body: "body",
+		Status: api.StatusScheduled, PublishAt: &due, PublishedTime: time.Now().Add(time.Second)})
+	require.NoError(err)
+	require.Equal([]string{scheduled, draft, fresh, hot, old}, ids(u.ID, db.ArticleFilter{}))
+
+	// A Feed stays read on listing when subscribers leave, unsubscribing removes its Articles either way
+	require.NoError(r.RemoveUserFeed(ctx, other.ID, popular.ID))
+	require.Empty(ids(other.ID, db.ArticleFilter{}))
+	require.NoError(r.RemoveUserFeed(ctx, u.ID, quiet.ID))
+	require.Equal([]string{hot}, ids(u.ID, db.ArticleFilter{}))
+	require.Equal(db.ErrNotSubscribed, r.RemoveUserFeed(ctx, u.ID, quiet.ID))
+
+	// Timelines are rebuilt when enabled again
+	require.NoError(r.AddUserFeed(ctx, u.ID, quiet.ID))
+	rebuilt, err := newRepository(os.Getenv(EnvTestDB), TestDB, false)
+	require.NoError(err)
+	rebuilt.Close()
+	rebuilt, err = newRepository(os.Getenv(EnvTestDB), TestDB, false, WithTimelines(1))
+	require.NoError(err)
+	defer rebuilt.Close()
+	articles, err := rebuilt.ListUserArticles(ctx, u.ID, db.ArticleFilter{})
+	require.NoError(err)
+	require.Len(articles, 5)
+}
+
+// benchmarkTimelines prepares users following feeds of a repository for listing timelines
+func benchmarkTimelines(b *testing.B, opts ...Option) (db.Repository, []string) {
+	ctx := context.Background()
+	const users, feeds, follows, articles = 50, 100, 20, 20
+
+	r := testRepository(opts...)
+	userIDs := []string{}
+	for i := 0; i < users; i++ {
+		u, err := r.CreateUser(ctx, "reader")
+		require.NoError(b, err)
+		userIDs = append(userIDs, u.ID)
+	}
+	for i := 0; i < feeds; i++ {
+		f, err := r.CreateFeed(ctx, "feed", "", nil)
+		require.NoError(b, err)
+		for j := 0; j < users; j++ {
+			if (i+j)%(feeds/follows) == 0 {
+				require.NoError(b, r.AddUserFeed(ctx, userIDs[j], f.ID))
+			}
+		}
+		batch := []api.Article{}
+		for j := 0; j < articles; j++ {
+			batch = append(batch, api.Article{Title: "title", Body: "body", PublishedTime: timeBefore().Add(-time.Duration(j) * time.Minute)})
+		}
+		_, err = r.CreateFeedArticles(ctx, f.ID, batch)
+		require.NoError(b, err)
+	}
+	return r, userIDs
+}
+
+func BenchmarkListUserArticles(b *testing.B) {
+	ctx := context.Background()
+	strategies := map[string][]Option{
+		"FanOutOnRead":  nil,
+		"FanOutOnWrite": {WithTimelines(1000)},
+	}
+	for name, opts := range strategies {
+		b.Run(name, func(b *testing.B) {
+			r, userIDs := benchmarkTimelines(b, opts...)
+			defer r.Close()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, err := r.ListUserArticles(ctx, userIDs[i%len(userIDs)], db.ArticleFilter{})
+				require.NoError(b, err)
+			}
+		})
+	}
+}
+
+func BenchmarkCreateFeedArticle(b *testing.B) {
+	ctx := context.Background()
+	strategies := map[string][]Option{
+		"FanOutOnRead":  nil,
+		"FanOutOnWrite": {WithTimelines(1000)},
+	}
+	for name, opts := range strategies {
+		b.Run(name, func(b *testing.B) {
+			r, userIDs := benchmarkTimelines(b, opts...)
+			defer r.Close()
+			feeds, err := r.ListUserFeeds(ctx, userIDs[0])
+			require.NoError(b, err)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, err := r.CreateFeedArticle(ctx, feeds[i%len(feeds)].ID, api.Article{Title: "title", Body: "body"})
+				require.NoError(b, err)
+			}
+		})
+	}
+}
+
+func TestDataset(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	r := testRepository()
+	defer r.Close()
+
+	user := api.User{ID: uuid.New().String(), Name: "restored"}
+	feed := db.FeedRecord{
+		Feed:        api.Feed{ID: uuid.New().String(), Name: "Restored", Retention: &api.RetentionPolicy{MaxCount: 5}},
+		Subscribers: []string{user.ID},
+	}
+	article := db.ArticleRecord{
+		Article:     api.Article{ID: uuid.New().String(), FeedID: feed.Feed.ID, Title: "Restored", Body: "body", PublishedTime: timeBefore()},
+		CreatedTime: timeBefore(),
+		StarredBy:   []string{user.ID},
+	}
+	rule := db.FilterRuleRecord{UserID: user.ID, Rule: api.FilterRule{ID: uuid.New().String(), Type: api.FilterTag, Value: "sports", Action: api.FilterExclude}}
+
+	require.NoError(r.RestoreUser(ctx, user, false))
+	require.NoError(r.RestoreFeed(ctx, feed, false))
+	require.NoError(r.RestoreArticle(ctx, article, false))
+	require.NoError(r.RestoreFilterRule(ctx, rule, false))
+	require.Equal(db.ErrRecordExists, r.RestoreUser(ctx, user, false))
+	require.Equal(db.ErrRecordExists, r.RestoreFeed(ctx, feed, false))
+	require.Equal(db.ErrRecordExists, r.RestoreArticle(ctx, article, false))
+	require.Equal(db.ErrRecordExists, r.RestoreFilterRule(ctx, rule, false))
+
+	feed.Feed.Name = "Replaced"
+	require.NoError(r.RestoreFeed(ctx, feed, true))
+	f, err := r.GetUserFeed(ctx, user.ID, feed.Feed.ID)
+	require.NoError(err)
+	require.Equal("Replaced", f.Name)
+	require.Equal(feed.Feed.Retention, f.Retention)
+
+	starred, err := r.ListStarredArticles(ctx, user.ID)
+	require.NoError(err)
+	require.Len(starred, 1)
+	require.Equal(article.Article.ID, starred[0].ID)
+
+	articles := []db.ArticleRecord{}
+	require.NoError(r.ExportArticles(ctx, func(a db.ArticleRecord) error {
+		articles = append(articles, a)
+		return nil
+	}))
+	require.Len(articles, 1)
+	require.Equal(article.StarredBy, articles[0].StarredBy)
+	require.Equal(article.Article.ID, articles[0].Article.ClusterID)
+
+	feeds := []db.FeedRecord{}
+	require.NoError(r.ExportFeeds(ctx, func(f db.FeedRecord) error {
+		feeds = append(feeds, f)
+		return nil
+	}))
+	require.Len(feeds, 1)
+	require.Equal(feed.Subscribers, feeds[0].Subscribers)
+}
+
+func TestCanceledContext(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	r := testRepository()
+	defer r.Close()
+
+	u, err := r.CreateUser(ctx, "reader")
+	require.NoError(err)
+	f, err := r.CreateFeed(ctx, "News", "", nil)
+	require.NoError(err)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = r.ListUserArticles(canceled, u.ID, db.ArticleFilter{})
+	require.Equal(context.Canceled, err)
+	require.Equal(context.Canceled, r.AddUserFeed(canceled, u.ID, f.ID))
+
+	expired, cancel := context.WithDeadline(ctx, time.Now().Add(-time.Second))
+	defer cancel()
+	_, err = r.ListFeedArticles(expired, f.ID, db.ArticleFilter{})
+	require.Equal(context.DeadlineExceeded, err)
+
+	feeds, err := r.ListUserFeeds(ctx, u.ID)
+	require.NoError(err)
+	require.Empty(feeds)
+}
+
+func TestAuditLog(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	r := testRepository()
+	defer r.Close()
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	appended := []api.AuditRecord{
+		{ID: "b", Time: now, Principal: "editor", RequestID: "req-1", Action: api.AuditCreate, EntityType: api.AuditFeed, EntityID: "f1", After: []byte(`{"name":"Hot"}`)},
+		{ID: "a", Time: now, Principal: "editor", Action: api.AuditCreate, EntityType: api.AuditUser, EntityID: "u1", After: []byte(`{"name":"reader"}`)},
+		{ID: "c", Time: now.Add(time.Second), Principal: "scheduler", Action: api.AuditPublish, EntityType: api.AuditArticle, EntityID: "a1"},
+		{ID: "d", Time: now.Add(2 * time.Second), Principal: "editor", Action: api.AuditDelete, EntityType: api.AuditFeed, EntityID: "f1", Before: []byte(`{"name":"Hot"}`)},
+	}
+	for _, record := range appended {
+		require.NoError(r.AppendAuditRecord(ctx, record))
+	}
+
+	// Records list oldest first, records of the same time by ID
+	records, err := r.ListAuditRecords(ctx, db.AuditFilter{})
+	require.NoError(err)
+	require.Len(records, 4)
+	ids := []string{}
+	for _, record := range records {
+		ids = append(ids, record.ID)
+	}
+	require.Equal([]string{"a", "b", "c", "d"}, ids)
+	require.True(now.Equal(records[1].Time))
+	require.Equal("req-1", records[1].RequestID)
+	require.JSONEq(`{"name":"Hot"}`, string(records[1].After))
+	require.Nil(records[1].Before)
+
+	for filter, expected := range map[*db.AuditFilter][]string{
+		{EntityType: api.AuditFeed, EntityID: "f1"}:    {"b", "d"},
+		{Principal: "editor", Action: api.AuditCreate}: {"a", "b"},
+		{Since: now.Add(time.Second)}:                  {"c", "d"},
+		{Until: now.Add(time.Second)}:                  {"a", "b"},
+		{Limit: 3}:                                     {"b", "c", "d"},
+		{Principal: "janitor"}:                         {},
+	} {
+		records, err := r.ListAuditRecords(ctx, *filter)
+		require.NoError(err)
+		ids := []string{}
+		for _, record := range records {
+			ids = append(ids, record.ID)
+		}
+		require.Equal(expected, ids, "%+v", *filter)
+	}
+}
@@ -0,0 +1,328 @@
+package mongo
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+	"github.com/pkg/errors"
+)
+
+const (
+	// TimelinesCollection contains references to the Articles of Users' materialized timelines
+	TimelinesCollection = "timelines"
+	// SettingsCollection contains settings the stored data depends on
+	SettingsCollection = "settings"
+
+	// timelinesSetting marks timelines as materialized and kept up to date
+	timelinesSetting = "timelines"
+	// timelineChunk is the number of timeline Articles looked up at once
+	timelineChunk = 500
+)
+
+// Option customizes the MongoDB repository
+type Option func(*repository)
+
+// WithTimelines materializes Users' timelines: Articles are fanned out to the timelines of their Feed's subscribers
+// as they are added, so that listing a timeline does not query all the Feeds a User follows. Feeds which ever had more
+// than maxSubscribers subscribers are read on listing instead, copying their Articles would cost more than it saves.
+func WithTimelines(maxSubscribers int) Option {
+	return func(r *repository) {
+		r.timelines = true
+		r.maxFanOut = maxSubscribers
+	}
+}
+
+// TimelineEntry is a Mongo document referencing an Article in a User's timeline
+type TimelineEntry struct {
+	// ID is made of the User and Article IDs so that an Article is fanned out to a User at most once
+	ID            string    `bson:"_id"`
+	UserID        string    `bson:"user_id"`
+	FeedID        string    `bson:"feed_id"`
+	ArticleID     string    `bson:"article_id"`
+	PublishedTime time.Time `bson:"published_at"`
+}
+
+func newTimelineEntry(userID string, a *Article) TimelineEntry {
+	return TimelineEntry{
+		ID:            userID + "/" + a.ID,
+		UserID:        userID,
+		FeedID:        a.FeedID,
+		ArticleID:     a.ID,
+		PublishedTime: a.PublishedTime,
+	}
+}
+
+func (s *session) timelines() *mgo.Collection {
+	return s.collection(TimelinesCollection)
+}
+
+func (s *session) settings() *mgo.Collection {
+	return s.collection(SettingsCollection)
+}
+
+// initTimelines creates the timeline indexes and rebuilds all timelines if they were not kept up to date, i.e. the
+// repository was last used without timelines. Without timelines the setting is cleared so that enabling them again
+// rebuilds them.
+func (r *repository) initTimelines() error {
+	s := r.newSession(context.Background())
+	defer s.close()
+
+	if !r.timelines {
+		if err := s.settings().RemoveId(timelinesSetting); err != nil && err != mgo.ErrNotFound {
+			return errors.Wrap(err, "Failed to clear timelines setting")
+		}
+		return nil
+	}
+
+	if err := s.timelines().EnsureIndexKey("user_id", "-published_at"); err != nil {
+		return errors.Wrap(err, "Failed to create timeline index")
+	}
+	for _, key := range []string{"feed_id", "article_id"} {
+		if err := s.timelines().EnsureIndexKey(key); err != nil {
+			return errors.Wrapf(err, "Failed to create timeline %s index", key)
+		}
+	}
+
+	n, err := s.settings().FindId(timelinesSetting).Count()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	log.Printf("Rebuilding User timelines")
+	if _, err := s.timelines().RemoveAll(nil); err != nil {
+		return errors.Wrap(err, "Failed to clear timelines")
+	}
+	feeds := FeedList{}
+	if err := s.feeds().Find(nil).All(&feeds); err != nil {
+		return err
+	}
+	for i := range feeds {
+		if err := r.updateFanOut(s, &feeds[i]); err != nil {
+			return err
+		}
+		for _, userID := range feeds[i].Users {
+			if err := r.backfillTimeline(s, userID, &feeds[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return s.settings().Insert(bson.M{"_id": timelinesSetting, "updated_at": time.Now()})
+}
+
+// updateFanOut switches a Feed with more than maxFanOut subscribers to being read on listing, for good so that
+// the timelines never miss Articles added while it was popular
+func (r *repository) updateFanOut(s *session, f *Feed) error {
+	if f.FanOutOnRead || len(f.Users) <= r.maxFanOut {
+		return nil
+	}
+	if err := s.feeds().UpdateId(f.ID, bson.M{"$set": bson.M{"fan_out_on_read": true}}); err != nil {
+		return err
+	}
+	f.FanOutOnRead = true
+	_, err := s.timelines().RemoveAll(bson.M{"feed_id": f.ID})
+	return err
+}
+
+// fanOut adds Articles to the timelines of their Feed's subscribers, drafts are added once published
+func (r *repository) fanOut(s *session, feedID string, articles ...*Article) error {
+	if !r.timelines {
+		return nil
+	}
+	// The subscribers are read after the Articles were stored, so that Users subscribing meanwhile either get the
+	// Articles here or when their timeline is backfilled
+	f, err := r.getFeed(s, feedID)
+	if err != nil {
+		return err
+	}
+	if f.FanOutOnRead {
+		return nil
+	}
+
+	entries := []interface{}{}
+	for _, a := range articles {
+		if a.Status == api.StatusDraft {
+			continue
+		}
+		for _, userID := range f.Users {
+			entries = append(entries, newTimelineEntry(userID, a))
+		}
+	}
+	return insertTimelineEntries(s, entries)
+}
+
+// backfillTimeline adds the Articles of a Feed to a User's timeline
+func (r *repository) backfillTimeline(s *session, userID string, f *Feed) error {
+	if f.FanOutOnRead {
+		return nil
+	}
+	articles := ArticleList{}
+	selector := bson.M{"feed_id": f.ID, "status": bson.M{"$ne": api.StatusDraft}}
+	if err := s.articles().Find(selector).Select(bson.M{"feed_id": 1, "published_at": 1}).All(&articles); err != nil {
+		return err
+	}
+
+	entries := []interface{}{}
+	for i := range articles {
+		entries = append(entries, newTimelineEntry(userID, &articles[i]))
+	}
+	return insertTimelineEntries(s, entries)
+}
+
+// insertTimelineEntries stores timeline entries, skipping the ones already stored
+func insertTimelineEntries(s *session, entries []interface{}) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	b := s.timelines().Bulk()
+	b.Unordered()
+	b.Insert(entries...)
+	if _, err := b.Run(); err != nil && !mgo.IsDup(err) {
+		return err
+	}
+	return nil
+}
+
+// updateTimelines keeps the timelines in step with an updated Article: the entries of the Article get its current
+// publication time, so that timelines stay in order when a scheduled Article is rescheduled, and the Article is added
+// to the timelines missing it. Articles turned back into drafts are removed from timelines.
+func (r *repository) updateTimelines(s *session, feedID string, a *Article) error {
+	if !r.timelines {
+		return nil
+	}
+	if a.Status == api.StatusDraft {
+		return r.removeFromTimelines(s, a.ID)
+	}
+	update := bson.M{"$set": bson.M{"published_at": a.PublishedTime}}
+	if _, err := s.timelines().UpdateAll(bson.M{"article_id": a.ID}, update); err != nil {
+		return err
+	}
+	return r.fanOut(s, feedID, a)
+}
+
+// removeFromTimelines removes Articles from all timelines
+func (r *repository) removeFromTimelines(s *session, articleIDs ...string) error {
+	if !r.timelines || len(articleIDs) == 0 {
+		return nil
+	}
+	_, err := s.timelines().RemoveAll(bson.M{"article_id": bson.M{"$in": articleIDs}})
+	return err
+}
+
+// removeExpiredFromTimelines removes the Articles among expired ones which were removed from all timelines, Articles
+// starred meanwhile are kept
+func (r *repository) removeExpiredFromTimelines(s *session, expiredIDs []string) error {
+	kept := ArticleList{}
+	if err := s.articles().Find(bson.M{"_id": bson.M{"$in": expiredIDs}}).Select(bson.M{"_id": 1}).All(&kept); err != nil {
+		return err
+	}
+	isKept := map[string]bool{}
+	for _, a := range kept {
+		isKept[a.ID] = true
+	}
+	removed := []string{}
+	for _, id := range expiredIDs {
+		if !isKept[id] {
+			removed = append(removed, id)
+		}
+	}
+	return r.removeFromTimelines(s, removed...)
+}
+
+// listTimelineArticles lists a User's timeline from the materialized timeline and the Feeds read on listing
+func (r *repository) listTimelineArticles(s *session, userID string, feeds FeedList, filter db.ArticleFilter) ([]api.Article, error) {
+	onRead := []string{}
+	for _, f := range feeds {
+		if f.FanOutOnRead {
+			onRead = append(onRead, f.ID)
+		}
+	}
+	fromFeeds := []api.Article{}
+	if len(onRead) > 0 {
+		var err error
+		if fromFeeds, err = r.listArticlesFromFeeds(s, onRead, filter); err != nil {
+			return nil, err
+		}
+	}
+
+	// Entries are read a chunk at a time, newest first, and their Articles looked up and put back in timeline order.
+	// Entries of Articles removed meanwhile, e.g. by the expiry index, are skipped.
+	now := time.Now()
+	fromTimeline := ArticleList{}
+	lookup := func(ids []string) error {
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
+		selector := bson.M{"_id": bson.M{"$in": ids}, "$or": visible(now)}
+		if filter.Tag != "" {
+			selector["tags"] = filter.Tag
+		}
+		found := ArticleList{}
+		if err := s.bounded(s.articles().Find(selector)).All(&found); err != nil {
+			return s.err(err)
+		}
+		byID := map[string]Article{}
+		for _, a := range found {
+			byID[a.ID] = a
+		}
+		for _, id := range ids {
+			if a, ok := byID[id]; ok {
+				fromTimeline = append(fromTimeline, a)
+			}
+		}
+		return nil
+	}
+
+	q := s.timelines().Find(bson.M{"user_id": userID}).Select(bson.M{"article_id": 1}).Sort("-published_at")
+	iter := s.bounded(q).Batch(timelineChunk).Iter()
+	ids := make([]string, 0, timelineChunk)
+	for e := (TimelineEntry{}); iter.Next(&e); e = (TimelineEntry{}) {
+		ids = append(ids, e.ArticleID)
+		if len(ids) < timelineChunk {
+			continue
+		}
+		if err := lookup(ids); err != nil {
+			iter.Close()
+			return nil, err
+		}
+		ids = ids[:0]
+	}
+	if err := iter.Close(); err != nil {
+		return nil, s.err(err)
+	}
+	if len(ids) > 0 {
+		if err := lookup(ids); err != nil {
+			return nil, err
+		}
+	}
+
+	return mergeNewestFirst(applyRules(fromTimeline, filter), fromFeeds), nil
+}
+
+// mergeNewestFirst merges two lists of Articles sorted by publication time, newest first. Articles in both lists,
+// i.e. of a Feed read on listing since they were fanned out, are kept once.
+func mergeNewestFirst(a []api.Article, b []api.Article) []api.Article {
+	if len(b) == 0 {
+		return a
+	}
+	merged := append(append([]api.Article{}, a...), b...)
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].PublishedTime.After(merged[j].PublishedTime) })
+
+	res := []api.Article{}
+	seen := map[string]bool{}
+	for _, article := range merged {
+		if !seen[article.ID] {
+			seen[article.ID] = true
+			res = append(res, article)
+		}
+	}
+	return res
+}
@@ -1,36 +1,149 @@
 package db
 
 import (
+	"context"
+	"time"
+
 	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/rules"
+	"github.com/if-ivan-else/tldrfeed/internal/search"
 )
 
-// Repository defines an interface with persistence layer for Users, Feeds, and Articles entities
+// Repository defines an interface with persistence layer for Users, Feeds, and Articles entities. Methods give up
+// with the context's error once their context is canceled or past its deadline.
 type Repository interface {
-	CreateUser(name string) (*api.User, error)
+	CreateUser(ctx context.Context, name string) (*api.User, error)
+
+	// ListUsers lists the Users, in ID order when paged
+	ListUsers(ctx context.Context, page Page) ([]api.User, error)
+
+	GetUser(ctx context.Context, userID string) (*api.User, error)
+
+	// CreateFeed creates a Feed, a nil retention policy keeping its Articles forever
+	CreateFeed(ctx context.Context, name string, category string, retention *api.RetentionPolicy) (*api.Feed, error)
+
+	ListFeeds(ctx context.Context, filter FeedFilter) ([]api.Feed, error)
+
+	GetFeed(ctx context.Context, feedID string) (*api.Feed, error)
+
+	// GetFeeds returns the Feeds with the given IDs in no particular order, leaving out unknown IDs
+	GetFeeds(ctx context.Context, feedIDs []string) ([]api.Feed, error)
+
+	// GetFeedVersion returns the current revision of a Feed
+	GetFeedVersion(ctx context.Context, feedID string) (*FeedVersion, error)
+
+	ListFeedArticles(ctx context.Context, feedID string, filter ArticleFilter) ([]api.Article, error)
+
+	// ListArticlesOfFeeds lists the Articles of several Feeds at once, leaving out unknown Feeds. The Articles of
+	// every Feed are in the order ListFeedArticles lists them, the page bounding the Articles of every Feed. Fails
+	// with ErrNoSuchArticle when the Article the page starts after is not listed.
+	ListArticlesOfFeeds(ctx context.Context, feedIDs []string, filter ArticleFilter, page Page) ([]api.Article, error)
+
+	// GetFeedArticles returns the Articles of a Feed with the given IDs as stored, whatever their status, in no
+	// particular order and leaving out unknown IDs
+	GetFeedArticles(ctx context.Context, feedID string, articleIDs []string) ([]api.Article, error)
+
+	// CreateFeedArticle adds an Article to a Feed, assigning the Article's ID
+	CreateFeedArticle(ctx context.Context, feedID string, article api.Article) (articleID string, e error)
+
+	// CreateFeedArticles adds Articles to a Feed at once, returning the IDs assigned in the order of the Articles
+	CreateFeedArticles(ctx context.Context, feedID string, articles []api.Article) (articleIDs []string, e error)
+
+	// CountFeedArticles counts Articles added to a Feed since the given time, regardless of their publication time
+	CountFeedArticles(ctx context.Context, feedID string, since time.Time) (int, error)
+
+	AddUserFeed(ctx context.Context, userID string, feedID string) error
+
+	// RemoveUserFeed unsubscribes a User from a Feed
+	RemoveUserFeed(ctx context.Context, userID string, feedID string) error
+
+	ListUserFeeds(ctx context.Context, userID string) ([]api.Feed, error)
 
-	ListUsers() ([]api.User, error)
+	GetUserFeed(ctx context.Context, userID string, feedID string) (*api.Feed, error)
 
-	GetUser(userID string) (*api.User, error)
+	ListUserArticles(ctx context.Context, userID string, filter ArticleFilter) ([]api.Article, error)
 
-	CreateFeed(name string) (*api.Feed, error)
+	ListUserFeedArticles(ctx context.Context, userID string, feedID string, filter ArticleFilter) ([]api.Article, error)
 
-	ListFeeds() ([]api.Feed, error)
+	// ListTags counts Articles by tag, most used tags first
+	ListTags(ctx context.Context) ([]api.TagCount, error)
 
-	GetFeed(feedID string) (*api.Feed, error)
+	// DiscoverFeeds returns statistics of Feeds a User is not following, optionally restricted to a category
+	DiscoverFeeds(ctx context.Context, userID string, category string) ([]FeedStats, error)
 
-	ListFeedArticles(feedID string) ([]api.Article, error)
+	// SearchArticles returns a page of Articles matching a query, best matches first.
+	// Only Articles of the given Feeds are searched unless feedIDs is nil.
+	SearchArticles(ctx context.Context, query *search.Query, feedIDs []string, offset int, limit int) (*search.Results, error)
 
-	CreateFeedArticle(feedID string, articleTitle string, articleBody string) (articleID string, e error)
+	// GetTimelineVersion returns the current revision of a User's timeline
+	GetTimelineVersion(ctx context.Context, userID string) (*TimelineVersion, error)
 
-	AddUserFeed(userID string, feedID string) error
+	IdempotencyStore
 
-	ListUserFeeds(userID string) ([]api.Feed, error)
+	FilterRuleStore
 
-	GetUserFeed(userID string, feedID string) (*api.Feed, error)
+	DraftStore
 
-	ListUserArticles(userID string) ([]api.Article, error)
+	RetentionStore
 
-	ListUserFeedArticles(userID string, feedID string) ([]api.Article, error)
+	StarStore
+
+	SummaryStore
+
+	DatasetStore
+
+	AuditStore
 
 	Close()
 }
+
+// ArticleFilter narrows down Article listings, zero values match all Articles
+type ArticleFilter struct {
+	// Tag matches Articles carrying the tag
+	Tag string
+	// Rules hides Articles muted by a User's filter rules
+	Rules *rules.Set
+}
+
+// Page bounds a listing to the items following an item, the zero Page lists all items
+type Page struct {
+	// After is the ID of the item the page starts after, the page starts with the first item when empty
+	After string
+	// Limit bounds the number of items of the page, 0 does not bound it
+	Limit int
+}
+
+// Paged returns true for pages not listing all items
+func (p Page) Paged() bool {
+	return p.After != "" || p.Limit > 0
+}
+
+// FeedFilter narrows down Feed listings, zero values match all Feeds
+type FeedFilter struct {
+	Category string
+}
+
+// FeedStats describes the popularity and activity of a Feed
+type FeedStats struct {
+	Feed        api.Feed
+	Subscribers int
+	// UpdatedTime is the time of the latest change to the Feed or its Articles
+	UpdatedTime time.Time
+}
+
+// FeedVersion identifies a revision of a Feed and its Articles
+type FeedVersion struct {
+	FeedID string
+	// Version is incremented on every change to the Feed or its Articles
+	Version int64
+	// UpdatedTime is the time of the latest change to the Feed or its Articles
+	UpdatedTime time.Time
+}
+
+// TimelineVersion identifies a revision of a User's timeline, i.e. their subscriptions and the subscribed Feeds
+type TimelineVersion struct {
+	UserID string
+	// SubscriptionsUpdatedTime is the time the User last subscribed to a Feed
+	SubscriptionsUpdatedTime time.Time
+	Feeds                    []FeedVersion
+}
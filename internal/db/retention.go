@@ -0,0 +1,36 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+)
+
+// RetentionStore defines persistence of Feed retention policies and the removal of expired Articles. Only published
+// Articles expire, Articles starred by any User never do.
+type RetentionStore interface {
+	// SetFeedRetention replaces the retention policy of a Feed, a nil policy keeps the Feed's Articles forever
+	SetFeedRetention(ctx context.Context, feedID string, policy *api.RetentionPolicy) error
+
+	// ReportExpiredArticles lists the Articles of a Feed a retention policy removes at the given time, whatever the
+	// Feed's own policy
+	ReportExpiredArticles(ctx context.Context, feedID string, policy api.RetentionPolicy, now time.Time) (*api.RetentionReport, error)
+
+	// ExpireFeedArticles removes the Articles of a Feed expired by its retention policy at the given time, returning
+	// the number of Articles removed
+	ExpireFeedArticles(ctx context.Context, feedID string, now time.Time) (int, error)
+}
+
+// StarStore defines persistence of the Articles Users star, which are exempt from retention policies
+type StarStore interface {
+	// StarArticle stars a published Article for a User, failing with ErrNoSuchArticle for unknown and unpublished
+	// Articles. Starring an Article twice has no effect.
+	StarArticle(ctx context.Context, userID string, articleID string) error
+
+	// UnstarArticle removes a User's star from an Article, unstarring an Article not starred has no effect
+	UnstarArticle(ctx context.Context, userID string, articleID string) error
+
+	// ListStarredArticles returns the Articles a User starred, most recently published first
+	ListStarredArticles(ctx context.Context, userID string) ([]api.Article, error)
+}
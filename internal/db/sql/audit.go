@@ -0,0 +1,96 @@
+package sql
+
+import (
+	"context"
+	"strings"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+)
+
+// payload converts an audit payload to a query argument, missing payloads are stored as NULL
+func payload(p []byte) interface{} {
+	if len(p) == 0 {
+		return nil
+	}
+	return string(p)
+}
+
+func (r *repository) AppendAuditRecord(ctx context.Context, record api.AuditRecord) error {
+	insert := `INSERT INTO audit_log (id, recorded_at, principal, request_id, action, entity_type, entity_id,
+		before_payload, after_payload) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := r.conn(ctx).exec(insert, record.ID, timestamp(record.Time), record.Principal, record.RequestID,
+		record.Action, record.EntityType, record.EntityID, payload(record.Before), payload(record.After))
+	return err
+}
+
+func (r *repository) ListAuditRecords(ctx context.Context, filter db.AuditFilter) ([]api.AuditRecord, error) {
+	conditions := []string{}
+	args := []interface{}{}
+	for _, c := range []struct {
+		column string
+		value  string
+	}{
+		{"entity_type", filter.EntityType},
+		{"entity_id", filter.EntityID},
+		{"principal", filter.Principal},
+		{"action", filter.Action},
+	} {
+		if c.value != "" {
+			conditions = append(conditions, c.column+" = ?")
+			args = append(args, c.value)
+		}
+	}
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "recorded_at >= ?")
+		args = append(args, timestamp(filter.Since))
+	}
+	if !filter.Until.IsZero() {
+		conditions = append(conditions, "recorded_at < ?")
+		args = append(args, timestamp(filter.Until))
+	}
+
+	// The latest records are selected newest first and put back in order
+	query := `SELECT id, recorded_at, principal, request_id, action, entity_type, entity_id, before_payload,
+		after_payload FROM audit_log`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY recorded_at DESC, id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := r.conn(ctx).query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := []api.AuditRecord{}
+	for rows.Next() {
+		var a api.AuditRecord
+		var before, after *string
+		dest := []interface{}{&a.ID, &a.Time, &a.Principal, &a.RequestID, &a.Action, &a.EntityType, &a.EntityID, &before, &after}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		if before != nil {
+			a.Before = []byte(*before)
+		}
+		if after != nil {
+			a.After = []byte(*after)
+		}
+		found = append(found, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	records := make([]api.AuditRecord, 0, len(found))
+	for i := len(found) - 1; i >= 0; i-- {
+		records = append(records, found[i])
+	}
+	return records, nil
+}
@@ -0,0 +1,235 @@
+package sql
+
+import (
+	"context"
+	"time"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+	"github.com/if-ivan-else/tldrfeed/internal/markup"
+)
+
+// Feeds and Articles are exported a page at a time, ordered by ID, so that no query is left running while visit is
+// called and related rows are looked up
+
+func (r *repository) ExportUsers(ctx context.Context, visit func(api.User) error) error {
+	users, err := r.ListUsers(ctx, db.Page{})
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		if err := visit(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *repository) ExportFeeds(ctx context.Context, visit func(db.FeedRecord) error) error {
+	c := r.conn(ctx)
+	for after := ""; ; {
+		feeds, err := queryFeeds(c, "SELECT "+feedColumns+" FROM feeds f WHERE f.id > ? ORDER BY f.id LIMIT ?", after, chunkSize)
+		if err != nil {
+			return err
+		}
+		if len(feeds) == 0 {
+			return nil
+		}
+		after = feeds[len(feeds)-1].ID
+
+		for _, f := range feeds {
+			subscribers, err := queryIDs(c, "SELECT user_id FROM subscriptions WHERE feed_id = ? ORDER BY user_id", f.ID)
+			if err != nil {
+				return err
+			}
+			if err := visit(db.FeedRecord{Feed: *f.toAPI(), Subscribers: subscribers}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (r *repository) ExportArticles(ctx context.Context, visit func(db.ArticleRecord) error) error {
+	c := r.conn(ctx)
+	for after := ""; ; {
+		articles, err := queryArticles(c, "SELECT "+articleSelect+" FROM articles a WHERE a.id > ? ORDER BY a.id LIMIT ?", after, chunkSize)
+		if err != nil {
+			return err
+		}
+		if len(articles) == 0 {
+			return nil
+		}
+		after = articles[len(articles)-1].ID
+
+		for _, a := range articles {
+			starredBy, err := queryIDs(c, "SELECT user_id FROM stars WHERE article_id = ? ORDER BY user_id", a.ID)
+			if err != nil {
+				return err
+			}
+			record := db.ArticleRecord{
+				Article:     *a.toAPI(),
+				CreatedTime: a.CreatedTime,
+				StarredBy:   starredBy,
+			}
+			if err := visit(record); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (r *repository) ExportFilterRules(ctx context.Context, visit func(db.FilterRuleRecord) error) error {
+	rows, err := r.conn(ctx).query("SELECT " + filterRuleSelect + " FROM filter_rules ORDER BY user_id, created_at")
+	if err != nil {
+		return err
+	}
+	rules := []FilterRule{}
+	for rows.Next() {
+		f, err := scanFilterRule(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		rules = append(rules, *f)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, f := range rules {
+		if err := visit(db.FilterRuleRecord{UserID: f.UserID, Rule: *f.toAPI()}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queryIDs runs a query selecting a single text column, returning nil when there are no rows
+func queryIDs(c *conn, query string, args ...interface{}) ([]string, error) {
+	rows, err := c.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *repository) RestoreUser(ctx context.Context, user api.User, replace bool) error {
+	columns := []string{"id", "name", "subscriptions_updated_at"}
+	return insertRow(r.conn(ctx), "users", columns, []interface{}{user.ID, user.Name, timestamp(time.Now())}, replace)
+}
+
+func (r *repository) RestoreFeed(ctx context.Context, record db.FeedRecord, replace bool) error {
+	return r.transact(ctx, func(c *conn) error {
+		now := time.Now()
+		f := &Feed{
+			ID:          record.Feed.ID,
+			Name:        record.Feed.Name,
+			Category:    record.Feed.Category,
+			Version:     1,
+			UpdatedTime: now,
+			Retention:   record.Feed.Retention,
+		}
+		// A replaced Feed gets a newer version so that clients do not mistake it for the Feed they cached
+		existing, err := r.getFeed(c, f.ID)
+		switch {
+		case err == db.ErrNoSuchFeed:
+		case err != nil:
+			return err
+		case !replace:
+			return db.ErrRecordExists
+		default:
+			f.Version = existing.Version + 1
+		}
+
+		columns := []string{"id", "title", "category", "version", "updated_at", "retention_max_age_days", "retention_max_count"}
+		values := append([]interface{}{f.ID, f.Name, f.Category, f.Version, timestamp(f.UpdatedTime)}, retentionArgs(f.Retention)...)
+		if err := insertRow(c, "feeds", columns, values, true); err != nil {
+			return err
+		}
+
+		// Users unsubscribed by the replacement see their subscriptions change as much as the restored subscribers
+		update := "UPDATE users SET subscriptions_updated_at = ? WHERE id IN (SELECT user_id FROM subscriptions WHERE feed_id = ?)"
+		if _, err := c.exec(update, timestamp(now), f.ID); err != nil {
+			return err
+		}
+		// Subscribers must exist, the foreign key fails the restore otherwise
+		if _, err := c.exec("DELETE FROM subscriptions WHERE feed_id = ?", f.ID); err != nil {
+			return err
+		}
+		for _, userID := range record.Subscribers {
+			insert := "INSERT INTO subscriptions (user_id, feed_id, created_at) VALUES (?, ?, ?) ON CONFLICT (user_id, feed_id) DO NOTHING"
+			if _, err := c.exec(insert, userID, f.ID, timestamp(now)); err != nil {
+				return err
+			}
+			if _, err := c.exec("UPDATE users SET subscriptions_updated_at = ? WHERE id = ?", timestamp(now), userID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *repository) RestoreArticle(ctx context.Context, record db.ArticleRecord, replace bool) error {
+	return r.transact(ctx, func(c *conn) error {
+		f, err := r.getFeed(c, record.Article.FeedID)
+		if err != nil {
+			return err
+		}
+
+		created := record.CreatedTime
+		if created.IsZero() {
+			created = time.Now()
+		}
+		// Archives may come from anywhere, so HTML bodies are sanitized as when Articles are created
+		if record.Article.BodyFormat == api.BodyHTML {
+			record.Article.Body = markup.Sanitize(record.Article.Body)
+		}
+		a := newArticle(f.ID, record.Article, created)
+		a.ID = record.Article.ID
+		a.ClusterID = record.Article.ClusterID
+		if a.ClusterID == "" {
+			a.ClusterID = a.ID
+		}
+		if err := insertArticle(c, a, replace); err != nil {
+			return err
+		}
+
+		if _, err := c.exec("DELETE FROM stars WHERE article_id = ?", a.ID); err != nil {
+			return err
+		}
+		for _, userID := range record.StarredBy {
+			insert := "INSERT INTO stars (user_id, article_id, created_at) VALUES (?, ?, ?) ON CONFLICT (user_id, article_id) DO NOTHING"
+			if _, err := c.exec(insert, userID, a.ID, timestamp(time.Now())); err != nil {
+				return err
+			}
+		}
+		return r.bumpFeedVersion(c, f.ID, time.Now())
+	})
+}
+
+func (r *repository) RestoreFilterRule(ctx context.Context, record db.FilterRuleRecord, replace bool) error {
+	c := r.conn(ctx)
+	if _, err := r.getUser(c, record.UserID); err != nil {
+		return err
+	}
+	rule := &FilterRule{
+		ID:          record.Rule.ID,
+		UserID:      record.UserID,
+		Type:        record.Rule.Type,
+		Value:       record.Rule.Value,
+		Action:      record.Rule.Action,
+		CreatedTime: record.Rule.CreatedTime,
+	}
+	return insertFilterRule(c, rule, replace)
+}
@@ -0,0 +1,122 @@
+package sql
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+)
+
+// URL schemes of the supported databases
+const (
+	SchemePostgres   = "postgres://"
+	SchemePostgreSQL = "postgresql://"
+	SchemeSQLite     = "sqlite://"
+)
+
+// dialect captures the differences between the supported databases. Queries are written with ? placeholders and
+// the column types used in migrations, which dialects translate to their own.
+type dialect struct {
+	name   string
+	driver string
+	types  *strings.Replacer
+	// numbered dialects use $1, $2... placeholders
+	numbered bool
+	// noLimit is the LIMIT clause of queries with an OFFSET but no limit
+	noLimit string
+	// isDuplicate tells violations of primary keys and unique constraints apart from other errors
+	isDuplicate func(error) bool
+	// serialized databases lock the whole database for writes, so they are used through a single connection
+	serialized bool
+}
+
+var sqliteDialect = &dialect{
+	name:    "SQLite",
+	driver:  "sqlite3",
+	types:   strings.NewReplacer("{{timestamp}}", "TIMESTAMP", "{{blob}}", "BLOB"),
+	noLimit: "LIMIT -1",
+	isDuplicate: func(err error) bool {
+		e, ok := err.(sqlite3.Error)
+		return ok && (e.ExtendedCode == sqlite3.ErrConstraintPrimaryKey || e.ExtendedCode == sqlite3.ErrConstraintUnique)
+	},
+	serialized: true,
+}
+
+var postgresDialect = &dialect{
+	name:     "PostgreSQL",
+	driver:   "postgres",
+	types:    strings.NewReplacer("{{timestamp}}", "TIMESTAMPTZ", "{{blob}}", "BYTEA"),
+	numbered: true,
+	noLimit:  "LIMIT ALL",
+	isDuplicate: func(err error) bool {
+		e, ok := err.(*pq.Error)
+		return ok && e.Code == "23505"
+	},
+}
+
+// Supports returns true for connection URLs of the SQL databases the repository supports
+func Supports(url string) bool {
+	for _, scheme := range []string{SchemePostgres, SchemePostgreSQL, SchemeSQLite} {
+		if strings.HasPrefix(url, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseURL returns the dialect of a connection URL and the data source name its driver is opened with.
+// SQLite URLs name a file (sqlite:///var/lib/tldrfeed.db) or :memory: (sqlite://:memory:), PostgreSQL URLs are
+// passed to the driver as they are.
+func parseURL(url string) (*dialect, string, error) {
+	switch {
+	case strings.HasPrefix(url, SchemeSQLite):
+		path := strings.TrimPrefix(url, SchemeSQLite)
+		if path == "" {
+			return nil, "", errors.Errorf("No SQLite database file in '%s'", url)
+		}
+		// SQLite only enforces foreign keys when asked to
+		separator := "?"
+		if strings.Contains(path, "?") {
+			separator = "&"
+		}
+		return sqliteDialect, "file:" + path + separator + "_foreign_keys=1&_busy_timeout=5000", nil
+	case strings.HasPrefix(url, SchemePostgres), strings.HasPrefix(url, SchemePostgreSQL):
+		return postgresDialect, url, nil
+	}
+	return nil, "", errors.Errorf("Unsupported SQL database URL '%s', expected one of %s, %s or %s",
+		url, SchemePostgres, SchemePostgreSQL, SchemeSQLite)
+}
+
+// rebind replaces the ? placeholders of a query with the dialect's own
+func (d *dialect) rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c != '?' {
+			b.WriteRune(c)
+			continue
+		}
+		n++
+		b.WriteString("$" + strconv.Itoa(n))
+	}
+	return b.String()
+}
+
+// placeholders returns n comma separated placeholders, e.g. for IN lists
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// stringArgs converts strings to query arguments
+func stringArgs(values []string) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
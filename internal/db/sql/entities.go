@@ -0,0 +1,274 @@
+package sql
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+	"github.com/if-ivan-else/tldrfeed/internal/dedup"
+)
+
+// scanner is a row of query results, either *sql.Row or *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// timestamp converts a time to a query argument, zero times are stored as NULL. Times are stored in UTC with the
+// microsecond precision of PostgreSQL, so that SQLite, which stores them as text, compares them in the same order.
+func timestamp(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC().Truncate(time.Microsecond)
+}
+
+// nullableTimestamp converts an optional time to a query argument
+func nullableTimestamp(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return timestamp(*t)
+}
+
+// timeOrZero returns the time scanned from a nullable column
+func timeOrZero(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+const feedColumns = "f.id, f.title, f.category, f.version, f.updated_at, f.retention_max_age_days, f.retention_max_count"
+
+// Feed is a row of the feeds table
+type Feed struct {
+	ID       string
+	Name     string
+	Category string
+	// Version and UpdatedTime change with every change to the Feed or its Articles
+	Version     int64
+	UpdatedTime time.Time
+	// Retention is nil for Feeds keeping their Articles forever
+	Retention *api.RetentionPolicy
+}
+
+// scanFeed reads a Feed selected with feedColumns followed by the extra columns
+func scanFeed(s scanner, extra ...interface{}) (*Feed, error) {
+	var f Feed
+	var maxAgeDays, maxCount *int
+	dest := append([]interface{}{&f.ID, &f.Name, &f.Category, &f.Version, &f.UpdatedTime, &maxAgeDays, &maxCount}, extra...)
+	if err := s.Scan(dest...); err != nil {
+		return nil, err
+	}
+	if maxAgeDays != nil && maxCount != nil {
+		f.Retention = &api.RetentionPolicy{MaxAgeDays: *maxAgeDays, MaxCount: *maxCount}
+	}
+	return &f, nil
+}
+
+// retentionArgs converts a retention policy to the arguments of the retention columns
+func retentionArgs(p *api.RetentionPolicy) []interface{} {
+	if p == nil {
+		return []interface{}{nil, nil}
+	}
+	return []interface{}{p.MaxAgeDays, p.MaxCount}
+}
+
+func (f *Feed) toAPI() *api.Feed {
+	feed := &api.Feed{
+		ID:       f.ID,
+		Name:     f.Name,
+		Category: f.Category,
+	}
+	if f.Retention != nil {
+		policy := *f.Retention
+		feed.Retention = &policy
+	}
+	return feed
+}
+
+func (f *Feed) toVersion() *db.FeedVersion {
+	return &db.FeedVersion{
+		FeedID:      f.ID,
+		Version:     f.Version,
+		UpdatedTime: f.UpdatedTime,
+	}
+}
+
+// FeedList is a list of Feed rows
+type FeedList []Feed
+
+func (l FeedList) toAPI() []api.Feed {
+	res := []api.Feed{}
+	for _, f := range l {
+		res = append(res, *f.toAPI())
+	}
+	return res
+}
+
+// articleColumns lists the columns of the articles table, the ones selected with articleSelect in the same order
+var articleColumns = []string{
+	"id", "feed_id", "title", "body", "body_format", "summary", "author", "url", "image_url", "published_at",
+	"updated_at", "created_at", "cluster_id", "url_key", "simhash", "status", "publish_at",
+}
+
+const articleSelect = "a.id, a.feed_id, a.title, a.body, a.body_format, a.summary, a.author, a.url, a.image_url, " +
+	"a.published_at, a.updated_at, a.created_at, a.cluster_id, a.url_key, a.simhash, a.status, a.publish_at"
+
+// Article is a row of the articles table with the Article's tags and SimHash bands, which are stored in tables of
+// their own
+type Article struct {
+	ID            string
+	FeedID        string
+	Title         string
+	Body          string
+	BodyFormat    string
+	Summary       string
+	Author        string
+	URL           string
+	ImageURL      string
+	Tags          []string
+	PublishedTime time.Time
+	UpdatedTime   time.Time
+	// CreatedTime is when the Article was added to the Feed, it differs from PublishedTime for backfilled Articles
+	CreatedTime time.Time
+	// ClusterID groups near-duplicates, it is the Article's own ID for Articles without duplicates
+	ClusterID string
+	// URLKey, SimHash and SimHashBands make up the dedup.Fingerprint of the Article
+	URLKey       string
+	SimHash      int64
+	SimHashBands []string
+	Status       string
+	PublishAt    *time.Time
+}
+
+// newArticle creates an Article row with a new ID for an Article added to a Feed at the given time
+func newArticle(feedID string, a api.Article, now time.Time) *Article {
+	article := &Article{
+		ID:            uuid.New().String(),
+		FeedID:        feedID,
+		Title:         a.Title,
+		Body:          a.Body,
+		BodyFormat:    a.BodyFormat,
+		Summary:       a.Summary,
+		Author:        a.Author,
+		URL:           a.URL,
+		ImageURL:      a.ImageURL,
+		Tags:          a.Tags,
+		PublishedTime: a.PublishedTime,
+		UpdatedTime:   a.UpdatedTime,
+		CreatedTime:   now,
+		Status:        a.Status,
+		PublishAt:     a.PublishAt,
+	}
+	article.ClusterID = article.ID
+	if article.Status == "" {
+		article.Status = api.StatusPublished
+	}
+	// Drafts are given a publication time when published
+	if article.PublishedTime.IsZero() && article.Status != api.StatusDraft {
+		article.PublishedTime = now
+	}
+	if article.UpdatedTime.IsZero() {
+		article.UpdatedTime = article.PublishedTime
+	}
+
+	fingerprint := dedup.Compute(&a)
+	article.URLKey = fingerprint.URL
+	// SQL databases have no unsigned integers
+	article.SimHash = int64(fingerprint.SimHash)
+	article.SimHashBands = fingerprint.Bands()
+	return article
+}
+
+// values returns the arguments of the articleColumns of the Article
+func (a *Article) values() []interface{} {
+	return []interface{}{
+		a.ID, a.FeedID, a.Title, a.Body, a.BodyFormat, a.Summary, a.Author, a.URL, a.ImageURL,
+		timestamp(a.PublishedTime), timestamp(a.UpdatedTime), timestamp(a.CreatedTime), a.ClusterID, a.URLKey,
+		a.SimHash, a.Status, nullableTimestamp(a.PublishAt),
+	}
+}
+
+// scanArticle reads an Article selected with articleSelect, without its tags
+func scanArticle(s scanner) (*Article, error) {
+	var a Article
+	var published, updated *time.Time
+	err := s.Scan(&a.ID, &a.FeedID, &a.Title, &a.Body, &a.BodyFormat, &a.Summary, &a.Author, &a.URL, &a.ImageURL,
+		&published, &updated, &a.CreatedTime, &a.ClusterID, &a.URLKey, &a.SimHash, &a.Status, &a.PublishAt)
+	if err != nil {
+		return nil, err
+	}
+	a.PublishedTime = timeOrZero(published)
+	a.UpdatedTime = timeOrZero(updated)
+	return &a, nil
+}
+
+func (a *Article) fingerprint() dedup.Fingerprint {
+	return dedup.Fingerprint{
+		URL:     a.URLKey,
+		SimHash: uint64(a.SimHash),
+	}
+}
+
+func (a *Article) toAPI() *api.Article {
+	return &api.Article{
+		ID:            a.ID,
+		Title:         a.Title,
+		Body:          a.Body,
+		BodyFormat:    a.BodyFormat,
+		Summary:       a.Summary,
+		Author:        a.Author,
+		URL:           a.URL,
+		ImageURL:      a.ImageURL,
+		Tags:          a.Tags,
+		PublishedTime: a.PublishedTime,
+		UpdatedTime:   a.UpdatedTime,
+		FeedID:        a.FeedID,
+		ClusterID:     a.ClusterID,
+		Status:        a.Status,
+		PublishAt:     a.PublishAt,
+	}
+}
+
+// ArticleList is a list of Article rows
+type ArticleList []Article
+
+func (l ArticleList) toAPI() []api.Article {
+	res := []api.Article{}
+	for _, a := range l {
+		res = append(res, *a.toAPI())
+	}
+	return res
+}
+
+// FilterRule is a row of the filter_rules table
+type FilterRule struct {
+	ID          string
+	UserID      string
+	Type        string
+	Value       string
+	Action      string
+	CreatedTime time.Time
+}
+
+const filterRuleSelect = "id, user_id, type, value, action, created_at"
+
+func scanFilterRule(s scanner) (*FilterRule, error) {
+	var f FilterRule
+	if err := s.Scan(&f.ID, &f.UserID, &f.Type, &f.Value, &f.Action, &f.CreatedTime); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func (r *FilterRule) toAPI() *api.FilterRule {
+	return &api.FilterRule{
+		ID:          r.ID,
+		Type:        r.Type,
+		Value:       r.Value,
+		Action:      r.Action,
+		CreatedTime: r.CreatedTime,
+	}
+}
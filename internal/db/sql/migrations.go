@@ -0,0 +1,212 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// migration is a versioned change to the schema. Migrations are compiled into the server and applied in order of
+// version when the repository is opened, each in a transaction of its own. Applied migrations must never change,
+// changes to the schema are made by adding migrations. A migration's backfill fills the tables it creates from the
+// existing rows, in the migration's transaction.
+type migration struct {
+	version     int
+	description string
+	statements  []string
+	backfill    func(c *conn) error
+}
+
+var migrations = []migration{
+	{
+		version:     1,
+		description: "Create users, feeds, subscriptions, articles, stars, filter rules and idempotency records",
+		statements: []string{
+			`CREATE TABLE users (
+				id TEXT PRIMARY KEY,
+				name TEXT NOT NULL,
+				subscriptions_updated_at {{timestamp}}
+			)`,
+			// Retention columns are NULL for Feeds keeping their Articles forever
+			`CREATE TABLE feeds (
+				id TEXT PRIMARY KEY,
+				title TEXT NOT NULL,
+				category TEXT NOT NULL DEFAULT '',
+				version BIGINT NOT NULL,
+				updated_at {{timestamp}} NOT NULL,
+				retention_max_age_days INTEGER,
+				retention_max_count INTEGER
+			)`,
+			`CREATE INDEX feeds_category ON feeds (category)`,
+			`CREATE TABLE subscriptions (
+				user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+				feed_id TEXT NOT NULL REFERENCES feeds (id) ON DELETE CASCADE,
+				created_at {{timestamp}} NOT NULL,
+				PRIMARY KEY (user_id, feed_id)
+			)`,
+			`CREATE INDEX subscriptions_feed ON subscriptions (feed_id)`,
+			// published_at is NULL for drafts, cluster_id is the Article's own ID for Articles without duplicates
+			`CREATE TABLE articles (
+				id TEXT PRIMARY KEY,
+				feed_id TEXT NOT NULL REFERENCES feeds (id) ON DELETE CASCADE,
+				title TEXT NOT NULL,
+				body TEXT NOT NULL,
+				body_format TEXT NOT NULL,
+				summary TEXT NOT NULL,
+				author TEXT NOT NULL,
+				url TEXT NOT NULL,
+				image_url TEXT NOT NULL,
+				published_at {{timestamp}},
+				updated_at {{timestamp}},
+				created_at {{timestamp}} NOT NULL,
+				cluster_id TEXT NOT NULL,
+				url_key TEXT NOT NULL,
+				simhash BIGINT NOT NULL,
+				status TEXT NOT NULL,
+				publish_at {{timestamp}}
+			)`,
+			// Lists the Articles of Feeds newest first and finds the oldest Articles of a Feed
+			`CREATE INDEX articles_feed_published ON articles (feed_id, published_at)`,
+			// Counts the Articles recently added to a Feed and finds duplicates added to other Feeds
+			`CREATE INDEX articles_feed_created ON articles (feed_id, created_at)`,
+			// Looks up scheduled Articles falling due
+			`CREATE INDEX articles_status_publish ON articles (status, publish_at)`,
+			`CREATE INDEX articles_url_key ON articles (url_key)`,
+			`CREATE TABLE article_tags (
+				article_id TEXT NOT NULL REFERENCES articles (id) ON DELETE CASCADE,
+				position INTEGER NOT NULL,
+				tag TEXT NOT NULL,
+				PRIMARY KEY (article_id, position)
+			)`,
+			`CREATE INDEX article_tags_tag ON article_tags (tag, article_id)`,
+			// Bands of the Articles' SimHashes, near-duplicates share at least one
+			`CREATE TABLE article_bands (
+				article_id TEXT NOT NULL REFERENCES articles (id) ON DELETE CASCADE,
+				band TEXT NOT NULL,
+				PRIMARY KEY (article_id, band)
+			)`,
+			`CREATE INDEX article_bands_band ON article_bands (band)`,
+			`CREATE TABLE stars (
+				user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+				article_id TEXT NOT NULL REFERENCES articles (id) ON DELETE CASCADE,
+				created_at {{timestamp}} NOT NULL,
+				PRIMARY KEY (user_id, article_id)
+			)`,
+			`CREATE INDEX stars_article ON stars (article_id)`,
+			`CREATE TABLE filter_rules (
+				id TEXT PRIMARY KEY,
+				user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+				type TEXT NOT NULL,
+				value TEXT NOT NULL,
+				action TEXT NOT NULL,
+				created_at {{timestamp}} NOT NULL
+			)`,
+			`CREATE INDEX filter_rules_user ON filter_rules (user_id, created_at)`,
+			`CREATE TABLE idempotency (
+				idempotency_key TEXT PRIMARY KEY,
+				request_hash TEXT NOT NULL,
+				status INTEGER NOT NULL,
+				content_type TEXT NOT NULL,
+				body {{blob}},
+				expires_at {{timestamp}} NOT NULL
+			)`,
+			`CREATE INDEX idempotency_expires ON idempotency (expires_at)`,
+		},
+	},
+	{
+		version:     2,
+		description: "Create the audit log",
+		statements: []string{
+			// Payloads are JSON, NULL when a change has no before or after
+			`CREATE TABLE audit_log (
+				id TEXT PRIMARY KEY,
+				recorded_at {{timestamp}} NOT NULL,
+				principal TEXT NOT NULL,
+				request_id TEXT NOT NULL,
+				action TEXT NOT NULL,
+				entity_type TEXT NOT NULL,
+				entity_id TEXT NOT NULL,
+				before_payload TEXT,
+				after_payload TEXT
+			)`,
+			`CREATE INDEX audit_log_time ON audit_log (recorded_at)`,
+			`CREATE INDEX audit_log_entity ON audit_log (entity_type, entity_id, recorded_at)`,
+			`CREATE INDEX audit_log_principal ON audit_log (principal, recorded_at)`,
+		},
+	},
+	{
+		version:     3,
+		description: "Create the search index of Articles",
+		statements: []string{
+			// Number of terms of the fields of every indexed Article
+			`CREATE TABLE search_documents (
+				article_id TEXT PRIMARY KEY,
+				title_terms INTEGER NOT NULL,
+				body_terms INTEGER NOT NULL
+			)`,
+			// Positions of every term in the fields of every indexed Article, comma separated
+			`CREATE TABLE search_postings (
+				term TEXT NOT NULL,
+				article_id TEXT NOT NULL,
+				field TEXT NOT NULL,
+				positions TEXT NOT NULL,
+				PRIMARY KEY (term, article_id, field)
+			)`,
+			`CREATE INDEX search_postings_article ON search_postings (article_id)`,
+			// Number of indexed Articles and total number of terms of each field
+			`CREATE TABLE search_stats (
+				name TEXT PRIMARY KEY,
+				value BIGINT NOT NULL
+			)`,
+			`INSERT INTO search_stats (name, value) VALUES ('documents', 0), ('title', 0), ('body', 0)`,
+		},
+		backfill: indexAllArticles,
+	},
+}
+
+// migrate applies the migrations newer than the schema's version
+func (r *repository) migrate(ctx context.Context) error {
+	c := r.conn(ctx)
+	create := `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at {{timestamp}} NOT NULL
+	)`
+	if _, err := c.exec(r.dialect.types.Replace(create)); err != nil {
+		return errors.Wrap(err, "Failed to create schema migrations table")
+	}
+
+	var current sql.NullInt64
+	if err := c.queryRow("SELECT MAX(version) FROM schema_migrations").Scan(&current); err != nil {
+		return errors.Wrap(err, "Failed to read schema version")
+	}
+
+	for _, m := range migrations {
+		if int64(m.version) <= current.Int64 {
+			continue
+		}
+		err := r.transact(ctx, func(c *conn) error {
+			for _, statement := range m.statements {
+				if _, err := c.exec(r.dialect.types.Replace(statement)); err != nil {
+					return err
+				}
+			}
+			if m.backfill != nil {
+				if err := m.backfill(c); err != nil {
+					return err
+				}
+			}
+			_, err := c.exec("INSERT INTO schema_migrations (version, description, applied_at) VALUES (?, ?, ?)",
+				m.version, m.description, timestamp(time.Now()))
+			return err
+		})
+		if err != nil {
+			return errors.Wrapf(err, "Failed to apply schema migration %d", m.version)
+		}
+		log.Printf("Applied %s schema migration %d: %s", r.dialect.name, m.version, m.description)
+	}
+	return nil
+}
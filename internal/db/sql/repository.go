@@ -0,0 +1,1386 @@
+// Package sql implements the tldrfeed repository on SQL databases, PostgreSQL and SQLite, with database/sql
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+	"github.com/if-ivan-else/tldrfeed/internal/dedup"
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxDuplicateCandidates limits the number of Articles compared to a new Article when looking for duplicates
+	maxDuplicateCandidates = 100
+	// chunkSize is the number of Articles whose tags are looked up, or which are removed, at once
+	chunkSize = 500
+)
+
+// repository implements a SQL based repository for tldrfeed persistence of Users, Articles and Feeds.
+// SQL databases have no expiry of their own, expired Articles and idempotency records are removed by the
+// repository.
+type repository struct {
+	db      *sql.DB
+	dialect *dialect
+}
+
+// conn runs queries written with ? placeholders on the DB or in a transaction, canceled along with its context.
+// Rows must be closed before the next query is run, SQLite is used through a single connection.
+type conn struct {
+	ctx context.Context
+	q   interface {
+		ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+		QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+		QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	}
+	dialect *dialect
+}
+
+// exec runs a statement, returning the number of rows affected
+func (c *conn) exec(query string, args ...interface{}) (int64, error) {
+	res, err := c.q.ExecContext(c.ctx, c.dialect.rebind(query), args...)
+	if err != nil {
+		return 0, c.err(err)
+	}
+	return res.RowsAffected()
+}
+
+func (c *conn) query(query string, args ...interface{}) (*sql.Rows, error) {
+	rows, err := c.q.QueryContext(c.ctx, c.dialect.rebind(query), args...)
+	return rows, c.err(err)
+}
+
+func (c *conn) queryRow(query string, args ...interface{}) *sql.Row {
+	return c.q.QueryRowContext(c.ctx, c.dialect.rebind(query), args...)
+}
+
+// err reports the failure of a statement interrupted by the context as the context's error, which drivers report
+// in their own terms
+func (c *conn) err(err error) error {
+	if err != nil && c.ctx.Err() != nil {
+		return c.ctx.Err()
+	}
+	return err
+}
+
+func (r *repository) conn(ctx context.Context) *conn {
+	return &conn{ctx: ctx, q: r.db, dialect: r.dialect}
+}
+
+// transact runs fn in a transaction, committed if fn succeeds and rolled back otherwise. The transaction is rolled
+// back when ctx is canceled before it is committed.
+func (r *repository) transact(ctx context.Context, fn func(c *conn) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(&conn{ctx: ctx, q: tx, dialect: r.dialect}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// NewRepository connects to the SQL database at url and applies pending schema migrations
+func NewRepository(url string) (db.Repository, error) {
+	d, dsn, err := parseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := sql.Open(d.driver, dsn)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to open DB @ %s", url)
+	}
+	if d.serialized {
+		conn.SetMaxOpenConns(1)
+	}
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, errors.Wrapf(err, "Failed to connect to DB @ %s", url)
+	}
+
+	r := &repository{
+		db:      conn,
+		dialect: d,
+	}
+	if err := r.migrate(context.Background()); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// insertRow inserts a row, or replaces the row with the same key if replace is true. The key is the first column.
+func insertRow(c *conn, table string, columns []string, values []interface{}, replace bool) error {
+	query := "INSERT INTO " + table + " (" + strings.Join(columns, ", ") + ") VALUES (" + placeholders(len(columns)) + ")"
+	if replace {
+		set := []string{}
+		for _, column := range columns[1:] {
+			set = append(set, column+" = excluded."+column)
+		}
+		query += " ON CONFLICT (" + columns[0] + ") DO UPDATE SET " + strings.Join(set, ", ")
+	}
+	if _, err := c.exec(query, values...); err != nil {
+		if c.dialect.isDuplicate(err) {
+			return db.ErrRecordExists
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *repository) CreateUser(ctx context.Context, name string) (*api.User, error) {
+	u := &api.User{
+		ID:   uuid.New().String(),
+		Name: name,
+	}
+
+	// TODO: make User's name uniqe so that we fail here if a User with such name already exists
+	if _, err := r.conn(ctx).exec("INSERT INTO users (id, name) VALUES (?, ?)", u.ID, u.Name); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (r *repository) ListUsers(ctx context.Context, page db.Page) ([]api.User, error) {
+	c := r.conn(ctx)
+	query := "SELECT id, name FROM users"
+	args := []interface{}{}
+	if page.After != "" {
+		if _, err := r.getUser(c, page.After); err != nil {
+			return nil, err
+		}
+		query += " WHERE id > ?"
+		args = append(args, page.After)
+	}
+	query += " ORDER BY id"
+	if page.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, page.Limit)
+	}
+
+	rows, err := c.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []api.User{}
+	for rows.Next() {
+		var u api.User
+		if err := rows.Scan(&u.ID, &u.Name); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (r *repository) GetUser(ctx context.Context, userID string) (*api.User, error) {
+	return r.getUser(r.conn(ctx), userID)
+}
+
+func (r *repository) getUser(c *conn, userID string) (*api.User, error) {
+	var u api.User
+	if err := c.queryRow("SELECT id, name FROM users WHERE id = ?", userID).Scan(&u.ID, &u.Name); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, db.ErrNoSuchUser
+		}
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r *repository) CreateFeed(ctx context.Context, name string, category string, retention *api.RetentionPolicy) (*api.Feed, error) {
+	f := Feed{
+		ID:          uuid.New().String(),
+		Name:        name,
+		Category:    category,
+		Version:     1,
+		UpdatedTime: time.Now(),
+		Retention:   retention,
+	}
+
+	// TODO: make Feed's name uniqe so that we fail here if a Feed with such name already exists
+	query := "INSERT INTO feeds (id, title, category, version, updated_at, retention_max_age_days, retention_max_count) " +
+		"VALUES (?, ?, ?, ?, ?, ?, ?)"
+	args := append([]interface{}{f.ID, f.Name, f.Category, f.Version, timestamp(f.UpdatedTime)}, retentionArgs(retention)...)
+	_, err := r.conn(ctx).exec(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return f.toAPI(), nil
+}
+
+func (r *repository) ListFeeds(ctx context.Context, filter db.FeedFilter) ([]api.Feed, error) {
+	query := "SELECT " + feedColumns + " FROM feeds f"
+	args := []interface{}{}
+	if filter.Category != "" {
+		query += " WHERE f.category = ?"
+		args = append(args, filter.Category)
+	}
+	feeds, err := queryFeeds(r.conn(ctx), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return feeds.toAPI(), nil
+}
+
+// queryFeeds runs a query selecting feedColumns
+func queryFeeds(c *conn, query string, args ...interface{}) (FeedList, error) {
+	rows, err := c.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feeds := FeedList{}
+	for rows.Next() {
+		f, err := scanFeed(rows)
+		if err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, *f)
+	}
+	return feeds, rows.Err()
+}
+
+func (r *repository) GetFeed(ctx context.Context, feedID string) (*api.Feed, error) {
+	f, err := r.getFeed(r.conn(ctx), feedID)
+	if err != nil {
+		return nil, err
+	}
+	return f.toAPI(), nil
+}
+
+func (r *repository) GetFeeds(ctx context.Context, feedIDs []string) ([]api.Feed, error) {
+	if len(feedIDs) == 0 {
+		return []api.Feed{}, nil
+	}
+	feeds, err := queryFeeds(r.conn(ctx), "SELECT "+feedColumns+" FROM feeds f WHERE f.id IN ("+placeholders(len(feedIDs))+")",
+		stringArgs(feedIDs)...)
+	if err != nil {
+		return nil, err
+	}
+	return feeds.toAPI(), nil
+}
+
+func (r *repository) getFeed(c *conn, feedID string) (*Feed, error) {
+	f, err := scanFeed(c.queryRow("SELECT "+feedColumns+" FROM feeds f WHERE f.id = ?", feedID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, db.ErrNoSuchFeed
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+func (r *repository) ListFeedArticles(ctx context.Context, feedID string, filter db.ArticleFilter) ([]api.Article, error) {
+	c := r.conn(ctx)
+	if _, err := r.getFeed(c, feedID); err != nil {
+		return nil, err
+	}
+	return r.listArticles(c, "a.feed_id = ?", []interface{}{feedID}, filter)
+}
+
+func (r *repository) ListArticlesOfFeeds(ctx context.Context, feedIDs []string, filter db.ArticleFilter, page db.Page) ([]api.Article, error) {
+	if len(feedIDs) == 0 {
+		return []api.Article{}, nil
+	}
+	c := r.conn(ctx)
+	if !page.Paged() {
+		return r.listArticles(c, "a.feed_id IN ("+placeholders(len(feedIDs))+")", stringArgs(feedIDs), filter)
+	}
+	return r.listArticlePages(c, feedIDs, filter, page)
+}
+
+// feedPage is the page of a Feed's Articles being read by listArticlePages
+type feedPage struct {
+	// last is the last row read, the next rows follow it
+	last     *Article
+	articles []api.Article
+	done     bool
+}
+
+// listArticlePages returns a page of the visible Articles of every Feed, newest first. The rows of every Feed are
+// numbered so that a single query reads the next rows of all the Feeds; further queries are only needed for the Feeds
+// the filter's rules hide Articles of.
+func (r *repository) listArticlePages(c *conn, feedIDs []string, filter db.ArticleFilter, page db.Page) ([]api.Article, error) {
+	isVisible, visibleArgs := visible(time.Now())
+	var after *Article
+	if page.After != "" {
+		query := "SELECT " + articleSelect + " FROM articles a WHERE a.id = ? AND a.feed_id IN (" +
+			placeholders(len(feedIDs)) + ") AND " + isVisible
+		args := append(append([]interface{}{page.After}, stringArgs(feedIDs)...), visibleArgs...)
+		var err error
+		if after, err = scanArticle(c.queryRow(query, args...)); err != nil {
+			if err == sql.ErrNoRows {
+				return nil, db.ErrNoSuchArticle
+			}
+			return nil, err
+		}
+	}
+
+	pages := make(map[string]*feedPage, len(feedIDs))
+	ids := []string{}
+	for _, id := range feedIDs {
+		if pages[id] == nil {
+			pages[id] = &feedPage{last: after}
+			ids = append(ids, id)
+		}
+	}
+	for {
+		conditions := []string{}
+		args := []interface{}{}
+		read := map[string]int{}
+		for _, id := range ids {
+			p := pages[id]
+			if p.done {
+				continue
+			}
+			read[id] = 0
+			if p.last == nil {
+				conditions = append(conditions, "a.feed_id = ?")
+				args = append(args, id)
+				continue
+			}
+			published := timestamp(p.last.PublishedTime)
+			conditions = append(conditions, "(a.feed_id = ? AND (a.published_at < ? OR (a.published_at = ? AND a.id < ?)))")
+			args = append(args, id, published, published, p.last.ID)
+		}
+		if len(conditions) == 0 {
+			break
+		}
+
+		condition := "(" + strings.Join(conditions, " OR ") + ") AND " + isVisible
+		args = append(args, visibleArgs...)
+		if filter.Tag != "" {
+			condition += " AND EXISTS (SELECT 1 FROM article_tags t WHERE t.article_id = a.id AND t.tag = ?)"
+			args = append(args, filter.Tag)
+		}
+		query := "SELECT " + articleSelect + " FROM articles a WHERE " + condition
+		if page.Limit > 0 {
+			query = "SELECT " + articleSelect + " FROM articles a JOIN (SELECT a.id, ROW_NUMBER() OVER " +
+				"(PARTITION BY a.feed_id ORDER BY a.published_at DESC, a.id DESC) AS feed_row FROM articles a WHERE " +
+				condition + ") n ON n.id = a.id WHERE n.feed_row <= ?"
+			args = append(args, page.Limit)
+		}
+		articles, err := queryArticles(c, query+" ORDER BY a.published_at DESC, a.id DESC", args...)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range articles {
+			a := &articles[i]
+			p := pages[a.FeedID]
+			p.last = a
+			read[a.FeedID]++
+			article := a.toAPI()
+			if (page.Limit == 0 || len(p.articles) < page.Limit) && !filter.Rules.Hides(a.FeedID, article) {
+				p.articles = append(p.articles, *article)
+			}
+		}
+		// A Feed is done once its page is full or its rows run out
+		for id, n := range read {
+			p := pages[id]
+			p.done = page.Limit == 0 || n < page.Limit || len(p.articles) == page.Limit
+		}
+	}
+
+	res := []api.Article{}
+	for _, id := range ids {
+		res = append(res, pages[id].articles...)
+	}
+	return res, nil
+}
+
+func (r *repository) GetFeedArticles(ctx context.Context, feedID string, articleIDs []string) ([]api.Article, error) {
+	if len(articleIDs) == 0 {
+		return []api.Article{}, nil
+	}
+	query := "SELECT " + articleSelect + " FROM articles a WHERE a.feed_id = ? AND a.id IN (" +
+		placeholders(len(articleIDs)) + ")"
+	articles, err := queryArticles(r.conn(ctx), query, append([]interface{}{feedID}, stringArgs(articleIDs)...)...)
+	if err != nil {
+		return nil, err
+	}
+	return articles.toAPI(), nil
+}
+
+func (r *repository) CreateFeedArticle(ctx context.Context, feedID string, article api.Article) (articleID string, e error) {
+	ids, err := r.CreateFeedArticles(ctx, feedID, []api.Article{article})
+	if err != nil {
+		return "", err
+	}
+	return ids[0], nil
+}
+
+func (r *repository) CreateFeedArticles(ctx context.Context, feedID string, articles []api.Article) (articleIDs []string, e error) {
+	ids := []string{}
+	err := r.transact(ctx, func(c *conn) error {
+		if _, err := r.getFeed(c, feedID); err != nil {
+			return err
+		}
+		if len(articles) == 0 {
+			return nil
+		}
+
+		now := time.Now()
+		for _, article := range articles {
+			a := newArticle(feedID, article, now)
+			// Duplicates are only looked up in other Feeds, so Articles of the batch cannot be each other's
+			cluster, err := r.findCluster(c, a)
+			if err != nil {
+				return err
+			}
+			a.ClusterID = cluster
+			if err := insertArticle(c, a, false); err != nil {
+				return err
+			}
+			ids = append(ids, a.ID)
+		}
+		return r.bumpFeedVersion(c, feedID, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// insertArticle stores an Article with its tags and SimHash bands, replacing the Article with the same ID if replace
+// is true
+func insertArticle(c *conn, a *Article, replace bool) error {
+	if err := insertRow(c, "articles", articleColumns, a.values(), replace); err != nil {
+		return err
+	}
+	if err := storeTagsAndBands(c, a); err != nil {
+		return err
+	}
+	return indexArticle(c, a)
+}
+
+// storeTagsAndBands replaces the tags and SimHash bands stored for an Article
+func storeTagsAndBands(c *conn, a *Article) error {
+	for _, table := range []string{"article_tags", "article_bands"} {
+		if _, err := c.exec("DELETE FROM "+table+" WHERE article_id = ?", a.ID); err != nil {
+			return err
+		}
+	}
+	for i, tag := range a.Tags {
+		if _, err := c.exec("INSERT INTO article_tags (article_id, position, tag) VALUES (?, ?, ?)", a.ID, i, tag); err != nil {
+			return err
+		}
+	}
+	for _, band := range a.SimHashBands {
+		if _, err := c.exec("INSERT INTO article_bands (article_id, band) VALUES (?, ?)", a.ID, band); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findCluster returns the cluster of a duplicate of a new Article added to other Feeds, or the Article's own cluster.
+// Duplicates join the cluster of the earliest one, so every duplicate found is in the same cluster. Drafts and
+// Articles past their Feed's maximum age, due to be removed by the janitor, are not duplicates.
+func (r *repository) findCluster(c *conn, a *Article) (string, error) {
+	candidates := []string{}
+	args := []interface{}{}
+	if a.URLKey != "" {
+		candidates = append(candidates, "a.url_key = ?")
+		args = append(args, a.URLKey)
+	}
+	if len(a.SimHashBands) > 0 {
+		candidates = append(candidates,
+			"a.id IN (SELECT b.article_id FROM article_bands b WHERE b.band IN ("+placeholders(len(a.SimHashBands))+"))")
+		args = append(args, stringArgs(a.SimHashBands)...)
+	}
+	if len(candidates) == 0 {
+		return a.ID, nil
+	}
+
+	// Bands are loose, so many Articles may share one: the most recent candidates are compared, near-duplicates
+	// being published close together
+	query := "SELECT a.cluster_id, a.url_key, a.simhash, a.published_at, f.retention_max_age_days " +
+		"FROM articles a JOIN feeds f ON f.id = a.feed_id WHERE (" + strings.Join(candidates, " OR ") +
+		") AND a.feed_id <> ? AND a.created_at >= ? AND a.status <> ? ORDER BY a.created_at DESC LIMIT ?"
+	args = append(args, a.FeedID, timestamp(a.CreatedTime.Add(-dedup.Window)), api.StatusDraft, maxDuplicateCandidates)
+	rows, err := c.query(query, args...)
+	if err != nil {
+		return "", err
+	}
+	found := ArticleList{}
+	for rows.Next() {
+		var candidate Article
+		var published *time.Time
+		var maxAgeDays *int
+		if err := rows.Scan(&candidate.ClusterID, &candidate.URLKey, &candidate.SimHash, &published, &maxAgeDays); err != nil {
+			rows.Close()
+			return "", err
+		}
+		if maxAgeDays != nil && *maxAgeDays > 0 && timeOrZero(published).Before(a.CreatedTime.AddDate(0, 0, -*maxAgeDays)) {
+			continue
+		}
+		found = append(found, candidate)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	// Sharing a band does not make near-duplicates, the fingerprints are compared to tell
+	for _, candidate := range found {
+		if a.fingerprint().Matches(candidate.fingerprint()) {
+			return candidate.ClusterID, nil
+		}
+	}
+	return a.ID, nil
+}
+
+func (r *repository) bumpFeedVersion(c *conn, feedID string, updated time.Time) error {
+	query := "UPDATE feeds SET version = version + 1, updated_at = CASE WHEN updated_at < ? THEN ? ELSE updated_at END WHERE id = ?"
+	n, err := c.exec(query, timestamp(updated), timestamp(updated), feedID)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return db.ErrNoSuchFeed
+	}
+	return nil
+}
+
+func (r *repository) GetFeedVersion(ctx context.Context, feedID string) (*db.FeedVersion, error) {
+	f, err := r.getFeed(r.conn(ctx), feedID)
+	if err != nil {
+		return nil, err
+	}
+	return f.toVersion(), nil
+}
+
+func (r *repository) GetTimelineVersion(ctx context.Context, userID string) (*db.TimelineVersion, error) {
+	c := r.conn(ctx)
+
+	var subscriptionsUpdated *time.Time
+	if err := c.queryRow("SELECT subscriptions_updated_at FROM users WHERE id = ?", userID).Scan(&subscriptionsUpdated); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, db.ErrNoSuchUser
+		}
+		return nil, err
+	}
+
+	feeds, err := queryFeeds(c, "SELECT "+feedColumns+" FROM feeds f JOIN subscriptions s ON s.feed_id = f.id WHERE s.user_id = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &db.TimelineVersion{
+		UserID:                   userID,
+		SubscriptionsUpdatedTime: timeOrZero(subscriptionsUpdated),
+		Feeds:                    []db.FeedVersion{},
+	}
+	for _, f := range feeds {
+		v.Feeds = append(v.Feeds, *f.toVersion())
+	}
+	return v, nil
+}
+
+func (r *repository) CountFeedArticles(ctx context.Context, feedID string, since time.Time) (int, error) {
+	c := r.conn(ctx)
+	if _, err := r.getFeed(c, feedID); err != nil {
+		return 0, err
+	}
+
+	var n int
+	err := c.queryRow("SELECT COUNT(*) FROM articles WHERE feed_id = ? AND created_at >= ?", feedID, timestamp(since)).Scan(&n)
+	return n, err
+}
+
+// AddUserFeed subscribes a User in a transaction, so that the subscription and the User's subscription time
+// change together
+func (r *repository) AddUserFeed(ctx context.Context, userID string, feedID string) error {
+	return r.transact(ctx, func(c *conn) error {
+		if _, err := r.getUser(c, userID); err != nil {
+			return err
+		}
+		if _, err := r.getFeed(c, feedID); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		// Subscribing twice has no effect
+		insert := "INSERT INTO subscriptions (user_id, feed_id, created_at) VALUES (?, ?, ?) ON CONFLICT (user_id, feed_id) DO NOTHING"
+		if _, err := c.exec(insert, userID, feedID, timestamp(now)); err != nil {
+			return err
+		}
+		_, err := c.exec("UPDATE users SET subscriptions_updated_at = ? WHERE id = ?", timestamp(now), userID)
+		return err
+	})
+}
+
+func (r *repository) RemoveUserFeed(ctx context.Context, userID string, feedID string) error {
+	return r.transact(ctx, func(c *conn) error {
+		if _, err := r.getUserFeed(c, userID, feedID); err != nil {
+			return err
+		}
+
+		if _, err := c.exec("DELETE FROM subscriptions WHERE user_id = ? AND feed_id = ?", userID, feedID); err != nil {
+			return err
+		}
+		_, err := c.exec("UPDATE users SET subscriptions_updated_at = ? WHERE id = ?", timestamp(time.Now()), userID)
+		return err
+	})
+}
+
+func (r *repository) ListUserFeeds(ctx context.Context, userID string) ([]api.Feed, error) {
+	feeds, err := queryFeeds(r.conn(ctx), "SELECT "+feedColumns+" FROM feeds f JOIN subscriptions s ON s.feed_id = f.id WHERE s.user_id = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+	return feeds.toAPI(), nil
+}
+
+func (r *repository) GetUserFeed(ctx context.Context, userID string, feedID string) (*api.Feed, error) {
+	f, err := r.getUserFeed(r.conn(ctx), userID, feedID)
+	if err != nil {
+		return nil, err
+	}
+	return f.toAPI(), nil
+}
+
+func (r *repository) getUserFeed(c *conn, userID string, feedID string) (*Feed, error) {
+	if _, err := r.getUser(c, userID); err != nil {
+		return nil, err
+	}
+
+	query := "SELECT " + feedColumns + " FROM feeds f JOIN subscriptions s ON s.feed_id = f.id WHERE s.user_id = ? AND f.id = ?"
+	f, err := scanFeed(c.queryRow(query, userID, feedID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, db.ErrNotSubscribed
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+func (r *repository) ListUserArticles(ctx context.Context, userID string, filter db.ArticleFilter) ([]api.Article, error) {
+	c := r.conn(ctx)
+	if _, err := r.getUser(c, userID); err != nil {
+		return nil, err
+	}
+	return r.listArticles(c, "a.feed_id IN (SELECT s.feed_id FROM subscriptions s WHERE s.user_id = ?)", []interface{}{userID}, filter)
+}
+
+func (r *repository) ListUserFeedArticles(ctx context.Context, userID string, feedID string, filter db.ArticleFilter) ([]api.Article, error) {
+	c := r.conn(ctx)
+	if _, err := r.getUserFeed(c, userID, feedID); err != nil {
+		return nil, err
+	}
+	return r.listArticles(c, "a.feed_id = ?", []interface{}{feedID}, filter)
+}
+
+// listArticles returns the visible Articles matching a condition, newest first. The condition picks the Feeds, the
+// index on feed_id and published_at serves the rest.
+func (r *repository) listArticles(c *conn, condition string, args []interface{}, filter db.ArticleFilter) ([]api.Article, error) {
+	isVisible, visibleArgs := visible(time.Now())
+	query := "SELECT " + articleSelect + " FROM articles a WHERE " + condition + " AND " + isVisible
+	args = append(append([]interface{}{}, args...), visibleArgs...)
+	if filter.Tag != "" {
+		query += " AND EXISTS (SELECT 1 FROM article_tags t WHERE t.article_id = a.id AND t.tag = ?)"
+		args = append(args, filter.Tag)
+	}
+
+	// Gather all the articles in the reverse order by published date
+	articles, err := queryArticles(c, query+" ORDER BY a.published_at DESC, a.id DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	return applyRules(articles, filter), nil
+}
+
+// queryArticles runs a query selecting articleSelect, then looks up the tags of the Articles found
+func queryArticles(c *conn, query string, args ...interface{}) (ArticleList, error) {
+	rows, err := c.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	articles := ArticleList{}
+	for rows.Next() {
+		a, err := scanArticle(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		articles = append(articles, *a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := loadTags(c, articles); err != nil {
+		return nil, err
+	}
+	return articles, nil
+}
+
+// loadTags sets the tags of Articles, a chunk of Articles at a time
+func loadTags(c *conn, articles ArticleList) error {
+	byID := map[string]*Article{}
+	ids := []string{}
+	for i := range articles {
+		byID[articles[i].ID] = &articles[i]
+		ids = append(ids, articles[i].ID)
+	}
+
+	for start := 0; start < len(ids); start += chunkSize {
+		end := start + chunkSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		query := "SELECT article_id, tag FROM article_tags WHERE article_id IN (" + placeholders(end-start) + ") ORDER BY article_id, position"
+		rows, err := c.query(query, stringArgs(ids[start:end])...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var articleID, tag string
+			if err := rows.Scan(&articleID, &tag); err != nil {
+				rows.Close()
+				return err
+			}
+			a := byID[articleID]
+			a.Tags = append(a.Tags, tag)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyRules returns the Articles not muted by the filter's rules
+func applyRules(articles ArticleList, filter db.ArticleFilter) []api.Article {
+	if filter.Rules.Empty() {
+		return articles.toAPI()
+	}
+
+	// Rules can match anything down to regular expressions, so muted Articles are dropped here rather than in the query
+	res := []api.Article{}
+	for _, a := range articles {
+		article := a.toAPI()
+		if !filter.Rules.Hides(a.FeedID, article) {
+			res = append(res, *article)
+		}
+	}
+	return res
+}
+
+// visible returns the condition matching published Articles, including scheduled Articles due but not yet
+// published by the scheduler
+func visible(now time.Time) (string, []interface{}) {
+	return "(a.status = ? OR (a.status = ? AND a.publish_at <= ?))",
+		[]interface{}{api.StatusPublished, api.StatusScheduled, timestamp(now)}
+}
+
+// unpublished lists the statuses of Articles not yet published, matched with status IN (?, ?)
+var unpublished = []interface{}{api.StatusDraft, api.StatusScheduled}
+
+func (r *repository) ListTags(ctx context.Context) ([]api.TagCount, error) {
+	isVisible, args := visible(time.Now())
+	query := "SELECT t.tag, COUNT(*) FROM article_tags t JOIN articles a ON a.id = t.article_id WHERE " + isVisible +
+		" GROUP BY t.tag ORDER BY COUNT(*) DESC, t.tag"
+	rows, err := r.conn(ctx).query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []api.TagCount{}
+	for rows.Next() {
+		var c api.TagCount
+		if err := rows.Scan(&c.Tag, &c.Articles); err != nil {
+			return nil, err
+		}
+		tags = append(tags, c)
+	}
+	return tags, rows.Err()
+}
+
+func (r *repository) DiscoverFeeds(ctx context.Context, userID string, category string) ([]db.FeedStats, error) {
+	c := r.conn(ctx)
+	if _, err := r.getUser(c, userID); err != nil {
+		return nil, err
+	}
+
+	query := "SELECT " + feedColumns + ", (SELECT COUNT(*) FROM subscriptions s WHERE s.feed_id = f.id) FROM feeds f " +
+		"WHERE NOT EXISTS (SELECT 1 FROM subscriptions s WHERE s.feed_id = f.id AND s.user_id = ?)"
+	args := []interface{}{userID}
+	if category != "" {
+		query += " AND f.category = ?"
+		args = append(args, category)
+	}
+	rows, err := c.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []db.FeedStats{}
+	for rows.Next() {
+		var subscribers int
+		f, err := scanFeed(rows, &subscribers)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, db.FeedStats{
+			Feed:        *f.toAPI(),
+			Subscribers: subscribers,
+			UpdatedTime: f.UpdatedTime,
+		})
+	}
+	return stats, rows.Err()
+}
+
+func (r *repository) ListFeedDrafts(ctx context.Context, feedID string) ([]api.Article, error) {
+	c := r.conn(ctx)
+	if _, err := r.getFeed(c, feedID); err != nil {
+		return nil, err
+	}
+
+	// Drafts never changed have no update time, they are listed last as in other databases
+	query := "SELECT " + articleSelect + " FROM articles a WHERE a.feed_id = ? AND a.status IN (?, ?) " +
+		"ORDER BY a.updated_at IS NULL, a.updated_at DESC"
+	drafts, err := queryArticles(c, query, append([]interface{}{feedID}, unpublished...)...)
+	if err != nil {
+		return nil, err
+	}
+	return drafts.toAPI(), nil
+}
+
+func (r *repository) GetFeedDraft(ctx context.Context, feedID string, articleID string) (*api.Article, error) {
+	c := r.conn(ctx)
+	if _, err := r.getFeed(c, feedID); err != nil {
+		return nil, err
+	}
+
+	a, err := getDraft(c, feedID, articleID)
+	if err != nil {
+		return nil, err
+	}
+	return a.toAPI(), nil
+}
+
+func getDraft(c *conn, feedID string, articleID string) (*Article, error) {
+	query := "SELECT " + articleSelect + " FROM articles a WHERE a.id = ? AND a.feed_id = ? AND a.status IN (?, ?)"
+	drafts, err := queryArticles(c, query, append([]interface{}{articleID, feedID}, unpublished...)...)
+	if err != nil {
+		return nil, err
+	}
+	if len(drafts) == 0 {
+		return nil, db.ErrNoSuchDraft
+	}
+	return &drafts[0], nil
+}
+
+func (r *repository) UpdateFeedDraft(ctx context.Context, feedID string, article api.Article) (*api.Article, error) {
+	var updated *Article
+	err := r.transact(ctx, func(c *conn) error {
+		if _, err := r.getFeed(c, feedID); err != nil {
+			return err
+		}
+
+		// The ID, cluster and creation time of the draft are kept, the rest is replaced
+		now := time.Now()
+		a := newArticle(feedID, article, now)
+		a.ID = article.ID
+		// Only unpublished Articles are updated, so that the scheduler and editors publish a draft at most once
+		query := "UPDATE articles SET title = ?, body = ?, body_format = ?, summary = ?, author = ?, url = ?, " +
+			"image_url = ?, published_at = ?, updated_at = ?, url_key = ?, simhash = ?, status = ?, publish_at = ? " +
+			"WHERE id = ? AND feed_id = ? AND status IN (?, ?)"
+		args := []interface{}{a.Title, a.Body, a.BodyFormat, a.Summary, a.Author, a.URL, a.ImageURL,
+			timestamp(a.PublishedTime), timestamp(a.UpdatedTime), a.URLKey, a.SimHash, a.Status,
+			nullableTimestamp(a.PublishAt), a.ID, feedID}
+		n, err := c.exec(query, append(args, unpublished...)...)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return draftNotFound(c, feedID, a.ID)
+		}
+
+		if err := storeTagsAndBands(c, a); err != nil {
+			return err
+		}
+		if err := indexArticle(c, a); err != nil {
+			return err
+		}
+		if err := r.bumpFeedVersion(c, feedID, now); err != nil {
+			return err
+		}
+
+		articles, err := queryArticles(c, "SELECT "+articleSelect+" FROM articles a WHERE a.id = ?", a.ID)
+		if err != nil {
+			return err
+		}
+		updated = &articles[0]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated.toAPI(), nil
+}
+
+func (r *repository) DeleteFeedDraft(ctx context.Context, feedID string, articleID string) error {
+	return r.transact(ctx, func(c *conn) error {
+		if _, err := r.getFeed(c, feedID); err != nil {
+			return err
+		}
+
+		query := "DELETE FROM articles WHERE id = ? AND feed_id = ? AND status IN (?, ?)"
+		n, err := c.exec(query, append([]interface{}{articleID, feedID}, unpublished...)...)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return draftNotFound(c, feedID, articleID)
+		}
+		if err := unindexArticles(c, []string{articleID}); err != nil {
+			return err
+		}
+		return r.bumpFeedVersion(c, feedID, time.Now())
+	})
+}
+
+// draftNotFound tells apart Articles that do not exist from Articles already published
+func draftNotFound(c *conn, feedID string, articleID string) error {
+	var n int
+	if err := c.queryRow("SELECT COUNT(*) FROM articles WHERE id = ? AND feed_id = ?", articleID, feedID).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return db.ErrArticlePublished
+	}
+	return db.ErrNoSuchDraft
+}
+
+func (r *repository) PublishDueArticles(ctx context.Context, now time.Time) ([]api.Article, error) {
+	query := "SELECT " + articleSelect + " FROM articles a WHERE a.status = ? AND a.publish_at <= ? ORDER BY a.publish_at"
+	due, err := queryArticles(r.conn(ctx), query, api.StatusScheduled, timestamp(now))
+	if err != nil {
+		return nil, err
+	}
+
+	published := []api.Article{}
+	for _, a := range due {
+		// Another server or an editor may have published the Article since it was found
+		publish := func(c *conn) error {
+			n, err := c.exec("UPDATE articles SET status = ? WHERE id = ? AND status = ?", api.StatusPublished, a.ID, api.StatusScheduled)
+			if err != nil || n == 0 {
+				return err
+			}
+			if err := r.bumpFeedVersion(c, a.FeedID, now); err != nil {
+				return err
+			}
+			a.Status = api.StatusPublished
+			return nil
+		}
+		if err := r.transact(ctx, publish); err != nil {
+			return published, err
+		}
+		if a.Status == api.StatusPublished {
+			published = append(published, *a.toAPI())
+		}
+	}
+	return published, nil
+}
+
+func (r *repository) SetFeedRetention(ctx context.Context, feedID string, policy *api.RetentionPolicy) error {
+	return r.transact(ctx, func(c *conn) error {
+		query := "UPDATE feeds SET retention_max_age_days = ?, retention_max_count = ? WHERE id = ?"
+		n, err := c.exec(query, append(retentionArgs(policy), feedID)...)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return db.ErrNoSuchFeed
+		}
+		return r.bumpFeedVersion(c, feedID, time.Now())
+	})
+}
+
+func (r *repository) ReportExpiredArticles(ctx context.Context, feedID string, policy api.RetentionPolicy, now time.Time) (*api.RetentionReport, error) {
+	c := r.conn(ctx)
+	if _, err := r.getFeed(c, feedID); err != nil {
+		return nil, err
+	}
+	return r.reportExpiredArticles(c, feedID, policy, now)
+}
+
+// expiredArticle is an Article a retention policy removes
+type expiredArticle struct {
+	api.ExpiredArticle
+	starred bool
+}
+
+func (r *repository) reportExpiredArticles(c *conn, feedID string, policy api.RetentionPolicy, now time.Time) (*api.RetentionReport, error) {
+	report := &api.RetentionReport{
+		FeedID:  feedID,
+		Policy:  policy,
+		Expired: []api.ExpiredArticle{},
+		Starred: []api.ExpiredArticle{},
+	}
+	if policy.Empty() {
+		return report, nil
+	}
+
+	selectPublished := "SELECT a.id, a.title, a.published_at, (SELECT COUNT(*) FROM stars s WHERE s.article_id = a.id) " +
+		"FROM articles a WHERE a.feed_id = ? AND a.status = ?"
+	cutoff := now.AddDate(0, 0, -policy.MaxAgeDays)
+	found := []expiredArticle{}
+	if policy.MaxAgeDays > 0 {
+		tooOld, err := queryExpired(c, selectPublished+" AND a.published_at < ?", feedID, api.StatusPublished, timestamp(cutoff))
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, tooOld...)
+	}
+	if policy.MaxCount > 0 {
+		query := selectPublished + " ORDER BY a.published_at DESC, a.id " + r.dialect.noLimit + " OFFSET ?"
+		beyond, err := queryExpired(c, query, feedID, api.StatusPublished, policy.MaxCount)
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, beyond...)
+	}
+
+	// Articles beyond the maximum count may also be too old, each is reported once
+	seen := map[string]bool{}
+	expired := []expiredArticle{}
+	for _, a := range found {
+		if !seen[a.ID] {
+			seen[a.ID] = true
+			expired = append(expired, a)
+		}
+	}
+	sort.SliceStable(expired, func(i, j int) bool { return expired[i].PublishedTime.Before(expired[j].PublishedTime) })
+
+	for _, a := range expired {
+		e := a.ExpiredArticle
+		e.Reason = api.ExpiryMaxCount
+		if policy.MaxAgeDays > 0 && a.PublishedTime.Before(cutoff) {
+			e.Reason = api.ExpiryMaxAge
+		}
+		if a.starred {
+			report.Starred = append(report.Starred, e)
+		} else {
+			report.Expired = append(report.Expired, e)
+		}
+	}
+	return report, nil
+}
+
+func queryExpired(c *conn, query string, args ...interface{}) ([]expiredArticle, error) {
+	rows, err := c.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	expired := []expiredArticle{}
+	for rows.Next() {
+		var a expiredArticle
+		var stars int
+		if err := rows.Scan(&a.ID, &a.Title, &a.PublishedTime, &stars); err != nil {
+			return nil, err
+		}
+		a.starred = stars > 0
+		expired = append(expired, a)
+	}
+	return expired, rows.Err()
+}
+
+func (r *repository) ExpireFeedArticles(ctx context.Context, feedID string, now time.Time) (int, error) {
+	removed := 0
+	err := r.transact(ctx, func(c *conn) error {
+		f, err := r.getFeed(c, feedID)
+		if err != nil {
+			return err
+		}
+		if f.Retention == nil {
+			return nil
+		}
+		report, err := r.reportExpiredArticles(c, feedID, *f.Retention, now)
+		if err != nil || len(report.Expired) == 0 {
+			return err
+		}
+
+		ids := []string{}
+		for _, e := range report.Expired {
+			ids = append(ids, e.ID)
+		}
+		// Articles starred since the report was made are kept
+		for start := 0; start < len(ids); start += chunkSize {
+			end := start + chunkSize
+			if end > len(ids) {
+				end = len(ids)
+			}
+			query := "DELETE FROM articles WHERE id IN (" + placeholders(end-start) + ") " +
+				"AND NOT EXISTS (SELECT 1 FROM stars s WHERE s.article_id = articles.id)"
+			n, err := c.exec(query, stringArgs(ids[start:end])...)
+			if err != nil {
+				return err
+			}
+			if err := unindexRemoved(c, ids[start:end]); err != nil {
+				return err
+			}
+			removed += int(n)
+		}
+		if removed == 0 {
+			return nil
+		}
+		return r.bumpFeedVersion(c, feedID, now)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// getPublished returns an error unless a published Article exists
+func getPublished(c *conn, articleID string) error {
+	var n int
+	if err := c.queryRow("SELECT COUNT(*) FROM articles WHERE id = ? AND status = ?", articleID, api.StatusPublished).Scan(&n); err != nil {
+		return err
+	}
+	if n == 0 {
+		return db.ErrNoSuchArticle
+	}
+	return nil
+}
+
+func (r *repository) StarArticle(ctx context.Context, userID string, articleID string) error {
+	return r.transact(ctx, func(c *conn) error {
+		if _, err := r.getUser(c, userID); err != nil {
+			return err
+		}
+		if err := getPublished(c, articleID); err != nil {
+			return err
+		}
+
+		insert := "INSERT INTO stars (user_id, article_id, created_at) VALUES (?, ?, ?) ON CONFLICT (user_id, article_id) DO NOTHING"
+		_, err := c.exec(insert, userID, articleID, timestamp(time.Now()))
+		return err
+	})
+}
+
+func (r *repository) UnstarArticle(ctx context.Context, userID string, articleID string) error {
+	c := r.conn(ctx)
+	if _, err := r.getUser(c, userID); err != nil {
+		return err
+	}
+	if err := getPublished(c, articleID); err != nil {
+		return err
+	}
+
+	_, err := c.exec("DELETE FROM stars WHERE user_id = ? AND article_id = ?", userID, articleID)
+	return err
+}
+
+func (r *repository) ListStarredArticles(ctx context.Context, userID string) ([]api.Article, error) {
+	c := r.conn(ctx)
+	if _, err := r.getUser(c, userID); err != nil {
+		return nil, err
+	}
+
+	query := "SELECT " + articleSelect + " FROM articles a JOIN stars s ON s.article_id = a.id WHERE s.user_id = ? ORDER BY a.published_at DESC"
+	articles, err := queryArticles(c, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	return articles.toAPI(), nil
+}
+
+func (r *repository) ListUnsummarizedArticles(ctx context.Context, afterID string, limit int) ([]api.Article, error) {
+	query := "SELECT " + articleSelect + " FROM articles a WHERE a.summary = '' AND a.id > ? ORDER BY a.id LIMIT ?"
+	articles, err := queryArticles(r.conn(ctx), query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return articles.toAPI(), nil
+}
+
+func (r *repository) SetArticleSummary(ctx context.Context, feedID string, articleID string, summary string) error {
+	n, err := r.conn(ctx).exec("UPDATE articles SET summary = ? WHERE id = ? AND feed_id = ?", summary, articleID, feedID)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return db.ErrNoSuchArticle
+	}
+	return nil
+}
+
+// CreateIdempotencyRecord also removes all expired records, which are otherwise kept
+func (r *repository) CreateIdempotencyRecord(ctx context.Context, record db.IdempotencyRecord) error {
+	return r.transact(ctx, func(c *conn) error {
+		if _, err := c.exec("DELETE FROM idempotency WHERE expires_at < ?", timestamp(time.Now())); err != nil {
+			return err
+		}
+
+		columns := []string{"idempotency_key", "request_hash", "status", "content_type", "body", "expires_at"}
+		values := []interface{}{record.Key, record.RequestHash, record.Status, record.ContentType, record.Body,
+			timestamp(record.ExpiresTime)}
+		if err := insertRow(c, "idempotency", columns, values, false); err != nil {
+			if err == db.ErrRecordExists {
+				return db.ErrIdempotencyKeyExists
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+func (r *repository) GetIdempotencyRecord(ctx context.Context, key string) (*db.IdempotencyRecord, error) {
+	var record db.IdempotencyRecord
+	query := "SELECT idempotency_key, request_hash, status, content_type, body, expires_at FROM idempotency " +
+		"WHERE idempotency_key = ? AND expires_at >= ?"
+	err := r.conn(ctx).queryRow(query, key, timestamp(time.Now())).Scan(&record.Key, &record.RequestHash, &record.Status,
+		&record.ContentType, &record.Body, &record.ExpiresTime)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, db.ErrNoSuchIdempotencyKey
+		}
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (r *repository) CompleteIdempotencyRecord(ctx context.Context, key string, status int, contentType string, body []byte,
+	expiresTime time.Time) error {
+	query := "UPDATE idempotency SET status = ?, content_type = ?, body = ?, expires_at = ? WHERE idempotency_key = ?"
+	n, err := r.conn(ctx).exec(query, status, contentType, body, timestamp(expiresTime), key)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return db.ErrNoSuchIdempotencyKey
+	}
+	return nil
+}
+
+func (r *repository) DeleteIdempotencyRecord(ctx context.Context, key string) error {
+	_, err := r.conn(ctx).exec("DELETE FROM idempotency WHERE idempotency_key = ?", key)
+	return err
+}
+
+func (r *repository) Close() {
+	r.db.Close()
+}
+
+func (r *repository) CreateFilterRule(ctx context.Context, userID string, rule api.FilterRule) (*api.FilterRule, error) {
+	c := r.conn(ctx)
+	if _, err := r.getUser(c, userID); err != nil {
+		return nil, err
+	}
+
+	// Times are stored with microsecond precision
+	f := FilterRule{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		Type:        rule.Type,
+		Value:       rule.Value,
+		Action:      rule.Action,
+		CreatedTime: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	if err := insertFilterRule(c, &f, false); err != nil {
+		return nil, err
+	}
+	return f.toAPI(), nil
+}
+
+func insertFilterRule(c *conn, f *FilterRule, replace bool) error {
+	columns := []string{"id", "user_id", "type", "value", "action", "created_at"}
+	values := []interface{}{f.ID, f.UserID, f.Type, f.Value, f.Action, timestamp(f.CreatedTime)}
+	return insertRow(c, "filter_rules", columns, values, replace)
+}
+
+func (r *repository) ListFilterRules(ctx context.Context, userID string) ([]api.FilterRule, error) {
+	c := r.conn(ctx)
+	if _, err := r.getUser(c, userID); err != nil {
+		return nil, err
+	}
+
+	rows, err := c.query("SELECT "+filterRuleSelect+" FROM filter_rules WHERE user_id = ? ORDER BY created_at", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []api.FilterRule{}
+	for rows.Next() {
+		f, err := scanFilterRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *f.toAPI())
+	}
+	return rules, rows.Err()
+}
+
+func (r *repository) GetFilterRule(ctx context.Context, userID string, ruleID string) (*api.FilterRule, error) {
+	c := r.conn(ctx)
+	if _, err := r.getUser(c, userID); err != nil {
+		return nil, err
+	}
+
+	f, err := scanFilterRule(c.queryRow("SELECT "+filterRuleSelect+" FROM filter_rules WHERE id = ? AND user_id = ?", ruleID, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, db.ErrNoSuchFilterRule
+		}
+		return nil, err
+	}
+	return f.toAPI(), nil
+}
+
+func (r *repository) UpdateFilterRule(ctx context.Context, userID string, rule api.FilterRule) error {
+	c := r.conn(ctx)
+	if _, err := r.getUser(c, userID); err != nil {
+		return err
+	}
+
+	query := "UPDATE filter_rules SET type = ?, value = ?, action = ? WHERE id = ? AND user_id = ?"
+	n, err := c.exec(query, rule.Type, rule.Value, rule.Action, rule.ID, userID)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return db.ErrNoSuchFilterRule
+	}
+	return nil
+}
+
+func (r *repository) DeleteFilterRule(ctx context.Context, userID string, ruleID string) error {
+	c := r.conn(ctx)
+	if _, err := r.getUser(c, userID); err != nil {
+		return err
+	}
+
+	n, err := c.exec("DELETE FROM filter_rules WHERE id = ? AND user_id = ?", ruleID, userID)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return db.ErrNoSuchFilterRule
+	}
+	return nil
+}
@@ -0,0 +1,821 @@
+package sql
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+	"github.com/if-ivan-else/tldrfeed/internal/rules"
+	"github.com/if-ivan-else/tldrfeed/internal/search"
+	"github.com/stretchr/testify/require"
+)
+
+// testRepository opens a repository on an in-memory SQLite database, which lives as long as its only connection
+func testRepository(t *testing.T) db.Repository {
+	r, err := NewRepository("sqlite://:memory:")
+	require.NoError(t, err)
+	return r
+}
+
+func TestParseURL(t *testing.T) {
+	require := require.New(t)
+
+	d, dsn, err := parseURL("sqlite:///var/lib/tldrfeed.db")
+	require.NoError(err)
+	require.Equal(sqliteDialect, d)
+	require.Equal("file:/var/lib/tldrfeed.db?_foreign_keys=1&_busy_timeout=5000", dsn)
+
+	_, dsn, err = parseURL("sqlite://tldrfeed.db?cache=shared")
+	require.NoError(err)
+	require.Equal("file:tldrfeed.db?cache=shared&_foreign_keys=1&_busy_timeout=5000", dsn)
+
+	d, dsn, err = parseURL("postgres://tldrfeed@localhost/tldrfeed?sslmode=disable")
+	require.NoError(err)
+	require.Equal(postgresDialect, d)
+	require.Equal("postgres://tldrfeed@localhost/tldrfeed?sslmode=disable", dsn)
+
+	_, _, err = parseURL("sqlite://")
+	require.Error(err)
+	_, _, err = parseURL("0.0.0.0:27017/db")
+	require.Error(err)
+
+	require.True(Supports("postgresql://localhost/tldrfeed"))
+	require.False(Supports("mongodb://localhost/tldrfeed"))
+}
+
+func TestRebind(t *testing.T) {
+	require := require.New(t)
+
+	query := "SELECT id FROM articles WHERE feed_id = ? AND status IN (?, ?)"
+	require.Equal(query, sqliteDialect.rebind(query))
+	require.Equal("SELECT id FROM articles WHERE feed_id = $1 AND status IN ($2, $3)", postgresDialect.rebind(query))
+}
+
+func TestMigrations(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	dir, err := ioutil.TempDir("", "tldrfeed-sql")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	url := SchemeSQLite + filepath.Join(dir, "tldrfeed.db")
+
+	r, err := NewRepository(url)
+	require.NoError(err)
+	u, err := r.CreateUser(ctx, "ivan")
+	require.NoError(err)
+	r.Close()
+
+	// Reopening applies no migration twice and keeps the data
+	r, err = NewRepository(url)
+	require.NoError(err)
+	defer r.Close()
+	_, err = r.GetUser(ctx, u.ID)
+	require.NoError(err)
+
+	var applied int
+	require.NoError(r.(*repository).db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&applied))
+	require.Equal(len(migrations), applied)
+}
+
+func TestUserFeeds(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	r := testRepository(t)
+	defer r.Close()
+
+	u, err := r.CreateUser(ctx, "natasha")
+	require.NoError(err)
+	f, err := r.CreateFeed(ctx, "Romanoff Royal Blog", "royals", nil)
+	require.NoError(err)
+	other, err := r.CreateFeed(ctx, "Court Gazette", "news", nil)
+	require.NoError(err)
+
+	feeds, err := r.ListFeeds(ctx, db.FeedFilter{Category: "royals"})
+	require.NoError(err)
+	require.Equal([]api.Feed{*f}, feeds)
+
+	require.Equal(db.ErrNoSuchUser, r.AddUserFeed(ctx, uuid.New().String(), f.ID))
+	require.Equal(db.ErrNoSuchFeed, r.AddUserFeed(ctx, u.ID, uuid.New().String()))
+	_, err = r.GetUserFeed(ctx, u.ID, f.ID)
+	require.Equal(db.ErrNotSubscribed, err)
+
+	before, err := r.GetTimelineVersion(ctx, u.ID)
+	require.NoError(err)
+	require.True(before.SubscriptionsUpdatedTime.IsZero())
+
+	// Subscribing twice has no effect
+	require.NoError(r.AddUserFeed(ctx, u.ID, f.ID))
+	require.NoError(r.AddUserFeed(ctx, u.ID, f.ID))
+	feeds, err = r.ListUserFeeds(ctx, u.ID)
+	require.NoError(err)
+	require.Equal([]api.Feed{*f}, feeds)
+	userFeed, err := r.GetUserFeed(ctx, u.ID, f.ID)
+	require.NoError(err)
+	require.Equal(f, userFeed)
+
+	after, err := r.GetTimelineVersion(ctx, u.ID)
+	require.NoError(err)
+	require.False(after.SubscriptionsUpdatedTime.IsZero())
+	require.Len(after.Feeds, 1)
+	require.Equal(f.ID, after.Feeds[0].FeedID)
+	require.Equal(int64(1), after.Feeds[0].Version)
+
+	stats, err := r.DiscoverFeeds(ctx, u.ID, "")
+	require.NoError(err)
+	require.Len(stats, 1)
+	require.Equal(*other, stats[0].Feed)
+	require.Zero(stats[0].Subscribers)
+
+	require.NoError(r.RemoveUserFeed(ctx, u.ID, f.ID))
+	require.Equal(db.ErrNotSubscribed, r.RemoveUserFeed(ctx, u.ID, f.ID))
+	stats, err = r.DiscoverFeeds(ctx, u.ID, "royals")
+	require.NoError(err)
+	require.Len(stats, 1)
+	require.Equal(f.ID, stats[0].Feed.ID)
+
+	// The foreign keys reject subscriptions of unknown Users
+	_, err = r.(*repository).conn(ctx).exec("INSERT INTO subscriptions (user_id, feed_id, created_at) VALUES (?, ?, ?)",
+		uuid.New().String(), f.ID, timestamp(time.Now()))
+	require.Error(err)
+}
+
+func TestArticles(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	r := testRepository(t)
+	defer r.Close()
+
+	u, err := r.CreateUser(ctx, "ivan")
+	require.NoError(err)
+	f, err := r.CreateFeed(ctx, "Wire", "", nil)
+	require.NoError(err)
+	require.NoError(r.AddUserFeed(ctx, u.ID, f.ID))
+
+	now := time.Now()
+	ids, err := r.CreateFeedArticles(ctx, f.ID, []api.Article{
+		{Title: "Old", Body: "body", Tags: []string{"news", "politics"}, PublishedTime: now.Add(-time.Hour)},
+		{Title: "New", Body: "body", Tags: []string{"news"}, PublishedTime: now},
+	})
+	require.NoError(err)
+	require.Len(ids, 2)
+	_, err = r.CreateFeedArticles(ctx, uuid.New().String(), []api.Article{{Title: "Lost"}})
+	require.Equal(db.ErrNoSuchFeed, err)
+
+	articles, err := r.ListUserArticles(ctx, u.ID, db.ArticleFilter{})
+	require.NoError(err)
+	require.Len(articles, 2)
+	require.Equal(ids[1], articles[0].ID)
+	require.Equal(ids[1], articles[0].ClusterID)
+	require.Equal(api.StatusPublished, articles[0].Status)
+	require.Equal([]string{"news", "politics"}, articles[1].Tags)
+	require.True(now.Add(-time.Hour).Truncate(time.Microsecond).Equal(articles[1].PublishedTime))
+
+	articles, err = r.ListFeedArticles(ctx, f.ID, db.ArticleFilter{Tag: "politics"})
+	require.NoError(err)
+	require.Len(articles, 1)
+	require.Equal(ids[0], articles[0].ID)
+
+	// Batches leave out unknown Feeds
+	other, err := r.CreateFeed(ctx, "Other", "", nil)
+	require.NoError(err)
+	_, err = r.CreateFeedArticle(ctx, other.ID, api.Article{Title: "Elsewhere", Body: "body", PublishedTime: now.Add(-time.Minute)})
+	require.NoError(err)
+	articles, err = r.ListArticlesOfFeeds(ctx, []string{f.ID, other.ID, uuid.New().String()}, db.ArticleFilter{}, db.Page{})
+	require.NoError(err)
+	require.Len(articles, 3)
+	require.Equal("Elsewhere", articles[1].Title)
+	feeds, err := r.GetFeeds(ctx, []string{other.ID, uuid.New().String(), f.ID})
+	require.NoError(err)
+	require.ElementsMatch([]api.Feed{*f, *other}, feeds)
+
+	set, err := rules.Compile([]api.FilterRule{{Type: api.FilterKeyword, Value: "old", Action: api.FilterExclude}})
+	require.NoError(err)
+	articles, err = r.ListUserFeedArticles(ctx, u.ID, f.ID, db.ArticleFilter{Rules: set})
+	require.NoError(err)
+	require.Len(articles, 1)
+	require.Equal(ids[1], articles[0].ID)
+
+	tags, err := r.ListTags(ctx)
+	require.NoError(err)
+	require.Equal([]api.TagCount{{Tag: "news", Articles: 2}, {Tag: "politics", Articles: 1}}, tags)
+
+	count, err := r.CountFeedArticles(ctx, f.ID, now.Add(-time.Minute))
+	require.NoError(err)
+	require.Equal(2, count)
+	version, err := r.GetFeedVersion(ctx, f.ID)
+	require.NoError(err)
+	require.Equal(int64(2), version.Version)
+}
+
+func TestPages(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	r := testRepository(t)
+	defer r.Close()
+
+	userIDs := []string{}
+	for _, name := range []string{"anna", "boris", "clara"} {
+		u, err := r.CreateUser(ctx, name)
+		require.NoError(err)
+		userIDs = append(userIDs, u.ID)
+	}
+	sort.Strings(userIDs)
+	users, err := r.ListUsers(ctx, db.Page{Limit: 2})
+	require.NoError(err)
+	require.Len(users, 2)
+	require.Equal(userIDs[:2], []string{users[0].ID, users[1].ID})
+	users, err = r.ListUsers(ctx, db.Page{After: users[1].ID, Limit: 2})
+	require.NoError(err)
+	require.Len(users, 1)
+	require.Equal(userIDs[2], users[0].ID)
+	_, err = r.ListUsers(ctx, db.Page{After: uuid.New().String()})
+	require.Equal(db.ErrNoSuchUser, err)
+
+	// The page bounds the Articles of every Feed
+	now := time.Now()
+	f, err := r.CreateFeed(ctx, "Wire", "", nil)
+	require.NoError(err)
+	ids, err := r.CreateFeedArticles(ctx, f.ID, []api.Article{
+		{Title: "First", Body: "body", PublishedTime: now.Add(-time.Hour)},
+		{Title: "Second", Body: "body", PublishedTime: now.Add(-2 * time.Hour)},
+		{Title: "Muted", Body: "body", PublishedTime: now.Add(-3 * time.Hour)},
+		{Title: "Fourth", Body: "body", PublishedTime: now.Add(-4 * time.Hour)},
+		{Title: "Fifth", Body: "body", PublishedTime: now.Add(-5 * time.Hour)},
+	})
+	require.NoError(err)
+	other, err := r.CreateFeed(ctx, "Other", "", nil)
+	require.NoError(err)
+	_, err = r.CreateFeedArticles(ctx, other.ID, []api.Article{
+		{Title: "Recent", Body: "body", PublishedTime: now.Add(-30 * time.Minute)},
+		{Title: "Older", Body: "body", PublishedTime: now.Add(-150 * time.Minute)},
+	})
+	require.NoError(err)
+
+	titles := func(articles []api.Article) []string {
+		res := []string{}
+		for _, a := range articles {
+			res = append(res, a.Title)
+		}
+		return res
+	}
+	articles, err := r.ListArticlesOfFeeds(ctx, []string{f.ID, other.ID}, db.ArticleFilter{}, db.Page{Limit: 2})
+	require.NoError(err)
+	require.Equal([]string{"First", "Second", "Recent", "Older"}, titles(articles))
+
+	// Articles hidden by rules are made up for with the following ones
+	set, err := rules.Compile([]api.FilterRule{{Type: api.FilterKeyword, Value: "muted", Action: api.FilterExclude}})
+	require.NoError(err)
+	articles, err = r.ListArticlesOfFeeds(ctx, []string{f.ID, other.ID}, db.ArticleFilter{Rules: set}, db.Page{Limit: 3})
+	require.NoError(err)
+	require.Equal([]string{"First", "Second", "Fourth", "Recent", "Older"}, titles(articles))
+
+	// Pages start after an Article, the Articles of other Feeds published before it following it
+	articles, err = r.ListArticlesOfFeeds(ctx, []string{f.ID, other.ID}, db.ArticleFilter{}, db.Page{After: ids[1], Limit: 2})
+	require.NoError(err)
+	require.Equal([]string{"Muted", "Fourth", "Older"}, titles(articles))
+	_, err = r.ListArticlesOfFeeds(ctx, []string{other.ID}, db.ArticleFilter{}, db.Page{After: ids[1], Limit: 2})
+	require.Equal(db.ErrNoSuchArticle, err)
+}
+
+func TestDuplicateArticles(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	r := testRepository(t)
+	defer r.Close()
+
+	wire, err := r.CreateFeed(ctx, "Wire", "", nil)
+	require.NoError(err)
+	paper, err := r.CreateFeed(ctx, "Paper", "", nil)
+	require.NoError(err)
+
+	body := "The tsar has abdicated in favour of his brother, who declined the throne the following day."
+	original, err := r.CreateFeedArticle(ctx, wire.ID, api.Article{Title: "Abdication", Body: body, URL: "https://wire/1?utm_source=x"})
+	require.NoError(err)
+	copied, err := r.CreateFeedArticle(ctx, paper.ID, api.Article{Title: "Abdication", Body: body, URL: "https://wire/1"})
+	require.NoError(err)
+	unrelated, err := r.CreateFeedArticle(ctx, paper.ID, api.Article{Title: "Weather", Body: "Snow is expected in Petrograd."})
+	require.NoError(err)
+
+	articles, err := r.ListFeedArticles(ctx, paper.ID, db.ArticleFilter{})
+	require.NoError(err)
+	clusters := map[string]string{}
+	for _, a := range articles {
+		clusters[a.ID] = a.ClusterID
+	}
+	require.Equal(original, clusters[copied])
+	require.Equal(unrelated, clusters[unrelated])
+
+	// Drafts and Articles past their Feed's maximum age are not duplicates
+	drafts, err := r.CreateFeed(ctx, "Drafts", "", nil)
+	require.NoError(err)
+	archive, err := r.CreateFeed(ctx, "Archive", "", &api.RetentionPolicy{MaxAgeDays: 1})
+	require.NoError(err)
+	weather := "Heavy snow is expected in Petrograd tonight, with the Neva frozen solid by the morning."
+	_, err = r.CreateFeedArticle(ctx, drafts.ID, api.Article{Title: "Snow", Body: weather, Status: api.StatusDraft})
+	require.NoError(err)
+	_, err = r.CreateFeedArticle(ctx, archive.ID, api.Article{Title: "Snow", Body: weather, PublishedTime: time.Now().AddDate(0, 0, -2)})
+	require.NoError(err)
+	fresh, err := r.CreateFeedArticle(ctx, wire.ID, api.Article{Title: "Snow", Body: weather})
+	require.NoError(err)
+	articles, err = r.ListFeedArticles(ctx, wire.ID, db.ArticleFilter{})
+	require.NoError(err)
+	require.Equal(fresh, articles[0].ID)
+	require.Equal(fresh, articles[0].ClusterID)
+}
+
+func TestDrafts(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	r := testRepository(t)
+	defer r.Close()
+
+	f, err := r.CreateFeed(ctx, "Editorial", "", nil)
+	require.NoError(err)
+
+	_, err = r.CreateFeedArticle(ctx, f.ID, api.Article{Title: "Live", Body: "body"})
+	require.NoError(err)
+	draftID, err := r.CreateFeedArticle(ctx, f.ID, api.Article{Title: "Draft", Body: "body", Tags: []string{"news"}, Status: api.StatusDraft})
+	require.NoError(err)
+	publishAt := time.Now().Add(time.Hour)
+	scheduledID, err := r.CreateFeedArticle(ctx, f.ID, api.Article{Title: "Scheduled", Body: "body",
+		Status: api.StatusScheduled, PublishAt: &publishAt, PublishedTime: publishAt})
+	require.NoError(err)
+
+	articles, err := r.ListFeedArticles(ctx, f.ID, db.ArticleFilter{})
+	require.NoError(err)
+	require.Len(articles, 1)
+	tags, err := r.ListTags(ctx)
+	require.NoError(err)
+	require.Empty(tags)
+
+	// Articles are read back by ID whatever their status, unknown IDs left out
+	stored, err := r.GetFeedArticles(ctx, f.ID, []string{draftID, uuid.New().String()})
+	require.NoError(err)
+	require.Len(stored, 1)
+	require.Equal(api.StatusDraft, stored[0].Status)
+	require.Equal([]string{"news"}, stored[0].Tags)
+
+	drafts, err := r.ListFeedDrafts(ctx, f.ID)
+	require.NoError(err)
+	require.Len(drafts, 2)
+	require.Equal(scheduledID, drafts[0].ID)
+	draft, err := r.GetFeedDraft(ctx, f.ID, draftID)
+	require.NoError(err)
+	require.True(draft.PublishedTime.IsZero())
+	require.Equal([]string{"news"}, draft.Tags)
+
+	draft.Body, draft.Tags, draft.Status, draft.PublishedTime = "final body", []string{"opinion"}, api.StatusPublished, time.Now()
+	updated, err := r.UpdateFeedDraft(ctx, f.ID, *draft)
+	require.NoError(err)
+	require.Equal("final body", updated.Body)
+	require.Equal([]string{"opinion"}, updated.Tags)
+	require.Equal(api.StatusPublished, updated.Status)
+	_, err = r.UpdateFeedDraft(ctx, f.ID, *draft)
+	require.Equal(db.ErrArticlePublished, err)
+	require.Equal(db.ErrArticlePublished, r.DeleteFeedDraft(ctx, f.ID, draftID))
+	require.Equal(db.ErrNoSuchDraft, r.DeleteFeedDraft(ctx, f.ID, uuid.New().String()))
+
+	// Due Articles are listed before the scheduler publishes them, and published once
+	articles, err = r.ListFeedArticles(ctx, f.ID, db.ArticleFilter{})
+	require.NoError(err)
+	require.Len(articles, 2)
+	published, err := r.PublishDueArticles(ctx, publishAt)
+	require.NoError(err)
+	require.Len(published, 1)
+	require.Equal(scheduledID, published[0].ID)
+	published, err = r.PublishDueArticles(ctx, publishAt)
+	require.NoError(err)
+	require.Empty(published)
+
+	drafts, err = r.ListFeedDrafts(ctx, f.ID)
+	require.NoError(err)
+	require.Empty(drafts)
+}
+
+func TestSummaryBackfill(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	r := testRepository(t)
+	defer r.Close()
+
+	f, err := r.CreateFeed(ctx, "Wire", "", nil)
+	require.NoError(err)
+	summarizedID, err := r.CreateFeedArticle(ctx, f.ID, api.Article{Title: "Summarized", Body: "body", Summary: "tl;dr"})
+	require.NoError(err)
+	ids := []string{}
+	for i := 0; i < 3; i++ {
+		id, err := r.CreateFeedArticle(ctx, f.ID, api.Article{Title: "Unsummarized", Body: "body"})
+		require.NoError(err)
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	articles, err := r.ListUnsummarizedArticles(ctx, "", 2)
+	require.NoError(err)
+	require.Len(articles, 2)
+	require.Equal(ids[:2], []string{articles[0].ID, articles[1].ID})
+	articles, err = r.ListUnsummarizedArticles(ctx, ids[1], 2)
+	require.NoError(err)
+	require.Len(articles, 1)
+	require.Equal(ids[2], articles[0].ID)
+
+	require.NoError(r.SetArticleSummary(ctx, f.ID, ids[0], "summary"))
+	require.Equal(db.ErrNoSuchArticle, r.SetArticleSummary(ctx, f.ID, "unknown", "summary"))
+	articles, err = r.ListUnsummarizedArticles(ctx, "", 10)
+	require.NoError(err)
+	require.Len(articles, 2)
+	for _, a := range articles {
+		require.NotEqual(ids[0], a.ID)
+		require.NotEqual(summarizedID, a.ID)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	r := testRepository(t)
+	defer r.Close()
+
+	f, err := r.CreateFeed(ctx, "Wire", "", nil)
+	require.NoError(err)
+	u, err := r.CreateUser(ctx, "ivan")
+	require.NoError(err)
+
+	now := time.Now()
+	ids := []string{}
+	for _, age := range []int{10, 5, 3, 1} {
+		id, err := r.CreateFeedArticle(ctx, f.ID, api.Article{Title: "title", Body: "body", PublishedTime: now.AddDate(0, 0, -age)})
+		require.NoError(err)
+		ids = append(ids, id)
+	}
+	_, err = r.CreateFeedArticle(ctx, f.ID, api.Article{Title: "Draft", Body: "body", Status: api.StatusDraft})
+	require.NoError(err)
+	require.NoError(r.StarArticle(ctx, u.ID, ids[0]))
+	require.NoError(r.StarArticle(ctx, u.ID, ids[0]))
+	require.Equal(db.ErrNoSuchArticle, r.StarArticle(ctx, u.ID, uuid.New().String()))
+
+	report, err := r.ReportExpiredArticles(ctx, f.ID, api.RetentionPolicy{MaxAgeDays: 4, MaxCount: 1}, now)
+	require.NoError(err)
+	require.Len(report.Expired, 2)
+	require.Equal(ids[1], report.Expired[0].ID)
+	require.Equal(api.ExpiryMaxAge, report.Expired[0].Reason)
+	require.Equal(ids[2], report.Expired[1].ID)
+	require.Equal(api.ExpiryMaxCount, report.Expired[1].Reason)
+	require.Len(report.Starred, 1)
+	require.Equal(ids[0], report.Starred[0].ID)
+
+	removed, err := r.ExpireFeedArticles(ctx, f.ID, now)
+	require.NoError(err)
+	require.Zero(removed)
+
+	require.NoError(r.SetFeedRetention(ctx, f.ID, &api.RetentionPolicy{MaxCount: 2}))
+	feed, err := r.GetFeed(ctx, f.ID)
+	require.NoError(err)
+	require.Equal(&api.RetentionPolicy{MaxCount: 2}, feed.Retention)
+	removed, err = r.ExpireFeedArticles(ctx, f.ID, now)
+	require.NoError(err)
+	require.Equal(1, removed)
+
+	starred, err := r.ListStarredArticles(ctx, u.ID)
+	require.NoError(err)
+	require.Len(starred, 1)
+	require.NoError(r.UnstarArticle(ctx, u.ID, ids[0]))
+	removed, err = r.ExpireFeedArticles(ctx, f.ID, now)
+	require.NoError(err)
+	require.Equal(1, removed)
+
+	articles, err := r.ListFeedArticles(ctx, f.ID, db.ArticleFilter{})
+	require.NoError(err)
+	require.Len(articles, 2)
+	drafts, err := r.ListFeedDrafts(ctx, f.ID)
+	require.NoError(err)
+	require.Len(drafts, 1)
+
+	require.NoError(r.SetFeedRetention(ctx, f.ID, nil))
+	feed, err = r.GetFeed(ctx, f.ID)
+	require.NoError(err)
+	require.Nil(feed.Retention)
+	require.Equal(db.ErrNoSuchFeed, r.SetFeedRetention(ctx, uuid.New().String(), nil))
+
+	// Feeds are created with their policy
+	policy := &api.RetentionPolicy{MaxAgeDays: 30}
+	created, err := r.CreateFeed(ctx, "Archive", "", policy)
+	require.NoError(err)
+	require.Equal(policy, created.Retention)
+	feed, err = r.GetFeed(ctx, created.ID)
+	require.NoError(err)
+	require.Equal(policy, feed.Retention)
+}
+
+func TestSearchArticles(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	r := testRepository(t)
+	defer r.Close()
+
+	f, err := r.CreateFeed(ctx, "Library", "", nil)
+	require.NoError(err)
+	other, err := r.CreateFeed(ctx, "Shelf", "", nil)
+	require.NoError(err)
+	tolstoy, err := r.CreateFeedArticle(ctx, f.ID, api.Article{Title: "War and Peace", Body: "A novel by Tolstoy"})
+	require.NoError(err)
+	_, err = r.CreateFeedArticle(ctx, f.ID, api.Article{Title: "Anna Karenina", Body: "Another novel about peace of mind"})
+	require.NoError(err)
+	_, err = r.CreateFeedArticle(ctx, other.ID, api.Article{Title: "Peace treaty", Body: "War ends"})
+	require.NoError(err)
+
+	q, err := search.ParseQuery(`"war and peace"`)
+	require.NoError(err)
+	results, err := r.SearchArticles(ctx, q, nil, 0, 10)
+	require.NoError(err)
+	require.Equal(1, results.Total)
+	require.Equal(tolstoy, results.Hits[0].Article.ID)
+
+	q, err = search.ParseQuery("peace")
+	require.NoError(err)
+	results, err = r.SearchArticles(ctx, q, nil, 0, 10)
+	require.NoError(err)
+	require.Equal(3, results.Total)
+	results, err = r.SearchArticles(ctx, q, []string{f.ID}, 1, 10)
+	require.NoError(err)
+	require.Equal(2, results.Total)
+	require.Len(results.Hits, 1)
+	results, err = r.SearchArticles(ctx, q, []string{}, 0, 10)
+	require.NoError(err)
+	require.Zero(results.Total)
+}
+
+func TestSearchIndex(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	r := testRepository(t)
+	defer r.Close()
+
+	f, err := r.CreateFeed(ctx, "Library", "", nil)
+	require.NoError(err)
+	articles := []api.Article{
+		{Title: "War and Peace", Body: "A novel by Tolstoy about war"},
+		{Title: "Anna Karenina", Body: "Another novel by Tolstoy"},
+		{Title: "Resurrection", Body: "The last novel"},
+	}
+	index := search.NewIndex()
+	for _, a := range articles {
+		id, err := r.CreateFeedArticle(ctx, f.ID, a)
+		require.NoError(err)
+		a.ID = id
+		index.Add(f.ID, a)
+	}
+
+	// Scores are relative to all Articles, as in the in-memory index
+	q, err := search.ParseQuery("tolstoy")
+	require.NoError(err)
+	results, err := r.SearchArticles(ctx, q, nil, 0, 1)
+	require.NoError(err)
+	expected := index.Search(q, nil, 0, 1)
+	require.Equal(2, results.Total)
+	require.Equal(expected.Hits[0].Article.ID, results.Hits[0].Article.ID)
+	require.InDelta(expected.Hits[0].Score, results.Hits[0].Score, 1e-9)
+	require.Equal(expected.Hits[0].Article.Body, results.Hits[0].Article.Body)
+
+	// Drafts are indexed as updated and removed from the index when deleted
+	draftID, err := r.CreateFeedArticle(ctx, f.ID, api.Article{Title: "Draft", Body: "Tolstoy", Status: api.StatusDraft})
+	require.NoError(err)
+	draft, err := r.GetFeedDraft(ctx, f.ID, draftID)
+	require.NoError(err)
+	draft.Body = "Dostoevsky"
+	_, err = r.UpdateFeedDraft(ctx, f.ID, *draft)
+	require.NoError(err)
+	require.NoError(r.DeleteFeedDraft(ctx, f.ID, draftID))
+	q, err = search.ParseQuery("dostoevsky")
+	require.NoError(err)
+	results, err = r.SearchArticles(ctx, q, nil, 0, 10)
+	require.NoError(err)
+	require.Zero(results.Total)
+
+	// Expired Articles are removed from the index
+	require.NoError(r.SetFeedRetention(ctx, f.ID, &api.RetentionPolicy{MaxCount: 1}))
+	removed, err := r.ExpireFeedArticles(ctx, f.ID, time.Now())
+	require.NoError(err)
+	require.Equal(2, removed)
+
+	var documents, postings int
+	c := r.(*repository).db
+	require.NoError(c.QueryRow("SELECT value FROM search_stats WHERE name = 'documents'").Scan(&documents))
+	require.NoError(c.QueryRow("SELECT COUNT(DISTINCT article_id) FROM search_postings").Scan(&postings))
+	require.Equal(1, documents)
+	require.Equal(1, postings)
+}
+
+func TestFilterRulesAndIdempotency(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	r := testRepository(t)
+	defer r.Close()
+
+	u, err := r.CreateUser(ctx, "ivan")
+	require.NoError(err)
+	rule, err := r.CreateFilterRule(ctx, u.ID, api.FilterRule{Type: api.FilterKeyword, Value: "sports", Action: api.FilterExclude})
+	require.NoError(err)
+	rule.Value = "football"
+	require.NoError(r.UpdateFilterRule(ctx, u.ID, *rule))
+	stored, err := r.GetFilterRule(ctx, u.ID, rule.ID)
+	require.NoError(err)
+	require.Equal("football", stored.Value)
+	require.True(rule.CreatedTime.Equal(stored.CreatedTime))
+	list, err := r.ListFilterRules(ctx, u.ID)
+	require.NoError(err)
+	require.Len(list, 1)
+	require.NoError(r.DeleteFilterRule(ctx, u.ID, rule.ID))
+	require.Equal(db.ErrNoSuchFilterRule, r.DeleteFilterRule(ctx, u.ID, rule.ID))
+	_, err = r.CreateFilterRule(ctx, uuid.New().String(), *rule)
+	require.Equal(db.ErrNoSuchUser, err)
+
+	record := db.IdempotencyRecord{Key: "POST /users:abc", RequestHash: "hash", ExpiresTime: time.Now().Add(time.Hour)}
+	require.NoError(r.CreateIdempotencyRecord(ctx, record))
+	require.Equal(db.ErrIdempotencyKeyExists, r.CreateIdempotencyRecord(ctx, record))
+	retained := time.Now().Add(24 * time.Hour)
+	require.NoError(r.CompleteIdempotencyRecord(ctx, record.Key, 201, "application/json", []byte(`{}`), retained))
+	stored2, err := r.GetIdempotencyRecord(ctx, record.Key)
+	require.NoError(err)
+	require.True(stored2.Completed())
+	require.Equal([]byte(`{}`), stored2.Body)
+	require.WithinDuration(retained, stored2.ExpiresTime, time.Second)
+	require.NoError(r.DeleteIdempotencyRecord(ctx, record.Key))
+	_, err = r.GetIdempotencyRecord(ctx, record.Key)
+	require.Equal(db.ErrNoSuchIdempotencyKey, err)
+
+	// Expired records are replaced
+	record.ExpiresTime = time.Now().Add(-time.Second)
+	require.NoError(r.CreateIdempotencyRecord(ctx, record))
+	_, err = r.GetIdempotencyRecord(ctx, record.Key)
+	require.Equal(db.ErrNoSuchIdempotencyKey, err)
+	require.NoError(r.CreateIdempotencyRecord(ctx, record))
+	require.Equal(db.ErrNoSuchIdempotencyKey, r.CompleteIdempotencyRecord(ctx, uuid.New().String(), 200, "", nil, retained))
+}
+
+func TestDataset(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	r := testRepository(t)
+	defer r.Close()
+
+	u := api.User{ID: uuid.New().String(), Name: "ivan"}
+	require.NoError(r.RestoreUser(ctx, u, false))
+	require.Equal(db.ErrRecordExists, r.RestoreUser(ctx, u, false))
+	feed := db.FeedRecord{Feed: api.Feed{ID: uuid.New().String(), Name: "Wire", Retention: &api.RetentionPolicy{MaxCount: 10}},
+		Subscribers: []string{u.ID}}
+	require.NoError(r.RestoreFeed(ctx, feed, false))
+	require.Equal(db.ErrRecordExists, r.RestoreFeed(ctx, feed, false))
+	require.Error(r.RestoreFeed(ctx, db.FeedRecord{Feed: api.Feed{ID: uuid.New().String()}, Subscribers: []string{"unknown"}}, false))
+
+	published := time.Now().Add(-time.Hour).UTC().Truncate(time.Microsecond)
+	article := db.ArticleRecord{
+		Article: api.Article{ID: uuid.New().String(), FeedID: feed.Feed.ID, Title: "Restored", Body: "body",
+			Tags: []string{"news"}, PublishedTime: published, UpdatedTime: published, ClusterID: "cluster",
+			Status: api.StatusPublished},
+		CreatedTime: published,
+		StarredBy:   []string{u.ID},
+	}
+	require.NoError(r.RestoreArticle(ctx, article, false))
+	require.Equal(db.ErrRecordExists, r.RestoreArticle(ctx, article, false))
+	article.Article.Title = "Replaced"
+	require.NoError(r.RestoreArticle(ctx, article, true))
+	rule := db.FilterRuleRecord{UserID: u.ID, Rule: api.FilterRule{ID: uuid.New().String(), Type: api.FilterKeyword,
+		Value: "sports", Action: api.FilterExclude, CreatedTime: published}}
+	require.NoError(r.RestoreFilterRule(ctx, rule, false))
+
+	feeds := []db.FeedRecord{}
+	require.NoError(r.ExportFeeds(ctx, func(f db.FeedRecord) error {
+		feeds = append(feeds, f)
+		return nil
+	}))
+	require.Equal([]db.FeedRecord{feed}, feeds)
+	articles := []db.ArticleRecord{}
+	require.NoError(r.ExportArticles(ctx, func(a db.ArticleRecord) error {
+		articles = append(articles, a)
+		return nil
+	}))
+	require.Len(articles, 1)
+	require.Equal("Replaced", articles[0].Article.Title)
+	require.Equal("cluster", articles[0].Article.ClusterID)
+	require.Equal([]string{"news"}, articles[0].Article.Tags)
+	require.Equal([]string{u.ID}, articles[0].StarredBy)
+	require.True(published.Equal(articles[0].CreatedTime))
+	filterRules := []db.FilterRuleRecord{}
+	require.NoError(r.ExportFilterRules(ctx, func(f db.FilterRuleRecord) error {
+		filterRules = append(filterRules, f)
+		return nil
+	}))
+	require.Len(filterRules, 1)
+	require.Equal(rule.Rule.ID, filterRules[0].Rule.ID)
+
+	// A replaced Feed gets a newer version
+	require.NoError(r.RestoreFeed(ctx, feed, true))
+	version, err := r.GetFeedVersion(ctx, feed.Feed.ID)
+	require.NoError(err)
+	require.Equal(int64(4), version.Version)
+	userFeeds, err := r.ListUserFeeds(ctx, u.ID)
+	require.NoError(err)
+	require.Len(userFeeds, 1)
+
+	// Users unsubscribed by a replacement see their subscriptions change
+	before, err := r.GetTimelineVersion(ctx, u.ID)
+	require.NoError(err)
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(r.RestoreFeed(ctx, db.FeedRecord{Feed: feed.Feed}, true))
+	after, err := r.GetTimelineVersion(ctx, u.ID)
+	require.NoError(err)
+	require.True(after.SubscriptionsUpdatedTime.After(before.SubscriptionsUpdatedTime))
+
+	// HTML bodies are sanitized as when Articles are created
+	unsafe := db.ArticleRecord{Article: api.Article{ID: uuid.New().String(), FeedID: feed.Feed.ID, Title: "Unsafe",
+		Body: `<p>Hello</p><script>alert(1)</script>`, BodyFormat: api.BodyHTML}}
+	require.NoError(r.RestoreArticle(ctx, unsafe, false))
+	articles = []db.ArticleRecord{}
+	require.NoError(r.ExportArticles(ctx, func(a db.ArticleRecord) error {
+		articles = append(articles, a)
+		return nil
+	}))
+	for _, a := range articles {
+		require.NotContains(a.Article.Body, "<script>")
+	}
+}
+
+func TestCanceledContext(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	r := testRepository(t)
+	defer r.Close()
+
+	u, err := r.CreateUser(ctx, "reader")
+	require.NoError(err)
+	f, err := r.CreateFeed(ctx, "News", "", nil)
+	require.NoError(err)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = r.ListUserArticles(canceled, u.ID, db.ArticleFilter{})
+	require.Equal(context.Canceled, err)
+	require.Equal(context.Canceled, r.AddUserFeed(canceled, u.ID, f.ID))
+
+	// Nothing is left behind by the canceled transaction and the connection can still be used
+	feeds, err := r.ListUserFeeds(ctx, u.ID)
+	require.NoError(err)
+	require.Empty(feeds)
+}
+
+func TestAuditLog(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	r := testRepository(t)
+	defer r.Close()
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	appended := []api.AuditRecord{
+		{ID: "b", Time: now, Principal: "editor", RequestID: "req-1", Action: api.AuditCreate, EntityType: api.AuditFeed, EntityID: "f1", After: []byte(`{"name":"Hot"}`)},
+		{ID: "a", Time: now, Principal: "editor", Action: api.AuditCreate, EntityType: api.AuditUser, EntityID: "u1", After: []byte(`{"name":"reader"}`)},
+		{ID: "c", Time: now.Add(time.Second), Principal: "scheduler", Action: api.AuditPublish, EntityType: api.AuditArticle, EntityID: "a1"},
+		{ID: "d", Time: now.Add(2 * time.Second), Principal: "editor", Action: api.AuditDelete, EntityType: api.AuditFeed, EntityID: "f1", Before: []byte(`{"name":"Hot"}`)},
+	}
+	for _, record := range appended {
+		require.NoError(r.AppendAuditRecord(ctx, record))
+	}
+
+	// Records list oldest first, records of the same time by ID
+	records, err := r.ListAuditRecords(ctx, db.AuditFilter{})
+	require.NoError(err)
+	require.Len(records, 4)
+	ids := []string{}
+	for _, record := range records {
+		ids = append(ids, record.ID)
+	}
+	require.Equal([]string{"a", "b", "c", "d"}, ids)
+	require.True(now.Equal(records[1].Time))
+	require.Equal("req-1", records[1].RequestID)
+	require.JSONEq(`{"name":"Hot"}`, string(records[1].After))
+	require.Nil(records[1].Before)
+
+	for filter, expected := range map[*db.AuditFilter][]string{
+		{EntityType: api.AuditFeed, EntityID: "f1"}:    {"b", "d"},
+		{Principal: "editor", Action: api.AuditCreate}: {"a", "b"},
+		{Since: now.Add(time.Second)}:                  {"c", "d"},
+		{Until: now.Add(time.Second)}:                  {"a", "b"},
+		{Limit: 3}:                                     {"b", "c", "d"},
+		{Principal: "janitor"}:                         {},
+	} {
+		records, err := r.ListAuditRecords(ctx, *filter)
+		require.NoError(err)
+		ids := []string{}
+		for _, record := range records {
+			ids = append(ids, record.ID)
+		}
+		require.Equal(expected, ids, "%+v", *filter)
+	}
+}
@@ -0,0 +1,331 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/if-ivan-else/tldrfeed/internal/search"
+)
+
+// Names of the search_stats rows: the number of Articles indexed and the number of terms of each field
+const statDocuments = "documents"
+
+// postingsPerInsert is the number of postings inserted by a statement
+const postingsPerInsert = 100
+
+// The search index is kept in the search_documents, search_postings and search_stats tables: the lengths of the fields
+// of every Article, the positions of every term in the fields of every Article, and running totals of both. They
+// have no foreign keys, so that Articles are removed from the index with the statistics updated before or after they
+// are deleted.
+
+// indexArticle adds an Article to the search index, replacing a previously indexed version of it
+func indexArticle(c *conn, a *Article) error {
+	if err := unindexArticles(c, []string{a.ID}); err != nil {
+		return err
+	}
+
+	d := search.Analyze(*a.toAPI())
+	insert := "INSERT INTO search_documents (article_id, title_terms, body_terms) VALUES (?, ?, ?)"
+	if _, err := c.exec(insert, a.ID, d.Lengths[search.FieldTitle], d.Lengths[search.FieldBody]); err != nil {
+		return err
+	}
+
+	values := []string{}
+	args := []interface{}{}
+	flush := func() error {
+		if len(values) == 0 {
+			return nil
+		}
+		insert := "INSERT INTO search_postings (term, article_id, field, positions) VALUES " + strings.Join(values, ", ")
+		_, err := c.exec(insert, args...)
+		values, args = values[:0], args[:0]
+		return err
+	}
+	for _, field := range search.Fields {
+		for term, positions := range d.Positions[field] {
+			values = append(values, "(?, ?, ?, ?)")
+			args = append(args, term, a.ID, field, formatPositions(positions))
+			if len(values) == postingsPerInsert {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	return updateSearchStats(c, 1, d.Lengths[search.FieldTitle], d.Lengths[search.FieldBody])
+}
+
+// unindexArticles removes Articles from the search index, Articles that are not indexed are ignored
+func unindexArticles(c *conn, articleIDs []string) error {
+	for start := 0; start < len(articleIDs); start += chunkSize {
+		end := start + chunkSize
+		if end > len(articleIDs) {
+			end = len(articleIDs)
+		}
+		ids := stringArgs(articleIDs[start:end])
+		in := "(" + placeholders(len(ids)) + ")"
+
+		var documents int
+		var titleTerms, bodyTerms sql.NullInt64
+		query := "SELECT COUNT(*), SUM(title_terms), SUM(body_terms) FROM search_documents WHERE article_id IN " + in
+		if err := c.queryRow(query, ids...).Scan(&documents, &titleTerms, &bodyTerms); err != nil {
+			return err
+		}
+		if documents == 0 {
+			continue
+		}
+		for _, table := range []string{"search_postings", "search_documents"} {
+			if _, err := c.exec("DELETE FROM "+table+" WHERE article_id IN "+in, ids...); err != nil {
+				return err
+			}
+		}
+		if err := updateSearchStats(c, -documents, -int(titleTerms.Int64), -int(bodyTerms.Int64)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unindexRemoved removes the Articles among articleIDs that were deleted from the search index
+func unindexRemoved(c *conn, articleIDs []string) error {
+	if len(articleIDs) == 0 {
+		return nil
+	}
+	kept := map[string]bool{}
+	rows, err := c.query("SELECT id FROM articles WHERE id IN ("+placeholders(len(articleIDs))+")", stringArgs(articleIDs)...)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		kept[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	removed := []string{}
+	for _, id := range articleIDs {
+		if !kept[id] {
+			removed = append(removed, id)
+		}
+	}
+	return unindexArticles(c, removed)
+}
+
+func updateSearchStats(c *conn, documents int, titleTerms int, bodyTerms int) error {
+	deltas := map[string]int{statDocuments: documents, search.FieldTitle: titleTerms, search.FieldBody: bodyTerms}
+	for name, delta := range deltas {
+		if _, err := c.exec("UPDATE search_stats SET value = value + ? WHERE name = ?", delta, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexAllArticles indexes the Articles stored before the search index was introduced, a page at a time
+func indexAllArticles(c *conn) error {
+	last := ""
+	for {
+		articles, err := queryArticles(c, "SELECT "+articleSelect+" FROM articles a WHERE a.id > ? ORDER BY a.id LIMIT ?",
+			last, chunkSize)
+		if err != nil {
+			return err
+		}
+		for i := range articles {
+			if err := indexArticle(c, &articles[i]); err != nil {
+				return err
+			}
+		}
+		if len(articles) < chunkSize {
+			return nil
+		}
+		last = articles[len(articles)-1].ID
+	}
+}
+
+func formatPositions(positions []int) string {
+	s := make([]string, len(positions))
+	for i, p := range positions {
+		s[i] = strconv.Itoa(p)
+	}
+	return strings.Join(s, ",")
+}
+
+func parsePositions(s string) ([]int, error) {
+	fields := strings.Split(s, ",")
+	positions := make([]int, len(fields))
+	for i, f := range fields {
+		p, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, err
+		}
+		positions[i] = p
+	}
+	return positions, nil
+}
+
+// searchStats reads the statistics of the search index, with the number of Articles containing each term
+func searchStats(c *conn, terms []string) (*search.Stats, error) {
+	stats := &search.Stats{FieldLengths: map[string]int{}, Frequencies: map[string]int{}}
+	rows, err := c.query("SELECT name, value FROM search_stats")
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var name string
+		var value int
+		if err := rows.Scan(&name, &value); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if name == statDocuments {
+			stats.Documents = value
+		} else {
+			stats.FieldLengths[name] = value
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query := "SELECT term, COUNT(DISTINCT article_id) FROM search_postings WHERE term IN (" + placeholders(len(terms)) +
+		") GROUP BY term"
+	rows, err = c.query(query, stringArgs(terms)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var term string
+		var n int
+		if err := rows.Scan(&term, &n); err != nil {
+			return nil, err
+		}
+		stats.Frequencies[term] = n
+	}
+	return stats, rows.Err()
+}
+
+// SearchArticles looks the Articles containing all the query's terms up in the search index and ranks them by the
+// postings of the query's terms, relative to all indexed Articles. Only the Articles of the page are read.
+func (r *repository) SearchArticles(ctx context.Context, query *search.Query, feedIDs []string, offset int, limit int) (*search.Results, error) {
+	if feedIDs != nil && len(feedIDs) == 0 {
+		return &search.Results{Total: 0, Hits: []search.Hit{}}, nil
+	}
+
+	c := r.conn(ctx)
+	terms := query.Terms()
+	stats, err := searchStats(c, terms)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range terms {
+		if stats.Frequencies[t] == 0 {
+			return &search.Results{Total: 0, Hits: []search.Hit{}}, nil
+		}
+	}
+
+	in := "(" + placeholders(len(terms)) + ")"
+	isVisible, visibleArgs := visible(time.Now())
+	args := stringArgs(terms)
+	conditions := []string{"p.term IN " + in, isVisible}
+	args = append(args, visibleArgs...)
+	conditions = append(conditions,
+		"p.article_id IN (SELECT s.article_id FROM search_postings s WHERE s.term IN "+in+
+			" GROUP BY s.article_id HAVING COUNT(DISTINCT s.term) = ?)")
+	args = append(append(args, stringArgs(terms)...), len(terms))
+	if feedIDs != nil {
+		conditions = append(conditions, "a.feed_id IN ("+placeholders(len(feedIDs))+")")
+		args = append(args, stringArgs(feedIDs)...)
+	}
+
+	postings := "SELECT p.article_id, a.feed_id, a.published_at, p.field, p.term, p.positions, d.title_terms, d.body_terms " +
+		"FROM search_postings p JOIN articles a ON a.id = p.article_id JOIN search_documents d ON d.article_id = p.article_id " +
+		"WHERE " + strings.Join(conditions, " AND ")
+	rows, err := c.query(postings, args...)
+	if err != nil {
+		return nil, err
+	}
+	hits := map[string]*search.Hit{}
+	documents := map[string]*search.Document{}
+	for rows.Next() {
+		var id, feedID, field, term, positions string
+		var published *time.Time
+		var titleTerms, bodyTerms int
+		if err := rows.Scan(&id, &feedID, &published, &field, &term, &positions, &titleTerms, &bodyTerms); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		d, ok := documents[id]
+		if !ok {
+			d = &search.Document{
+				Positions: map[string]map[string][]int{search.FieldTitle: {}, search.FieldBody: {}},
+				Lengths:   map[string]int{search.FieldTitle: titleTerms, search.FieldBody: bodyTerms},
+			}
+			documents[id] = d
+			hits[id] = &search.Hit{FeedID: feedID}
+			hits[id].Article.ID = id
+			hits[id].Article.PublishedTime = timeOrZero(published)
+		}
+		if d.Positions[field][term], err = parsePositions(positions); err != nil {
+			rows.Close()
+			return nil, err
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Terms are matched by the index, phrases and fields by the positions of their terms
+	matches := []search.Hit{}
+	for id, d := range documents {
+		if score, ok := query.Score(d, stats); ok {
+			hit := hits[id]
+			hit.Score = score
+			matches = append(matches, *hit)
+		}
+	}
+	results := search.Rank(matches, offset, limit)
+	if len(results.Hits) == 0 {
+		return results, nil
+	}
+
+	ids := make([]string, len(results.Hits))
+	for i, h := range results.Hits {
+		ids[i] = h.Article.ID
+	}
+	articles, err := queryArticles(c, "SELECT "+articleSelect+" FROM articles a WHERE a.id IN ("+placeholders(len(ids))+")",
+		stringArgs(ids)...)
+	if err != nil {
+		return nil, err
+	}
+	byID := map[string]*Article{}
+	for i := range articles {
+		byID[articles[i].ID] = &articles[i]
+	}
+	page := results.Hits[:0]
+	for _, h := range results.Hits {
+		// Articles removed since they were ranked are left out
+		if a, ok := byID[h.Article.ID]; ok {
+			h.Article = *a.toAPI()
+			page = append(page, h)
+		}
+	}
+	results.Hits = page
+	return results, nil
+}
@@ -0,0 +1,19 @@
+package db
+
+import (
+	"context"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+)
+
+// SummaryStore defines the backfill of summaries of Articles stored without one, i.e. before summaries were computed
+// when Articles are added. Article listings return summaries as stored.
+type SummaryStore interface {
+	// ListUnsummarizedArticles returns up to limit Articles stored without a summary whose IDs sort after afterID,
+	// in ID order, so that Articles whose summary stays empty are not listed again
+	ListUnsummarizedArticles(ctx context.Context, afterID string, limit int) ([]api.Article, error)
+
+	// SetArticleSummary stores the summary of an Article of a Feed, failing with ErrNoSuchArticle for unknown
+	// Articles
+	SetArticleSummary(ctx context.Context, feedID string, articleID string, summary string) error
+}
@@ -0,0 +1,451 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+	"github.com/if-ivan-else/tldrfeed/internal/search"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the spans of repository calls
+const tracerName = "github.com/if-ivan-else/tldrfeed/internal/db"
+
+// Attributes of repository call spans, the IDs of the records a call is given
+const (
+	systemKey    = attribute.Key("db.system")
+	operationKey = attribute.Key("db.operation")
+	userIDKey    = attribute.Key("tldrfeed.user_id")
+	feedIDKey    = attribute.Key("tldrfeed.feed_id")
+	articleIDKey = attribute.Key("tldrfeed.article_id")
+	ruleIDKey    = attribute.Key("tldrfeed.filter_rule_id")
+)
+
+// Repository is a db.Repository recording a span of every call, as a child of the span of the call's context
+type Repository struct {
+	db.Repository
+	tracer trace.Tracer
+	system string
+}
+
+// NewRepository decorates a repository with spans created by provider. System names the DB (e.g. "mongodb") in
+// the spans' db.system attribute.
+func NewRepository(repo db.Repository, provider trace.TracerProvider, system string) *Repository {
+	return &Repository{
+		Repository: repo,
+		tracer:     provider.Tracer(tracerName),
+		system:     system,
+	}
+}
+
+// start starts the span of a call
+func (r *Repository) start(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, systemKey.String(r.system), operationKey.String(operation))
+	return r.tracer.Start(ctx, "db."+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// end ends the span of a call, recording its error if it failed
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (r *Repository) CreateUser(ctx context.Context, name string) (*api.User, error) {
+	ctx, span := r.start(ctx, "CreateUser")
+	u, err := r.Repository.CreateUser(ctx, name)
+	end(span, err)
+	return u, err
+}
+
+func (r *Repository) ListUsers(ctx context.Context, page db.Page) ([]api.User, error) {
+	ctx, span := r.start(ctx, "ListUsers")
+	users, err := r.Repository.ListUsers(ctx, page)
+	end(span, err)
+	return users, err
+}
+
+func (r *Repository) GetUser(ctx context.Context, userID string) (*api.User, error) {
+	ctx, span := r.start(ctx, "GetUser", userIDKey.String(userID))
+	u, err := r.Repository.GetUser(ctx, userID)
+	end(span, err)
+	return u, err
+}
+
+func (r *Repository) CreateFeed(ctx context.Context, name string, category string, retention *api.RetentionPolicy) (*api.Feed, error) {
+	ctx, span := r.start(ctx, "CreateFeed")
+	f, err := r.Repository.CreateFeed(ctx, name, category, retention)
+	end(span, err)
+	return f, err
+}
+
+func (r *Repository) ListFeeds(ctx context.Context, filter db.FeedFilter) ([]api.Feed, error) {
+	ctx, span := r.start(ctx, "ListFeeds")
+	feeds, err := r.Repository.ListFeeds(ctx, filter)
+	end(span, err)
+	return feeds, err
+}
+
+func (r *Repository) GetFeed(ctx context.Context, feedID string) (*api.Feed, error) {
+	ctx, span := r.start(ctx, "GetFeed", feedIDKey.String(feedID))
+	f, err := r.Repository.GetFeed(ctx, feedID)
+	end(span, err)
+	return f, err
+}
+
+func (r *Repository) GetFeeds(ctx context.Context, feedIDs []string) ([]api.Feed, error) {
+	ctx, span := r.start(ctx, "GetFeeds", feedIDKey.StringSlice(feedIDs))
+	feeds, err := r.Repository.GetFeeds(ctx, feedIDs)
+	end(span, err)
+	return feeds, err
+}
+
+func (r *Repository) GetFeedVersion(ctx context.Context, feedID string) (*db.FeedVersion, error) {
+	ctx, span := r.start(ctx, "GetFeedVersion", feedIDKey.String(feedID))
+	v, err := r.Repository.GetFeedVersion(ctx, feedID)
+	end(span, err)
+	return v, err
+}
+
+func (r *Repository) ListFeedArticles(ctx context.Context, feedID string, filter db.ArticleFilter) ([]api.Article, error) {
+	ctx, span := r.start(ctx, "ListFeedArticles", feedIDKey.String(feedID))
+	articles, err := r.Repository.ListFeedArticles(ctx, feedID, filter)
+	end(span, err)
+	return articles, err
+}
+
+func (r *Repository) ListArticlesOfFeeds(ctx context.Context, feedIDs []string, filter db.ArticleFilter, page db.Page) ([]api.Article, error) {
+	ctx, span := r.start(ctx, "ListArticlesOfFeeds", feedIDKey.StringSlice(feedIDs))
+	articles, err := r.Repository.ListArticlesOfFeeds(ctx, feedIDs, filter, page)
+	end(span, err)
+	return articles, err
+}
+
+func (r *Repository) GetFeedArticles(ctx context.Context, feedID string, articleIDs []string) ([]api.Article, error) {
+	ctx, span := r.start(ctx, "GetFeedArticles", feedIDKey.String(feedID))
+	articles, err := r.Repository.GetFeedArticles(ctx, feedID, articleIDs)
+	end(span, err)
+	return articles, err
+}
+
+func (r *Repository) CreateFeedArticle(ctx context.Context, feedID string, article api.Article) (string, error) {
+	ctx, span := r.start(ctx, "CreateFeedArticle", feedIDKey.String(feedID))
+	articleID, err := r.Repository.CreateFeedArticle(ctx, feedID, article)
+	end(span, err)
+	return articleID, err
+}
+
+func (r *Repository) CreateFeedArticles(ctx context.Context, feedID string, articles []api.Article) ([]string, error) {
+	ctx, span := r.start(ctx, "CreateFeedArticles", feedIDKey.String(feedID))
+	articleIDs, err := r.Repository.CreateFeedArticles(ctx, feedID, articles)
+	end(span, err)
+	return articleIDs, err
+}
+
+func (r *Repository) CountFeedArticles(ctx context.Context, feedID string, since time.Time) (int, error) {
+	ctx, span := r.start(ctx, "CountFeedArticles", feedIDKey.String(feedID))
+	n, err := r.Repository.CountFeedArticles(ctx, feedID, since)
+	end(span, err)
+	return n, err
+}
+
+func (r *Repository) AddUserFeed(ctx context.Context, userID string, feedID string) error {
+	ctx, span := r.start(ctx, "AddUserFeed", userIDKey.String(userID), feedIDKey.String(feedID))
+	err := r.Repository.AddUserFeed(ctx, userID, feedID)
+	end(span, err)
+	return err
+}
+
+func (r *Repository) RemoveUserFeed(ctx context.Context, userID string, feedID string) error {
+	ctx, span := r.start(ctx, "RemoveUserFeed", userIDKey.String(userID), feedIDKey.String(feedID))
+	err := r.Repository.RemoveUserFeed(ctx, userID, feedID)
+	end(span, err)
+	return err
+}
+
+func (r *Repository) ListUserFeeds(ctx context.Context, userID string) ([]api.Feed, error) {
+	ctx, span := r.start(ctx, "ListUserFeeds", userIDKey.String(userID))
+	feeds, err := r.Repository.ListUserFeeds(ctx, userID)
+	end(span, err)
+	return feeds, err
+}
+
+func (r *Repository) GetUserFeed(ctx context.Context, userID string, feedID string) (*api.Feed, error) {
+	ctx, span := r.start(ctx, "GetUserFeed", userIDKey.String(userID), feedIDKey.String(feedID))
+	f, err := r.Repository.GetUserFeed(ctx, userID, feedID)
+	end(span, err)
+	return f, err
+}
+
+func (r *Repository) ListUserArticles(ctx context.Context, userID string, filter db.ArticleFilter) ([]api.Article, error) {
+	ctx, span := r.start(ctx, "ListUserArticles", userIDKey.String(userID))
+	articles, err := r.Repository.ListUserArticles(ctx, userID, filter)
+	end(span, err)
+	return articles, err
+}
+
+func (r *Repository) ListUserFeedArticles(ctx context.Context, userID string, feedID string, filter db.ArticleFilter) ([]api.Article, error) {
+	ctx, span := r.start(ctx, "ListUserFeedArticles", userIDKey.String(userID), feedIDKey.String(feedID))
+	articles, err := r.Repository.ListUserFeedArticles(ctx, userID, feedID, filter)
+	end(span, err)
+	return articles, err
+}
+
+func (r *Repository) ListTags(ctx context.Context) ([]api.TagCount, error) {
+	ctx, span := r.start(ctx, "ListTags")
+	tags, err := r.Repository.ListTags(ctx)
+	end(span, err)
+	return tags, err
+}
+
+func (r *Repository) DiscoverFeeds(ctx context.Context, userID string, category string) ([]db.FeedStats, error) {
+	ctx, span := r.start(ctx, "DiscoverFeeds", userIDKey.String(userID))
+	stats, err := r.Repository.DiscoverFeeds(ctx, userID, category)
+	end(span, err)
+	return stats, err
+}
+
+func (r *Repository) SearchArticles(ctx context.Context, query *search.Query, feedIDs []string, offset int, limit int) (*search.Results, error) {
+	ctx, span := r.start(ctx, "SearchArticles")
+	results, err := r.Repository.SearchArticles(ctx, query, feedIDs, offset, limit)
+	end(span, err)
+	return results, err
+}
+
+func (r *Repository) GetTimelineVersion(ctx context.Context, userID string) (*db.TimelineVersion, error) {
+	ctx, span := r.start(ctx, "GetTimelineVersion", userIDKey.String(userID))
+	v, err := r.Repository.GetTimelineVersion(ctx, userID)
+	end(span, err)
+	return v, err
+}
+
+func (r *Repository) CreateIdempotencyRecord(ctx context.Context, record db.IdempotencyRecord) error {
+	ctx, span := r.start(ctx, "CreateIdempotencyRecord")
+	err := r.Repository.CreateIdempotencyRecord(ctx, record)
+	end(span, err)
+	return err
+}
+
+func (r *Repository) GetIdempotencyRecord(ctx context.Context, key string) (*db.IdempotencyRecord, error) {
+	ctx, span := r.start(ctx, "GetIdempotencyRecord")
+	record, err := r.Repository.GetIdempotencyRecord(ctx, key)
+	end(span, err)
+	return record, err
+}
+
+func (r *Repository) CompleteIdempotencyRecord(ctx context.Context, key string, status int, contentType string, body []byte,
+	expiresTime time.Time) error {
+	ctx, span := r.start(ctx, "CompleteIdempotencyRecord")
+	err := r.Repository.CompleteIdempotencyRecord(ctx, key, status, contentType, body, expiresTime)
+	end(span, err)
+	return err
+}
+
+func (r *Repository) DeleteIdempotencyRecord(ctx context.Context, key string) error {
+	ctx, span := r.start(ctx, "DeleteIdempotencyRecord")
+	err := r.Repository.DeleteIdempotencyRecord(ctx, key)
+	end(span, err)
+	return err
+}
+
+func (r *Repository) CreateFilterRule(ctx context.Context, userID string, rule api.FilterRule) (*api.FilterRule, error) {
+	ctx, span := r.start(ctx, "CreateFilterRule", userIDKey.String(userID))
+	created, err := r.Repository.CreateFilterRule(ctx, userID, rule)
+	end(span, err)
+	return created, err
+}
+
+func (r *Repository) ListFilterRules(ctx context.Context, userID string) ([]api.FilterRule, error) {
+	ctx, span := r.start(ctx, "ListFilterRules", userIDKey.String(userID))
+	filterRules, err := r.Repository.ListFilterRules(ctx, userID)
+	end(span, err)
+	return filterRules, err
+}
+
+func (r *Repository) GetFilterRule(ctx context.Context, userID string, ruleID string) (*api.FilterRule, error) {
+	ctx, span := r.start(ctx, "GetFilterRule", userIDKey.String(userID), ruleIDKey.String(ruleID))
+	rule, err := r.Repository.GetFilterRule(ctx, userID, ruleID)
+	end(span, err)
+	return rule, err
+}
+
+func (r *Repository) UpdateFilterRule(ctx context.Context, userID string, rule api.FilterRule) error {
+	ctx, span := r.start(ctx, "UpdateFilterRule", userIDKey.String(userID))
+	err := r.Repository.UpdateFilterRule(ctx, userID, rule)
+	end(span, err)
+	return err
+}
+
+func (r *Repository) DeleteFilterRule(ctx context.Context, userID string, ruleID string) error {
+	ctx, span := r.start(ctx, "DeleteFilterRule", userIDKey.String(userID), ruleIDKey.String(ruleID))
+	err := r.Repository.DeleteFilterRule(ctx, userID, ruleID)
+	end(span, err)
+	return err
+}
+
+func (r *Repository) ListFeedDrafts(ctx context.Context, feedID string) ([]api.Article, error) {
+	ctx, span := r.start(ctx, "ListFeedDrafts", feedIDKey.String(feedID))
+	articles, err := r.Repository.ListFeedDrafts(ctx, feedID)
+	end(span, err)
+	return articles, err
+}
+
+func (r *Repository) GetFeedDraft(ctx context.Context, feedID string, articleID string) (*api.Article, error) {
+	ctx, span := r.start(ctx, "GetFeedDraft", feedIDKey.String(feedID), articleIDKey.String(articleID))
+	a, err := r.Repository.GetFeedDraft(ctx, feedID, articleID)
+	end(span, err)
+	return a, err
+}
+
+func (r *Repository) UpdateFeedDraft(ctx context.Context, feedID string, article api.Article) (*api.Article, error) {
+	ctx, span := r.start(ctx, "UpdateFeedDraft", feedIDKey.String(feedID))
+	a, err := r.Repository.UpdateFeedDraft(ctx, feedID, article)
+	end(span, err)
+	return a, err
+}
+
+func (r *Repository) DeleteFeedDraft(ctx context.Context, feedID string, articleID string) error {
+	ctx, span := r.start(ctx, "DeleteFeedDraft", feedIDKey.String(feedID), articleIDKey.String(articleID))
+	err := r.Repository.DeleteFeedDraft(ctx, feedID, articleID)
+	end(span, err)
+	return err
+}
+
+func (r *Repository) PublishDueArticles(ctx context.Context, now time.Time) ([]api.Article, error) {
+	ctx, span := r.start(ctx, "PublishDueArticles")
+	articles, err := r.Repository.PublishDueArticles(ctx, now)
+	end(span, err)
+	return articles, err
+}
+
+func (r *Repository) SetFeedRetention(ctx context.Context, feedID string, policy *api.RetentionPolicy) error {
+	ctx, span := r.start(ctx, "SetFeedRetention", feedIDKey.String(feedID))
+	err := r.Repository.SetFeedRetention(ctx, feedID, policy)
+	end(span, err)
+	return err
+}
+
+func (r *Repository) ReportExpiredArticles(ctx context.Context, feedID string, policy api.RetentionPolicy, now time.Time) (*api.RetentionReport, error) {
+	ctx, span := r.start(ctx, "ReportExpiredArticles", feedIDKey.String(feedID))
+	report, err := r.Repository.ReportExpiredArticles(ctx, feedID, policy, now)
+	end(span, err)
+	return report, err
+}
+
+func (r *Repository) ExpireFeedArticles(ctx context.Context, feedID string, now time.Time) (int, error) {
+	ctx, span := r.start(ctx, "ExpireFeedArticles", feedIDKey.String(feedID))
+	n, err := r.Repository.ExpireFeedArticles(ctx, feedID, now)
+	end(span, err)
+	return n, err
+}
+
+func (r *Repository) StarArticle(ctx context.Context, userID string, articleID string) error {
+	ctx, span := r.start(ctx, "StarArticle", userIDKey.String(userID), articleIDKey.String(articleID))
+	err := r.Repository.StarArticle(ctx, userID, articleID)
+	end(span, err)
+	return err
+}
+
+func (r *Repository) UnstarArticle(ctx context.Context, userID string, articleID string) error {
+	ctx, span := r.start(ctx, "UnstarArticle", userIDKey.String(userID), articleIDKey.String(articleID))
+	err := r.Repository.UnstarArticle(ctx, userID, articleID)
+	end(span, err)
+	return err
+}
+
+func (r *Repository) ListStarredArticles(ctx context.Context, userID string) ([]api.Article, error) {
+	ctx, span := r.start(ctx, "ListStarredArticles", userIDKey.String(userID))
+	articles, err := r.Repository.ListStarredArticles(ctx, userID)
+	end(span, err)
+	return articles, err
+}
+
+func (r *Repository) ListUnsummarizedArticles(ctx context.Context, afterID string, limit int) ([]api.Article, error) {
+	ctx, span := r.start(ctx, "ListUnsummarizedArticles")
+	articles, err := r.Repository.ListUnsummarizedArticles(ctx, afterID, limit)
+	end(span, err)
+	return articles, err
+}
+
+func (r *Repository) SetArticleSummary(ctx context.Context, feedID string, articleID string, summary string) error {
+	ctx, span := r.start(ctx, "SetArticleSummary", feedIDKey.String(feedID), articleIDKey.String(articleID))
+	err := r.Repository.SetArticleSummary(ctx, feedID, articleID, summary)
+	end(span, err)
+	return err
+}
+
+func (r *Repository) ExportUsers(ctx context.Context, visit func(api.User) error) error {
+	ctx, span := r.start(ctx, "ExportUsers")
+	err := r.Repository.ExportUsers(ctx, visit)
+	end(span, err)
+	return err
+}
+
+func (r *Repository) ExportFeeds(ctx context.Context, visit func(db.FeedRecord) error) error {
+	ctx, span := r.start(ctx, "ExportFeeds")
+	err := r.Repository.ExportFeeds(ctx, visit)
+	end(span, err)
+	return err
+}
+
+func (r *Repository) ExportArticles(ctx context.Context, visit func(db.ArticleRecord) error) error {
+	ctx, span := r.start(ctx, "ExportArticles")
+	err := r.Repository.ExportArticles(ctx, visit)
+	end(span, err)
+	return err
+}
+
+func (r *Repository) ExportFilterRules(ctx context.Context, visit func(db.FilterRuleRecord) error) error {
+	ctx, span := r.start(ctx, "ExportFilterRules")
+	err := r.Repository.ExportFilterRules(ctx, visit)
+	end(span, err)
+	return err
+}
+
+func (r *Repository) RestoreUser(ctx context.Context, user api.User, replace bool) error {
+	ctx, span := r.start(ctx, "RestoreUser")
+	err := r.Repository.RestoreUser(ctx, user, replace)
+	end(span, err)
+	return err
+}
+
+func (r *Repository) RestoreFeed(ctx context.Context, record db.FeedRecord, replace bool) error {
+	ctx, span := r.start(ctx, "RestoreFeed")
+	err := r.Repository.RestoreFeed(ctx, record, replace)
+	end(span, err)
+	return err
+}
+
+func (r *Repository) RestoreArticle(ctx context.Context, record db.ArticleRecord, replace bool) error {
+	ctx, span := r.start(ctx, "RestoreArticle")
+	err := r.Repository.RestoreArticle(ctx, record, replace)
+	end(span, err)
+	return err
+}
+
+func (r *Repository) RestoreFilterRule(ctx context.Context, record db.FilterRuleRecord, replace bool) error {
+	ctx, span := r.start(ctx, "RestoreFilterRule")
+	err := r.Repository.RestoreFilterRule(ctx, record, replace)
+	end(span, err)
+	return err
+}
+
+func (r *Repository) AppendAuditRecord(ctx context.Context, record api.AuditRecord) error {
+	ctx, span := r.start(ctx, "AppendAuditRecord")
+	err := r.Repository.AppendAuditRecord(ctx, record)
+	end(span, err)
+	return err
+}
+
+func (r *Repository) ListAuditRecords(ctx context.Context, filter db.AuditFilter) ([]api.AuditRecord, error) {
+	ctx, span := r.start(ctx, "ListAuditRecords")
+	auditRecords, err := r.Repository.ListAuditRecords(ctx, filter)
+	end(span, err)
+	return auditRecords, err
+}
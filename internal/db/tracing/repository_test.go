@@ -0,0 +1,51 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+	"github.com/if-ivan-else/tldrfeed/internal/db/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRepository(t *testing.T) {
+	require := require.New(t)
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	r := NewRepository(mock.NewRepository(), provider, "mock")
+	defer r.Close()
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "request")
+	u, err := r.CreateUser(ctx, "reader")
+	require.NoError(err)
+	_, err = r.GetFeed(ctx, "missing")
+	require.Equal(db.ErrNoSuchFeed, err)
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(spans, 3)
+	created, failed := spans[0], spans[1]
+
+	// Calls are children of the span of their context
+	require.Equal("db.CreateUser", created.Name())
+	require.Equal(parent.SpanContext().SpanID(), created.Parent().SpanID())
+	require.Equal(parent.SpanContext().TraceID(), created.SpanContext().TraceID())
+	require.Equal(codes.Unset, created.Status().Code)
+	require.NotEmpty(u.ID)
+
+	// Failed calls record their error along with the IDs they were given
+	require.Equal("db.GetFeed", failed.Name())
+	require.Equal(codes.Error, failed.Status().Code)
+	require.Equal(db.ErrNoSuchFeed.Error(), failed.Status().Description)
+	attrs := map[string]string{}
+	for _, kv := range failed.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	require.Equal("missing", attrs["tldrfeed.feed_id"])
+	require.Equal("mock", attrs["db.system"])
+	require.Equal("GetFeed", attrs["db.operation"])
+}
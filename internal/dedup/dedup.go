@@ -0,0 +1,208 @@
+// Package dedup detects near-duplicate Articles published in different Feeds, e.g. the same story picked up by
+// several aggregators
+package dedup
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/markup"
+)
+
+const (
+	// Threshold is the largest Hamming distance between the SimHashes of near-duplicate Articles, news stories are
+	// short so a few changed words move their SimHashes more than those of web pages
+	Threshold = 6
+	// Window is how far back duplicates of a new Article are looked for
+	Window = 7 * 24 * time.Hour
+
+	// minWords is the number of words below which texts are too short for SimHashes to tell stories apart
+	minWords = 8
+	// shingleSize is the number of consecutive words hashed together
+	shingleSize = 3
+	// bands is the number of SimHash bands, more than Threshold so that near-duplicates share at least one band
+	// (the bits left over past the last band do not matter)
+	bands = Threshold + 1
+)
+
+// Fingerprint identifies the story of an Article
+type Fingerprint struct {
+	// URL is the normalized URL of the original of the Article, empty when the Article has no URL
+	URL string
+	// SimHash of the Article's title and body, zero when the Article is too short to be compared by its text
+	SimHash uint64
+}
+
+// Compute returns the Fingerprint of an Article
+func Compute(a *api.Article) Fingerprint {
+	return Fingerprint{
+		URL:     NormalizeURL(a.URL),
+		SimHash: SimHash(a.Title + "\n" + markup.Text(a.Body, a.BodyFormat)),
+	}
+}
+
+// Matches returns true when two Fingerprints belong to the same story
+func (f Fingerprint) Matches(other Fingerprint) bool {
+	if f.URL != "" && f.URL == other.URL {
+		return true
+	}
+	return f.SimHash != 0 && other.SimHash != 0 && Distance(f.SimHash, other.SimHash) <= Threshold
+}
+
+// Bands splits the SimHash into keys that near-duplicates share at least one of, to look up candidates with
+// exact matches. There are no bands for Fingerprints without a SimHash.
+func (f Fingerprint) Bands() []string {
+	if f.SimHash == 0 {
+		return nil
+	}
+	keys := make([]string, bands)
+	width := uint(64 / bands)
+	for i := range keys {
+		band := (f.SimHash >> (uint(i) * width)) & (1<<width - 1)
+		keys[i] = fmt.Sprintf("%d:%x", i, band)
+	}
+	return keys
+}
+
+// Distance returns the Hamming distance of two SimHashes
+func Distance(a uint64, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// SimHash computes the SimHash of a text over shingles of its words, returning zero for texts that are too short
+func SimHash(text string) uint64 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) < minWords {
+		return 0
+	}
+
+	var weights [64]int
+	for i := 0; i+shingleSize <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:i+shingleSize], " ")))
+		sum := h.Sum64()
+		for bit := uint(0); bit < 64; bit++ {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var simhash uint64
+	for bit, w := range weights {
+		if w > 0 {
+			simhash |= 1 << uint(bit)
+		}
+	}
+	if simhash == 0 {
+		// Zero means no SimHash
+		simhash = 1
+	}
+	return simhash
+}
+
+// NormalizeURL reduces the URLs of the same page to one form: the scheme, host and "www." prefix, default ports,
+// trailing slashes, fragments and tracking parameters do not matter. Invalid URLs normalize to the empty string.
+func NormalizeURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return ""
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+
+	query := u.Query()
+	for key := range query {
+		if isTrackingParam(key) {
+			query.Del(key)
+		}
+	}
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	params := []string{}
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, v := range values {
+			params = append(params, url.QueryEscape(key)+"="+url.QueryEscape(v))
+		}
+	}
+
+	normalized := host + strings.TrimRight(u.EscapedPath(), "/")
+	if len(params) > 0 {
+		normalized += "?" + strings.Join(params, "&")
+	}
+	return normalized
+}
+
+// isTrackingParam returns true for query parameters added by analytics and ad tools
+func isTrackingParam(key string) bool {
+	key = strings.ToLower(key)
+	switch key {
+	case "fbclid", "gclid", "dclid", "msclkid", "mc_cid", "mc_eid", "igshid", "ref", "ref_src":
+		return true
+	}
+	return strings.HasPrefix(key, "utm_")
+}
+
+// Collapse keeps one Article per cluster of duplicates, in the position of the cluster's first Article, attaching
+// the other Articles of the cluster as its duplicates. The earliest published Article represents its cluster.
+func Collapse(articles []api.Article) []api.Article {
+	clusters := map[string][]int{}
+	order := []string{}
+	for i, a := range articles {
+		cluster := a.ClusterID
+		if cluster == "" {
+			cluster = a.ID
+		}
+		if _, ok := clusters[cluster]; !ok {
+			order = append(order, cluster)
+		}
+		clusters[cluster] = append(clusters[cluster], i)
+	}
+
+	collapsed := make([]api.Article, 0, len(order))
+	for _, cluster := range order {
+		members := clusters[cluster]
+		representative := members[0]
+		for _, m := range members[1:] {
+			if articles[m].PublishedTime.Before(articles[representative].PublishedTime) {
+				representative = m
+			}
+		}
+
+		a := articles[representative]
+		for _, m := range members {
+			if m == representative {
+				continue
+			}
+			d := articles[m]
+			a.Duplicates = append(a.Duplicates, api.ArticleSource{
+				ArticleID:     d.ID,
+				FeedID:        d.FeedID,
+				Title:         d.Title,
+				URL:           d.URL,
+				PublishedTime: d.PublishedTime,
+			})
+		}
+		collapsed = append(collapsed, a)
+	}
+	return collapsed
+}
@@ -0,0 +1,82 @@
+package dedup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/stretchr/testify/require"
+)
+
+const story = `The city council approved the new budget on Monday after a six hour debate. The budget raises
+spending on public transport and road repairs, while critics say it ignores housing.`
+
+func TestNormalizeURL(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal("example.com/news/budget?id=7&page=2",
+		NormalizeURL("https://www.Example.com:443/news/budget/?page=2&utm_source=feed&id=7#comments"))
+	require.Equal(NormalizeURL("http://example.com/news/budget?id=7&page=2&fbclid=abc"),
+		NormalizeURL("https://example.com/news/budget/?page=2&id=7"))
+	require.NotEqual(NormalizeURL("https://example.com/news/budget"), NormalizeURL("https://example.com/news/weather"))
+	require.Equal("example.com:8080", NormalizeURL("http://example.com:8080/"))
+	require.Empty(NormalizeURL(""))
+	require.Empty(NormalizeURL("not a url"))
+}
+
+func TestSimHash(t *testing.T) {
+	require := require.New(t)
+
+	original := SimHash("Council approves budget\n" + story)
+	// Aggregators reword titles and trim bodies a little
+	reworded := SimHash("Council approves new budget\n" + story + " Read more.")
+	unrelated := SimHash(`Local weather stays sunny for the rest of the week as a high pressure system settles over the
+region, forecasters said, with temperatures climbing well above the seasonal average by Sunday.`)
+
+	require.True(Distance(original, reworded) <= Threshold, "distance %d", Distance(original, reworded))
+	require.True(Distance(original, unrelated) > Threshold, "distance %d", Distance(original, unrelated))
+	require.Zero(SimHash("Too short to compare"))
+}
+
+func TestFingerprintMatches(t *testing.T) {
+	require := require.New(t)
+
+	a := Compute(&api.Article{Title: "Council approves budget", Body: story, URL: "https://example.com/budget"})
+	b := Compute(&api.Article{Title: "Budget passes", Body: "Short", URL: "http://www.example.com/budget/"})
+	c := Compute(&api.Article{Title: "Council approves budget", Body: story})
+	d := Compute(&api.Article{Title: "Short", Body: "Short"})
+
+	require.True(a.Matches(b))
+	require.True(a.Matches(c))
+	require.False(b.Matches(c))
+	require.False(d.Matches(d))
+
+	require.Len(a.Bands(), Threshold+1)
+	require.Empty(d.Bands())
+	// Near-duplicates share a band
+	shared := false
+	for _, x := range a.Bands() {
+		for _, y := range c.Bands() {
+			shared = shared || x == y
+		}
+	}
+	require.True(shared)
+}
+
+func TestCollapse(t *testing.T) {
+	require := require.New(t)
+
+	now := time.Now()
+	collapsed := Collapse([]api.Article{
+		{ID: "late", FeedID: "aggregator", ClusterID: "original", Title: "Copy", PublishedTime: now},
+		{ID: "other", FeedID: "news", Title: "Other", PublishedTime: now.Add(-time.Minute)},
+		{ID: "original", FeedID: "news", ClusterID: "original", Title: "Story", PublishedTime: now.Add(-time.Hour)},
+	})
+
+	require.Len(collapsed, 2)
+	require.Equal("original", collapsed[0].ID)
+	require.Equal([]api.ArticleSource{{ArticleID: "late", FeedID: "aggregator", Title: "Copy", PublishedTime: now}},
+		collapsed[0].Duplicates)
+	require.Equal("other", collapsed[1].ID)
+	require.Empty(collapsed[1].Duplicates)
+}
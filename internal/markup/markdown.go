@@ -0,0 +1,238 @@
+package markup
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	headingPattern   = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	rulePattern      = regexp.MustCompile(`^ {0,3}([-*_])(\s*([-*_])){2,}\s*$`)
+	bulletPattern    = regexp.MustCompile(`^ {0,3}[-*+]\s+`)
+	numberedPattern  = regexp.MustCompile(`^ {0,3}\d{1,9}[.)]\s+`)
+	quotePattern     = regexp.MustCompile(`^ {0,3}>\s?`)
+	fencePattern     = regexp.MustCompile("^ {0,3}(```|~~~)")
+	linkTailPattern  = regexp.MustCompile(`^\(\s*<?([^\s()<>]*)>?(?:\s+"([^"]*)")?\s*\)`)
+	autolinkPattern  = regexp.MustCompile(`^<((?:https?://|mailto:)[^\s<>]+)>`)
+	punctuationChars = "\\`*_{}[]()#+-.!<>\"'|~"
+)
+
+// Markdown renders a subset of Markdown to HTML: paragraphs, headings, emphasis, code spans and blocks, links,
+// images, lists, block quotes and rules. Raw HTML is escaped rather than passed through. The output is sanitized.
+func Markdown(src string) string {
+	lines := strings.Split(strings.Replace(src, "\r\n", "\n", -1), "\n")
+	return Sanitize(renderBlocks(lines))
+}
+
+// renderBlocks renders block level Markdown
+func renderBlocks(lines []string) string {
+	var b strings.Builder
+	paragraph := []string{}
+	flush := func() {
+		if len(paragraph) > 0 {
+			b.WriteString("<p>" + renderInline(strings.Join(paragraph, "\n")) + "</p>\n")
+			paragraph = paragraph[:0]
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			flush()
+
+		case fencePattern.MatchString(line):
+			flush()
+			fence := fencePattern.FindStringSubmatch(line)[1]
+			code := []string{}
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence); i++ {
+				code = append(code, lines[i])
+			}
+			b.WriteString("<pre><code>" + html.EscapeString(strings.Join(code, "\n")) + "</code></pre>\n")
+
+		case len(paragraph) == 0 && (strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t")):
+			code := []string{}
+			for ; i < len(lines) && (strings.HasPrefix(lines[i], "    ") || strings.HasPrefix(lines[i], "\t") ||
+				strings.TrimSpace(lines[i]) == ""); i++ {
+				code = append(code, strings.TrimPrefix(strings.TrimPrefix(lines[i], "\t"), "    "))
+			}
+			i--
+			b.WriteString("<pre><code>" + html.EscapeString(strings.TrimRight(strings.Join(code, "\n"), "\n")) +
+				"</code></pre>\n")
+
+		case headingPattern.MatchString(line):
+			flush()
+			m := headingPattern.FindStringSubmatch(line)
+			b.WriteString(fmt.Sprintf("<h%d>%s</h%d>\n", len(m[1]), renderInline(m[2]), len(m[1])))
+
+		case rulePattern.MatchString(line):
+			flush()
+			b.WriteString("<hr>\n")
+
+		case quotePattern.MatchString(line):
+			flush()
+			quoted := []string{}
+			for ; i < len(lines) && quotePattern.MatchString(lines[i]); i++ {
+				quoted = append(quoted, quotePattern.ReplaceAllString(lines[i], ""))
+			}
+			i--
+			b.WriteString("<blockquote>\n" + renderBlocks(quoted) + "</blockquote>\n")
+
+		case bulletPattern.MatchString(line) || numberedPattern.MatchString(line):
+			flush()
+			marker, tag := bulletPattern, "ul"
+			if !bulletPattern.MatchString(line) {
+				marker, tag = numberedPattern, "ol"
+			}
+			items := []string{}
+			for ; i < len(lines); i++ {
+				if marker.MatchString(lines[i]) {
+					items = append(items, marker.ReplaceAllString(lines[i], ""))
+				} else if strings.TrimSpace(lines[i]) != "" && strings.HasPrefix(lines[i], " ") {
+					// Indented lines continue the last item
+					items[len(items)-1] += "\n" + strings.TrimSpace(lines[i])
+				} else {
+					break
+				}
+			}
+			i--
+			b.WriteString("<" + tag + ">\n")
+			for _, item := range items {
+				b.WriteString("<li>" + renderInline(item) + "</li>\n")
+			}
+			b.WriteString("</" + tag + ">\n")
+
+		default:
+			// Trailing spaces are kept for line breaks
+			paragraph = append(paragraph, strings.TrimLeft(line, " \t"))
+		}
+	}
+	flush()
+	return b.String()
+}
+
+// renderInline renders inline Markdown, escaping everything else
+func renderInline(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte(punctuationChars, s[i+1]) >= 0:
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '`':
+			run := runLength(s[i:], '`')
+			delimiter := s[i : i+run]
+			if end := strings.Index(s[i+run:], delimiter); end >= 0 {
+				code := strings.TrimSpace(s[i+run : i+run+end])
+				b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+				i += run + end + run
+				continue
+			}
+			b.WriteString(delimiter)
+			i += run
+			continue
+
+		case c == '!' && strings.HasPrefix(s[i+1:], "["):
+			if text, dest, title, n, ok := parseLink(s[i+1:]); ok {
+				b.WriteString(`<img src="` + html.EscapeString(dest) + `" alt="` + html.EscapeString(text) + `"`)
+				if title != "" {
+					b.WriteString(` title="` + html.EscapeString(title) + `"`)
+				}
+				b.WriteString(">")
+				i += 1 + n
+				continue
+			}
+
+		case c == '[':
+			if text, dest, title, n, ok := parseLink(s[i:]); ok {
+				b.WriteString(`<a href="` + html.EscapeString(dest) + `"`)
+				if title != "" {
+					b.WriteString(` title="` + html.EscapeString(title) + `"`)
+				}
+				b.WriteString(">" + renderInline(text) + "</a>")
+				i += n
+				continue
+			}
+
+		case c == '<':
+			if m := autolinkPattern.FindStringSubmatch(s[i:]); m != nil {
+				link := html.EscapeString(m[1])
+				b.WriteString(`<a href="` + link + `">` + link + "</a>")
+				i += len(m[0])
+				continue
+			}
+
+		case c == '*' || c == '_':
+			// Underscores inside words (snake_case) are not emphasis
+			if c == '_' && i > 0 && isWordChar(s[i-1]) {
+				break
+			}
+			run := runLength(s[i:], c)
+			if run > 2 {
+				run = 2
+			}
+			delimiter := s[i : i+run]
+			rest := s[i+run:]
+			if end := strings.Index(rest, delimiter); end > 0 && rest[0] != ' ' && rest[end-1] != ' ' {
+				tag := "em"
+				if run == 2 {
+					tag = "strong"
+				}
+				b.WriteString("<" + tag + ">" + renderInline(rest[:end]) + "</" + tag + ">")
+				i += run + end + run
+				continue
+			}
+
+		case c == '\n':
+			// Two trailing spaces break the line
+			if strings.HasSuffix(b.String(), "  ") {
+				trimmed := strings.TrimRight(b.String(), " ")
+				b.Reset()
+				b.WriteString(trimmed + "<br>")
+			}
+		}
+		b.WriteString(html.EscapeString(s[i : i+1]))
+		i++
+	}
+	return b.String()
+}
+
+// parseLink parses a link "[text](destination "title")", returning its length
+func parseLink(s string) (text string, dest string, title string, n int, ok bool) {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				m := linkTailPattern.FindStringSubmatch(s[i+1:])
+				if m == nil {
+					return "", "", "", 0, false
+				}
+				return s[1:i], m[1], m[2], i + 1 + len(m[0]), true
+			}
+		}
+	}
+	return "", "", "", 0, false
+}
+
+func runLength(s string, c byte) int {
+	n := 0
+	for n < len(s) && s[n] == c {
+		n++
+	}
+	return n
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,68 @@
+package markup
+
+import (
+	"testing"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitize(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal(`<p>Hello <b>world</b></p>`, Sanitize(`<p onclick="steal()">Hello <b>world</b></p>`))
+	require.Equal(`<p>Hi</p>`, Sanitize(`<p>Hi<script>alert("x")</script></p>`))
+	require.Equal(`Hi`, Sanitize(`Hi<style>p { color: red }</style><!-- note -->`))
+	require.Equal(`<a href="https://example.com/?a=1&amp;b=2" rel="nofollow noopener noreferrer">link</a>`,
+		Sanitize(`<a href="https://example.com/?a=1&b=2" target="_blank">link</a>`))
+	require.Equal(`<a>link</a>`, Sanitize(`<a href="javascript:alert(1)">link</a>`))
+	require.Equal(`<a>link</a>`, Sanitize(`<a href=" JaVaScRiPt:alert(1)">link</a>`))
+	require.Equal(`<img alt="cat">`, Sanitize(`<img src="data:image/png;base64,AAAA" alt="cat" onerror="x()">`))
+	require.Equal(`<img src="https://example.com/cat.png">`, Sanitize(`<IMG SRC="https://example.com/cat.png"/>`))
+	require.Equal(`caption`, Sanitize(`<figure><figcaption>caption</figcaption></figure>`))
+
+	// Broken markup is balanced and whatever is not understood is escaped
+	require.Equal(`<ul><li>one<li>two</li></li></ul>`, Sanitize(`<ul><li>one<li>two</ul>`))
+	require.Equal(`<em>open</em>`, Sanitize(`</strong><em>open`))
+	require.Equal(`a &lt; b &amp;&amp; c &gt; d`, Sanitize(`a < b && c > d`))
+	require.Equal(`&lt;<p>x</p>`, Sanitize(`<<p>x`))
+}
+
+func TestMarkdown(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal("<h1>Title</h1>\n<p>Some <strong>bold</strong> and <em>italic</em> text with <code>a &lt; b</code>.</p>\n",
+		Markdown("# Title\n\nSome **bold** and *italic* text with `a < b`."))
+	require.Equal(`<p><a href="https://example.com" title="Example" rel="nofollow noopener noreferrer">a <em>link</em></a></p>`+"\n",
+		Markdown(`[a _link_](https://example.com "Example")`))
+	require.Equal(`<p><img src="https://example.com/cat.png" alt="A cat"></p>`+"\n",
+		Markdown(`![A cat](https://example.com/cat.png)`))
+	require.Equal("<ul>\n<li>one</li>\n<li>two\ncontinued</li>\n</ul>\n<ol>\n<li>first</li>\n</ol>\n",
+		Markdown("- one\n- two\n  continued\n\n1. first"))
+	require.Equal("<blockquote>\n<p>quoted</p>\n</blockquote>\n<hr>\n<pre><code>x := &lt;-ch\n</code></pre>\n",
+		Markdown("> quoted\n\n---\n\n```go\nx := <-ch\n\n```"))
+	require.Equal("<p>line<br>\nbreak snake_case_name *not emphasis*</p>\n",
+		Markdown("line  \nbreak snake_case_name \\*not emphasis\\*"))
+
+	// Raw HTML and unsafe links do not get through
+	require.Equal("<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n", Markdown("<script>alert(1)</script>"))
+	require.Equal("<p><a>click</a></p>\n", Markdown("[click](javascript:steal)"))
+}
+
+func TestConvert(t *testing.T) {
+	require := require.New(t)
+
+	html := `<h1>Title</h1><p>First &amp; <b>second</b></p><ul><li>one</li><li>two</li></ul>`
+	require.Equal("Title\n\nFirst & second\n\none\ntwo", PlainText(html))
+	require.Equal("Title\n\nSome bold text.", Text("# Title\n\nSome **bold** text.", api.BodyMarkdown))
+	require.Equal("plain <b>text</b>", Text("plain <b>text</b>", ""))
+
+	require.Equal("<p>One &lt;b&gt;<br>two</p><p>three</p>", TextToHTML("One <b>\ntwo\n\n\nthree\n"))
+	require.Equal("<p>One &lt;b&gt;<br>two</p><p>three</p>", HTML("One <b>\ntwo\n\n\nthree\n", api.BodyText))
+	require.Equal("<p>safe</p>", HTML(`<p>safe<script>x</script></p>`, api.BodyHTML))
+	require.Equal("<p><em>md</em></p>\n", HTML("*md*", api.BodyMarkdown))
+
+	require.NoError(ValidFormat(""))
+	require.NoError(ValidFormat(api.BodyMarkdown))
+	require.Error(ValidFormat("rtf"))
+}
@@ -0,0 +1,181 @@
+package markup
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// allowedTags maps the elements kept by Sanitize to the attributes they keep
+var allowedTags = map[string][]string{
+	"a": {"href", "title"}, "img": {"src", "alt", "title"},
+	"p": nil, "br": nil, "hr": nil, "div": nil, "span": nil,
+	"h1": nil, "h2": nil, "h3": nil, "h4": nil, "h5": nil, "h6": nil,
+	"strong": nil, "b": nil, "em": nil, "i": nil, "u": nil, "s": nil, "del": nil, "sup": nil, "sub": nil,
+	"blockquote": nil, "code": nil, "pre": nil, "ul": nil, "ol": nil, "li": nil,
+	"table": nil, "thead": nil, "tbody": nil, "tr": nil, "th": nil, "td": nil,
+}
+
+// voidTags are elements without content or closing tags
+var voidTags = map[string]bool{"br": true, "hr": true, "img": true}
+
+// droppedTags are elements removed along with their content, other disallowed elements only lose their tags
+var droppedTags = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true, "noscript": true,
+	"textarea": true, "template": true, "svg": true, "math": true, "head": true, "title": true,
+}
+
+// urlSchemes are the URL schemes allowed in attributes holding URLs
+var urlSchemes = map[string][]string{
+	"href": {"http", "https", "mailto"},
+	"src":  {"http", "https"},
+}
+
+var (
+	tagPattern  = regexp.MustCompile(`^<(/?)([a-zA-Z][a-zA-Z0-9]*)((?:\s+[^\s"'>/=]+(?:\s*=\s*(?:"[^"]*"|'[^']*'|[^\s"'=<>` + "`" + `]+))?)*)\s*(/?)>`)
+	attrPattern = regexp.MustCompile(`([^\s"'>/=]+)(?:\s*=\s*("[^"]*"|'[^']*'|[^\s"'=<>` + "`" + `]+))?`)
+)
+
+// Sanitize cleans up untrusted HTML with a strict allowlist: elements and attributes not on the list are removed,
+// URLs must be absolute http(s) (or mailto for links) and all text is escaped anew. The output is rebuilt from the
+// parsed input rather than filtered, so anything not understood ends up escaped as text.
+func Sanitize(input string) string {
+	var b strings.Builder
+	open := []string{}
+
+	text := func(t string) {
+		b.WriteString(html.EscapeString(html.UnescapeString(t)))
+	}
+
+	for i := 0; i < len(input); {
+		j := strings.IndexByte(input[i:], '<')
+		if j < 0 {
+			text(input[i:])
+			break
+		}
+		text(input[i : i+j])
+		i += j
+		rest := input[i:]
+
+		// Comments, doctypes, CDATA sections and processing instructions are dropped
+		if strings.HasPrefix(rest, "<!--") {
+			end := strings.Index(rest, "-->")
+			if end < 0 {
+				break
+			}
+			i += end + len("-->")
+			continue
+		}
+		if strings.HasPrefix(rest, "<!") || strings.HasPrefix(rest, "<?") {
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				break
+			}
+			i += end + 1
+			continue
+		}
+
+		m := tagPattern.FindStringSubmatch(rest)
+		if m == nil {
+			text("<")
+			i++
+			continue
+		}
+		i += len(m[0])
+		closing, name, attrs, selfClosing := m[1] == "/", strings.ToLower(m[2]), m[3], m[4] == "/"
+
+		if droppedTags[name] {
+			if !closing && !selfClosing {
+				end := strings.Index(strings.ToLower(input[i:]), "</"+name)
+				if end < 0 {
+					break
+				}
+				i += end
+			}
+			continue
+		}
+		if _, ok := allowedTags[name]; !ok {
+			continue
+		}
+
+		if closing {
+			for k := len(open) - 1; k >= 0; k-- {
+				if open[k] == name {
+					for _, o := range reverse(open[k:]) {
+						b.WriteString("</" + o + ">")
+					}
+					open = open[:k]
+					break
+				}
+			}
+			continue
+		}
+
+		b.WriteString("<" + name)
+		writeAttributes(&b, name, attrs)
+		b.WriteString(">")
+		if voidTags[name] {
+			continue
+		}
+		if selfClosing {
+			b.WriteString("</" + name + ">")
+			continue
+		}
+		open = append(open, name)
+	}
+
+	for _, o := range reverse(open) {
+		b.WriteString("</" + o + ">")
+	}
+	return b.String()
+}
+
+// writeAttributes writes the allowed attributes of an element, links open without passing on referrers
+func writeAttributes(b *strings.Builder, tag string, attrs string) {
+	seen := map[string]bool{}
+	for _, m := range attrPattern.FindAllStringSubmatch(attrs, -1) {
+		name, value := strings.ToLower(m[1]), m[2]
+		if seen[name] || !contains(allowedTags[tag], name) {
+			continue
+		}
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+			value = value[1 : len(value)-1]
+		}
+		value = strings.TrimSpace(html.UnescapeString(value))
+		if schemes, ok := urlSchemes[name]; ok && !allowedURL(value, schemes) {
+			continue
+		}
+		seen[name] = true
+		b.WriteString(" " + name + `="` + html.EscapeString(value) + `"`)
+	}
+	if tag == "a" && seen["href"] {
+		b.WriteString(` rel="nofollow noopener noreferrer"`)
+	}
+}
+
+// allowedURL returns true for absolute URLs with one of the given schemes
+func allowedURL(value string, schemes []string) bool {
+	u, err := url.Parse(value)
+	if err != nil {
+		return false
+	}
+	return contains(schemes, strings.ToLower(u.Scheme)) && (u.Host != "" || u.Scheme == "mailto")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func reverse(values []string) []string {
+	reversed := make([]string, len(values))
+	for i, v := range values {
+		reversed[len(values)-1-i] = v
+	}
+	return reversed
+}
@@ -0,0 +1,86 @@
+// Package markup sanitizes HTML Article bodies and converts bodies between the plain text, Markdown and HTML
+// formats
+package markup
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+)
+
+// Formats lists all Article body formats
+var Formats = []string{api.BodyText, api.BodyMarkdown, api.BodyHTML}
+
+var (
+	// lineBreakPattern and paragraphBreakPattern match the tags of the canonical HTML produced by Sanitize that
+	// end a line or a paragraph of text
+	lineBreakPattern      = regexp.MustCompile(`<br>|</(?:div|li|tr)>|<(?:ul|ol|pre|blockquote|table)>`)
+	paragraphBreakPattern = regexp.MustCompile(`<hr>|</(?:p|h[1-6]|blockquote|pre|ul|ol|table)>`)
+	anyTagPattern         = regexp.MustCompile(`<[^>]*>`)
+	blankLinesPattern     = regexp.MustCompile(`\n{3,}`)
+	paragraphPattern      = regexp.MustCompile(`\n\s*\n`)
+)
+
+// ValidFormat returns an error for unknown body formats, the empty format stands for plain text
+func ValidFormat(format string) error {
+	if format == "" || contains(Formats, format) {
+		return nil
+	}
+	return fmt.Errorf("Unknown body format '%s', expected one of %s", format, strings.Join(Formats, ", "))
+}
+
+// Text converts a body of the given format to plain text
+func Text(body string, format string) string {
+	switch format {
+	case api.BodyHTML:
+		return PlainText(body)
+	case api.BodyMarkdown:
+		return PlainText(Markdown(body))
+	}
+	return body
+}
+
+// HTML converts a body of the given format to sanitized HTML
+func HTML(body string, format string) string {
+	switch format {
+	case api.BodyHTML:
+		return Sanitize(body)
+	case api.BodyMarkdown:
+		return Markdown(body)
+	}
+	return TextToHTML(body)
+}
+
+// PlainText strips the tags of sanitized HTML, turning block elements into line and paragraph breaks
+func PlainText(sanitized string) string {
+	text := paragraphBreakPattern.ReplaceAllString(sanitized, "\n\n")
+	text = lineBreakPattern.ReplaceAllString(text, "\n")
+	text = html.UnescapeString(anyTagPattern.ReplaceAllString(text, ""))
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	text = blankLinesPattern.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return strings.TrimSpace(text)
+}
+
+// TextToHTML escapes plain text into HTML paragraphs, single line breaks are kept as such
+func TextToHTML(text string) string {
+	text = strings.TrimSpace(strings.Replace(text, "\r\n", "\n", -1))
+	if text == "" {
+		return ""
+	}
+	var b strings.Builder
+	for _, paragraph := range paragraphPattern.Split(text, -1) {
+		lines := strings.Split(strings.TrimSpace(paragraph), "\n")
+		for i, line := range lines {
+			lines[i] = html.EscapeString(strings.TrimSpace(line))
+		}
+		b.WriteString("<p>" + strings.Join(lines, "<br>") + "</p>")
+	}
+	return b.String()
+}
@@ -0,0 +1,98 @@
+// Package openapi describes HTTP APIs with OpenAPI 3 documents, deriving schemas from Go types
+package openapi
+
+// Version is the version of the OpenAPI specification documents follow
+const Version = "3.0.3"
+
+// Document is the root of an OpenAPI document
+type Document struct {
+	OpenAPI string   `json:"openapi"`
+	Info    Info     `json:"info"`
+	Servers []Server `json:"servers,omitempty"`
+	// Paths maps path templates, relative to the server URL, to their operations
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// Server is a base URL the paths of a Document are relative to
+type Server struct {
+	URL string `json:"url"`
+}
+
+// PathItem maps lower-case HTTP methods to the operations of a path
+type PathItem map[string]*Operation
+
+// Operation describes a single route
+type Operation struct {
+	OperationID string       `json:"operationId"`
+	Summary     string       `json:"summary,omitempty"`
+	Tags        []string     `json:"tags,omitempty"`
+	Parameters  []Parameter  `json:"parameters,omitempty"`
+	RequestBody *RequestBody `json:"requestBody,omitempty"`
+	// Responses maps status codes, or "default" for all other statuses, to responses
+	Responses map[string]Response `json:"responses"`
+}
+
+// Parameter locations
+const (
+	InPath   = "path"
+	InQuery  = "query"
+	InHeader = "header"
+)
+
+// Parameter describes a path, query or header parameter of an operation
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes the body of requests, by content type
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response describes a response, by content type
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType describes a request or response body of a content type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components holds the schemas referenced from the rest of a Document, by name
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// Schema describes a value, either inline or as a reference to a component schema
+type Schema struct {
+	Ref         string             `json:"$ref,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	Default     interface{}        `json:"default,omitempty"`
+	Minimum     *int               `json:"minimum,omitempty"`
+	Maximum     *int               `json:"maximum,omitempty"`
+	MinLength   int                `json:"minLength,omitempty"`
+	Pattern     string             `json:"pattern,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	// AdditionalProperties describes the values of maps
+	AdditionalProperties *Schema  `json:"additionalProperties,omitempty"`
+	Required             []string `json:"required,omitempty"`
+}
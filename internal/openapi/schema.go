@@ -0,0 +1,187 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// Patterns of the govalidator string validators
+var validatorPatterns = map[string]string{
+	"alpha":    "^[a-zA-Z]+$",
+	"alphanum": "^[a-zA-Z0-9]+$",
+	"numeric":  "^[0-9]+$",
+}
+
+// Formats of the govalidator string validators
+var validatorFormats = map[string]string{
+	"email": "email",
+	"url":   "uri",
+	"uuid":  "uuid",
+}
+
+// Generator derives Schemas from Go types as encoding/json marshals them. Named struct types are described once
+// as component schemas and referenced everywhere else.
+type Generator struct {
+	schemas map[string]*Schema
+	types   map[string]reflect.Type
+}
+
+// NewGenerator creates a Generator with no component schemas
+func NewGenerator() *Generator {
+	return &Generator{
+		schemas: make(map[string]*Schema),
+		types:   make(map[string]reflect.Type),
+	}
+}
+
+// Schema returns the Schema of the type of v, adding the named struct types it refers to to the components
+func (g *Generator) Schema(v interface{}) *Schema {
+	return g.schema(reflect.TypeOf(v))
+}
+
+// Components returns the component schemas of all the named struct types seen so far
+func (g *Generator) Components() Components {
+	return Components{Schemas: g.schemas}
+}
+
+func (g *Generator) schema(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rawMessageType:
+		// Any JSON value
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.schema(t.Elem())
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16,
+		reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// Byte slices are marshalled as base64 strings
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + g.component(t)}
+	default:
+		// Interfaces may hold any JSON value
+		return &Schema{}
+	}
+}
+
+// component adds the schema of a named struct type to the components unless already there, returning its name
+func (g *Generator) component(t reflect.Type) string {
+	name := t.Name()
+	if seen, ok := g.types[name]; ok && seen != t {
+		// Types of the same name from different packages
+		name = strings.Replace(t.PkgPath(), "/", ".", -1) + "." + name
+	}
+	if _, ok := g.types[name]; ok {
+		return name
+	}
+
+	// Registered before its fields are described so that recursive types refer to themselves
+	g.types[name] = t
+	g.schemas[name] = nil
+	g.schemas[name] = g.structSchema(t)
+	return name
+}
+
+func (g *Generator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.addFields(s, t)
+	return s
+}
+
+// addFields describes the fields of a struct type as properties of s, following the encoding/json rules for
+// names, ignored and embedded fields
+func (g *Generator) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.addFields(s, ft)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			// Unexported
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		property := g.schema(f.Type)
+		if applyValidators(property, f.Tag.Get("valid")) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = property
+	}
+}
+
+// applyValidators constrains a property with the govalidator validators of its valid tag (e.g.
+// "required~Name cannot be blank,alphanum"), returning true when the property is required
+func applyValidators(property *Schema, tag string) bool {
+	if tag == "" {
+		return false
+	}
+
+	required := false
+	for _, validator := range strings.Split(tag, ",") {
+		// Custom error messages follow a tilde
+		name := strings.Split(validator, "~")[0]
+		if name == "required" {
+			required = true
+		}
+		// References cannot carry constraints
+		if property.Ref != "" || property.Type != "string" {
+			continue
+		}
+		switch {
+		case name == "required":
+			property.MinLength = 1
+		case validatorPatterns[name] != "":
+			property.Pattern = validatorPatterns[name]
+		case validatorFormats[name] != "":
+			property.Format = validatorFormats[name]
+		}
+	}
+	return required
+}
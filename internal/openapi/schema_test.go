@@ -0,0 +1,94 @@
+package openapi
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testBase struct {
+	ID string `json:"id"`
+}
+
+type testChild struct {
+	Name string `json:"name"`
+}
+
+type testNode struct {
+	Parent *testNode `json:"parent,omitempty"`
+}
+
+type testRequest struct {
+	testBase
+	Name     string            `json:"name" valid:"required~Name cannot be blank,alphanum~Name should be alphanumeric"`
+	Email    string            `json:"email,omitempty" valid:"email"`
+	Count    int               `json:"count"`
+	Total    int64             `json:"total"`
+	Score    float64           `json:"score"`
+	Enabled  bool              `json:"enabled"`
+	Tags     []string          `json:"tags,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Created  time.Time         `json:"created_at"`
+	Due      *time.Time        `json:"due_at,omitempty"`
+	Payload  json.RawMessage   `json:"payload,omitempty"`
+	Child    *testChild        `json:"child" valid:"required~Child is required"`
+	Children []testChild       `json:"children"`
+	Ignored  string            `json:"-"`
+	Untagged string
+	hidden   string
+}
+
+func TestStructSchema(t *testing.T) {
+	require := require.New(t)
+	g := NewGenerator()
+
+	require.Equal(&Schema{Ref: "#/components/schemas/testRequest"}, g.Schema(testRequest{}))
+	require.Equal(&Schema{Ref: "#/components/schemas/testRequest"}, g.Schema(&testRequest{}))
+
+	schemas := g.Components().Schemas
+	require.Len(schemas, 2)
+	require.Equal(&Schema{Type: "object", Properties: map[string]*Schema{"name": {Type: "string"}}}, schemas["testChild"])
+
+	s := schemas["testRequest"]
+	require.Equal("object", s.Type)
+	require.Equal([]string{"name", "child"}, s.Required)
+	require.Equal(map[string]*Schema{
+		"id":         {Type: "string"},
+		"name":       {Type: "string", MinLength: 1, Pattern: "^[a-zA-Z0-9]+$"},
+		"email":      {Type: "string", Format: "email"},
+		"count":      {Type: "integer"},
+		"total":      {Type: "integer", Format: "int64"},
+		"score":      {Type: "number", Format: "double"},
+		"enabled":    {Type: "boolean"},
+		"tags":       {Type: "array", Items: &Schema{Type: "string"}},
+		"labels":     {Type: "object", AdditionalProperties: &Schema{Type: "string"}},
+		"created_at": {Type: "string", Format: "date-time"},
+		"due_at":     {Type: "string", Format: "date-time"},
+		"payload":    {},
+		"child":      {Ref: "#/components/schemas/testChild"},
+		"children":   {Type: "array", Items: &Schema{Ref: "#/components/schemas/testChild"}},
+		"Untagged":   {Type: "string"},
+	}, s.Properties)
+}
+
+func TestCollectionSchema(t *testing.T) {
+	require := require.New(t)
+	g := NewGenerator()
+
+	require.Equal(&Schema{Type: "array", Items: &Schema{Ref: "#/components/schemas/testChild"}}, g.Schema([]testChild{}))
+	require.Equal(&Schema{Type: "object", AdditionalProperties: &Schema{}}, g.Schema(map[string]interface{}{}))
+	require.Len(g.Components().Schemas, 1)
+}
+
+func TestRecursiveSchema(t *testing.T) {
+	require := require.New(t)
+	g := NewGenerator()
+
+	require.Equal(&Schema{Ref: "#/components/schemas/testNode"}, g.Schema(testNode{}))
+	require.Equal(&Schema{
+		Type:       "object",
+		Properties: map[string]*Schema{"parent": {Ref: "#/components/schemas/testNode"}},
+	}, g.Components().Schemas["testNode"])
+}
@@ -0,0 +1,162 @@
+// Package querycost estimates how expensive GraphQL queries are before they run, so that servers can refuse queries
+// reading too much.
+//
+// The complexity of a field is 1 plus the complexity of its selections, times the number of items it is expected to
+// return when it is a list of objects. Fields taking a "first" argument size the lists they select (e.g. the nodes of a
+// connection), other lists are expected to return a default number of items. Introspection fields are free.
+package querycost
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vektah/gqlparser"
+	"github.com/vektah/gqlparser/ast"
+)
+
+// SizeArgument is the argument of fields sizing the lists they select
+const SizeArgument = "first"
+
+// Cost is the estimated cost of a GraphQL operation
+type Cost struct {
+	// Operation is the type of the operation: query, mutation or subscription
+	Operation string
+	// Complexity estimates the number of fields resolved
+	Complexity int
+	// Depth is the deepest nesting of fields
+	Depth int
+}
+
+// Estimator estimates the cost of the operations of a schema
+type Estimator struct {
+	schema          *ast.Schema
+	defaultListSize int
+}
+
+// NewEstimator creates an Estimator of operations of the schema defined in the GraphQL schema language, expecting
+// lists not sized by an argument to return defaultListSize items
+func NewEstimator(schema string, defaultListSize int) (*Estimator, error) {
+	s, err := gqlparser.LoadSchema(&ast.Source{Name: "schema", Input: schema})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to load schema")
+	}
+	return &Estimator{schema: s, defaultListSize: defaultListSize}, nil
+}
+
+// Estimate estimates the cost of an operation of a query document with the given variables, failing when the
+// document is not valid against the schema
+func (e *Estimator) Estimate(query string, operationName string, variables map[string]interface{}) (*Cost, error) {
+	doc, errs := gqlparser.LoadQuery(e.schema, query)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	var op *ast.OperationDefinition
+	if operationName == "" {
+		if len(doc.Operations) != 1 {
+			return nil, errors.New("An operation name is required for documents with several operations")
+		}
+		op = doc.Operations[0]
+	} else if op = doc.Operations.ForName(operationName); op == nil {
+		return nil, errors.Errorf("No operation named '%s'", operationName)
+	}
+
+	complexity, depth, err := e.selectionCost(op.SelectionSet, unsized, variables)
+	if err != nil {
+		return nil, err
+	}
+	return &Cost{Operation: string(op.Operation), Complexity: complexity, Depth: depth}, nil
+}
+
+// unsized is the size of the lists not sized by an argument
+const unsized = -1
+
+// selectionCost returns the complexity and depth of a selection set, lists selected being sized by size unless
+// unsized
+func (e *Estimator) selectionCost(set ast.SelectionSet, size int, variables map[string]interface{}) (int, int, error) {
+	complexity, depth := 0, 0
+	for _, sel := range set {
+		var c, d int
+		var err error
+		switch sel := sel.(type) {
+		case *ast.Field:
+			c, d, err = e.fieldCost(sel, size, variables)
+		case *ast.InlineFragment:
+			c, d, err = e.selectionCost(sel.SelectionSet, size, variables)
+		case *ast.FragmentSpread:
+			c, d, err = e.selectionCost(sel.Definition.SelectionSet, size, variables)
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		complexity += c
+		if d > depth {
+			depth = d
+		}
+	}
+	return complexity, depth, nil
+}
+
+func (e *Estimator) fieldCost(field *ast.Field, size int, variables map[string]interface{}) (int, int, error) {
+	if strings.HasPrefix(field.Name, "__") {
+		return 0, 0, nil
+	}
+
+	// Lists of scalars are read at once, like scalars
+	multiplier := 1
+	if field.Definition.Type.Elem != nil && len(field.SelectionSet) > 0 {
+		multiplier = e.defaultListSize
+		if size != unsized {
+			multiplier = size
+		}
+	}
+
+	childSize := unsized
+	if field.Definition.Arguments.ForName(SizeArgument) != nil {
+		n, ok, err := intArgument(field, SizeArgument, variables)
+		if err != nil {
+			return 0, 0, err
+		}
+		if ok {
+			childSize = n
+			if n < 0 {
+				childSize = 0
+			}
+		}
+	}
+
+	complexity, depth, err := e.selectionCost(field.SelectionSet, childSize, variables)
+	if err != nil {
+		return 0, 0, err
+	}
+	return multiplier * (1 + complexity), depth + 1, nil
+}
+
+// intArgument returns the value of an Int argument of a field, from its variable or its default when needed. It
+// returns false when the argument has no value.
+func intArgument(field *ast.Field, name string, variables map[string]interface{}) (n int, ok bool, err error) {
+	// Argument values that cannot be converted (e.g. integers overflowing int64) make the parser panic
+	defer func() {
+		if recover() != nil {
+			n, ok, err = 0, false, errors.Errorf("Argument '%s' of field '%s' must be an integer", name, field.Name)
+		}
+	}()
+
+	args := field.ArgumentMap(variables)
+	switch v := args[name].(type) {
+	case nil:
+		return 0, false, nil
+	case int64:
+		return int(v), true, nil
+	case int:
+		return v, true, nil
+	case float64:
+		return int(v), true, nil
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return int(n), true, nil
+		}
+	}
+	return 0, false, errors.Errorf("Argument '%s' of field '%s' must be an integer", name, field.Name)
+}
@@ -0,0 +1,122 @@
+package querycost
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testSchema = `
+type Query {
+  user(id: ID!): User
+  users(first: Int = 10): UserConnection!
+}
+
+type Subscription {
+  userAdded: User!
+}
+
+type User {
+  name: String!
+  tags: [String!]!
+  friends: [User!]!
+  posts(first: Int = 5): PostConnection!
+}
+
+type UserConnection {
+  nodes: [User!]!
+  total: Int!
+}
+
+type PostConnection {
+  nodes: [Post!]!
+  total: Int!
+}
+
+type Post {
+  title: String!
+}
+`
+
+func testEstimator(require *require.Assertions) *Estimator {
+	e, err := NewEstimator(testSchema, 20)
+	require.NoError(err)
+	return e
+}
+
+func TestEstimate(t *testing.T) {
+	require := require.New(t)
+	e := testEstimator(require)
+
+	tests := []struct {
+		query      string
+		variables  map[string]interface{}
+		complexity int
+		depth      int
+	}{
+		{`{ user(id: "1") { name } }`, nil, 2, 2},
+		// Scalar lists cost as much as scalars
+		{`{ user(id: "1") { name tags } }`, nil, 3, 2},
+		// Unsized lists return the default number of items
+		{`{ user(id: "1") { friends { name } } }`, nil, 1 + 20*2, 3},
+		// Sized lists return as many items as the argument, or its default
+		{`{ user(id: "1") { posts(first: 3) { nodes { title } total } } }`, nil, 1 + 1 + 3*2 + 1, 4},
+		{`{ user(id: "1") { posts { nodes { title } } } }`, nil, 1 + 1 + 5*2, 4},
+		{`query($n: Int) { users(first: $n) { nodes { name } } }`, map[string]interface{}{"n": float64(50)}, 1 + 50*2, 3},
+		{`{ users(first: -1) { nodes { name } } }`, nil, 1, 3},
+		{`query($n: Int) { users(first: $n) { nodes { name } } }`, nil, 1 + 10*2, 3},
+		// Nested lists multiply
+		{`{ users(first: 10) { nodes { posts(first: 10) { nodes { title } } } } }`, nil, 1 + 10*(1+1+10*2), 5},
+		// Fragments count where they are spread, introspection is free
+		{`{ user(id: "1") { ...f __typename } } fragment f on User { name friends { name } }`, nil, 1 + 1 + 20*2, 3},
+		{`{ __schema { types { name fields { name } } } }`, nil, 0, 0},
+	}
+	for _, test := range tests {
+		cost, err := e.Estimate(test.query, "", test.variables)
+		require.NoError(err, test.query)
+		require.Equal(test.complexity, cost.Complexity, test.query)
+		require.Equal(test.depth, cost.Depth, test.query)
+	}
+}
+
+func TestEstimateOperations(t *testing.T) {
+	require := require.New(t)
+	e := testEstimator(require)
+
+	query := `query a { user(id: "1") { name } } query b { users { total } }`
+	cost, err := e.Estimate(query, "b", nil)
+	require.NoError(err)
+	require.Equal("query", cost.Operation)
+	require.Equal(2, cost.Complexity)
+
+	cost, err = e.Estimate(`{ users { total } }`, "", nil)
+	require.NoError(err)
+	require.Equal("query", cost.Operation)
+	cost, err = e.Estimate(`subscription { userAdded { name } }`, "", nil)
+	require.NoError(err)
+	require.Equal("subscription", cost.Operation)
+	require.Equal(2, cost.Complexity)
+
+	_, err = e.Estimate(query, "", nil)
+	require.EqualError(err, "An operation name is required for documents with several operations")
+	_, err = e.Estimate(query, "c", nil)
+	require.EqualError(err, "No operation named 'c'")
+}
+
+func TestEstimateInvalid(t *testing.T) {
+	require := require.New(t)
+	e := testEstimator(require)
+
+	_, err := e.Estimate(`{ user(id: "1") { age } }`, "", nil)
+	require.Error(err)
+	require.Contains(err.Error(), `Cannot query field "age" on type "User"`)
+	_, err = e.Estimate(`{ user(`, "", nil)
+	require.Error(err)
+	_, err = e.Estimate(`query($n: Int) { users(first: $n) { total } }`, "", map[string]interface{}{"n": "many"})
+	require.EqualError(err, "Argument 'first' of field 'users' must be an integer")
+	_, err = e.Estimate(`{ users(first: 99999999999999999999) { total } }`, "", nil)
+	require.Error(err)
+
+	_, err = NewEstimator(`type Query { user: Nobody }`, 20)
+	require.Error(err)
+}
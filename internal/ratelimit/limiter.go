@@ -0,0 +1,154 @@
+// Package ratelimit implements keyed token bucket rate limiting
+package ratelimit
+
+import (
+	"container/list"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often buckets that have refilled completely are dropped
+const sweepInterval = time.Minute
+
+// Limit describes a token bucket: Burst tokens at most, refilled at Rate tokens per second
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result describes the outcome of taking a token from a bucket
+type Result struct {
+	// Allowed is true when a token was available
+	Allowed bool
+	// Limit is the capacity of the bucket
+	Limit int
+	// Remaining is the number of whole tokens left in the bucket
+	Remaining int
+	// Reset is the time until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is the time until the next token is available when not allowed
+	RetryAfter time.Duration
+}
+
+type bucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.Rate)
+	b.last = now
+}
+
+func (b *bucket) full() bool {
+	return b.tokens >= float64(b.limit.Burst)
+}
+
+func (b *bucket) until(tokens float64) time.Duration {
+	missing := tokens - b.tokens
+	if missing <= 0 || b.limit.Rate <= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(missing / b.limit.Rate * float64(time.Second)))
+}
+
+// Limiter holds token buckets identified by arbitrary keys. Beyond its maximum number of buckets, the buckets of the
+// least recently seen keys are dropped, so that clients making up keys cannot exhaust memory.
+type Limiter struct {
+	mu         sync.Mutex
+	maxBuckets int
+	buckets    map[string]*list.Element
+	// order lists buckets, most recently used first
+	order     *list.List
+	lastSweep time.Time
+
+	now func() time.Time
+}
+
+// New creates a new Limiter holding up to maxBuckets buckets, 0 leaves the number of buckets unbounded
+func New(maxBuckets int) *Limiter {
+	return newLimiter(time.Now, maxBuckets)
+}
+
+func newLimiter(now func() time.Time, maxBuckets int) *Limiter {
+	return &Limiter{
+		maxBuckets: maxBuckets,
+		buckets:    make(map[string]*list.Element),
+		order:      list.New(),
+		lastSweep:  now(),
+		now:        now,
+	}
+}
+
+// Allow takes a token from the bucket identified by key, creating a full bucket with the given limit if needed
+func (l *Limiter) Allow(key string, limit Limit) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	var b *bucket
+	if e, ok := l.buckets[key]; ok {
+		b = e.Value.(*bucket)
+		l.order.MoveToFront(e)
+	} else {
+		b = &bucket{key: key}
+		l.buckets[key] = l.order.PushFront(b)
+		for l.maxBuckets > 0 && l.order.Len() > l.maxBuckets {
+			l.remove(l.order.Back())
+		}
+	}
+	if b.limit != limit {
+		b.tokens = float64(limit.Burst)
+		b.last = now
+		b.limit = limit
+	}
+	b.refill(now)
+
+	res := Result{
+		Limit: limit.Burst,
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = b.until(1)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = b.until(float64(limit.Burst))
+	return res
+}
+
+// Reset drops all buckets, holding up to maxBuckets buckets from now on
+func (l *Limiter) Reset(maxBuckets int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.maxBuckets = maxBuckets
+	l.buckets = make(map[string]*list.Element)
+	l.order = list.New()
+}
+
+// sweep drops buckets that would be full by now to keep memory bounded by the number of active keys
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	for _, e := range l.buckets {
+		b := e.Value.(*bucket)
+		b.refill(now)
+		if b.full() {
+			l.remove(e)
+		}
+	}
+	l.lastSweep = now
+}
+
+func (l *Limiter) remove(e *list.Element) {
+	l.order.Remove(e)
+	delete(l.buckets, e.Value.(*bucket).key)
+}
@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestAllowBurst(t *testing.T) {
+	require := require.New(t)
+	clock := &testClock{now: time.Now()}
+	l := newLimiter(clock.Now, 0)
+	limit := Limit{Rate: 1, Burst: 3}
+
+	for i := 2; i >= 0; i-- {
+		res := l.Allow("boris", limit)
+		require.True(res.Allowed)
+		require.Equal(3, res.Limit)
+		require.Equal(i, res.Remaining)
+	}
+
+	res := l.Allow("boris", limit)
+	require.False(res.Allowed)
+	require.Equal(0, res.Remaining)
+	require.Equal(time.Second, res.RetryAfter)
+	require.Equal(3*time.Second, res.Reset)
+
+	// Other keys have buckets of their own
+	require.True(l.Allow("olga", limit).Allowed)
+}
+
+func TestAllowRefill(t *testing.T) {
+	require := require.New(t)
+	clock := &testClock{now: time.Now()}
+	l := newLimiter(clock.Now, 0)
+	limit := Limit{Rate: 2, Burst: 1}
+
+	require.True(l.Allow("boris", limit).Allowed)
+	require.False(l.Allow("boris", limit).Allowed)
+
+	clock.Advance(500 * time.Millisecond)
+	require.True(l.Allow("boris", limit).Allowed)
+	require.False(l.Allow("boris", limit).Allowed)
+}
+
+func TestSweep(t *testing.T) {
+	require := require.New(t)
+	clock := &testClock{now: time.Now()}
+	l := newLimiter(clock.Now, 0)
+	limit := Limit{Rate: 1, Burst: 1}
+
+	l.Allow("boris", limit)
+	l.Allow("olga", limit)
+	require.Len(l.buckets, 2)
+
+	clock.Advance(2 * sweepInterval)
+	l.Allow("olga", limit)
+	require.Len(l.buckets, 1)
+	require.Contains(l.buckets, "olga")
+}
+
+func TestMaxBuckets(t *testing.T) {
+	require := require.New(t)
+	clock := &testClock{now: time.Now()}
+	l := newLimiter(clock.Now, 2)
+	limit := Limit{Rate: 1, Burst: 1}
+
+	require.True(l.Allow("boris", limit).Allowed)
+	require.True(l.Allow("olga", limit).Allowed)
+	require.False(l.Allow("boris", limit).Allowed)
+
+	// The least recently seen key is dropped to make room
+	require.True(l.Allow("ivan", limit).Allowed)
+	require.Len(l.buckets, 2)
+	require.Contains(l.buckets, "boris")
+	require.Contains(l.buckets, "ivan")
+	require.False(l.Allow("boris", limit).Allowed)
+}
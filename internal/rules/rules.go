@@ -0,0 +1,199 @@
+// Package rules matches Articles against the filter rules Users set up to mute topics in their timelines
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/markup"
+)
+
+// Rule value limits
+const (
+	maxValueLen = 200
+)
+
+// Types lists all filter rule types
+var Types = []string{api.FilterKeyword, api.FilterRegex, api.FilterTag, api.FilterFeed, api.FilterAuthor}
+
+// Set is a compiled set of filter rules. Articles matching an exclude rule are hidden unless they also match an
+// include rule. The zero Set hides nothing.
+type Set struct {
+	include []matcher
+	exclude []matcher
+}
+
+// matcher tells whether an Article matches a rule
+type matcher func(s *subject) bool
+
+// subject is an Article of a Feed being matched against the rules of a Set. Its text is extracted and split into
+// words once, on first use, for all the rules.
+type subject struct {
+	feedID  string
+	article *api.Article
+
+	text  *string
+	words [][]string
+}
+
+// bodyText returns the body of the Article as plain text
+func (s *subject) bodyText() string {
+	if s.text == nil {
+		text := markup.Text(s.article.Body, s.article.BodyFormat)
+		s.text = &text
+	}
+	return *s.text
+}
+
+// fieldWords returns the words of the title, summary and body of the Article
+func (s *subject) fieldWords() [][]string {
+	if s.words == nil {
+		s.words = [][]string{words(s.article.Title), words(s.article.Summary), words(s.bodyText())}
+	}
+	return s.words
+}
+
+// Normalize validates a rule, returning it with its value trimmed and, where matching ignores case, lower-cased.
+// The action defaults to exclude.
+func Normalize(rule api.FilterRule) (api.FilterRule, error) {
+	rule.Value = strings.TrimSpace(rule.Value)
+	if rule.Value == "" {
+		return rule, fmt.Errorf("Filter rule value cannot be blank")
+	}
+	if len([]rune(rule.Value)) > maxValueLen {
+		return rule, fmt.Errorf("Filter rule value cannot be longer than %d characters", maxValueLen)
+	}
+
+	switch rule.Action {
+	case "":
+		rule.Action = api.FilterExclude
+	case api.FilterExclude, api.FilterInclude:
+	default:
+		return rule, fmt.Errorf("Unknown filter rule action '%s', expected one of %s, %s",
+			rule.Action, api.FilterExclude, api.FilterInclude)
+	}
+
+	switch rule.Type {
+	case api.FilterKeyword:
+		if len(words(rule.Value)) == 0 {
+			return rule, fmt.Errorf("Filter rule keyword '%s' has no words", rule.Value)
+		}
+		rule.Value = strings.ToLower(rule.Value)
+	case api.FilterTag, api.FilterAuthor:
+		rule.Value = strings.ToLower(rule.Value)
+	case api.FilterRegex:
+		if _, err := regexp.Compile(rule.Value); err != nil {
+			return rule, fmt.Errorf("Invalid filter rule regex: %s", err)
+		}
+	case api.FilterFeed:
+	default:
+		return rule, fmt.Errorf("Unknown filter rule type '%s', expected one of %s", rule.Type, strings.Join(Types, ", "))
+	}
+	return rule, nil
+}
+
+// Compile compiles rules into a Set
+func Compile(rules []api.FilterRule) (*Set, error) {
+	s := &Set{}
+	for _, rule := range rules {
+		rule, err := Normalize(rule)
+		if err != nil {
+			return nil, err
+		}
+		m := compile(rule)
+		if rule.Action == api.FilterInclude {
+			s.include = append(s.include, m)
+		} else {
+			s.exclude = append(s.exclude, m)
+		}
+	}
+	return s, nil
+}
+
+// compile builds the matcher of a normalized rule
+func compile(rule api.FilterRule) matcher {
+	switch rule.Type {
+	case api.FilterKeyword:
+		keyword := words(rule.Value)
+		return func(s *subject) bool {
+			for _, text := range s.fieldWords() {
+				if containsPhrase(text, keyword) {
+					return true
+				}
+			}
+			return false
+		}
+	case api.FilterRegex:
+		re := regexp.MustCompile(rule.Value)
+		return func(s *subject) bool {
+			return re.MatchString(s.article.Title) || re.MatchString(s.article.Summary) || re.MatchString(s.bodyText())
+		}
+	case api.FilterTag:
+		return func(s *subject) bool {
+			for _, t := range s.article.Tags {
+				if t == rule.Value {
+					return true
+				}
+			}
+			return false
+		}
+	case api.FilterFeed:
+		return func(s *subject) bool {
+			return s.feedID == rule.Value
+		}
+	default:
+		return func(s *subject) bool {
+			return strings.ToLower(strings.TrimSpace(s.article.Author)) == rule.Value
+		}
+	}
+}
+
+// Empty returns true when the Set hides nothing
+func (s *Set) Empty() bool {
+	return s == nil || len(s.exclude) == 0
+}
+
+// Hides returns true when an Article of a Feed is to be hidden
+func (s *Set) Hides(feedID string, a *api.Article) bool {
+	if s.Empty() {
+		return false
+	}
+	subject := &subject{feedID: feedID, article: a}
+	return matchesAny(s.exclude, subject) && !matchesAny(s.include, subject)
+}
+
+func matchesAny(matchers []matcher, s *subject) bool {
+	for _, m := range matchers {
+		if m(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// words splits a text into lower-cased words
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// containsPhrase returns true when phrase occurs in text as consecutive words
+func containsPhrase(text []string, phrase []string) bool {
+	for i := 0; i+len(phrase) <= len(text); i++ {
+		match := true
+		for j, w := range phrase {
+			if text[i+j] != w {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	require := require.New(t)
+
+	rule, err := Normalize(api.FilterRule{Type: api.FilterKeyword, Value: "  Climate Change "})
+	require.NoError(err)
+	require.Equal("climate change", rule.Value)
+	require.Equal(api.FilterExclude, rule.Action)
+
+	rule, err = Normalize(api.FilterRule{Type: api.FilterRegex, Value: "(?i)^Breaking", Action: api.FilterInclude})
+	require.NoError(err)
+	require.Equal("(?i)^Breaking", rule.Value)
+
+	for _, invalid := range []api.FilterRule{
+		{Type: api.FilterKeyword, Value: " "},
+		{Type: api.FilterKeyword, Value: "!!!"},
+		{Type: api.FilterRegex, Value: "(unclosed"},
+		{Type: "sentiment", Value: "negative"},
+		{Type: api.FilterTag, Value: "politics", Action: "hide"},
+	} {
+		_, err := Normalize(invalid)
+		require.Error(err, "%+v", invalid)
+	}
+}
+
+func TestHides(t *testing.T) {
+	require := require.New(t)
+
+	election := &api.Article{Title: "Election Night", Body: "Polls close at eight.", Tags: []string{"politics"}, Author: "Boris"}
+	local := &api.Article{Title: "Local election results", Body: "The mayor keeps her seat.", Tags: []string{"politics", "local"}}
+	sports := &api.Article{Title: "Cup final", Body: "A selection of highlights.", Tags: []string{"sports"}}
+
+	set, err := Compile([]api.FilterRule{
+		{Type: api.FilterKeyword, Value: "Election"},
+		{Type: api.FilterTag, Value: "local", Action: api.FilterInclude},
+	})
+	require.NoError(err)
+	require.True(set.Hides("news", election))
+	// Include rules win over exclude rules
+	require.False(set.Hides("news", local))
+	// Keywords match whole words only
+	require.False(set.Hides("news", sports))
+
+	set, err = Compile([]api.FilterRule{
+		{Type: api.FilterKeyword, Value: "polls close"},
+		{Type: api.FilterFeed, Value: "sports-feed"},
+	})
+	require.NoError(err)
+	require.True(set.Hides("news", election))
+	require.False(set.Hides("news", sports))
+	require.True(set.Hides("sports-feed", sports))
+
+	set, err = Compile([]api.FilterRule{{Type: api.FilterAuthor, Value: "boris"}, {Type: api.FilterRegex, Value: "high.ights"}})
+	require.NoError(err)
+	require.True(set.Hides("news", election))
+	require.True(set.Hides("news", sports))
+	require.False(set.Hides("news", local))
+
+	// Only include rules hide nothing
+	set, err = Compile([]api.FilterRule{{Type: api.FilterTag, Value: "sports", Action: api.FilterInclude}})
+	require.NoError(err)
+	require.True(set.Empty())
+	require.False(set.Hides("news", election))
+
+	var none *Set
+	require.False(none.Hides("news", election))
+}
+
+func TestSubjectTextIsPreparedOnce(t *testing.T) {
+	require := require.New(t)
+
+	s := &subject{feedID: "news", article: &api.Article{
+		Title:      "Election Night",
+		Summary:    "Polls close.",
+		Body:       "<p>Polls <b>close</b> at eight</p>",
+		BodyFormat: api.BodyHTML,
+	}}
+	require.Equal([][]string{{"election", "night"}, {"polls", "close"}, {"polls", "close", "at", "eight"}}, s.fieldWords())
+
+	// Later rules see the words prepared for the first one
+	s.article.Title = "Cup final"
+	require.Equal([]string{"election", "night"}, s.fieldWords()[0])
+	require.Equal("Polls close at eight", s.bodyText())
+}
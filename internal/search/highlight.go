@@ -0,0 +1,75 @@
+package search
+
+import (
+	"html"
+	"strings"
+)
+
+// Highlight tags
+const (
+	HighlightStart = "<mark>"
+	HighlightEnd   = "</mark>"
+)
+
+// snippetContext is the number of terms preceding the first match that are kept in a snippet
+const snippetContext = 5
+
+// Highlight returns an HTML snippet of at most about size characters of a field's text around the first term
+// matching the Query, with all matching terms wrapped in <mark> tags. An empty string is returned when nothing
+// in the text matches.
+func Highlight(q *Query, field string, text string, size int) string {
+	terms := map[string]bool{}
+	for _, c := range q.Clauses {
+		if c.matchesField(field) {
+			for _, t := range c.Terms {
+				terms[t] = true
+			}
+		}
+	}
+
+	tokens := tokenize(text)
+	first := -1
+	for i, t := range tokens {
+		if terms[t.term] {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return ""
+	}
+
+	start, end := 0, len(text)
+	if runeCount(text) > size {
+		from := first - snippetContext
+		if from < 0 {
+			from = 0
+		}
+		start = tokens[from].start
+		end = tokens[first].end
+		for j := first + 1; j < len(tokens) && runeCount(text[start:tokens[j].end]) <= size; j++ {
+			end = tokens[j].end
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, t := range tokens {
+		if t.start < start || t.end > end || !terms[t.term] {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:t.start]))
+		b.WriteString(HighlightStart)
+		b.WriteString(html.EscapeString(text[t.start:t.end]))
+		b.WriteString(HighlightEnd)
+		pos = t.end
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
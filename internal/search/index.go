@@ -0,0 +1,143 @@
+package search
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+)
+
+// Hit is an Article matching a Query
+type Hit struct {
+	Article api.Article
+	FeedID  string
+	Score   float64
+}
+
+// Results is a page of Hits ranked by relevance, Total counts all matching Articles
+type Results struct {
+	Total int
+	Hits  []Hit
+}
+
+// Index is an in-memory inverted index of Articles, safe for concurrent use. It provides full-text search
+// for repositories that have no text search of their own.
+type Index struct {
+	mu sync.RWMutex
+	// docs maps Article IDs to indexed Articles
+	docs map[string]*document
+	// postings maps terms to the IDs of Articles containing them
+	postings map[string]map[string]bool
+	// fieldLengths sums the number of terms in each field across all Articles
+	fieldLengths map[string]int
+}
+
+type document struct {
+	*Document
+	article api.Article
+	feedID  string
+}
+
+// NewIndex creates an empty Index
+func NewIndex() *Index {
+	return &Index{
+		docs:         make(map[string]*document),
+		postings:     make(map[string]map[string]bool),
+		fieldLengths: make(map[string]int),
+	}
+}
+
+// Add indexes an Article of a Feed, replacing a previously indexed version of the Article
+func (i *Index) Add(feedID string, a api.Article) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.remove(a.ID)
+
+	d := &document{Document: Analyze(a), article: a, feedID: feedID}
+	for field, positions := range d.Positions {
+		for t := range positions {
+			if i.postings[t] == nil {
+				i.postings[t] = make(map[string]bool)
+			}
+			i.postings[t][a.ID] = true
+		}
+		i.fieldLengths[field] += d.Lengths[field]
+	}
+	i.docs[a.ID] = d
+}
+
+// Remove drops an Article from the Index
+func (i *Index) Remove(articleID string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.remove(articleID)
+}
+
+func (i *Index) remove(articleID string) {
+	d, ok := i.docs[articleID]
+	if !ok {
+		return
+	}
+	for field, positions := range d.Positions {
+		for t := range positions {
+			delete(i.postings[t], articleID)
+			if len(i.postings[t]) == 0 {
+				delete(i.postings, t)
+			}
+		}
+		i.fieldLengths[field] -= d.Lengths[field]
+	}
+	delete(i.docs, articleID)
+}
+
+// Search returns a page of Articles matching the Query, best matches first. Only Articles of the given Feeds
+// are searched unless feedIDs is nil.
+func (i *Index) Search(q *Query, feedIDs []string, offset int, limit int) *Results {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	var feeds map[string]bool
+	if feedIDs != nil {
+		feeds = make(map[string]bool, len(feedIDs))
+		for _, id := range feedIDs {
+			feeds[id] = true
+		}
+	}
+
+	stats := &Stats{Documents: len(i.docs), FieldLengths: i.fieldLengths, Frequencies: map[string]int{}}
+	for _, t := range q.Terms() {
+		stats.Frequencies[t] = len(i.postings[t])
+	}
+
+	hits := []Hit{}
+	for id := range i.candidates(q) {
+		d := i.docs[id]
+		if feeds != nil && !feeds[d.feedID] {
+			continue
+		}
+		if score, ok := q.Score(d.Document, stats); ok {
+			hits = append(hits, Hit{Article: d.article, FeedID: d.feedID, Score: score})
+		}
+	}
+	return Rank(hits, offset, limit)
+}
+
+// candidates returns the IDs of Articles containing all terms of the Query
+func (i *Index) candidates(q *Query) map[string]bool {
+	terms := q.Terms()
+	sort.Slice(terms, func(a, b int) bool { return len(i.postings[terms[a]]) < len(i.postings[terms[b]]) })
+
+	candidates := map[string]bool{}
+	for id := range i.postings[terms[0]] {
+		candidates[id] = true
+	}
+	for _, t := range terms[1:] {
+		for id := range candidates {
+			if !i.postings[t][id] {
+				delete(candidates, id)
+			}
+		}
+	}
+	return candidates
+}
@@ -0,0 +1,134 @@
+// Package search implements full-text search of Articles: query parsing, BM25 scoring of analyzed Articles for
+// repositories keeping an inverted index of their own, an in-memory inverted index, and highlighting of matches
+package search
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// Searchable Article fields
+const (
+	FieldTitle = "title"
+	FieldBody  = "body"
+)
+
+// Fields lists all searchable fields
+var Fields = []string{FieldTitle, FieldBody}
+
+// ErrEmptyQuery is the error returned when a query has nothing to search for
+var ErrEmptyQuery = errors.New("Search query cannot be blank")
+
+// Clause is a term or a phrase that must occur in an Article for the Article to match a Query
+type Clause struct {
+	// Field restricts the Clause to a single field, empty matches any field
+	Field string
+	// Terms are the normalized terms of the Clause, a phrase has more than one
+	Terms []string
+}
+
+// Phrase returns true when the Clause requires several terms in sequence
+func (c Clause) Phrase() bool {
+	return len(c.Terms) > 1
+}
+
+// matchesField returns true when the Clause applies to the given field
+func (c Clause) matchesField(field string) bool {
+	return c.Field == "" || c.Field == field
+}
+
+// Query is a parsed search query, an Article matches a Query when it matches all of its Clauses
+type Query struct {
+	Raw     string
+	Clauses []Clause
+}
+
+// Terms returns all distinct terms of the Query
+func (q *Query) Terms() []string {
+	seen := map[string]bool{}
+	terms := []string{}
+	for _, c := range q.Clauses {
+		for _, t := range c.Terms {
+			if !seen[t] {
+				seen[t] = true
+				terms = append(terms, t)
+			}
+		}
+	}
+	return terms
+}
+
+// ParseQuery parses a search query. Queries consist of terms (`tolstoy`), quoted phrases (`"war and peace"`)
+// and either of them restricted to a field (`title:tolstoy`, `body:"war and peace"`). Other words with a colon
+// (`golang:generics`, `https://example.com`) are searched for as they are.
+func ParseQuery(raw string) (*Query, error) {
+	q := &Query{Raw: raw}
+	runes := []rune(raw)
+
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		field := ""
+		if name, next, ok := scanField(runes, i); ok {
+			field, i = name, next
+		}
+
+		var text string
+		if i < len(runes) && runes[i] == '"' {
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			// An unterminated phrase runs to the end of the query
+			text = string(runes[i+1 : end])
+			i = end + 1
+		} else {
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) {
+				end++
+			}
+			text = string(runes[i:end])
+			i = end
+		}
+
+		// Words with punctuation inside (e.g. "e-mail") become phrases
+		terms := tokenTerms(tokenize(text))
+		if len(terms) > 0 {
+			q.Clauses = append(q.Clauses, Clause{Field: field, Terms: terms})
+		}
+	}
+
+	if len(q.Clauses) == 0 {
+		return nil, ErrEmptyQuery
+	}
+	return q, nil
+}
+
+// scanField recognizes the prefix of a searchable field (`title:`) at position i
+func scanField(runes []rune, i int) (name string, next int, ok bool) {
+	end := i
+	for end < len(runes) && unicode.IsLetter(runes[end]) {
+		end++
+	}
+	if end == i || end >= len(runes) || runes[end] != ':' {
+		return "", i, false
+	}
+	name = strings.ToLower(string(runes[i:end]))
+	if !isField(name) {
+		return "", i, false
+	}
+	return name, end + 1, true
+}
+
+func isField(name string) bool {
+	for _, f := range Fields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package search
+
+import (
+	"math"
+	"sort"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/markup"
+)
+
+// BM25 ranking parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// fieldWeights boosts matches in titles over matches in bodies
+var fieldWeights = map[string]float64{
+	FieldTitle: 2,
+	FieldBody:  1,
+}
+
+// Document is an Article as indexed: the positions of the terms in each of its fields and the number of terms of
+// each field
+type Document struct {
+	Positions map[string]map[string][]int
+	Lengths   map[string]int
+}
+
+// Analyze tokenizes the searchable fields of an Article into a Document
+func Analyze(a api.Article) *Document {
+	d := &Document{
+		Positions: make(map[string]map[string][]int),
+		Lengths:   make(map[string]int),
+	}
+	texts := map[string]string{FieldTitle: a.Title, FieldBody: markup.Text(a.Body, a.BodyFormat)}
+	for field, text := range texts {
+		terms := tokenTerms(tokenize(text))
+		positions := make(map[string][]int)
+		for pos, t := range terms {
+			positions[t] = append(positions[t], pos)
+		}
+		d.Positions[field] = positions
+		d.Lengths[field] = len(terms)
+	}
+	return d
+}
+
+// Stats describe all indexed Articles, scores are relative to them so that they compare across queries
+type Stats struct {
+	// Documents is the number of Articles indexed
+	Documents int
+	// FieldLengths sums the number of terms of each field across Articles
+	FieldLengths map[string]int
+	// Frequencies counts the Articles containing each term of the Query scored
+	Frequencies map[string]int
+}
+
+func (s *Stats) idf(term string) float64 {
+	n := float64(s.Frequencies[term])
+	return math.Log(1 + (float64(s.Documents)-n+0.5)/(n+0.5))
+}
+
+// Score ranks a Document against the Query using BM25 over weighted fields, returning false when the Document does
+// not match all Clauses
+func (q *Query) Score(d *Document, stats *Stats) (float64, bool) {
+	total := 0.0
+	for _, c := range q.Clauses {
+		matched := false
+		for _, field := range Fields {
+			if !c.matchesField(field) {
+				continue
+			}
+			tf := occurrences(c.Terms, d.Positions[field])
+			if tf == 0 {
+				continue
+			}
+			matched = true
+
+			idf := 0.0
+			for _, t := range c.Terms {
+				idf += stats.idf(t)
+			}
+			avgLength := float64(stats.FieldLengths[field]) / float64(stats.Documents)
+			norm := 1 - bm25B
+			if avgLength > 0 {
+				norm += bm25B * float64(d.Lengths[field]) / avgLength
+			}
+			total += fieldWeights[field] * idf * float64(tf) * (bm25K1 + 1) / (float64(tf) + bm25K1*norm)
+		}
+		if !matched {
+			return 0, false
+		}
+	}
+	return total, true
+}
+
+// Rank orders Hits best matches first, the most recently published first among equal matches, and returns a page
+// of them
+func Rank(hits []Hit, offset int, limit int) *Results {
+	sort.Slice(hits, func(a, b int) bool {
+		if hits[a].Score != hits[b].Score {
+			return hits[a].Score > hits[b].Score
+		}
+		if !hits[a].Article.PublishedTime.Equal(hits[b].Article.PublishedTime) {
+			return hits[a].Article.PublishedTime.After(hits[b].Article.PublishedTime)
+		}
+		return hits[a].Article.ID < hits[b].Article.ID
+	})
+
+	results := &Results{Total: len(hits), Hits: []Hit{}}
+	if offset < len(hits) {
+		end := offset + limit
+		if end > len(hits) {
+			end = len(hits)
+		}
+		results.Hits = hits[offset:end]
+	}
+	return results
+}
+
+// occurrences counts how many times the terms occur in sequence in a field
+func occurrences(terms []string, positions map[string][]int) int {
+	count := 0
+	for _, start := range positions[terms[0]] {
+		if followedBy(terms[1:], start+1, positions) {
+			count++
+		}
+	}
+	return count
+}
+
+func followedBy(terms []string, pos int, positions map[string][]int) bool {
+	for k, t := range terms {
+		found := false
+		for _, p := range positions[t] {
+			if p == pos+k {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQuery(t *testing.T) {
+	require := require.New(t)
+
+	q, err := ParseQuery(`Tolstoy "War and  Peace" title:anna body:"levin's farm" e-mail`)
+	require.NoError(err)
+	require.Equal([]Clause{
+		{Terms: []string{"tolstoy"}},
+		{Terms: []string{"war", "and", "peace"}},
+		{Field: FieldTitle, Terms: []string{"anna"}},
+		{Field: FieldBody, Terms: []string{"levin", "s", "farm"}},
+		{Terms: []string{"e", "mail"}},
+	}, q.Clauses)
+	require.True(q.Clauses[1].Phrase())
+
+	// Unterminated phrases run to the end
+	q, err = ParseQuery(`"anna karenina`)
+	require.NoError(err)
+	require.Equal([]string{"anna", "karenina"}, q.Clauses[0].Terms)
+
+	// Only searchable fields restrict terms, other words with a colon are terms
+	q, err = ParseQuery(`author:tolstoy golang:generics https://example.com TITLE:anna`)
+	require.NoError(err)
+	require.Equal([]Clause{
+		{Terms: []string{"author", "tolstoy"}},
+		{Terms: []string{"golang", "generics"}},
+		{Terms: []string{"https", "example", "com"}},
+		{Field: FieldTitle, Terms: []string{"anna"}},
+	}, q.Clauses)
+	_, err = ParseQuery(`  "" -- `)
+	require.Equal(ErrEmptyQuery, err)
+}
+
+func TestIndexSearch(t *testing.T) {
+	require := require.New(t)
+
+	now := time.Now()
+	index := NewIndex()
+	index.Add("russian", api.Article{ID: "1", Title: "War and Peace", Body: "Napoleon invades Russia", PublishedTime: now})
+	index.Add("russian", api.Article{ID: "2", Title: "Anna Karenina", Body: "Levin thinks about war and peace on his farm", PublishedTime: now})
+	index.Add("french", api.Article{ID: "3", Title: "Les Misérables", Body: "Peace and war in Paris", PublishedTime: now})
+
+	search := func(raw string, feedIDs []string) []string {
+		q, err := ParseQuery(raw)
+		require.NoError(err)
+		ids := []string{}
+		for _, h := range index.Search(q, feedIDs, 0, 10).Hits {
+			ids = append(ids, h.Article.ID)
+		}
+		return ids
+	}
+
+	// Title matches rank above body matches, shorter bodies above longer ones
+	require.Equal([]string{"1", "3", "2"}, search("war", nil))
+	require.Equal([]string{"1", "2"}, search(`"war and peace"`, nil))
+	require.Equal([]string{"3", "2"}, search("body:war", nil))
+	require.Equal([]string{"2"}, search("war levin", nil))
+	require.Equal([]string{"3"}, search("misérables", nil))
+	require.Equal([]string{"3"}, search("war", []string{"french"}))
+	require.Empty(search("war", []string{}))
+	require.Empty(search("tolstoy", nil))
+
+	// Paging
+	q, _ := ParseQuery("war")
+	page := index.Search(q, nil, 1, 1)
+	require.Equal(3, page.Total)
+	require.Len(page.Hits, 1)
+	require.Equal("3", page.Hits[0].Article.ID)
+	require.Empty(index.Search(q, nil, 5, 1).Hits)
+
+	// Replacing and removing Articles
+	index.Add("russian", api.Article{ID: "1", Title: "Childhood", Body: "Boyhood", PublishedTime: now})
+	require.Equal([]string{"3", "2"}, search("war", nil))
+	index.Remove("3")
+	require.Equal([]string{"2"}, search("war", nil))
+}
+
+func TestHighlight(t *testing.T) {
+	require := require.New(t)
+
+	q, err := ParseQuery(`title:anna war`)
+	require.NoError(err)
+
+	require.Equal("<mark>Anna</mark> &amp; the <mark>War</mark>", Highlight(q, FieldTitle, "Anna & the War", 100))
+	require.Equal("No Anna in the <mark>war</mark>", Highlight(q, FieldBody, "No Anna in the war", 100))
+	require.Equal("", Highlight(q, FieldBody, "Nothing to see", 100))
+
+	long := "one two three four five six seven eight nine ten war eleven twelve thirteen fourteen fifteen"
+	require.Equal("…six seven eight nine ten <mark>war</mark> eleven…", Highlight(q, FieldBody, long, 40))
+}
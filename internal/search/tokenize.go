@@ -0,0 +1,49 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// token is a normalized term found in a text, start and end are byte offsets into the text
+type token struct {
+	term  string
+	start int
+	end   int
+}
+
+// tokenize splits a text into lower-cased terms made of letters and digits
+func tokenize(text string) []token {
+	tokens := []token{}
+	start := -1
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, token{term: strings.ToLower(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{term: strings.ToLower(text[start:]), start: start, end: len(text)})
+	}
+	return tokens
+}
+
+func tokenTerms(tokens []token) []string {
+	terms := make([]string, len(tokens))
+	for i, t := range tokens {
+		terms[i] = t.term
+	}
+	return terms
+}
+
+// runeCount is a shorthand used when sizing snippets
+func runeCount(s string) int {
+	return utf8.RuneCountInString(s)
+}
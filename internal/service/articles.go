@@ -1,10 +1,18 @@
 package service
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+	"github.com/if-ivan-else/tldrfeed/internal/dedup"
+	"github.com/if-ivan-else/tldrfeed/internal/markup"
 )
 
 func (s *Server) createFeedArticleHandler() http.HandlerFunc {
@@ -15,8 +23,18 @@ func (s *Server) createFeedArticleHandler() http.HandlerFunc {
 			return
 		}
 
+		article, err := newArticle(&articleRequest, time.Now())
+		if err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
 		vars := mux.Vars(req)
-		articleID, err := s.repo.CreateFeedArticle(vars["feedID"], articleRequest.Title, articleRequest.Body)
+		if !s.checkFeedQuota(req.Context(), w, vars["feedID"]) {
+			return
+		}
+
+		articleID, err := s.addArticle(req.Context(), vars["feedID"], article)
 		if err != nil {
 			s.formatter.Text(w, errorToStatus(err), err.Error())
 			return
@@ -29,44 +47,410 @@ func (s *Server) createFeedArticleHandler() http.HandlerFunc {
 	}
 }
 
+// addArticle adds a validated Article to a Feed, summarizing it when it has no summary and notifying the publish
+// hooks when it is published
+func (s *Server) addArticle(ctx context.Context, feedID string, article api.Article) (string, error) {
+	if article.Summary == "" {
+		article.Summary = s.currentSummarizer().Summarize(article.Title, markup.Text(article.Body, article.BodyFormat))
+	}
+	articleID, err := s.repo.CreateFeedArticle(ctx, feedID, article)
+	if err != nil {
+		return "", err
+	}
+	if article.Status == api.StatusPublished {
+		article.ID, article.FeedID = articleID, feedID
+		s.notifyPublished(article)
+	}
+	return articleID, nil
+}
+
+// Article field limits
+const (
+	maxArticleTags   = 20
+	maxArticleTagLen = 50
+	maxAuthorLen     = 200
+	// maxClockSkew is how far in the future publishers' clocks may be
+	maxClockSkew = 5 * time.Minute
+)
+
+// newArticle validates an Article creation request, returning the Article to store with HTML bodies sanitized,
+// tags normalized and times and status defaulted relative to now
+func newArticle(r *api.CreateArticleRequest, now time.Time) (api.Article, error) {
+	a := api.Article{
+		Title:         r.Title,
+		Body:          r.Body,
+		BodyFormat:    r.BodyFormat,
+		Summary:       strings.TrimSpace(r.Summary),
+		Author:        strings.TrimSpace(r.Author),
+		URL:           r.URL,
+		ImageURL:      r.ImageURL,
+		PublishedTime: now,
+	}
+
+	if a.BodyFormat == api.BodyText {
+		// Plain text is the default format
+		a.BodyFormat = ""
+	}
+	if err := markup.ValidFormat(a.BodyFormat); err != nil {
+		return a, err
+	}
+	if a.BodyFormat == api.BodyHTML {
+		a.Body = markup.Sanitize(a.Body)
+		if markup.PlainText(a.Body) == "" && !strings.Contains(a.Body, "<img") {
+			return a, fmt.Errorf("Article body has no content left after removing unsafe HTML")
+		}
+	}
+
+	if len([]rune(a.Author)) > maxAuthorLen {
+		return a, fmt.Errorf("Article author cannot be longer than %d characters", maxAuthorLen)
+	}
+	if err := validateURL("URL", a.URL); err != nil {
+		return a, err
+	}
+	if err := validateURL("image URL", a.ImageURL); err != nil {
+		return a, err
+	}
+
+	tags, err := normalizeTags(r.Tags)
+	if err != nil {
+		return a, err
+	}
+	a.Tags = tags
+
+	a.Status = r.Status
+	if a.Status == "" {
+		a.Status = api.StatusPublished
+		if r.PublishAt != nil {
+			a.Status = api.StatusScheduled
+		}
+	}
+	switch a.Status {
+	case api.StatusPublished:
+		if r.PublishAt != nil {
+			return a, fmt.Errorf("Published Articles cannot have a publish_at time, schedule them instead")
+		}
+	case api.StatusScheduled:
+		if r.PublishAt == nil || !r.PublishAt.After(now) {
+			return a, fmt.Errorf("Scheduled Articles need a publish_at time in the future")
+		}
+		if r.PublishedTime != nil || r.UpdatedTime != nil {
+			return a, fmt.Errorf("Scheduled Articles are published at their publish_at time, they cannot have other times")
+		}
+		publishAt := *r.PublishAt
+		a.PublishAt = &publishAt
+		a.PublishedTime, a.UpdatedTime = publishAt, publishAt
+		return a, nil
+	case api.StatusDraft:
+		if r.PublishAt != nil || r.PublishedTime != nil || r.UpdatedTime != nil {
+			return a, fmt.Errorf("Draft Articles are given their times when published, they cannot have any")
+		}
+		// The update time of a draft tells when it was last edited
+		a.PublishedTime, a.UpdatedTime = time.Time{}, now
+		return a, nil
+	default:
+		return a, fmt.Errorf("Unknown Article status '%s', expected one of %s, %s, %s",
+			a.Status, api.StatusPublished, api.StatusDraft, api.StatusScheduled)
+	}
+
+	if r.PublishedTime != nil {
+		if r.PublishedTime.After(now.Add(maxClockSkew)) {
+			return a, fmt.Errorf("Article publication time %s is in the future", r.PublishedTime.Format(time.RFC3339))
+		}
+		a.PublishedTime = *r.PublishedTime
+	}
+	a.UpdatedTime = a.PublishedTime
+	if r.UpdatedTime != nil {
+		if r.UpdatedTime.Before(a.PublishedTime) {
+			return a, fmt.Errorf("Article update time %s precedes its publication time", r.UpdatedTime.Format(time.RFC3339))
+		}
+		if r.UpdatedTime.After(now.Add(maxClockSkew)) {
+			return a, fmt.Errorf("Article update time %s is in the future", r.UpdatedTime.Format(time.RFC3339))
+		}
+		a.UpdatedTime = *r.UpdatedTime
+	}
+	return a, nil
+}
+
+// validateURL checks an optional absolute http(s) URL
+func validateURL(name string, value string) error {
+	if value == "" {
+		return nil
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("Article %s '%s' must be an absolute http or https URL", name, value)
+	}
+	return nil
+}
+
+// normalizeTags lower-cases and trims tags, dropping duplicates
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" {
+			return nil, fmt.Errorf("Article tags cannot be blank")
+		}
+		if len([]rune(t)) > maxArticleTagLen {
+			return nil, fmt.Errorf("Article tag '%s' is longer than %d characters", t, maxArticleTagLen)
+		}
+		if !seen[t] {
+			seen[t] = true
+			normalized = append(normalized, t)
+		}
+	}
+	if len(normalized) > maxArticleTags {
+		return nil, fmt.Errorf("Article cannot have more than %d tags", maxArticleTags)
+	}
+	if len(normalized) == 0 {
+		return nil, nil
+	}
+	return normalized, nil
+}
+
+// checkFeedQuota enforces the hourly publishing quota of a Feed, responding with an error and returning false
+// when the quota is exhausted. Concurrent publishers may overshoot the quota by a few Articles.
+func (s *Server) checkFeedQuota(ctx context.Context, w http.ResponseWriter, feedID string) bool {
+	remaining, err := s.remainingFeedQuota(ctx, feedID)
+	if err != nil {
+		s.formatter.Text(w, errorToStatus(err), err.Error())
+		return false
+	}
+	if remaining == 0 {
+		// The window slides, so retrying after a full hour is guaranteed to succeed
+		w.Header().Set("Retry-After", seconds(time.Hour))
+		s.formatter.Text(w, http.StatusTooManyRequests, s.feedQuotaExceeded(feedID))
+		return false
+	}
+	return true
+}
+
+// remainingFeedQuota returns how many more Articles can be published to a Feed this hour, -1 for Feeds without quota
+func (s *Server) remainingFeedQuota(ctx context.Context, feedID string) (int, error) {
+	quota := s.currentConfig().Quotas.feedArticlesPerHour(feedID)
+	if quota == 0 {
+		return -1, nil
+	}
+
+	count, err := s.repo.CountFeedArticles(ctx, feedID, time.Now().Add(-time.Hour))
+	if err != nil {
+		return 0, err
+	}
+	if count >= quota {
+		return 0, nil
+	}
+	return quota - count, nil
+}
+
+func (s *Server) feedQuotaExceeded(feedID string) string {
+	return fmt.Sprintf("Feed '%s' exceeded its quota of %d Articles per hour",
+		feedID, s.currentConfig().Quotas.feedArticlesPerHour(feedID))
+}
+
 func (s *Server) getUserFeedArticleListHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		vars := mux.Vars(req)
+		view, err := articleView(req)
+		if err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		format, err := bodyFormat(req)
+		if err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		filter, err := articleFilter(req)
+		if err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		filter.Rules, _, err = s.userRules(req.Context(), vars["userID"])
+		if err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
 
-		articles, err := s.repo.ListUserFeedArticles(vars["userID"], vars["feedID"])
+		articles, err := s.repo.ListUserFeedArticles(req.Context(), vars["userID"], vars["feedID"], filter)
 		if err != nil {
 			s.formatter.Text(w, errorToStatus(err), err.Error())
 			return
 		}
 
-		s.formatter.JSON(w, http.StatusOK, articles)
+		s.formatter.JSON(w, http.StatusOK, s.presentArticles(articles, view, format))
 	}
 }
 
 func (s *Server) getUserArticleListHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		vars := mux.Vars(req)
+		view, err := articleView(req)
+		if err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		format, err := bodyFormat(req)
+		if err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		filter, err := articleFilter(req)
+		if err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		collapse, err := boolParam(req.URL.Query().Get("collapse_duplicates"))
+		if err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, fmt.Sprintf("Invalid collapse_duplicates: %s", err))
+			return
+		}
+
+		// Filter rules are part of the timeline's representation
+		var rulesDigest string
+		filter.Rules, rulesDigest, err = s.userRules(req.Context(), vars["userID"])
+		if err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
 
-		articles, err := s.repo.ListUserArticles(vars["userID"])
+		version, err := s.repo.GetTimelineVersion(req.Context(), vars["userID"])
 		if err != nil {
 			s.formatter.Text(w, errorToStatus(err), err.Error())
 			return
 		}
+		if checkNotModified(w, req, timelineValidators(version, view, "tag="+filter.Tag, "rules="+rulesDigest,
+			fmt.Sprintf("collapse=%t", collapse), "body_format="+format)) {
+			return
+		}
 
-		s.formatter.JSON(w, http.StatusOK, articles)
+		articles, err := s.repo.ListUserArticles(req.Context(), vars["userID"], filter)
+		if err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
+
+		if collapse {
+			articles = dedup.Collapse(articles)
+		}
+		s.formatter.JSON(w, http.StatusOK, s.presentArticles(articles, view, format))
 	}
 }
 
 func (s *Server) getFeedArticleListHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		vars := mux.Vars(req)
+		view, err := articleView(req)
+		if err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		format, err := bodyFormat(req)
+		if err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		filter, err := articleFilter(req)
+		if err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, err.Error())
+			return
+		}
 
-		articles, err := s.repo.ListFeedArticles(vars["feedID"])
+		version, err := s.repo.GetFeedVersion(req.Context(), vars["feedID"])
 		if err != nil {
 			s.formatter.Text(w, errorToStatus(err), err.Error())
 			return
 		}
+		if checkNotModified(w, req, feedValidators(version, view, "tag="+filter.Tag, "body_format="+format)) {
+			return
+		}
+
+		articles, err := s.repo.ListFeedArticles(req.Context(), vars["feedID"], filter)
+		if err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
+
+		s.formatter.JSON(w, http.StatusOK, s.presentArticles(articles, view, format))
+	}
+}
+
+// articleView returns the Article list view requested with the view query parameter, the full view by default
+func articleView(req *http.Request) (string, error) {
+	return parseView(req.URL.Query().Get("view"))
+}
+
+// parseView checks an Article list view, the full view by default
+func parseView(view string) (string, error) {
+	switch view {
+	case "", api.ViewFull:
+		return api.ViewFull, nil
+	case api.ViewTLDR:
+		return api.ViewTLDR, nil
+	default:
+		return "", fmt.Errorf("Unknown view '%s', expected one of %s, %s", view, api.ViewFull, api.ViewTLDR)
+	}
+}
+
+// bodyFormat returns the format Article bodies are requested in with the body_format query parameter, the format
+// they were added in by default
+func bodyFormat(req *http.Request) (string, error) {
+	return parseBodyFormat(req.URL.Query().Get("body_format"))
+}
+
+// parseBodyFormat checks the format Article bodies are requested in, the format they were added in by default
+func parseBodyFormat(format string) (string, error) {
+	switch format {
+	case "", api.BodyOriginal:
+		return api.BodyOriginal, nil
+	case api.BodyText, api.BodyHTML:
+		return format, nil
+	default:
+		return "", fmt.Errorf("Unknown body format '%s', expected one of %s, %s, %s",
+			format, api.BodyOriginal, api.BodyText, api.BodyHTML)
+	}
+}
+
+// articleFilter returns the Article filter requested with the tag query parameter
+func articleFilter(req *http.Request) (db.ArticleFilter, error) {
+	return tagFilter(req.URL.Query().Get("tag"))
+}
+
+// tagFilter returns the Article filter of Articles carrying a tag, of all Articles when the tag is empty
+func tagFilter(tag string) (db.ArticleFilter, error) {
+	filter := db.ArticleFilter{}
+	if tag != "" {
+		tags, err := normalizeTags([]string{tag})
+		if err != nil {
+			return filter, err
+		}
+		filter.Tag = tags[0]
+	}
+	return filter, nil
+}
+
+// presentArticles prepares Articles for listing in a view with their bodies in a format
+func (s *Server) presentArticles(articles []api.Article, view string, format string) []api.Article {
+	presented := make([]api.Article, len(articles))
+	for i, a := range articles {
+		if view == api.ViewTLDR {
+			a.Body, a.BodyFormat = "", ""
+		} else {
+			a.Body, a.BodyFormat = presentBody(a.Body, a.BodyFormat, format)
+		}
+		presented[i] = a
+	}
+	return presented
+}
 
-		s.formatter.JSON(w, http.StatusOK, articles)
+// presentBody converts an Article body stored in a format to the requested format
+func presentBody(body string, stored string, requested string) (string, string) {
+	switch requested {
+	case api.BodyText:
+		return markup.Text(body, stored), ""
+	case api.BodyHTML:
+		return markup.HTML(body, stored), api.BodyHTML
 	}
+	return body, stored
 }
@@ -1,23 +1,28 @@
 package service
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/if-ivan-else/tldrfeed/api"
 	"github.com/stretchr/testify/require"
 )
 
 func TestCreateInvalidArticle(t *testing.T) {
+	ctx := context.Background()
 	require := require.New(t)
 	const textData = `not json`
 
 	server := testServer()
-	f, _ := server.repo.CreateFeed("Non-stop Tolstoy Fun Channel")
+	f, _ := server.repo.CreateFeed(ctx, "Non-stop Tolstoy Fun Channel", "", nil)
 
 	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/v1/feeds/%s/articles", f.ID), strings.NewReader(textData))
 	rr := httptest.NewRecorder()
@@ -27,10 +32,11 @@ func TestCreateInvalidArticle(t *testing.T) {
 }
 
 func TestCreateBlankArticle(t *testing.T) {
+	ctx := context.Background()
 	require := require.New(t)
 
 	server := testServer()
-	f, _ := server.repo.CreateFeed("Non-stop Tolstoy Fun Channel")
+	f, _ := server.repo.CreateFeed(ctx, "Non-stop Tolstoy Fun Channel", "", nil)
 
 	const jsonData = `{}`
 	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/v1/feeds/%s/articles", f.ID), strings.NewReader(jsonData))
@@ -42,10 +48,27 @@ func TestCreateBlankArticle(t *testing.T) {
 	t.Logf("Error message: %s", rr.Body.String())
 }
 
+func TestCreateTooLongArticle(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	server := testServer()
+	f, _ := server.repo.CreateFeed(ctx, "Non-stop Tolstoy Fun Channel", "", nil)
+
+	jsonData := `{"title": "War and Peace", "body": "` + strings.Repeat("Well, Prince. ", 100000) + `"}`
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/v1/feeds/%s/articles", f.ID), strings.NewReader(jsonData))
+	rr := httptest.NewRecorder()
+	router(server).ServeHTTP(rr, req)
+
+	requireStatus(http.StatusBadRequest, require, rr)
+	require.Contains(rr.Body.String(), "Article body can be at most 1000000 characters")
+}
+
 func TestCreateValidArticle(t *testing.T) {
+	ctx := context.Background()
 	require := require.New(t)
 	server := testServer()
-	f, _ := server.repo.CreateFeed("Non-stop Tolstoy Fun Channel")
+	f, _ := server.repo.CreateFeed(ctx, "Non-stop Tolstoy Fun Channel", "", nil)
 
 	jsonData := `{
     "title": "War and Peace: Chapter 7",
@@ -95,15 +118,16 @@ func TestListUnknownFeedArticles(t *testing.T) {
 }
 
 func TestListFeedArticles(t *testing.T) {
+	ctx := context.Background()
 	require := require.New(t)
 
 	server := testServer()
 	name := "Anton Chekhov Super Short Stories"
-	f, _ := server.repo.CreateFeed(name)
+	f, _ := server.repo.CreateFeed(ctx, name, "", nil)
 	title := "Gooseberries"
 	body := `Ivan Ivanovich Chimsha-Gimalayski, a veterinary surgeon,
 tells the story of his younger brother Nikolai Ivanovich.`
-	server.repo.CreateFeedArticle(f.ID, title, body)
+	server.repo.CreateFeedArticle(ctx, f.ID, api.Article{Title: title, Body: body})
 
 	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/v1/feeds/%s/articles", f.ID), nil)
 	rr := httptest.NewRecorder()
@@ -121,11 +145,12 @@ tells the story of his younger brother Nikolai Ivanovich.`
 }
 
 func TestListUnknownUserArticles(t *testing.T) {
+	ctx := context.Background()
 	require := require.New(t)
 
 	server := testServer()
 	name := "Anton Chekhov Super Short Stories"
-	f, _ := server.repo.CreateFeed(name)
+	f, _ := server.repo.CreateFeed(ctx, name, "", nil)
 
 	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/v1/users/%s/feeds/%s/articles", uuid.New().String(), f.ID), nil)
 	rr := httptest.NewRecorder()
@@ -140,19 +165,20 @@ func TestListUnknownUserArticles(t *testing.T) {
 }
 
 func TestListUserArticles(t *testing.T) {
+	ctx := context.Background()
 	require := require.New(t)
 
 	server := testServer()
 	name := "Anton Chekhov Super Short Stories"
-	f, _ := server.repo.CreateFeed(name)
+	f, _ := server.repo.CreateFeed(ctx, name, "", nil)
 	title := "A Boring Story"
 	body := `Nikolai Stepanovich, a luminary in the world of medical science,
 tormented by insomnia and bouts of devastating weakness,
 lives in a kind of darkening haze.`
 
-	server.repo.CreateFeedArticle(f.ID, title, body)
-	u, _ := server.repo.CreateUser("alexey")
-	server.repo.AddUserFeed(u.ID, f.ID)
+	server.repo.CreateFeedArticle(ctx, f.ID, api.Article{Title: title, Body: body})
+	u, _ := server.repo.CreateUser(ctx, "alexey")
+	server.repo.AddUserFeed(ctx, u.ID, f.ID)
 
 	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/v1/users/%s/feeds/%s/articles", u.ID, f.ID), nil)
 	rr := httptest.NewRecorder()
@@ -177,3 +203,307 @@ lives in a kind of darkening haze.`
 
 	requireArticleJSON(rr)
 }
+
+func TestFeedArticleQuota(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	server := testServer()
+	f, _ := server.repo.CreateFeed(ctx, "Pushkin Poetry Hour", "", nil)
+	unlimited, _ := server.repo.CreateFeed(ctx, "Tolstoy Unabridged", "", nil)
+	config := testConfig()
+	config.Quotas = QuotaConfig{
+		FeedArticlesPerHour: 2,
+		Feeds: []FeedQuota{
+			{FeedID: unlimited.ID, ArticlesPerHour: 0},
+		},
+	}
+	server.Reload(config)
+
+	publish := func(feedID string) *httptest.ResponseRecorder {
+		jsonData := `{"title": "Winter Evening", "body": "Storm has covered sky with darkness"}`
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/v1/feeds/%s/articles", feedID), strings.NewReader(jsonData))
+		rr := httptest.NewRecorder()
+		router(server).ServeHTTP(rr, req)
+		return rr
+	}
+
+	requireStatus(http.StatusCreated, require, publish(f.ID))
+	requireStatus(http.StatusCreated, require, publish(f.ID))
+
+	rr := publish(f.ID)
+	requireStatus(http.StatusTooManyRequests, require, rr)
+	require.Equal("3600", rr.Header().Get("Retry-After"))
+	t.Logf("Error message (expected): %s", rr.Body.String())
+
+	for i := 0; i < 3; i++ {
+		requireStatus(http.StatusCreated, require, publish(unlimited.ID))
+	}
+}
+
+func TestArticleSummaries(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	server := testServer()
+	f, _ := server.repo.CreateFeed(ctx, "Tolstoy Unabridged", "", nil)
+	body := `Happy families are all alike. Every unhappy family is unhappy in its own way.
+Everything was in confusion in the Oblonskys' house. The wife had discovered that the husband was carrying on an intrigue.`
+
+	jsonData, _ := json.Marshal(api.CreateArticleRequest{Title: "Anna Karenina", Body: body})
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/v1/feeds/%s/articles", f.ID), bytes.NewReader(jsonData))
+	rr := httptest.NewRecorder()
+	router(server).ServeHTTP(rr, req)
+	requireStatus(http.StatusCreated, require, rr)
+
+	// Articles stored without a summary are listed as stored until summaries are backfilled
+	server.repo.CreateFeedArticle(ctx, f.ID, api.Article{Title: "Hadji Murat", Body: "I was returning home by the fields. It was midsummer. The hay harvest was over."})
+	server.repo.CreateFeedArticle(ctx, f.ID, api.Article{Title: "Untitled"})
+
+	list := func(view string) []api.Article {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/v1/feeds/%s/articles?view=%s", f.ID, view), nil)
+		rr := httptest.NewRecorder()
+		router(server).ServeHTTP(rr, req)
+		requireStatus(http.StatusOK, require, rr)
+		articles := []api.Article{}
+		require.NoError(json.NewDecoder(rr.Body).Decode(&articles))
+		require.Len(articles, 3)
+		return articles
+	}
+	require.Empty(list(api.ViewTLDR)[1].Summary)
+	server.backfillSummaries(ctx)
+
+	full := list(api.ViewFull)
+	require.Equal(body, full[0].Body)
+	require.NotEmpty(full[0].Summary)
+	require.True(len(full[0].Summary) < len(body))
+	require.NotEmpty(full[1].Summary)
+
+	tldr := list(api.ViewTLDR)
+	require.Empty(tldr[0].Body)
+	require.Equal(full[0].Summary, tldr[0].Summary)
+	require.Equal("I was returning home by the fields. It was midsummer.", tldr[1].Summary)
+
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/v1/feeds/%s/articles?view=abridged", f.ID), nil)
+	rr = httptest.NewRecorder()
+	router(server).ServeHTTP(rr, req)
+	requireStatus(http.StatusBadRequest, require, rr)
+}
+
+func TestCreateRichArticle(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	server := testServer()
+	ts := httptest.NewServer(router(server))
+	defer ts.Close()
+	c := api.NewClient(ts.URL)
+
+	f, err := c.CreateFeed("Tolstoy Backfill")
+	require.NoError(err)
+	published := time.Date(1877, 4, 1, 0, 0, 0, 0, time.UTC)
+	updated := published.Add(24 * time.Hour)
+	_, err = c.PublishArticle(f.ID, &api.CreateArticleRequest{
+		Title:         "Anna Karenina",
+		Body:          "Happy families are all alike.",
+		Summary:       "Trains, mostly.",
+		Author:        " Lev Tolstoy ",
+		URL:           "https://example.com/anna-karenina",
+		ImageURL:      "http://example.com/anna.png",
+		Tags:          []string{"Novels", " classics", "novels"},
+		PublishedTime: &published,
+		UpdatedTime:   &updated,
+	})
+	require.NoError(err)
+	_, err = c.CreateArticle(f.ID, "Hadji Murat", "I was returning home by the fields.")
+	require.NoError(err)
+
+	articles, err := c.ListArticles(f.ID)
+	require.NoError(err)
+	require.Len(articles, 2)
+	a := articles[0]
+	require.Equal("Trains, mostly.", a.Summary)
+	require.Equal("Lev Tolstoy", a.Author)
+	require.Equal("https://example.com/anna-karenina", a.URL)
+	require.Equal("http://example.com/anna.png", a.ImageURL)
+	require.Equal([]string{"novels", "classics"}, a.Tags)
+	require.True(published.Equal(a.PublishedTime))
+	require.True(updated.Equal(a.UpdatedTime))
+
+	// Optional fields default sensibly
+	a = articles[1]
+	require.Empty(a.Author)
+	require.Empty(a.Tags)
+	require.Equal(a.PublishedTime, a.UpdatedTime)
+	require.WithinDuration(time.Now(), a.PublishedTime, time.Minute)
+
+	// Backfilled Articles count towards quotas when added
+	count, err := server.repo.CountFeedArticles(ctx, f.ID, time.Now().Add(-time.Minute))
+	require.NoError(err)
+	require.Equal(2, count)
+}
+
+func TestCreateInvalidRichArticle(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	server := testServer()
+	f, _ := server.repo.CreateFeed(ctx, "Validation", "", nil)
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+	longTag := strings.Repeat("x", maxArticleTagLen+1)
+
+	for _, r := range []api.CreateArticleRequest{
+		{URL: "example.com/article"},
+		{URL: "ftp://example.com/article"},
+		{ImageURL: "not a url"},
+		{Author: strings.Repeat("a", maxAuthorLen+1)},
+		{Tags: []string{"ok", " "}},
+		{Tags: []string{longTag}},
+		{PublishedTime: &future},
+		{UpdatedTime: &past},
+		{BodyFormat: "rtf"},
+	} {
+		r.Title, r.Body = "Title", "Body"
+		jsonData, _ := json.Marshal(r)
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/v1/feeds/%s/articles", f.ID), bytes.NewReader(jsonData))
+		rr := httptest.NewRecorder()
+		router(server).ServeHTTP(rr, req)
+		requireStatus(http.StatusBadRequest, require, rr)
+		t.Logf("Error message (expected): %s", rr.Body.String())
+	}
+}
+
+func TestCollapseDuplicates(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	const story = `The city council approved the new budget on Monday after a six hour debate. The budget raises
+spending on public transport and road repairs, while critics say it ignores housing.`
+
+	server := testServer()
+	news, _ := server.repo.CreateFeed(ctx, "Chaikovsky Breaking News", "", nil)
+	aggregator, _ := server.repo.CreateFeed(ctx, "Everything Aggregated", "", nil)
+	user, _ := server.repo.CreateUser(ctx, "ivan")
+	require.NoError(server.repo.AddUserFeed(ctx, user.ID, news.ID))
+	require.NoError(server.repo.AddUserFeed(ctx, user.ID, aggregator.ID))
+
+	published := time.Now().Add(-time.Hour)
+	originalID, err := server.repo.CreateFeedArticle(ctx, news.ID, api.Article{Title: "Council approves budget", Body: story,
+		URL: "https://example.com/budget", PublishedTime: published})
+	require.NoError(err)
+	// Same story by URL, and by text
+	_, err = server.repo.CreateFeedArticle(ctx, aggregator.ID, api.Article{Title: "Budget passes", Body: "See the link",
+		URL: "http://www.example.com/budget?utm_source=aggregator"})
+	require.NoError(err)
+	_, err = server.repo.CreateFeedArticle(ctx, aggregator.ID, api.Article{Title: "Council approves new budget", Body: story})
+	require.NoError(err)
+	_, err = server.repo.CreateFeedArticle(ctx, aggregator.ID, api.Article{Title: "Weather", Body: "Sunny all week"})
+	require.NoError(err)
+
+	list := func(query string) []api.Article {
+		req, _ := http.NewRequest("GET", "/api/v1/users/"+user.ID+"/articles"+query, nil)
+		rr := httptest.NewRecorder()
+		router(server).ServeHTTP(rr, req)
+		requireStatus(http.StatusOK, require, rr)
+		articles := []api.Article{}
+		require.NoError(json.NewDecoder(rr.Body).Decode(&articles))
+		return articles
+	}
+
+	articles := list("")
+	require.Len(articles, 4)
+	clusters := map[string]int{}
+	for _, a := range articles {
+		clusters[a.ClusterID]++
+		require.Empty(a.Duplicates)
+	}
+	require.Equal(3, clusters[originalID])
+
+	articles = list("?collapse_duplicates=true")
+	require.Len(articles, 2)
+	for _, a := range articles {
+		if a.ID == originalID {
+			require.Equal(news.ID, a.FeedID)
+			require.Len(a.Duplicates, 2)
+			for _, d := range a.Duplicates {
+				require.Equal(aggregator.ID, d.FeedID)
+			}
+		} else {
+			require.Equal("Weather", a.Title)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "/api/v1/users/"+user.ID+"/articles?collapse_duplicates=maybe", nil)
+	rr := httptest.NewRecorder()
+	router(server).ServeHTTP(rr, req)
+	requireStatus(http.StatusBadRequest, require, rr)
+}
+
+func TestArticleBodyFormats(t *testing.T) {
+	require := require.New(t)
+
+	server := testServer()
+	ts := httptest.NewServer(router(server))
+	defer ts.Close()
+	c := api.NewClient(ts.URL)
+
+	f, err := c.CreateFeed("Formats")
+	require.NoError(err)
+	for _, r := range []api.CreateArticleRequest{
+		{Title: "Text", Body: "Plain <b>text</b>"},
+		{Title: "Markdown", Body: "Some **bold** text", BodyFormat: api.BodyMarkdown},
+		{Title: "HTML", Body: `<p onclick="steal()">Safe<script>alert(1)</script></p>`, BodyFormat: api.BodyHTML},
+	} {
+		r := r
+		_, err = c.PublishArticle(f.ID, &r)
+		require.NoError(err)
+	}
+
+	list := func(format string) []api.Article {
+		articles, err := c.ListArticlesWithOptions(f.ID, api.ArticleListOptions{BodyFormat: format})
+		require.NoError(err)
+		require.Len(articles, 3)
+		return articles
+	}
+
+	// HTML is sanitized when added, bodies are listed as added by default
+	original := list("")
+	require.Equal("Plain <b>text</b>", original[0].Body)
+	require.Empty(original[0].BodyFormat)
+	require.Equal("Some **bold** text", original[1].Body)
+	require.Equal(api.BodyMarkdown, original[1].BodyFormat)
+	require.Equal("<p>Safe</p>", original[2].Body)
+	require.Equal(api.BodyHTML, original[2].BodyFormat)
+	require.Equal("Some bold text", original[1].Summary)
+
+	html := list(api.BodyHTML)
+	require.Equal("<p>Plain &lt;b&gt;text&lt;/b&gt;</p>", html[0].Body)
+	require.Equal("<p>Some <strong>bold</strong> text</p>\n", html[1].Body)
+	require.Equal("<p>Safe</p>", html[2].Body)
+	for _, a := range html {
+		require.Equal(api.BodyHTML, a.BodyFormat)
+	}
+
+	text := list(api.BodyText)
+	require.Equal("Plain <b>text</b>", text[0].Body)
+	require.Equal("Some bold text", text[1].Body)
+	require.Equal("Safe", text[2].Body)
+	for _, a := range text {
+		require.Empty(a.BodyFormat)
+	}
+
+	// Bodies with nothing safe left are rejected
+	jsonData, _ := json.Marshal(api.CreateArticleRequest{
+		Title: "Unsafe", Body: "<script>alert(1)</script>", BodyFormat: api.BodyHTML,
+	})
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/v1/feeds/%s/articles", f.ID), bytes.NewReader(jsonData))
+	rr := httptest.NewRecorder()
+	router(server).ServeHTTP(rr, req)
+	requireStatus(http.StatusBadRequest, require, rr)
+
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/v1/feeds/%s/articles?body_format=rtf", f.ID), nil)
+	rr = httptest.NewRecorder()
+	router(server).ServeHTTP(rr, req)
+	requireStatus(http.StatusBadRequest, require, rr)
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+	"github.com/if-ivan-else/tldrfeed/internal/db/audit"
+)
+
+// Audit log paging defaults and limits
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// auditActor is a mux middleware recording the changes made by a request in the audit log as made by the request's
+// principal, along with the request's ID
+func (s *Server) auditActor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var requestID string
+		if info := requestInfoFromContext(req.Context()); info != nil {
+			requestID = info.ID
+		} else {
+			requestID = newRequestID(w, req)
+		}
+
+		actor := audit.Actor{Principal: principalFromRequest(req), RequestID: requestID}
+		next.ServeHTTP(w, req.WithContext(audit.NewContext(req.Context(), actor)))
+	})
+}
+
+// listAuditRecordsHandler lists the latest audit records matching the request's filters, oldest first. Only the
+// configured admins read the audit log.
+func (s *Server) listAuditRecordsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		config := s.currentConfig().Audit
+		if !config.Enabled {
+			s.formatter.Text(w, http.StatusNotFound, "Audit log is disabled")
+			return
+		}
+		if !config.isAdmin(principalFromRequest(req)) {
+			s.formatter.Text(w, http.StatusForbidden, "Audit log is only readable by admins")
+			return
+		}
+
+		params := req.URL.Query()
+		filter := db.AuditFilter{
+			EntityType: params.Get("entity"),
+			EntityID:   params.Get("entity_id"),
+			Principal:  params.Get("actor"),
+			Action:     params.Get("action"),
+		}
+		var err error
+		if filter.Since, err = timeParam(params.Get("since")); err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, fmt.Sprintf("Invalid since: %s", err))
+			return
+		}
+		if filter.Until, err = timeParam(params.Get("until")); err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, fmt.Sprintf("Invalid until: %s", err))
+			return
+		}
+		if filter.Limit, err = intParam(params.Get("limit"), defaultAuditLimit, 1, maxAuditLimit); err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, fmt.Sprintf("Invalid limit: %s", err))
+			return
+		}
+
+		records, err := s.repo.ListAuditRecords(req.Context(), filter)
+		if err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
+		s.formatter.JSON(w, http.StatusOK, records)
+	}
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/db/audit"
+	"github.com/if-ivan-else/tldrfeed/internal/db/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLog(t *testing.T) {
+	require := require.New(t)
+
+	rr := httptest.NewRecorder()
+	router(testServer()).ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/admin/audit", nil))
+	requireStatus(http.StatusNotFound, require, rr)
+
+	config := testConfig()
+	config.Audit.Enabled = true
+	config.Audit.Admins = []string{"ops"}
+	server := newServer(config, audit.NewRepository(mock.NewRepository()))
+
+	// Requests keep the ID they are given and get one otherwise
+	req := httptest.NewRequest("POST", "/api/v1/users", strings.NewReader(`{"name": "reader"}`))
+	req.Header.Set(api.RequestIDHeader, "req-1")
+	rr = httptest.NewRecorder()
+	router(server).ServeHTTP(rr, req)
+	requireStatus(http.StatusCreated, require, rr)
+	require.Equal("req-1", rr.Header().Get(api.RequestIDHeader))
+
+	req = httptest.NewRequest("POST", "/api/v1/feeds", strings.NewReader(`{"name": "Hot"}`))
+	req = req.WithContext(context.WithValue(req.Context(), principalKey{}, "publisher"))
+	rr = httptest.NewRecorder()
+	router(server).ServeHTTP(rr, req)
+	requireStatus(http.StatusCreated, require, rr)
+	requestID := rr.Header().Get(api.RequestIDHeader)
+	require.NotEmpty(requestID)
+
+	auditRequest := func(query string, principal string) *http.Request {
+		req := httptest.NewRequest("GET", "/api/v1/admin/audit"+query, nil)
+		return req.WithContext(context.WithValue(req.Context(), principalKey{}, principal))
+	}
+	listAudit := func(query string) []api.AuditRecord {
+		rr := httptest.NewRecorder()
+		router(server).ServeHTTP(rr, auditRequest(query, "ops"))
+		requireStatus(http.StatusOK, require, rr)
+		records := []api.AuditRecord{}
+		require.NoError(json.NewDecoder(rr.Body).Decode(&records))
+		return records
+	}
+
+	records := listAudit("")
+	require.Len(records, 2)
+	require.Equal(api.AuditUser, records[0].EntityType)
+	require.Equal(audit.Anonymous, records[0].Principal)
+	require.Equal("req-1", records[0].RequestID)
+	require.Equal(api.AuditFeed, records[1].EntityType)
+	require.Equal("publisher", records[1].Principal)
+	require.Equal(requestID, records[1].RequestID)
+
+	records = listAudit("?actor=publisher&entity=feed&action=create")
+	require.Len(records, 1)
+	require.Equal(requestID, records[0].RequestID)
+	require.Empty(listAudit("?entity=article"))
+	require.Len(listAudit("?since="+records[0].Time.Format(time.RFC3339Nano)), 1)
+	require.Len(listAudit("?until="+records[0].Time.Format(time.RFC3339Nano)), 1)
+	require.Len(listAudit("?limit=1"), 1)
+
+	for _, query := range []string{"?since=yesterday", "?until=1", "?limit=0", "?limit=1001"} {
+		rr = httptest.NewRecorder()
+		router(server).ServeHTTP(rr, auditRequest(query, "ops"))
+		requireStatus(http.StatusBadRequest, require, rr)
+	}
+
+	// Only admins read the audit log
+	for _, principal := range []string{"", "publisher"} {
+		rr = httptest.NewRecorder()
+		router(server).ServeHTTP(rr, auditRequest("", principal))
+		requireStatus(http.StatusForbidden, require, rr)
+	}
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/markup"
+)
+
+// Batch limits
+const (
+	maxBatchArticles = 1000
+	maxBatchBytes    = 32 << 20
+)
+
+// createFeedArticlesHandler adds a batch of Articles to a Feed, given as a JSON array or as NDJSON (one Article per
+// line). Every Article is validated on its own and invalid ones are reported without failing the rest of the batch.
+func (s *Server) createFeedArticlesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		vars := mux.Vars(req)
+		feedID := vars["feedID"]
+		if _, err := s.repo.GetFeed(req.Context(), feedID); err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
+
+		items, err := readBatch(http.MaxBytesReader(w, req.Body, maxBatchBytes))
+		if err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, fmt.Sprintf("Invalid batch: %s", err))
+			return
+		}
+		if len(items) == 0 {
+			s.formatter.Text(w, http.StatusBadRequest, "Batch has no Articles")
+			return
+		}
+		if len(items) > maxBatchArticles {
+			s.formatter.Text(w, http.StatusRequestEntityTooLarge,
+				fmt.Sprintf("Batch has %d Articles, at most %d can be added at once", len(items), maxBatchArticles),
+			)
+			return
+		}
+
+		remaining, err := s.remainingFeedQuota(req.Context(), feedID)
+		if err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
+
+		now := time.Now()
+		summarizer := s.currentSummarizer()
+		response := api.CreateArticlesResponse{Results: make([]api.BatchArticleResult, len(items))}
+		// positions maps the Articles to add to their positions in the batch
+		articles, positions := []api.Article{}, []int{}
+		for i, item := range items {
+			result := &response.Results[i]
+			result.Index = i
+
+			articleRequest := api.CreateArticleRequest{}
+			if err := unmarshalAndValidate(item, &articleRequest); err != nil {
+				result.Status, result.Error = http.StatusBadRequest, err.Error()
+				continue
+			}
+			article, err := newArticle(&articleRequest, now)
+			if err != nil {
+				result.Status, result.Error = http.StatusBadRequest, err.Error()
+				continue
+			}
+			if remaining >= 0 && len(articles) >= remaining {
+				result.Status, result.Error = http.StatusTooManyRequests, s.feedQuotaExceeded(feedID)
+				w.Header().Set("Retry-After", seconds(time.Hour))
+				continue
+			}
+
+			if article.Summary == "" {
+				article.Summary = summarizer.Summarize(article.Title, markup.Text(article.Body, article.BodyFormat))
+			}
+			articles = append(articles, article)
+			positions = append(positions, i)
+		}
+
+		if len(articles) > 0 {
+			// Articles are stored at once, a failure here leaves the Feed without any Article of the batch
+			ids, err := s.repo.CreateFeedArticles(req.Context(), feedID, articles)
+			if err != nil {
+				s.formatter.Text(w, errorToStatus(err), err.Error())
+				return
+			}
+			published := []api.Article{}
+			for j, id := range ids {
+				result := &response.Results[positions[j]]
+				result.ID, result.Status = id, http.StatusCreated
+				if articles[j].Status == api.StatusPublished {
+					articles[j].ID, articles[j].FeedID = id, feedID
+					published = append(published, articles[j])
+				}
+			}
+			s.notifyPublished(published...)
+		}
+
+		response.Created = len(articles)
+		response.Failed = len(items) - len(articles)
+		status := http.StatusCreated
+		if response.Failed > 0 {
+			status = http.StatusMultiStatus
+		}
+		s.formatter.JSON(w, status, response)
+	}
+}
+
+// readBatch splits a batch of Articles given as a JSON array or as NDJSON into its items, left undecoded so that
+// malformed NDJSON lines fail on their own. Blank NDJSON lines are skipped.
+func readBatch(r io.Reader) ([]json.RawMessage, error) {
+	br := bufio.NewReader(r)
+	first, err := firstNonSpace(br)
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	items := []json.RawMessage{}
+	if first == '[' {
+		if err := json.NewDecoder(br).Decode(&items); err != nil {
+			return nil, err
+		}
+		return items, nil
+	}
+
+	for {
+		line, err := br.ReadBytes('\n')
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			items = append(items, json.RawMessage(trimmed))
+		}
+		if err == io.EOF {
+			return items, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// firstNonSpace returns the first byte of r other than white space, leaving it unread
+func firstNonSpace(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return b, r.UnreadByte()
+	}
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateArticlesBatch(t *testing.T) {
+	require := require.New(t)
+
+	server := testServer()
+	ts := httptest.NewServer(router(server))
+	defer ts.Close()
+	c := api.NewClient(ts.URL)
+
+	published := []string{}
+	server.OnPublish(func(a api.Article) {
+		published = append(published, a.Title)
+	})
+
+	f, err := c.CreateFeed("Archive")
+	require.NoError(err)
+	r, err := c.CreateArticles(f.ID, []api.CreateArticleRequest{
+		{Title: "First", Body: "One."},
+		{Title: "Invalid", Body: "Two.", Status: "pending"},
+		{Title: "Second", Body: "Three.", Tags: []string{"History"}},
+		{Title: "Draft", Body: "Four.", Status: api.StatusDraft},
+	})
+	require.NoError(err)
+	require.Equal(3, r.Created)
+	require.Equal(1, r.Failed)
+	require.Len(r.Results, 4)
+	for i, status := range []int{http.StatusCreated, http.StatusBadRequest, http.StatusCreated, http.StatusCreated} {
+		require.Equal(i, r.Results[i].Index)
+		require.Equal(status, r.Results[i].Status)
+	}
+	require.NotEmpty(r.Results[0].ID)
+	require.Empty(r.Results[1].ID)
+	require.Contains(r.Results[1].Error, "pending")
+	require.Equal([]string{"First", "Second"}, published)
+
+	articles, err := c.ListArticles(f.ID)
+	require.NoError(err)
+	require.Len(articles, 2)
+	require.Equal(r.Results[2].ID, articles[1].ID)
+	require.Equal([]string{"history"}, articles[1].Tags)
+	require.NotEmpty(articles[1].Summary)
+
+	// NDJSON lines are decoded on their own, a malformed line does not fail the others
+	ndjson := `{"title": "Third", "body": "Five."}
+not json
+
+{"title": "Fourth", "body": "Six."}
+`
+	resp, err := http.Post(fmt.Sprintf("%s/api/v1/feeds/%s/articles:batch", ts.URL, f.ID), "application/x-ndjson", strings.NewReader(ndjson))
+	require.NoError(err)
+	defer resp.Body.Close()
+	require.Equal(http.StatusMultiStatus, resp.StatusCode)
+	require.NoError(json.NewDecoder(resp.Body).Decode(r))
+	require.Equal(2, r.Created)
+	require.Equal(http.StatusBadRequest, r.Results[1].Status)
+
+	articles, err = c.ListArticles(f.ID)
+	require.NoError(err)
+	require.Len(articles, 4)
+
+	r, err = c.CreateArticles(f.ID, []api.CreateArticleRequest{{Title: "Fifth", Body: "Seven."}})
+	require.NoError(err)
+	require.Equal(1, r.Created)
+	require.Zero(r.Failed)
+}
+
+func TestCreateArticlesBatchQuota(t *testing.T) {
+	require := require.New(t)
+
+	server := testServer()
+	config := testConfig()
+	config.Quotas = QuotaConfig{FeedArticlesPerHour: 3}
+	server.Reload(config)
+	ts := httptest.NewServer(router(server))
+	defer ts.Close()
+	c := api.NewClient(ts.URL)
+
+	f, err := c.CreateFeed("Archive")
+	require.NoError(err)
+	_, err = c.CreateArticle(f.ID, "First", "One.")
+	require.NoError(err)
+
+	// Articles past the quota are rejected, invalid ones do not use it up
+	r, err := c.CreateArticles(f.ID, []api.CreateArticleRequest{
+		{Title: "Second", Body: "Two."},
+		{Title: "Invalid", Body: "Three.", Status: "pending"},
+		{Title: "Third", Body: "Four."},
+		{Title: "Fourth", Body: "Five."},
+	})
+	require.NoError(err)
+	require.Equal(2, r.Created)
+	require.Equal(http.StatusTooManyRequests, r.Results[3].Status)
+	t.Logf("Error message (expected): %s", r.Results[3].Error)
+}
+
+func TestInvalidArticlesBatch(t *testing.T) {
+	require := require.New(t)
+
+	server := testServer()
+	ts := httptest.NewServer(router(server))
+	defer ts.Close()
+	c := api.NewClient(ts.URL)
+
+	f, err := c.CreateFeed("Archive")
+	require.NoError(err)
+
+	post := func(feedID string, body string) int {
+		resp, err := http.Post(fmt.Sprintf("%s/api/v1/feeds/%s/articles:batch", ts.URL, feedID), "application/json", strings.NewReader(body))
+		require.NoError(err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	require.Equal(http.StatusNotFound, post("nonexistent", `[{"title": "Title", "body": "Body"}]`))
+	require.Equal(http.StatusBadRequest, post(f.ID, ``))
+	require.Equal(http.StatusBadRequest, post(f.ID, `[]`))
+	require.Equal(http.StatusBadRequest, post(f.ID, `[{"title": "Title"`))
+
+	tooMany := "[" + strings.Repeat(`{"title": "Title", "body": "Body"},`, maxBatchArticles) + `{"title": "Title", "body": "Body"}]`
+	require.Equal(http.StatusRequestEntityTooLarge, post(f.ID, tooMany))
+
+	articles, err := c.ListArticles(f.ID)
+	require.NoError(err)
+	require.Empty(articles)
+}
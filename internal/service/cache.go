@@ -0,0 +1,16 @@
+package service
+
+import (
+	"net/http"
+)
+
+// cacheStatsHandler reports hits and misses of cached repository queries
+func (s *Server) cacheStatsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if s.cache == nil {
+			s.formatter.Text(w, http.StatusNotFound, "Repository queries are not cached")
+			return
+		}
+		s.formatter.JSON(w, http.StatusOK, s.cache.Stats())
+	}
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/db/cache"
+	"github.com/if-ivan-else/tldrfeed/internal/db/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheStats(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	req, _ := http.NewRequest("GET", "/api/v1/cache/stats", nil)
+	rr := httptest.NewRecorder()
+	router(testServer()).ServeHTTP(rr, req)
+	requireStatus(http.StatusNotFound, require, rr)
+
+	server := newServer(testConfig(), cache.NewRepository(mock.NewRepository(), cache.NewLRU(10), time.Minute))
+	f, _ := server.repo.CreateFeed(ctx, "Cached", "", nil)
+	for i := 0; i < 2; i++ {
+		rr = httptest.NewRecorder()
+		router(server).ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/feeds/"+f.ID, nil))
+		requireStatus(http.StatusOK, require, rr)
+	}
+
+	rr = httptest.NewRecorder()
+	router(server).ServeHTTP(rr, req)
+	requireStatus(http.StatusOK, require, rr)
+	var stats api.CacheStats
+	require.NoError(json.NewDecoder(rr.Body).Decode(&stats))
+	require.Equal(api.CacheQueryStats{Hits: 1, Misses: 1}, stats.Queries[cache.QueryGetFeed])
+}
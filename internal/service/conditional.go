@@ -0,0 +1,96 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+)
+
+// validators are the cache validators of a representation
+type validators struct {
+	etag         string
+	lastModified time.Time
+}
+
+// weakETag hashes the given parts into a weak entity tag, weak because equal versions may still be rendered
+// differently (e.g. with or without JSON indentation)
+func weakETag(parts ...string) string {
+	h := sha256.Sum256([]byte(strings.Join(parts, "\n")))
+	return `W/"` + hex.EncodeToString(h[:16]) + `"`
+}
+
+// feedValidators returns the validators of a Feed representation, variants (e.g. Article list views) tell apart
+// different representations of the same Feed
+func feedValidators(v *db.FeedVersion, variants ...string) validators {
+	parts := append([]string{"feed", v.FeedID, fmt.Sprint(v.Version)}, variants...)
+	return validators{
+		etag:         weakETag(parts...),
+		lastModified: v.UpdatedTime,
+	}
+}
+
+// timelineValidators returns the validators of a User's timeline representation, see feedValidators for variants
+func timelineValidators(v *db.TimelineVersion, variants ...string) validators {
+	feeds := make([]db.FeedVersion, len(v.Feeds))
+	copy(feeds, v.Feeds)
+	sort.Slice(feeds, func(i, j int) bool { return feeds[i].FeedID < feeds[j].FeedID })
+
+	parts := append([]string{"timeline", v.UserID, v.SubscriptionsUpdatedTime.UTC().Format(time.RFC3339Nano)}, variants...)
+	lastModified := v.SubscriptionsUpdatedTime
+	for _, f := range feeds {
+		parts = append(parts, f.FeedID, fmt.Sprint(f.Version))
+		if f.UpdatedTime.After(lastModified) {
+			lastModified = f.UpdatedTime
+		}
+	}
+	return validators{
+		etag:         weakETag(parts...),
+		lastModified: lastModified,
+	}
+}
+
+// checkNotModified sets the validator headers of a response and responds with 304 Not Modified, returning true,
+// when the client's cached copy is still current. If-None-Match takes precedence over If-Modified-Since.
+func checkNotModified(w http.ResponseWriter, req *http.Request, v validators) bool {
+	w.Header().Set("ETag", v.etag)
+	if !v.lastModified.IsZero() {
+		w.Header().Set("Last-Modified", v.lastModified.UTC().Format(http.TimeFormat))
+	}
+	// Caches may store responses but have to revalidate them on every use
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		if !etagMatches(inm, v.etag) {
+			return false
+		}
+	} else if ims := req.Header.Get("If-Modified-Since"); ims != "" && !v.lastModified.IsZero() {
+		since, err := http.ParseTime(ims)
+		if err != nil || v.lastModified.Truncate(time.Second).After(since) {
+			return false
+		}
+	} else {
+		return false
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches performs the weak comparison of If-None-Match against an entity tag
+func etagMatches(header string, etag string) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
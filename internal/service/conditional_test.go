@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/codegangsta/negroni"
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConditionalFeedArticles(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	server := testServer()
+	feed, err := server.repo.CreateFeed(ctx, "Conditional", "", nil)
+	require.NoError(err)
+	_, err = server.repo.CreateFeedArticle(ctx, feed.ID, api.Article{Title: "First", Body: "Body"})
+	require.NoError(err)
+
+	get := func(header string, value string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/api/v1/feeds/"+feed.ID+"/articles", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rr := httptest.NewRecorder()
+		router(server).ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("", "")
+	requireStatus(http.StatusOK, require, rr)
+	etag := rr.Header().Get("ETag")
+	lastModified := rr.Header().Get("Last-Modified")
+	require.NotEmpty(etag)
+	require.NotEmpty(lastModified)
+	require.Equal("no-cache", rr.Header().Get("Cache-Control"))
+
+	rr = get("If-None-Match", etag)
+	requireStatus(http.StatusNotModified, require, rr)
+	require.Empty(rr.Body.String())
+	rr = get("If-None-Match", `"other", `+etag)
+	requireStatus(http.StatusNotModified, require, rr)
+	rr = get("If-None-Match", "*")
+	requireStatus(http.StatusNotModified, require, rr)
+	rr = get("If-Modified-Since", lastModified)
+	requireStatus(http.StatusNotModified, require, rr)
+
+	// Publishing changes the version of the Feed
+	_, err = server.repo.CreateFeedArticle(ctx, feed.ID, api.Article{Title: "Second", Body: "Body"})
+	require.NoError(err)
+	rr = get("If-None-Match", etag)
+	requireStatus(http.StatusOK, require, rr)
+	require.NotEqual(etag, rr.Header().Get("ETag"))
+
+	rr = get("If-Modified-Since", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+	requireStatus(http.StatusOK, require, rr)
+}
+
+func TestConditionalUserArticles(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	server := testServer()
+	user, err := server.repo.CreateUser(ctx, "ivan")
+	require.NoError(err)
+	first, err := server.repo.CreateFeed(ctx, "First", "", nil)
+	require.NoError(err)
+	second, err := server.repo.CreateFeed(ctx, "Second", "", nil)
+	require.NoError(err)
+	require.NoError(server.repo.AddUserFeed(ctx, user.ID, first.ID))
+
+	get := func(etag string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/api/v1/users/"+user.ID+"/articles", nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rr := httptest.NewRecorder()
+		router(server).ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("")
+	requireStatus(http.StatusOK, require, rr)
+	etag := rr.Header().Get("ETag")
+	requireStatus(http.StatusNotModified, require, get(etag))
+
+	// Articles in Feeds the User does not follow do not change the timeline
+	_, err = server.repo.CreateFeedArticle(ctx, second.ID, api.Article{Title: "Elsewhere", Body: "Body"})
+	require.NoError(err)
+	requireStatus(http.StatusNotModified, require, get(etag))
+
+	// Following a Feed does
+	require.NoError(server.repo.AddUserFeed(ctx, user.ID, second.ID))
+	rr = get(etag)
+	requireStatus(http.StatusOK, require, rr)
+	etag = rr.Header().Get("ETag")
+
+	_, err = server.repo.CreateFeedArticle(ctx, first.ID, api.Article{Title: "News", Body: "Body"})
+	require.NoError(err)
+	requireStatus(http.StatusOK, require, get(etag))
+}
+
+func TestConditionalUnknownFeed(t *testing.T) {
+	require := require.New(t)
+
+	req, _ := http.NewRequest("GET", "/api/v1/feeds/nope", nil)
+	req.Header.Set("If-None-Match", "*")
+	rr := httptest.NewRecorder()
+	router(testServer()).ServeHTTP(rr, req)
+	requireStatus(http.StatusNotFound, require, rr)
+}
+
+func TestClientCache(t *testing.T) {
+	require := require.New(t)
+
+	server := testServer()
+	statuses := []int{}
+	n := negroni.New()
+	n.UseFunc(func(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+		next(w, req)
+		statuses = append(statuses, w.(negroni.ResponseWriter).Status())
+	})
+	n.UseHandler(router(server))
+	ts := httptest.NewServer(n)
+	defer ts.Close()
+
+	c := api.NewClient(ts.URL, api.WithCache())
+	feed, err := c.CreateFeed("Cached")
+	require.NoError(err)
+	_, err = c.CreateArticle(feed.ID, "Title", "Body")
+	require.NoError(err)
+
+	articles, err := c.ListArticles(feed.ID)
+	require.NoError(err)
+	require.Len(articles, 1)
+	articles, err = c.ListArticles(feed.ID)
+	require.NoError(err)
+	require.Len(articles, 1)
+	require.Equal([]int{http.StatusCreated, http.StatusCreated, http.StatusOK, http.StatusNotModified}, statuses)
+
+	_, err = c.CreateArticle(feed.ID, "Another", "Body")
+	require.NoError(err)
+	articles, err = c.ListArticles(feed.ID)
+	require.NoError(err)
+	require.Len(articles, 2)
+	require.Equal(http.StatusOK, statuses[len(statuses)-1])
+}
@@ -1,11 +1,590 @@
 package service
 
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/if-ivan-else/tldrfeed/internal/db/sql"
+	"github.com/if-ivan-else/tldrfeed/internal/summarize"
+	"github.com/pkg/errors"
+)
+
 // Config provides configuration for the tldrfeed service
 type Config struct {
 	// Port to bind to
-	Port int
+	Port int `mapstructure:"port" yaml:"port"`
 	// Should JSON be indented nicely
-	IndentJSON bool
-	// DB specifies DB address to connect to
-	DB string
+	IndentJSON bool `mapstructure:"indent_json" yaml:"indent_json"`
+	// DB specifies DB address to connect to: a MongoDB address, or a postgres:// or sqlite:// URL of a SQL DB
+	DB string `mapstructure:"db" yaml:"db"`
+	// DBTimeout bounds the time a request spends in the DB, requests running out of it fail with 504. 0 disables it.
+	DBTimeout time.Duration `mapstructure:"db_timeout" yaml:"db_timeout"`
+	// TLS configures HTTPS serving
+	TLS TLSConfig `mapstructure:"tls" yaml:"tls"`
+	// RateLimit configures per-client request rate limiting
+	RateLimit RateLimitConfig `mapstructure:"rate_limit" yaml:"rate_limit"`
+	// Quotas configures publishing quotas
+	Quotas QuotaConfig `mapstructure:"quotas" yaml:"quotas"`
+	// Idempotency configures replaying of responses to retried requests
+	Idempotency IdempotencyConfig `mapstructure:"idempotency" yaml:"idempotency"`
+	// Summary configures TL;DR summaries of Articles
+	Summary SummaryConfig `mapstructure:"summary" yaml:"summary"`
+	// Scheduler configures publishing of scheduled Articles
+	Scheduler SchedulerConfig `mapstructure:"scheduler" yaml:"scheduler"`
+	// Retention configures removal of Articles expired by their Feed's retention policy
+	Retention RetentionConfig `mapstructure:"retention" yaml:"retention"`
+	// Timelines configures materialized User timelines
+	Timelines TimelineConfig `mapstructure:"timelines" yaml:"timelines"`
+	// Cache configures caching of Feed and subscription queries
+	Cache CacheConfig `mapstructure:"cache" yaml:"cache"`
+	// Audit configures the audit log of changes
+	Audit AuditConfig `mapstructure:"audit" yaml:"audit"`
+	// Log configures the server's log
+	Log LogConfig `mapstructure:"log" yaml:"log"`
+	// Tracing configures OpenTelemetry tracing of requests and DB calls
+	Tracing TracingConfig `mapstructure:"tracing" yaml:"tracing"`
+	// GRPC configures the gRPC API
+	GRPC GRPCConfig `mapstructure:"grpc" yaml:"grpc"`
+	// GraphQL configures the limits of the GraphQL API
+	GraphQL GraphQLConfig `mapstructure:"graphql" yaml:"graphql"`
+}
+
+// GRPCConfig provides configuration for the gRPC API
+type GRPCConfig struct {
+	// Port of the gRPC API, served with the TLS settings of the REST API. 0 disables it.
+	Port int `mapstructure:"port" yaml:"port"`
+}
+
+// Enabled returns true when the gRPC API is served
+func (c GRPCConfig) Enabled() bool {
+	return c.Port != 0
+}
+
+// GraphQLConfig provides configuration for the GraphQL API
+type GraphQLConfig struct {
+	// MaxComplexity bounds the estimated number of fields a query resolves, queries above it are refused. 0 disables it.
+	MaxComplexity int `mapstructure:"max_complexity" yaml:"max_complexity"`
+	// MaxDepth bounds how deeply the fields of queries are nested, deeper queries are refused. 0 disables it.
+	MaxDepth int `mapstructure:"max_depth" yaml:"max_depth"`
+	// MaxOperations bounds the operations a websocket connection runs at once, further operations are refused. 0
+	// disables it.
+	MaxOperations int `mapstructure:"max_operations" yaml:"max_operations"`
+}
+
+// Log formats
+const (
+	// LogFormatText logs lines of plain text
+	LogFormatText = "text"
+	// LogFormatJSON logs a JSON object per line
+	LogFormatJSON = "json"
+)
+
+// LogConfig provides configuration for the server's log
+type LogConfig struct {
+	// Format is one of "text" or "json"
+	Format string `mapstructure:"format" yaml:"format"`
+}
+
+// Tracing exporters
+const (
+	// TracingExporterNone disables tracing
+	TracingExporterNone = "none"
+	// TracingExporterStdout writes spans to stdout
+	TracingExporterStdout = "stdout"
+	// TracingExporterOTLP sends spans to an OpenTelemetry collector over OTLP/HTTP
+	TracingExporterOTLP = "otlp"
+)
+
+// TracingConfig provides configuration for OpenTelemetry tracing
+type TracingConfig struct {
+	// Exporter is one of "none", "stdout" or "otlp"
+	Exporter string `mapstructure:"exporter" yaml:"exporter"`
+	// Endpoint is the host:port of the collector spans are sent to by the otlp exporter
+	Endpoint string `mapstructure:"endpoint" yaml:"endpoint"`
+	// Insecure sends spans to the collector over plain HTTP
+	Insecure bool `mapstructure:"insecure" yaml:"insecure"`
+	// SampleRatio is the fraction of traces sampled, requests continuing a trace follow the caller's decision
+	SampleRatio float64 `mapstructure:"sample_ratio" yaml:"sample_ratio"`
+}
+
+// Enabled returns true when spans are exported
+func (c TracingConfig) Enabled() bool {
+	return c.Exporter != "" && c.Exporter != TracingExporterNone
+}
+
+// AuditConfig provides configuration for the audit log
+type AuditConfig struct {
+	// Enabled records every change to Users, Feeds, Articles and filter rules in the audit log
+	Enabled bool `mapstructure:"enabled" yaml:"enabled"`
+	// Admins are the principals allowed to read the audit log, nobody reads it when empty
+	Admins []string `mapstructure:"admins" yaml:"admins,omitempty"`
+}
+
+// isAdmin returns true when principal may read the audit log
+func (c AuditConfig) isAdmin(principal string) bool {
+	if principal == "" {
+		return false
+	}
+	for _, admin := range c.Admins {
+		if admin == principal {
+			return true
+		}
+	}
+	return false
+}
+
+// Repository cache backends
+const (
+	// CacheBackendNone disables caching
+	CacheBackendNone = "none"
+	// CacheBackendLRU caches in the server's memory, changes made through other servers show after the TTL
+	CacheBackendLRU = "lru"
+	// CacheBackendRedis caches in a Redis server shared by all servers
+	CacheBackendRedis = "redis"
+)
+
+// CacheConfig provides configuration for caching of repository queries
+type CacheConfig struct {
+	// Backend is one of "none", "lru" or "redis"
+	Backend string `mapstructure:"backend" yaml:"backend"`
+	// Size is the number of query results held by the lru backend
+	Size int `mapstructure:"size" yaml:"size"`
+	// TTL is how long query results are cached
+	TTL time.Duration `mapstructure:"ttl" yaml:"ttl"`
+	// RedisURL is the address of the Redis server of the redis backend (e.g. redis://localhost:6379/0)
+	RedisURL string `mapstructure:"redis_url" yaml:"redis_url"`
+}
+
+// Enabled returns true when repository queries are cached
+func (c CacheConfig) Enabled() bool {
+	return c.Backend != "" && c.Backend != CacheBackendNone
+}
+
+// TimelineConfig provides configuration for materialized User timelines
+type TimelineConfig struct {
+	// FanOut copies Articles to the timelines of their Feed's subscribers as they are added, instead of querying all
+	// the Feeds a User follows when listing their timeline
+	FanOut bool `mapstructure:"fan_out" yaml:"fan_out"`
+	// MaxSubscribers is the number of subscribers above which a Feed's Articles are read on listing instead
+	MaxSubscribers int `mapstructure:"max_subscribers" yaml:"max_subscribers"`
+}
+
+// RetentionConfig provides configuration for the janitor removing expired Articles
+type RetentionConfig struct {
+	// Interval is how often the janitor runs. Articles older than their Feed's maximum age are also removed by the
+	// DB where it supports expiry, Articles beyond the maximum count only by the janitor.
+	Interval time.Duration `mapstructure:"interval" yaml:"interval"`
+}
+
+// SchedulerConfig provides configuration for publishing scheduled Articles
+type SchedulerConfig struct {
+	// Interval is how often due Articles are published. Due Articles are listed right away, but notifications of
+	// their publication and changes to Feed versions wait for the scheduler.
+	Interval time.Duration `mapstructure:"interval" yaml:"interval"`
+}
+
+// SummaryConfig provides configuration for TL;DR summaries of Articles
+type SummaryConfig struct {
+	// Strategy is one of "textrank" or "lead"
+	Strategy string `mapstructure:"strategy" yaml:"strategy"`
+	// Sentences is the maximum number of sentences in a summary
+	Sentences int `mapstructure:"sentences" yaml:"sentences"`
+}
+
+// IdempotencyConfig provides configuration for requests made with idempotency keys
+type IdempotencyConfig struct {
+	// TTL is how long responses are kept for replaying to retries
+	TTL time.Duration `mapstructure:"ttl" yaml:"ttl"`
+	// Lease is how long a key stays reserved for a request being processed, retries being refused meanwhile. Keys
+	// of requests whose response could not be stored, e.g. because the server died, are released past it.
+	Lease time.Duration `mapstructure:"lease" yaml:"lease"`
+	// MaxBodyBytes bounds the body of requests with an idempotency key, which is read whole to be hashed. Larger
+	// requests are refused with 413.
+	MaxBodyBytes int64 `mapstructure:"max_body_bytes" yaml:"max_body_bytes"`
+}
+
+// Client certificate authentication modes
+const (
+	// ClientAuthNone does not ask clients for certificates
+	ClientAuthNone = "none"
+	// ClientAuthRequest verifies client certificates when presented but lets anonymous clients through
+	ClientAuthRequest = "request"
+	// ClientAuthRequire rejects clients without a valid certificate
+	ClientAuthRequire = "require"
+)
+
+// TLSConfig provides TLS configuration for the tldrfeed service
+type TLSConfig struct {
+	// Cert is the path to the PEM encoded server certificate, TLS is enabled when set
+	Cert string `mapstructure:"cert" yaml:"cert"`
+	// Key is the path to the PEM encoded server private key
+	Key string `mapstructure:"key" yaml:"key"`
+	// ClientCA is the path to the PEM encoded CA bundle used to verify client certificates
+	ClientCA string `mapstructure:"client_ca" yaml:"client_ca"`
+	// ClientAuth is one of "none", "request" or "require"
+	ClientAuth string `mapstructure:"client_auth" yaml:"client_auth"`
+	// Principals maps client certificate subjects to principals, when empty the subject's common name is used
+	Principals []PrincipalMapping `mapstructure:"principals" yaml:"principals,omitempty"`
+	// RedirectPort is the port of a plain HTTP listener redirecting all requests to HTTPS, disabled when 0
+	RedirectPort int `mapstructure:"redirect_port" yaml:"redirect_port"`
+}
+
+// PrincipalMapping maps a client certificate subject to a principal
+type PrincipalMapping struct {
+	// Subject is either the full RFC 2253 subject (e.g. "CN=publisher,O=Acme") or just its common name
+	Subject string `mapstructure:"subject" yaml:"subject"`
+	// Principal is the name the client is known by once authenticated
+	Principal string `mapstructure:"principal" yaml:"principal"`
+}
+
+// Rate limiting client keys
+const (
+	// RateLimitKeyIP tells clients apart by their IP address
+	RateLimitKeyIP = "ip"
+	// RateLimitKeyUser tells clients apart by their principal or the User they access, falling back to IP
+	RateLimitKeyUser = "user"
+	// RateLimitKeyAPIKey tells clients apart by the API key of their X-API-Key header, falling back to principal and
+	// then IP for clients without a known API key
+	RateLimitKeyAPIKey = "api_key"
+)
+
+// RateLimitConfig provides token bucket rate limiting configuration
+type RateLimitConfig struct {
+	// Key is one of "ip", "user" or "api_key"
+	Key string `mapstructure:"key" yaml:"key"`
+	// Rate is the number of requests per second a client can sustain on a route, 0 disables rate limiting
+	Rate float64 `mapstructure:"rate" yaml:"rate"`
+	// Burst is the number of requests a client can make at once on a route
+	Burst int `mapstructure:"burst" yaml:"burst"`
+	// Routes overrides limits for individual routes
+	Routes []RouteRateLimit `mapstructure:"routes" yaml:"routes,omitempty"`
+	// APIKeys are the API keys clients are known by with the api_key key, unknown keys are ignored
+	APIKeys []APIKey `mapstructure:"api_keys" yaml:"api_keys,omitempty"`
+	// MaxClients bounds the number of client buckets kept, the least recently seen clients are forgotten beyond it. 0
+	// disables it.
+	MaxClients int `mapstructure:"max_clients" yaml:"max_clients"`
+}
+
+// APIKey maps an API key sent in the X-API-Key header to the name of a client
+type APIKey struct {
+	Key    string `mapstructure:"key" yaml:"key"`
+	Client string `mapstructure:"client" yaml:"client"`
+}
+
+// RouteRateLimit overrides rate limiting for a named route (e.g. "listUserArticles")
+type RouteRateLimit struct {
+	Route string  `mapstructure:"route" yaml:"route"`
+	Rate  float64 `mapstructure:"rate" yaml:"rate"`
+	Burst int     `mapstructure:"burst" yaml:"burst"`
+}
+
+// QuotaConfig provides publishing quota configuration
+type QuotaConfig struct {
+	// FeedArticlesPerHour is the number of Articles that can be published to a Feed per hour, 0 disables the quota
+	FeedArticlesPerHour int `mapstructure:"feed_articles_per_hour" yaml:"feed_articles_per_hour"`
+	// Feeds overrides the quota for individual Feeds
+	Feeds []FeedQuota `mapstructure:"feeds" yaml:"feeds,omitempty"`
+}
+
+// FeedQuota overrides the publishing quota of a Feed
+type FeedQuota struct {
+	FeedID          string `mapstructure:"feed_id" yaml:"feed_id"`
+	ArticlesPerHour int    `mapstructure:"articles_per_hour" yaml:"articles_per_hour"`
+}
+
+// feedArticlesPerHour returns the publishing quota of a Feed
+func (c QuotaConfig) feedArticlesPerHour(feedID string) int {
+	for _, f := range c.Feeds {
+		if f.FeedID == feedID {
+			return f.ArticlesPerHour
+		}
+	}
+	return c.FeedArticlesPerHour
+}
+
+// Enabled returns true when the server should serve HTTPS
+func (c TLSConfig) Enabled() bool {
+	return c.Cert != "" || c.Key != ""
+}
+
+// redacted replaces secrets in printed configuration
+const redacted = "REDACTED"
+
+// Redacted returns a copy of the configuration fit for printing, without the credentials of the DB and Redis URLs
+// and without API keys
+func (c Config) Redacted() Config {
+	c.DB = redactURL(c.DB)
+	c.Cache.RedisURL = redactURL(c.Cache.RedisURL)
+	if len(c.RateLimit.APIKeys) > 0 {
+		keys := make([]APIKey, len(c.RateLimit.APIKeys))
+		for i, k := range c.RateLimit.APIKeys {
+			keys[i] = APIKey{Key: redacted, Client: k.Client}
+		}
+		c.RateLimit.APIKeys = keys
+	}
+	return c
+}
+
+// redactURL replaces the user info and password parameters of a URL, or of a MongoDB address without a scheme
+func redactURL(s string) string {
+	start := 0
+	if i := strings.Index(s, "://"); i >= 0 {
+		start = i + len("://")
+	}
+	end := len(s)
+	if i := strings.IndexAny(s[start:], "/?#"); i >= 0 {
+		end = start + i
+	}
+	if at := strings.LastIndex(s[start:end], "@"); at >= 0 {
+		s = s[:start] + redacted + s[start+at:]
+	}
+
+	q := strings.Index(s, "?")
+	if q < 0 {
+		return s
+	}
+	params := strings.Split(s[q+1:], "&")
+	for i, p := range params {
+		if name := strings.SplitN(p, "=", 2)[0]; strings.Contains(strings.ToLower(name), "password") {
+			params[i] = name + "=" + redacted
+		}
+	}
+	return s[:q+1] + strings.Join(params, "&")
+}
+
+// Validate checks the configuration for errors, reporting all problems found at once
+func (c Config) Validate() error {
+	problems := []string{}
+
+	if c.Port < 1 || c.Port > 65535 {
+		problems = append(problems, fmt.Sprintf("port %d is out of range, expected a value between 1 and 65535", c.Port))
+	}
+
+	if strings.TrimSpace(c.DB) == "" {
+		problems = append(problems, "db connection URL cannot be blank")
+	}
+
+	if c.DBTimeout < 0 {
+		problems = append(problems, fmt.Sprintf("db_timeout %s cannot be negative", c.DBTimeout))
+	}
+
+	problems = append(problems, c.TLS.validate(c.Port)...)
+	problems = append(problems, c.RateLimit.validate()...)
+	problems = append(problems, c.Quotas.validate()...)
+
+	if c.Idempotency.TTL <= 0 {
+		problems = append(problems, fmt.Sprintf("idempotency.ttl %s must be positive", c.Idempotency.TTL))
+	}
+	if c.Idempotency.Lease <= 0 {
+		problems = append(problems, fmt.Sprintf("idempotency.lease %s must be positive", c.Idempotency.Lease))
+	} else if c.DBTimeout > 0 && c.Idempotency.Lease < c.DBTimeout {
+		problems = append(problems, fmt.Sprintf("idempotency.lease %s cannot be shorter than db_timeout %s",
+			c.Idempotency.Lease, c.DBTimeout))
+	}
+	if c.Idempotency.MaxBodyBytes <= 0 {
+		problems = append(problems, fmt.Sprintf("idempotency.max_body_bytes %d must be positive", c.Idempotency.MaxBodyBytes))
+	}
+
+	if c.Scheduler.Interval <= 0 {
+		problems = append(problems, fmt.Sprintf("scheduler.interval %s must be positive", c.Scheduler.Interval))
+	}
+
+	if c.Retention.Interval <= 0 {
+		problems = append(problems, fmt.Sprintf("retention.interval %s must be positive", c.Retention.Interval))
+	}
+
+	if c.Timelines.FanOut && sql.Supports(c.DB) {
+		problems = append(problems, "timelines.fan_out is only supported with MongoDB")
+	}
+
+	if c.Timelines.MaxSubscribers < 0 {
+		problems = append(problems, fmt.Sprintf("timelines.max_subscribers %d cannot be negative", c.Timelines.MaxSubscribers))
+	}
+
+	problems = append(problems, c.Cache.validate()...)
+
+	for i, admin := range c.Audit.Admins {
+		if admin == "" {
+			problems = append(problems, fmt.Sprintf("audit.admins[%d] cannot be blank", i))
+		}
+	}
+
+	switch c.Log.Format {
+	case "", LogFormatText, LogFormatJSON:
+	default:
+		problems = append(problems, fmt.Sprintf("log.format '%s' is unknown, expected one of text, json", c.Log.Format))
+	}
+
+	problems = append(problems, c.Tracing.validate()...)
+	problems = append(problems, c.GRPC.validate(c.Port, c.TLS.RedirectPort)...)
+
+	if c.GraphQL.MaxComplexity < 0 {
+		problems = append(problems, fmt.Sprintf("graphql.max_complexity %d cannot be negative", c.GraphQL.MaxComplexity))
+	}
+	if c.GraphQL.MaxDepth < 0 {
+		problems = append(problems, fmt.Sprintf("graphql.max_depth %d cannot be negative", c.GraphQL.MaxDepth))
+	}
+	if c.GraphQL.MaxOperations < 0 {
+		problems = append(problems, fmt.Sprintf("graphql.max_operations %d cannot be negative", c.GraphQL.MaxOperations))
+	}
+
+	if _, err := summarize.New(c.Summary.Strategy, c.Summary.Sentences); err != nil {
+		problems = append(problems, fmt.Sprintf("summary: %s", err))
+	}
+
+	if len(problems) > 0 {
+		return errors.Errorf("Invalid configuration:\n  * %s", strings.Join(problems, "\n  * "))
+	}
+	return nil
+}
+
+func (c TLSConfig) validate(port int) []string {
+	problems := []string{}
+
+	if c.Enabled() && (c.Cert == "" || c.Key == "") {
+		problems = append(problems, "tls.cert and tls.key must be set together")
+	}
+
+	switch c.ClientAuth {
+	case "", ClientAuthNone:
+	case ClientAuthRequest, ClientAuthRequire:
+		if c.ClientCA == "" {
+			problems = append(problems, fmt.Sprintf("tls.client_auth '%s' requires tls.client_ca", c.ClientAuth))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("tls.client_auth '%s' is unknown, expected one of none, request, require", c.ClientAuth))
+	}
+
+	if !c.Enabled() && (c.ClientCA != "" || c.RedirectPort != 0) {
+		problems = append(problems, "tls.client_ca and tls.redirect_port require tls.cert and tls.key")
+	}
+
+	if c.RedirectPort != 0 {
+		if c.RedirectPort < 0 || c.RedirectPort > 65535 {
+			problems = append(problems, fmt.Sprintf("tls.redirect_port %d is out of range, expected a value between 1 and 65535", c.RedirectPort))
+		} else if c.RedirectPort == port {
+			problems = append(problems, fmt.Sprintf("tls.redirect_port %d cannot be the same as port", c.RedirectPort))
+		}
+	}
+
+	for i, m := range c.Principals {
+		if m.Subject == "" || m.Principal == "" {
+			problems = append(problems, fmt.Sprintf("tls.principals[%d] must have both subject and principal set", i))
+		}
+	}
+	return problems
+}
+
+func (c CacheConfig) validate() []string {
+	problems := []string{}
+
+	switch c.Backend {
+	case "", CacheBackendNone:
+		return problems
+	case CacheBackendLRU:
+		if c.Size < 1 {
+			problems = append(problems, fmt.Sprintf("cache.size %d must be at least 1", c.Size))
+		}
+	case CacheBackendRedis:
+		if c.RedisURL == "" {
+			problems = append(problems, "cache.redis_url must be set for the redis backend")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("cache.backend '%s' is unknown, expected one of none, lru, redis", c.Backend))
+	}
+	if c.TTL <= 0 {
+		problems = append(problems, fmt.Sprintf("cache.ttl %s must be positive", c.TTL))
+	}
+	return problems
+}
+
+func (c TracingConfig) validate() []string {
+	problems := []string{}
+
+	switch c.Exporter {
+	case "", TracingExporterNone:
+		return problems
+	case TracingExporterStdout:
+	case TracingExporterOTLP:
+		if c.Endpoint == "" {
+			problems = append(problems, "tracing.endpoint must be set for the otlp exporter")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("tracing.exporter '%s' is unknown, expected one of none, stdout, otlp", c.Exporter))
+	}
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		problems = append(problems, fmt.Sprintf("tracing.sample_ratio %g is out of range, expected a value between 0 and 1", c.SampleRatio))
+	}
+	return problems
+}
+
+func (c RateLimitConfig) validate() []string {
+	problems := []string{}
+
+	switch c.Key {
+	case "", RateLimitKeyIP, RateLimitKeyUser:
+	case RateLimitKeyAPIKey:
+		if len(c.APIKeys) == 0 {
+			problems = append(problems, "rate_limit.key 'api_key' requires rate_limit.api_keys")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("rate_limit.key '%s' is unknown, expected one of ip, user, api_key", c.Key))
+	}
+	for i, k := range c.APIKeys {
+		if k.Key == "" || k.Client == "" {
+			problems = append(problems, fmt.Sprintf("rate_limit.api_keys[%d] must have both key and client set", i))
+		}
+	}
+	if c.MaxClients < 0 {
+		problems = append(problems, fmt.Sprintf("rate_limit.max_clients %d cannot be negative", c.MaxClients))
+	}
+
+	validateLimit := func(name string, rate float64, burst int) {
+		if rate < 0 {
+			problems = append(problems, fmt.Sprintf("%s.rate cannot be negative", name))
+		}
+		if rate > 0 && burst < 1 {
+			problems = append(problems, fmt.Sprintf("%s.burst must be at least 1 when %s.rate is set", name, name))
+		}
+	}
+	validateLimit("rate_limit", c.Rate, c.Burst)
+	for i, r := range c.Routes {
+		name := fmt.Sprintf("rate_limit.routes[%d]", i)
+		if r.Route == "" {
+			problems = append(problems, fmt.Sprintf("%s.route cannot be blank", name))
+		}
+		validateLimit(name, r.Rate, r.Burst)
+	}
+	return problems
+}
+
+func (c QuotaConfig) validate() []string {
+	problems := []string{}
+
+	if c.FeedArticlesPerHour < 0 {
+		problems = append(problems, "quotas.feed_articles_per_hour cannot be negative")
+	}
+	for i, f := range c.Feeds {
+		if f.FeedID == "" {
+			problems = append(problems, fmt.Sprintf("quotas.feeds[%d].feed_id cannot be blank", i))
+		}
+		if f.ArticlesPerHour < 0 {
+			problems = append(problems, fmt.Sprintf("quotas.feeds[%d].articles_per_hour cannot be negative", i))
+		}
+	}
+	return problems
+}
+
+func (c GRPCConfig) validate(port int, redirectPort int) []string {
+	if !c.Enabled() {
+		return nil
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		return []string{fmt.Sprintf("grpc.port %d is out of range, expected a value between 1 and 65535", c.Port)}
+	}
+	if c.Port == port || c.Port == redirectPort {
+		return []string{fmt.Sprintf("grpc.port %d is already used by the REST API", c.Port)}
+	}
+	return nil
 }
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+)
+
+// Feed discovery defaults and limits
+const (
+	defaultDiscoverLimit = 20
+	maxDiscoverLimit     = 100
+	// activityHalfLife is how long it takes a silent Feed to lose half of its score
+	activityHalfLife = 7 * 24 * time.Hour
+)
+
+// listTagsHandler returns Article tags with the number of Articles carrying them
+func (s *Server) listTagsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		tags, err := s.repo.ListTags(req.Context())
+		if err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
+
+		s.formatter.JSON(w, http.StatusOK, tags)
+	}
+}
+
+// discoverFeedsHandler suggests Feeds a User is not following yet, optionally in a category
+func (s *Server) discoverFeedsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		vars := mux.Vars(req)
+		params := req.URL.Query()
+
+		category, err := normalizeCategory(params.Get("category"))
+		if err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		limit, err := intParam(params.Get("limit"), defaultDiscoverLimit, 1, maxDiscoverLimit)
+		if err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, fmt.Sprintf("Invalid limit: %s", err))
+			return
+		}
+
+		stats, err := s.repo.DiscoverFeeds(req.Context(), vars["userID"], category)
+		if err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
+
+		suggestions := rankFeeds(stats, time.Now())
+		if len(suggestions) > limit {
+			suggestions = suggestions[:limit]
+		}
+		s.formatter.JSON(w, http.StatusOK, suggestions)
+	}
+}
+
+// rankFeeds scores Feeds by their subscribers, with diminishing returns, decayed by the time since they last
+// changed, best Feeds first
+func rankFeeds(stats []db.FeedStats, now time.Time) []api.FeedSuggestion {
+	suggestions := make([]api.FeedSuggestion, len(stats))
+	for i, f := range stats {
+		// Feeds never updated have no recent activity at all
+		age := now.Sub(f.UpdatedTime)
+		if age < 0 {
+			age = 0
+		}
+		decay := math.Exp(-float64(age) * math.Ln2 / float64(activityHalfLife))
+		suggestions[i] = api.FeedSuggestion{
+			Feed:        f.Feed,
+			Subscribers: f.Subscribers,
+			UpdatedTime: f.UpdatedTime,
+			Score:       (1 + math.Log1p(float64(f.Subscribers))) * decay,
+		}
+	}
+	// Ties go to the most popular, then alphabetically, keeping the order stable across requests
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Subscribers != b.Subscribers {
+			return a.Subscribers > b.Subscribers
+		}
+		return a.Feed.Name < b.Feed.Name
+	})
+	return suggestions
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagFiltering(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	server := testServer()
+	poetry, _ := server.repo.CreateFeed(ctx, "Pushkin Poetry Hour", "", nil)
+	prose, _ := server.repo.CreateFeed(ctx, "Tolstoy Unabridged", "", nil)
+	_, err := server.repo.CreateFeedArticle(ctx, poetry.ID, api.Article{Title: "Winter Morning", Body: "Frost and sun", Tags: []string{"verse", "classic"}})
+	require.NoError(err)
+	_, err = server.repo.CreateFeedArticle(ctx, poetry.ID, api.Article{Title: "The Prophet", Body: "Parched with spiritual thirst", Tags: []string{"verse"}})
+	require.NoError(err)
+	_, err = server.repo.CreateFeedArticle(ctx, prose.ID, api.Article{Title: "War and Peace", Body: "Well, Prince", Tags: []string{"classic"}})
+	require.NoError(err)
+
+	user, _ := server.repo.CreateUser(ctx, "ivan")
+	require.NoError(server.repo.AddUserFeed(ctx, user.ID, poetry.ID))
+	require.NoError(server.repo.AddUserFeed(ctx, user.ID, prose.ID))
+
+	list := func(path string) []api.Article {
+		req, _ := http.NewRequest("GET", "/api/v1"+path, nil)
+		rr := httptest.NewRecorder()
+		router(server).ServeHTTP(rr, req)
+		requireStatus(http.StatusOK, require, rr)
+		articles := []api.Article{}
+		require.NoError(json.NewDecoder(rr.Body).Decode(&articles))
+		return articles
+	}
+	titles := func(articles []api.Article) []string {
+		titles := []string{}
+		for _, a := range articles {
+			titles = append(titles, a.Title)
+		}
+		return titles
+	}
+
+	require.ElementsMatch([]string{"Winter Morning", "The Prophet"}, titles(list("/feeds/"+poetry.ID+"/articles?tag=verse")))
+	// Tags are matched as they are stored, lower-cased and trimmed
+	require.Equal([]string{"Winter Morning"}, titles(list("/feeds/"+poetry.ID+"/articles?tag=%20Classic")))
+	require.ElementsMatch([]string{"Winter Morning", "War and Peace"}, titles(list("/users/"+user.ID+"/articles?tag=classic")))
+	require.Equal([]string{"War and Peace"}, titles(list("/users/"+user.ID+"/feeds/"+prose.ID+"/articles?tag=classic")))
+	require.Empty(list("/users/" + user.ID + "/articles?tag=drama"))
+	require.Len(list("/users/"+user.ID+"/articles"), 3)
+
+	req, _ := http.NewRequest("GET", "/api/v1/feeds/"+poetry.ID+"/articles?tag="+strings.Repeat("x", maxArticleTagLen+1), nil)
+	rr := httptest.NewRecorder()
+	router(server).ServeHTTP(rr, req)
+	requireStatus(http.StatusBadRequest, require, rr)
+
+	// Filtered lists are cached apart from unfiltered ones
+	get := func(query string, etag string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/api/v1/feeds/"+poetry.ID+"/articles"+query, nil)
+		req.Header.Set("If-None-Match", etag)
+		rr := httptest.NewRecorder()
+		router(server).ServeHTTP(rr, req)
+		return rr
+	}
+	etag := get("", "").Header().Get("ETag")
+	requireStatus(http.StatusNotModified, require, get("", etag))
+	requireStatus(http.StatusOK, require, get("?tag=verse", etag))
+
+	req, _ = http.NewRequest("GET", "/api/v1/tags", nil)
+	rr = httptest.NewRecorder()
+	router(server).ServeHTTP(rr, req)
+	requireStatus(http.StatusOK, require, rr)
+	tags := []api.TagCount{}
+	require.NoError(json.NewDecoder(rr.Body).Decode(&tags))
+	require.Equal([]api.TagCount{{Tag: "classic", Articles: 2}, {Tag: "verse", Articles: 2}}, tags)
+}
+
+func TestFeedCategories(t *testing.T) {
+	require := require.New(t)
+
+	server := testServer()
+	create := func(name string, category string, status int) *api.Feed {
+		jsonData := fmt.Sprintf(`{"name": "%s", "category": "%s"}`, name, category)
+		req, _ := http.NewRequest("POST", "/api/v1/feeds", strings.NewReader(jsonData))
+		rr := httptest.NewRecorder()
+		router(server).ServeHTTP(rr, req)
+		requireStatus(status, require, rr)
+		feed := &api.Feed{}
+		if status == http.StatusCreated {
+			require.NoError(json.NewDecoder(rr.Body).Decode(feed))
+		}
+		return feed
+	}
+	poetry := create("Pushkin Poetry Hour", " Poetry ", http.StatusCreated)
+	require.Equal("poetry", poetry.Category)
+	create("Tolstoy Unabridged", "prose", http.StatusCreated)
+	create("Uncategorized", "", http.StatusCreated)
+	create("Long", strings.Repeat("x", maxCategoryLen+1), http.StatusBadRequest)
+
+	list := func(query string) []api.Feed {
+		req, _ := http.NewRequest("GET", "/api/v1/feeds"+query, nil)
+		rr := httptest.NewRecorder()
+		router(server).ServeHTTP(rr, req)
+		requireStatus(http.StatusOK, require, rr)
+		feeds := []api.Feed{}
+		require.NoError(json.NewDecoder(rr.Body).Decode(&feeds))
+		return feeds
+	}
+	require.Equal([]api.Feed{*poetry}, list("?category=POETRY"))
+	require.Empty(list("?category=drama"))
+	require.Len(list(""), 3)
+}
+
+func TestDiscoverFeeds(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	server := testServer()
+	popular, _ := server.repo.CreateFeed(ctx, "Popular", "news", nil)
+	quiet, _ := server.repo.CreateFeed(ctx, "Quiet", "news", nil)
+	followed, _ := server.repo.CreateFeed(ctx, "Followed", "news", nil)
+	other, _ := server.repo.CreateFeed(ctx, "Other", "sports", nil)
+
+	user, _ := server.repo.CreateUser(ctx, "ivan")
+	require.NoError(server.repo.AddUserFeed(ctx, user.ID, followed.ID))
+	for _, name := range []string{"anna", "boris"} {
+		u, _ := server.repo.CreateUser(ctx, name)
+		require.NoError(server.repo.AddUserFeed(ctx, u.ID, popular.ID))
+	}
+
+	discover := func(query string, status int) []api.FeedSuggestion {
+		req, _ := http.NewRequest("GET", "/api/v1/users/"+user.ID+"/discover"+query, nil)
+		rr := httptest.NewRecorder()
+		router(server).ServeHTTP(rr, req)
+		requireStatus(status, require, rr)
+		if status != http.StatusOK {
+			return nil
+		}
+		suggestions := []api.FeedSuggestion{}
+		require.NoError(json.NewDecoder(rr.Body).Decode(&suggestions))
+		return suggestions
+	}
+
+	suggestions := discover("?category=news", http.StatusOK)
+	require.Len(suggestions, 2)
+	require.Equal(*popular, suggestions[0].Feed)
+	require.Equal(2, suggestions[0].Subscribers)
+	require.Equal(*quiet, suggestions[1].Feed)
+	require.True(suggestions[0].Score > suggestions[1].Score)
+
+	require.Len(discover("", http.StatusOK), 3)
+	require.Equal(*popular, discover("?limit=1", http.StatusOK)[0].Feed)
+	require.Equal(*other, discover("?category=Sports", http.StatusOK)[0].Feed)
+	discover("?limit=0", http.StatusBadRequest)
+
+	req, _ := http.NewRequest("GET", "/api/v1/users/nobody/discover", nil)
+	rr := httptest.NewRecorder()
+	router(server).ServeHTTP(rr, req)
+	requireStatus(http.StatusNotFound, require, rr)
+}
+
+func TestRankFeeds(t *testing.T) {
+	require := require.New(t)
+
+	now := time.Now()
+	suggestions := rankFeeds([]db.FeedStats{
+		{Feed: api.Feed{Name: "Stale"}, Subscribers: 100, UpdatedTime: now.Add(-8 * activityHalfLife)},
+		{Feed: api.Feed{Name: "Fresh"}, Subscribers: 3, UpdatedTime: now},
+		{Feed: api.Feed{Name: "Never updated"}, Subscribers: 1000},
+		{Feed: api.Feed{Name: "Busy"}, Subscribers: 10, UpdatedTime: now.Add(-activityHalfLife)},
+	}, now)
+
+	names := []string{}
+	for _, s := range suggestions {
+		names = append(names, s.Feed.Name)
+	}
+	// Activity outweighs popularity once Feeds go silent for a few weeks
+	require.Equal([]string{"Fresh", "Busy", "Stale", "Never updated"}, names)
+	require.InDelta(1+math.Log(4), suggestions[0].Score, 1e-9)
+	require.InDelta((1+math.Log(11))/2, suggestions[1].Score, 1e-9)
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/markup"
+)
+
+// listFeedDraftsHandler returns the draft and scheduled Articles of a Feed
+func (s *Server) listFeedDraftsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		vars := mux.Vars(req)
+		drafts, err := s.repo.ListFeedDrafts(req.Context(), vars["feedID"])
+		if err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
+
+		s.formatter.JSON(w, http.StatusOK, drafts)
+	}
+}
+
+func (s *Server) getFeedDraftHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		vars := mux.Vars(req)
+		draft, err := s.repo.GetFeedDraft(req.Context(), vars["feedID"], vars["articleID"])
+		if err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
+
+		s.formatter.JSON(w, http.StatusOK, draft)
+	}
+}
+
+// updateFeedDraftHandler replaces a draft or scheduled Article, which publishes or schedules it depending on the
+// requested status
+func (s *Server) updateFeedDraftHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		articleRequest := api.CreateArticleRequest{}
+		if err := decodeAndValidate(req, &articleRequest); err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		article, err := newArticle(&articleRequest, time.Now())
+		if err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		vars := mux.Vars(req)
+		article.ID = vars["articleID"]
+		if article.Summary == "" {
+			article.Summary = s.currentSummarizer().Summarize(article.Title, markup.Text(article.Body, article.BodyFormat))
+		}
+		updated, err := s.repo.UpdateFeedDraft(req.Context(), vars["feedID"], article)
+		if err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
+		if updated.Status == api.StatusPublished {
+			s.notifyPublished(*updated)
+		}
+
+		s.formatter.JSON(w, http.StatusOK, updated)
+	}
+}
+
+func (s *Server) deleteFeedDraftHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		vars := mux.Vars(req)
+		if err := s.repo.DeleteFeedDraft(req.Context(), vars["feedID"], vars["articleID"]); err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
+
+		s.formatter.Text(w, http.StatusOK,
+			fmt.Sprintf("Successfully deleted draft '%s' of Feed '%s'", vars["articleID"], vars["feedID"]),
+		)
+	}
+}
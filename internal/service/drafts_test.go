@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDraftsAndScheduledArticles(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	server := testServer()
+	ts := httptest.NewServer(router(server))
+	defer ts.Close()
+	c := api.NewClient(ts.URL)
+
+	published := []string{}
+	server.OnPublish(func(a api.Article) {
+		published = append(published, a.Title)
+	})
+
+	f, err := c.CreateFeed("Editorial")
+	require.NoError(err)
+	_, err = c.CreateArticle(f.ID, "Live", "Published right away.")
+	require.NoError(err)
+	draft, err := c.PublishArticle(f.ID, &api.CreateArticleRequest{Title: "Draft", Body: "Work in progress.", Status: api.StatusDraft})
+	require.NoError(err)
+	publishAt := time.Now().Add(time.Hour)
+	scheduled, err := c.PublishArticle(f.ID, &api.CreateArticleRequest{Title: "Scheduled", Body: "Embargoed until noon.", PublishAt: &publishAt})
+	require.NoError(err)
+	require.Equal([]string{"Live"}, published)
+
+	titles := func() []string {
+		articles, err := c.ListArticles(f.ID)
+		require.NoError(err)
+		titles := []string{}
+		for _, a := range articles {
+			titles = append(titles, a.Title)
+		}
+		return titles
+	}
+
+	// Drafts and scheduled Articles are only listed among drafts
+	require.Equal([]string{"Live"}, titles())
+	drafts, err := c.ListDrafts(f.ID)
+	require.NoError(err)
+	require.Len(drafts, 2)
+	statuses := map[string]string{}
+	for _, d := range drafts {
+		statuses[d.Title] = d.Status
+	}
+	require.Equal(map[string]string{"Draft": api.StatusDraft, "Scheduled": api.StatusScheduled}, statuses)
+
+	d, err := c.GetDraft(f.ID, draft.ID)
+	require.NoError(err)
+	require.Equal("Work in progress.", d.Body)
+	require.True(d.PublishedTime.IsZero())
+
+	// Editing a draft keeps it hidden until it is published
+	d, err = c.UpdateDraft(f.ID, draft.ID, &api.CreateArticleRequest{Title: "Draft", Body: "Almost there.", Status: api.StatusDraft})
+	require.NoError(err)
+	require.Equal("Almost there.", d.Body)
+	require.Equal([]string{"Live"}, titles())
+
+	d, err = c.UpdateDraft(f.ID, draft.ID, &api.CreateArticleRequest{Title: "Final", Body: "Done.", Status: api.StatusPublished})
+	require.NoError(err)
+	require.Equal(api.StatusPublished, d.Status)
+	require.WithinDuration(time.Now(), d.PublishedTime, time.Minute)
+	require.Equal([]string{"Live", "Final"}, titles())
+	require.Equal([]string{"Live", "Final"}, published)
+
+	// Published Articles are no longer drafts
+	_, err = c.UpdateDraft(f.ID, draft.ID, &api.CreateArticleRequest{Title: "Again", Body: "Done.", Status: api.StatusDraft})
+	require.Error(err)
+	require.Equal(http.StatusConflict, err.(*api.Error).StatusCode)
+	_, err = c.GetDraft(f.ID, draft.ID)
+	require.Error(err)
+	require.Equal(http.StatusNotFound, err.(*api.Error).StatusCode)
+
+	// The scheduler publishes scheduled Articles once due
+	server.publishDue(ctx, time.Now())
+	require.Equal([]string{"Live", "Final"}, titles())
+	server.publishDue(ctx, publishAt)
+	require.Equal([]string{"Live", "Final", "Scheduled"}, published)
+	articles, err := c.ListArticles(f.ID)
+	require.NoError(err)
+	require.Len(articles, 3)
+	require.Equal(scheduled.ID, articles[2].ID)
+	require.Equal(api.StatusPublished, articles[2].Status)
+	require.True(publishAt.Equal(articles[2].PublishedTime))
+	server.publishDue(ctx, publishAt)
+	require.Len(published, 3)
+
+	drafts, err = c.ListDrafts(f.ID)
+	require.NoError(err)
+	require.Empty(drafts)
+
+	// Discarded drafts are gone
+	draft, err = c.PublishArticle(f.ID, &api.CreateArticleRequest{Title: "Scrapped", Body: "Never mind.", Status: api.StatusDraft})
+	require.NoError(err)
+	require.NoError(c.DeleteDraft(f.ID, draft.ID))
+	err = c.DeleteDraft(f.ID, draft.ID)
+	require.Error(err)
+	require.Equal(http.StatusNotFound, err.(*api.Error).StatusCode)
+}
+
+func TestInvalidDrafts(t *testing.T) {
+	require := require.New(t)
+
+	server := testServer()
+	ts := httptest.NewServer(router(server))
+	defer ts.Close()
+	c := api.NewClient(ts.URL)
+
+	f, err := c.CreateFeed("Editorial")
+	require.NoError(err)
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	for _, r := range []api.CreateArticleRequest{
+		{Status: "pending"},
+		{Status: api.StatusScheduled},
+		{PublishAt: &past},
+		{Status: api.StatusPublished, PublishAt: &future},
+		{PublishAt: &future, PublishedTime: &past},
+		{Status: api.StatusDraft, PublishAt: &future},
+		{Status: api.StatusDraft, PublishedTime: &past},
+	} {
+		r.Title, r.Body = "Title", "Body"
+		_, err := c.PublishArticle(f.ID, &r)
+		require.Error(err)
+		require.Equal(http.StatusBadRequest, err.(*api.Error).StatusCode)
+		t.Logf("Error message (expected): %s", err)
+	}
+}
@@ -1,11 +1,14 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
 )
 
 // createFeedHandler creates a new Feed
@@ -17,7 +20,18 @@ func (s *Server) createFeedHandler() http.HandlerFunc {
 			return
 		}
 
-		feed, err := s.repo.CreateFeed(feedRequest.Name)
+		category, err := normalizeCategory(feedRequest.Category)
+		if err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		retention, err := normalizeRetention(feedRequest.Retention)
+		if err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		feed, err := s.createFeed(req.Context(), feedRequest.Name, category, retention)
 		if err != nil {
 			s.formatter.Text(w, errorToStatus(err), err.Error())
 			return
@@ -27,10 +41,21 @@ func (s *Server) createFeedHandler() http.HandlerFunc {
 	}
 }
 
-// getFeedListHandler returns the entire list of Feeds available for subscription
+// createFeed creates a Feed with a normalized category and retention policy
+func (s *Server) createFeed(ctx context.Context, name string, category string, retention *api.RetentionPolicy) (*api.Feed, error) {
+	return s.repo.CreateFeed(ctx, name, category, retention)
+}
+
+// getFeedListHandler returns the entire list of Feeds available for subscription, optionally in a category
 func (s *Server) getFeedListHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-		feeds, err := s.repo.ListFeeds()
+		category, err := normalizeCategory(req.URL.Query().Get("category"))
+		if err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		feeds, err := s.repo.ListFeeds(req.Context(), db.FeedFilter{Category: category})
 		if err != nil {
 			s.formatter.Text(w, errorToStatus(err), err.Error())
 			return
@@ -44,7 +69,17 @@ func (s *Server) getFeedHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		vars := mux.Vars(req)
 
-		feed, err := s.repo.GetFeed(vars["feedID"])
+		// The version is read before the data so that a concurrent change can only make the validators stale
+		version, err := s.repo.GetFeedVersion(req.Context(), vars["feedID"])
+		if err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
+		if checkNotModified(w, req, feedValidators(version)) {
+			return
+		}
+
+		feed, err := s.repo.GetFeed(req.Context(), vars["feedID"])
 		if err != nil {
 			s.formatter.Text(w, errorToStatus(err), err.Error())
 			return
@@ -58,7 +93,7 @@ func (s *Server) getFeedHandler() http.HandlerFunc {
 func (s *Server) getUserFeedListHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		vars := mux.Vars(req)
-		feeds, err := s.repo.ListUserFeeds(vars["userID"])
+		feeds, err := s.repo.ListUserFeeds(req.Context(), vars["userID"])
 		if err != nil {
 			s.formatter.Text(w, errorToStatus(err), err.Error())
 			return
@@ -71,7 +106,7 @@ func (s *Server) getUserFeedListHandler() http.HandlerFunc {
 func (s *Server) getUserFeedHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		vars := mux.Vars(req)
-		feeds, err := s.repo.GetUserFeed(vars["userID"], vars["feedID"])
+		feeds, err := s.repo.GetUserFeed(req.Context(), vars["userID"], vars["feedID"])
 		if err != nil {
 			s.formatter.Text(w, errorToStatus(err), err.Error())
 			return
@@ -90,7 +125,7 @@ func (s *Server) addUserFeedHandler() http.HandlerFunc {
 			s.formatter.Text(w, http.StatusBadRequest, err.Error())
 			return
 		}
-		err := s.repo.AddUserFeed(vars["userID"], addFeedRequest.FeedID)
+		err := s.repo.AddUserFeed(req.Context(), vars["userID"], addFeedRequest.FeedID)
 		if err != nil {
 			s.formatter.Text(w, errorToStatus(err), err.Error())
 			return
@@ -100,3 +135,29 @@ func (s *Server) addUserFeedHandler() http.HandlerFunc {
 		)
 	}
 }
+
+// removeUserFeedHandler unsubscribes a User from a Feed
+func (s *Server) removeUserFeedHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		vars := mux.Vars(req)
+		if err := s.repo.RemoveUserFeed(req.Context(), vars["userID"], vars["feedID"]); err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
+		s.formatter.Text(w, http.StatusOK,
+			fmt.Sprintf("Successfully unsubscribed User '%s' from Feed '%s'", vars["userID"], vars["feedID"]),
+		)
+	}
+}
+
+// maxCategoryLen limits the length of Feed categories
+const maxCategoryLen = 50
+
+// normalizeCategory lower-cases and trims an optional Feed category
+func normalizeCategory(category string) (string, error) {
+	category = strings.ToLower(strings.TrimSpace(category))
+	if len([]rune(category)) > maxCategoryLen {
+		return "", fmt.Errorf("Feed category '%s' is longer than %d characters", category, maxCategoryLen)
+	}
+	return category, nil
+}
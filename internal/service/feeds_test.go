@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -9,6 +10,8 @@ import (
 	"testing"
 
 	"github.com/google/uuid"
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
 	"github.com/stretchr/testify/require"
 )
 
@@ -63,11 +66,12 @@ func TestCreateValidFeed(t *testing.T) {
 }
 
 func TestListFeeds(t *testing.T) {
+	ctx := context.Background()
 	require := require.New(t)
 
 	server := testServer()
 	name := "Anton Chekhov News"
-	server.repo.CreateFeed(name)
+	server.repo.CreateFeed(ctx, name, "", nil)
 
 	req, _ := http.NewRequest("GET", "/api/v1/feeds", nil)
 	rr := httptest.NewRecorder()
@@ -97,11 +101,12 @@ func TestGetUnknownFeed(t *testing.T) {
 }
 
 func TestGetFeed(t *testing.T) {
+	ctx := context.Background()
 	require := require.New(t)
 
 	server := testServer()
 	name := "N.V. Gogol's Personal Blog"
-	f, _ := server.repo.CreateFeed(name)
+	f, _ := server.repo.CreateFeed(ctx, name, "", nil)
 
 	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/v1/feeds/%s", f.ID), nil)
 	rr := httptest.NewRecorder()
@@ -130,12 +135,13 @@ func TestGetUnknownUserFeedList(t *testing.T) {
 }
 
 func TestGetUserFeedList(t *testing.T) {
+	ctx := context.Background()
 	require := require.New(t)
 
 	server := testServer()
-	u, _ := server.repo.CreateUser("victor")
-	f, _ := server.repo.CreateFeed("Dostoevsky Daily")
-	_ = server.repo.AddUserFeed(u.ID, f.ID)
+	u, _ := server.repo.CreateUser(ctx, "victor")
+	f, _ := server.repo.CreateFeed(ctx, "Dostoevsky Daily", "", nil)
+	_ = server.repo.AddUserFeed(ctx, u.ID, f.ID)
 
 	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/v1/users/%s/feeds", u.ID), nil)
 	rr := httptest.NewRecorder()
@@ -152,10 +158,11 @@ func TestGetUserFeedList(t *testing.T) {
 }
 
 func TestGetUserUnknownFeed(t *testing.T) {
+	ctx := context.Background()
 	require := require.New(t)
 
 	server := testServer()
-	u, _ := server.repo.CreateUser("victor")
+	u, _ := server.repo.CreateUser(ctx, "victor")
 
 	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/v1/users/%s/feeds/%s", u.ID, uuid.New().String()), nil)
 	rr := httptest.NewRecorder()
@@ -166,12 +173,13 @@ func TestGetUserUnknownFeed(t *testing.T) {
 }
 
 func TestGetUserFeed(t *testing.T) {
+	ctx := context.Background()
 	require := require.New(t)
 
 	server := testServer()
-	u, _ := server.repo.CreateUser("olga")
-	f, _ := server.repo.CreateFeed("Rakhmaninov Folk Fairy Tales")
-	_ = server.repo.AddUserFeed(u.ID, f.ID)
+	u, _ := server.repo.CreateUser(ctx, "olga")
+	f, _ := server.repo.CreateFeed(ctx, "Rakhmaninov Folk Fairy Tales", "", nil)
+	_ = server.repo.AddUserFeed(ctx, u.ID, f.ID)
 
 	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/v1/users/%s/feeds/%s", u.ID, f.ID), nil)
 	rr := httptest.NewRecorder()
@@ -187,10 +195,11 @@ func TestGetUserFeed(t *testing.T) {
 }
 
 func TestAddUnknownUserFeed(t *testing.T) {
+	ctx := context.Background()
 	require := require.New(t)
 
 	server := testServer()
-	f, _ := server.repo.CreateFeed("Chaikovsky Breaking News")
+	f, _ := server.repo.CreateFeed(ctx, "Chaikovsky Breaking News", "", nil)
 
 	jsonData := fmt.Sprintf(`{
     "feed_id" : "%s"
@@ -205,10 +214,11 @@ func TestAddUnknownUserFeed(t *testing.T) {
 }
 
 func TestAddUserUnknownFeed(t *testing.T) {
+	ctx := context.Background()
 	require := require.New(t)
 
 	server := testServer()
-	u, _ := server.repo.CreateUser("alexandra")
+	u, _ := server.repo.CreateUser(ctx, "alexandra")
 	feedID := uuid.New().String()
 
 	jsonData := fmt.Sprintf(`{
@@ -224,10 +234,11 @@ func TestAddUserUnknownFeed(t *testing.T) {
 }
 
 func TestAddUserFeedBadRequest(t *testing.T) {
+	ctx := context.Background()
 	require := require.New(t)
 
 	server := testServer()
-	u, _ := server.repo.CreateUser("alexandra")
+	u, _ := server.repo.CreateUser(ctx, "alexandra")
 
 	jsonData := "not json"
 
@@ -240,10 +251,11 @@ func TestAddUserFeedBadRequest(t *testing.T) {
 }
 
 func TestAddUserFeedNoID(t *testing.T) {
+	ctx := context.Background()
 	require := require.New(t)
 
 	server := testServer()
-	u, _ := server.repo.CreateUser("alexandra")
+	u, _ := server.repo.CreateUser(ctx, "alexandra")
 
 	jsonData := "{}"
 
@@ -256,11 +268,12 @@ func TestAddUserFeedNoID(t *testing.T) {
 }
 
 func TestAddUserFeed(t *testing.T) {
+	ctx := context.Background()
 	require := require.New(t)
 
 	server := testServer()
-	u, _ := server.repo.CreateUser("alexandra")
-	f, _ := server.repo.CreateFeed("Chaikovsky Breaking News")
+	u, _ := server.repo.CreateUser(ctx, "alexandra")
+	f, _ := server.repo.CreateFeed(ctx, "Chaikovsky Breaking News", "", nil)
 
 	jsonData := fmt.Sprintf(`{
     "feed_id" : "%s"
@@ -273,3 +286,31 @@ func TestAddUserFeed(t *testing.T) {
 	requireStatus(http.StatusAccepted, require, rr)
 	t.Logf("Error message (expected): %s", rr.Body.String())
 }
+
+func TestRemoveUserFeed(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	server := testServer()
+	u, _ := server.repo.CreateUser(ctx, "alexandra")
+	f, _ := server.repo.CreateFeed(ctx, "Chaikovsky Breaking News", "", nil)
+	_ = server.repo.AddUserFeed(ctx, u.ID, f.ID)
+	_, _ = server.repo.CreateFeedArticle(ctx, f.ID, api.Article{Title: "Premiere", Body: "Tonight"})
+
+	req, _ := http.NewRequest("DELETE", fmt.Sprintf("/api/v1/users/%s/feeds/%s", u.ID, f.ID), nil)
+	rr := httptest.NewRecorder()
+	router(server).ServeHTTP(rr, req)
+	requireStatus(http.StatusOK, require, rr)
+
+	feeds, err := server.repo.ListUserFeeds(ctx, u.ID)
+	require.NoError(err)
+	require.Empty(feeds)
+	articles, err := server.repo.ListUserArticles(ctx, u.ID, db.ArticleFilter{})
+	require.NoError(err)
+	require.Empty(articles)
+
+	// Unsubscribing twice fails
+	rr = httptest.NewRecorder()
+	router(server).ServeHTTP(rr, req)
+	requireStatus(http.StatusNotFound, require, rr)
+}
@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+	"github.com/if-ivan-else/tldrfeed/internal/rules"
+)
+
+// Filter rule limits
+const (
+	maxFilterRules = 100
+	// defaultPreviewLimit is the number of recent Articles filter rule previews are tried on by default
+	defaultPreviewLimit = 100
+	maxPreviewLimit     = 1000
+)
+
+// listFilterRulesHandler returns a User's filter rules
+func (s *Server) listFilterRulesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		vars := mux.Vars(req)
+		filterRules, err := s.repo.ListFilterRules(req.Context(), vars["userID"])
+		if err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
+
+		s.formatter.JSON(w, http.StatusOK, filterRules)
+	}
+}
+
+// createFilterRuleHandler adds a filter rule to a User's timeline
+func (s *Server) createFilterRuleHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		vars := mux.Vars(req)
+		rule, err := decodeFilterRule(req)
+		if err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		existing, err := s.repo.ListFilterRules(req.Context(), vars["userID"])
+		if err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
+		if len(existing) >= maxFilterRules {
+			s.formatter.Text(w, http.StatusBadRequest,
+				fmt.Sprintf("User '%s' cannot have more than %d filter rules", vars["userID"], maxFilterRules),
+			)
+			return
+		}
+
+		created, err := s.repo.CreateFilterRule(req.Context(), vars["userID"], rule)
+		if err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
+
+		s.formatter.JSON(w, http.StatusCreated, created)
+	}
+}
+
+func (s *Server) getFilterRuleHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		vars := mux.Vars(req)
+		rule, err := s.repo.GetFilterRule(req.Context(), vars["userID"], vars["ruleID"])
+		if err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
+
+		s.formatter.JSON(w, http.StatusOK, rule)
+	}
+}
+
+// updateFilterRuleHandler replaces a User's filter rule
+func (s *Server) updateFilterRuleHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		vars := mux.Vars(req)
+		rule, err := decodeFilterRule(req)
+		if err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		rule.ID = vars["ruleID"]
+		if err := s.repo.UpdateFilterRule(req.Context(), vars["userID"], rule); err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
+		updated, err := s.repo.GetFilterRule(req.Context(), vars["userID"], rule.ID)
+		if err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
+
+		s.formatter.JSON(w, http.StatusOK, updated)
+	}
+}
+
+func (s *Server) deleteFilterRuleHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		vars := mux.Vars(req)
+		if err := s.repo.DeleteFilterRule(req.Context(), vars["userID"], vars["ruleID"]); err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
+
+		s.formatter.Text(w, http.StatusOK,
+			fmt.Sprintf("Successfully deleted filter rule '%s' of User '%s'", vars["ruleID"], vars["userID"]),
+		)
+	}
+}
+
+// previewFilterRuleHandler shows which of a User's recent Articles a filter rule would hide, without saving it
+func (s *Server) previewFilterRuleHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		vars := mux.Vars(req)
+		rule, err := decodeFilterRule(req)
+		if err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		limit, err := intParam(req.URL.Query().Get("limit"), defaultPreviewLimit, 1, maxPreviewLimit)
+		if err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, fmt.Sprintf("Invalid limit: %s", err))
+			return
+		}
+
+		existing, err := s.repo.ListFilterRules(req.Context(), vars["userID"])
+		if err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
+		current, err := rules.Compile(existing)
+		if err != nil {
+			s.formatter.Text(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		proposed, err := rules.Compile(append(existing, rule))
+		if err != nil {
+			s.formatter.Text(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		recent, err := s.recentUserArticles(req.Context(), vars["userID"], limit)
+		if err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
+
+		hidden, revealed := []api.Article{}, []api.Article{}
+		for _, a := range recent {
+			before, after := current.Hides(a.feedID, &a.Article), proposed.Hides(a.feedID, &a.Article)
+			switch {
+			case after && !before:
+				hidden = append(hidden, a.Article)
+			case before && !after:
+				revealed = append(revealed, a.Article)
+			}
+		}
+
+		s.formatter.JSON(w, http.StatusOK, api.FilterPreview{
+			Rule:     rule,
+			Checked:  len(recent),
+			Hidden:   s.presentArticles(hidden, api.ViewTLDR, api.BodyOriginal),
+			Revealed: s.presentArticles(revealed, api.ViewTLDR, api.BodyOriginal),
+		})
+	}
+}
+
+// feedArticle is an Article along with the Feed it was published in
+type feedArticle struct {
+	api.Article
+	feedID string
+}
+
+// recentUserArticles returns up to limit of the latest unfiltered Articles of the Feeds a User is following
+func (s *Server) recentUserArticles(ctx context.Context, userID string, limit int) ([]feedArticle, error) {
+	feeds, err := s.repo.ListUserFeeds(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	recent := []feedArticle{}
+	for _, f := range feeds {
+		articles, err := s.repo.ListUserFeedArticles(ctx, userID, f.ID, db.ArticleFilter{})
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range articles {
+			recent = append(recent, feedArticle{Article: a, feedID: f.ID})
+		}
+	}
+
+	sort.SliceStable(recent, func(i, j int) bool { return recent[i].PublishedTime.After(recent[j].PublishedTime) })
+	if len(recent) > limit {
+		recent = recent[:limit]
+	}
+	return recent, nil
+}
+
+// decodeFilterRule decodes and validates a filter rule request
+func decodeFilterRule(req *http.Request) (api.FilterRule, error) {
+	ruleRequest := api.FilterRuleRequest{}
+	if err := decodeAndValidate(req, &ruleRequest); err != nil {
+		return api.FilterRule{}, err
+	}
+	return rules.Normalize(api.FilterRule{
+		Type:   ruleRequest.Type,
+		Value:  ruleRequest.Value,
+		Action: ruleRequest.Action,
+	})
+}
+
+// userRules returns the compiled filter rules of a User along with a digest telling apart timelines filtered
+// with different rules
+func (s *Server) userRules(ctx context.Context, userID string) (*rules.Set, string, error) {
+	filterRules, err := s.repo.ListFilterRules(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	set, err := rules.Compile(filterRules)
+	if err != nil {
+		return nil, "", err
+	}
+
+	parts := []string{}
+	for _, r := range filterRules {
+		parts = append(parts, strings.Join([]string{r.ID, r.Type, r.Action, r.Value}, ":"))
+	}
+	return set, weakETag(parts...), nil
+}
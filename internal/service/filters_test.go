@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterRules(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	server := testServer()
+	user, _ := server.repo.CreateUser(ctx, "ivan")
+	news, _ := server.repo.CreateFeed(ctx, "Chaikovsky Breaking News", "", nil)
+	sports, _ := server.repo.CreateFeed(ctx, "Rakhmaninov Sports", "", nil)
+	require.NoError(server.repo.AddUserFeed(ctx, user.ID, news.ID))
+	require.NoError(server.repo.AddUserFeed(ctx, user.ID, sports.ID))
+	_, err := server.repo.CreateFeedArticle(ctx, news.ID, api.Article{Title: "Election Night", Body: "Polls close at eight", Tags: []string{"politics"}})
+	require.NoError(err)
+	_, err = server.repo.CreateFeedArticle(ctx, news.ID, api.Article{Title: "Local election results", Body: "The mayor keeps her seat", Tags: []string{"local"}})
+	require.NoError(err)
+	_, err = server.repo.CreateFeedArticle(ctx, sports.ID, api.Article{Title: "Cup final", Body: "Highlights of the match"})
+	require.NoError(err)
+
+	do := func(method string, path string, body string, status int) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, "/api/v1/users/"+user.ID+path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		router(server).ServeHTTP(rr, req)
+		requireStatus(status, require, rr)
+		return rr
+	}
+	titles := func(path string) []string {
+		articles := []api.Article{}
+		require.NoError(json.NewDecoder(do("GET", path, "", http.StatusOK).Body).Decode(&articles))
+		titles := []string{}
+		for _, a := range articles {
+			titles = append(titles, a.Title)
+		}
+		return titles
+	}
+
+	rr := do("POST", "/filters", `{"type": "keyword", "value": " Election "}`, http.StatusCreated)
+	rule := api.FilterRule{}
+	require.NoError(json.NewDecoder(rr.Body).Decode(&rule))
+	require.NotEmpty(rule.ID)
+	require.Equal("election", rule.Value)
+	require.Equal(api.FilterExclude, rule.Action)
+
+	require.Equal([]string{"Cup final"}, titles("/articles"))
+	require.Empty(titles("/feeds/" + news.ID + "/articles"))
+
+	// The preview shows what a rule would change without saving it
+	rr = do("POST", "/filters/preview", `{"type": "tag", "value": "local", "action": "include"}`, http.StatusOK)
+	preview := api.FilterPreview{}
+	require.NoError(json.NewDecoder(rr.Body).Decode(&preview))
+	require.Equal(3, preview.Checked)
+	require.Empty(preview.Hidden)
+	require.Len(preview.Revealed, 1)
+	require.Equal("Local election results", preview.Revealed[0].Title)
+	require.Empty(preview.Revealed[0].Body)
+
+	rr = do("POST", "/filters/preview?limit=1", `{"type": "feed", "value": "`+sports.ID+`"}`, http.StatusOK)
+	preview = api.FilterPreview{}
+	require.NoError(json.NewDecoder(rr.Body).Decode(&preview))
+	require.Equal(1, preview.Checked)
+	require.Len(preview.Hidden, 1)
+	require.Equal("Cup final", preview.Hidden[0].Title)
+
+	rules := []api.FilterRule{}
+	require.NoError(json.NewDecoder(do("GET", "/filters", "", http.StatusOK).Body).Decode(&rules))
+	require.Len(rules, 1)
+
+	// Changing rules changes the timeline's validators
+	etag := do("GET", "/articles", "", http.StatusOK).Header().Get("ETag")
+	do("PUT", "/filters/"+rule.ID, `{"type": "keyword", "value": "night"}`, http.StatusOK)
+	require.NotEqual(etag, do("GET", "/articles", "", http.StatusOK).Header().Get("ETag"))
+	require.ElementsMatch([]string{"Local election results", "Cup final"}, titles("/articles"))
+
+	do("DELETE", "/filters/"+rule.ID, "", http.StatusOK)
+	do("GET", "/filters/"+rule.ID, "", http.StatusNotFound)
+	do("DELETE", "/filters/"+rule.ID, "", http.StatusNotFound)
+	require.Len(titles("/articles"), 3)
+
+	do("POST", "/filters", `{"type": "regex", "value": "(unclosed"}`, http.StatusBadRequest)
+	do("POST", "/filters", `{"type": "mood", "value": "grumpy"}`, http.StatusBadRequest)
+	do("POST", "/filters/preview?limit=0", `{"type": "tag", "value": "local"}`, http.StatusBadRequest)
+
+	req, _ := http.NewRequest("GET", "/api/v1/users/nobody/filters", nil)
+	rr = httptest.NewRecorder()
+	router(server).ServeHTTP(rr, req)
+	requireStatus(http.StatusNotFound, require, rr)
+}
+
+func TestFilterRuleLimit(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	server := testServer()
+	user, _ := server.repo.CreateUser(ctx, "ivan")
+	for i := 0; i < maxFilterRules; i++ {
+		_, err := server.repo.CreateFilterRule(ctx, user.ID, api.FilterRule{Type: api.FilterTag, Value: "spam", Action: api.FilterExclude})
+		require.NoError(err)
+	}
+
+	req, _ := http.NewRequest("POST", "/api/v1/users/"+user.ID+"/filters", strings.NewReader(`{"type": "tag", "value": "more"}`))
+	rr := httptest.NewRecorder()
+	router(server).ServeHTTP(rr, req)
+	requireStatus(http.StatusBadRequest, require, rr)
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"io"
+	"sync"
+
+	"github.com/unrolled/render"
+)
+
+// formatter renders API responses, allowing its options to be swapped while the server is running
+type formatter struct {
+	mu sync.RWMutex
+	r  *render.Render
+}
+
+func newFormatter(indentJSON bool) *formatter {
+	f := &formatter{}
+	f.setIndentJSON(indentJSON)
+	return f
+}
+
+func (f *formatter) setIndentJSON(indentJSON bool) {
+	r := render.New(
+		render.Options{
+			IndentJSON: indentJSON,
+		},
+	)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.r = r
+}
+
+func (f *formatter) render() *render.Render {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.r
+}
+
+// JSON renders v as a JSON response with the given status
+func (f *formatter) JSON(w io.Writer, status int, v interface{}) error {
+	return f.render().JSON(w, status, v)
+}
+
+// Text renders v as a plain text response with the given status
+func (f *formatter) Text(w io.Writer, status int, v string) error {
+	return f.render().Text(w, status, v)
+}
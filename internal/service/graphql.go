@@ -0,0 +1,872 @@
+package service
+
+import (
+	"context"
+	_ "embed" // schema.graphql
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	graphql "github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/dataloader"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+	"github.com/if-ivan-else/tldrfeed/internal/dedup"
+	"github.com/if-ivan-else/tldrfeed/internal/querycost"
+	"github.com/pkg/errors"
+)
+
+// graphqlSchema is the schema of the GraphQL API, in the GraphQL schema language
+//
+//go:embed schema.graphql
+var graphqlSchema string
+
+const (
+	// defaultPageSize is the number of items of the pages of lists, as set by the first argument defaults of the
+	// schema, and the number of items expected of lists without one when estimating the complexity of queries
+	defaultPageSize = 20
+	// maxPageSize bounds the first argument of lists
+	maxPageSize = 100
+
+	// loaderWait is how long loaders wait for the loads of sibling fields to batch them
+	loaderWait = time.Millisecond
+	// graphqlParallelism bounds the fields resolved concurrently in an operation, it lets the items of a page of
+	// the largest size batch their loads at once
+	graphqlParallelism = maxPageSize
+	// graphqlEventTimeout bounds the time spent resolving and sending an event of a subscription
+	graphqlEventTimeout = 10 * time.Second
+)
+
+// newGraphQL parses the schema of the GraphQL API with its resolvers and sets up the estimation of the cost of its
+// queries
+func (s *Server) newGraphQL() (*graphql.Schema, *querycost.Estimator) {
+	schema, err := graphql.ParseSchema(graphqlSchema, &graphqlResolver{s: s},
+		graphql.UseStringDescriptions(),
+		graphql.MaxParallelism(graphqlParallelism),
+		graphql.SubscribeResolverTimeout(graphqlEventTimeout),
+	)
+	if err != nil {
+		log.Fatalf("Failed to parse GraphQL schema: %s", err)
+	}
+	estimator, err := querycost.NewEstimator(graphqlSchema, defaultPageSize)
+	if err != nil {
+		log.Fatalf("Failed to parse GraphQL schema: %s", err)
+	}
+	return schema, estimator
+}
+
+// graphqlHandler runs GraphQL operations given as the JSON body of POST requests or the query parameters of GET
+// requests, and serves subscriptions over websocket connections. Operations that could be run respond with 200, any
+// error being reported in the response.
+func (s *Server) graphqlHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if websocket.IsWebSocketUpgrade(req) {
+			s.serveGraphQLWS(w, req)
+			return
+		}
+
+		graphqlRequest := api.GraphQLRequest{}
+		if req.Method == http.MethodGet {
+			q := req.URL.Query()
+			graphqlRequest.Query = q.Get("query")
+			graphqlRequest.OperationName = q.Get("operationName")
+			if variables := q.Get("variables"); variables != "" {
+				if err := json.Unmarshal([]byte(variables), &graphqlRequest.Variables); err != nil {
+					s.formatter.Text(w, http.StatusBadRequest, fmt.Sprintf("Invalid variables: %s", err))
+					return
+				}
+			}
+			if err := validate(&graphqlRequest); err != nil {
+				s.formatter.Text(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		} else if err := decodeAndValidate(req, &graphqlRequest); err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		cost, errs := s.checkGraphQL(graphqlRequest)
+		if len(errs) > 0 {
+			s.formatter.JSON(w, http.StatusOK, &graphql.Response{Errors: errs})
+			return
+		}
+		if cost.Operation == "subscription" {
+			s.formatter.JSON(w, http.StatusOK, &graphql.Response{Errors: []*gqlerrors.QueryError{
+				limitError("Subscriptions are served over websocket connections with the %s protocol", graphqlWSProtocol),
+			}})
+			return
+		}
+
+		ctx := withGraphQLLoaders(req.Context(), s.newGraphQLLoaders(req.Context()))
+		resp := s.graphql.Exec(ctx, graphqlRequest.Query, graphqlRequest.OperationName, graphqlRequest.Variables)
+		resp.Extensions = costExtensions(cost)
+		s.formatter.JSON(w, http.StatusOK, resp)
+	}
+}
+
+// checkGraphQL validates a GraphQL operation and estimates its cost, refusing operations above the configured
+// limits
+func (s *Server) checkGraphQL(req api.GraphQLRequest) (*querycost.Cost, []*gqlerrors.QueryError) {
+	if errs := s.graphql.ValidateWithVariables(req.Query, req.Variables); len(errs) > 0 {
+		return nil, errs
+	}
+	cost, err := s.graphqlCost.Estimate(req.Query, req.OperationName, req.Variables)
+	if err != nil {
+		return nil, []*gqlerrors.QueryError{limitError("%s", err)}
+	}
+
+	limits := s.currentConfig().GraphQL
+	var errs []*gqlerrors.QueryError
+	if limits.MaxDepth > 0 && cost.Depth > limits.MaxDepth {
+		errs = append(errs, limitError("Query depth %d exceeds the limit of %d", cost.Depth, limits.MaxDepth))
+	}
+	if limits.MaxComplexity > 0 && cost.Complexity > limits.MaxComplexity {
+		errs = append(errs, limitError("Query complexity %d exceeds the limit of %d, request smaller pages or fewer fields",
+			cost.Complexity, limits.MaxComplexity))
+	}
+	return cost, errs
+}
+
+// limitError is the error of an operation refused before it is run
+func limitError(format string, args ...interface{}) *gqlerrors.QueryError {
+	err := gqlerrors.Errorf(format, args...)
+	err.Extensions = map[string]interface{}{"status": http.StatusBadRequest}
+	return err
+}
+
+// costExtensions reports the estimated cost of an operation in the extensions of its response
+func costExtensions(cost *querycost.Cost) map[string]interface{} {
+	return map[string]interface{}{
+		"cost": map[string]interface{}{"complexity": cost.Complexity, "depth": cost.Depth},
+	}
+}
+
+// graphqlError is the error of a resolver, reporting the HTTP status the REST API responds with to the same error
+type graphqlError struct {
+	err    error
+	status int
+}
+
+// resolverError returns the error of a resolver failing to read records
+func resolverError(err error) error {
+	return graphqlError{err: err, status: errorToStatus(err)}
+}
+
+// argumentError returns the error of a resolver given an invalid argument
+func argumentError(err error) error {
+	return graphqlError{err: err, status: http.StatusBadRequest}
+}
+
+func (e graphqlError) Error() string {
+	return e.err.Error()
+}
+
+// Extensions is called by the GraphQL executor to describe the error
+func (e graphqlError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"status": e.status}
+}
+
+// graphqlLoaders batch the repository reads of the fields of an operation, they live as long as the operation or the
+// event of a subscription
+type graphqlLoaders struct {
+	s     *Server
+	ctx   context.Context
+	feeds *dataloader.Loader
+
+	mu sync.Mutex
+	// articles load the Articles of Feeds, by the User whose filter rules apply, the tag they carry and the page
+	articles map[articlesKey]*dataloader.Loader
+}
+
+type articlesKey struct {
+	userID string
+	tag    string
+	page   db.Page
+}
+
+type graphqlLoadersKey struct{}
+
+func (s *Server) newGraphQLLoaders(ctx context.Context) *graphqlLoaders {
+	l := &graphqlLoaders{s: s, ctx: ctx, articles: make(map[articlesKey]*dataloader.Loader)}
+	l.feeds = dataloader.New(ctx, l.loadFeeds, loaderWait, maxPageSize)
+	return l
+}
+
+func withGraphQLLoaders(ctx context.Context, loaders *graphqlLoaders) context.Context {
+	return context.WithValue(ctx, graphqlLoadersKey{}, loaders)
+}
+
+func graphqlLoadersFromContext(ctx context.Context) *graphqlLoaders {
+	loaders, _ := ctx.Value(graphqlLoadersKey{}).(*graphqlLoaders)
+	return loaders
+}
+
+func (l *graphqlLoaders) loadFeeds(ctx context.Context, feedIDs []string) (map[string]interface{}, error) {
+	feeds, err := l.s.repo.GetFeeds(ctx, feedIDs)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]interface{}, len(feeds))
+	for _, f := range feeds {
+		values[f.ID] = f
+	}
+	return values, nil
+}
+
+// feed returns a Feed, loading it in a batch with the Feeds of the other fields being resolved
+func (l *graphqlLoaders) feed(ctx context.Context, feedID string) (api.Feed, error) {
+	v, err := l.feeds.Load(ctx, feedID)
+	if err != nil {
+		return api.Feed{}, err
+	}
+	if v == nil {
+		return api.Feed{}, db.ErrNoSuchFeed
+	}
+	return v.(api.Feed), nil
+}
+
+// feedArticles returns a page of the published Articles of a Feed carrying a tag, with the filter rules of a User
+// applied unless userID is empty. Articles are loaded in a batch with those of the other Feeds being resolved.
+func (l *graphqlLoaders) feedArticles(ctx context.Context, userID string, tag string, page db.Page, feedID string) ([]api.Article, error) {
+	key := articlesKey{userID: userID, tag: tag, page: page}
+	l.mu.Lock()
+	loader, ok := l.articles[key]
+	if !ok {
+		loader = dataloader.New(l.ctx, func(ctx context.Context, feedIDs []string) (map[string]interface{}, error) {
+			return l.loadArticles(ctx, key, feedIDs)
+		}, loaderWait, maxPageSize)
+		l.articles[key] = loader
+	}
+	l.mu.Unlock()
+
+	v, err := loader.Load(ctx, feedID)
+	if err != nil {
+		return nil, err
+	}
+	articles, _ := v.([]api.Article)
+	return articles, nil
+}
+
+func (l *graphqlLoaders) loadArticles(ctx context.Context, key articlesKey, feedIDs []string) (map[string]interface{}, error) {
+	filter := db.ArticleFilter{Tag: key.tag}
+	if key.userID != "" {
+		var err error
+		if filter.Rules, _, err = l.s.userRules(ctx, key.userID); err != nil {
+			return nil, err
+		}
+	}
+	articles, err := l.s.repo.ListArticlesOfFeeds(ctx, feedIDs, filter, key.page)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]interface{}, len(feedIDs))
+	for _, a := range articles {
+		feedArticles, _ := values[a.FeedID].([]api.Article)
+		values[a.FeedID] = append(feedArticles, a)
+	}
+	return values, nil
+}
+
+// resolver is embedded in the resolvers of GraphQL objects, giving them the server and the loaders of the operation
+type resolver struct {
+	s       *Server
+	loaders *graphqlLoaders
+}
+
+// graphqlResolver resolves the Query and Subscription fields of the GraphQL API
+type graphqlResolver struct {
+	s *Server
+}
+
+// resolver returns the resolver of the objects of an operation, loading records with the loaders of the operation
+func (r *graphqlResolver) resolver(ctx context.Context) resolver {
+	loaders := graphqlLoadersFromContext(ctx)
+	if loaders == nil {
+		loaders = r.s.newGraphQLLoaders(ctx)
+	}
+	return resolver{s: r.s, loaders: loaders}
+}
+
+func (r *graphqlResolver) Users(ctx context.Context, args pageArgs) (*userConnection, error) {
+	page, err := args.dbPage()
+	if err != nil {
+		return nil, err
+	}
+	users, err := r.s.repo.ListUsers(ctx, page)
+	if err != nil {
+		return nil, args.readError(err)
+	}
+	end, info := args.fetched(len(users), func(i int) string { return users[i].ID })
+	c := &userConnection{info: info, count: func(ctx context.Context) (int, error) {
+		users, err := r.s.repo.ListUsers(ctx, db.Page{})
+		return len(users), err
+	}}
+	res := r.resolver(ctx)
+	for _, u := range users[:end] {
+		c.nodes = append(c.nodes, &userResolver{resolver: res, user: u})
+	}
+	return c, nil
+}
+
+func (r *graphqlResolver) User(ctx context.Context, args struct{ ID graphql.ID }) (*userResolver, error) {
+	user, err := r.s.repo.GetUser(ctx, string(args.ID))
+	if err == db.ErrNoSuchUser {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, resolverError(err)
+	}
+	return &userResolver{resolver: r.resolver(ctx), user: *user}, nil
+}
+
+func (r *graphqlResolver) Feeds(ctx context.Context, args struct {
+	pageArgs
+	Category *string
+}) (*feedConnection, error) {
+	category, err := normalizeCategory(stringArg(args.Category))
+	if err != nil {
+		return nil, argumentError(err)
+	}
+	feeds, err := r.s.repo.ListFeeds(ctx, db.FeedFilter{Category: category})
+	if err != nil {
+		return nil, resolverError(err)
+	}
+	return newFeedConnection(r.resolver(ctx), feeds, "", args.pageArgs)
+}
+
+func (r *graphqlResolver) Feed(ctx context.Context, args struct{ ID graphql.ID }) (*feedResolver, error) {
+	feed, err := r.s.repo.GetFeed(ctx, string(args.ID))
+	if err == db.ErrNoSuchFeed {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, resolverError(err)
+	}
+	return &feedResolver{resolver: r.resolver(ctx), feed: *feed}, nil
+}
+
+// ArticlePublished streams the Articles published to the Feeds a User follows, with the User's filter rules applied
+// like the gRPC WatchUserArticles stream
+func (r *graphqlResolver) ArticlePublished(ctx context.Context, args struct {
+	UserID graphql.ID
+	Tag    *string
+}) (<-chan *articleResolver, error) {
+	userID := string(args.UserID)
+	filter, err := tagFilter(stringArg(args.Tag))
+	if err != nil {
+		return nil, argumentError(err)
+	}
+	if _, err := r.s.repo.GetUser(ctx, userID); err != nil {
+		return nil, resolverError(err)
+	}
+
+	watch, err := r.s.watchUser(ctx, userID, filter.Tag)
+	if err != nil {
+		return nil, resolverError(err)
+	}
+	articles := make(chan *articleResolver)
+	go func() {
+		defer close(articles)
+		defer watch.close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-watch.refreshes():
+				if err := watch.refresh(ctx); err != nil {
+					endSubscription(ctx, resolverError(err))
+					return
+				}
+			case article, ok := <-watch.articles():
+				if !ok {
+					endSubscription(ctx, errSubscriptionBehind)
+					return
+				}
+				if !watch.watches(article) {
+					continue
+				}
+				// Every event is resolved with loaders of its own, so that it reads up to date records
+				res := resolver{s: r.s, loaders: r.s.newGraphQLLoaders(ctx)}
+				select {
+				case articles <- &articleResolver{resolver: res, article: *article}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return articles, nil
+}
+
+// errSubscriptionBehind ends the subscriptions falling behind the Articles published
+var errSubscriptionBehind = graphqlError{
+	err:    errors.New("Subscription fell behind the Articles published, query the User's articles to catch up and subscribe again"),
+	status: http.StatusTooManyRequests,
+}
+
+// subscriptionEnd records the error ending a subscription. The subscription sets it before closing its channel, so
+// that the transport reads it once the responses of the subscription end.
+type subscriptionEnd struct {
+	err error
+}
+
+type subscriptionEndKey struct{}
+
+func withSubscriptionEnd(ctx context.Context, end *subscriptionEnd) context.Context {
+	return context.WithValue(ctx, subscriptionEndKey{}, end)
+}
+
+// endSubscription records the error ending the subscription of ctx
+func endSubscription(ctx context.Context, err error) {
+	if end, ok := ctx.Value(subscriptionEndKey{}).(*subscriptionEnd); ok {
+		end.err = err
+	}
+}
+
+type userResolver struct {
+	resolver
+	user api.User
+}
+
+func (r *userResolver) ID() graphql.ID {
+	return graphql.ID(r.user.ID)
+}
+
+func (r *userResolver) Name() string {
+	return r.user.Name
+}
+
+func (r *userResolver) Subscriptions(ctx context.Context, args pageArgs) (*feedConnection, error) {
+	feeds, err := r.s.repo.ListUserFeeds(ctx, r.user.ID)
+	if err != nil {
+		return nil, resolverError(err)
+	}
+	return newFeedConnection(r.resolver, feeds, r.user.ID, args)
+}
+
+func (r *userResolver) Articles(ctx context.Context, args struct {
+	pageArgs
+	Tag                *string
+	CollapseDuplicates bool
+}) (*articleConnection, error) {
+	filter, err := tagFilter(stringArg(args.Tag))
+	if err != nil {
+		return nil, argumentError(err)
+	}
+	if filter.Rules, _, err = r.s.userRules(ctx, r.user.ID); err != nil {
+		return nil, resolverError(err)
+	}
+
+	articles, err := r.s.repo.ListUserArticles(ctx, r.user.ID, filter)
+	if err != nil {
+		return nil, resolverError(err)
+	}
+	if args.CollapseDuplicates {
+		articles = dedup.Collapse(articles)
+	}
+	return newArticleConnection(r.resolver, articles, args.pageArgs)
+}
+
+type feedResolver struct {
+	resolver
+	feed api.Feed
+	// userID is the User whose filter rules apply to the Feed's Articles, when reached through their subscriptions
+	userID string
+}
+
+func (r *feedResolver) ID() graphql.ID {
+	return graphql.ID(r.feed.ID)
+}
+
+func (r *feedResolver) Name() string {
+	return r.feed.Name
+}
+
+func (r *feedResolver) Category() *string {
+	return optionalString(r.feed.Category)
+}
+
+func (r *feedResolver) Retention() *retentionResolver {
+	if r.feed.Retention == nil || r.feed.Retention.Empty() {
+		return nil
+	}
+	return &retentionResolver{policy: *r.feed.Retention}
+}
+
+func (r *feedResolver) Articles(ctx context.Context, args struct {
+	pageArgs
+	Tag *string
+}) (*articleConnection, error) {
+	filter, err := tagFilter(stringArg(args.Tag))
+	if err != nil {
+		return nil, argumentError(err)
+	}
+	page, err := args.dbPage()
+	if err != nil {
+		return nil, err
+	}
+	articles, err := r.loaders.feedArticles(ctx, r.userID, filter.Tag, page, r.feed.ID)
+	if err != nil {
+		return nil, args.readError(err)
+	}
+	end, info := args.fetched(len(articles), func(i int) string { return articles[i].ID })
+	c := &articleConnection{info: info, count: func(ctx context.Context) (int, error) {
+		articles, err := r.loaders.feedArticles(ctx, r.userID, filter.Tag, db.Page{}, r.feed.ID)
+		return len(articles), err
+	}}
+	for _, a := range articles[:end] {
+		c.nodes = append(c.nodes, &articleResolver{resolver: r.resolver, article: a})
+	}
+	return c, nil
+}
+
+type retentionResolver struct {
+	policy api.RetentionPolicy
+}
+
+func (r *retentionResolver) MaxAgeDays() int32 {
+	return int32(r.policy.MaxAgeDays)
+}
+
+func (r *retentionResolver) MaxCount() int32 {
+	return int32(r.policy.MaxCount)
+}
+
+type articleResolver struct {
+	resolver
+	article api.Article
+}
+
+// formatArgs are the arguments of the fields of Article bodies
+type formatArgs struct {
+	Format string
+}
+
+func (r *articleResolver) ID() graphql.ID {
+	return graphql.ID(r.article.ID)
+}
+
+func (r *articleResolver) Title() string {
+	return r.article.Title
+}
+
+func (r *articleResolver) Body(args formatArgs) (string, error) {
+	format, err := parseBodyFormat(args.Format)
+	if err != nil {
+		return "", argumentError(err)
+	}
+	body, _ := presentBody(r.article.Body, r.article.BodyFormat, format)
+	return body, nil
+}
+
+func (r *articleResolver) BodyFormat(args formatArgs) (*string, error) {
+	format, err := parseBodyFormat(args.Format)
+	if err != nil {
+		return nil, argumentError(err)
+	}
+	_, bodyFormat := presentBody(r.article.Body, r.article.BodyFormat, format)
+	return optionalString(bodyFormat), nil
+}
+
+// Summary returns the summary of the Article as stored
+func (r *articleResolver) Summary() string {
+	return r.article.Summary
+}
+
+func (r *articleResolver) Author() *string {
+	return optionalString(r.article.Author)
+}
+
+func (r *articleResolver) URL() *string {
+	return optionalString(r.article.URL)
+}
+
+func (r *articleResolver) ImageURL() *string {
+	return optionalString(r.article.ImageURL)
+}
+
+func (r *articleResolver) Tags() []string {
+	if r.article.Tags == nil {
+		return []string{}
+	}
+	return r.article.Tags
+}
+
+func (r *articleResolver) PublishedAt() graphql.Time {
+	return graphql.Time{Time: r.article.PublishedTime}
+}
+
+func (r *articleResolver) UpdatedAt() graphql.Time {
+	return graphql.Time{Time: r.article.UpdatedTime}
+}
+
+func (r *articleResolver) Feed(ctx context.Context) (*feedResolver, error) {
+	feed, err := r.loaders.feed(ctx, r.article.FeedID)
+	if err != nil {
+		return nil, resolverError(err)
+	}
+	return &feedResolver{resolver: r.resolver, feed: feed}, nil
+}
+
+func (r *articleResolver) ClusterID() *string {
+	return optionalString(r.article.ClusterID)
+}
+
+func (r *articleResolver) Duplicates() []*articleSourceResolver {
+	duplicates := make([]*articleSourceResolver, len(r.article.Duplicates))
+	for i, d := range r.article.Duplicates {
+		duplicates[i] = &articleSourceResolver{resolver: r.resolver, source: d}
+	}
+	return duplicates
+}
+
+type articleSourceResolver struct {
+	resolver
+	source api.ArticleSource
+}
+
+func (r *articleSourceResolver) ArticleID() graphql.ID {
+	return graphql.ID(r.source.ArticleID)
+}
+
+func (r *articleSourceResolver) Feed(ctx context.Context) (*feedResolver, error) {
+	feed, err := r.loaders.feed(ctx, r.source.FeedID)
+	if err != nil {
+		return nil, resolverError(err)
+	}
+	return &feedResolver{resolver: r.resolver, feed: feed}, nil
+}
+
+func (r *articleSourceResolver) Title() string {
+	return r.source.Title
+}
+
+func (r *articleSourceResolver) URL() *string {
+	return optionalString(r.source.URL)
+}
+
+func (r *articleSourceResolver) PublishedAt() graphql.Time {
+	return graphql.Time{Time: r.source.PublishedTime}
+}
+
+// pageArgs are the arguments of the fields listing a page of items
+type pageArgs struct {
+	First int32
+	After *string
+}
+
+// page returns the bounds of the page of items following the item of the after cursor, the n items being identified
+// by id
+func (a pageArgs) page(n int, id func(int) string) (int, int, *pageInfo, error) {
+	afterID, err := a.afterID()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	start := 0
+	if afterID != "" {
+		start = -1
+		for i := 0; i < n; i++ {
+			if id(i) == afterID {
+				start = i + 1
+				break
+			}
+		}
+		if start < 0 {
+			return 0, 0, nil, a.cursorError()
+		}
+	}
+
+	end := start + int(a.First)
+	if end > n {
+		end = n
+	}
+	info := &pageInfo{hasNextPage: end < n}
+	if end > start {
+		info.endCursor = optionalString(base64.RawURLEncoding.EncodeToString([]byte(id(end - 1))))
+	}
+	return start, end, info, nil
+}
+
+// dbPage returns the page the repository is to read, holding one more item than the page so that the next page is
+// known to exist
+func (a pageArgs) dbPage() (db.Page, error) {
+	afterID, err := a.afterID()
+	if err != nil {
+		return db.Page{}, err
+	}
+	return db.Page{After: afterID, Limit: int(a.First) + 1}, nil
+}
+
+// fetched returns the end of the page of the n items read from the repository with dbPage, identified by id
+func (a pageArgs) fetched(n int, id func(int) string) (int, *pageInfo) {
+	end := n
+	if end > int(a.First) {
+		end = int(a.First)
+	}
+	info := &pageInfo{hasNextPage: end < n}
+	if end > 0 {
+		info.endCursor = optionalString(base64.RawURLEncoding.EncodeToString([]byte(id(end - 1))))
+	}
+	return end, info
+}
+
+// afterID validates the arguments, returning the ID of the item of the after cursor, empty without a cursor
+func (a pageArgs) afterID() (string, error) {
+	if a.First < 0 || a.First > maxPageSize {
+		return "", argumentError(errors.Errorf("first %d is out of range, expected a value between 0 and %d",
+			a.First, maxPageSize))
+	}
+	if a.After == nil {
+		return "", nil
+	}
+	afterID, err := base64.RawURLEncoding.DecodeString(*a.After)
+	if err != nil || len(afterID) == 0 {
+		return "", argumentError(errors.Errorf("Invalid cursor '%s'", *a.After))
+	}
+	return string(afterID), nil
+}
+
+// cursorError returns the error of a resolver given a cursor matching no item
+func (a pageArgs) cursorError() error {
+	return argumentError(errors.Errorf("Cursor '%s' does not match any item, it may have been removed", *a.After))
+}
+
+// readError returns the error of a resolver failing to read a page from the repository, which fails with an
+// ErrNoSuch* error when the item of the after cursor is gone
+func (a pageArgs) readError(err error) error {
+	if a.After != nil && (err == db.ErrNoSuchUser || err == db.ErrNoSuchArticle) {
+		return a.cursorError()
+	}
+	return resolverError(err)
+}
+
+type pageInfo struct {
+	endCursor   *string
+	hasNextPage bool
+}
+
+func (p *pageInfo) EndCursor() *string {
+	return p.endCursor
+}
+
+func (p *pageInfo) HasNextPage() bool {
+	return p.hasNextPage
+}
+
+type userConnection struct {
+	nodes []*userResolver
+	info  *pageInfo
+	// count counts the Users, which are only all read when the total count is asked for
+	count func(ctx context.Context) (int, error)
+}
+
+func (c *userConnection) Nodes() []*userResolver {
+	return c.nodes
+}
+
+func (c *userConnection) PageInfo() *pageInfo {
+	return c.info
+}
+
+func (c *userConnection) TotalCount(ctx context.Context) (int32, error) {
+	n, err := c.count(ctx)
+	if err != nil {
+		return 0, resolverError(err)
+	}
+	return int32(n), nil
+}
+
+type feedConnection struct {
+	nodes []*feedResolver
+	info  *pageInfo
+	total int
+}
+
+// newFeedConnection returns a page of Feeds, the Articles of which have the filter rules of a User applied unless
+// userID is empty
+func newFeedConnection(res resolver, feeds []api.Feed, userID string, args pageArgs) (*feedConnection, error) {
+	start, end, info, err := args.page(len(feeds), func(i int) string { return feeds[i].ID })
+	if err != nil {
+		return nil, err
+	}
+	c := &feedConnection{info: info, total: len(feeds)}
+	for _, f := range feeds[start:end] {
+		c.nodes = append(c.nodes, &feedResolver{resolver: res, feed: f, userID: userID})
+	}
+	return c, nil
+}
+
+func (c *feedConnection) Nodes() []*feedResolver {
+	return c.nodes
+}
+
+func (c *feedConnection) PageInfo() *pageInfo {
+	return c.info
+}
+
+func (c *feedConnection) TotalCount() int32 {
+	return int32(c.total)
+}
+
+type articleConnection struct {
+	nodes []*articleResolver
+	info  *pageInfo
+	total int
+	// count counts the Articles when they are read a page at a time, leaving total unset
+	count func(ctx context.Context) (int, error)
+}
+
+func newArticleConnection(res resolver, articles []api.Article, args pageArgs) (*articleConnection, error) {
+	start, end, info, err := args.page(len(articles), func(i int) string { return articles[i].ID })
+	if err != nil {
+		return nil, err
+	}
+	c := &articleConnection{info: info, total: len(articles)}
+	for _, a := range articles[start:end] {
+		c.nodes = append(c.nodes, &articleResolver{resolver: res, article: a})
+	}
+	return c, nil
+}
+
+func (c *articleConnection) Nodes() []*articleResolver {
+	return c.nodes
+}
+
+func (c *articleConnection) PageInfo() *pageInfo {
+	return c.info
+}
+
+func (c *articleConnection) TotalCount(ctx context.Context) (int32, error) {
+	if c.count == nil {
+		return int32(c.total), nil
+	}
+	n, err := c.count(ctx)
+	if err != nil {
+		return 0, resolverError(err)
+	}
+	return int32(n), nil
+}
+
+// stringArg returns the value of an optional String argument, empty when it is not given
+func stringArg(arg *string) string {
+	if arg == nil {
+		return ""
+	}
+	return *arg
+}
+
+// optionalString returns nil for empty strings, resolving optional fields to null
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
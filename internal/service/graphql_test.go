@@ -0,0 +1,596 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+	"github.com/if-ivan-else/tldrfeed/internal/db/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// countingRepository counts the Feed and Article reads of the GraphQL resolvers
+type countingRepository struct {
+	db.Repository
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (r *countingRepository) count(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls[name]++
+}
+
+func (r *countingRepository) GetFeed(ctx context.Context, feedID string) (*api.Feed, error) {
+	r.count("GetFeed")
+	return r.Repository.GetFeed(ctx, feedID)
+}
+
+func (r *countingRepository) GetFeeds(ctx context.Context, feedIDs []string) ([]api.Feed, error) {
+	r.count("GetFeeds")
+	return r.Repository.GetFeeds(ctx, feedIDs)
+}
+
+func (r *countingRepository) ListFeedArticles(ctx context.Context, feedID string, filter db.ArticleFilter) ([]api.Article, error) {
+	r.count("ListFeedArticles")
+	return r.Repository.ListFeedArticles(ctx, feedID, filter)
+}
+
+func (r *countingRepository) ListArticlesOfFeeds(ctx context.Context, feedIDs []string, filter db.ArticleFilter, page db.Page) ([]api.Article, error) {
+	r.count("ListArticlesOfFeeds")
+	return r.Repository.ListArticlesOfFeeds(ctx, feedIDs, filter, page)
+}
+
+// graphqlFixture creates a User following two of three Feeds, with Articles published to each
+func graphqlFixture(require *require.Assertions, server *Server) (*api.User, []*api.Feed) {
+	ctx := context.Background()
+	user, err := server.repo.CreateUser(ctx, "ivan")
+	require.NoError(err)
+
+	feeds := []*api.Feed{}
+	for _, f := range []struct{ name, category string }{
+		{"Chaikovsky Breaking News", "news"},
+		{"Rakhmaninov Sports", "sports"},
+		{"Prokofiev Weekly", "news"},
+	} {
+		feed, err := server.repo.CreateFeed(ctx, f.name, f.category, nil)
+		require.NoError(err)
+		feeds = append(feeds, feed)
+		for _, title := range []string{"Morning", "Evening"} {
+			_, err := server.addArticle(ctx, feed.ID, api.Article{
+				Title:         title + " " + f.category,
+				Body:          "**" + title + "** in " + f.name,
+				BodyFormat:    api.BodyMarkdown,
+				Tags:          []string{f.category},
+				Status:        api.StatusPublished,
+				PublishedTime: time.Now(),
+			})
+			require.NoError(err)
+		}
+	}
+	require.NoError(server.repo.AddUserFeed(ctx, user.ID, feeds[0].ID))
+	require.NoError(server.repo.AddUserFeed(ctx, user.ID, feeds[1].ID))
+	return user, feeds
+}
+
+func testGraphQLClient(t *testing.T, server *Server) *api.Client {
+	ts := httptest.NewServer(router(server))
+	t.Cleanup(ts.Close)
+	return api.NewClient(ts.URL)
+}
+
+func TestGraphQLQueries(t *testing.T) {
+	require := require.New(t)
+	server := testServer()
+	user, feeds := graphqlFixture(require, server)
+	client := testGraphQLClient(t, server)
+
+	var data struct {
+		User struct {
+			Name          string
+			Subscriptions struct {
+				TotalCount int
+				Nodes      []struct {
+					Name     string
+					Category string
+					Articles struct {
+						TotalCount int
+						Nodes      []struct {
+							Title      string
+							Body       string
+							BodyFormat *string
+							Summary    string
+							Tags       []string
+							Feed       struct{ ID string }
+						}
+					}
+				}
+			}
+			Articles struct {
+				Nodes []struct{ Title string }
+			}
+		}
+		Nobody *struct{ Name string }
+	}
+	err := client.GraphQL(&api.GraphQLRequest{
+		Query: `query($id: ID!) {
+			user(id: $id) {
+				name
+				subscriptions {
+					totalCount
+					nodes {
+						name category
+						articles(first: 1) {
+							totalCount
+							nodes { title body(format: "text") bodyFormat(format: "text") summary tags feed { id } }
+						}
+					}
+				}
+				articles(tag: "sports") { nodes { title } }
+			}
+			nobody: user(id: "nobody") { name }
+		}`,
+		Variables: map[string]interface{}{"id": user.ID},
+	}, &data)
+	require.NoError(err)
+
+	require.Equal("ivan", data.User.Name)
+	require.Nil(data.Nobody)
+	subscriptions := data.User.Subscriptions
+	require.Equal(2, subscriptions.TotalCount)
+	require.Len(subscriptions.Nodes, 2)
+	for i, feed := range subscriptions.Nodes {
+		require.Equal(feeds[i].Name, feed.Name)
+		require.Equal(feeds[i].Category, feed.Category)
+		require.Equal(2, feed.Articles.TotalCount)
+		require.Len(feed.Articles.Nodes, 1)
+
+		article := feed.Articles.Nodes[0]
+		require.NotContains(article.Body, "**")
+		require.Nil(article.BodyFormat)
+		require.NotEmpty(article.Summary)
+		require.Equal([]string{feeds[i].Category}, article.Tags)
+		require.Equal(feeds[i].ID, article.Feed.ID)
+	}
+	require.Len(data.User.Articles.Nodes, 2)
+	for _, a := range data.User.Articles.Nodes {
+		require.True(strings.HasSuffix(a.Title, " sports"))
+	}
+
+	// Feeds are listed by category, their Articles have no User's filter rules applied
+	var feedData struct {
+		Feeds struct {
+			Nodes []struct {
+				Name      string
+				Retention *struct{ MaxCount int }
+				Articles  struct {
+					Nodes []struct {
+						Body       string
+						BodyFormat string
+					}
+				}
+			}
+		}
+	}
+	err = client.GraphQL(&api.GraphQLRequest{
+		Query: `{ feeds(category: "News") { nodes { name retention { maxCount } articles { nodes { body bodyFormat } } } } }`,
+	}, &feedData)
+	require.NoError(err)
+	require.Len(feedData.Feeds.Nodes, 2)
+	require.Nil(feedData.Feeds.Nodes[0].Retention)
+	require.Equal(api.BodyMarkdown, feedData.Feeds.Nodes[0].Articles.Nodes[0].BodyFormat)
+	require.Contains(feedData.Feeds.Nodes[0].Articles.Nodes[0].Body, "**")
+
+	// Resolver errors are reported with the status the REST API responds with
+	err = client.GraphQL(&api.GraphQLRequest{Query: `{ user(id: "nobody") { name } feed(id: "nothing") { name } }`}, nil)
+	require.NoError(err)
+	err = client.GraphQL(&api.GraphQLRequest{
+		Query:     `query($id: ID!) { user(id: $id) { articles { nodes { body(format: "pdf") } } } }`,
+		Variables: map[string]interface{}{"id": user.ID},
+	}, nil)
+	require.Error(err)
+	require.Contains(err.Error(), "Unknown body format 'pdf'")
+	require.Equal(float64(http.StatusBadRequest), err.(api.GraphQLErrors)[0].Extensions["status"])
+}
+
+func TestGraphQLPagination(t *testing.T) {
+	require := require.New(t)
+	server := testServer()
+	for _, name := range []string{"anna", "boris", "clara", "dmitri", "elena"} {
+		_, err := server.repo.CreateUser(context.Background(), name)
+		require.NoError(err)
+	}
+	client := testGraphQLClient(t, server)
+
+	type page struct {
+		Users struct {
+			Nodes    []struct{ Name string }
+			PageInfo struct {
+				EndCursor   *string
+				HasNextPage bool
+			}
+			TotalCount int
+		}
+	}
+	query := `query($after: String) { users(first: 2, after: $after) {
+		nodes { name } pageInfo { endCursor hasNextPage } totalCount } }`
+
+	names := []string{}
+	var after interface{}
+	for pages := 0; ; pages++ {
+		require.Less(pages, 3)
+		var p page
+		require.NoError(client.GraphQL(&api.GraphQLRequest{Query: query, Variables: map[string]interface{}{"after": after}}, &p))
+		require.Equal(5, p.Users.TotalCount)
+		for _, u := range p.Users.Nodes {
+			names = append(names, u.Name)
+		}
+		if !p.Users.PageInfo.HasNextPage {
+			break
+		}
+		after = *p.Users.PageInfo.EndCursor
+	}
+	require.Equal([]string{"anna", "boris", "clara", "dmitri", "elena"}, names)
+
+	err := client.GraphQL(&api.GraphQLRequest{Query: query, Variables: map[string]interface{}{"after": "!"}}, nil)
+	require.Error(err)
+	require.Contains(err.Error(), "Invalid cursor '!'")
+	err = client.GraphQL(&api.GraphQLRequest{Query: `{ users(first: 101) { totalCount } }`}, nil)
+	require.Error(err)
+	require.Contains(err.Error(), "first 101 is out of range")
+}
+
+func TestGraphQLArticlePages(t *testing.T) {
+	require := require.New(t)
+	server := testServer()
+	_, feeds := graphqlFixture(require, server)
+	client := testGraphQLClient(t, server)
+
+	type page struct {
+		Feed struct {
+			Articles struct {
+				Nodes    []struct{ Title string }
+				PageInfo struct {
+					EndCursor   *string
+					HasNextPage bool
+				}
+				TotalCount int
+			}
+		}
+	}
+	query := `query($id: ID!, $after: String) { feed(id: $id) { articles(first: 1, after: $after) {
+		nodes { title } pageInfo { endCursor hasNextPage } totalCount } } }`
+
+	titles := []string{}
+	var after interface{}
+	for pages := 0; ; pages++ {
+		require.Less(pages, 2)
+		var p page
+		require.NoError(client.GraphQL(&api.GraphQLRequest{
+			Query:     query,
+			Variables: map[string]interface{}{"id": feeds[0].ID, "after": after},
+		}, &p))
+		require.Equal(2, p.Feed.Articles.TotalCount)
+		for _, a := range p.Feed.Articles.Nodes {
+			titles = append(titles, a.Title)
+		}
+		if !p.Feed.Articles.PageInfo.HasNextPage {
+			break
+		}
+		after = *p.Feed.Articles.PageInfo.EndCursor
+	}
+	require.Equal([]string{"Morning news", "Evening news"}, titles)
+
+	// Cursors of Articles of other Feeds match no Article
+	err := client.GraphQL(&api.GraphQLRequest{
+		Query:     query,
+		Variables: map[string]interface{}{"id": feeds[1].ID, "after": after},
+	}, nil)
+	require.Error(err)
+	require.Contains(err.Error(), "does not match any item")
+	require.Equal(float64(http.StatusBadRequest), err.(api.GraphQLErrors)[0].Extensions["status"])
+}
+
+func TestGraphQLBatching(t *testing.T) {
+	require := require.New(t)
+	repo := &countingRepository{Repository: mock.NewRepository(), calls: map[string]int{}}
+	server := newServer(testConfig(), repo)
+	_, feeds := graphqlFixture(require, server)
+	client := testGraphQLClient(t, server)
+
+	// The Articles of every Feed and the Feed of every Article are read at once
+	var data struct {
+		Feeds struct {
+			Nodes []struct {
+				Articles struct {
+					Nodes []struct {
+						Feed struct{ Name string }
+					}
+				}
+			}
+		}
+	}
+	err := client.GraphQL(&api.GraphQLRequest{Query: `{ feeds { nodes { articles { nodes { feed { name } } } } } }`}, &data)
+	require.NoError(err)
+	require.Len(data.Feeds.Nodes, 3)
+	for i, f := range data.Feeds.Nodes {
+		require.Len(f.Articles.Nodes, 2)
+		require.Equal(feeds[i].Name, f.Articles.Nodes[0].Feed.Name)
+	}
+	require.Equal(map[string]int{"ListArticlesOfFeeds": 1, "GetFeeds": 1}, repo.calls)
+}
+
+func TestGraphQLLimits(t *testing.T) {
+	require := require.New(t)
+	server := testServer()
+	user, _ := graphqlFixture(require, server)
+	config := testConfig()
+	config.GraphQL = GraphQLConfig{MaxComplexity: 100, MaxDepth: 6}
+	server.Reload(config)
+	client := testGraphQLClient(t, server)
+
+	query := func(q string) (*api.GraphQLResponse, error) {
+		var resp api.GraphQLResponse
+		params := url.Values{"query": {q}, "variables": {`{"id": "` + user.ID + `"}`}}
+		req, _ := http.NewRequest("GET", "/api/v1/graphql?"+params.Encode(), nil)
+		rr := httptest.NewRecorder()
+		router(server).ServeHTTP(rr, req)
+		requireStatus(http.StatusOK, require, rr)
+		return &resp, json.NewDecoder(rr.Body).Decode(&resp)
+	}
+
+	resp, err := query(`query($id: ID!) { user(id: $id) { subscriptions(first: 2) { nodes { articles(first: 5) { nodes { title } } } } } }`)
+	require.NoError(err)
+	require.Empty(resp.Errors)
+	require.Equal(map[string]interface{}{"complexity": float64(1 + 1 + 2*(1+1+5*2)), "depth": float64(6)},
+		resp.Extensions["cost"])
+
+	// Queries above the limits are refused before they run
+	resp, err = query(`query($id: ID!) { user(id: $id) { subscriptions(first: 2) { nodes { articles(first: 5) { nodes { feed { name } } } } } } }`)
+	require.NoError(err)
+	require.Nil(resp.Data)
+	require.Len(resp.Errors, 1)
+	require.Equal("Query depth 7 exceeds the limit of 6", resp.Errors[0].Message)
+
+	resp, err = query(`query($id: ID!) { user(id: $id) { subscriptions(first: 10) { nodes { articles(first: 10) { nodes { title } } } } } }`)
+	require.NoError(err)
+	require.Len(resp.Errors, 1)
+	require.Contains(resp.Errors[0].Message, "Query complexity 222 exceeds the limit of 100")
+	require.Equal(float64(http.StatusBadRequest), resp.Errors[0].Extensions["status"])
+
+	resp, err = query(`{ users { nodes { age } } }`)
+	require.NoError(err)
+	require.Len(resp.Errors, 1)
+	require.Contains(resp.Errors[0].Message, `Cannot query field "age" on type "User"`)
+
+	resp, err = query(`subscription($id: ID!) { articlePublished(userId: $id) { title } }`)
+	require.NoError(err)
+	require.Contains(resp.Errors[0].Message, "Subscriptions are served over websocket connections")
+
+	// Limits are lifted when disabled
+	config.GraphQL = GraphQLConfig{}
+	server.Reload(config)
+	err = client.GraphQL(&api.GraphQLRequest{
+		Query:     `query($id: ID!) { user(id: $id) { subscriptions(first: 10) { nodes { articles(first: 10) { nodes { feed { name } } } } } } }`,
+		Variables: map[string]interface{}{"id": user.ID},
+	}, nil)
+	require.NoError(err)
+
+	req, _ := http.NewRequest("POST", "/api/v1/graphql", strings.NewReader(`{"query": `))
+	rr := httptest.NewRecorder()
+	router(server).ServeHTTP(rr, req)
+	requireStatus(http.StatusBadRequest, require, rr)
+	req, _ = http.NewRequest("GET", "/api/v1/graphql?query=%7Busers%7BtotalCount%7D%7D&variables=%7B", nil)
+	rr = httptest.NewRecorder()
+	router(server).ServeHTTP(rr, req)
+	requireStatus(http.StatusBadRequest, require, rr)
+}
+
+// graphqlWSClient is a client of the graphql-transport-ws protocol
+type graphqlWSClient struct {
+	require *require.Assertions
+	conn    *websocket.Conn
+}
+
+func dialGraphQLWS(t *testing.T, server *Server) *graphqlWSClient {
+	require := require.New(t)
+	ts := httptest.NewServer(router(server))
+	t.Cleanup(ts.Close)
+
+	dialer := websocket.Dialer{Subprotocols: []string{graphqlWSProtocol}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/api/v1/graphql", nil)
+	require.NoError(err)
+	t.Cleanup(func() { conn.Close() })
+	require.Equal(graphqlWSProtocol, conn.Subprotocol())
+	return &graphqlWSClient{require: require, conn: conn}
+}
+
+func (c *graphqlWSClient) send(msg wsMessage) {
+	c.require.NoError(c.conn.WriteJSON(msg))
+}
+
+func (c *graphqlWSClient) subscribe(id string, query string, variables map[string]interface{}) {
+	payload, err := json.Marshal(api.GraphQLRequest{Query: query, Variables: variables})
+	c.require.NoError(err)
+	c.send(wsMessage{ID: id, Type: wsSubscribe, Payload: payload})
+}
+
+func (c *graphqlWSClient) receive() wsMessage {
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg wsMessage
+	c.require.NoError(c.conn.ReadJSON(&msg))
+	return msg
+}
+
+// requireClosed expects the connection to be closed with a code
+func (c *graphqlWSClient) requireClosed(code int) {
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err := c.conn.ReadMessage()
+	c.require.True(websocket.IsCloseError(err, code), "Expected close %d, got %v", code, err)
+}
+
+func TestGraphQLSubscription(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	server := testServer()
+	user, feeds := graphqlFixture(require, server)
+	client := dialGraphQLWS(t, server)
+
+	client.send(wsMessage{Type: wsConnectionInit})
+	require.Equal(wsConnectionAck, client.receive().Type)
+	client.send(wsMessage{Type: wsPing})
+	require.Equal(wsPong, client.receive().Type)
+
+	client.subscribe("1", `subscription($id: ID!) { articlePublished(userId: $id, tag: "news") { title feed { name } } }`,
+		map[string]interface{}{"id": user.ID})
+	require.Eventually(func() bool { return server.watchers.count() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// Articles of Feeds the User does not follow or not carrying the tag are not sent
+	publish := func(feed *api.Feed, title string, tag string) {
+		_, err := server.addArticle(ctx, feed.ID, api.Article{
+			Title:         title,
+			Body:          "Just published",
+			Tags:          []string{tag},
+			Status:        api.StatusPublished,
+			PublishedTime: time.Now(),
+		})
+		require.NoError(err)
+	}
+	publish(feeds[2], "Unfollowed", "news")
+	publish(feeds[1], "Untagged", "sports")
+	publish(feeds[0], "Breaking", "news")
+
+	msg := client.receive()
+	require.Equal(wsNext, msg.Type)
+	require.Equal("1", msg.ID)
+	var resp api.GraphQLResponse
+	require.NoError(json.Unmarshal(msg.Payload, &resp))
+	require.Empty(resp.Errors)
+	require.JSONEq(`{"articlePublished": {"title": "Breaking", "feed": {"name": "Chaikovsky Breaking News"}}}`, string(resp.Data))
+
+	// Queries are answered with a single response
+	client.subscribe("2", `{ users { totalCount } }`, nil)
+	msg = client.receive()
+	require.Equal(wsNext, msg.Type)
+	require.Equal("2", msg.ID)
+	require.JSONEq(`{"data": {"users": {"totalCount": 1}}, "extensions": {"cost": {"complexity": 2, "depth": 2}}}`,
+		string(msg.Payload))
+	require.Equal(wsMessage{ID: "2", Type: wsComplete}, client.receive())
+
+	// Invalid operations fail with an error message
+	client.subscribe("3", `{ users { age } }`, nil)
+	msg = client.receive()
+	require.Equal(wsError, msg.Type)
+	require.Contains(string(msg.Payload), `Cannot query field \"age\"`)
+
+	client.send(wsMessage{ID: "1", Type: wsComplete})
+	require.Eventually(func() bool { return server.watchers.count() == 0 }, 5*time.Second, 10*time.Millisecond)
+
+	// Subscribing twice with the same ID violates the protocol
+	client.subscribe("4", `subscription($id: ID!) { articlePublished(userId: $id) { title } }`, map[string]interface{}{"id": user.ID})
+	client.subscribe("4", `subscription($id: ID!) { articlePublished(userId: $id) { title } }`, map[string]interface{}{"id": user.ID})
+	client.requireClosed(wsSubscriberExists)
+	require.Eventually(func() bool { return server.watchers.count() == 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestGraphQLSubscriptionProtocol(t *testing.T) {
+	require := require.New(t)
+	server := testServer()
+
+	client := dialGraphQLWS(t, server)
+	client.subscribe("1", `{ users { totalCount } }`, nil)
+	client.requireClosed(wsUnauthorized)
+
+	client = dialGraphQLWS(t, server)
+	client.send(wsMessage{Type: wsConnectionInit})
+	require.Equal(wsConnectionAck, client.receive().Type)
+	client.send(wsMessage{Type: wsConnectionInit})
+	client.requireClosed(wsTooManyInitRequests)
+
+	client = dialGraphQLWS(t, server)
+	client.send(wsMessage{Type: "start"})
+	client.requireClosed(wsBadRequest)
+
+	// Subscribing to a User that does not exist ends the subscription
+	client = dialGraphQLWS(t, server)
+	client.send(wsMessage{Type: wsConnectionInit})
+	require.Equal(wsConnectionAck, client.receive().Type)
+	client.subscribe("1", `subscription { articlePublished(userId: "nobody") { title } }`, nil)
+	msg := client.receive()
+	require.Equal(wsNext, msg.Type)
+	require.Contains(string(msg.Payload), db.ErrNoSuchUser.Error())
+	require.Equal(wsMessage{ID: "1", Type: wsComplete}, client.receive())
+}
+
+func TestGraphQLSubscriptionLimits(t *testing.T) {
+	require := require.New(t)
+	server := testServer()
+	user, _ := graphqlFixture(require, server)
+	config := testConfig()
+	config.GraphQL = GraphQLConfig{MaxOperations: 1}
+	config.RateLimit = RateLimitConfig{Key: RateLimitKeyIP, Routes: []RouteRateLimit{{Route: "graphql", Rate: 0.01, Burst: 2}}}
+	server.Reload(config)
+
+	client := dialGraphQLWS(t, server)
+	client.send(wsMessage{Type: wsConnectionInit})
+	require.Equal(wsConnectionAck, client.receive().Type)
+
+	// Operations above the connection's limit are refused, the connection stays open
+	client.subscribe("1", `subscription($id: ID!) { articlePublished(userId: $id) { title } }`,
+		map[string]interface{}{"id": user.ID})
+	client.subscribe("2", `{ users { totalCount } }`, nil)
+	msg := client.receive()
+	require.Equal("2", msg.ID)
+	require.Equal(wsError, msg.Type)
+	require.Contains(string(msg.Payload), "at most 1 can run at once")
+	require.Contains(string(msg.Payload), `"status":429`)
+
+	// Every operation started takes a token of the client's rate limit
+	client.send(wsMessage{ID: "1", Type: wsComplete})
+	client.subscribe("3", `{ users { totalCount } }`, nil)
+	require.Equal(wsNext, client.receive().Type)
+	require.Equal(wsMessage{ID: "3", Type: wsComplete}, client.receive())
+	client.subscribe("4", `{ users { totalCount } }`, nil)
+	msg = client.receive()
+	require.Equal("4", msg.ID)
+	require.Equal(wsError, msg.Type)
+	require.Contains(string(msg.Payload), "Rate limit exceeded")
+}
+
+func TestGraphQLSubscriptionFallsBehind(t *testing.T) {
+	require := require.New(t)
+	server := testServer()
+	user, feeds := graphqlFixture(require, server)
+
+	end := &subscriptionEnd{}
+	ctx, cancel := context.WithCancel(withSubscriptionEnd(context.Background(), end))
+	defer cancel()
+	articles, err := (&graphqlResolver{s: server}).ArticlePublished(ctx, struct {
+		UserID graphql.ID
+		Tag    *string
+	}{UserID: graphql.ID(user.ID)})
+	require.NoError(err)
+
+	// The subscription is not read from while Articles are published
+	for i := 0; i < watchBuffer+2; i++ {
+		server.watchers.publish(api.Article{Title: "Weather", FeedID: feeds[0].ID})
+	}
+	// The Articles buffered are sent before the subscription ends
+	received := 0
+	for range articles {
+		received++
+	}
+	require.GreaterOrEqual(received, watchBuffer)
+	require.Equal(errSubscriptionBehind, end.err)
+}
@@ -0,0 +1,296 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/if-ivan-else/tldrfeed/api"
+)
+
+// graphqlWSProtocol is the websocket subprotocol GraphQL operations are served with over websocket connections, see
+// https://github.com/enisdenjo/graphql-ws/blob/master/PROTOCOL.md
+const graphqlWSProtocol = "graphql-transport-ws"
+
+const (
+	// graphqlWSRoute is the route whose rate limit the operations started over websocket connections count against,
+	// like the operations posted to /graphql
+	graphqlWSRoute = "graphql"
+	// graphqlInitTimeout is how long clients have to initialise connections once upgraded
+	graphqlInitTimeout = 10 * time.Second
+	// graphqlWriteTimeout bounds the time spent writing a message to a client
+	graphqlWriteTimeout = 10 * time.Second
+)
+
+// Types of the messages of the graphql-transport-ws protocol
+const (
+	wsConnectionInit = "connection_init"
+	wsConnectionAck  = "connection_ack"
+	wsPing           = "ping"
+	wsPong           = "pong"
+	wsSubscribe      = "subscribe"
+	wsNext           = "next"
+	wsError          = "error"
+	wsComplete       = "complete"
+)
+
+// Codes connections are closed with on protocol violations
+const (
+	wsBadRequest             = 4400
+	wsUnauthorized           = 4401
+	wsInitTimeout            = 4408
+	wsSubscriberExists       = 4409
+	wsTooManyInitRequests    = 4429
+	wsSubprotocolUnsupported = 4406
+)
+
+var graphqlUpgrader = websocket.Upgrader{Subprotocols: []string{graphqlWSProtocol}}
+
+// wsMessage is a message of the graphql-transport-ws protocol
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// graphqlWSConn serves the operations a client subscribes to over a websocket connection
+type graphqlWSConn struct {
+	s    *Server
+	conn *websocket.Conn
+	// client identifies the client whose rate limit operations are counted against
+	client string
+
+	// writeMu serializes the writes of the operations to the connection
+	writeMu sync.Mutex
+
+	mu sync.Mutex
+	// operations cancel the operations running by ID
+	operations map[string]context.CancelFunc
+	wg         sync.WaitGroup
+}
+
+// serveGraphQLWS upgrades a request to a websocket connection speaking the graphql-transport-ws protocol, serving
+// the operations of the client until it closes the connection or violates the protocol
+func (s *Server) serveGraphQLWS(w http.ResponseWriter, req *http.Request) {
+	conn, err := graphqlUpgrader.Upgrade(w, req, nil)
+	if err != nil {
+		// The upgrader responded with an error
+		return
+	}
+	c := &graphqlWSConn{
+		s:          s,
+		conn:       conn,
+		client:     s.limiter.currentConfig().clientKey(req),
+		operations: make(map[string]context.CancelFunc),
+	}
+	if conn.Subprotocol() != graphqlWSProtocol {
+		c.close(wsSubprotocolUnsupported, "Subprotocol not acceptable")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	c.serve(ctx)
+	cancel()
+	c.wg.Wait()
+	conn.Close()
+}
+
+// serve reads the messages of the client until it closes the connection or violates the protocol
+func (c *graphqlWSConn) serve(ctx context.Context) {
+	acknowledged := false
+	c.conn.SetReadDeadline(time.Now().Add(graphqlInitTimeout))
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() && !acknowledged {
+				c.close(wsInitTimeout, "Connection initialisation timeout")
+			}
+			return
+		}
+		var msg wsMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			c.close(wsBadRequest, "Invalid message received")
+			return
+		}
+
+		switch msg.Type {
+		case wsConnectionInit:
+			if acknowledged {
+				c.close(wsTooManyInitRequests, "Too many initialisation requests")
+				return
+			}
+			acknowledged = true
+			c.conn.SetReadDeadline(time.Time{})
+			c.write(wsMessage{Type: wsConnectionAck})
+
+		case wsPing:
+			c.write(wsMessage{Type: wsPong})
+		case wsPong:
+
+		case wsSubscribe:
+			if !acknowledged {
+				c.close(wsUnauthorized, "Unauthorized")
+				return
+			}
+			var req api.GraphQLRequest
+			if msg.ID == "" || json.Unmarshal(msg.Payload, &req) != nil {
+				c.close(wsBadRequest, "Invalid message received")
+				return
+			}
+			if !c.start(ctx, msg.ID, req) {
+				c.close(wsSubscriberExists, "Subscriber for "+msg.ID+" already exists")
+				return
+			}
+
+		case wsComplete:
+			c.stop(msg.ID)
+
+		default:
+			c.close(wsBadRequest, "Invalid message received")
+			return
+		}
+	}
+}
+
+// start runs an operation, returning false when one with the same ID is running. Operations over the connection's
+// limit of running operations or the client's rate limit are refused with an error.
+func (c *graphqlWSConn) start(ctx context.Context, id string, req api.GraphQLRequest) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.operations[id]; ok {
+		return false
+	}
+	if max := c.s.currentConfig().GraphQL.MaxOperations; max > 0 && len(c.operations) >= max {
+		c.refuse(id, fmt.Sprintf("Too many operations running, at most %d can run at once", max))
+		return true
+	}
+	if res := c.s.limiter.allow(graphqlWSRoute, c.client); !res.Allowed {
+		c.refuse(id, fmt.Sprintf("Rate limit exceeded, retry in %ss", seconds(res.RetryAfter)))
+		return true
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	c.operations[id] = cancel
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer cancel()
+		c.run(ctx, id, req)
+	}()
+	return true
+}
+
+// refuse fails an operation that is not run
+func (c *graphqlWSConn) refuse(id string, message string) {
+	c.writePayload(id, wsError, []*api.GraphQLError{{
+		Message:    message,
+		Extensions: map[string]interface{}{"status": http.StatusTooManyRequests},
+	}})
+}
+
+// stop cancels an operation at the request of the client, which expects no more messages about it
+func (c *graphqlWSConn) stop(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cancel, ok := c.operations[id]; ok {
+		cancel()
+		delete(c.operations, id)
+	}
+}
+
+// finish forgets an operation that ran to its end, returning false when the client stopped it
+func (c *graphqlWSConn) finish(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.operations[id]; !ok {
+		return false
+	}
+	delete(c.operations, id)
+	return true
+}
+
+// run sends the responses of an operation: the single response of queries, or the events of subscriptions until the
+// client stops them or they end
+func (c *graphqlWSConn) run(ctx context.Context, id string, req api.GraphQLRequest) {
+	cost, errs := c.s.checkGraphQL(req)
+	if len(errs) > 0 {
+		if c.finish(id) {
+			c.writePayload(id, wsError, errs)
+		}
+		return
+	}
+
+	// Subscriptions live as long as the client wants them, like gRPC streams, queries are bounded like requests
+	if timeout := c.s.currentConfig().DBTimeout; cost.Operation != "subscription" && timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	end := &subscriptionEnd{}
+	ctx = withSubscriptionEnd(withGraphQLLoaders(ctx, c.s.newGraphQLLoaders(ctx)), end)
+
+	responses, err := c.s.graphql.Subscribe(ctx, req.Query, req.OperationName, req.Variables)
+	if err != nil {
+		if c.finish(id) {
+			c.writePayload(id, wsError, []*api.GraphQLError{{Message: err.Error()}})
+		}
+		return
+	}
+	for resp := range responses {
+		if r, ok := resp.(*graphql.Response); ok {
+			r.Extensions = costExtensions(cost)
+		}
+		c.writePayload(id, wsNext, resp)
+	}
+
+	if !c.finish(id) {
+		return
+	}
+	if end.err != nil {
+		c.writePayload(id, wsError, []*api.GraphQLError{{
+			Message:    end.err.Error(),
+			Extensions: errorExtensions(end.err),
+		}})
+		return
+	}
+	c.write(wsMessage{ID: id, Type: wsComplete})
+}
+
+// errorExtensions returns the extensions of the errors of resolvers
+func errorExtensions(err error) map[string]interface{} {
+	if e, ok := err.(graphqlError); ok {
+		return e.Extensions()
+	}
+	return nil
+}
+
+func (c *graphqlWSConn) writePayload(id string, msgType string, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		data, _ = json.Marshal([]*api.GraphQLError{{Message: err.Error()}})
+		msgType = wsError
+	}
+	c.write(wsMessage{ID: id, Type: msgType, Payload: data})
+}
+
+// write sends a message to the client, failures to write being noticed by serve as the connection breaks
+func (c *graphqlWSConn) write(msg wsMessage) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(graphqlWriteTimeout))
+	c.conn.WriteJSON(msg)
+}
+
+// close closes the connection with a code and reason telling the client how it violated the protocol
+func (c *graphqlWSConn) close(code int, reason string) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason),
+		time.Now().Add(graphqlWriteTimeout))
+	c.conn.Close()
+}
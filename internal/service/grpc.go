@@ -0,0 +1,753 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/api/tldrfeedpb"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+	"github.com/if-ivan-else/tldrfeed/internal/db/audit"
+	"github.com/if-ivan-else/tldrfeed/internal/dedup"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// serveGRPC serves the gRPC API on its port, over TLS when a TLS configuration is given
+func (s *Server) serveGRPC(tlsConfig *tls.Config) {
+	addr := ":" + strconv.Itoa(s.currentConfig().GRPC.Port)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Serving gRPC on %s", addr)
+	log.Fatal(s.newGRPCServer(tlsConfig).Serve(listener))
+}
+
+// newGRPCServer creates a gRPC server of the tldrfeed service sharing the repository, validation and publish hooks
+// of the REST API. Calls are identified, traced, authenticated, logged, rate limited, made safe to retry and held to
+// quotas like REST requests.
+func (s *Server) newGRPCServer(tlsConfig *tls.Config) *grpc.Server {
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(s.grpcUnaryInterceptor, s.grpcRateLimitInterceptor, s.grpcIdempotencyInterceptor,
+			s.grpcQuotaInterceptor),
+		grpc.ChainStreamInterceptor(s.grpcStreamInterceptor, s.grpcStreamRateLimitInterceptor),
+	}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	server := grpc.NewServer(opts...)
+	tldrfeedpb.RegisterTLDRFeedServer(server, &grpcService{s: s})
+	return server
+}
+
+// errorToCode maps repository errors to gRPC status codes the way errorToStatus maps them to HTTP statuses
+func errorToCode(e error) codes.Code {
+	switch e {
+	case db.ErrNotImplemented:
+		return codes.Unimplemented
+
+	case context.DeadlineExceeded:
+		return codes.DeadlineExceeded
+	case context.Canceled:
+		return codes.Canceled
+
+	case db.ErrUserExists:
+		fallthrough
+	case db.ErrIdempotencyKeyExists:
+		fallthrough
+	case db.ErrRecordExists:
+		return codes.AlreadyExists
+	case db.ErrArticlePublished:
+		return codes.FailedPrecondition
+
+	case db.ErrNoSuchFeed:
+		fallthrough
+	case db.ErrNoSuchUser:
+		fallthrough
+	case db.ErrNotSubscribed:
+		fallthrough
+	case db.ErrNoSuchFilterRule:
+		fallthrough
+	case db.ErrNoSuchDraft:
+		fallthrough
+	case db.ErrNoSuchArticle:
+		return codes.NotFound
+	default:
+		return codes.Internal
+	}
+}
+
+// grpcError converts a repository error to a gRPC status error
+func grpcError(err error) error {
+	return status.Error(errorToCode(err), err.Error())
+}
+
+// invalidArgument converts a validation error to a gRPC status error
+func invalidArgument(err error) error {
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+// serverFault returns true for the codes of failures of the service rather than of the call, logged as errors
+func serverFault(code codes.Code) bool {
+	switch code {
+	case codes.Unknown, codes.DeadlineExceeded, codes.Unimplemented, codes.Internal, codes.Unavailable, codes.DataLoss:
+		return true
+	}
+	return false
+}
+
+// grpcCall describes a gRPC call for its log entry
+type grpcCall struct {
+	start     time.Time
+	id        string
+	method    string
+	principal string
+	span      trace.Span
+}
+
+// startGRPCCall identifies a gRPC call by its X-Request-ID metadata, assigning an ID when missing, traces it in a
+// span continuing the caller's trace and records its changes in the audit log as made by its principal
+func (s *Server) startGRPCCall(ctx context.Context, method string) (context.Context, *grpcCall, error) {
+	call := &grpcCall{start: time.Now(), method: method}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if ids := md.Get(api.RequestIDHeader); len(ids) > 0 && ids[0] != "" {
+		call.id = ids[0]
+	} else {
+		call.id = uuid.New().String()
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(api.RequestIDHeader, call.id))
+
+	ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	ctx, call.span = tracer().Start(ctx, strings.TrimPrefix(method, "/"),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "grpc"),
+			attribute.String("rpc.method", method),
+			attribute.String("rpc.request_id", call.id),
+		),
+	)
+
+	principal, err := s.grpcPrincipal(ctx)
+	if err != nil {
+		return ctx, call, err
+	}
+	call.principal = principal
+	return audit.NewContext(ctx, audit.Actor{Principal: principal, RequestID: call.id}), call, nil
+}
+
+// finish ends the span of a gRPC call and logs it with its ID, method, principal, status code and latency
+func (call *grpcCall) finish(ctx context.Context, err error) {
+	code := status.Code(err)
+	call.span.SetAttributes(attribute.Int("rpc.grpc.status_code", int(code)))
+	if serverFault(code) {
+		call.span.SetStatus(otelcodes.Error, code.String())
+	}
+	call.span.End()
+
+	attrs := []slog.Attr{
+		slog.String("request_id", call.id),
+		slog.String("method", call.method),
+		slog.String("code", code.String()),
+		slog.Float64("latency_ms", float64(time.Since(call.start))/float64(time.Millisecond)),
+	}
+	if call.principal != "" {
+		attrs = append(attrs, slog.String("principal", call.principal))
+	}
+	if sc := call.span.SpanContext(); sc.HasTraceID() {
+		attrs = append(attrs, slog.String("trace_id", sc.TraceID().String()))
+	}
+	level := slog.LevelInfo
+	if serverFault(code) {
+		level = slog.LevelError
+	}
+	slog.LogAttrs(ctx, level, "Served gRPC call", attrs...)
+}
+
+// grpcPrincipal maps the verified client certificate of a call to a principal like clientCertAuth does for REST
+// requests, calls without one have no principal
+func (s *Server) grpcPrincipal(ctx context.Context) (string, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", nil
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 {
+		return "", nil
+	}
+
+	auth := &clientCertAuth{principals: s.currentConfig().TLS.Principals}
+	principal, ok := auth.principal(info.State.VerifiedChains[0][0])
+	if !ok {
+		return "", status.Error(codes.PermissionDenied, "Client certificate subject is not mapped to a principal")
+	}
+	return principal, nil
+}
+
+// grpcUnaryInterceptor prepares unary calls like the REST middleware prepares requests, bounding their time in the DB
+func (s *Server) grpcUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, call, err := s.startGRPCCall(ctx, info.FullMethod)
+	if err != nil {
+		call.finish(ctx, err)
+		return nil, err
+	}
+
+	if timeout := s.currentConfig().DBTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	resp, err := handler(ctx, req)
+	call.finish(ctx, err)
+	return resp, err
+}
+
+// grpcStreamInterceptor prepares streaming calls like the REST middleware prepares requests. Streams last until the
+// client goes away, so their time in the DB is not bounded.
+func (s *Server) grpcStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+	ctx, call, err := s.startGRPCCall(ss.Context(), info.FullMethod)
+	if err != nil {
+		call.finish(ctx, err)
+		return err
+	}
+
+	err = handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	call.finish(ctx, err)
+	return err
+}
+
+// grpcRoutes maps gRPC methods to the REST routes they mirror, so that calls take tokens of the rate limits of the
+// routes. Methods without a REST route are limited under their own name.
+var grpcRoutes = map[string]string{
+	tldrfeedpb.TLDRFeed_CreateUser_FullMethodName:        "createUser",
+	tldrfeedpb.TLDRFeed_ListUsers_FullMethodName:         "listUsers",
+	tldrfeedpb.TLDRFeed_GetUser_FullMethodName:           "getUser",
+	tldrfeedpb.TLDRFeed_CreateFeed_FullMethodName:        "createFeed",
+	tldrfeedpb.TLDRFeed_ListFeeds_FullMethodName:         "listFeeds",
+	tldrfeedpb.TLDRFeed_GetFeed_FullMethodName:           "getFeed",
+	tldrfeedpb.TLDRFeed_CreateArticle_FullMethodName:     "createFeedArticle",
+	tldrfeedpb.TLDRFeed_ListFeedArticles_FullMethodName:  "listFeedArticles",
+	tldrfeedpb.TLDRFeed_Subscribe_FullMethodName:         "addUserFeed",
+	tldrfeedpb.TLDRFeed_Unsubscribe_FullMethodName:       "removeUserFeed",
+	tldrfeedpb.TLDRFeed_ListUserFeeds_FullMethodName:     "listUserFeeds",
+	tldrfeedpb.TLDRFeed_ListUserArticles_FullMethodName:  "listUserArticles",
+	tldrfeedpb.TLDRFeed_WatchUserArticles_FullMethodName: "watchUserArticles",
+}
+
+// grpcClientKey identifies the client making a gRPC call according to the configured key like clientKey identifies
+// the clients of requests: by the API key of the x-api-key metadata, by principal or the User the call accesses, or by
+// IP address
+func (c RateLimitConfig) grpcClientKey(ctx context.Context, req interface{}) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	switch c.Key {
+	case RateLimitKeyAPIKey:
+		if keys := md.Get(APIKeyHeader); len(keys) > 0 {
+			if client := c.apiClient(keys[0]); client != "" {
+				return "key:" + client
+			}
+		}
+		if p := audit.FromContext(ctx).Principal; p != "" {
+			return "principal:" + p
+		}
+	case RateLimitKeyUser:
+		if p := audit.FromContext(ctx).Principal; p != "" {
+			return "principal:" + p
+		}
+		if r, ok := req.(interface{ GetUserId() string }); ok && r.GetUserId() != "" {
+			return "user:" + r.GetUserId()
+		}
+	}
+
+	return "ip:" + grpcPeerIP(ctx)
+}
+
+// grpcClientIdentity identifies the client making a gRPC call like clientIdentity, whatever the configured key
+func (c RateLimitConfig) grpcClientIdentity(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	apiKey := ""
+	if keys := md.Get(APIKeyHeader); len(keys) > 0 {
+		apiKey = keys[0]
+	}
+	return c.clientIdentity(audit.FromContext(ctx).Principal, apiKey, grpcPeerIP(ctx))
+}
+
+func grpcPeerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	ip := p.Addr.String()
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return ip
+}
+
+// allowGRPCCall takes a token of the rate limit of the route a gRPC method mirrors, telling the client about the limit
+// in the RateLimit headers of the call. Calls over the limit fail with ResourceExhausted.
+func (s *Server) allowGRPCCall(ctx context.Context, method string, req interface{}) error {
+	route, ok := grpcRoutes[method]
+	if !ok {
+		route = method
+	}
+	res := s.limiter.allow(route, s.limiter.currentConfig().grpcClientKey(ctx, req))
+	if res.Limit == 0 {
+		return nil
+	}
+
+	md := metadata.Pairs(
+		"ratelimit-limit", strconv.Itoa(res.Limit),
+		"ratelimit-remaining", strconv.Itoa(res.Remaining),
+		"ratelimit-reset", seconds(res.Reset),
+	)
+	if !res.Allowed {
+		md.Set("retry-after", seconds(res.RetryAfter))
+	}
+	grpc.SetHeader(ctx, md)
+	if !res.Allowed {
+		return status.Error(codes.ResourceExhausted, "Rate limit exceeded, retry later")
+	}
+	return nil
+}
+
+// grpcRateLimitInterceptor applies rate limits to unary calls like the REST middleware applies them to requests
+func (s *Server) grpcRateLimitInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	if err := s.allowGRPCCall(ctx, info.FullMethod, req); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// grpcStreamRateLimitInterceptor applies rate limits to streaming calls as they start
+func (s *Server) grpcStreamRateLimitInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+	if err := s.allowGRPCCall(ss.Context(), info.FullMethod, nil); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// grpcIdempotentMethods lists the unary methods made safe to retry like the POST routes of the REST API, with the
+// type of their responses
+var grpcIdempotentMethods = map[string]func() proto.Message{
+	tldrfeedpb.TLDRFeed_CreateUser_FullMethodName:    func() proto.Message { return &tldrfeedpb.User{} },
+	tldrfeedpb.TLDRFeed_CreateFeed_FullMethodName:    func() proto.Message { return &tldrfeedpb.Feed{} },
+	tldrfeedpb.TLDRFeed_CreateArticle_FullMethodName: func() proto.Message { return &tldrfeedpb.CreateArticleResponse{} },
+}
+
+// grpcContentType is the content type of the responses to gRPC calls stored for their idempotency keys
+const grpcContentType = "application/grpc+proto"
+
+// grpcIdempotencyInterceptor makes the calls of idempotent methods carrying idempotency-key metadata safe to retry
+// like the REST idempotency middleware: the first response is stored and replayed for retries with the same key and
+// request. Failed calls are not stored, so that their retries are made again.
+func (s *Server) grpcIdempotencyInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	newResponse, ok := grpcIdempotentMethods[info.FullMethod]
+	md, _ := metadata.FromIncomingContext(ctx)
+	keys := md.Get(api.IdempotencyKeyHeader)
+	if !ok || len(keys) == 0 || keys[0] == "" {
+		return handler(ctx, req)
+	}
+	key := keys[0]
+
+	body, err := proto.MarshalOptions{Deterministic: true}.Marshal(req.(proto.Message))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	digest := sha256.Sum256(body)
+	requestHash := hex.EncodeToString(digest[:])
+
+	// Keys are scoped to the client and the method they are used with, and reserved for the lease only
+	config := s.currentConfig().Idempotency
+	scopedKey := s.limiter.currentConfig().grpcClientIdentity(ctx) + "|" + info.FullMethod + "|" + key
+	err = s.repo.CreateIdempotencyRecord(ctx, db.IdempotencyRecord{
+		Key:         scopedKey,
+		RequestHash: requestHash,
+		ExpiresTime: time.Now().Add(config.Lease),
+	})
+	if err == db.ErrIdempotencyKeyExists {
+		return s.replayGRPCIdempotent(ctx, scopedKey, requestHash, newResponse())
+	}
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	// The outcome is stored even when the client gave up on the call meanwhile, so that its retry does not find the
+	// key still being processed
+	storeCtx := context.Background()
+	served := false
+	defer func() {
+		if !served {
+			// The handler panicked, release the key rather than leave retries refused until the lease expires
+			if err := s.repo.DeleteIdempotencyRecord(storeCtx, scopedKey); err != nil {
+				log.Printf("Failed to release idempotency key '%s': %s", key, err)
+			}
+		}
+	}()
+
+	resp, callErr := handler(ctx, req)
+	served = true
+
+	if callErr != nil {
+		err = s.repo.DeleteIdempotencyRecord(storeCtx, scopedKey)
+	} else if body, err = proto.Marshal(resp.(proto.Message)); err == nil {
+		// Calls of idempotent methods create records, stored with the status of the REST API's creates
+		err = s.repo.CompleteIdempotencyRecord(storeCtx, scopedKey, http.StatusCreated, grpcContentType, body,
+			time.Now().Add(config.TTL))
+	}
+	if err != nil {
+		log.Printf("Failed to store response for idempotency key '%s': %s", key, err)
+	}
+	return resp, callErr
+}
+
+// replayGRPCIdempotent answers a retried call with the response stored for its key
+func (s *Server) replayGRPCIdempotent(ctx context.Context, key string, requestHash string, resp proto.Message) (interface{}, error) {
+	record, err := s.repo.GetIdempotencyRecord(ctx, key)
+	if err != nil {
+		// The record expired between reserving and reading it
+		if err == db.ErrNoSuchIdempotencyKey {
+			err = db.ErrIdempotencyKeyExists
+		}
+		return nil, grpcError(err)
+	}
+
+	if record.RequestHash != requestHash {
+		return nil, status.Error(codes.AlreadyExists, "Idempotency key was already used with a different request")
+	}
+	if !record.Completed() {
+		return nil, status.Error(codes.Aborted, "A call with the same idempotency key is still being processed")
+	}
+	if err := proto.Unmarshal(record.Body, resp); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(ReplayedHeader, "true"))
+	return resp, nil
+}
+
+// grpcQuotaInterceptor enforces the hourly publishing quotas of Feeds on the calls adding Articles, like the REST
+// routes adding Articles do
+func (s *Server) grpcQuotaInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	if r, ok := req.(*tldrfeedpb.CreateArticleRequest); ok {
+		remaining, err := s.remainingFeedQuota(ctx, r.GetFeedId())
+		if err != nil {
+			return nil, grpcError(err)
+		}
+		if remaining == 0 {
+			// The window slides, so retrying after a full hour is guaranteed to succeed
+			grpc.SetHeader(ctx, metadata.Pairs("retry-after", seconds(time.Hour)))
+			return nil, status.Error(codes.ResourceExhausted, s.feedQuotaExceeded(r.GetFeedId()))
+		}
+	}
+	return handler(ctx, req)
+}
+
+// serverStream is a grpc.ServerStream with the context prepared by grpcStreamInterceptor
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// metadataCarrier adapts gRPC metadata to OpenTelemetry propagation
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (c metadataCarrier) Set(key string, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// grpcService implements the tldrfeed gRPC service on top of the Server
+type grpcService struct {
+	tldrfeedpb.UnimplementedTLDRFeedServer
+	s *Server
+}
+
+func (g *grpcService) CreateUser(ctx context.Context, req *tldrfeedpb.CreateUserRequest) (*tldrfeedpb.User, error) {
+	userRequest := api.CreateUserRequest{Name: req.GetName()}
+	if err := validate(&userRequest); err != nil {
+		return nil, invalidArgument(err)
+	}
+
+	user, err := g.s.repo.CreateUser(ctx, userRequest.Name)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return tldrfeedpb.FromUser(*user), nil
+}
+
+func (g *grpcService) ListUsers(ctx context.Context, req *tldrfeedpb.ListUsersRequest) (*tldrfeedpb.ListUsersResponse, error) {
+	users, err := g.s.repo.ListUsers(ctx, db.Page{})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	resp := &tldrfeedpb.ListUsersResponse{}
+	for _, u := range users {
+		resp.Users = append(resp.Users, tldrfeedpb.FromUser(u))
+	}
+	return resp, nil
+}
+
+func (g *grpcService) GetUser(ctx context.Context, req *tldrfeedpb.GetUserRequest) (*tldrfeedpb.User, error) {
+	user, err := g.s.repo.GetUser(ctx, req.GetUserId())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return tldrfeedpb.FromUser(*user), nil
+}
+
+func (g *grpcService) CreateFeed(ctx context.Context, req *tldrfeedpb.CreateFeedRequest) (*tldrfeedpb.Feed, error) {
+	feedRequest := api.CreateFeedRequest{
+		Name:      req.GetName(),
+		Category:  req.GetCategory(),
+		Retention: req.GetRetention().ToAPI(),
+	}
+	if err := validate(&feedRequest); err != nil {
+		return nil, invalidArgument(err)
+	}
+	category, err := normalizeCategory(feedRequest.Category)
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	retention, err := normalizeRetention(feedRequest.Retention)
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+
+	feed, err := g.s.createFeed(ctx, feedRequest.Name, category, retention)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return tldrfeedpb.FromFeed(*feed), nil
+}
+
+func (g *grpcService) ListFeeds(ctx context.Context, req *tldrfeedpb.ListFeedsRequest) (*tldrfeedpb.ListFeedsResponse, error) {
+	category, err := normalizeCategory(req.GetCategory())
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+
+	feeds, err := g.s.repo.ListFeeds(ctx, db.FeedFilter{Category: category})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return feedList(feeds), nil
+}
+
+func (g *grpcService) GetFeed(ctx context.Context, req *tldrfeedpb.GetFeedRequest) (*tldrfeedpb.Feed, error) {
+	feed, err := g.s.repo.GetFeed(ctx, req.GetFeedId())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return tldrfeedpb.FromFeed(*feed), nil
+}
+
+func (g *grpcService) CreateArticle(ctx context.Context, req *tldrfeedpb.CreateArticleRequest) (*tldrfeedpb.CreateArticleResponse, error) {
+	articleRequest := req.ToAPI()
+	if err := validate(&articleRequest); err != nil {
+		return nil, invalidArgument(err)
+	}
+	article, err := newArticle(&articleRequest, time.Now())
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+
+	articleID, err := g.s.addArticle(ctx, req.GetFeedId(), article)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &tldrfeedpb.CreateArticleResponse{Id: articleID}, nil
+}
+
+func (g *grpcService) ListFeedArticles(ctx context.Context, req *tldrfeedpb.ListFeedArticlesRequest) (*tldrfeedpb.ListArticlesResponse, error) {
+	opts, err := parseListOptions(req.GetOptions())
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+
+	articles, err := g.s.repo.ListFeedArticles(ctx, req.GetFeedId(), opts.filter)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return articleList(g.s.presentArticles(articles, opts.view, opts.format)), nil
+}
+
+func (g *grpcService) Subscribe(ctx context.Context, req *tldrfeedpb.SubscribeRequest) (*tldrfeedpb.SubscribeResponse, error) {
+	subscribeRequest := api.AddUserFeedRequest{FeedID: req.GetFeedId()}
+	if err := validate(&subscribeRequest); err != nil {
+		return nil, invalidArgument(err)
+	}
+
+	if err := g.s.repo.AddUserFeed(ctx, req.GetUserId(), subscribeRequest.FeedID); err != nil {
+		return nil, grpcError(err)
+	}
+	return &tldrfeedpb.SubscribeResponse{}, nil
+}
+
+func (g *grpcService) Unsubscribe(ctx context.Context, req *tldrfeedpb.UnsubscribeRequest) (*tldrfeedpb.UnsubscribeResponse, error) {
+	if err := g.s.repo.RemoveUserFeed(ctx, req.GetUserId(), req.GetFeedId()); err != nil {
+		return nil, grpcError(err)
+	}
+	return &tldrfeedpb.UnsubscribeResponse{}, nil
+}
+
+func (g *grpcService) ListUserFeeds(ctx context.Context, req *tldrfeedpb.ListUserFeedsRequest) (*tldrfeedpb.ListFeedsResponse, error) {
+	feeds, err := g.s.repo.ListUserFeeds(ctx, req.GetUserId())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return feedList(feeds), nil
+}
+
+func (g *grpcService) ListUserArticles(ctx context.Context, req *tldrfeedpb.ListUserArticlesRequest) (*tldrfeedpb.ListArticlesResponse, error) {
+	opts, err := parseListOptions(req.GetOptions())
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+	if opts.filter.Rules, _, err = g.s.userRules(ctx, req.GetUserId()); err != nil {
+		return nil, grpcError(err)
+	}
+
+	var articles []api.Article
+	if req.GetFeedId() != "" {
+		articles, err = g.s.repo.ListUserFeedArticles(ctx, req.GetUserId(), req.GetFeedId(), opts.filter)
+	} else {
+		articles, err = g.s.repo.ListUserArticles(ctx, req.GetUserId(), opts.filter)
+		if err == nil && opts.collapse {
+			articles = dedup.Collapse(articles)
+		}
+	}
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return articleList(g.s.presentArticles(articles, opts.view, opts.format)), nil
+}
+
+// WatchUserArticles streams the Articles published to the Feeds a User follows, with the User's filter rules applied.
+// Changes to the User's subscriptions and filter rules apply to the stream once it refreshes them.
+func (g *grpcService) WatchUserArticles(req *tldrfeedpb.WatchUserArticlesRequest, stream tldrfeedpb.TLDRFeed_WatchUserArticlesServer) error {
+	ctx := stream.Context()
+	opts, err := parseListOptions(req.GetOptions())
+	if err != nil {
+		return invalidArgument(err)
+	}
+	if _, err := g.s.repo.GetUser(ctx, req.GetUserId()); err != nil {
+		return grpcError(err)
+	}
+
+	// Watching starts before the headers are sent so that clients can list the User's Articles once they get them
+	// without missing any published in between
+	watch, err := g.s.watchUser(ctx, req.GetUserId(), opts.filter.Tag)
+	if err != nil {
+		return grpcError(err)
+	}
+	defer watch.close()
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-watch.refreshes():
+			if err := watch.refresh(ctx); err != nil {
+				return grpcError(err)
+			}
+		case article, ok := <-watch.articles():
+			if !ok {
+				return status.Error(codes.ResourceExhausted,
+					"Watcher fell behind the Articles published, list the User's Articles to catch up and watch again")
+			}
+			if !watch.watches(article) {
+				continue
+			}
+			if err := stream.Send(tldrfeedpb.FromArticle(g.s.presentArticles([]api.Article{*article}, opts.view, opts.format)[0])); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// listOptions are the checked Article list options of a call
+type listOptions struct {
+	view     string
+	format   string
+	filter   db.ArticleFilter
+	collapse bool
+}
+
+// parseListOptions checks Article list options like the query parameters of REST Article listings
+func parseListOptions(o *tldrfeedpb.ArticleListOptions) (listOptions, error) {
+	opts := listOptions{collapse: o.GetCollapseDuplicates()}
+	var err error
+	if opts.view, err = parseView(o.GetView()); err != nil {
+		return opts, err
+	}
+	if opts.format, err = parseBodyFormat(o.GetBodyFormat()); err != nil {
+		return opts, err
+	}
+	if opts.filter, err = tagFilter(o.GetTag()); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+func feedList(feeds []api.Feed) *tldrfeedpb.ListFeedsResponse {
+	resp := &tldrfeedpb.ListFeedsResponse{}
+	for _, f := range feeds {
+		resp.Feeds = append(resp.Feeds, tldrfeedpb.FromFeed(f))
+	}
+	return resp
+}
+
+func articleList(articles []api.Article) *tldrfeedpb.ListArticlesResponse {
+	resp := &tldrfeedpb.ListArticlesResponse{}
+	for _, a := range articles {
+		resp.Articles = append(resp.Articles, tldrfeedpb.FromArticle(a))
+	}
+	return resp
+}
@@ -0,0 +1,298 @@
+package service
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/api/grpcclient"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testGRPCClient serves the gRPC API of server on a local port and returns a client of it
+func testGRPCClient(t *testing.T, server *Server) *grpcclient.Client {
+	require := require.New(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	grpcServer := server.newGRPCServer(nil)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	client, err := grpcclient.NewClient(listener.Addr().String())
+	require.NoError(err)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func requireCode(require *require.Assertions, code codes.Code, err error) {
+	require.Error(err)
+	require.Equal(code, status.Code(err), "gRPC Error: %s", err)
+}
+
+func TestGRPCUsersAndFeeds(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	client := testGRPCClient(t, testServer())
+
+	user, err := client.CreateUser(ctx, "ivan")
+	require.NoError(err)
+	require.NotEmpty(user.ID)
+	require.Equal("ivan", user.Name)
+
+	got, err := client.GetUser(ctx, user.ID)
+	require.NoError(err)
+	require.Equal(user, got)
+	users, err := client.ListUsers(ctx)
+	require.NoError(err)
+	require.Equal([]api.User{*user}, users)
+	_, err = client.GetUser(ctx, "nobody")
+	requireCode(require, codes.NotFound, err)
+
+	feed, err := client.CreateFeed(ctx, &api.CreateFeedRequest{
+		Name:      "Chaikovsky Breaking News",
+		Category:  "News",
+		Retention: &api.RetentionPolicy{MaxCount: 10},
+	})
+	require.NoError(err)
+	require.Equal("news", feed.Category)
+	require.Equal(&api.RetentionPolicy{MaxCount: 10}, feed.Retention)
+	_, err = client.CreateFeed(ctx, &api.CreateFeedRequest{Name: "Rakhmaninov Sports"})
+	require.NoError(err)
+
+	got2, err := client.GetFeed(ctx, feed.ID)
+	require.NoError(err)
+	require.Equal(feed, got2)
+	feeds, err := client.ListFeeds(ctx, "news")
+	require.NoError(err)
+	require.Equal([]api.Feed{*feed}, feeds)
+	feeds, err = client.ListFeeds(ctx, "")
+	require.NoError(err)
+	require.Len(feeds, 2)
+	_, err = client.GetFeed(ctx, "nothing")
+	requireCode(require, codes.NotFound, err)
+}
+
+func TestGRPCArticlesAndSubscriptions(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	client := testGRPCClient(t, testServer())
+
+	user, err := client.CreateUser(ctx, "ivan")
+	require.NoError(err)
+	news, err := client.CreateFeed(ctx, &api.CreateFeedRequest{Name: "Chaikovsky Breaking News"})
+	require.NoError(err)
+
+	article, err := client.PublishArticle(ctx, news.ID, &api.CreateArticleRequest{
+		Title: "Election Night",
+		Body:  "Polls close at eight. Results are expected by midnight.",
+		Tags:  []string{"politics"},
+	})
+	require.NoError(err)
+	require.NotEmpty(article.ID)
+	_, err = client.PublishArticle(ctx, news.ID, &api.CreateArticleRequest{Title: "Weather", Body: "Sunny", Status: api.StatusDraft})
+	require.NoError(err)
+	_, err = client.PublishArticle(ctx, news.ID, &api.CreateArticleRequest{Title: "Weather", Body: "Sunny", Status: "pending"})
+	requireCode(require, codes.InvalidArgument, err)
+	_, err = client.PublishArticle(ctx, "nothing", &api.CreateArticleRequest{Title: "Weather", Body: "Sunny"})
+	requireCode(require, codes.NotFound, err)
+
+	articles, err := client.ListArticles(ctx, news.ID, api.ArticleListOptions{})
+	require.NoError(err)
+	require.Len(articles, 1)
+	require.Equal(article.ID, articles[0].ID)
+	require.Equal("Polls close at eight. Results are expected by midnight.", articles[0].Body)
+	require.False(articles[0].PublishedTime.IsZero())
+
+	articles, err = client.ListArticles(ctx, news.ID, api.ArticleListOptions{View: "tldr"})
+	require.NoError(err)
+	require.Empty(articles[0].Body)
+	_, err = client.ListArticles(ctx, news.ID, api.ArticleListOptions{View: "short"})
+	requireCode(require, codes.InvalidArgument, err)
+
+	require.NoError(client.Subscribe(ctx, user.ID, news.ID))
+	feeds, err := client.ListUserFeeds(ctx, user.ID)
+	require.NoError(err)
+	require.Equal([]api.Feed{*news}, feeds)
+
+	articles, err = client.ListUserArticles(ctx, user.ID, "", api.ArticleListOptions{Tag: "politics"})
+	require.NoError(err)
+	require.Len(articles, 1)
+	articles, err = client.ListUserArticles(ctx, user.ID, news.ID, api.ArticleListOptions{Tag: "sports"})
+	require.NoError(err)
+	require.Empty(articles)
+
+	require.NoError(client.Unsubscribe(ctx, user.ID, news.ID))
+	requireCode(require, codes.NotFound, client.Unsubscribe(ctx, user.ID, news.ID))
+	_, err = client.ListUserArticles(ctx, user.ID, news.ID, api.ArticleListOptions{})
+	requireCode(require, codes.NotFound, err)
+}
+
+func TestGRPCWatchUserArticles(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	server := testServer()
+	client := testGRPCClient(t, server)
+
+	user, _ := server.repo.CreateUser(ctx, "ivan")
+	news, _ := server.repo.CreateFeed(ctx, "Chaikovsky Breaking News", "", nil)
+	sports, _ := server.repo.CreateFeed(ctx, "Rakhmaninov Sports", "", nil)
+	require.NoError(server.repo.AddUserFeed(ctx, user.ID, news.ID))
+
+	req, _ := http.NewRequest("POST", "/api/v1/users/"+user.ID+"/filters", strings.NewReader(`{"type": "keyword", "value": "election"}`))
+	rr := httptest.NewRecorder()
+	router(server).ServeHTTP(rr, req)
+	requireStatus(http.StatusCreated, require, rr)
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	watched := make(chan api.Article)
+	done := make(chan error, 1)
+	go func() {
+		done <- client.WatchUserArticles(watchCtx, user.ID, api.ArticleListOptions{View: "tldr"}, func(a api.Article) error {
+			watched <- a
+			return nil
+		})
+	}()
+	require.Eventually(func() bool { return server.watchers.count() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// Articles of Feeds the User does not follow, hidden by their rules or not published are not streamed
+	publish := func(feedID string, r api.CreateArticleRequest) {
+		_, err := client.PublishArticle(ctx, feedID, &r)
+		require.NoError(err)
+	}
+	publish(sports.ID, api.CreateArticleRequest{Title: "Cup final", Body: "Highlights of the match"})
+	publish(news.ID, api.CreateArticleRequest{Title: "Election Night", Body: "Polls close at eight"})
+	publish(news.ID, api.CreateArticleRequest{Title: "Budget", Body: "Draft numbers", Status: api.StatusDraft})
+	publish(news.ID, api.CreateArticleRequest{Title: "Weather", Body: "Sunny all week"})
+
+	select {
+	case a := <-watched:
+		require.Equal("Weather", a.Title)
+		require.Equal(news.ID, a.FeedID)
+		require.Empty(a.Body)
+	case err := <-done:
+		require.FailNow("Watch ended", "%v", err)
+	case <-time.After(5 * time.Second):
+		require.FailNow("No Article watched")
+	}
+
+	cancel()
+	requireCode(require, codes.Canceled, <-done)
+	require.Eventually(func() bool { return server.watchers.count() == 0 }, 5*time.Second, 10*time.Millisecond)
+
+	err := client.WatchUserArticles(ctx, "nobody", api.ArticleListOptions{}, func(api.Article) error { return nil })
+	requireCode(require, codes.NotFound, err)
+}
+
+func TestArticleWatchersFallBehind(t *testing.T) {
+	require := require.New(t)
+	w := newArticleWatchers()
+
+	slow, stop := w.watch("")
+	defer stop()
+	for i := 0; i < watchBuffer+1; i++ {
+		w.publish(api.Article{Title: "Weather"})
+	}
+	require.Equal(0, w.count())
+
+	// The Articles buffered are received before the channel closes
+	received := 0
+	for range slow.articles {
+		received++
+	}
+	require.Equal(watchBuffer, received)
+}
+
+func TestUserWatch(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	server := testServer()
+
+	user, _ := server.repo.CreateUser(ctx, "ivan")
+	news, _ := server.repo.CreateFeed(ctx, "Chaikovsky Breaking News", "", nil)
+	sports, _ := server.repo.CreateFeed(ctx, "Rakhmaninov Sports", "", nil)
+	require.NoError(server.repo.AddUserFeed(ctx, user.ID, news.ID))
+
+	watch, err := server.watchUser(ctx, user.ID, "")
+	require.NoError(err)
+	defer watch.close()
+
+	// Only the Articles of the Feeds followed are handed to the watch
+	server.watchers.publish(api.Article{Title: "Cup final", FeedID: sports.ID})
+	server.watchers.publish(api.Article{Title: "Election Night", FeedID: news.ID})
+	require.Len(watch.articles(), 1)
+	article := <-watch.articles()
+	require.Equal("Election Night", article.Title)
+	require.True(watch.watches(article))
+
+	// Subscriptions and filter rules are picked up once refreshed
+	require.NoError(server.repo.AddUserFeed(ctx, user.ID, sports.ID))
+	_, err = server.repo.CreateFilterRule(ctx, user.ID, api.FilterRule{Type: api.FilterKeyword, Value: "weather", Action: api.FilterExclude})
+	require.NoError(err)
+	require.NoError(watch.refresh(ctx))
+	server.watchers.publish(api.Article{Title: "Cup final", FeedID: sports.ID})
+	server.watchers.publish(api.Article{Title: "Weather", FeedID: news.ID})
+	require.Len(watch.articles(), 2)
+	require.True(watch.watches(<-watch.articles()))
+	require.False(watch.watches(<-watch.articles()))
+
+	_, err = server.watchUser(ctx, "nobody", "")
+	require.Equal(db.ErrNoSuchUser, err)
+	require.Equal(1, server.watchers.count())
+}
+
+func TestGRPCLimits(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+	server := testServer()
+	config := testConfig()
+	config.RateLimit = RateLimitConfig{Key: RateLimitKeyIP, Routes: []RouteRateLimit{{Route: "createFeed", Rate: 0.001, Burst: 1}}}
+	config.Quotas = QuotaConfig{FeedArticlesPerHour: 1}
+	server.Reload(config)
+	client := testGRPCClient(t, server)
+
+	// Calls take tokens of the rate limits of the routes they mirror
+	feed, err := client.CreateFeed(ctx, &api.CreateFeedRequest{Name: "Chaikovsky Breaking News"})
+	require.NoError(err)
+	_, err = client.CreateFeed(ctx, &api.CreateFeedRequest{Name: "Rakhmaninov Sports"})
+	requireCode(require, codes.ResourceExhausted, err)
+
+	// Retries with the same idempotency key get the response to the first call, which used up the quota of the Feed
+	keyed := grpcclient.WithIdempotencyKey(ctx, "weather-1")
+	article := &api.CreateArticleRequest{Title: "Weather", Body: "Sunny all week"}
+	first, err := client.PublishArticle(keyed, feed.ID, article)
+	require.NoError(err)
+	retry, err := client.PublishArticle(keyed, feed.ID, article)
+	require.NoError(err)
+	require.Equal(first.ID, retry.ID)
+	articles, err := server.repo.ListFeedArticles(ctx, feed.ID, db.ArticleFilter{})
+	require.NoError(err)
+	require.Len(articles, 1)
+
+	_, err = client.PublishArticle(keyed, feed.ID, &api.CreateArticleRequest{Title: "Storm", Body: "Rain all week"})
+	requireCode(require, codes.AlreadyExists, err)
+	_, err = client.PublishArticle(grpcclient.WithIdempotencyKey(ctx, "weather-2"), feed.ID, article)
+	requireCode(require, codes.ResourceExhausted, err)
+	require.Contains(err.Error(), "exceeded its quota of 1 Articles per hour")
+}
+
+func TestErrorToCode(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal(codes.NotFound, errorToCode(db.ErrNoSuchUser))
+	require.Equal(codes.NotFound, errorToCode(db.ErrNotSubscribed))
+	require.Equal(codes.AlreadyExists, errorToCode(db.ErrUserExists))
+	require.Equal(codes.FailedPrecondition, errorToCode(db.ErrArticlePublished))
+	require.Equal(codes.Unimplemented, errorToCode(db.ErrNotImplemented))
+	require.Equal(codes.DeadlineExceeded, errorToCode(context.DeadlineExceeded))
+	require.Equal(codes.Internal, errorToCode(net.ErrClosed))
+}
@@ -2,17 +2,38 @@ package service
 
 import (
 	"net/http/httptest"
+	"time"
 
 	"github.com/if-ivan-else/tldrfeed/internal/db/mock"
+	"github.com/if-ivan-else/tldrfeed/internal/summarize"
 	"github.com/stretchr/testify/require"
 )
 
+func testConfig() Config {
+	return Config{
+		IndentJSON: true,
+		Port:       8080,
+		Idempotency: IdempotencyConfig{
+			TTL:          time.Hour,
+			Lease:        time.Minute,
+			MaxBodyBytes: 1 << 20,
+		},
+		Summary: SummaryConfig{
+			Strategy:  summarize.StrategyTextRank,
+			Sentences: 2,
+		},
+		Scheduler: SchedulerConfig{
+			Interval: time.Minute,
+		},
+		Retention: RetentionConfig{
+			Interval: time.Hour,
+		},
+	}
+}
+
 func testServer() *Server {
 	return newServer(
-		Config{
-			IndentJSON: true,
-			Port:       8080,
-		},
+		testConfig(),
 		mock.NewRepository(),
 	)
 }
@@ -1,17 +1,34 @@
 package service
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/if-ivan-else/tldrfeed/internal/db"
 )
 
+// statusClientClosedRequest is logged for requests the client gave up on before they were served, the client gets
+// no response
+const statusClientClosedRequest = 499
+
 func errorToStatus(e error) int {
 	switch e {
 	case db.ErrNotImplemented:
 		return http.StatusNotImplemented
 
+	case context.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case context.Canceled:
+		return statusClientClosedRequest
+
 	case db.ErrUserExists:
+		fallthrough
+	case db.ErrIdempotencyKeyExists:
+		fallthrough
+	case db.ErrArticlePublished:
 		return http.StatusConflict
 
 	case db.ErrNoSuchFeed:
@@ -19,8 +36,56 @@ func errorToStatus(e error) int {
 	case db.ErrNoSuchUser:
 		fallthrough
 	case db.ErrNotSubscribed:
+		fallthrough
+	case db.ErrNoSuchFilterRule:
+		fallthrough
+	case db.ErrNoSuchDraft:
+		fallthrough
+	case db.ErrNoSuchArticle:
 		return http.StatusNotFound
 	default:
 		return http.StatusInternalServerError
 	}
 }
+
+// intParam parses an optional integer query parameter, checking it against min and max (unless max is negative)
+func intParam(value string, def int, min int, max int) (int, error) {
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("'%s' is not a number", value)
+	}
+	if n < min || (max >= 0 && n > max) {
+		if max < 0 {
+			return 0, fmt.Errorf("%d must be at least %d", n, min)
+		}
+		return 0, fmt.Errorf("%d is out of range, expected a value between %d and %d", n, min, max)
+	}
+	return n, nil
+}
+
+// boolParam parses an optional boolean query parameter, false by default
+func boolParam(value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("'%s' is not a boolean", value)
+	}
+	return b, nil
+}
+
+// timeParam parses an optional RFC 3339 time query parameter, the zero time by default
+func timeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("'%s' is not an RFC 3339 time", value)
+	}
+	return t, nil
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+)
+
+// ReplayedHeader is set on responses replayed for retried requests
+const ReplayedHeader = "Idempotent-Replayed"
+
+// capturingWriter passes a response through while keeping a copy of it
+type capturingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *capturingWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *capturingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// retriable returns true for the statuses of responses that are not stored, so that their requests can be retried:
+// requests that failed on our end or were given up on by the client, whose outcome it never learnt, and requests
+// refused for now, e.g. over a quota, which may succeed later
+func retriable(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusLocked, http.StatusTooManyRequests,
+		statusClientClosedRequest:
+		return true
+	}
+	return status >= http.StatusInternalServerError
+}
+
+// idempotencyClient identifies the client an idempotency key belongs to, by principal, by API key or else by IP
+// address, so that clients are not replayed the responses to the requests of others
+func (s *Server) idempotencyClient(req *http.Request) string {
+	return s.limiter.currentConfig().clientIdentity(principalFromRequest(req), req.Header.Get(APIKeyHeader), clientIP(req))
+}
+
+// idempotency is a mux middleware making POST requests carrying an Idempotency-Key header safe to retry:
+// the first response is stored and replayed for retries with the same key and body
+func (s *Server) idempotency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		key := req.Header.Get(api.IdempotencyKeyHeader)
+		if req.Method != "POST" || key == "" {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		config := s.currentConfig().Idempotency
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, config.MaxBodyBytes))
+		if err != nil {
+			if _, ok := err.(*http.MaxBytesError); ok {
+				s.formatter.Text(w, http.StatusRequestEntityTooLarge,
+					fmt.Sprintf("Requests with an idempotency key can be at most %d bytes", config.MaxBodyBytes))
+				return
+			}
+			s.formatter.Text(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		digest := sha256.Sum256(body)
+		requestHash := hex.EncodeToString(digest[:])
+
+		// Keys are scoped to the client and the resource they are used with. They are reserved for the lease only, so
+		// that keys of requests whose response is never stored are released well before responses would expire.
+		scopedKey := s.idempotencyClient(req) + "|" + req.URL.Path + "|" + key
+		record := db.IdempotencyRecord{
+			Key:         scopedKey,
+			RequestHash: requestHash,
+			ExpiresTime: time.Now().Add(config.Lease),
+		}
+
+		err = s.repo.CreateIdempotencyRecord(req.Context(), record)
+		if err == db.ErrIdempotencyKeyExists {
+			s.replayIdempotent(req.Context(), w, scopedKey, requestHash)
+			return
+		}
+		if err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
+
+		// The outcome is stored even when the client gave up on the request meanwhile, so that its retry does not
+		// find the key still being processed
+		ctx := context.Background()
+		served := false
+		defer func() {
+			if !served {
+				// The handler panicked, release the key rather than leave retries refused until the lease expires
+				if err := s.repo.DeleteIdempotencyRecord(ctx, scopedKey); err != nil {
+					log.Printf("Failed to release idempotency key '%s': %s", key, err)
+				}
+			}
+		}()
+
+		cw := &capturingWriter{ResponseWriter: w}
+		next.ServeHTTP(cw, req)
+		served = true
+
+		if retriable(cw.status) {
+			err = s.repo.DeleteIdempotencyRecord(ctx, scopedKey)
+		} else {
+			err = s.repo.CompleteIdempotencyRecord(ctx, scopedKey, cw.status, cw.Header().Get("Content-Type"),
+				cw.body.Bytes(), time.Now().Add(config.TTL))
+		}
+		if err != nil {
+			log.Printf("Failed to store response for idempotency key '%s': %s", key, err)
+		}
+	})
+}
+
+// replayIdempotent responds to a retried request with the response stored for its key
+func (s *Server) replayIdempotent(ctx context.Context, w http.ResponseWriter, key string, requestHash string) {
+	record, err := s.repo.GetIdempotencyRecord(ctx, key)
+	if err != nil {
+		// The record expired between reserving and reading it
+		if err == db.ErrNoSuchIdempotencyKey {
+			err = db.ErrIdempotencyKeyExists
+		}
+		s.formatter.Text(w, errorToStatus(err), err.Error())
+		return
+	}
+
+	if record.RequestHash != requestHash {
+		s.formatter.Text(w, http.StatusConflict, "Idempotency key was already used with a different request body")
+		return
+	}
+	if !record.Completed() {
+		s.formatter.Text(w, http.StatusConflict, "A request with the same idempotency key is still being processed")
+		return
+	}
+
+	w.Header().Set("Content-Type", record.ContentType)
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(record.Status)
+	w.Write(record.Body)
+}
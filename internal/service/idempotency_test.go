@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotentArticleCreate(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	server := testServer()
+	f, _ := server.repo.CreateFeed(ctx, "Lermontov Hero of Our Time", "", nil)
+
+	publish := func(key string, jsonData string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/v1/feeds/%s/articles", f.ID), strings.NewReader(jsonData))
+		if key != "" {
+			req.Header.Set(api.IdempotencyKeyHeader, key)
+		}
+		rr := httptest.NewRecorder()
+		router(server).ServeHTTP(rr, req)
+		return rr
+	}
+	articleID := func(rr *httptest.ResponseRecorder) string {
+		var respJSON map[string]string
+		require.NoError(json.NewDecoder(rr.Result().Body).Decode(&respJSON))
+		return respJSON["id"]
+	}
+
+	const bela = `{"title": "Bela", "body": "I was travelling post from Tiflis"}`
+	first := publish("bela-1", bela)
+	requireStatus(http.StatusCreated, require, first)
+	require.Empty(first.Header().Get(ReplayedHeader))
+
+	// Retry gets the very same response without creating another Article
+	retry := publish("bela-1", bela)
+	requireStatus(http.StatusCreated, require, retry)
+	require.Equal("true", retry.Header().Get(ReplayedHeader))
+	require.Equal(articleID(first), articleID(retry))
+
+	articles, _ := server.repo.ListFeedArticles(ctx, f.ID, db.ArticleFilter{})
+	require.Len(articles, 1)
+
+	// Reusing the key for a different request is a conflict
+	rr := publish("bela-1", `{"title": "Taman", "body": "Taman is the nastiest little hole"}`)
+	requireStatus(http.StatusConflict, require, rr)
+	t.Logf("Error message (expected): %s", rr.Body.String())
+
+	// Requests without a key are not deduplicated
+	requireStatus(http.StatusCreated, require, publish("", bela))
+	requireStatus(http.StatusCreated, require, publish("", bela))
+	articles, _ = server.repo.ListFeedArticles(ctx, f.ID, db.ArticleFilter{})
+	require.Len(articles, 3)
+}
+
+func TestIdempotentErrorsAreNotStored(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	server := testServer()
+	f, _ := server.repo.CreateFeed(ctx, "Lermontov Hero of Our Time", "", nil)
+	const jsonData = `{"title": "Princess Mary", "body": "Yesterday I arrived at Pyatigorsk"}`
+
+	// Client errors are replayed like any other response
+	req, _ := http.NewRequest("POST", "/api/v1/feeds/unknown/articles", strings.NewReader(jsonData))
+	req.Header.Set(api.IdempotencyKeyHeader, "mary")
+	rr := httptest.NewRecorder()
+	router(server).ServeHTTP(rr, req)
+	requireStatus(http.StatusNotFound, require, rr)
+
+	req, _ = http.NewRequest("POST", "/api/v1/feeds/unknown/articles", strings.NewReader(jsonData))
+	req.Header.Set(api.IdempotencyKeyHeader, "mary")
+	rr = httptest.NewRecorder()
+	router(server).ServeHTTP(rr, req)
+	requireStatus(http.StatusNotFound, require, rr)
+	require.Equal("true", rr.Header().Get(ReplayedHeader))
+
+	// Keys are scoped to the resource they were used with
+	req, _ = http.NewRequest("POST", fmt.Sprintf("/api/v1/feeds/%s/articles", f.ID), strings.NewReader(jsonData))
+	req.Header.Set(api.IdempotencyKeyHeader, "mary")
+	rr = httptest.NewRecorder()
+	router(server).ServeHTTP(rr, req)
+	requireStatus(http.StatusCreated, require, rr)
+
+	// Requests the client gave up on are not stored either, whether they took effect is unknown to it so their
+	// retries are served anew
+	served := 0
+	handler := server.idempotency(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		served++
+		if served == 1 {
+			w.WriteHeader(statusClientClosedRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	for _, status := range []int{statusClientClosedRequest, http.StatusCreated, http.StatusCreated} {
+		req, _ = http.NewRequest("POST", "/api/v1/feeds", strings.NewReader(jsonData))
+		req.Header.Set(api.IdempotencyKeyHeader, "mary-again")
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		requireStatus(status, require, rr)
+	}
+	require.Equal(2, served)
+}
+
+func TestClientRetriesWithIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	server := testServer()
+	f, _ := server.repo.CreateFeed(ctx, "Lermontov Hero of Our Time", "", nil)
+
+	// Fail the first attempt after the Article is created, as if the response got lost
+	var attempts int32
+	keys := []string{}
+	handler := router(server)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		keys = append(keys, req.Header.Get(api.IdempotencyKeyHeader))
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		handler.ServeHTTP(w, req)
+	}))
+	defer ts.Close()
+
+	c := api.NewClient(ts.URL, api.WithRetries(2))
+	a, err := c.CreateArticle(f.ID, "Fatalist", "I happened once to spend two weeks in a Cossack village")
+	require.NoError(err)
+	require.NotEmpty(a.ID)
+
+	require.Len(keys, 2)
+	require.NotEmpty(keys[0])
+	require.Equal(keys[0], keys[1])
+
+	articles, _ := server.repo.ListFeedArticles(ctx, f.ID, db.ArticleFilter{})
+	require.Len(articles, 1)
+	require.Equal(articles[0].ID, a.ID)
+
+	// Non transient errors are not retried
+	_, err = c.CreateArticle("unknown", "Fatalist", "Vulich")
+	require.Error(err)
+	require.Equal(http.StatusNotFound, err.(*api.Error).StatusCode)
+	require.Len(keys, 3)
+}
+
+func TestIdempotencyKeyLease(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	server := testServer()
+	const scopedKey = "ip:|/api/v1/feeds|marina"
+	var leased *db.IdempotencyRecord
+	handler := server.idempotency(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		leased, _ = server.repo.GetIdempotencyRecord(ctx, scopedKey)
+		if req.Header.Get("X-Panic") != "" {
+			panic("handler failed")
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	serve := func(panics bool) {
+		req, _ := http.NewRequest("POST", "/api/v1/feeds", strings.NewReader(`{"name": "Taman"}`))
+		req.Header.Set(api.IdempotencyKeyHeader, "marina")
+		if panics {
+			req.Header.Set("X-Panic", "true")
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// Keys of requests failing without a response are released for retries
+	require.Panics(func() { serve(true) })
+	require.NotNil(leased)
+	_, err := server.repo.GetIdempotencyRecord(ctx, scopedKey)
+	require.Equal(db.ErrNoSuchIdempotencyKey, err)
+
+	// Keys are reserved for the lease while requests are processed, responses are kept for the TTL
+	serve(false)
+	require.False(leased.Completed())
+	require.WithinDuration(time.Now().Add(time.Minute), leased.ExpiresTime, 5*time.Second)
+	stored, err := server.repo.GetIdempotencyRecord(ctx, scopedKey)
+	require.NoError(err)
+	require.True(stored.Completed())
+	require.WithinDuration(time.Now().Add(time.Hour), stored.ExpiresTime, 5*time.Second)
+}
+
+func TestIdempotentRequestsOverQuotaAreRetried(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	server := testServer()
+	f, _ := server.repo.CreateFeed(ctx, "Lermontov Hero of Our Time", "", nil)
+	config := testConfig()
+	config.Quotas.FeedArticlesPerHour = 1
+	server.Reload(config)
+	_, err := server.repo.CreateFeedArticle(ctx, f.ID, api.Article{Title: "Bela", Body: "I was travelling post from Tiflis"})
+	require.NoError(err)
+
+	publish := func() *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/v1/feeds/%s/articles", f.ID),
+			strings.NewReader(`{"title": "Maksim Maksimych", "body": "Having parted with Maksim Maksimych"}`))
+		req.Header.Set(api.IdempotencyKeyHeader, "maksimych")
+		rr := httptest.NewRecorder()
+		router(server).ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := publish()
+	requireStatus(http.StatusTooManyRequests, require, rr)
+	require.NotEmpty(rr.Header().Get("Retry-After"))
+
+	// Once the quota is raised the retry is served anew rather than replayed the refusal
+	config.Quotas.FeedArticlesPerHour = 2
+	server.Reload(config)
+	rr = publish()
+	requireStatus(http.StatusCreated, require, rr)
+	require.Empty(rr.Header().Get(ReplayedHeader))
+
+	rr = publish()
+	requireStatus(http.StatusCreated, require, rr)
+	require.Equal("true", rr.Header().Get(ReplayedHeader))
+}
+
+func TestIdempotencyKeysOfClients(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	server := testServer()
+	f, _ := server.repo.CreateFeed(ctx, "Lermontov Hero of Our Time", "", nil)
+	config := testConfig()
+	config.Idempotency.MaxBodyBytes = 100
+	config.RateLimit.APIKeys = []APIKey{{Key: "pechorin-key", Client: "pechorin"}}
+	server.Reload(config)
+
+	publish := func(remoteAddr string, apiKey string, jsonData string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/v1/feeds/%s/articles", f.ID), strings.NewReader(jsonData))
+		req.RemoteAddr = remoteAddr
+		req.Header.Set(api.IdempotencyKeyHeader, "journal")
+		req.Header.Set(APIKeyHeader, apiKey)
+		rr := httptest.NewRecorder()
+		router(server).ServeHTTP(rr, req)
+		return rr
+	}
+
+	const journal = `{"title": "Journal", "body": "Pechorin's Journal"}`
+	requireStatus(http.StatusCreated, require, publish("10.0.0.1:1234", "pechorin-key", journal))
+	// The client is known by its API key wherever it calls from
+	rr := publish("10.0.0.2:1234", "pechorin-key", journal)
+	requireStatus(http.StatusCreated, require, rr)
+	require.Equal("true", rr.Header().Get(ReplayedHeader))
+
+	// Other clients using the same key are not replayed the response
+	rr = publish("10.0.0.3:1234", "", journal)
+	requireStatus(http.StatusCreated, require, rr)
+	require.Empty(rr.Header().Get(ReplayedHeader))
+	rr = publish("10.0.0.3:1234", "made-up-key", journal)
+	requireStatus(http.StatusCreated, require, rr)
+	require.Equal("true", rr.Header().Get(ReplayedHeader))
+
+	articles, _ := server.repo.ListFeedArticles(ctx, f.ID, db.ArticleFilter{})
+	require.Len(articles, 2)
+
+	// Bodies are read whole to be hashed, so they are bounded
+	rr = publish("10.0.0.1:1234", "pechorin-key", `{"title": "Journal", "body": "`+strings.Repeat("a", 100)+`"}`)
+	requireStatus(http.StatusRequestEntityTooLarge, require, rr)
+}
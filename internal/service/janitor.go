@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/if-ivan-else/tldrfeed/internal/db"
+	"github.com/if-ivan-else/tldrfeed/internal/db/audit"
+	"github.com/if-ivan-else/tldrfeed/internal/markup"
+)
+
+// janitorPrincipal is who the janitor's changes are recorded in the audit log as made by
+const janitorPrincipal = "janitor"
+
+// summaryBackfillBatch is the number of Articles read at once when backfilling summaries
+const summaryBackfillBatch = 100
+
+// runJanitor removes Articles expired by their Feed's retention policy, forever
+func (s *Server) runJanitor() {
+	for {
+		// The interval is read anew every time so that it can be reloaded
+		time.Sleep(s.currentConfig().Retention.Interval)
+		s.expireArticles(audit.NewContext(context.Background(), audit.Actor{Principal: janitorPrincipal}), time.Now())
+	}
+}
+
+// expireArticles removes the Articles of all Feeds expired by now, a Feed failing does not hold up the others
+func (s *Server) expireArticles(ctx context.Context, now time.Time) {
+	feeds, err := s.repo.ListFeeds(ctx, db.FeedFilter{})
+	if err != nil {
+		log.Printf("Failed to list Feeds to expire Articles of: %s", err)
+		return
+	}
+
+	for _, f := range feeds {
+		if f.Retention == nil {
+			continue
+		}
+		removed, err := s.repo.ExpireFeedArticles(ctx, f.ID, now)
+		if err != nil {
+			log.Printf("Failed to expire Articles of Feed %s: %s", f.ID, err)
+			continue
+		}
+		if removed > 0 {
+			log.Printf("Removed %d expired Articles of Feed %s", removed, f.ID)
+		}
+	}
+}
+
+// backfillSummaries stores the summaries of Articles stored without one, i.e. before summaries were computed when
+// Articles are added, so that listings return summaries as stored. Articles with nothing to summarize keep none.
+func (s *Server) backfillSummaries(ctx context.Context) {
+	summarizer := s.currentSummarizer()
+	afterID := ""
+	summarized := 0
+	for {
+		articles, err := s.repo.ListUnsummarizedArticles(ctx, afterID, summaryBackfillBatch)
+		if err != nil {
+			log.Printf("Failed to list Articles to summarize: %s", err)
+			return
+		}
+		for _, a := range articles {
+			afterID = a.ID
+			summary := summarizer.Summarize(a.Title, markup.Text(a.Body, a.BodyFormat))
+			if summary == "" {
+				continue
+			}
+			if err := s.repo.SetArticleSummary(ctx, a.FeedID, a.ID, summary); err != nil {
+				log.Printf("Failed to store the summary of Article %s: %s", a.ID, err)
+				continue
+			}
+			summarized++
+		}
+		if len(articles) < summaryBackfillBatch {
+			break
+		}
+	}
+	if summarized > 0 {
+		log.Printf("Summarized %d Articles stored without a summary", summarized)
+	}
+}
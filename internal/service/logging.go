@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/codegangsta/negroni"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/if-ivan-else/tldrfeed/api"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// setupLogging directs the standard logger, which the server logs with, to a handler of the configured format
+func setupLogging(config LogConfig) {
+	if config.Format != LogFormatJSON {
+		return
+	}
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, nil)))
+}
+
+// requestInfo describes a request for its log entry, it is filled in as the request is routed and authenticated
+type requestInfo struct {
+	ID        string
+	Route     string
+	Principal string
+	UserID    string
+}
+
+type requestInfoKey struct{}
+
+// requestInfoFromContext returns the description of the request being served, nil outside of requestLog
+func requestInfoFromContext(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(*requestInfo)
+	return info
+}
+
+// newRequestID returns the ID of a request given by its X-Request-ID header, assigning one when missing, and
+// returns it in the response
+func newRequestID(w http.ResponseWriter, req *http.Request) string {
+	requestID := req.Header.Get(api.RequestIDHeader)
+	if requestID == "" {
+		requestID = uuid.New().String()
+	}
+	w.Header().Set(api.RequestIDHeader, requestID)
+	return requestID
+}
+
+// requestLog is a negroni middleware identifying requests, tracing them in a span continuing the caller's trace and
+// logging them once served with their ID, route, principal, User, status and latency
+func (s *Server) requestLog(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	start := time.Now()
+	info := &requestInfo{ID: newRequestID(w, req)}
+
+	ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+	ctx, span := tracer().Start(ctx, "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.method", req.Method),
+			attribute.String("http.target", req.URL.Path),
+			attribute.String("http.request_id", info.ID),
+		),
+	)
+	defer span.End()
+
+	rw, ok := w.(negroni.ResponseWriter)
+	if !ok {
+		rw = negroni.NewResponseWriter(w)
+	}
+	next(rw, req.WithContext(context.WithValue(ctx, requestInfoKey{}, info)))
+
+	status := rw.Status()
+	span.SetAttributes(attribute.Int("http.status_code", status))
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+
+	attrs := []slog.Attr{
+		slog.String("request_id", info.ID),
+		slog.String("method", req.Method),
+		slog.String("path", req.URL.Path),
+		slog.String("route", info.Route),
+		slog.Int("status", status),
+		slog.Float64("latency_ms", float64(time.Since(start))/float64(time.Millisecond)),
+	}
+	if info.Principal != "" {
+		attrs = append(attrs, slog.String("principal", info.Principal))
+	}
+	if info.UserID != "" {
+		attrs = append(attrs, slog.String("user_id", info.UserID))
+	}
+	if sc := span.SpanContext(); sc.HasTraceID() {
+		attrs = append(attrs, slog.String("trace_id", sc.TraceID().String()))
+	}
+	level := slog.LevelInfo
+	if status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	slog.LogAttrs(ctx, level, "Served request", attrs...)
+}
+
+// routeInfo is a mux middleware completing the description of a request once routed and authenticated, naming its
+// span after the route
+func (s *Server) routeInfo(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if info := requestInfoFromContext(req.Context()); info != nil {
+			if route := mux.CurrentRoute(req); route != nil {
+				info.Route = route.GetName()
+			}
+			info.Principal = principalFromRequest(req)
+			info.UserID = mux.Vars(req)["userID"]
+
+			span := trace.SpanFromContext(req.Context())
+			span.SetName(info.Route)
+			span.SetAttributes(attribute.String("http.route", info.Route))
+			if info.UserID != "" {
+				span.SetAttributes(attribute.String("tldrfeed.user_id", info.UserID))
+			}
+		}
+		next.ServeHTTP(w, req)
+	})
+}
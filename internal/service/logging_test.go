@@ -0,0 +1,81 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRequestLog(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	logs := &bytes.Buffer{}
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(logs, nil)))
+	defer func() {
+		slog.SetDefault(defaultLogger)
+		log.SetOutput(os.Stderr)
+		log.SetFlags(log.LstdFlags)
+	}()
+
+	recorder := tracetest.NewSpanRecorder()
+	defaultProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(defaultProvider)
+	_, err := setupTracing(TracingConfig{})
+	require.NoError(err)
+
+	server := testServer()
+	u, err := server.repo.CreateUser(ctx, "reader")
+	require.NoError(err)
+
+	// Requests continue the caller's trace and are logged with their route and User
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest("GET", "/api/v1/users/"+u.ID+"/feeds", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	req.Header.Set(api.RequestIDHeader, "req-1")
+	rr := httptest.NewRecorder()
+	server.handler().ServeHTTP(rr, req)
+	requireStatus(http.StatusOK, require, rr)
+	require.Equal("req-1", rr.Header().Get(api.RequestIDHeader))
+
+	var entry map[string]interface{}
+	require.NoError(json.Unmarshal(logs.Bytes(), &entry))
+	require.Equal("Served request", entry["msg"])
+	require.Equal("req-1", entry["request_id"])
+	require.Equal("listUserFeeds", entry["route"])
+	require.Equal(u.ID, entry["user_id"])
+	require.Equal(float64(http.StatusOK), entry["status"])
+	require.Equal(traceID, entry["trace_id"])
+	require.Contains(entry, "latency_ms")
+
+	spans := recorder.Ended()
+	require.Len(spans, 1)
+	require.Equal("listUserFeeds", spans[0].Name())
+	require.Equal(traceID, spans[0].SpanContext().TraceID().String())
+
+	// Unrouted requests are logged too, with an ID of their own
+	logs.Reset()
+	rr = httptest.NewRecorder()
+	server.handler().ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/nowhere", nil))
+	requireStatus(http.StatusNotFound, require, rr)
+	requestID := rr.Header().Get(api.RequestIDHeader)
+	require.NotEmpty(requestID)
+	entry = nil
+	require.NoError(json.Unmarshal(logs.Bytes(), &entry))
+	require.Equal(requestID, entry["request_id"])
+	require.Equal("", entry["route"])
+}
@@ -0,0 +1,408 @@
+package service
+
+import (
+	_ "embed" // docs.html
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/openapi"
+	"github.com/pkg/errors"
+)
+
+// docsPage is the API documentation UI, rendering the OpenAPI document served by the openapi route
+//
+//go:embed docs.html
+var docsPage []byte
+
+// routeDoc documents a route for the OpenAPI document, the path and its parameters being taken from the route
+type routeDoc struct {
+	summary string
+	tag     string
+	query   []openapi.Parameter
+	// request is a value of the type of the request body, nil for requests without a body
+	request interface{}
+	// status is the status of successful responses, statuses lists them for routes with several
+	status   int
+	statuses []int
+	// response is a value of the type of successful responses, nil for plain text responses
+	response interface{}
+	// contentType overrides the content type of successful responses
+	contentType string
+}
+
+// Query parameters shared by several routes
+var (
+	viewParam = stringQuery("view", "Article list view, the TL;DR view replaces Article bodies with their summaries",
+		api.ViewFull, api.ViewTLDR)
+	bodyFormatParam = stringQuery("body_format", "Format of Article bodies, the format they were added in by default",
+		api.BodyOriginal, api.BodyText, api.BodyHTML)
+	tagParam = stringQuery("tag", "Only list Articles carrying the tag")
+
+	articleListParams = []openapi.Parameter{viewParam, bodyFormatParam, tagParam}
+	searchParams      = []openapi.Parameter{
+		stringQuery("q", "Search query of terms and \"quoted phrases\", optionally restricted to a field (title:tolstoy)"),
+		intQuery("offset", "Number of best matches to skip", 0, 0, -1),
+		intQuery("limit", "Number of matches to return", defaultSearchLimit, 1, maxSearchLimit),
+		bodyFormatParam,
+	}
+)
+
+// pathParamDescriptions describes the path variables of the routes
+var pathParamDescriptions = map[string]string{
+	"userID":    "ID of the User",
+	"feedID":    "ID of the Feed",
+	"articleID": "ID of the Article",
+	"ruleID":    "ID of the filter rule",
+}
+
+// routeDocs documents every named route, keyed by route name
+var routeDocs = map[string]routeDoc{
+	"createUser": {summary: "Create a User", tag: "users",
+		request: api.CreateUserRequest{}, status: http.StatusCreated, response: api.User{}},
+	"listUsers": {summary: "List Users", tag: "users",
+		status: http.StatusOK, response: []api.User{}},
+	"getUser": {summary: "Get a User", tag: "users",
+		status: http.StatusOK, response: api.User{}},
+
+	"listUserFeeds": {summary: "List the Feeds a User is following", tag: "subscriptions",
+		status: http.StatusOK, response: []api.Feed{}},
+	"addUserFeed": {summary: "Subscribe a User to a Feed", tag: "subscriptions",
+		request: api.AddUserFeedRequest{}, status: http.StatusAccepted},
+	"getUserFeed": {summary: "Get a Feed a User is following", tag: "subscriptions",
+		status: http.StatusOK, response: api.Feed{}},
+	"removeUserFeed": {summary: "Unsubscribe a User from a Feed", tag: "subscriptions",
+		status: http.StatusOK},
+	"listUserFeedArticles": {summary: "List the Articles of a Feed a User is following, with the User's filter rules applied",
+		tag: "subscriptions", query: articleListParams, status: http.StatusOK, response: []api.Article{}},
+	"listUserArticles": {summary: "List the timeline of a User: the Articles of all the Feeds they follow",
+		tag: "subscriptions", status: http.StatusOK, response: []api.Article{},
+		query: []openapi.Parameter{viewParam, bodyFormatParam, tagParam,
+			boolQuery("collapse_duplicates", "List near-duplicate Articles once, with the others as duplicates")},
+	},
+
+	"listStarredArticles": {summary: "List the Articles starred by a User", tag: "stars",
+		query: []openapi.Parameter{viewParam, bodyFormatParam}, status: http.StatusOK, response: []api.Article{}},
+	"starArticle": {summary: "Star an Article, keeping it regardless of retention policies", tag: "stars",
+		status: http.StatusOK},
+	"unstarArticle": {summary: "Unstar an Article", tag: "stars",
+		status: http.StatusOK},
+
+	"listFilterRules": {summary: "List the filter rules of a User", tag: "filters",
+		status: http.StatusOK, response: []api.FilterRule{}},
+	"createFilterRule": {summary: "Add a filter rule to a User", tag: "filters",
+		request: api.FilterRuleRequest{}, status: http.StatusCreated, response: api.FilterRule{}},
+	"previewFilterRule": {summary: "Show which recent Articles a filter rule would hide without saving it", tag: "filters",
+		query:   []openapi.Parameter{intQuery("limit", "Number of recent Articles to try the rule on", defaultPreviewLimit, 1, maxPreviewLimit)},
+		request: api.FilterRuleRequest{}, status: http.StatusOK, response: api.FilterPreview{}},
+	"getFilterRule": {summary: "Get a filter rule", tag: "filters",
+		status: http.StatusOK, response: api.FilterRule{}},
+	"updateFilterRule": {summary: "Replace a filter rule", tag: "filters",
+		request: api.FilterRuleRequest{}, status: http.StatusOK, response: api.FilterRule{}},
+	"deleteFilterRule": {summary: "Remove a filter rule", tag: "filters",
+		status: http.StatusOK},
+
+	"listFeeds": {summary: "List Feeds", tag: "feeds",
+		query:  []openapi.Parameter{stringQuery("category", "Only list Feeds of the category")},
+		status: http.StatusOK, response: []api.Feed{}},
+	"getFeed": {summary: "Get a Feed", tag: "feeds",
+		status: http.StatusOK, response: api.Feed{}},
+	"createFeed": {summary: "Create a Feed", tag: "feeds",
+		request: api.CreateFeedRequest{}, status: http.StatusCreated, response: api.Feed{}},
+
+	"setFeedRetention": {summary: "Replace the retention policy of a Feed, an empty policy keeps Articles forever",
+		tag: "retention", request: api.RetentionPolicy{}, status: http.StatusOK, response: api.Feed{}},
+	"retentionReport": {summary: "Show which Articles a retention policy would remove now, without removing them",
+		tag: "retention", status: http.StatusOK, response: api.RetentionReport{},
+		query: []openapi.Parameter{
+			policyQuery("max_age_days", "Maximum age of Articles in days, the Feed's policy by default"),
+			policyQuery("max_count", "Maximum number of Articles, the Feed's policy by default"),
+		},
+	},
+
+	"listFeedArticles": {summary: "List the published Articles of a Feed", tag: "articles",
+		query: articleListParams, status: http.StatusOK, response: []api.Article{}},
+	"createFeedArticle": {summary: "Add an Article to a Feed, published, as a draft or scheduled", tag: "articles",
+		request: api.CreateArticleRequest{}, status: http.StatusCreated, response: api.CreateArticleResponse{}},
+	"createFeedArticles": {summary: "Add a batch of Articles to a Feed, given as a JSON array or NDJSON. " +
+		"Responds with 207 Multi-Status when some of the Articles could not be added.", tag: "articles",
+		request: []api.CreateArticleRequest{}, statuses: []int{http.StatusCreated, http.StatusMultiStatus},
+		response: api.CreateArticlesResponse{}},
+
+	"listFeedDrafts": {summary: "List the draft and scheduled Articles of a Feed", tag: "drafts",
+		status: http.StatusOK, response: []api.Article{}},
+	"getFeedDraft": {summary: "Get a draft or scheduled Article", tag: "drafts",
+		status: http.StatusOK, response: api.Article{}},
+	"updateFeedDraft": {summary: "Edit a draft, publishing or scheduling it depending on its status", tag: "drafts",
+		request: api.CreateArticleRequest{}, status: http.StatusOK, response: api.Article{}},
+	"deleteFeedDraft": {summary: "Discard a draft or scheduled Article", tag: "drafts",
+		status: http.StatusOK},
+
+	"cacheStats": {summary: "Count hits and misses of cached repository queries", tag: "admin",
+		status: http.StatusOK, response: api.CacheStats{}},
+	"listAuditRecords": {summary: "List the latest changes recorded in the audit log, oldest first", tag: "admin",
+		status: http.StatusOK, response: []api.AuditRecord{},
+		query: []openapi.Parameter{
+			stringQuery("entity", "Only list changes to entities of the type",
+				api.AuditUser, api.AuditFeed, api.AuditArticle, api.AuditFilterRule),
+			stringQuery("entity_id", "Only list changes to the entity of the ID"),
+			stringQuery("actor", "Only list changes made by the principal"),
+			stringQuery("action", "Only list changes of the action"),
+			timeQuery("since", "Only list changes made at or after the time"),
+			timeQuery("until", "Only list changes made before the time"),
+			intQuery("limit", "Number of latest changes to list", defaultAuditLimit, 1, maxAuditLimit),
+		},
+	},
+
+	"search": {summary: "Search Articles in all Feeds, best matches first", tag: "search",
+		query: searchParams, status: http.StatusOK, response: api.SearchResults{}},
+	"userSearch": {summary: "Search Articles in the Feeds a User is following, best matches first", tag: "search",
+		query: searchParams, status: http.StatusOK, response: api.SearchResults{}},
+
+	"listTags": {summary: "List Article tags with their Article counts", tag: "discovery",
+		status: http.StatusOK, response: []api.TagCount{}},
+	"discoverFeeds": {summary: "Suggest Feeds a User is not following, by popularity and recent activity", tag: "discovery",
+		status: http.StatusOK, response: []api.FeedSuggestion{},
+		query: []openapi.Parameter{
+			stringQuery("category", "Only suggest Feeds of the category"),
+			intQuery("limit", "Number of Feeds to suggest", defaultDiscoverLimit, 1, maxDiscoverLimit),
+		},
+	},
+
+	"graphql": {summary: "Run a GraphQL operation, responding with 200 and the errors of the operation when it can be run",
+		tag: "graphql", request: api.GraphQLRequest{}, status: http.StatusOK, response: api.GraphQLResponse{}},
+	"graphqlQuery": {summary: "Run a GraphQL query given as query parameters, or upgrade to a websocket connection " +
+		"serving queries and subscriptions with the graphql-transport-ws protocol", tag: "graphql",
+		status: http.StatusOK, response: api.GraphQLResponse{},
+		query: []openapi.Parameter{
+			stringQuery("query", "GraphQL query document"),
+			stringQuery("operationName", "Operation to run in documents defining several"),
+			stringQuery("variables", "Variables of the operation, as a JSON object"),
+		},
+	},
+
+	"openapi": {summary: "Get this OpenAPI document", tag: "docs",
+		status: http.StatusOK, response: map[string]interface{}{}},
+	"docs": {summary: "Browse the API documentation", tag: "docs",
+		status: http.StatusOK, contentType: "text/html"},
+}
+
+// pathVariable matches the variables of mux path templates
+var pathVariable = regexp.MustCompile(`{([^}:]+)(:[^}]*)?}`)
+
+// openAPIDocument describes the named routes of a router with their routeDocs, failing when a route is not
+// documented or a documented route does not exist
+func openAPIDocument(router *mux.Router) (*openapi.Document, error) {
+	doc := &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info: openapi.Info{
+			Title:       "tldrfeed",
+			Description: "Feeds of Articles and the Users subscribing to them",
+			Version:     strings.TrimPrefix(api.APIVersion, "/api/"),
+		},
+		Servers: []openapi.Server{{URL: api.APIVersion}},
+		Paths:   make(map[string]openapi.PathItem),
+	}
+	g := openapi.NewGenerator()
+	documented := make(map[string]bool)
+
+	err := router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		name := route.GetName()
+		if name == "" {
+			return nil
+		}
+		rd, ok := routeDocs[name]
+		if !ok {
+			return errors.Errorf("Route '%s' is not documented", name)
+		}
+		documented[name] = true
+
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return err
+		}
+
+		// OpenAPI path templates carry no patterns
+		path := pathVariable.ReplaceAllString(strings.TrimPrefix(template, api.APIVersion), "{$1}")
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = make(openapi.PathItem)
+			doc.Paths[path] = item
+		}
+		for _, method := range methods {
+			item[strings.ToLower(method)] = rd.operation(g, name, method, template)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for name := range routeDocs {
+		if !documented[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, errors.Errorf("Documented routes do not exist: %s", strings.Join(missing, ", "))
+	}
+
+	doc.Components = g.Components()
+	return doc, nil
+}
+
+// operation describes a route with the given name, method and path template
+func (rd routeDoc) operation(g *openapi.Generator, name string, method string, template string) *openapi.Operation {
+	op := &openapi.Operation{
+		OperationID: name,
+		Summary:     rd.summary,
+		Tags:        []string{rd.tag},
+		Responses: map[string]openapi.Response{
+			"default": {
+				Description: "Error",
+				Content:     map[string]openapi.MediaType{"text/plain": {Schema: &openapi.Schema{Type: "string"}}},
+			},
+		},
+	}
+
+	for _, match := range pathVariable.FindAllStringSubmatch(template, -1) {
+		op.Parameters = append(op.Parameters, openapi.Parameter{
+			Name:        match[1],
+			In:          openapi.InPath,
+			Description: pathParamDescriptions[match[1]],
+			Required:    true,
+			Schema:      &openapi.Schema{Type: "string"},
+		})
+	}
+	op.Parameters = append(op.Parameters, rd.query...)
+	if method == http.MethodPost {
+		op.Parameters = append(op.Parameters, openapi.Parameter{
+			Name:        api.IdempotencyKeyHeader,
+			In:          openapi.InHeader,
+			Description: "Key making the request safe to retry, retries with the same key replay the first response",
+			Schema:      &openapi.Schema{Type: "string"},
+		})
+	}
+
+	if rd.request != nil {
+		schema := g.Schema(rd.request)
+		op.RequestBody = &openapi.RequestBody{
+			Required: true,
+			Content:  map[string]openapi.MediaType{"application/json": {Schema: schema}},
+		}
+		if schema.Type == "array" {
+			// Batches may also be given one item per line
+			op.RequestBody.Content["application/x-ndjson"] = openapi.MediaType{Schema: schema.Items}
+		}
+	}
+
+	response := openapi.Response{Description: "Success"}
+	switch {
+	case rd.response != nil:
+		contentType := rd.contentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		response.Content = map[string]openapi.MediaType{contentType: {Schema: g.Schema(rd.response)}}
+	case rd.contentType != "":
+		response.Content = map[string]openapi.MediaType{rd.contentType: {Schema: &openapi.Schema{Type: "string"}}}
+	default:
+		response.Content = map[string]openapi.MediaType{"text/plain": {Schema: &openapi.Schema{Type: "string"}}}
+	}
+	statuses := rd.statuses
+	if len(statuses) == 0 {
+		statuses = []int{rd.status}
+	}
+	for _, status := range statuses {
+		op.Responses[strconv.Itoa(status)] = response
+	}
+	return op
+}
+
+func stringQuery(name string, description string, enum ...string) openapi.Parameter {
+	return openapi.Parameter{
+		Name:        name,
+		In:          openapi.InQuery,
+		Description: description,
+		Schema:      &openapi.Schema{Type: "string", Enum: enum},
+	}
+}
+
+// intQuery describes an integer query parameter the way intParam parses it, max being ignored when negative
+func intQuery(name string, description string, def int, min int, max int) openapi.Parameter {
+	schema := &openapi.Schema{Type: "integer", Default: def, Minimum: &min}
+	if max >= 0 {
+		schema.Maximum = &max
+	}
+	return openapi.Parameter{Name: name, In: openapi.InQuery, Description: description, Schema: schema}
+}
+
+// policyQuery describes a retention policy query parameter, defaulting to the policy of the Feed
+func policyQuery(name string, description string) openapi.Parameter {
+	min := 0
+	return openapi.Parameter{
+		Name:        name,
+		In:          openapi.InQuery,
+		Description: description,
+		Schema:      &openapi.Schema{Type: "integer", Minimum: &min},
+	}
+}
+
+func boolQuery(name string, description string) openapi.Parameter {
+	return openapi.Parameter{
+		Name:        name,
+		In:          openapi.InQuery,
+		Description: description,
+		Schema:      &openapi.Schema{Type: "boolean", Default: false},
+	}
+}
+
+func timeQuery(name string, description string) openapi.Parameter {
+	return openapi.Parameter{
+		Name:        name,
+		In:          openapi.InQuery,
+		Description: description,
+		Schema:      &openapi.Schema{Type: "string", Format: "date-time"},
+	}
+}
+
+// openAPIHandler responds with the OpenAPI document describing the routes of router
+func (s *Server) openAPIHandler(router *mux.Router) http.HandlerFunc {
+	var (
+		once sync.Once
+		doc  *openapi.Document
+		err  error
+	)
+	return func(w http.ResponseWriter, req *http.Request) {
+		// All routes are registered by the time the first request is served
+		once.Do(func() {
+			doc, err = openAPIDocument(router)
+		})
+		if err != nil {
+			s.formatter.Text(w, http.StatusInternalServerError, fmt.Sprintf("Failed to describe the API: %s", err))
+			return
+		}
+		s.formatter.JSON(w, http.StatusOK, doc)
+	}
+}
+
+// docsHandler serves the API documentation UI
+func (s *Server) docsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write(docsPage)
+	}
+}
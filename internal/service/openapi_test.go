@@ -0,0 +1,130 @@
+package service
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/openapi"
+	"github.com/stretchr/testify/require"
+)
+
+// specFile is the OpenAPI document checked in for API consumers
+const specFile = "../../api/openapi.json"
+
+var updateSpec = flag.Bool("update-openapi", false, "Rewrite "+specFile+" from the routes and api types")
+
+func testRoutes() *mux.Router {
+	r := mux.NewRouter().PathPrefix(api.APIVersion).Subrouter()
+	setupRoutes(r, testServer())
+	return r
+}
+
+// TestOpenAPISpec fails when the routes or the api types drift from the checked-in OpenAPI document, run
+// go test ./internal/service -run TestOpenAPISpec -update-openapi to update it
+func TestOpenAPISpec(t *testing.T) {
+	require := require.New(t)
+
+	doc, err := openAPIDocument(testRoutes())
+	require.NoError(err)
+	generated, err := json.MarshalIndent(doc, "", "  ")
+	require.NoError(err)
+
+	if *updateSpec {
+		require.NoError(ioutil.WriteFile(specFile, append(generated, '\n'), 0644))
+	}
+	checkedIn, err := ioutil.ReadFile(specFile)
+	require.NoError(err)
+	require.JSONEq(string(checkedIn), string(generated),
+		"%s is out of date, run the test with -update-openapi to update it", specFile)
+}
+
+func TestOpenAPIServed(t *testing.T) {
+	require := require.New(t)
+
+	req, _ := http.NewRequest("GET", "/api/v1/openapi.json", nil)
+	rr := httptest.NewRecorder()
+	router(testServer()).ServeHTTP(rr, req)
+	requireStatus(http.StatusOK, require, rr)
+
+	var doc openapi.Document
+	require.NoError(json.Unmarshal(rr.Body.Bytes(), &doc))
+	require.Equal(openapi.Version, doc.OpenAPI)
+	require.Equal([]openapi.Server{{URL: "/api/v1"}}, doc.Servers)
+
+	// Every route is described under its name
+	operations := make(map[string]bool)
+	for _, item := range doc.Paths {
+		for _, op := range item {
+			operations[op.OperationID] = true
+		}
+	}
+	err := testRoutes().Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		require.True(operations[route.GetName()], "Route '%s' is missing", route.GetName())
+		delete(operations, route.GetName())
+		return nil
+	})
+	require.NoError(err)
+	require.Empty(operations)
+
+	op := doc.Paths["/users/{userID}/feeds/{feedID}"]["delete"]
+	require.NotNil(op)
+	require.Equal("removeUserFeed", op.OperationID)
+	require.Len(op.Parameters, 2)
+	require.Equal(openapi.InPath, op.Parameters[0].In)
+	require.Equal("userID", op.Parameters[0].Name)
+	require.True(op.Parameters[0].Required)
+	require.Equal("feedID", op.Parameters[1].Name)
+
+	op = doc.Paths["/users"]["post"]
+	require.NotNil(op)
+	require.Equal("#/components/schemas/CreateUserRequest", op.RequestBody.Content["application/json"].Schema.Ref)
+	require.Equal("#/components/schemas/User", op.Responses["201"].Content["application/json"].Schema.Ref)
+	require.Equal(api.IdempotencyKeyHeader, op.Parameters[0].Name)
+
+	op = doc.Paths["/feeds/{feedID}/articles:batch"]["post"]
+	require.NotNil(op)
+	require.Contains(op.Responses, "201")
+	require.Contains(op.Responses, "207")
+	require.Contains(op.RequestBody.Content, "application/x-ndjson")
+
+	// Validation tags become constraints
+	user := doc.Components.Schemas["CreateUserRequest"]
+	require.NotNil(user)
+	require.Equal([]string{"name"}, user.Required)
+	require.Equal(1, user.Properties["name"].MinLength)
+	require.Equal("^[a-zA-Z0-9]+$", user.Properties["name"].Pattern)
+}
+
+func TestOpenAPIUndocumentedRoute(t *testing.T) {
+	require := require.New(t)
+	s := testServer()
+
+	r := testRoutes()
+	r.HandleFunc("/users/{userID}/secret", s.getUserHandler()).Methods("GET").Name("secret")
+	_, err := openAPIDocument(r)
+	require.EqualError(err, "Route 'secret' is not documented")
+
+	r = mux.NewRouter().PathPrefix(api.APIVersion).Subrouter()
+	r.HandleFunc("/users", s.getUserListHandler()).Methods("GET").Name("listUsers")
+	_, err = openAPIDocument(r)
+	require.Error(err)
+	require.Contains(err.Error(), "Documented routes do not exist: addUserFeed, cacheStats")
+}
+
+func TestDocs(t *testing.T) {
+	require := require.New(t)
+
+	req, _ := http.NewRequest("GET", "/api/v1/docs", nil)
+	rr := httptest.NewRecorder()
+	router(testServer()).ServeHTTP(rr, req)
+	requireStatus(http.StatusOK, require, rr)
+
+	require.Equal("text/html; charset=UTF-8", rr.Header().Get("Content-Type"))
+	require.Contains(rr.Body.String(), `fetch("openapi.json")`)
+}
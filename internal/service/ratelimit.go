@@ -0,0 +1,155 @@
+package service
+
+import (
+	"crypto/subtle"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/if-ivan-else/tldrfeed/internal/ratelimit"
+)
+
+// APIKeyHeader is the request header carrying the API key identifying clients when rate limiting by API key
+const APIKeyHeader = "X-API-Key"
+
+// rateLimiter limits request rates per client and route using token buckets
+type rateLimiter struct {
+	mu      sync.RWMutex
+	config  RateLimitConfig
+	limiter *ratelimit.Limiter
+}
+
+func newRateLimiter(config RateLimitConfig) *rateLimiter {
+	return &rateLimiter{
+		config:  config,
+		limiter: ratelimit.New(config.MaxClients),
+	}
+}
+
+// setConfig swaps the rate limiting configuration, starting all clients with full buckets
+func (l *rateLimiter) setConfig(config RateLimitConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.config = config
+	l.limiter.Reset(config.MaxClients)
+}
+
+func (l *rateLimiter) currentConfig() RateLimitConfig {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.config
+}
+
+// routeLimit returns the limit for a route, a zero rate means the route is not limited
+func (c RateLimitConfig) routeLimit(route string) ratelimit.Limit {
+	for _, r := range c.Routes {
+		if r.Route == route {
+			return ratelimit.Limit{Rate: r.Rate, Burst: r.Burst}
+		}
+	}
+	return ratelimit.Limit{Rate: c.Rate, Burst: c.Burst}
+}
+
+// apiClient returns the name of the client an API key is configured for, or "" for unknown keys
+func (c RateLimitConfig) apiClient(key string) string {
+	if key == "" {
+		return ""
+	}
+	client := ""
+	for _, k := range c.APIKeys {
+		// Every key is compared so that the time taken does not tell how close a key is to a known one
+		if subtle.ConstantTimeCompare([]byte(k.Key), []byte(key)) == 1 {
+			client = k.Client
+		}
+	}
+	return client
+}
+
+// clientIdentity identifies a client whatever the configured key: by principal, by a configured API key or else by
+// IP address
+func (c RateLimitConfig) clientIdentity(principal string, apiKey string, ip string) string {
+	if principal != "" {
+		return "principal:" + principal
+	}
+	if client := c.apiClient(apiKey); client != "" {
+		return "key:" + client
+	}
+	return "ip:" + ip
+}
+
+// clientKey identifies the client making a request according to the configured key. Only API keys configured are
+// trusted, so that clients cannot escape their limits by making keys up or use up the limits of others.
+func (c RateLimitConfig) clientKey(req *http.Request) string {
+	switch c.Key {
+	case RateLimitKeyAPIKey:
+		if client := c.apiClient(req.Header.Get(APIKeyHeader)); client != "" {
+			return "key:" + client
+		}
+		if p := principalFromRequest(req); p != "" {
+			return "principal:" + p
+		}
+	case RateLimitKeyUser:
+		if p := principalFromRequest(req); p != "" {
+			return "principal:" + p
+		}
+		if userID := mux.Vars(req)["userID"]; userID != "" {
+			return "user:" + userID
+		}
+	}
+	return "ip:" + clientIP(req)
+}
+
+func clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// allow takes a token from the bucket of a client on a route, clients of routes that are not limited are always
+// allowed
+func (l *rateLimiter) allow(route string, client string) ratelimit.Result {
+	limit := l.currentConfig().routeLimit(route)
+	if limit.Rate <= 0 {
+		return ratelimit.Result{Allowed: true}
+	}
+	return l.limiter.Allow(route+"|"+client, limit)
+}
+
+// middleware is a mux middleware applying rate limits to the matched route
+func (l *rateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		config := l.currentConfig()
+
+		route := ""
+		if r := mux.CurrentRoute(req); r != nil {
+			route = r.GetName()
+		}
+		limit := config.routeLimit(route)
+		if limit.Rate <= 0 {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		res := l.limiter.Allow(route+"|"+config.clientKey(req), limit)
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		w.Header().Set("RateLimit-Reset", seconds(res.Reset))
+		if !res.Allowed {
+			w.Header().Set("Retry-After", seconds(res.RetryAfter))
+			http.Error(w, "Rate limit exceeded, retry later", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// seconds formats a duration as a whole number of seconds, rounding up
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
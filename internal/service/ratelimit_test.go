@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitByAPIKey(t *testing.T) {
+	require := require.New(t)
+
+	server := testServer()
+	config := testConfig()
+	config.RateLimit = RateLimitConfig{
+		Key:   RateLimitKeyAPIKey,
+		Rate:  1,
+		Burst: 2,
+		Routes: []RouteRateLimit{
+			// Not limited
+			{Route: "listFeeds"},
+		},
+		APIKeys: []APIKey{{Key: "poller-key", Client: "poller"}, {Key: "reader-key", Client: "reader"}},
+	}
+	server.Reload(config)
+	handler := router(server)
+
+	listUsers := func(apiKey string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/api/v1/users", nil)
+		req.Header.Set(APIKeyHeader, apiKey)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := listUsers("poller-key")
+	requireStatus(http.StatusOK, require, rr)
+	require.Equal("2", rr.Header().Get("RateLimit-Limit"))
+	require.Equal("1", rr.Header().Get("RateLimit-Remaining"))
+
+	requireStatus(http.StatusOK, require, listUsers("poller-key"))
+
+	rr = listUsers("poller-key")
+	requireStatus(http.StatusTooManyRequests, require, rr)
+	require.Equal("0", rr.Header().Get("RateLimit-Remaining"))
+	require.Equal("1", rr.Header().Get("Retry-After"))
+	require.Equal("2", rr.Header().Get("RateLimit-Reset"))
+
+	// Other clients are not affected
+	requireStatus(http.StatusOK, require, listUsers("reader-key"))
+
+	// Neither are other routes
+	for i := 0; i < 5; i++ {
+		req, _ := http.NewRequest("GET", "/api/v1/feeds", nil)
+		req.Header.Set(APIKeyHeader, "poller-key")
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		requireStatus(http.StatusOK, require, rr)
+		require.Empty(rr.Header().Get("RateLimit-Limit"))
+	}
+
+	// Unknown keys are limited by IP, clients cannot escape their limit by making keys up
+	requireStatus(http.StatusOK, require, listUsers("made-up-1"))
+	requireStatus(http.StatusOK, require, listUsers("made-up-2"))
+	requireStatus(http.StatusTooManyRequests, require, listUsers("made-up-3"))
+	requireStatus(http.StatusTooManyRequests, require, listUsers(""))
+	requireStatus(http.StatusOK, require, listUsers("reader-key"))
+}
+
+func TestRateLimitByUser(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	server := testServer()
+	config := testConfig()
+	config.RateLimit = RateLimitConfig{
+		Key:   RateLimitKeyUser,
+		Rate:  1,
+		Burst: 1,
+	}
+	server.Reload(config)
+	handler := router(server)
+	boris, _ := server.repo.CreateUser(ctx, "boris")
+	olga, _ := server.repo.CreateUser(ctx, "olga")
+
+	getUser := func(userID string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/api/v1/users/"+userID+"/articles", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	requireStatus(http.StatusOK, require, getUser(boris.ID))
+	requireStatus(http.StatusTooManyRequests, require, getUser(boris.ID))
+	requireStatus(http.StatusOK, require, getUser(olga.ID))
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/if-ivan-else/tldrfeed/api"
+)
+
+// setFeedRetentionHandler replaces the retention policy of a Feed, an empty policy keeps Articles forever
+func (s *Server) setFeedRetentionHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		policy := api.RetentionPolicy{}
+		if err := decodeAndValidate(req, &policy); err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		retention, err := normalizeRetention(&policy)
+		if err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		vars := mux.Vars(req)
+		if err := s.repo.SetFeedRetention(req.Context(), vars["feedID"], retention); err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
+		feed, err := s.repo.GetFeed(req.Context(), vars["feedID"])
+		if err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
+
+		s.formatter.JSON(w, http.StatusOK, feed)
+	}
+}
+
+// retentionReportHandler lists the Articles of a Feed its retention policy would remove now, without removing them.
+// The max_age_days and max_count parameters try out a different policy.
+func (s *Server) retentionReportHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		vars := mux.Vars(req)
+		feed, err := s.repo.GetFeed(req.Context(), vars["feedID"])
+		if err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
+
+		policy := api.RetentionPolicy{}
+		if feed.Retention != nil {
+			policy = *feed.Retention
+		}
+		query := req.URL.Query()
+		if policy.MaxAgeDays, err = intParam(query.Get("max_age_days"), policy.MaxAgeDays, 0, -1); err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, fmt.Sprintf("Invalid max_age_days: %s", err))
+			return
+		}
+		if policy.MaxCount, err = intParam(query.Get("max_count"), policy.MaxCount, 0, -1); err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, fmt.Sprintf("Invalid max_count: %s", err))
+			return
+		}
+
+		report, err := s.repo.ReportExpiredArticles(req.Context(), feed.ID, policy, time.Now())
+		if err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
+
+		s.formatter.JSON(w, http.StatusOK, report)
+	}
+}
+
+// normalizeRetention checks a requested retention policy, returning nil for policies keeping Articles forever
+func normalizeRetention(policy *api.RetentionPolicy) (*api.RetentionPolicy, error) {
+	if policy == nil {
+		return nil, nil
+	}
+	if policy.MaxAgeDays < 0 {
+		return nil, fmt.Errorf("Retention max_age_days %d cannot be negative", policy.MaxAgeDays)
+	}
+	if policy.MaxCount < 0 {
+		return nil, fmt.Errorf("Retention max_count %d cannot be negative", policy.MaxCount)
+	}
+	if policy.Empty() {
+		return nil, nil
+	}
+	return policy, nil
+}
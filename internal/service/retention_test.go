@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetention(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	server := testServer()
+	ts := httptest.NewServer(router(server))
+	defer ts.Close()
+	c := api.NewClient(ts.URL)
+
+	u, err := c.CreateUser("Archivist")
+	require.NoError(err)
+	f, err := c.CreateFeedWithOptions(&api.CreateFeedRequest{Name: "Wire", Retention: &api.RetentionPolicy{MaxCount: 2}})
+	require.NoError(err)
+	require.Equal(&api.RetentionPolicy{MaxCount: 2}, f.Retention)
+
+	ids := map[string]string{}
+	now := time.Now()
+	for _, age := range []int{10, 5, 3, 1} {
+		published := now.AddDate(0, 0, -age)
+		title := time.Duration(age*24) * time.Hour
+		a, err := c.PublishArticle(f.ID, &api.CreateArticleRequest{Title: title.String(), Body: "News.", PublishedTime: &published})
+		require.NoError(err)
+		ids[title.String()] = a.ID
+	}
+	_, err = c.PublishArticle(f.ID, &api.CreateArticleRequest{Title: "Draft", Body: "Not yet.", Status: api.StatusDraft})
+	require.NoError(err)
+	require.NoError(c.StarArticle(u.ID, ids["240h0m0s"]))
+
+	expired := func(articles []api.ExpiredArticle) []string {
+		res := []string{}
+		for _, a := range articles {
+			res = append(res, a.Title+" "+a.Reason)
+		}
+		return res
+	}
+
+	// Reports list expired Articles oldest first, setting aside starred ones
+	report, err := c.RetentionReport(f.ID, nil)
+	require.NoError(err)
+	require.Equal(api.RetentionPolicy{MaxCount: 2}, report.Policy)
+	require.Equal([]string{"120h0m0s max_count"}, expired(report.Expired))
+	require.Equal([]string{"240h0m0s max_count"}, expired(report.Starred))
+
+	report, err = c.RetentionReport(f.ID, &api.RetentionPolicy{MaxAgeDays: 4})
+	require.NoError(err)
+	require.Equal([]string{"120h0m0s max_age"}, expired(report.Expired))
+	require.Equal([]string{"240h0m0s max_age"}, expired(report.Starred))
+
+	report, err = c.RetentionReport(f.ID, &api.RetentionPolicy{MaxAgeDays: 4, MaxCount: 1})
+	require.NoError(err)
+	require.Equal([]string{"120h0m0s max_age", "72h0m0s max_count"}, expired(report.Expired))
+
+	// Reports do not remove anything, the janitor does
+	articles, err := c.ListArticles(f.ID)
+	require.NoError(err)
+	require.Len(articles, 4)
+	server.expireArticles(ctx, time.Now())
+	articles, err = c.ListArticles(f.ID)
+	require.NoError(err)
+	require.Len(articles, 3)
+	starred, err := c.ListStarredArticles(u.ID)
+	require.NoError(err)
+	require.Len(starred, 1)
+	require.Equal(ids["240h0m0s"], starred[0].ID)
+
+	// Unstarred Articles expire
+	require.NoError(c.UnstarArticle(u.ID, ids["240h0m0s"]))
+	server.expireArticles(ctx, time.Now())
+	articles, err = c.ListArticles(f.ID)
+	require.NoError(err)
+	require.Len(articles, 2)
+	drafts, err := c.ListDrafts(f.ID)
+	require.NoError(err)
+	require.Len(drafts, 1)
+
+	// Empty policies keep Articles forever
+	f, err = c.SetFeedRetention(f.ID, &api.RetentionPolicy{})
+	require.NoError(err)
+	require.Nil(f.Retention)
+	report, err = c.RetentionReport(f.ID, nil)
+	require.NoError(err)
+	require.Empty(report.Expired)
+
+	f, err = c.SetFeedRetention(f.ID, &api.RetentionPolicy{MaxAgeDays: 2})
+	require.NoError(err)
+	require.Equal(&api.RetentionPolicy{MaxAgeDays: 2}, f.Retention)
+	server.expireArticles(ctx, time.Now())
+	articles, err = c.ListArticles(f.ID)
+	require.NoError(err)
+	require.Len(articles, 1)
+	require.Equal(ids["24h0m0s"], articles[0].ID)
+}
+
+func TestInvalidRetention(t *testing.T) {
+	require := require.New(t)
+
+	ts := httptest.NewServer(router(testServer()))
+	defer ts.Close()
+	c := api.NewClient(ts.URL)
+
+	u, err := c.CreateUser("Archivist")
+	require.NoError(err)
+	f, err := c.CreateFeed("Wire")
+	require.NoError(err)
+	draft, err := c.PublishArticle(f.ID, &api.CreateArticleRequest{Title: "Draft", Body: "Not yet.", Status: api.StatusDraft})
+	require.NoError(err)
+
+	for _, policy := range []api.RetentionPolicy{{MaxAgeDays: -1}, {MaxCount: -1}} {
+		_, err := c.SetFeedRetention(f.ID, &policy)
+		require.Error(err)
+		require.Equal(http.StatusBadRequest, err.(*api.Error).StatusCode)
+		t.Logf("Error message (expected): %s", err)
+
+		_, err = c.CreateFeedWithOptions(&api.CreateFeedRequest{Name: "Wire", Retention: &policy})
+		require.Error(err)
+		require.Equal(http.StatusBadRequest, err.(*api.Error).StatusCode)
+	}
+
+	_, err = c.SetFeedRetention("nonexistent", &api.RetentionPolicy{MaxCount: 1})
+	require.Error(err)
+	require.Equal(http.StatusNotFound, err.(*api.Error).StatusCode)
+	_, err = c.RetentionReport("nonexistent", nil)
+	require.Error(err)
+	require.Equal(http.StatusNotFound, err.(*api.Error).StatusCode)
+
+	// Only published Articles can be starred
+	for _, articleID := range []string{"nonexistent", draft.ID} {
+		err = c.StarArticle(u.ID, articleID)
+		require.Error(err)
+		require.Equal(http.StatusNotFound, err.(*api.Error).StatusCode)
+	}
+	a, err := c.CreateArticle(f.ID, "Live", "Published.")
+	require.NoError(err)
+	err = c.StarArticle("nonexistent", a.ID)
+	require.Error(err)
+	require.Equal(http.StatusNotFound, err.(*api.Error).StatusCode)
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/db/audit"
+)
+
+// PublishHook is notified of an Article as it gets published
+type PublishHook func(article api.Article)
+
+// OnPublish registers a hook notified of every Article published: added as published, published from a draft or
+// published by the scheduler. Hooks are called synchronously and must not block.
+func (s *Server) OnPublish(hook PublishHook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, hook)
+}
+
+// notifyPublished calls the publish hooks for every Article
+func (s *Server) notifyPublished(articles ...api.Article) {
+	s.mu.Lock()
+	hooks := s.hooks
+	s.mu.Unlock()
+
+	for _, a := range articles {
+		for _, hook := range hooks {
+			hook(a)
+		}
+	}
+}
+
+// schedulerPrincipal is who the scheduler's changes are recorded in the audit log as made by
+const schedulerPrincipal = "scheduler"
+
+// runScheduler publishes scheduled Articles as they fall due, forever
+func (s *Server) runScheduler() {
+	for {
+		// The interval is read anew every time so that it can be reloaded
+		time.Sleep(s.currentConfig().Scheduler.Interval)
+		s.publishDue(audit.NewContext(context.Background(), audit.Actor{Principal: schedulerPrincipal}), time.Now())
+	}
+}
+
+// publishDue publishes the scheduled Articles due by now and notifies the publish hooks of them
+func (s *Server) publishDue(ctx context.Context, now time.Time) {
+	published, err := s.repo.PublishDueArticles(ctx, now)
+	if len(published) > 0 {
+		log.Printf("Published %d scheduled Articles", len(published))
+		s.notifyPublished(published...)
+	}
+	if err != nil {
+		log.Printf("Failed to publish scheduled Articles: %s", err)
+	}
+}
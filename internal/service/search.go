@@ -0,0 +1,112 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/markup"
+	"github.com/if-ivan-else/tldrfeed/internal/search"
+)
+
+// Search paging defaults and limits
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	// snippetSize is the approximate length of highlighted snippets
+	snippetSize = 160
+)
+
+// searchHandler searches Articles of all Feeds
+func (s *Server) searchHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		s.search(w, req, nil)
+	}
+}
+
+// userSearchHandler searches Articles of the Feeds a User is following
+func (s *Server) userSearchHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		vars := mux.Vars(req)
+
+		feeds, err := s.repo.ListUserFeeds(req.Context(), vars["userID"])
+		if err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
+
+		feedIDs := []string{}
+		for _, f := range feeds {
+			feedIDs = append(feedIDs, f.ID)
+		}
+		s.search(w, req, feedIDs)
+	}
+}
+
+// search responds with a page of Articles matching the request's query in the given Feeds, or all Feeds when nil
+func (s *Server) search(w http.ResponseWriter, req *http.Request, feedIDs []string) {
+	params := req.URL.Query()
+	query, err := search.ParseQuery(params.Get("q"))
+	if err != nil {
+		s.formatter.Text(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	offset, err := intParam(params.Get("offset"), 0, 0, -1)
+	if err != nil {
+		s.formatter.Text(w, http.StatusBadRequest, fmt.Sprintf("Invalid offset: %s", err))
+		return
+	}
+	limit, err := intParam(params.Get("limit"), defaultSearchLimit, 1, maxSearchLimit)
+	if err != nil {
+		s.formatter.Text(w, http.StatusBadRequest, fmt.Sprintf("Invalid limit: %s", err))
+		return
+	}
+	format, err := bodyFormat(req)
+	if err != nil {
+		s.formatter.Text(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response := api.SearchResults{
+		Query:  query.Raw,
+		Offset: offset,
+		Limit:  limit,
+		Hits:   []api.SearchHit{},
+	}
+	// Following no Feeds, a User has nothing to search
+	if feedIDs != nil && len(feedIDs) == 0 {
+		s.formatter.JSON(w, http.StatusOK, response)
+		return
+	}
+
+	results, err := s.repo.SearchArticles(req.Context(), query, feedIDs, offset, limit)
+	if err != nil {
+		s.formatter.Text(w, errorToStatus(err), err.Error())
+		return
+	}
+
+	response.Total = results.Total
+	for _, h := range results.Hits {
+		hit := api.SearchHit{
+			Article:    h.Article,
+			FeedID:     h.FeedID,
+			Score:      h.Score,
+			Highlights: map[string]string{},
+		}
+		// Snippets are cut from plain text, markup would not survive being cut
+		texts := map[string]string{
+			search.FieldTitle: h.Article.Title,
+			search.FieldBody:  markup.Text(h.Article.Body, h.Article.BodyFormat),
+		}
+		for field, text := range texts {
+			if snippet := search.Highlight(query, field, text, snippetSize); snippet != "" {
+				hit.Highlights[field] = snippet
+			}
+		}
+		hit.Article.Body, hit.Article.BodyFormat = presentBody(h.Article.Body, h.Article.BodyFormat, format)
+		response.Hits = append(response.Hits, hit)
+	}
+	s.formatter.JSON(w, http.StatusOK, response)
+}
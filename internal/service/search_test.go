@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	server := testServer()
+	russian, err := server.repo.CreateFeed(ctx, "Russian Classics", "", nil)
+	require.NoError(err)
+	french, err := server.repo.CreateFeed(ctx, "French Classics", "", nil)
+	require.NoError(err)
+	_, err = server.repo.CreateFeedArticle(ctx, russian.ID, api.Article{Title: "War and Peace", Body: "Napoleon invades Russia"})
+	require.NoError(err)
+	_, err = server.repo.CreateFeedArticle(ctx, french.ID, api.Article{Title: "Les Misérables", Body: "<i>Barricades</i>, war and peace in Paris"})
+	require.NoError(err)
+
+	user, err := server.repo.CreateUser(ctx, "ivan")
+	require.NoError(err)
+
+	search := func(path string, query string, status int) *api.SearchResults {
+		req, _ := http.NewRequest("GET", "/api/v1"+path+"?"+query, nil)
+		rr := httptest.NewRecorder()
+		router(server).ServeHTTP(rr, req)
+		requireStatus(status, require, rr)
+		if status != http.StatusOK {
+			return nil
+		}
+		results := &api.SearchResults{}
+		require.NoError(json.NewDecoder(rr.Body).Decode(results))
+		return results
+	}
+
+	results := search("/search", "q="+url.QueryEscape(`"war and peace"`), http.StatusOK)
+	require.Equal(2, results.Total)
+	require.Equal(defaultSearchLimit, results.Limit)
+	require.Equal("War and Peace", results.Hits[0].Article.Title)
+	require.Equal(russian.ID, results.Hits[0].FeedID)
+	require.Equal("<mark>War</mark> <mark>and</mark> <mark>Peace</mark>", results.Hits[0].Highlights["title"])
+	require.NotContains(results.Hits[0].Highlights, "body")
+	require.Equal("&lt;i&gt;Barricades&lt;/i&gt;, <mark>war</mark> <mark>and</mark> <mark>peace</mark> in Paris", results.Hits[1].Highlights["body"])
+
+	results = search("/search", "q=title:war&offset=1&limit=1", http.StatusOK)
+	require.Equal(1, results.Total)
+	require.Empty(results.Hits)
+
+	search("/search", "q=", http.StatusBadRequest)
+	require.Zero(search("/search", "q=author:hugo", http.StatusOK).Total)
+	search("/search", "q=war&limit=1000", http.StatusBadRequest)
+	search("/search", "q=war&offset=-1", http.StatusBadRequest)
+
+	// User search is restricted to followed Feeds
+	results = search("/users/"+user.ID+"/search", "q=war", http.StatusOK)
+	require.Zero(results.Total)
+	require.NoError(server.repo.AddUserFeed(ctx, user.ID, french.ID))
+	results = search("/users/"+user.ID+"/search", "q=war", http.StatusOK)
+	require.Equal(1, results.Total)
+	require.Equal("Les Misérables", results.Hits[0].Article.Title)
+
+	search("/users/nobody/search", "q=war", http.StatusNotFound)
+}
+
+func TestClientSearch(t *testing.T) {
+	require := require.New(t)
+
+	ts := httptest.NewServer(router(testServer()))
+	defer ts.Close()
+
+	c := api.NewClient(ts.URL)
+	feed, err := c.CreateFeed("Poetry")
+	require.NoError(err)
+	_, err = c.CreateArticle(feed.ID, "Eugene Onegin", "A novel in verse")
+	require.NoError(err)
+
+	results, err := c.Search("", "verse", 0, 0)
+	require.NoError(err)
+	require.Equal(1, results.Total)
+	require.Equal("Eugene Onegin", results.Hits[0].Article.Title)
+
+	_, err = c.Search("", "", 0, 0)
+	require.Error(err)
+}
@@ -1,53 +1,276 @@
 package service
 
 import (
+	"context"
 	"log"
 	"net/http"
+	"reflect"
 	"strconv"
+	"sync"
 
 	"github.com/codegangsta/negroni"
 	"github.com/gorilla/mux"
+	graphql "github.com/graph-gophers/graphql-go"
 	"github.com/if-ivan-else/tldrfeed/api"
 	"github.com/if-ivan-else/tldrfeed/internal/db"
+	"github.com/if-ivan-else/tldrfeed/internal/db/audit"
+	"github.com/if-ivan-else/tldrfeed/internal/db/cache"
 	"github.com/if-ivan-else/tldrfeed/internal/db/mongo"
-	"github.com/unrolled/render"
+	"github.com/if-ivan-else/tldrfeed/internal/db/sql"
+	"github.com/if-ivan-else/tldrfeed/internal/db/tracing"
+	"github.com/if-ivan-else/tldrfeed/internal/querycost"
+	"github.com/if-ivan-else/tldrfeed/internal/summarize"
+	"go.opentelemetry.io/otel"
 )
 
 // Server captures runtime aspects of the tldrfeed server
 type Server struct {
-	formatter *render.Render
+	formatter *formatter
+	limiter   *rateLimiter
 	repo      db.Repository
-	port      int
+	// cache is the repository when queries are cached, nil otherwise
+	cache *cache.Repository
+	port  int
+
+	mu         sync.Mutex
+	config     Config
+	summarizer summarize.Summarizer
+	hooks      []PublishHook
+	// graphql runs the operations of the GraphQL API, once checked by graphqlCost
+	graphql     *graphql.Schema
+	graphqlCost *querycost.Estimator
+	// watchers are the gRPC streams and GraphQL subscriptions watching Articles as they are published
+	watchers *articleWatchers
+	// certs serves the TLS certificate, nil without TLS
+	certs *certReloader
 }
 
 // NewServer creates and configures a new tldrfeed server
 func NewServer(config Config) *Server {
-	r, err := mongo.NewRepository(config.DB)
+	setupLogging(config.Log)
+	// The server runs until the process exits, spans not yet exported by then are dropped
+	if _, err := setupTracing(config.Tracing); err != nil {
+		log.Fatal(err)
+	}
+
+	r, err := OpenRepository(config)
 	if err != nil {
 		log.Fatal(err)
 	}
-	return newServer(config, r)
+	if config.Tracing.Enabled() {
+		r = tracing.NewRepository(r, otel.GetTracerProvider(), dbSystem(config.DB))
+	}
+	if config.Audit.Enabled {
+		r = audit.NewRepository(r)
+	}
+	if !config.Cache.Enabled() {
+		return newServer(config, r)
+	}
+
+	var store cache.Store
+	switch config.Cache.Backend {
+	case CacheBackendRedis:
+		if store, err = cache.NewRedis(config.Cache.RedisURL); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		store = cache.NewLRU(config.Cache.Size)
+	}
+	return newServer(config, cache.NewRepository(r, store, config.Cache.TTL))
+}
+
+// OpenRepository connects to the configured DB, PostgreSQL and SQLite URLs select the SQL repository and any other
+// URL MongoDB
+func OpenRepository(config Config) (db.Repository, error) {
+	if sql.Supports(config.DB) {
+		return sql.NewRepository(config.DB)
+	}
+	opts := []mongo.Option{}
+	if config.Timelines.FanOut {
+		opts = append(opts, mongo.WithTimelines(config.Timelines.MaxSubscribers))
+	}
+	return mongo.NewRepository(config.DB, opts...)
 }
 
 func newServer(config Config, repo db.Repository) *Server {
-	return &Server{
-		formatter: render.New(
-			render.Options{
-				IndentJSON: config.IndentJSON,
-			},
-		),
-		port: config.Port,
-		repo: repo,
+	summarizer, err := summarize.New(config.Summary.Strategy, config.Summary.Sentences)
+	if err != nil {
+		log.Fatal(err)
+	}
+	s := &Server{
+		formatter:  newFormatter(config.IndentJSON),
+		limiter:    newRateLimiter(config.RateLimit),
+		port:       config.Port,
+		repo:       repo,
+		config:     config,
+		summarizer: summarizer,
+		watchers:   newArticleWatchers(),
+	}
+	if c, ok := repo.(*cache.Repository); ok {
+		s.cache = c
+	}
+	s.graphql, s.graphqlCost = s.newGraphQL()
+	s.OnPublish(s.watchers.publish)
+	return s
+}
+
+// Reload applies settings from config that are safe to change while the server is running.
+// Settings that require a restart (port, DB, TLS, timelines, cache, audit, log, tracing, gRPC) are left untouched and reported in the log.
+func (s *Server) Reload(config Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if config.Port != s.config.Port {
+		log.Printf("Ignoring port change to %d on reload: restart required", config.Port)
+		config.Port = s.config.Port
+	}
+	if config.DB != s.config.DB {
+		log.Printf("Ignoring DB change on reload: restart required")
+		config.DB = s.config.DB
+	}
+	// Certificate files are reloaded when they changed, other TLS settings need a restart
+	if !reflect.DeepEqual(config.TLS, s.config.TLS) {
+		log.Printf("Ignoring TLS settings change on reload: restart required")
+		config.TLS = s.config.TLS
+	}
+	if s.certs != nil {
+		s.certs.reload()
+	}
+	if config.Cache != s.config.Cache {
+		log.Printf("Ignoring cache settings change on reload: restart required")
+		config.Cache = s.config.Cache
+	}
+	if config.Timelines != s.config.Timelines {
+		log.Printf("Ignoring timeline settings change on reload: restart required")
+		config.Timelines = s.config.Timelines
+	}
+	if config.Audit.Enabled != s.config.Audit.Enabled {
+		log.Printf("Ignoring audit settings change on reload: restart required")
+		config.Audit.Enabled = s.config.Audit.Enabled
+	}
+	if config.Log != s.config.Log {
+		log.Printf("Ignoring log settings change on reload: restart required")
+		config.Log = s.config.Log
+	}
+	if config.Tracing != s.config.Tracing {
+		log.Printf("Ignoring tracing settings change on reload: restart required")
+		config.Tracing = s.config.Tracing
+	}
+	if config.GRPC != s.config.GRPC {
+		log.Printf("Ignoring gRPC settings change on reload: restart required")
+		config.GRPC = s.config.GRPC
+	}
+
+	if config.IndentJSON != s.config.IndentJSON {
+		s.formatter.setIndentJSON(config.IndentJSON)
+		log.Printf("Reloaded JSON indentation: %t", config.IndentJSON)
+	}
+	if !reflect.DeepEqual(config.RateLimit, s.config.RateLimit) {
+		s.limiter.setConfig(config.RateLimit)
+		log.Printf("Reloaded rate limits")
+	}
+	if config.DBTimeout != s.config.DBTimeout {
+		log.Printf("Reloaded DB timeout: %s", config.DBTimeout)
+	}
+	if config.Scheduler != s.config.Scheduler {
+		log.Printf("Reloaded scheduler settings")
+	}
+	if config.Retention != s.config.Retention {
+		log.Printf("Reloaded retention settings")
+	}
+	if config.GraphQL != s.config.GraphQL {
+		log.Printf("Reloaded GraphQL limits")
+	}
+	if !reflect.DeepEqual(config.Audit.Admins, s.config.Audit.Admins) {
+		log.Printf("Reloaded audit log admins")
+	}
+	if config.Summary != s.config.Summary {
+		summarizer, err := summarize.New(config.Summary.Strategy, config.Summary.Sentences)
+		if err != nil {
+			log.Printf("Ignoring summary settings change on reload: %s", err)
+			config.Summary = s.config.Summary
+		} else {
+			s.summarizer = summarizer
+			log.Printf("Reloaded summary settings")
+		}
 	}
+
+	s.config = config
+}
+
+// currentConfig returns the configuration the server is currently running with
+func (s *Server) currentConfig() Config {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.config
+}
+
+// currentSummarizer returns the Summarizer the server is currently configured with
+func (s *Server) currentSummarizer() summarize.Summarizer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.summarizer
 }
 
 // Run runs the tldrfeed Server
 func (s *Server) Run() {
-	n := negroni.Classic()
-	// Run the server
-	n.UseHandler(router(s))
+	go s.runScheduler()
+	go s.runJanitor()
+	go s.backfillSummaries(audit.NewContext(context.Background(), audit.Actor{Principal: janitorPrincipal}))
+
+	// TLS and gRPC settings are not reloaded, the configuration is read once so as not to race with reloads
+	config := s.currentConfig()
+	addr := ":" + strconv.Itoa(s.port)
+	if !config.TLS.Enabled() {
+		if config.GRPC.Enabled() {
+			go s.serveGRPC(nil)
+		}
+		log.Printf("Listening on %s", addr)
+		log.Fatal(http.ListenAndServe(addr, s.handler()))
+	}
+
+	certs, err := newCertReloader(config.TLS.Cert, config.TLS.Key)
+	if err != nil {
+		log.Fatal(err)
+	}
+	s.mu.Lock()
+	s.certs = certs
+	s.mu.Unlock()
+	tlsConfig, err := newTLSConfig(config.TLS, certs)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	n.Run(":" + strconv.Itoa(s.port))
+	if config.GRPC.Enabled() {
+		go s.serveGRPC(tlsConfig)
+	}
+	if config.TLS.RedirectPort != 0 {
+		redirectAddr := ":" + strconv.Itoa(config.TLS.RedirectPort)
+		go func() {
+			log.Printf("Redirecting HTTP on %s to HTTPS", redirectAddr)
+			log.Fatal(http.ListenAndServe(redirectAddr, redirectHandler(s.port)))
+		}()
+	}
+
+	server := &http.Server{
+		Addr:      addr,
+		Handler:   s.handler(),
+		TLSConfig: tlsConfig,
+	}
+	log.Printf("Listening on %s (TLS)", addr)
+	// Certificates are provided by tlsConfig.GetCertificate
+	log.Fatal(server.ListenAndServeTLS("", ""))
+}
+
+// handler wires up the middleware and routes of the server
+func (s *Server) handler() http.Handler {
+	// Requests are logged by requestLog rather than negroni's plain text logger
+	n := negroni.New(negroni.HandlerFunc(s.requestLog), negroni.NewRecovery(), negroni.NewStatic(http.Dir("public")))
+	if config := s.currentConfig(); config.TLS.Enabled() {
+		n.Use(&clientCertAuth{principals: config.TLS.Principals})
+	}
+	n.UseHandler(router(s))
+	return n
 }
 
 // Run configures and runs tldrfeed Service
@@ -58,44 +281,115 @@ func Run(config Config) {
 func router(s *Server) http.Handler {
 	router := mux.NewRouter().PathPrefix(api.APIVersion).Subrouter()
 	setupRoutes(router, s)
+	router.Use(s.routeInfo, s.auditActor, s.dbTimeout, s.limiter.middleware, s.idempotency)
 	return router
 }
 
+// setupRoutes registers all API routes, route names identify routes in configuration (e.g. rate limits)
 func setupRoutes(r *mux.Router, s *Server) {
 	// User routes
 	//
 	// Create a User
-	r.HandleFunc("/users", s.createUserHandler()).Methods("POST")
+	r.HandleFunc("/users", s.createUserHandler()).Methods("POST").Name("createUser")
 	// List Users
-	r.HandleFunc("/users", s.getUserListHandler()).Methods("GET")
+	r.HandleFunc("/users", s.getUserListHandler()).Methods("GET").Name("listUsers")
 	// Get User
-	r.HandleFunc("/users/{userID}", s.getUserHandler()).Methods("GET")
+	r.HandleFunc("/users/{userID}", s.getUserHandler()).Methods("GET").Name("getUser")
 
 	// User feed and article retrieval
 	//
 	// Get all Feeds a Subscriber is following
-	r.HandleFunc("/users/{userID}/feeds", s.getUserFeedListHandler()).Methods("GET")
-	r.HandleFunc("/users/{userID}/feeds", s.addUserFeedHandler()).Methods("POST")
+	r.HandleFunc("/users/{userID}/feeds", s.getUserFeedListHandler()).Methods("GET").Name("listUserFeeds")
+	r.HandleFunc("/users/{userID}/feeds", s.addUserFeedHandler()).Methods("POST").Name("addUserFeed")
 
 	// Get a Feed a Subscriber is following
-	r.HandleFunc("/users/{userID}/feeds/{feedID}", s.getUserFeedHandler()).Methods("GET")
-	r.HandleFunc("/users/{userID}/feeds/{feedID}/articles", s.getUserFeedArticleListHandler()).Methods("GET")
-	r.HandleFunc("/users/{userID}/articles", s.getUserArticleListHandler()).Methods("GET")
+	r.HandleFunc("/users/{userID}/feeds/{feedID}", s.getUserFeedHandler()).Methods("GET").Name("getUserFeed")
+	r.HandleFunc("/users/{userID}/feeds/{feedID}", s.removeUserFeedHandler()).Methods("DELETE").Name("removeUserFeed")
+	r.HandleFunc("/users/{userID}/feeds/{feedID}/articles", s.getUserFeedArticleListHandler()).Methods("GET").Name("listUserFeedArticles")
+	r.HandleFunc("/users/{userID}/articles", s.getUserArticleListHandler()).Methods("GET").Name("listUserArticles")
+
+	// Starred Articles, which are kept regardless of retention policies
+	r.HandleFunc("/users/{userID}/starred", s.listStarredArticlesHandler()).Methods("GET").Name("listStarredArticles")
+	r.HandleFunc("/users/{userID}/starred/{articleID}", s.starArticleHandler()).Methods("PUT").Name("starArticle")
+	r.HandleFunc("/users/{userID}/starred/{articleID}", s.unstarArticleHandler()).Methods("DELETE").Name("unstarArticle")
+
+	// User filter rules
+	//
+	// List and add rules hiding Articles from a User's timeline
+	r.HandleFunc("/users/{userID}/filters", s.listFilterRulesHandler()).Methods("GET").Name("listFilterRules")
+	r.HandleFunc("/users/{userID}/filters", s.createFilterRuleHandler()).Methods("POST").Name("createFilterRule")
+	// Show which recent Articles a rule would hide without saving it
+	r.HandleFunc("/users/{userID}/filters/preview", s.previewFilterRuleHandler()).Methods("POST").Name("previewFilterRule")
+	// Get, replace or remove a rule
+	r.HandleFunc("/users/{userID}/filters/{ruleID}", s.getFilterRuleHandler()).Methods("GET").Name("getFilterRule")
+	r.HandleFunc("/users/{userID}/filters/{ruleID}", s.updateFilterRuleHandler()).Methods("PUT").Name("updateFilterRule")
+	r.HandleFunc("/users/{userID}/filters/{ruleID}", s.deleteFilterRuleHandler()).Methods("DELETE").Name("deleteFilterRule")
 
 	// Feed management routes
 	//
 	// List available Feeds
-	r.HandleFunc("/feeds", s.getFeedListHandler()).Methods("GET")
+	r.HandleFunc("/feeds", s.getFeedListHandler()).Methods("GET").Name("listFeeds")
 	// Get a Feed
-	r.HandleFunc("/feeds/{feedID}", s.getFeedHandler()).Methods("GET")
+	r.HandleFunc("/feeds/{feedID}", s.getFeedHandler()).Methods("GET").Name("getFeed")
 
 	// Create (sign up) a new Feed
-	r.HandleFunc("/feeds", s.createFeedHandler()).Methods("POST")
+	r.HandleFunc("/feeds", s.createFeedHandler()).Methods("POST").Name("createFeed")
+
+	// Feed retention routes
+	//
+	// Replace the retention policy of a Feed
+	r.HandleFunc("/feeds/{feedID}/retention", s.setFeedRetentionHandler()).Methods("PUT").Name("setFeedRetention")
+	// Show which Articles the retention policy would remove now, without removing them
+	r.HandleFunc("/feeds/{feedID}/retention/report", s.retentionReportHandler()).Methods("GET").Name("retentionReport")
 
 	// Feed articles routes
 	// List Articles in a Feed
-	r.HandleFunc("/feeds/{feedID}/articles", s.getFeedArticleListHandler()).Methods("GET")
+	r.HandleFunc("/feeds/{feedID}/articles", s.getFeedArticleListHandler()).Methods("GET").Name("listFeedArticles")
 	// Add Articles to a Feed
-	r.HandleFunc("/feeds/{feedID}/articles", s.createFeedArticleHandler()).Methods("POST")
+	r.HandleFunc("/feeds/{feedID}/articles", s.createFeedArticleHandler()).Methods("POST").Name("createFeedArticle")
+	// Add a batch of Articles to a Feed, given as a JSON array or NDJSON
+	r.HandleFunc("/feeds/{feedID}/articles:batch", s.createFeedArticlesHandler()).Methods("POST").Name("createFeedArticles")
+
+	// Feed draft routes
+	//
+	// List draft and scheduled Articles of a Feed
+	r.HandleFunc("/feeds/{feedID}/drafts", s.listFeedDraftsHandler()).Methods("GET").Name("listFeedDrafts")
+	// Get, edit (and publish or schedule) or discard a draft
+	r.HandleFunc("/feeds/{feedID}/drafts/{articleID}", s.getFeedDraftHandler()).Methods("GET").Name("getFeedDraft")
+	r.HandleFunc("/feeds/{feedID}/drafts/{articleID}", s.updateFeedDraftHandler()).Methods("PUT").Name("updateFeedDraft")
+	r.HandleFunc("/feeds/{feedID}/drafts/{articleID}", s.deleteFeedDraftHandler()).Methods("DELETE").Name("deleteFeedDraft")
 
+	// Hits and misses of cached repository queries
+	r.HandleFunc("/cache/stats", s.cacheStatsHandler()).Methods("GET").Name("cacheStats")
+
+	// Changes recorded in the audit log, filtered by entity, actor and time
+	r.HandleFunc("/admin/audit", s.listAuditRecordsHandler()).Methods("GET").Name("listAuditRecords")
+
+	// Search routes
+	//
+	// Search Articles in all Feeds
+	r.HandleFunc("/search", s.searchHandler()).Methods("GET").Name("search")
+	// Search Articles in the Feeds a User is following
+	r.HandleFunc("/users/{userID}/search", s.userSearchHandler()).Methods("GET").Name("userSearch")
+
+	// Discovery routes
+	//
+	// List Article tags with their Article counts
+	r.HandleFunc("/tags", s.listTagsHandler()).Methods("GET").Name("listTags")
+	// Suggest Feeds a User is not following
+	r.HandleFunc("/users/{userID}/discover", s.discoverFeedsHandler()).Methods("GET").Name("discoverFeeds")
+
+	// GraphQL routes
+	//
+	// Run a GraphQL operation
+	r.HandleFunc("/graphql", s.graphqlHandler()).Methods("POST").Name("graphql")
+	// Run a GraphQL query given as query parameters, or serve subscriptions over a websocket connection
+	r.HandleFunc("/graphql", s.graphqlHandler()).Methods("GET").Name("graphqlQuery")
+
+	// API description routes, every route above must be documented in routeDocs
+	//
+	// OpenAPI document describing the routes
+	r.HandleFunc("/openapi.json", s.openAPIHandler(r)).Methods("GET").Name("openapi")
+	// Browse the OpenAPI document
+	r.HandleFunc("/docs", s.docsHandler()).Methods("GET").Name("docs")
 }
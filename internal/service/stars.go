@@ -0,0 +1,62 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// starArticleHandler stars an Article for a User, exempting it from retention policies
+func (s *Server) starArticleHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		vars := mux.Vars(req)
+		if err := s.repo.StarArticle(req.Context(), vars["userID"], vars["articleID"]); err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
+
+		s.formatter.Text(w, http.StatusOK,
+			fmt.Sprintf("Successfully starred Article '%s' for User '%s'", vars["articleID"], vars["userID"]),
+		)
+	}
+}
+
+func (s *Server) unstarArticleHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		vars := mux.Vars(req)
+		if err := s.repo.UnstarArticle(req.Context(), vars["userID"], vars["articleID"]); err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
+
+		s.formatter.Text(w, http.StatusOK,
+			fmt.Sprintf("Successfully unstarred Article '%s' for User '%s'", vars["articleID"], vars["userID"]),
+		)
+	}
+}
+
+// listStarredArticlesHandler returns the Articles a User starred, most recently published first
+func (s *Server) listStarredArticlesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		view, err := articleView(req)
+		if err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		format, err := bodyFormat(req)
+		if err != nil {
+			s.formatter.Text(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		vars := mux.Vars(req)
+		articles, err := s.repo.ListStarredArticles(req.Context(), vars["userID"])
+		if err != nil {
+			s.formatter.Text(w, errorToStatus(err), err.Error())
+			return
+		}
+
+		s.formatter.JSON(w, http.StatusOK, s.presentArticles(articles, view, format))
+	}
+}
@@ -0,0 +1,26 @@
+package service
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+// dbTimeout is a mux middleware bounding the time a request spends in the DB. Handlers pass the request's context to
+// the repository, which checks it between DB calls. How a call already running reacts depends on the backend: SQL
+// drivers interrupt statements when the context is done, MongoDB reads are given the time left as their maximum
+// run time and abort past the deadline, but mgo cannot interrupt a call in flight when the client disconnects.
+// Websocket connections live as long as the client keeps them open, the operations they serve are bounded instead.
+func (s *Server) dbTimeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		timeout := s.currentConfig().DBTimeout
+		if timeout <= 0 || websocket.IsWebSocketUpgrade(req) {
+			next.ServeHTTP(w, req)
+			return
+		}
+		ctx, cancel := context.WithTimeout(req.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/if-ivan-else/tldrfeed/internal/db/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDBTimeout(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	config := testConfig()
+	config.DBTimeout = time.Minute
+	server := newServer(config, mock.NewRepository())
+	u, err := server.repo.CreateUser(ctx, "reader")
+	require.NoError(err)
+
+	// Requests carry the deadline to the repository
+	var deadline time.Time
+	handler := server.dbTimeout(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		deadline, _ = req.Context().Deadline()
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	require.WithinDuration(time.Now().Add(time.Minute), deadline, time.Second)
+
+	// Requests running out of time fail, requests the client gave up on are logged as such
+	expired, cancel := context.WithDeadline(ctx, time.Now().Add(-time.Second))
+	defer cancel()
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	statuses := map[context.Context]int{
+		ctx:      http.StatusOK,
+		expired:  http.StatusGatewayTimeout,
+		canceled: statusClientClosedRequest,
+	}
+	for reqCtx, status := range statuses {
+		req, _ := http.NewRequest("GET", "/api/v1/users/"+u.ID, nil)
+		rr := httptest.NewRecorder()
+		router(server).ServeHTTP(rr, req.WithContext(reqCtx))
+		requireStatus(status, require, rr)
+	}
+
+	// The timeout can be disabled
+	config.DBTimeout = 0
+	server.Reload(config)
+	deadline = time.Time{}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	require.True(deadline.IsZero())
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// certReloader serves a certificate key pair from disk, reloading it when the server is reloaded if either file
+// changed. Handshakes are served from memory, without touching the files.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func newCertReloader(certFile string, keyFile string) (*certReloader, error) {
+	c := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := c.maybeReload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// maybeReload loads the key pair if it has been modified since it was last loaded
+func (c *certReloader) maybeReload() error {
+	modTime, err := latestModTime(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+	if c.cert != nil && !modTime.After(c.modTime) {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to load TLS key pair '%s', '%s'", c.certFile, c.keyFile)
+	}
+	if c.cert != nil {
+		log.Printf("Reloaded TLS certificate from '%s'", c.certFile)
+	}
+	c.cert = &cert
+	c.modTime = modTime
+	return nil
+}
+
+// reload reloads the key pair if either file changed, keeping the previous certificate if reloading fails
+func (c *certReloader) reload() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.maybeReload(); err != nil {
+		log.Printf("Serving previous TLS certificate: %s", err)
+	}
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+func latestModTime(files ...string) (time.Time, error) {
+	var latest time.Time
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return latest, errors.Wrapf(err, "Failed to stat '%s'", f)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// newTLSConfig builds server side TLS configuration, serving the certificate of certs
+func newTLSConfig(config TLSConfig, certs *certReloader) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: certs.GetCertificate,
+	}
+
+	if config.ClientCA != "" {
+		pool, err := loadCertPool(config.ClientCA)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientCAs = pool
+	}
+
+	switch config.ClientAuth {
+	case ClientAuthRequest:
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		tlsConfig.ClientAuth = tls.NoClientCert
+	}
+	return tlsConfig, nil
+}
+
+func loadCertPool(file string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read CA bundle '%s'", file)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.Errorf("No PEM certificates found in CA bundle '%s'", file)
+	}
+	return pool, nil
+}
+
+type principalKey struct{}
+
+// principalFromRequest returns the principal authenticated by client certificate, if any
+func principalFromRequest(req *http.Request) string {
+	p, _ := req.Context().Value(principalKey{}).(string)
+	return p
+}
+
+// clientCertAuth is a middleware mapping verified client certificate subjects to principals
+type clientCertAuth struct {
+	principals []PrincipalMapping
+}
+
+func (a *clientCertAuth) principal(cert *x509.Certificate) (string, bool) {
+	if len(a.principals) == 0 {
+		return cert.Subject.CommonName, true
+	}
+	subject := cert.Subject.String()
+	for _, m := range a.principals {
+		if m.Subject == subject || m.Subject == cert.Subject.CommonName {
+			return m.Principal, true
+		}
+	}
+	return "", false
+}
+
+func (a *clientCertAuth) ServeHTTP(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 {
+		next(w, req)
+		return
+	}
+
+	cert := req.TLS.VerifiedChains[0][0]
+	principal, ok := a.principal(cert)
+	if !ok {
+		http.Error(w, "Client certificate subject is not mapped to a principal", http.StatusForbidden)
+		return
+	}
+	next(w, req.WithContext(context.WithValue(req.Context(), principalKey{}, principal)))
+}
+
+// redirectHandler redirects plain HTTP requests to the HTTPS server on the given port
+func redirectHandler(httpsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		host := req.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+		}
+
+		status := http.StatusPermanentRedirect
+		if req.Method == "GET" || req.Method == "HEAD" {
+			status = http.StatusMovedPermanently
+		}
+		http.Redirect(w, req, "https://"+host+req.URL.RequestURI(), status)
+	})
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/codegangsta/negroni"
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/stretchr/testify/require"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// newTestCert generates a certificate signed by parent, or a self-signed CA when parent is nil
+func newTestCert(require *require.Assertions, subject pkix.Name, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(err)
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(err)
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func (c *testCert) write(require *require.Assertions, dir string, name string) (certFile string, keyFile string) {
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	require.NoError(ioutil.WriteFile(certFile, c.certPEM, 0600))
+	require.NoError(ioutil.WriteFile(keyFile, c.keyPEM, 0600))
+	return certFile, keyFile
+}
+
+func testTempDir(require *require.Assertions) string {
+	dir, err := ioutil.TempDir("", "tldrfeed-tls")
+	require.NoError(err)
+	return dir
+}
+
+func TestClientOverTLS(t *testing.T) {
+	require := require.New(t)
+	dir := testTempDir(require)
+	defer os.RemoveAll(dir)
+
+	ts := httptest.NewTLSServer(router(testServer()))
+	defer ts.Close()
+
+	caFile := filepath.Join(dir, "ca.crt")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	require.NoError(ioutil.WriteFile(caFile, caPEM, 0600))
+
+	// Server certificate is not trusted by default
+	_, err := api.NewClient(ts.URL).ListUsers()
+	require.Error(err)
+
+	tlsConfig, err := api.NewTLSConfig(caFile, "", "")
+	require.NoError(err)
+	c := api.NewClient(ts.URL, api.WithTLSConfig(tlsConfig))
+
+	_, err = c.CreateUser("ekaterina")
+	require.NoError(err)
+	users, err := c.ListUsers()
+	require.NoError(err)
+	require.Len(users, 1)
+}
+
+func TestMutualTLSPrincipals(t *testing.T) {
+	require := require.New(t)
+	dir := testTempDir(require)
+	defer os.RemoveAll(dir)
+
+	ca := newTestCert(require, pkix.Name{CommonName: "tldrfeed test CA"}, nil)
+	caFile, _ := ca.write(require, dir, "ca")
+	certFile, keyFile := newTestCert(require, pkix.Name{CommonName: "localhost"}, ca).write(require, dir, "server")
+	publisherCert, publisherKey := newTestCert(require, pkix.Name{CommonName: "publisher", Organization: []string{"Acme"}}, ca).write(require, dir, "publisher")
+	strangerCert, strangerKey := newTestCert(require, pkix.Name{CommonName: "stranger"}, ca).write(require, dir, "stranger")
+
+	config := TLSConfig{
+		Cert:       certFile,
+		Key:        keyFile,
+		ClientCA:   caFile,
+		ClientAuth: ClientAuthRequire,
+		Principals: []PrincipalMapping{
+			{Subject: "CN=publisher,O=Acme", Principal: "acme-publisher"},
+		},
+	}
+	certs, err := newCertReloader(config.Cert, config.Key)
+	require.NoError(err)
+	tlsConfig, err := newTLSConfig(config, certs)
+	require.NoError(err)
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	require.NoError(err)
+	defer l.Close()
+
+	n := negroni.New(&clientCertAuth{principals: config.Principals})
+	n.UseHandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(principalFromRequest(req)))
+	})
+	go http.Serve(l, n)
+
+	get := func(certFile string, keyFile string) (*http.Response, error) {
+		clientConfig, err := api.NewTLSConfig(caFile, certFile, keyFile)
+		require.NoError(err)
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}}
+		return client.Get("https://" + l.Addr().String())
+	}
+
+	resp, err := get(publisherCert, publisherKey)
+	require.NoError(err)
+	require.Equal(http.StatusOK, resp.StatusCode)
+	principal, _ := ioutil.ReadAll(resp.Body)
+	require.Equal("acme-publisher", string(principal))
+
+	resp, err = get(strangerCert, strangerKey)
+	require.NoError(err)
+	require.Equal(http.StatusForbidden, resp.StatusCode)
+
+	// Client certificate is required
+	_, err = get("", "")
+	require.Error(err)
+}
+
+func TestCertReload(t *testing.T) {
+	require := require.New(t)
+	dir := testTempDir(require)
+	defer os.RemoveAll(dir)
+
+	ca := newTestCert(require, pkix.Name{CommonName: "tldrfeed test CA"}, nil)
+	first := newTestCert(require, pkix.Name{CommonName: "localhost"}, ca)
+	certFile, keyFile := first.write(require, dir, "server")
+
+	r, err := newCertReloader(certFile, keyFile)
+	require.NoError(err)
+	cert, err := r.GetCertificate(nil)
+	require.NoError(err)
+	require.Equal(first.certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}))
+
+	second := newTestCert(require, pkix.Name{CommonName: "localhost"}, ca)
+	second.write(require, dir, "server")
+	later := time.Now().Add(time.Minute)
+	require.NoError(os.Chtimes(certFile, later, later))
+
+	// Changed files are only loaded when the server is reloaded
+	cert, err = r.GetCertificate(nil)
+	require.NoError(err)
+	require.Equal(first.certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}))
+
+	server := testServer()
+	server.certs = r
+	server.Reload(testConfig())
+	cert, err = r.GetCertificate(nil)
+	require.NoError(err)
+	require.Equal(second.certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}))
+
+	// Broken files keep the previous certificate in service
+	require.NoError(ioutil.WriteFile(certFile, []byte("garbage"), 0600))
+	require.NoError(os.Chtimes(certFile, later.Add(time.Minute), later.Add(time.Minute)))
+	server.Reload(testConfig())
+	cert, err = r.GetCertificate(nil)
+	require.NoError(err)
+	require.Equal(second.certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}))
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	require := require.New(t)
+
+	req, _ := http.NewRequest("GET", "http://example.com:8080/api/v1/users?limit=1", nil)
+	rr := httptest.NewRecorder()
+	redirectHandler(8443).ServeHTTP(rr, req)
+
+	requireStatus(http.StatusMovedPermanently, require, rr)
+	require.Equal("https://example.com:8443/api/v1/users?limit=1", rr.Header().Get("Location"))
+
+	req, _ = http.NewRequest("POST", "http://example.com/api/v1/users", nil)
+	rr = httptest.NewRecorder()
+	redirectHandler(443).ServeHTTP(rr, req)
+
+	requireStatus(http.StatusPermanentRedirect, require, rr)
+	require.Equal("https://example.com/api/v1/users", rr.Header().Get("Location"))
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/if-ivan-else/tldrfeed/internal/buildinfo"
+	"github.com/if-ivan-else/tldrfeed/internal/db/sql"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the spans of requests
+const tracerName = "github.com/if-ivan-else/tldrfeed/internal/service"
+
+// serviceName identifies the server in exported spans
+const serviceName = "tldrfeed"
+
+// setupTracing installs the global tracer provider exporting spans as configured, returning a function flushing and
+// stopping the exporter. Trace context is read from W3C traceparent and baggage headers even when tracing is disabled,
+// so that request logs carry the caller's trace ID.
+func setupTracing(config TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch config.Exporter {
+	case TracingExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case TracingExporterOTLP:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(config.Endpoint)}
+		if config.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(context.Background(), opts...)
+	default:
+		return func(context.Context) error { return nil }, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", serviceName),
+			attribute.String("service.version", buildinfo.Version),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// tracer returns the tracer of request spans, from the global provider so that it follows setupTracing
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// dbSystem names the DB of a connection URL in the db.system attribute of DB call spans
+func dbSystem(url string) string {
+	switch {
+	case strings.HasPrefix(url, sql.SchemeSQLite):
+		return "sqlite"
+	case sql.Supports(url):
+		return "postgresql"
+	default:
+		return "mongodb"
+	}
+}
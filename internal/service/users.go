@@ -5,6 +5,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/db"
 )
 
 func (s *Server) createUserHandler() http.HandlerFunc {
@@ -16,7 +17,7 @@ func (s *Server) createUserHandler() http.HandlerFunc {
 			return
 		}
 
-		user, err := s.repo.CreateUser(userRequest.Name)
+		user, err := s.repo.CreateUser(req.Context(), userRequest.Name)
 		if err != nil {
 			s.formatter.Text(w, errorToStatus(err), err.Error())
 			return
@@ -28,7 +29,7 @@ func (s *Server) createUserHandler() http.HandlerFunc {
 
 func (s *Server) getUserListHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-		users, err := s.repo.ListUsers()
+		users, err := s.repo.ListUsers(req.Context(), db.Page{})
 		if err != nil {
 			s.formatter.Text(w, errorToStatus(err), err.Error())
 			return
@@ -41,7 +42,7 @@ func (s *Server) getUserListHandler() http.HandlerFunc {
 func (s *Server) getUserHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		vars := mux.Vars(req)
-		user, err := s.repo.GetUser(vars["userID"])
+		user, err := s.repo.GetUser(req.Context(), vars["userID"])
 		if err != nil {
 			s.formatter.Text(w, errorToStatus(err), err.Error())
 			return
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -75,10 +76,11 @@ func TestCreateValidUser(t *testing.T) {
 }
 
 func TestListUsers(t *testing.T) {
+	ctx := context.Background()
 	require := require.New(t)
 
 	server := testServer()
-	server.repo.CreateUser("natasha")
+	server.repo.CreateUser(ctx, "natasha")
 
 	req, _ := http.NewRequest("GET", "/api/v1/users", nil)
 	rr := httptest.NewRecorder()
@@ -108,10 +110,11 @@ func TestGetUnknownUser(t *testing.T) {
 }
 
 func TestGetUser(t *testing.T) {
+	ctx := context.Background()
 	require := require.New(t)
 
 	server := testServer()
-	user, _ := server.repo.CreateUser("alexey")
+	user, _ := server.repo.CreateUser(ctx, "alexey")
 
 	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/v1/users/%s", user.ID), nil)
 	rr := httptest.NewRecorder()
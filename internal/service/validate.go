@@ -20,3 +20,19 @@ func decodeAndValidate(r *http.Request, v interface{}) error {
 	_, err := valid.ValidateStruct(v)
 	return err
 }
+
+// unmarshalAndValidate performs JSON decoding of data and validates it using govalidator annotations
+func unmarshalAndValidate(data []byte, v interface{}) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+
+	_, err := valid.ValidateStruct(v)
+	return err
+}
+
+// validate validates a request decoded by other means (e.g. from a gRPC message) using govalidator annotations
+func validate(v interface{}) error {
+	_, err := valid.ValidateStruct(v)
+	return err
+}
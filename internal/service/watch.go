@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/if-ivan-else/tldrfeed/api"
+	"github.com/if-ivan-else/tldrfeed/internal/rules"
+)
+
+const (
+	// watchBuffer is how many published Articles a watcher may fall behind by before it is dropped, a whole batch of
+	// Articles fits so that publishing a batch does not drop the watchers of its Feed
+	watchBuffer = maxBatchArticles
+	// watchRefresh is how often streams check whether the subscriptions of their User changed and reload the User's
+	// filter rules
+	watchRefresh = 5 * time.Second
+)
+
+// articleWatchers fans the Articles published out to the streams watching them, gRPC streams and GraphQL
+// subscriptions. It is notified by a publish hook, which must not block, so watchers falling behind are dropped rather
+// than waited for.
+type articleWatchers struct {
+	mu       sync.Mutex
+	watchers map[*watcher]struct{}
+}
+
+// watcher receives the Articles published to the Feeds a stream follows. Articles are handed out by pointer, keeping
+// the buffers of watchers small, and must not be modified.
+type watcher struct {
+	articles chan *api.Article
+	tag      string
+	// feeds are the IDs of the Feeds followed, every Feed's Articles are received until they are set
+	feeds map[string]bool
+}
+
+func newArticleWatchers() *articleWatchers {
+	return &articleWatchers{watchers: make(map[*watcher]struct{})}
+}
+
+// publish hands a published Article to the watchers following its Feed, closing the channels of the watchers whose
+// buffer is full
+func (w *articleWatchers) publish(article api.Article) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for wt := range w.watchers {
+		if (wt.feeds != nil && !wt.feeds[article.FeedID]) || (wt.tag != "" && !hasTag(article, wt.tag)) {
+			continue
+		}
+		select {
+		case wt.articles <- &article:
+		default:
+			delete(w.watchers, wt)
+			close(wt.articles)
+		}
+	}
+}
+
+// watch returns a watcher receiving every Article carrying a tag published from now on, its channel closed when the
+// watcher falls behind, and a function to stop watching. An empty tag matches all Articles.
+func (w *articleWatchers) watch(tag string) (*watcher, func()) {
+	wt := &watcher{articles: make(chan *api.Article, watchBuffer), tag: tag}
+	w.mu.Lock()
+	w.watchers[wt] = struct{}{}
+	w.mu.Unlock()
+
+	return wt, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if _, ok := w.watchers[wt]; ok {
+			delete(w.watchers, wt)
+			close(wt.articles)
+		}
+	}
+}
+
+// follow narrows the Articles a watcher receives down to the ones published to some Feeds
+func (w *articleWatchers) follow(wt *watcher, feedIDs []string) {
+	feeds := make(map[string]bool, len(feedIDs))
+	for _, id := range feedIDs {
+		feeds[id] = true
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	wt.feeds = feeds
+}
+
+// count returns the number of watchers
+func (w *articleWatchers) count() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.watchers)
+}
+
+// userWatch watches the Articles published to a User's timeline: published to a Feed the User follows, carrying the
+// watched tag if any and not hidden by the User's filter rules. The User's subscriptions are loaded as the watch
+// starts and again once their subscriptions_updated_at time moves; the filter rules are reloaded with them and on
+// every refresh.
+type userWatch struct {
+	s       *Server
+	userID  string
+	watcher *watcher
+	stop    func()
+	ticker  *time.Ticker
+
+	feeds                map[string]bool
+	rules                *rules.Set
+	subscriptionsUpdated time.Time
+}
+
+// watchUser starts watching the Articles published to a User's timeline, the watch must be closed once done with
+func (s *Server) watchUser(ctx context.Context, userID string, tag string) (*userWatch, error) {
+	w := &userWatch{s: s, userID: userID}
+	// Watching starts before the subscriptions are loaded so that no Article published in between is missed
+	w.watcher, w.stop = s.watchers.watch(tag)
+	if err := w.refresh(ctx); err != nil {
+		w.stop()
+		return nil, err
+	}
+	w.ticker = time.NewTicker(watchRefresh)
+	return w, nil
+}
+
+// articles returns the channel of the Articles published to the Feeds followed, closed when the watch falls behind
+func (w *userWatch) articles() <-chan *api.Article {
+	return w.watcher.articles
+}
+
+// refreshes returns the channel ticking when the watch is to be refreshed
+func (w *userWatch) refreshes() <-chan time.Time {
+	return w.ticker.C
+}
+
+// refresh reloads the User's filter rules, and their subscriptions when they changed since they were loaded
+func (w *userWatch) refresh(ctx context.Context) error {
+	version, err := w.s.repo.GetTimelineVersion(ctx, w.userID)
+	if err != nil {
+		return err
+	}
+	if w.feeds == nil || !version.SubscriptionsUpdatedTime.Equal(w.subscriptionsUpdated) {
+		feeds, err := w.s.repo.ListUserFeeds(ctx, w.userID)
+		if err != nil {
+			return err
+		}
+		feedIDs := make([]string, len(feeds))
+		w.feeds = make(map[string]bool, len(feeds))
+		for i, f := range feeds {
+			feedIDs[i] = f.ID
+			w.feeds[f.ID] = true
+		}
+		w.s.watchers.follow(w.watcher, feedIDs)
+		w.subscriptionsUpdated = version.SubscriptionsUpdatedTime
+	}
+
+	w.rules, _, err = w.s.userRules(ctx, w.userID)
+	return err
+}
+
+// watches returns true when an Article received belongs in the User's timeline. Articles published before the
+// subscriptions were first loaded may be of other Feeds.
+func (w *userWatch) watches(article *api.Article) bool {
+	return w.feeds[article.FeedID] && !w.rules.Hides(article.FeedID, article)
+}
+
+func (w *userWatch) close() {
+	w.ticker.Stop()
+	w.stop()
+}
+
+func hasTag(article api.Article, tag string) bool {
+	for _, t := range article.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
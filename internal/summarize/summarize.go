@@ -0,0 +1,121 @@
+// Package summarize computes extractive TL;DR summaries of Articles
+package summarize
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Summarization strategies
+const (
+	// StrategyTextRank picks the most central sentences of an Article using TextRank
+	StrategyTextRank = "textrank"
+	// StrategyLead picks the leading sentences of an Article
+	StrategyLead = "lead"
+)
+
+// Strategies lists all summarization strategies
+var Strategies = []string{StrategyTextRank, StrategyLead}
+
+// Summarizer computes a summary of an Article
+type Summarizer interface {
+	// Summarize returns a summary of an Article's body, the title may be used as a hint
+	Summarize(title string, body string) string
+}
+
+// New creates a Summarizer using the given strategy, picking up to the given number of sentences
+func New(strategy string, sentences int) (Summarizer, error) {
+	if sentences < 1 {
+		return nil, fmt.Errorf("Summary must have at least one sentence, got %d", sentences)
+	}
+	switch strategy {
+	case StrategyTextRank:
+		return &TextRank{Sentences: sentences}, nil
+	case StrategyLead:
+		return &Lead{Sentences: sentences}, nil
+	default:
+		return nil, fmt.Errorf("Unknown summary strategy '%s', expected one of %s", strategy, strings.Join(Strategies, ", "))
+	}
+}
+
+// Lead summarizes Articles with their first sentences, which works well for news written as an inverted pyramid
+type Lead struct {
+	Sentences int
+}
+
+// Summarize implements Summarizer
+func (l *Lead) Summarize(title string, body string) string {
+	sentences := splitSentences(body)
+	if len(sentences) > l.Sentences {
+		sentences = sentences[:l.Sentences]
+	}
+	return strings.Join(sentences, " ")
+}
+
+// splitSentences splits a text into sentences ending with terminal punctuation or a paragraph break
+func splitSentences(text string) []string {
+	sentences := []string{}
+	runes := []rune(text)
+	start := 0
+	flush := func(end int) {
+		s := strings.Join(strings.Fields(string(runes[start:end])), " ")
+		if s != "" {
+			sentences = append(sentences, s)
+		}
+		start = end
+	}
+
+	for i, r := range runes {
+		switch {
+		case r == '\n':
+			// Blank lines end paragraphs (and headings), single line breaks only wrap text
+			next := i + 1
+			for next < len(runes) && (runes[next] == ' ' || runes[next] == '\t' || runes[next] == '\r') {
+				next++
+			}
+			if next < len(runes) && runes[next] == '\n' {
+				flush(next)
+			}
+		case r == '.' || r == '!' || r == '?':
+			// Sentences end before whitespace, allowing for closing quotes and brackets
+			next := i + 1
+			for next < len(runes) && strings.ContainsRune(`"')]»”’`, runes[next]) {
+				next++
+			}
+			if next == len(runes) || unicode.IsSpace(runes[next]) {
+				flush(next)
+			}
+		}
+	}
+	flush(len(runes))
+	return sentences
+}
+
+// words returns the distinct lower-cased content words of a sentence
+func words(sentence string) map[string]bool {
+	set := map[string]bool{}
+	for _, w := range strings.FieldsFunc(sentence, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		w = strings.ToLower(w)
+		if len([]rune(w)) > 1 && !stopWords[w] {
+			set[w] = true
+		}
+	}
+	return set
+}
+
+// stopWords are common English words that carry no meaning on their own
+var stopWords = map[string]bool{}
+
+func init() {
+	for _, w := range strings.Fields(`a about above after again against all am an and any are as at be because been
+		before being below between both but by can could did do does doing down during each few for from further had
+		has have having he her here hers herself him himself his how i if in into is it its itself just me more most
+		my myself no nor not now of off on once only or other our ours ourselves out over own same she should so some
+		such than that the their theirs them themselves then there these they this those through to too under until up
+		very was we were what when where which while who whom why will with would you your yours yourself yourselves`) {
+		stopWords[w] = true
+	}
+}
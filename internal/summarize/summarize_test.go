@@ -0,0 +1,78 @@
+package summarize
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const article = `The city council approved the new budget on Monday. The budget raises spending on public transport
+and road repairs. Council members debated the transport budget for six hours. Local weather was sunny.
+Critics say the budget ignores housing, while supporters point to transport investment.`
+
+func TestSplitSentences(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal([]string{
+		"He said: \"Stop!\"",
+		"Version 1.2 shipped (finally.)",
+		"A heading",
+		"Is it?",
+		"Yes",
+	}, splitSentences("He said: \"Stop!\" Version 1.2 shipped (finally.)\nA heading\n\nIs it? Yes"))
+	require.Empty(splitSentences("  \n "))
+}
+
+func TestTextRank(t *testing.T) {
+	require := require.New(t)
+
+	s, err := New(StrategyTextRank, 2)
+	require.NoError(err)
+	summary := s.Summarize("Council approves budget", article)
+
+	// Sentences about the transport budget are central, the weather is not, and the order is kept
+	require.Equal("The budget raises spending on public transport and road repairs. "+
+		"Council members debated the transport budget for six hours.", summary)
+	require.NotContains(summary, "weather")
+
+	// Short articles are their own summary
+	require.Equal("Just one sentence.", s.Summarize("", "Just one   sentence."))
+}
+
+func TestTextRankLongArticles(t *testing.T) {
+	require := require.New(t)
+
+	s, err := New(StrategyTextRank, 2)
+	require.NoError(err)
+
+	// Only the leading sentences are ranked, central sentences further down are left out
+	body := article + strings.Repeat(" Filler sentence number one.", maxRankedSentences) +
+		strings.Repeat(" The transport budget for public transport was debated by the council.", 1000)
+	summary := s.Summarize("Council approves budget", body)
+	require.Equal("The budget raises spending on public transport and road repairs. "+
+		"Council members debated the transport budget for six hours.", summary)
+
+	// Articles whose leading sentences are too long to rank are summarized by their lead
+	long := strings.Repeat("word ", maxRankedBytes/5+1) + "end. Second sentence. Third sentence."
+	require.Equal(strings.TrimSpace(strings.Repeat("word ", maxRankedBytes/5+1))+" end. Second sentence.",
+		s.Summarize("", long))
+}
+
+func TestLead(t *testing.T) {
+	require := require.New(t)
+
+	s, err := New(StrategyLead, 2)
+	require.NoError(err)
+	require.Equal("The city council approved the new budget on Monday. "+
+		"The budget raises spending on public transport and road repairs.", s.Summarize("", article))
+}
+
+func TestNew(t *testing.T) {
+	require := require.New(t)
+
+	_, err := New("abstractive", 3)
+	require.Error(err)
+	_, err = New(StrategyLead, 0)
+	require.Error(err)
+}
@@ -0,0 +1,140 @@
+package summarize
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// TextRank parameters
+const (
+	damping       = 0.85
+	maxIterations = 50
+	convergence   = 1e-4
+	// titleBoost weighs how much sharing words with the title makes a sentence more relevant
+	titleBoost = 0.5
+	// Every pair of sentences is compared, so only the leading sentences of long Articles are ranked: up to
+	// maxRankedSentences of them, of up to maxRankedBytes in all
+	maxRankedSentences = 200
+	maxRankedBytes     = 32 << 10
+)
+
+// TextRank summarizes Articles with their most central sentences: sentences are ranked with PageRank over a graph
+// linking sentences by the words they share, the best ones are kept in their original order. Articles too long to
+// rank are summarized with the best of their leading sentences, or with their lead when not even those can be ranked.
+type TextRank struct {
+	Sentences int
+}
+
+// Summarize implements Summarizer
+func (t *TextRank) Summarize(title string, body string) string {
+	sentences := splitSentences(body)
+	if len(sentences) <= t.Sentences {
+		return strings.Join(sentences, " ")
+	}
+	if leading := rankable(sentences); len(leading) > t.Sentences {
+		sentences = leading
+	} else {
+		return strings.Join(sentences[:t.Sentences], " ")
+	}
+
+	bags := make([]map[string]bool, len(sentences))
+	for i, s := range sentences {
+		bags[i] = words(s)
+	}
+
+	// weights[i][j] is the similarity of sentences i and j, totals[i] sums the similarities of sentence i
+	weights := make([][]float64, len(sentences))
+	totals := make([]float64, len(sentences))
+	for i := range sentences {
+		weights[i] = make([]float64, len(sentences))
+		for j := range sentences {
+			if i != j {
+				weights[i][j] = similarity(bags[i], bags[j])
+				totals[i] += weights[i][j]
+			}
+		}
+	}
+
+	scores := make([]float64, len(sentences))
+	for i := range scores {
+		scores[i] = 1
+	}
+	for iteration := 0; iteration < maxIterations; iteration++ {
+		delta := 0.0
+		next := make([]float64, len(sentences))
+		for i := range sentences {
+			rank := 0.0
+			for j := range sentences {
+				if weights[j][i] > 0 {
+					rank += weights[j][i] / totals[j] * scores[j]
+				}
+			}
+			next[i] = 1 - damping + damping*rank
+			delta += math.Abs(next[i] - scores[i])
+		}
+		scores = next
+		if delta < convergence {
+			break
+		}
+	}
+
+	if titleWords := words(title); len(titleWords) > 0 {
+		for i := range scores {
+			scores[i] *= 1 + titleBoost*overlap(bags[i], titleWords)/float64(len(titleWords))
+		}
+	}
+
+	// Pick the best sentences, earlier ones winning ties, and restore their order
+	ranked := make([]int, len(sentences))
+	for i := range ranked {
+		ranked[i] = i
+	}
+	sort.SliceStable(ranked, func(a, b int) bool { return scores[ranked[a]] > scores[ranked[b]] })
+	picked := ranked[:t.Sentences]
+	sort.Ints(picked)
+
+	summary := make([]string, len(picked))
+	for i, p := range picked {
+		summary[i] = sentences[p]
+	}
+	return strings.Join(summary, " ")
+}
+
+// rankable returns the leading sentences that are ranked
+func rankable(sentences []string) []string {
+	size := 0
+	for i, s := range sentences {
+		size += len(s)
+		if i == maxRankedSentences || size > maxRankedBytes {
+			return sentences[:i]
+		}
+	}
+	return sentences
+}
+
+// similarity is the TextRank similarity of two sentences: their overlap normalized by their lengths
+func similarity(a map[string]bool, b map[string]bool) float64 {
+	common := overlap(a, b)
+	if common == 0 {
+		return 0
+	}
+	norm := math.Log(float64(len(a))) + math.Log(float64(len(b)))
+	if norm <= 0 {
+		norm = 1
+	}
+	return common / norm
+}
+
+func overlap(a map[string]bool, b map[string]bool) float64 {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	common := 0
+	for w := range a {
+		if b[w] {
+			common++
+		}
+	}
+	return float64(common)
+}